@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// sortedSessionNames returns the configured session names in order
+func sortedSessionNames(config *Config) []string {
+	var names []string
+	for name, info := range config.Sessions {
+		if info != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// attachSession attaches the terminal to a session by name from any
+// directory, starting its tmux session in the stored path if it isn't running.
+// A restarted session continues its conversation when it has a transcript.
+func attachSession(name string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("no config found: %w", err)
+	}
+	info := config.Sessions[name]
+	if info == nil {
+		names := sortedSessionNames(config)
+		if len(names) == 0 {
+			return fmt.Errorf("session '%s' not found", name)
+		}
+		return fmt.Errorf("session '%s' not found (sessions: %s)", name, strings.Join(names, ", "))
+	}
+
+	tmuxName := sessionName(name)
+	if !tmuxSessionExists(tmuxName) {
+		workDir := info.Path
+		if workDir == "" {
+			workDir = resolveProjectPath(config, name)
+		}
+		if _, err := os.Stat(workDir); os.IsNotExist(err) {
+			return fmt.Errorf("session directory %s no longer exists", workDir)
+		}
+		if err := createTmuxSession(tmuxName, workDir, latestTranscript(workDir) != ""); err != nil {
+			return err
+		}
+	}
+	return attachTmuxSession(tmuxName)
+}
+
+// handleAttachCommand implements `ccc attach [--names] [session]`. Without a
+// session it lists them; --names prints bare names for shell completion.
+func handleAttachCommand(args []string) error {
+	if len(args) > 0 && args[0] != "--names" {
+		return attachSession(args[0])
+	}
+	config, err := loadConfig()
+	if err != nil {
+		if len(args) > 0 {
+			return nil // completion in an unconfigured shell: offer nothing
+		}
+		return fmt.Errorf("no config found: %w", err)
+	}
+	for _, name := range sortedSessionNames(config) {
+		if len(args) > 0 {
+			fmt.Println(name)
+			continue
+		}
+		state := "stopped"
+		if tmuxSessionExists(sessionName(name)) {
+			state = "running"
+		}
+		fmt.Printf("%-20s %-8s %s\n", name, state, config.Sessions[name].Path)
+	}
+	return nil
+}
+
+// cliCommands are the subcommands offered by shell completion
+var cliCommands = []string{
+	"attach", "away", "batch", "completion", "config", "cost", "doctor", "export", "gc", "github-listen", "headless", "health", "install", "listen", "logs", "ls",
+	"pair", "receive", "relay", "rpc", "search", "send", "setgroup", "setup", "start", "uninstall", "web",
+}
+
+// completionScript returns a completion script for bash, zsh or fish that
+// completes subcommands and, after attach or export, session names
+func completionScript(shell string) (string, error) {
+	cmds := strings.Join(cliCommands, " ")
+	switch shell {
+	case "bash":
+		return fmt.Sprintf(`_ccc() {
+    local cur=${COMP_WORDS[COMP_CWORD]}
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+    elif [ "$COMP_CWORD" -eq 2 ] && { [ "${COMP_WORDS[1]}" = attach ] || [ "${COMP_WORDS[1]}" = export ]; }; then
+        COMPREPLY=($(compgen -W "$(ccc attach --names 2>/dev/null)" -- "$cur"))
+    fi
+}
+complete -F _ccc ccc
+`, cmds), nil
+	case "zsh":
+		return fmt.Sprintf(`#compdef ccc
+_ccc() {
+    if (( CURRENT == 2 )); then
+        compadd -- %s
+    elif (( CURRENT == 3 )) && [[ $words[2] == (attach|export) ]]; then
+        compadd -- ${(f)"$(ccc attach --names 2>/dev/null)"}
+    fi
+}
+compdef _ccc ccc
+`, cmds), nil
+	case "fish":
+		return fmt.Sprintf(`complete -c ccc -f
+complete -c ccc -n "__fish_use_subcommand" -a "%s"
+complete -c ccc -n "__fish_seen_subcommand_from attach export" -a "(ccc attach --names 2>/dev/null)"
+`, cmds), nil
+	}
+	return "", fmt.Errorf("unsupported shell %q (use bash, zsh or fish)", shell)
+}
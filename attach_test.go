@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAttachSessionUnknown(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	data := []byte(`{"bot_token": "test", "chat_id": 123, "sessions": {"beta": {"topic_id": 2, "path": "/b"}, "alpha": {"topic_id": 1, "path": "/a"}}}`)
+	if err := os.WriteFile(filepath.Join(tmpDir, ".ccc.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := attachSession("gamma")
+	if err == nil || !strings.Contains(err.Error(), "sessions: alpha, beta") {
+		t.Errorf("attachSession(unknown) = %v, want the known sessions listed", err)
+	}
+	err = attachSession("alpha")
+	if err == nil || !strings.Contains(err.Error(), "no longer exists") {
+		t.Errorf("attachSession with a missing directory = %v, want an error", err)
+	}
+}
+
+func TestCompletionScript(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		script, err := completionScript(shell)
+		if err != nil {
+			t.Fatalf("completionScript(%s): %v", shell, err)
+		}
+		if !strings.Contains(script, "ccc attach --names") || !strings.Contains(script, "attach") {
+			t.Errorf("%s completion does not complete session names:\n%s", shell, script)
+		}
+	}
+	if _, err := completionScript("tcsh"); err == nil {
+		t.Error("completionScript(tcsh) should fail")
+	}
+}
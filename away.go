@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// awaySchedule is when you're away by the clock: time windows (which may wrap
+// past midnight) and whole days. Being inside either counts.
+type awaySchedule struct {
+	windows [][2]int // minutes since midnight, [start, end)
+	days    map[time.Weekday]bool
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseAwaySchedule parses a schedule like "18:00-09:00 weekends" or
+// "22:00-07:00,sat,sun". Days are mon..sun, "weekends" or "weekdays".
+func parseAwaySchedule(spec string) (*awaySchedule, error) {
+	s := &awaySchedule{days: make(map[time.Weekday]bool)}
+	fields := strings.FieldsFunc(strings.ToLower(spec), func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty schedule")
+	}
+	for _, f := range fields {
+		switch {
+		case f == "weekends":
+			s.days[time.Saturday], s.days[time.Sunday] = true, true
+		case f == "weekdays":
+			for d := time.Monday; d <= time.Friday; d++ {
+				s.days[d] = true
+			}
+		case strings.Contains(f, "-"):
+			parts := strings.SplitN(f, "-", 2)
+			start, err := parseClock(parts[0])
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseClock(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			s.windows = append(s.windows, [2]int{start, end})
+		default:
+			d, ok := weekdayNames[f]
+			if !ok && len(f) > 3 {
+				d, ok = weekdayNames[f[:3]]
+			}
+			if !ok {
+				return nil, fmt.Errorf("unknown schedule part %q (use HH:MM-HH:MM, mon..sun, weekends or weekdays)", f)
+			}
+			s.days[d] = true
+		}
+	}
+	return s, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (use HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether t falls inside the schedule
+func (s *awaySchedule) contains(t time.Time) bool {
+	if s.days[t.Weekday()] {
+		return true
+	}
+	m := t.Hour()*60 + t.Minute()
+	for _, w := range s.windows {
+		if w[0] <= w[1] {
+			if m >= w[0] && m < w[1] {
+				return true
+			}
+		} else if m >= w[0] || m < w[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// lastTerminalActivity returns the latest time a terminal was attached to or
+// typed into any tmux session, or zero if tmux has no record
+var lastTerminalActivity = func() time.Time {
+	var latest time.Time
+	track := func(args ...string) {
+		out, err := exec.Command(tmuxPath, args...).Output()
+		if err != nil {
+			return
+		}
+		for _, line := range strings.Fields(string(out)) {
+			if sec, err := strconv.ParseInt(line, 10, 64); err == nil && sec > 0 {
+				if t := time.Unix(sec, 0); t.After(latest) {
+					latest = t
+				}
+			}
+		}
+	}
+	track("list-clients", "-F", "#{client_activity}")
+	track("list-sessions", "-F", "#{session_last_attached}")
+	return latest
+}
+
+// awayStatus decides whether notifications should reach Telegram and why.
+// Manual mode uses the away flag; automatic mode is away inside the schedule
+// or after away_idle_hours without terminal activity.
+func awayStatus(config *Config, now time.Time) (bool, string) {
+	if !config.AwayAuto {
+		if config.Away {
+			return true, "away mode on"
+		}
+		return false, "away mode off"
+	}
+	if config.AwaySchedule != "" {
+		if s, err := parseAwaySchedule(config.AwaySchedule); err != nil {
+			hookLog("away: invalid schedule %q: %v", config.AwaySchedule, err)
+		} else if s.contains(now) {
+			return true, "inside away schedule " + config.AwaySchedule
+		}
+	}
+	if config.AwayIdleHours > 0 {
+		last := lastTerminalActivity()
+		idle := time.Duration(config.AwayIdleHours) * time.Hour
+		if last.IsZero() || now.Sub(last) >= idle {
+			return true, fmt.Sprintf("no terminal activity for %dh", config.AwayIdleHours)
+		}
+		return false, fmt.Sprintf("terminal active %s ago", formatDuration(now.Sub(last)))
+	}
+	return false, "outside away schedule"
+}
+
+// isAway reports whether you're currently away
+func isAway(config *Config) bool {
+	away, _ := awayStatus(config, time.Now())
+	return away
+}
+
+// handleAwayCommand applies an /away argument to the config and returns the reply:
+//
+//	(empty)           current state
+//	on | off          manual away mode
+//	auto              follow the schedule and idle detection
+//	schedule <spec>   set the schedule (switches to auto)
+//	idle <hours>      set idle detection, 0 disables (switches to auto)
+func handleAwayCommand(config *Config, arg string) (string, error) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		away, reason := awayStatus(config, time.Now())
+		state := "🏠 Present"
+		if away {
+			state = "✈️ Away"
+		}
+		mode := "manual"
+		if config.AwayAuto {
+			mode = "auto"
+		}
+		msg := fmt.Sprintf("%s (%s: %s)", state, mode, reason)
+		if config.AwaySchedule != "" {
+			msg += "\nSchedule: " + config.AwaySchedule
+		}
+		if config.AwayIdleHours > 0 {
+			msg += fmt.Sprintf("\nIdle after: %dh without terminal activity", config.AwayIdleHours)
+		}
+		return msg, nil
+	}
+
+	var reply string
+	switch fields[0] {
+	case "on", "off":
+		config.Away = fields[0] == "on"
+		config.AwayAuto = false
+		reply = "✈️ Away mode on"
+		if !config.Away {
+			reply = "🏠 Away mode off"
+		}
+	case "auto":
+		if config.AwaySchedule == "" && config.AwayIdleHours <= 0 {
+			return "", fmt.Errorf("set a schedule or idle hours first: /away schedule 18:00-09:00 weekends")
+		}
+		config.AwayAuto = true
+		reply = "🕒 Away mode follows the schedule"
+	case "schedule":
+		spec := strings.Join(fields[1:], " ")
+		if _, err := parseAwaySchedule(spec); err != nil {
+			return "", err
+		}
+		config.AwaySchedule = spec
+		config.AwayAuto = true
+		reply = "🕒 Away schedule: " + spec
+	case "idle":
+		if len(fields) < 2 {
+			return "", fmt.Errorf("usage: /away idle <hours>")
+		}
+		hours, err := strconv.Atoi(fields[1])
+		if err != nil || hours < 0 {
+			return "", fmt.Errorf("hours must be a non-negative number")
+		}
+		config.AwayIdleHours = hours
+		config.AwayAuto = config.AwayAuto || hours > 0
+		reply = fmt.Sprintf("🕒 Away after %dh without terminal activity", hours)
+		if hours == 0 {
+			reply = "🕒 Idle detection off"
+		}
+	default:
+		return "", fmt.Errorf("usage: /away [on|off|auto|schedule <spec>|idle <hours>]")
+	}
+	if err := saveConfig(config); err != nil {
+		return "", err
+	}
+	return reply, nil
+}
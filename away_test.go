@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAwaySchedule(t *testing.T) {
+	s, err := parseAwaySchedule("18:00-09:00 weekends")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2026-10-14 is a Wednesday
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		{"2026-10-14 12:00", false},
+		{"2026-10-14 08:59", true},
+		{"2026-10-14 09:00", false},
+		{"2026-10-14 18:00", true},
+		{"2026-10-14 23:30", true},
+		{"2026-10-17 12:00", true}, // Saturday
+		{"2026-10-18 12:00", true}, // Sunday
+	}
+	for _, tt := range tests {
+		at, _ := time.ParseInLocation("2006-01-02 15:04", tt.at, time.Local)
+		if got := s.contains(at); got != tt.want {
+			t.Errorf("contains(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+
+	s, err = parseAwaySchedule("12:00-13:00,fri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at := time.Date(2026, 10, 14, 12, 30, 0, 0, time.Local); !s.contains(at) {
+		t.Error("lunch window should be away")
+	}
+	if at := time.Date(2026, 10, 16, 8, 0, 0, 0, time.Local); !s.contains(at) {
+		t.Error("friday should be away")
+	}
+
+	for _, bad := range []string{"", "25:00-09:00", "someday", "18:00-"} {
+		if _, err := parseAwaySchedule(bad); err == nil {
+			t.Errorf("parseAwaySchedule(%q) should fail", bad)
+		}
+	}
+}
+
+func TestAwayStatus(t *testing.T) {
+	orig := lastTerminalActivity
+	defer func() { lastTerminalActivity = orig }()
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.Local)
+	lastActive := now.Add(-30 * time.Minute)
+	lastTerminalActivity = func() time.Time { return lastActive }
+
+	if away, _ := awayStatus(&Config{Away: true}, now); !away {
+		t.Error("manual away on should be away")
+	}
+	if away, _ := awayStatus(&Config{Away: true, AwayAuto: true, AwaySchedule: "18:00-09:00"}, now); away {
+		t.Error("auto mode outside the schedule should not be away")
+	}
+	if away, _ := awayStatus(&Config{AwayAuto: true, AwaySchedule: "11:00-13:00"}, now); !away {
+		t.Error("auto mode inside the schedule should be away")
+	}
+
+	config := &Config{AwayAuto: true, AwayIdleHours: 2}
+	if away, _ := awayStatus(config, now); away {
+		t.Error("terminal active 30m ago should not be away")
+	}
+	lastActive = now.Add(-3 * time.Hour)
+	if away, reason := awayStatus(config, now); !away || !strings.Contains(reason, "2h") {
+		t.Errorf("terminal idle 3h = %v (%s), want away", away, reason)
+	}
+}
+
+func TestHandleAwayCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	if err := os.WriteFile(filepath.Join(tmpDir, ".ccc.json"), []byte(`{"bot_token": "test", "chat_id": 123}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, _ := loadConfig()
+	if _, err := handleAwayCommand(config, "auto"); err == nil {
+		t.Error("auto without a schedule should fail")
+	}
+	if _, err := handleAwayCommand(config, "schedule 18:00-09:00 weekends"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := handleAwayCommand(config, "on"); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, _ := loadConfig()
+	if !saved.Away || saved.AwayAuto || saved.AwaySchedule != "18:00-09:00 weekends" {
+		t.Errorf("after schedule + on: away=%v auto=%v schedule=%q", saved.Away, saved.AwayAuto, saved.AwaySchedule)
+	}
+	if _, err := handleAwayCommand(saved, "auto"); err != nil || !saved.AwayAuto {
+		t.Errorf("auto with a schedule: err=%v auto=%v", err, saved.AwayAuto)
+	}
+	if _, err := handleAwayCommand(saved, "schedule nonsense"); err == nil {
+		t.Error("invalid schedule should fail")
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// `ccc batch <file.yaml>` runs prompts through sessions one after another:
+//
+//	- session: api
+//	  workdir: ~/code/api
+//	  timeout: 45m            # per prompt (default 30m)
+//	  prompts:
+//	    - run the tests and fix what fails
+//	    - update CHANGELOG.md
+//
+// Each prompt waits for Claude to go idle before the next is sent. Steps are
+// reported to a topic of their own; a step that ends in an error state skips
+// the rest of its session's prompts and makes the batch exit non-zero.
+
+const (
+	defaultBatchStepTimeout = 30 * time.Minute
+	// batchPollInterval and batchIdlePolls match the monitor: a turn is over
+	// after three idle polls three seconds apart
+	batchPollInterval = 3 * time.Second
+	batchIdlePolls    = 3
+)
+
+// batchEntry is one session of a batch file
+type batchEntry struct {
+	Session string   `yaml:"session"`
+	WorkDir string   `yaml:"workdir"`
+	Timeout string   `yaml:"timeout"`
+	Prompts []string `yaml:"prompts"`
+
+	stepTimeout time.Duration
+}
+
+// parseBatch parses and checks a batch file. An entry may leave out the
+// workdir when its session already exists.
+func parseBatch(data []byte, config *Config) ([]batchEntry, error) {
+	var entries []batchEntry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no sessions in batch file")
+	}
+	for i := range entries {
+		e := &entries[i]
+		if e.Session == "" {
+			return nil, fmt.Errorf("entry %d: session is required", i+1)
+		}
+		if e.WorkDir == "" {
+			if info := config.Sessions[e.Session]; info != nil {
+				e.WorkDir = info.Path
+			} else {
+				return nil, fmt.Errorf("%s: workdir is required for a new session", e.Session)
+			}
+		}
+		e.WorkDir = resolveProjectPath(config, e.WorkDir)
+		if len(e.Prompts) == 0 {
+			return nil, fmt.Errorf("%s: no prompts", e.Session)
+		}
+		for _, p := range e.Prompts {
+			if strings.TrimSpace(p) == "" {
+				return nil, fmt.Errorf("%s: empty prompt", e.Session)
+			}
+		}
+		e.stepTimeout = defaultBatchStepTimeout
+		if e.Timeout != "" {
+			d, err := time.ParseDuration(e.Timeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("%s: invalid timeout %q", e.Session, e.Timeout)
+			}
+			e.stepTimeout = d
+		}
+	}
+	return entries, nil
+}
+
+// ensureBatchSession makes sure an entry's session runs in tmux with Claude
+// waiting for input, creating it or restarting it with its conversation
+func ensureBatchSession(config *Config, e *batchEntry) error {
+	info := config.Sessions[e.Session]
+	if isHeadless(info) {
+		return fmt.Errorf("headless sessions can't run in a batch")
+	}
+	tmuxName := sessionName(e.Session)
+	if info == nil {
+		topicID, err := getMessenger(config).CreateTopic(e.Session)
+		if err != nil {
+			return fmt.Errorf("failed to create topic: %w", err)
+		}
+		info = &SessionInfo{TopicID: topicID, Path: e.WorkDir, Machine: sessionMachine(config)}
+		if tmuxSessionExists(tmuxName) {
+			killTmuxSession(tmuxName)
+		}
+		if err := createTmuxSession(tmuxName, e.WorkDir, false); err != nil {
+			return err
+		}
+		config.Sessions[e.Session] = info
+		if err := saveSession(e.Session, info); err != nil {
+			return err
+		}
+	} else if !tmuxSessionExists(tmuxName) {
+		if err := createTmuxSession(tmuxName, info.Path, true); err != nil {
+			return err
+		}
+		if info.Hibernated {
+			info.Hibernated = false
+			saveSession(e.Session, info)
+		}
+	} else {
+		// Let a turn already in progress finish first
+		return waitForTurn(tmuxName, e.stepTimeout)
+	}
+	return waitForSessionStart(config, tmuxName)
+}
+
+// waitForTurn waits for Claude to finish the turn in progress. It fails when
+// the pane shows an error, Claude exits or the turn outlasts timeout.
+func waitForTurn(tmuxName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	idle := 0
+	for time.Now().Before(deadline) {
+		time.Sleep(batchPollInterval)
+		if !tmuxSessionExists(tmuxName) {
+			return fmt.Errorf("the tmux session ended")
+		}
+		if isClaudeExited(tmuxName) {
+			return fmt.Errorf("%s", crashedPaneError.Title)
+		}
+		if out, err := exec.Command(tmuxPath, "capture-pane", "-t", tmuxName, "-p").Output(); err == nil {
+			if perr, line := detectPaneError(string(out)); perr != nil {
+				return fmt.Errorf("%s: %s", perr.Title, truncate(line, 200))
+			}
+		}
+		if isClaudeIdle(tmuxName) {
+			idle++
+			if idle >= batchIdlePolls {
+				return nil
+			}
+		} else {
+			idle = 0
+		}
+	}
+	return fmt.Errorf("still running after %s", formatDuration(timeout))
+}
+
+// runBatch runs a batch file and reports each step to a new topic. It
+// returns an error when any step failed.
+func runBatch(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("not configured. Run: ccc setup <bot_token>")
+	}
+	if !hasSessionChannel(config) {
+		return fmt.Errorf("no group configured. Run: ccc setgroup")
+	}
+	if config.Sessions == nil {
+		config.Sessions = make(map[string]*SessionInfo)
+	}
+	entries, err := parseBatch(data, config)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	total := 0
+	for _, e := range entries {
+		total += len(e.Prompts)
+	}
+	msgr := getMessenger(config)
+	batchName := "batch " + strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	topicID, err := msgr.CreateTopic(batchName)
+	if err != nil {
+		return fmt.Errorf("failed to create topic: %w", err)
+	}
+	report := func(text string) {
+		fmt.Println(text)
+		msgr.Send(config.GroupID, topicID, text)
+	}
+	report(fmt.Sprintf("📋 %s: %d prompts in %d sessions", batchName, total, len(entries)))
+
+	started := time.Now()
+	passed, failed := 0, 0
+	for i := range entries {
+		e := &entries[i]
+		if err := ensureBatchSession(config, e); err != nil {
+			report(fmt.Sprintf("❌ %s: %v — skipping its %d prompts", e.Session, err, len(e.Prompts)))
+			failed += len(e.Prompts)
+			continue
+		}
+		tmuxName := sessionName(e.Session)
+		for j, prompt := range e.Prompts {
+			step := fmt.Sprintf("%s %d/%d", e.Session, j+1, len(e.Prompts))
+			report(fmt.Sprintf("▶️ %s: %s", step, truncate(strings.TrimSpace(prompt), 200)))
+			stepStarted := time.Now()
+			err := typeIntoSession(e.Session, strings.TrimSpace(prompt))
+			if err == nil {
+				err = waitForTurn(tmuxName, e.stepTimeout)
+			}
+			if err != nil {
+				failed += len(e.Prompts) - j
+				msg := fmt.Sprintf("❌ %s: %v", step, err)
+				if rest := len(e.Prompts) - j - 1; rest > 0 {
+					msg += fmt.Sprintf(" — skipping the remaining %d", rest)
+				}
+				report(msg)
+				break
+			}
+			passed++
+			report(fmt.Sprintf("✅ %s done in %s", step, formatDuration(time.Since(stepStarted).Round(time.Second))))
+		}
+	}
+
+	elapsed := formatDuration(time.Since(started).Round(time.Second))
+	if failed > 0 {
+		report(fmt.Sprintf("🏁 %s finished in %s: %d of %d prompts failed or skipped", batchName, elapsed, failed, total))
+		return fmt.Errorf("%d of %d prompts failed or skipped", failed, total)
+	}
+	report(fmt.Sprintf("🏁 %s finished in %s: all %d prompts done", batchName, elapsed, total))
+	return nil
+}
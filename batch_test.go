@@ -0,0 +1,61 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBatch(t *testing.T) {
+	config := &Config{
+		ProjectsDir: "/projects",
+		Sessions:    map[string]*SessionInfo{"web": {Path: "/srv/web"}},
+	}
+	data := `
+- session: api
+  workdir: api
+  timeout: 45m
+  prompts:
+    - run the tests and fix what fails
+    - update CHANGELOG.md
+- session: web
+  prompts: [bump the version]
+`
+	entries, err := parseBatch([]byte(data), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	api, web := entries[0], entries[1]
+	if api.WorkDir != filepath.Join("/projects", "api") || api.stepTimeout != 45*time.Minute || len(api.Prompts) != 2 {
+		t.Errorf("api entry = %+v", api)
+	}
+	if web.WorkDir != "/srv/web" || web.stepTimeout != defaultBatchStepTimeout {
+		t.Errorf("existing session should keep its path and the default timeout: %+v", web)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", "", "no sessions"},
+		{"no session", "- workdir: /x\n  prompts: [a]", "session is required"},
+		{"new without workdir", "- session: new\n  prompts: [a]", "workdir is required"},
+		{"no prompts", "- session: web", "no prompts"},
+		{"blank prompt", "- session: web\n  prompts: ['  ']", "empty prompt"},
+		{"bad timeout", "- session: web\n  timeout: soon\n  prompts: [a]", "invalid timeout"},
+		{"not a list", "session: web", "cannot unmarshal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseBatch([]byte(tt.data), config)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseBatch() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Checkpoints snapshot a session's work tree, untracked files included, as a
+// commit on refs/ccc/checkpoints/<session>/<label> without touching HEAD, the
+// index or the stash. The commit message records the Claude conversation and
+// how long its transcript was, so a rewind restores both.
+
+// checkpointRefPrefix is where a session's checkpoints are kept
+func checkpointRefPrefix(sessName string) string {
+	return "refs/ccc/checkpoints/" + sessName + "/"
+}
+
+// checkpoint is a saved state of a session
+type checkpoint struct {
+	Label           string
+	Commit          string
+	Parent          string // HEAD when it was taken, "" in a repo without commits
+	Created         time.Time
+	ClaudeSessionID string
+	TranscriptLines int
+}
+
+// Trailers in a checkpoint commit message
+const (
+	checkpointSessionTrailer = "Claude-Session: "
+	checkpointLinesTrailer   = "Transcript-Lines: "
+)
+
+// checkpointCommitMessage is the message of a checkpoint commit
+func checkpointCommitMessage(cp checkpoint) string {
+	msg := "ccc checkpoint: " + cp.Label
+	if cp.ClaudeSessionID != "" {
+		msg += fmt.Sprintf("\n\n%s%s\n%s%d", checkpointSessionTrailer, cp.ClaudeSessionID, checkpointLinesTrailer, cp.TranscriptLines)
+	}
+	return msg
+}
+
+// parseCheckpointMessage reads the conversation trailers of a checkpoint
+// commit message into cp
+func parseCheckpointMessage(msg string, cp *checkpoint) {
+	for _, line := range strings.Split(msg, "\n") {
+		switch {
+		case strings.HasPrefix(line, checkpointSessionTrailer):
+			cp.ClaudeSessionID = strings.TrimSpace(strings.TrimPrefix(line, checkpointSessionTrailer))
+		case strings.HasPrefix(line, checkpointLinesTrailer):
+			cp.TranscriptLines, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, checkpointLinesTrailer)))
+		}
+	}
+}
+
+// runGitIndex runs git with a separate index file, so snapshots leave the
+// real index alone
+func runGitIndex(dir, index string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+index)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return strings.TrimSpace(out.String()), err
+}
+
+// snapshotIndex returns a temporary index file holding the whole work tree,
+// seeded from the real index so unchanged files aren't hashed again. The
+// caller removes it.
+func snapshotIndex(dir string) (string, error) {
+	tmp, err := os.CreateTemp("", "ccc-checkpoint-index-")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	index := tmp.Name()
+	// git refuses an empty index file, so without a real one it starts fresh
+	os.Remove(index)
+	if real, err := runGit(dir, "rev-parse", "--git-path", "index"); err == nil {
+		if !filepath.IsAbs(real) {
+			real = filepath.Join(dir, real)
+		}
+		if data, err := os.ReadFile(real); err == nil {
+			os.WriteFile(index, data, 0600)
+		}
+	}
+	if out, err := runGitIndex(dir, index, "add", "-A"); err != nil {
+		os.Remove(index)
+		return "", fmt.Errorf("git add failed: %s", out)
+	}
+	return index, nil
+}
+
+// transcriptFile is the transcript of a Claude conversation in a project
+func transcriptFile(workDir, claudeID string) string {
+	return filepath.Join(claudeProjectDir(workDir), claudeID+".jsonl")
+}
+
+// countFileLines returns the number of lines in a file, 0 if unreadable
+func countFileLines(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	n := 0
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			n++
+		}
+		if err != nil {
+			return n
+		}
+	}
+}
+
+// createCheckpoint snapshots a session's work tree and conversation under a
+// label, the current time if empty
+func createCheckpoint(sessName string, info *SessionInfo, label string, now time.Time) (checkpoint, error) {
+	dir := info.Path
+	if !isGitRepo(dir) {
+		return checkpoint{}, fmt.Errorf("checkpoints need a git repository; %s isn't one", dir)
+	}
+	if label == "" {
+		label = now.Format("20060102-150405")
+	}
+	ref := checkpointRefPrefix(sessName) + label
+	if _, err := runGit(dir, "check-ref-format", ref); err != nil || strings.Contains(label, "/") {
+		return checkpoint{}, fmt.Errorf("invalid checkpoint label %q", label)
+	}
+
+	index, err := snapshotIndex(dir)
+	if err != nil {
+		return checkpoint{}, err
+	}
+	defer os.Remove(index)
+	tree, err := runGitIndex(dir, index, "write-tree")
+	if err != nil {
+		return checkpoint{}, fmt.Errorf("git write-tree failed: %s", tree)
+	}
+
+	cp := checkpoint{Label: label, Created: now, ClaudeSessionID: info.ClaudeSessionID}
+	if cp.ClaudeSessionID != "" {
+		cp.TranscriptLines = countFileLines(transcriptFile(dir, cp.ClaudeSessionID))
+	}
+	args := []string{"-c", "user.name=ccc", "-c", "user.email=ccc@localhost", "commit-tree", tree, "-m", checkpointCommitMessage(cp)}
+	if head, err := runGit(dir, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		cp.Parent = head
+		args = append(args, "-p", head)
+	}
+	commit, err := runGit(dir, args...)
+	if err != nil {
+		return checkpoint{}, fmt.Errorf("git commit-tree failed: %s", commit)
+	}
+	if out, err := runGit(dir, "update-ref", ref, commit); err != nil {
+		return checkpoint{}, fmt.Errorf("git update-ref failed: %s", out)
+	}
+	cp.Commit = commit
+	return cp, nil
+}
+
+// readCheckpoint loads a session's checkpoint by label
+func readCheckpoint(dir, sessName, label string) (checkpoint, error) {
+	out, err := runGit(dir, "log", "-1", "--format=%H%x00%P%x00%ct%x00%B", checkpointRefPrefix(sessName)+label, "--")
+	if err != nil {
+		return checkpoint{}, fmt.Errorf("no checkpoint %q", label)
+	}
+	fields := strings.SplitN(out, "\x00", 4)
+	if len(fields) != 4 {
+		return checkpoint{}, fmt.Errorf("unreadable checkpoint %q", label)
+	}
+	cp := checkpoint{Label: label, Commit: fields[0], Parent: fields[1]}
+	if ts, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+		cp.Created = time.Unix(ts, 0)
+	}
+	parseCheckpointMessage(fields[3], &cp)
+	return cp, nil
+}
+
+// listCheckpoints returns a session's checkpoints, newest first
+func listCheckpoints(dir, sessName string) ([]checkpoint, error) {
+	prefix := checkpointRefPrefix(sessName)
+	out, err := runGit(dir, "for-each-ref", "--sort=-committerdate", "--format=%(refname)", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref failed: %s", out)
+	}
+	var cps []checkpoint
+	for _, ref := range strings.Split(out, "\n") {
+		if ref == "" {
+			continue
+		}
+		if cp, err := readCheckpoint(dir, sessName, strings.TrimPrefix(ref, prefix)); err == nil {
+			cps = append(cps, cp)
+		}
+	}
+	return cps, nil
+}
+
+// restoreCheckpointTree makes the work tree match a checkpoint, removing
+// files created since, and moves HEAD back to where it was. The index is
+// reset to HEAD.
+func restoreCheckpointTree(dir string, cp checkpoint) error {
+	index, err := snapshotIndex(dir)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(index)
+	current, err := runGitIndex(dir, index, "write-tree")
+	if err != nil {
+		return fmt.Errorf("git write-tree failed: %s", current)
+	}
+	// A two-tree read-tree from an index that matches the work tree
+	// updates, adds and deletes exactly what differs
+	if out, err := runGitIndex(dir, index, "read-tree", "-m", "-u", current, cp.Commit+"^{tree}"); err != nil {
+		return fmt.Errorf("restoring files failed: %s", out)
+	}
+	if cp.Parent != "" {
+		if out, err := runGit(dir, "reset", "-q", cp.Parent); err != nil {
+			return fmt.Errorf("git reset failed: %s", out)
+		}
+	}
+	return nil
+}
+
+// newClaudeSessionID returns a random UUID for a forked conversation
+func newClaudeSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// forkTranscript copies the first lines of a conversation's transcript into
+// a new conversation and returns its ID, so resuming it picks up from there
+// while the original stays intact
+func forkTranscript(workDir, claudeID string, lines int) (string, error) {
+	data, err := os.ReadFile(transcriptFile(workDir, claudeID))
+	if err != nil {
+		return "", err
+	}
+	all := strings.SplitAfter(string(data), "\n")
+	if lines > len(all) {
+		lines = len(all)
+	}
+	newID := newClaudeSessionID()
+	kept := strings.Join(all[:lines], "")
+	kept = strings.ReplaceAll(kept, `"sessionId":"`+claudeID+`"`, `"sessionId":"`+newID+`"`)
+	if err := os.WriteFile(transcriptFile(workDir, newID), []byte(kept), 0600); err != nil {
+		return "", err
+	}
+	return newID, nil
+}
+
+// rewindSession restores a session to a checkpoint: the current state is
+// saved as a "before-rewind" checkpoint, Claude is stopped, the work tree
+// restored and Claude resumed from the conversation as it was
+func rewindSession(sessName string, info *SessionInfo, label string) (string, error) {
+	cp, err := readCheckpoint(info.Path, sessName, label)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	backup, err := createCheckpoint(sessName, info, "before-rewind-"+now.Format("20060102-150405"), now)
+	if err != nil {
+		return "", fmt.Errorf("saving the current state first failed: %w", err)
+	}
+
+	tmuxName := sessionName(sessName)
+	running := !isHeadless(info) && tmuxSessionExists(tmuxName)
+	if running {
+		markClaudeRestarting(sessName)
+		if err := stopClaudeInPane(tmuxName); err != nil {
+			return "", err
+		}
+	}
+	if err := restoreCheckpointTree(info.Path, cp); err != nil {
+		return "", err
+	}
+
+	runArgs := "-c"
+	conversation := "the latest conversation"
+	if cp.ClaudeSessionID != "" {
+		if id, err := forkTranscript(info.Path, cp.ClaudeSessionID, cp.TranscriptLines); err == nil {
+			info.ClaudeSessionID = id
+			runArgs = "-r " + id
+			conversation = "the conversation as it was"
+			if err := saveSession(sessName, info); err != nil {
+				hookLog("checkpoint: session=%s saving resumed conversation: %v", sessName, err)
+			}
+		} else {
+			hookLog("checkpoint: session=%s forking transcript: %v", sessName, err)
+		}
+	}
+
+	switch {
+	case isHeadless(info):
+	case running:
+		ResetSessionMonitor(sessName)
+		if err := launchClaudeInPane(tmuxName, runArgs); err != nil {
+			return "", err
+		}
+	default:
+		if err := startTmuxSession(tmuxName, info.Path, runArgs); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("⏪ Rewound '%s' to checkpoint %s (%s) and resumed %s.\nThe state before is saved as %s.",
+		sessName, cp.Label, cp.Created.Format("Jan 2 15:04"), conversation, backup.Label), nil
+}
+
+// formatCheckpoints renders a session's checkpoints for /rewind
+func formatCheckpoints(cps []checkpoint, now time.Time) string {
+	if len(cps) == 0 {
+		return "No checkpoints yet. /checkpoint [label] saves one."
+	}
+	var sb strings.Builder
+	sb.WriteString("Checkpoints (/rewind <label>):\n")
+	for _, cp := range cps {
+		fmt.Fprintf(&sb, "\n• %s — %s", cp.Label, formatLastActivity(cp.Created, now))
+	}
+	return sb.String()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckpointRewind(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "ccc test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "ccc@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "ccc test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "ccc@example.com")
+
+	dir := t.TempDir()
+	runGit(dir, "init", "-q")
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("v1\n"), 0644)
+	runGit(dir, "add", "-A")
+	runGit(dir, "commit", "-q", "-m", "initial")
+	head, _ := runGit(dir, "rev-parse", "HEAD")
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("untracked\n"), 0644)
+
+	info := &SessionInfo{Path: dir, ClaudeSessionID: "conv-1"}
+	os.MkdirAll(claudeProjectDir(dir), 0755)
+	os.WriteFile(transcriptFile(dir, "conv-1"), []byte(`{"sessionId":"conv-1","n":1}`+"\n"+`{"sessionId":"conv-1","n":2}`+"\n"), 0600)
+
+	cp, err := createCheckpoint("api", info, "before-refactor", time.Now())
+	if err != nil {
+		t.Fatalf("createCheckpoint: %v", err)
+	}
+	if status, _ := runGit(dir, "status", "--porcelain"); status != "?? notes.txt" {
+		t.Errorf("checkpoint changed the index or tree: %q", status)
+	}
+	if _, err := createCheckpoint("api", info, "bad..label", time.Now()); err == nil {
+		t.Error("an invalid label should be refused")
+	}
+
+	// Claude refactors: edits, commits, deletes and adds files
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("v2\n"), 0644)
+	os.Remove(filepath.Join(dir, "notes.txt"))
+	runGit(dir, "add", "-A")
+	runGit(dir, "commit", "-q", "-m", "refactor")
+	os.WriteFile(filepath.Join(dir, "new.go"), []byte("new\n"), 0644)
+	f, _ := os.OpenFile(transcriptFile(dir, "conv-1"), os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"sessionId":"conv-1","n":3}` + "\n")
+	f.Close()
+
+	got, err := readCheckpoint(dir, "api", "before-refactor")
+	if err != nil || got.Commit != cp.Commit || got.Parent != head || got.ClaudeSessionID != "conv-1" || got.TranscriptLines != 2 {
+		t.Fatalf("readCheckpoint = %+v, %v", got, err)
+	}
+	if err := restoreCheckpointTree(dir, got); err != nil {
+		t.Fatalf("restoreCheckpointTree: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.go")); string(data) != "v1\n" {
+		t.Errorf("main.go = %q, want v1", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Error("the untracked file was not restored")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.go")); err == nil {
+		t.Error("a file created after the checkpoint was left behind")
+	}
+	if now, _ := runGit(dir, "rev-parse", "HEAD"); now != head {
+		t.Errorf("HEAD = %s, want %s", now, head)
+	}
+
+	id, err := forkTranscript(dir, "conv-1", got.TranscriptLines)
+	if err != nil {
+		t.Fatalf("forkTranscript: %v", err)
+	}
+	data, _ := os.ReadFile(transcriptFile(dir, id))
+	if strings.Count(string(data), "\n") != 2 || strings.Contains(string(data), "conv-1") {
+		t.Errorf("forked transcript = %q", data)
+	}
+
+	cps, err := listCheckpoints(dir, "api")
+	if err != nil || len(cps) != 1 || cps[0].Label != "before-refactor" {
+		t.Errorf("listCheckpoints = %+v, %v", cps, err)
+	}
+}
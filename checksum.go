@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Header the relay sets on downloads once it knows the file's SHA-256
+const relayChecksumHeader = "X-Checksum-Sha256"
+
+// checksumReader hashes what is read through it and notes when it reached
+// the end, so the sum is only trusted for a complete read
+type checksumReader struct {
+	r   io.Reader
+	h   hash.Hash
+	eof bool
+}
+
+func newChecksumReader(r io.Reader) *checksumReader {
+	return &checksumReader{r: r, h: sha256.New()}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.h.Write(p[:n])
+	if err == io.EOF {
+		c.eof = true
+	}
+	return n, err
+}
+
+// sum returns the hex SHA-256, or "" if the reader wasn't read to the end
+func (c *checksumReader) sum() string {
+	if !c.eof {
+		return ""
+	}
+	return hex.EncodeToString(c.h.Sum(nil))
+}
+
+// parseChecksums finds name's checksum in sha256sum output ("<hex>  <name>",
+// or "<hex> *<name>" for binary mode)
+func parseChecksums(text, name string) (string, bool) {
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum := strings.ToLower(fields[0])
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != sha256.Size*2 {
+			return "", false
+		}
+		return sum, true
+	}
+	return "", false
+}
+
+// fetchPublishedChecksum downloads a checksums file and returns name's entry.
+// Releases publish checksums.txt, written by `make checksums` in the release
+// workflow. It comes from the same place as the binary, so it catches a
+// corrupt or cut off download, not a tampered release.
+func fetchPublishedChecksum(url, name string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("checksums download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("no checksums published (HTTP %d)", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", err
+	}
+	sum, ok := parseChecksums(string(body), name)
+	if !ok {
+		return "", fmt.Errorf("no checksum published for %s", name)
+	}
+	return sum, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChecksumReader(t *testing.T) {
+	r := newChecksumReader(strings.NewReader("hello"))
+	buf := make([]byte, 3)
+	r.Read(buf)
+	if r.sum() != "" {
+		t.Error("sum of a partial read should be empty")
+	}
+	io.Copy(io.Discard, r)
+	// sha256("hello")
+	if got, want := r.sum(), "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; got != want {
+		t.Errorf("sum = %s, want %s", got, want)
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	text := "0000  ccc-linux-amd64\n" + sum + "  ccc-linux-arm64\n" + strings.ToUpper(sum) + " *ccc-darwin-arm64\n"
+	if got, ok := parseChecksums(text, "ccc-linux-arm64"); !ok || got != sum {
+		t.Errorf("linux-arm64 = %q, %v", got, ok)
+	}
+	if got, ok := parseChecksums(text, "ccc-darwin-arm64"); !ok || got != sum {
+		t.Errorf("binary-mode entry = %q, %v", got, ok)
+	}
+	if _, ok := parseChecksums(text, "ccc-linux-amd64"); ok {
+		t.Error("a malformed checksum should not be accepted")
+	}
+	if _, ok := parseChecksums(text, "ccc-windows-amd64"); ok {
+		t.Error("a missing entry should not be found")
+	}
+}
+
+func TestFetchPublishedChecksum(t *testing.T) {
+	sum := strings.Repeat("0f", 32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/checksums.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, "%s  ccc-linux-amd64\n", sum)
+	}))
+	defer srv.Close()
+
+	if got, err := fetchPublishedChecksum(srv.URL+"/checksums.txt", "ccc-linux-amd64"); err != nil || got != sum {
+		t.Errorf("fetchPublishedChecksum = %q, %v", got, err)
+	}
+	if _, err := fetchPublishedChecksum(srv.URL+"/checksums.txt", "ccc-linux-arm64"); err == nil {
+		t.Error("expected an error for a binary without a checksum")
+	}
+	if _, err := fetchPublishedChecksum(srv.URL+"/missing.txt", "ccc-linux-amd64"); err == nil {
+		t.Error("expected an error when no checksums are published")
+	}
+}
+
+func TestRelayChecksumHeader(t *testing.T) {
+	server := httptest.NewServer(newRelayMux(relayOptions{}))
+	defer server.Close()
+
+	content := strings.Repeat("0123456789", 10)
+	token := "csum0123456789abcdef0123456789"
+	resp, err := http.Post(server.URL+"/register", "application/json",
+		strings.NewReader(`{"token":"`+token+`","filename":"a.bin","size":100}`))
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	resp.Body.Close()
+	defer cancelRelayTransfer(server.URL, "", token)
+
+	download := func() string {
+		done := make(chan string)
+		go func() {
+			resp, err := http.Get(server.URL + "/d/" + token + "/a.bin")
+			if err != nil {
+				done <- ""
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			done <- resp.Header.Get(relayChecksumHeader)
+		}()
+		for i := 0; i < 100; i++ {
+			resp, _ := http.Get(server.URL + "/status/" + token)
+			status, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(status) == "ready" {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		streamResp, err := http.Post(server.URL+"/stream/"+token, "application/octet-stream", strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("stream failed: %v", err)
+		}
+		streamResp.Body.Close()
+		return <-done
+	}
+
+	if got := download(); got != "" {
+		t.Errorf("first download has checksum %q before the relay has seen the file", got)
+	}
+	sum := sha256.Sum256([]byte(content))
+	if got, want := download(), hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("second download checksum = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// managedClaudeFlags are set by ccc itself and can't be given as session flags
+var managedClaudeFlags = map[string]bool{
+	"-p": true, "--print": true,
+	"-c": true, "--continue": true,
+	"-r": true, "--resume": true,
+	"--output-format": true, "--dangerously-skip-permissions": true,
+}
+
+// splitClaudeFlags splits a flag string like a shell would, honoring single
+// and double quotes
+func splitClaudeFlags(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// parseClaudeFlags parses the flags given to /flags, refusing the ones ccc
+// manages
+func parseClaudeFlags(s string) ([]string, error) {
+	flags, err := splitClaudeFlags(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range flags {
+		name := strings.SplitN(f, "=", 2)[0]
+		if managedClaudeFlags[name] {
+			return nil, fmt.Errorf("%s is set by ccc", name)
+		}
+	}
+	return flags, nil
+}
+
+// formatClaudeFlags renders flags for display, quoting the ones with spaces
+func formatClaudeFlags(flags []string) string {
+	quoted := make([]string, len(flags))
+	for i, f := range flags {
+		if f == "" || strings.ContainsAny(f, " \t'\"") {
+			f = "'" + strings.ReplaceAll(f, "'", `'"'"'`) + "'"
+		}
+		quoted[i] = f
+	}
+	return strings.Join(quoted, " ")
+}
+
+// claudeFlagValue returns the value of a flag given as "--name value" or
+// "--name=value", "" if it isn't set
+func claudeFlagValue(flags []string, name string) string {
+	for i, f := range flags {
+		if f == name && i+1 < len(flags) {
+			return flags[i+1]
+		}
+		if strings.HasPrefix(f, name+"=") {
+			return strings.TrimPrefix(f, name+"=")
+		}
+	}
+	return ""
+}
+
+// withClaudeFlag returns flags with a valued flag replaced; an empty value
+// drops it
+func withClaudeFlag(flags []string, name, value string) []string {
+	var out []string
+	for i := 0; i < len(flags); i++ {
+		switch {
+		case flags[i] == name:
+			i++
+		case strings.HasPrefix(flags[i], name+"="):
+		default:
+			out = append(out, flags[i])
+		}
+	}
+	if value != "" {
+		out = append(out, name, value)
+	}
+	return out
+}
+
+// currentTmuxSession returns the name of the tmux session this process runs
+// in, "" outside tmux
+func currentTmuxSession() string {
+	if os.Getenv("TMUX") == "" {
+		return ""
+	}
+	out, err := exec.Command(tmuxPath, "display-message", "-p", "#S").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// sessionClaudeFlags returns the extra claude flags of the session running
+// in a tmux session
+func sessionClaudeFlags(config *Config, tmuxName string) []string {
+	if tmuxName == "" {
+		return nil
+	}
+	for name, info := range config.Sessions {
+		if info != nil && sessionName(name) == tmuxName {
+			return info.ClaudeFlags
+		}
+	}
+	return nil
+}
+
+// flagsApplyNote tells when changed flags take effect
+func flagsApplyNote(info *SessionInfo) string {
+	if isHeadless(info) {
+		return "Takes effect from the next message."
+	}
+	return "Takes effect when Claude next starts: /continue restarts it keeping the conversation."
+}
+
+// handleFlagsCommand shows or changes a session's extra claude flags:
+// "/flags", "/flags off" or "/flags <flags>"
+func handleFlagsCommand(sessName string, info *SessionInfo, arg string) string {
+	switch arg {
+	case "":
+		if len(info.ClaudeFlags) == 0 {
+			return "No extra claude flags. Set them with /flags <flags>, e.g. /flags --permission-mode plan"
+		}
+		return "⚙️ claude flags: " + formatClaudeFlags(info.ClaudeFlags)
+	case "off":
+		info.ClaudeFlags = nil
+		if err := saveSession(sessName, info); err != nil {
+			return "❌ " + err.Error()
+		}
+		return "⚙️ Extra claude flags cleared. " + flagsApplyNote(info)
+	}
+	flags, err := parseClaudeFlags(arg)
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+	info.ClaudeFlags = flags
+	if err := saveSession(sessName, info); err != nil {
+		return "❌ " + err.Error()
+	}
+	return "⚙️ claude flags: " + formatClaudeFlags(flags) + "\n" + flagsApplyNote(info)
+}
+
+// handleModelCommand shows or changes the model a session's Claude runs:
+// "/model", "/model default" or "/model <name>"
+func handleModelCommand(sessName string, info *SessionInfo, arg string) string {
+	if arg == "" {
+		if model := claudeFlagValue(info.ClaudeFlags, "--model"); model != "" {
+			return "🧠 Model: " + model
+		}
+		return "🧠 Model: Claude's default. Change it with /model <name>, e.g. /model opus"
+	}
+	if strings.ContainsAny(arg, " \t") {
+		return "Usage: /model [name|default]"
+	}
+	model := arg
+	if model == "default" {
+		model = ""
+	}
+	info.ClaudeFlags = withClaudeFlag(info.ClaudeFlags, "--model", model)
+	if err := saveSession(sessName, info); err != nil {
+		return "❌ " + err.Error()
+	}
+	if model == "" {
+		return "🧠 Model reset to Claude's default. " + flagsApplyNote(info)
+	}
+	return "🧠 Model set to " + model + ". " + flagsApplyNote(info)
+}
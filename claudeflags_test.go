@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseClaudeFlags(t *testing.T) {
+	got, err := parseClaudeFlags(`--permission-mode plan --allowedTools "Bash(git *)" Edit --mcp-config '/srv/my mcp.json'`)
+	want := []string{"--permission-mode", "plan", "--allowedTools", "Bash(git *)", "Edit", "--mcp-config", "/srv/my mcp.json"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseClaudeFlags = %q, %v, want %q", got, err, want)
+	}
+	if s := formatClaudeFlags(got); s != `--permission-mode plan --allowedTools 'Bash(git *)' Edit --mcp-config '/srv/my mcp.json'` {
+		t.Errorf("formatClaudeFlags = %s", s)
+	}
+	for _, bad := range []string{`--model "opus`, "--resume abc", "--output-format=json", "-p hi"} {
+		if _, err := parseClaudeFlags(bad); err == nil {
+			t.Errorf("parseClaudeFlags(%q) should fail", bad)
+		}
+	}
+}
+
+func TestWithClaudeFlag(t *testing.T) {
+	flags := []string{"--model", "sonnet", "--permission-mode", "plan", "--model=haiku"}
+	got := withClaudeFlag(flags, "--model", "opus")
+	if want := []string{"--permission-mode", "plan", "--model", "opus"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("withClaudeFlag = %q, want %q", got, want)
+	}
+	if m := claudeFlagValue(got, "--model"); m != "opus" {
+		t.Errorf("claudeFlagValue = %q, want opus", m)
+	}
+	if m := claudeFlagValue([]string{"--permission-mode=plan"}, "--permission-mode"); m != "plan" {
+		t.Errorf("claudeFlagValue with = %q, want plan", m)
+	}
+	if got := withClaudeFlag(got, "--model", ""); !reflect.DeepEqual(got, []string{"--permission-mode", "plan"}) {
+		t.Errorf("default model = %q, want --model dropped", got)
+	}
+}
+
+func TestSessionClaudeFlags(t *testing.T) {
+	config := &Config{Sessions: map[string]*SessionInfo{
+		"api": {ClaudeFlags: []string{"--model", "opus"}},
+		"web": {},
+	}}
+	if got := sessionClaudeFlags(config, sessionName("api")); !reflect.DeepEqual(got, []string{"--model", "opus"}) {
+		t.Errorf("sessionClaudeFlags(api) = %q", got)
+	}
+	if got := sessionClaudeFlags(config, ""); got != nil {
+		t.Errorf("outside tmux = %q, want none", got)
+	}
+
+	info := &SessionInfo{Path: t.TempDir(), ClaudeFlags: []string{"--model", "opus"}}
+	t.Setenv("HOME", t.TempDir())
+	args := headlessArgs(info, "hi")
+	if !reflect.DeepEqual(args[:4], []string{"--dangerously-skip-permissions", "--model", "opus", "-p"}) {
+		t.Errorf("headlessArgs = %q, want the flags before -p", args)
+	}
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -16,10 +18,106 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	"unicode/utf8"
 )
 
-var authInProgress sync.Mutex
-var authWaitingCode bool
+// Auth flow state. authGen identifies the current flow so goroutines from a
+// cancelled or timed-out flow can tell they are stale.
+var (
+	authMu          sync.Mutex
+	authInProgress  bool
+	authWaitingCode bool
+	authGen         int
+	authChatID      int64 // chat/thread where /auth was issued; only messages there are taken as the code
+	authThreadID    int64
+	authTimer       *time.Timer
+)
+
+const authCodeTimeout = 5 * time.Minute
+
+// beginAuth marks an auth flow as started. Returns false if one is already running.
+func beginAuth(chatID, threadID int64) (int, bool) {
+	authMu.Lock()
+	defer authMu.Unlock()
+	if authInProgress {
+		return 0, false
+	}
+	authGen++
+	authInProgress = true
+	authWaitingCode = false
+	authChatID = chatID
+	authThreadID = threadID
+	return authGen, true
+}
+
+// endAuth resets the auth flow if gen is still the current flow.
+// Returns false if the flow was already ended (cancelled or timed out).
+func endAuth(gen int) bool {
+	authMu.Lock()
+	defer authMu.Unlock()
+	if !authInProgress || gen != authGen {
+		return false
+	}
+	authInProgress = false
+	authWaitingCode = false
+	if authTimer != nil {
+		authTimer.Stop()
+		authTimer = nil
+	}
+	return true
+}
+
+// authActive reports whether gen is still the running auth flow
+func authActive(gen int) bool {
+	authMu.Lock()
+	defer authMu.Unlock()
+	return authInProgress && gen == authGen
+}
+
+// waitForAuthCode arms the code prompt and a timeout that abandons the flow
+// if no code arrives within authCodeTimeout.
+func waitForAuthCode(config *Config, gen int) {
+	authMu.Lock()
+	defer authMu.Unlock()
+	if !authInProgress || gen != authGen {
+		return
+	}
+	authWaitingCode = true
+	chatID, threadID := authChatID, authThreadID
+	authTimer = time.AfterFunc(authCodeTimeout, func() {
+		if endAuth(gen) {
+			killTmuxSession(authTmuxSession)
+			sendMessage(config, chatID, threadID, "⏱️ Auth timed out waiting for the code. Send /auth to try again.")
+		}
+	})
+}
+
+// isAuthCodeMessage reports whether a message should be taken as the OAuth code:
+// a code must be pending and the message must come from the chat/thread where /auth was issued.
+func isAuthCodeMessage(chatID, threadID int64, text string) (int, bool) {
+	authMu.Lock()
+	defer authMu.Unlock()
+	if !authWaitingCode || strings.HasPrefix(text, "/") {
+		return 0, false
+	}
+	if chatID != authChatID || threadID != authThreadID {
+		return 0, false
+	}
+	authWaitingCode = false
+	return authGen, true
+}
+
+// cancelAuth aborts any in-progress auth flow. Returns false if none was running.
+func cancelAuth() bool {
+	authMu.Lock()
+	gen := authGen
+	authMu.Unlock()
+	if !endAuth(gen) {
+		return false
+	}
+	killTmuxSession(authTmuxSession)
+	return true
+}
 
 // getSystemStats returns machine stats (works on Linux and macOS)
 func getSystemStats() string {
@@ -107,15 +205,17 @@ func getSystemStats() string {
 
 // Execute shell command
 func executeCommand(cmdStr string) (string, error) {
+	home, _ := os.UserHomeDir()
+	return executeCommandIn(cmdStr, home)
+}
+
+// executeCommandIn runs a shell command with the given working directory
+func executeCommandIn(cmdStr string, dir string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	shell := "bash"
-	if _, err := exec.LookPath("zsh"); err == nil {
-		shell = "zsh"
-	}
-	cmd := exec.CommandContext(ctx, shell, "-l", "-c", cmdStr)
-	cmd.Dir, _ = os.UserHomeDir()
+	cmd := exec.CommandContext(ctx, commandShell(), "-l", "-c", cmdStr)
+	cmd.Dir = dir
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -142,6 +242,244 @@ func executeCommand(cmdStr string) (string, error) {
 	return strings.TrimSpace(output), err
 }
 
+// commandShell returns the shell used for /c commands (zsh if available, else bash)
+func commandShell() string {
+	if _, err := exec.LookPath("zsh"); err == nil {
+		return "zsh"
+	}
+	return "bash"
+}
+
+const (
+	streamTimeout       = 10 * time.Minute
+	streamFlushInterval = 3 * time.Second // keeps message edits well under Telegram's rate limit
+	streamTailLen       = 3500
+	streamMaxOutput     = 64 * 1024 // output kept for the final message; older output is dropped
+)
+
+// Running streamed commands, keyed by chat/thread, so /stop can cancel them
+var (
+	streamsMu      sync.Mutex
+	runningStreams = make(map[string]context.CancelFunc)
+)
+
+func streamKey(chatID, threadID int64) string {
+	return fmt.Sprintf("%d:%d", chatID, threadID)
+}
+
+// stopStreamCommand cancels the streamed command running in a chat/thread.
+// Returns false if none is running.
+func stopStreamCommand(chatID, threadID int64) bool {
+	streamsMu.Lock()
+	defer streamsMu.Unlock()
+	cancel, exists := runningStreams[streamKey(chatID, threadID)]
+	if exists {
+		cancel()
+	}
+	return exists
+}
+
+// streamCommand runs a shell command and shows its output live by periodically
+// editing a Telegram message. Commands that finish before the first flush are
+// answered with a single message, like a plain /c.
+func streamCommand(config *Config, chatID, threadID int64, cmdStr, dir string) {
+	key := streamKey(chatID, threadID)
+	ctx, cancel := context.WithTimeout(context.Background(), streamTimeout)
+	defer cancel()
+
+	streamsMu.Lock()
+	if _, busy := runningStreams[key]; busy {
+		streamsMu.Unlock()
+		sendMessage(config, chatID, threadID, "⚠️ A command is already running here. Send /stop to cancel it.")
+		return
+	}
+	runningStreams[key] = cancel
+	streamsMu.Unlock()
+	defer func() {
+		streamsMu.Lock()
+		delete(runningStreams, key)
+		streamsMu.Unlock()
+	}()
+
+	cmd := exec.Command(commandShell(), "-l", "-c", cmdStr)
+	cmd.Dir = dir
+	// Own process group so /stop and the timeout also kill child processes (e.g. tail -f)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+
+	var outMu sync.Mutex
+	var output string
+	truncated := false
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		reader := bufio.NewReader(pr)
+		for {
+			line, err := reader.ReadString('\n')
+			outMu.Lock()
+			output += line
+			if len(output) > streamMaxOutput {
+				cut := len(output) - streamMaxOutput
+				for cut < len(output) && !utf8.RuneStart(output[cut]) {
+					cut++
+				}
+				output = output[cut:]
+				truncated = true
+			}
+			outMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := cmd.Start(); err != nil {
+		pw.Close()
+		sendMessage(config, chatID, threadID, fmt.Sprintf("⚠️ Failed to start: %v", err))
+		return
+	}
+
+	waitErr := make(chan error, 1)
+	exited := make(chan struct{})
+	go func() {
+		err := cmd.Wait()
+		close(exited)
+		pw.Close()
+		waitErr <- err
+	}()
+	// Kill on timeout or /stop, but never once the command has been reaped:
+	// its PID and process group may belong to something else by then
+	go func() {
+		select {
+		case <-exited:
+		case <-ctx.Done():
+			select {
+			case <-exited:
+			default:
+				syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamFlushInterval)
+	defer ticker.Stop()
+
+	var msgID int64
+	var lastShown string
+	for {
+		select {
+		case err := <-waitErr:
+			<-readDone
+			final := strings.TrimSpace(output)
+			if truncated {
+				final = "…" + final
+			}
+			if final == "" {
+				if err != nil {
+					final = fmt.Sprintf("Error: %v", err)
+				} else {
+					final = "(no output)"
+				}
+			}
+			outcome := "exit 0"
+			switch {
+			case ctx.Err() == context.DeadlineExceeded:
+				final = fmt.Sprintf("⏱️ Timeout (%v)\n\n%s", streamTimeout, final)
+				outcome = "timeout"
+			case ctx.Err() == context.Canceled:
+				final = fmt.Sprintf("🛑 Stopped\n\n%s", final)
+				outcome = "stopped"
+			case err != nil:
+				final = fmt.Sprintf("⚠️ %s\n\nExit: %v", final, err)
+				outcome = err.Error()
+			}
+			auditCommand(chatID, threadID, dir, cmdStr, outcome)
+			if msgID == 0 {
+				sendMessage(config, chatID, threadID, final)
+			} else {
+				editMessage(config, chatID, msgID, threadID, final)
+			}
+			return
+		case <-ticker.C:
+			outMu.Lock()
+			current := output
+			outMu.Unlock()
+			if len(current) > streamTailLen {
+				cut := len(current) - streamTailLen
+				for cut < len(current) && !utf8.RuneStart(current[cut]) {
+					cut++
+				}
+				current = "…" + current[cut:]
+			}
+			text := fmt.Sprintf("⏳ $ %s\n\n%s", truncate(cmdStr, 100), strings.TrimSpace(current))
+			if text == lastShown {
+				continue
+			}
+			lastShown = text
+			if msgID == 0 {
+				msgID, _ = sendMessageGetID(config, chatID, threadID, text)
+			} else {
+				editMessage(config, chatID, msgID, threadID, text)
+			}
+		}
+	}
+}
+
+// jailCommand returns the working directory a shell command should run in.
+// When CommandJailDir is set, dir must be inside the jail (it falls back to the
+// jail root otherwise) and commands referencing absolute or parent paths outside
+// the jail are rejected. This is a best-effort string check to catch mistakes,
+// NOT a security boundary - a determined command can still escape.
+func jailCommand(config *Config, cmdStr string, dir string) (string, error) {
+	if config.CommandJailDir == "" {
+		return dir, nil
+	}
+	jail := filepath.Clean(expandPath(config.CommandJailDir))
+	if dir == "" || !isInsideDir(jail, dir) {
+		dir = jail
+	}
+	if path := findPathOutsideJail(jail, dir, cmdStr); path != "" {
+		return "", fmt.Errorf("path %s is outside the command jail (%s)", path, jail)
+	}
+	return dir, nil
+}
+
+// findPathOutsideJail scans the words of a shell command for paths that
+// resolve outside jail. Relative paths are resolved against dir.
+// Returns the first offending path, or "" if none.
+func findPathOutsideJail(jail, dir, cmdStr string) string {
+	home, _ := os.UserHomeDir()
+	words := strings.FieldsFunc(cmdStr, func(r rune) bool {
+		return strings.ContainsRune(" \t\n;|&<>()'\"`=", r)
+	})
+	for _, w := range words {
+		var resolved string
+		switch {
+		case w == "~" || strings.HasPrefix(w, "~/"):
+			resolved = filepath.Join(home, strings.TrimPrefix(w, "~"))
+		case strings.HasPrefix(w, "/"):
+			resolved = w
+		case w == ".." || strings.HasPrefix(w, "../") || strings.Contains(w, "/../") || strings.HasSuffix(w, "/.."):
+			resolved = filepath.Join(dir, w)
+		default:
+			continue
+		}
+		if !isInsideDir(jail, resolved) {
+			return w
+		}
+	}
+	return ""
+}
+
+// isInsideDir reports whether path is dir itself or somewhere beneath it
+func isInsideDir(dir, path string) bool {
+	dir = filepath.Clean(dir)
+	path = filepath.Clean(path)
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
 // One-shot Claude run (for private chat)
 func runClaude(prompt string) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
@@ -323,7 +661,9 @@ step3:
 	return nil
 }
 
-func setGroup(config *Config) error {
+// setGroup waits for a message in a group and makes it the main group, or
+// registers it under a name so sessions can be created there too
+func setGroup(config *Config, name string) error {
 	fmt.Println("Send a message in the group where you want to use topics...")
 	fmt.Println("(Make sure Topics are enabled in group settings)")
 
@@ -349,11 +689,14 @@ func setGroup(config *Config) error {
 			offset = update.UpdateID + 1
 			chat := update.Message.Chat
 			if chat.Type == "supergroup" && update.Message.From.ID == config.ChatID {
-				config.GroupID = chat.ID
-				if err := saveConfig(config); err != nil {
+				if err := registerGroup(config, name, chat.ID); err != nil {
 					return err
 				}
-				fmt.Printf("Group set: %d\n", chat.ID)
+				if name != "" {
+					fmt.Printf("Group %s registered: %d\n", name, chat.ID)
+				} else {
+					fmt.Printf("Group set: %d\n", chat.ID)
+				}
 				fmt.Println("You can now create sessions with: /new <name>")
 				return nil
 			}
@@ -413,13 +756,26 @@ func doctor() {
 	// Check config
 	fmt.Print("config............ ")
 	config, err := loadConfig()
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("❌ %v\n", err)
+		allGood = false
+	} else if err != nil {
 		fmt.Println("❌ not found")
 		fmt.Println("   Run: ccc setup <bot_token>")
 		allGood = false
 	} else {
 		fmt.Printf("✅ %s\n", getConfigPath())
 
+		fmt.Print("  secrets......... ")
+		switch secretsBackend(config) {
+		case secretsKeychain:
+			fmt.Printf("✅ OS keychain (%s)\n", keychainTool())
+		case secretsEncrypted:
+			fmt.Printf("✅ encrypted (unlocked with $%s)\n", secretsKeyEnv)
+		default:
+			fmt.Println("⚠️  plaintext in config (optional: ccc config secrets keychain)")
+		}
+
 		// Check bot token
 		fmt.Print("  bot_token....... ")
 		if config.BotToken != "" {
@@ -445,6 +801,9 @@ func doctor() {
 		} else {
 			fmt.Println("⚠️  not set (optional, run: ccc setgroup)")
 		}
+		if len(config.Groups) > 0 {
+			fmt.Printf("  groups.......... ✅ %s\n", formatGroups(config))
+		}
 	}
 
 	// Check Claude hook (only AskUserQuestion hook is needed now, polling handles the rest)
@@ -473,15 +832,15 @@ func doctor() {
 	fmt.Print("service........... ")
 	if _, err := os.Stat("/Library"); err == nil {
 		// macOS - check launchd
-		plistPath := filepath.Join(home, "Library", "LaunchAgents", "com.ccc.plist")
+		plistPath := filepath.Join(home, "Library", "LaunchAgents", "com."+serviceName()+".plist")
 		if _, err := os.Stat(plistPath); err == nil {
 			// Check if loaded
-			cmd := exec.Command("launchctl", "list", "com.ccc")
+			cmd := exec.Command("launchctl", "list", "com."+serviceName())
 			if cmd.Run() == nil {
 				fmt.Println("✅ running (launchd)")
 			} else {
 				fmt.Println("⚠️  installed but not running")
-				fmt.Println("   Run: launchctl load ~/Library/LaunchAgents/com.ccc.plist")
+				fmt.Printf("   Run: launchctl load ~/Library/LaunchAgents/com.%s.plist\n", serviceName())
 			}
 		} else {
 			fmt.Println("❌ not installed")
@@ -490,14 +849,14 @@ func doctor() {
 		}
 	} else {
 		// Linux - check systemd
-		cmd := exec.Command("systemctl", "--user", "is-active", "ccc")
+		cmd := exec.Command("systemctl", "--user", "is-active", serviceName())
 		if output, err := cmd.Output(); err == nil && strings.TrimSpace(string(output)) == "active" {
 			fmt.Println("✅ running (systemd)")
 		} else {
 			servicePath := filepath.Join(home, ".config", "systemd", "user", "ccc.service")
 			if _, err := os.Stat(servicePath); err == nil {
 				fmt.Println("⚠️  installed but not running")
-				fmt.Println("   Run: systemctl --user start ccc")
+				fmt.Printf("   Run: systemctl --user start %s\n", serviceName())
 			} else {
 				fmt.Println("❌ not installed")
 				fmt.Println("   Run: ccc setup <token> (or manually create service)")
@@ -520,6 +879,8 @@ func doctor() {
 	fmt.Print("openrouter key.... ")
 	if config != nil && config.OpenRouterKey != "" {
 		fmt.Println("✅ configured (LLM routing enabled)")
+	} else if config != nil && config.RouterEndpoint != "" {
+		fmt.Printf("✅ not needed (routing via %s, model %s)\n", config.RouterEndpoint, routerModel(config))
 	} else {
 		fmt.Println("⚠️  not set (natural language routing disabled)")
 		fmt.Println("   Set with: ccc config openrouter-key <key>")
@@ -540,8 +901,8 @@ func send(message string) error {
 		return fmt.Errorf("not configured. Run: ccc setup <bot_token>")
 	}
 
-	if !config.Away {
-		fmt.Println("Away mode off, skipping notification.")
+	if away, reason := awayStatus(config, time.Now()); !away {
+		fmt.Printf("Not away (%s), skipping notification.\n", reason)
 		return nil
 	}
 
@@ -554,7 +915,7 @@ func send(message string) error {
 			}
 			// Match against saved path, subdirectories of saved path, or suffix
 			if cwd == info.Path || strings.HasPrefix(cwd, info.Path+"/") || strings.HasSuffix(cwd, "/"+name) {
-				return sendMessage(config, config.GroupID, info.TopicID, message)
+				return sendMessage(config, sessionGroup(config, info), info.TopicID, message)
 			}
 		}
 	}
@@ -568,9 +929,8 @@ func listen() error {
 	// Small random delay to avoid race conditions when multiple instances start
 	time.Sleep(time.Duration(os.Getpid()%500) * time.Millisecond)
 
-	// Use a lock file to ensure only one instance runs
-	home, _ := os.UserHomeDir()
-	lockPath := filepath.Join(home, ".ccc.lock")
+	// Use a lock file to ensure only one instance runs (per profile)
+	lockPath := stateFile(".lock")
 	lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
 	if err != nil {
 		return fmt.Errorf("failed to open lock file: %w", err)
@@ -594,14 +954,52 @@ func listen() error {
 		return fmt.Errorf("not configured. Run: ccc setup <bot_token>")
 	}
 
+	switch config.Messenger {
+	case messengerDiscord:
+		return listenDiscord(config)
+	case messengerSlack:
+		return listenSlack(config)
+	case messengerRelay:
+		return listenRelay(config)
+	case messengerMatrix:
+		return listenMatrix(config)
+	}
+
 	fmt.Printf("Bot listening... (chat: %d, group: %d)\n", config.ChatID, config.GroupID)
 	fmt.Printf("Active sessions: %d\n", len(config.Sessions))
 	fmt.Println("Press Ctrl+C to stop")
-
-	setBotCommands(config.BotToken)
+	logEcho = true
+	logf(levelInfo, "listener started", "version", version, "chat", config.ChatID, "group", config.GroupID, "sessions", len(config.Sessions))
+
+	// A follower gets its updates from the federation hub, so it doesn't
+	// compete with it for getUpdates
+	follower := config.FederationHub != ""
+	if isFederated(config) {
+		if config.FederationSecret == "" {
+			return fmt.Errorf("federation needs a shared secret: ccc config federation-secret <secret>")
+		}
+		if follower {
+			if err := checkFederationHub(config.FederationHub); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Machine: %s\n", machineName(config))
+	}
+	if follower {
+		fmt.Printf("Following federation hub %s\n", config.FederationHub)
+	} else {
+		setBotCommands(config.BotToken)
+	}
+	if config.FederationPort != 0 && !follower {
+		federation = newFederationHub()
+		go startFederationHub(config, federation)
+	}
 
 	// Start session monitor (polls tmux sessions and syncs output to Telegram)
 	go startSessionMonitor(config)
+	go startScheduler()
+	go startControlServer()
+	go startWatchdog(config)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -612,37 +1010,78 @@ func listen() error {
 	go func() {
 		<-sigChan
 		fmt.Println("\nShutting down...")
+		logf(levelInfo, "listener stopped")
 		os.Exit(0)
 	}()
 
 	for {
-		reqURL := fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates?offset=%d&timeout=30", config.BotToken, offset)
-		resp, err := telegramClientGet(client, config.BotToken, reqURL)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Network error: %v (retrying...)\n", err)
-			time.Sleep(5 * time.Second)
-			continue
-		}
+		var updates TelegramUpdate
+		if follower {
+			// Another machine polls Telegram; ask it for our updates
+			updates, err = pollFederationHub(client, config, offset)
+			if err != nil {
+				logf(levelWarn, "federation hub poll failed, retrying", "hub", config.FederationHub, "err", err)
+				time.Sleep(5 * time.Second)
+				continue
+			}
+		} else {
+			reqURL := fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates?offset=%d&timeout=30&allowed_updates=%s", config.BotToken, offset, url.QueryEscape(telegramAllowedUpdates))
+			resp, err := telegramClientGet(client, config.BotToken, reqURL)
+			if err != nil {
+				logf(levelWarn, "getUpdates network error, retrying", "err", err)
+				time.Sleep(5 * time.Second)
+				continue
+			}
 
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
-		resp.Body.Close()
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+			resp.Body.Close()
 
-		var updates TelegramUpdate
-		if err := json.Unmarshal(body, &updates); err != nil {
-			fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
-			time.Sleep(time.Second)
-			continue
+			if err := json.Unmarshal(body, &updates); err != nil {
+				logf(levelWarn, "getUpdates parse error", "err", err)
+				time.Sleep(time.Second)
+				continue
+			}
 		}
 
 		if !updates.OK {
-			fmt.Fprintf(os.Stderr, "Telegram API error: %s\n", updates.Description)
+			logf(levelError, "getUpdates failed", "description", updates.Description)
 			time.Sleep(5 * time.Second)
 			continue
 		}
+		markPoll()
 
 		for _, update := range updates.Result {
 			offset = update.UpdateID + 1
 
+			// Federation: updates for other machines' sessions are queued for
+			// them on the hub and skipped on a follower
+			if isFederated(config) {
+				config, _ = loadConfig()
+				if !isLocalUpdate(config, update) {
+					if federation != nil && federation.followersOnline(time.Now()) {
+						federation.push(update)
+						continue
+					}
+					if follower {
+						continue
+					}
+				}
+			}
+
+			// Reactions on session messages: 👍 ⏹ 🔁
+			if update.Reaction != nil {
+				config, _ = loadConfig()
+				go handleReaction(config, update.Reaction)
+				continue
+			}
+
+			// Inline queries: @bot <session> from any chat
+			if update.InlineQuery != nil {
+				config, _ = loadConfig()
+				go handleInlineQuery(config, update.InlineQuery)
+				continue
+			}
+
 			// Handle callback queries (button presses)
 			if update.CallbackQuery != nil {
 				cb := update.CallbackQuery
@@ -653,44 +1092,120 @@ func listen() error {
 
 				answerCallbackQuery(config, cb.ID)
 
-				// Parse callback data: session:questionIndex:totalQuestions:optionIndex
-				parts := strings.Split(cb.Data, ":")
-				if len(parts) >= 3 {
-					sessionName := parts[0]
-					questionIndex, _ := strconv.Atoi(parts[1])
-					var totalQuestions, optionIndex int
-					if len(parts) == 4 {
-						totalQuestions, _ = strconv.Atoi(parts[2])
-						optionIndex, _ = strconv.Atoi(parts[3])
-					} else {
-						// Legacy format: session:questionIndex:optionIndex
-						optionIndex, _ = strconv.Atoi(parts[2])
+				// /list buttons: list:<action>:<session>
+				if strings.HasPrefix(cb.Data, listCallbackPrefix) {
+					if cb.Message != nil {
+						config, _ = loadConfig()
+						handleListCallback(config, cb.Message.Chat.ID, cb.Message.MessageThreadID, cb.Data)
 					}
+					continue
+				}
 
-					// Edit message to show selection and remove buttons
-					if cb.Message != nil {
-						originalText := cb.Message.Text
-						newText := fmt.Sprintf("%s\n\n✓ Selected option %d", originalText, optionIndex+1)
-						editMessageRemoveKeyboard(config, cb.Message.Chat.ID, cb.Message.MessageID, newText)
+				// /c confirmation buttons: cmd:<id>:<run|cancel>
+				if strings.HasPrefix(cb.Data, commandCallbackPrefix) {
+					p, run, label := takePendingCommand(cb.Data)
+					if cb.Message != nil && label != "" {
+						editMessageRemoveKeyboard(config, cb.Message.Chat.ID, cb.Message.MessageID, cb.Message.Text+"\n\n"+label)
+					}
+					if run {
+						go streamCommand(config, p.ChatID, p.ThreadID, p.Command, p.Dir)
 					}
+					continue
+				}
 
-					tmuxName := "claude-" + strings.ReplaceAll(sessionName, ".", "_")
-					if tmuxSessionExists(tmuxName) {
-						// Send arrow down keys to select option, then Enter
-						for i := 0; i < optionIndex; i++ {
-							exec.Command(tmuxPath, "send-keys", "-t", tmuxName, "Down").Run()
-							time.Sleep(50 * time.Millisecond)
+				// Show more buttons on long blocks: more:
+				if strings.HasPrefix(cb.Data, expandCallbackPrefix) {
+					if cb.Message == nil {
+						continue
+					}
+					chatID, threadID, msgID := cb.Message.Chat.ID, cb.Message.MessageThreadID, cb.Message.MessageID
+					removeKeyboard(config, chatID, msgID)
+					e := takeExpandable(chatID, int64(msgID))
+					if e == nil {
+						sendMessage(config, chatID, threadID, "⌛ That block is no longer available")
+						continue
+					}
+					go func() {
+						if err := sendExpanded(config, chatID, threadID, int64(msgID), e); err != nil {
+							sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to send the rest: %v", err))
+						}
+					}()
+					continue
+				}
+
+				// Session buttons under search results: jump:<session>
+				if strings.HasPrefix(cb.Data, jumpCallbackPrefix) {
+					if cb.Message == nil {
+						continue
+					}
+					config, _ = loadConfig()
+					reply := jumpToSession(config, strings.TrimPrefix(cb.Data, jumpCallbackPrefix))
+					sendMessage(config, cb.Message.Chat.ID, cb.Message.MessageThreadID, reply)
+					continue
+				}
+
+				// Show full output buttons on turn summaries: out:<id>
+				if strings.HasPrefix(cb.Data, outputCallbackPrefix) {
+					if cb.Message == nil {
+						continue
+					}
+					chatID, threadID, msgID := cb.Message.Chat.ID, cb.Message.MessageThreadID, cb.Message.MessageID
+					removeKeyboard(config, chatID, msgID)
+					e := takeHeldOutput(cb.Data)
+					if e == nil {
+						sendMessage(config, chatID, threadID, "⌛ That output is no longer available")
+						continue
+					}
+					go func() {
+						if err := sendExpanded(config, chatID, threadID, int64(msgID), e); err != nil {
+							sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to send the output: %v", err))
 						}
-						exec.Command(tmuxPath, "send-keys", "-t", tmuxName, "Enter").Run()
-						fmt.Printf("[callback] Selected option %d for %s (question %d/%d)\n", optionIndex, sessionName, questionIndex+1, totalQuestions)
-
-						// After the last question, send Enter to confirm "Submit answers"
-						if totalQuestions > 0 && questionIndex == totalQuestions-1 {
-							time.Sleep(300 * time.Millisecond)
-							exec.Command(tmuxPath, "send-keys", "-t", tmuxName, "Enter").Run()
-							fmt.Printf("[callback] Auto-submitted answers for %s\n", sessionName)
+					}()
+					continue
+				}
+
+				// Truncated diff buttons: file:<id>
+				if strings.HasPrefix(cb.Data, fullFileCallbackPrefix) {
+					f, label := takeFullFile(cb.Data)
+					if f == nil {
+						if cb.Message != nil {
+							editMessageRemoveKeyboard(config, cb.Message.Chat.ID, cb.Message.MessageID, cb.Message.Text+"\n\n"+label)
 						}
+						continue
+					}
+					go func() {
+						if err := sendFile(config, f.ChatID, f.ThreadID, f.Path, f.Path); err != nil {
+							sendMessage(config, f.ChatID, f.ThreadID, fmt.Sprintf("❌ Failed to send %s: %v", filepath.Base(f.Path), err))
+						}
+					}()
+					continue
+				}
+
+				// Permission buttons: perm:<requestID>:<decision>
+				if strings.HasPrefix(cb.Data, permissionCallbackPrefix) {
+					if label, ok := handlePermissionCallback(cb.Data); ok && cb.Message != nil {
+						editMessageRemoveKeyboard(config, cb.Message.Chat.ID, cb.Message.MessageID, cb.Message.Text+"\n\n"+label)
+					}
+					continue
+				}
+
+				// Plan buttons: plan:<action>:<session>
+				if strings.HasPrefix(cb.Data, planCallbackPrefix) {
+					if label, ok := handlePlanCallback(cb.Data); ok && cb.Message != nil {
+						editMessageRemoveKeyboard(config, cb.Message.Chat.ID, cb.Message.MessageID, cb.Message.Text+"\n\n"+label)
+					}
+					continue
+				}
+
+				// Parse callback data: session:questionIndex:totalQuestions:optionIndex
+				if qc, ok := parseQuestionCallback(cb.Data); ok {
+					// Edit message to show selection and remove buttons
+					if cb.Message != nil {
+						originalText := cb.Message.Text
+						newText := fmt.Sprintf("%s\n\n✓ Selected option %d", originalText, qc.OptionIndex+1)
+						editMessageRemoveKeyboard(config, cb.Message.Chat.ID, cb.Message.MessageID, newText)
 					}
+					answerQuestion(qc)
 				}
 
 				continue
@@ -707,21 +1222,55 @@ func listen() error {
 			threadID := msg.MessageThreadID
 			isGroup := msg.Chat.Type == "supergroup"
 
-			// Voice messages not supported (whisper removed)
+			// Voice messages are transcribed with the configured backend and sent as text
 			if msg.Voice != nil {
-				if isGroup && threadID > 0 {
-					sendMessage(config, chatID, threadID, "Voice messages not supported. Please send text.")
+				if !isGroup || threadID == 0 {
+					continue
+				}
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					continue
+				}
+				if configuredTranscriptionBackend(config) == "" {
+					sendMessage(config, chatID, threadID, "Voice messages are not set up. Run: ccc config transcription-backend <local|openai|deepgram>")
+					continue
 				}
+				fileID := msg.Voice.FileID
+				go func() {
+					audioPath := filepath.Join(os.TempDir(), fmt.Sprintf("telegram_voice_%d.ogg", time.Now().UnixNano()))
+					defer os.Remove(audioPath)
+					if err := downloadTelegramFile(config, fileID, audioPath); err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Download failed: %v", err))
+						return
+					}
+					text, err := transcribeAudio(config, audioPath)
+					if err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Transcription failed: %v", err))
+						return
+					}
+					sendMessage(config, chatID, threadID, "🎤 "+text)
+					forwardToSession(config, getMessenger(config), chatID, threadID, sessName, text)
+				}()
+				continue
+			}
+
+			// Photos and documents outside session topics go to the inbox
+			if (len(msg.Photo) > 0 || msg.Document != nil) && (!isGroup || threadID == 0) {
+				config, _ = loadConfig()
+				go handleInboxMedia(config, chatID, threadID, &msg, "")
 				continue
 			}
 
 			// Handle photo messages
 			if len(msg.Photo) > 0 && isGroup && threadID > 0 {
 				config, _ = loadConfig()
-				sessionName := getSessionByTopic(config, threadID)
+				sessionName := getSessionByChatTopic(config, chatID, threadID)
 				if sessionName != "" {
-					tmuxName := "claude-" + strings.ReplaceAll(sessionName, ".", "_")
-					if tmuxSessionExists(tmuxName) {
+					tmuxName := tmuxPrefix() + strings.ReplaceAll(sessionName, ".", "_")
+					if tmuxSessionExists(tmuxName) && isClaudeExited(tmuxName) {
+						sendMessage(config, chatID, threadID, "💥 Claude is not running in this session. Use /restart-claude to start it again.")
+					} else if tmuxSessionExists(tmuxName) {
 						// Get largest photo (last in array)
 						photo := msg.Photo[len(msg.Photo)-1]
 						imgPath := filepath.Join(os.TempDir(), fmt.Sprintf("telegram_%d.jpg", time.Now().UnixNano()))
@@ -737,7 +1286,11 @@ func listen() error {
 							ResetSessionMonitor(sessionName)
 							sendToTmuxWithDelay(tmuxName, prompt, 2*time.Second)
 						}
+					} else {
+						go handleInboxMedia(config, chatID, threadID, &msg, fmt.Sprintf("⚠️ Session '%s' is not running.", sessionName))
 					}
+				} else {
+					go handleInboxMedia(config, chatID, threadID, &msg, "⚠️ No session linked to this topic.")
 				}
 				continue
 			}
@@ -745,10 +1298,22 @@ func listen() error {
 			// Handle document messages
 			if msg.Document != nil && isGroup && threadID > 0 {
 				config, _ = loadConfig()
-				sessionName := getSessionByTopic(config, threadID)
+				sessionName := getSessionByChatTopic(config, chatID, threadID)
 				if sessionName != "" {
-					tmuxName := "claude-" + strings.ReplaceAll(sessionName, ".", "_")
-					if tmuxSessionExists(tmuxName) {
+					// Remember the file so `ccc receive` can fetch it again later
+					if err := recordSessionFile(sessionName, SessionFile{
+						MessageID: int64(msg.MessageID),
+						FileID:    msg.Document.FileID,
+						FileName:  msg.Document.FileName,
+						FileSize:  int64(msg.Document.FileSize),
+						Posted:    time.Unix(msg.Date, 0),
+					}); err != nil {
+						hookLog("store: failed to record file for %s: %v", sessionName, err)
+					}
+					tmuxName := tmuxPrefix() + strings.ReplaceAll(sessionName, ".", "_")
+					if tmuxSessionExists(tmuxName) && isClaudeExited(tmuxName) {
+						sendMessage(config, chatID, threadID, "💥 Claude is not running in this session. Use /restart-claude to start it again.")
+					} else if tmuxSessionExists(tmuxName) {
 						sessionInfo := config.Sessions[sessionName]
 						destDir := sessionInfo.Path
 						if destDir == "" {
@@ -768,7 +1333,17 @@ func listen() error {
 							ResetSessionMonitor(sessionName)
 							sendToTmux(tmuxName, caption)
 						}
+					} else {
+						// No Claude to hand it to: keep it in the project for later
+						path, err := saveMediaTo(config, &msg, config.Sessions[sessionName].Path)
+						if err != nil {
+							sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Download failed: %v", err))
+						} else {
+							sendMessage(config, chatID, threadID, fmt.Sprintf("📎 File saved: %s\nSession '%s' is not running; mention the file once it is.", path, sessionName))
+						}
 					}
+				} else {
+					go handleInboxMedia(config, chatID, threadID, &msg, "⚠️ No session linked to this topic.")
 				}
 				continue
 			}
@@ -794,40 +1369,115 @@ func listen() error {
 			// Handle commands
 			if strings.HasPrefix(text, "/c ") {
 				cmdStr := strings.TrimPrefix(text, "/c ")
-				output, err := executeCommand(cmdStr)
+				home, _ := os.UserHomeDir()
+				workDir, err := jailCommand(config, cmdStr, home)
 				if err != nil {
-					output = fmt.Sprintf("⚠️ %s\n\nExit: %v", output, err)
+					auditCommand(chatID, threadID, home, cmdStr, "blocked: "+err.Error())
+					sendMessage(config, chatID, threadID, fmt.Sprintf("🚫 %v", err))
+					continue
+				}
+				if config.ConfirmDestructive && matchDestructive(config, cmdStr) != "" {
+					if err := requestCommandConfirmation(config, chatID, threadID, cmdStr, workDir); err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to ask for confirmation: %v", err))
+					}
+					continue
 				}
-				sendMessage(config, chatID, threadID, output)
+				auditCommand(chatID, threadID, workDir, cmdStr, "run")
+				go streamCommand(config, chatID, threadID, cmdStr, workDir)
 				continue
 			}
 
-			if text == "/update" {
-				updateCCC(config, chatID, threadID, offset)
+			// /stop - the /c command running here, else Claude's turn in this topic
+			if text == "/stop" {
+				if stopStreamCommand(chatID, threadID) {
+					continue
+				}
+				config, _ = loadConfig()
+				if sessName := getSessionByChatTopic(config, chatID, threadID); isGroup && threadID > 0 && sessName != "" {
+					sendMessage(config, chatID, threadID, interruptSession(config, sessName))
+					continue
+				}
+				sendMessage(config, chatID, threadID, "No running command here.")
 				continue
 			}
 
-			if text == "/restart" {
-				sendMessage(config, chatID, threadID, "🔄 Restarting ccc service...")
+			if text == "/update" || strings.HasPrefix(text, "/update ") {
+				updateCCC(config, chatID, threadID, offset, strings.TrimSpace(strings.TrimPrefix(text, "/update")))
+				continue
+			}
+
+			if text == "/rollback" {
+				rollbackCCC(config, chatID, threadID, offset)
+				continue
+			}
+
+			if text == "/restart" {
+				sendMessage(config, chatID, threadID, "🔄 Restarting ccc service...")
 				// Re-exec ourselves to restart cleanly
-				go func() {
-					time.Sleep(500 * time.Millisecond)
-					exe, err := os.Executable()
-					if err != nil {
-						return
-					}
-					exec.Command(exe, "listen").Start()
-					os.Exit(0)
-				}()
+				go restartListener()
+				continue
+			}
+
+			// /away [on|off|auto|schedule <spec>|idle <hours>]
+			if text == "/away" || strings.HasPrefix(text, "/away ") {
+				config, _ = loadConfig()
+				reply, err := handleAwayCommand(config, strings.TrimSpace(strings.TrimPrefix(text, "/away")))
+				if err != nil {
+					reply = "❌ " + err.Error()
+				}
+				sendMessage(config, chatID, threadID, reply)
+				continue
+			}
+
+			// /default [<session>|off] - session the General area forwards to
+			if text == "/default" || strings.HasPrefix(text, "/default ") {
+				config, _ = loadConfig()
+				reply, err := handleDefaultCommand(config, strings.TrimSpace(strings.TrimPrefix(text, "/default")))
+				if err != nil {
+					reply = "❌ " + err.Error()
+				}
+				sendMessage(config, chatID, threadID, reply)
+				continue
+			}
+
+			// /machines - machines sharing the bot and whether they're reachable
+			if text == "/machines" {
+				config, _ = loadConfig()
+				sendMessage(config, chatID, threadID, formatMachines(config, federation, time.Now()))
 				continue
 			}
 
 			if text == "/stats" {
-				stats := getSystemStats()
+				config, _ = loadConfig()
+				stats := strings.TrimRight(getSystemStats(), "\n") + "\n\n" + formatBusySessions(busySessions(), config.MaxConcurrentSessions, time.Now())
+				if usage := formatSessionResources(config); usage != "" {
+					stats += "\n\n" + usage
+				}
 				sendMessage(config, chatID, threadID, stats)
 				continue
 			}
 
+			if text == "/logs" || strings.HasPrefix(text, "/logs ") {
+				out, err := recentLogText(strings.TrimSpace(strings.TrimPrefix(text, "/logs")))
+				if err != nil {
+					sendMessage(config, chatID, threadID, err.Error())
+					continue
+				}
+				sendCodeBlock(config, chatID, threadID, "", out)
+				continue
+			}
+
+			if text == "/help" || text == "/start" {
+				where := inPrivate
+				if isGroup && threadID > 0 {
+					where = inTopic
+				} else if isGroup {
+					where = inGroup
+				}
+				sendMessage(config, chatID, threadID, formatHelp(where))
+				continue
+			}
+
 			if text == "/version" {
 				sendMessage(config, chatID, threadID, fmt.Sprintf("ccc %s", version))
 				continue
@@ -838,83 +1488,615 @@ func listen() error {
 				continue
 			}
 
-			// If auth is waiting for code, send it
-			if authWaitingCode && !strings.HasPrefix(text, "/") {
-				go handleAuthCode(config, chatID, threadID, text)
+			if text == "/cancel" {
+				if cancelAuth() {
+					sendMessage(config, chatID, threadID, "🛑 Auth cancelled")
+				} else {
+					sendMessage(config, chatID, threadID, "Nothing to cancel")
+				}
+				continue
+			}
+
+			// If auth is waiting for code in this chat/thread, send it
+			if gen, ok := isAuthCodeMessage(chatID, threadID, text); ok {
+				go handleAuthCode(config, chatID, threadID, text, gen)
 				continue
 			}
 
-			// /list command - show all sessions with status
+			// /list command - show all sessions with status and action buttons
 			if text == "/list" {
 				config, _ = loadConfig()
-				handleRouterStatus(config, chatID, threadID)
+				handleListCommand(config, chatID, threadID)
+				continue
+			}
+
+			// /json <command> - structured results for automation
+			if text == "/json" || strings.HasPrefix(text, "/json ") {
+				config, _ = loadConfig()
+				handleJSONCommand(config, chatID, threadID, strings.TrimPrefix(text, "/json"))
 				continue
 			}
 
 			// /continue command - restart session preserving conversation history
 			if text == "/continue" && isGroup && threadID > 0 {
 				config, _ = loadConfig()
-				sessName := getSessionByTopic(config, threadID)
+				sessName := getSessionByChatTopic(config, chatID, threadID)
 				if sessName == "" {
 					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic. Use /new <name> to create one.")
 					continue
 				}
-				tmuxName := "claude-" + strings.ReplaceAll(sessName, ".", "_")
-				if tmuxSessionExists(tmuxName) {
-					killTmuxSession(tmuxName)
-					time.Sleep(300 * time.Millisecond)
+				restartSession(config, chatID, threadID, sessName)
+				continue
+			}
+
+			// /cost - token usage and estimated cost (this session in a topic, else all)
+			if text == "/cost" {
+				config, _ = loadConfig()
+				usage := collectSessionUsage(config, time.Now())
+				if sessName := getSessionByChatTopic(config, chatID, threadID); isGroup && threadID > 0 && sessName != "" {
+					for _, u := range usage {
+						if u.Name == sessName {
+							sendMessage(config, chatID, threadID, formatSessionCost(u))
+						}
+					}
+					continue
 				}
-				// Clear monitor state and block cache for fresh start
-				ClearSessionMonitor(sessName)
-				// Use the stored path from config, fallback to resolveProjectPath
-				sessionInfo := config.Sessions[sessName]
-				workDir := sessionInfo.Path
-				if workDir == "" {
-					workDir = resolveProjectPath(config, sessName)
+				sendMessage(config, chatID, threadID, formatCostReport(usage))
+				continue
+			}
+
+			// /digest [now] - daily activity digest
+			if text == "/digest" || text == "/digest now" {
+				config, _ = loadConfig()
+				if text == "/digest" {
+					if config.DigestTime == "" {
+						sendMessage(config, chatID, threadID, "📰 No daily digest. Turn it on with: ccc config digest-time 08:30\n/digest now sends one now.")
+					} else {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("📰 Daily digest at %s in the private chat. /digest now sends one now.", config.DigestTime))
+					}
+					continue
 				}
-				if _, err := os.Stat(workDir); os.IsNotExist(err) {
-					os.MkdirAll(workDir, 0755)
+				go sendMessage(config, chatID, threadID, buildDigest(config, time.Now()))
+				continue
+			}
+
+			// /search <query> - search transcripts and session directories
+			if text == "/search" || strings.HasPrefix(text, "/search ") {
+				config, _ = loadConfig()
+				query := strings.TrimSpace(strings.TrimPrefix(text, "/search"))
+				if query == "" {
+					sendMessage(config, chatID, threadID, "Usage: /search <query>")
+					continue
 				}
-				if err := createTmuxSession(tmuxName, workDir, true); err != nil {
-					sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to start: %v", err))
+				go func() {
+					hits := searchSessions(config, query)
+					result := formatSearchHits(query, hits)
+					if buttons := searchButtons(config, hits); len(buttons) > 0 {
+						getMessenger(config).SendWithKeyboard(chatID, threadID, result, buttons)
+						return
+					}
+					sendMessage(config, chatID, threadID, result)
+				}()
+				continue
+			}
+
+			// /catchup [n] - recap of the last n blocks plus current status
+			if (text == "/catchup" || strings.HasPrefix(text, "/catchup ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				n := defaultCatchupBlocks
+				if arg := strings.TrimSpace(strings.TrimPrefix(text, "/catchup")); arg != "" {
+					v, err := strconv.Atoi(arg)
+					if err != nil || v <= 0 {
+						sendMessage(config, chatID, threadID, "Usage: /catchup [n]")
+						continue
+					}
+					n = v
+				}
+				sendMessage(config, chatID, threadID, buildCatchup(sessName, n))
+				continue
+			}
+
+			// /autocommit [on|off|push <target>|push off] - git checkpoints after each completed turn, optionally pushed
+			if (text == "/autocommit" || strings.HasPrefix(text, "/autocommit ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				info := config.Sessions[sessName]
+				arg := strings.TrimSpace(strings.TrimPrefix(text, "/autocommit"))
+				switch {
+				case arg == "on":
+					if !isGitRepo(info.Path) {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("⚠️ %s is not a git repository", info.Path))
+						continue
+					}
+					info.AutoCommit = true
+				case arg == "off":
+					info.AutoCommit = false
+				case arg == "push off":
+					info.AutoPush = ""
+				case strings.HasPrefix(arg, "push "):
+					target := strings.TrimSpace(strings.TrimPrefix(arg, "push "))
+					remote, _ := parsePushTarget(target)
+					if _, err := runGit(info.Path, "remote", "get-url", remote); err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("⚠️ No git remote %q in %s", remote, info.Path))
+						continue
+					}
+					info.AutoPush = target
+				case arg == "":
+				default:
+					sendMessage(config, chatID, threadID, "Usage: /autocommit [on|off|push <remote>[/<branch>]|push off]")
+					continue
+				}
+				saveSession(sessName, info)
+				if info.AutoCommit {
+					reply := "📌 Auto-commit is on: a checkpoint commit is made after each completed turn"
+					if info.AutoPush != "" {
+						reply += " and pushed to " + info.AutoPush
+					}
+					sendMessage(config, chatID, threadID, reply)
+				} else if info.AutoPush != "" {
+					sendMessage(config, chatID, threadID, fmt.Sprintf("Auto-commit is off; checkpoints will be pushed to %s once it is on", info.AutoPush))
 				} else {
-					time.Sleep(500 * time.Millisecond)
-					if tmuxSessionExists(tmuxName) {
-						sendMessage(config, chatID, threadID, fmt.Sprintf("🔄 Session '%s' restarted with conversation history", sessName))
-					} else {
-						sendMessage(config, chatID, threadID, "⚠️ Session died immediately")
+					sendMessage(config, chatID, threadID, "Auto-commit is off")
+				}
+				continue
+			}
+
+			// /gate [command|off] - test command run after each completed turn
+			if (text == "/gate" || strings.HasPrefix(text, "/gate ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				info := config.Sessions[sessName]
+				switch arg := strings.TrimSpace(strings.TrimPrefix(text, "/gate")); arg {
+				case "":
+				case "off":
+					info.TestGate = ""
+				default:
+					if _, err := jailCommand(config, arg, info.Path); err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+						continue
+					}
+					info.TestGate = arg
+				}
+				saveSession(sessName, info)
+				if info.TestGate != "" {
+					sendMessage(config, chatID, threadID, fmt.Sprintf("🧪 Test gate: %s runs after each completed turn. Failures go back to Claude up to %d times in a row.", info.TestGate, maxGateRetries))
+				} else {
+					sendMessage(config, chatID, threadID, "Test gate is off. /gate <command> runs it after each completed turn, e.g. /gate go test ./...")
+				}
+				continue
+			}
+
+			// /mode [tmux|headless] - how Claude runs for this session
+			if (text == "/mode" || strings.HasPrefix(text, "/mode ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				mode := strings.TrimSpace(strings.TrimPrefix(text, "/mode"))
+				if mode == "" {
+					current := sessionModeTmux
+					if isHeadless(config.Sessions[sessName]) {
+						current = sessionModeHeadless
+					}
+					sendMessage(config, chatID, threadID, fmt.Sprintf("Mode: %s\n\nUsage: /mode tmux|headless", current))
+					continue
+				}
+				if err := setSessionMode(config, sessName, mode); err != nil {
+					sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+					continue
+				}
+				if mode == sessionModeHeadless {
+					sendMessage(config, chatID, threadID, "🪶 Headless: each message runs claude -p, resuming this conversation. The tmux session was stopped.")
+				} else {
+					sendMessage(config, chatID, threadID, "🖥️ tmux: Claude runs interactively again, continuing the conversation")
+				}
+				continue
+			}
+
+			// /verbose [on|off] - one message per block, or consecutive blocks coalesced
+			if (text == "/verbose" || strings.HasPrefix(text, "/verbose ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				info := config.Sessions[sessName]
+				switch strings.TrimSpace(strings.TrimPrefix(text, "/verbose")) {
+				case "on":
+					info.Coalesce = false
+				case "off":
+					info.Coalesce = true
+				case "":
+				default:
+					sendMessage(config, chatID, threadID, "Usage: /verbose [on|off]")
+					continue
+				}
+				saveSession(sessName, info)
+				if info.Coalesce {
+					sendMessage(config, chatID, threadID, "🔇 Verbose is off: consecutive blocks are combined into one message that is edited as they arrive")
+				} else {
+					sendMessage(config, chatID, threadID, "🔊 Verbose is on: each block is sent as its own message")
+				}
+				continue
+			}
+
+			// /checkpoint [label] and /rewind [label] - save and restore the work tree and conversation
+			if (text == "/checkpoint" || strings.HasPrefix(text, "/checkpoint ") || text == "/rewind" || strings.HasPrefix(text, "/rewind ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				info := config.Sessions[sessName]
+				if strings.HasPrefix(text, "/checkpoint") {
+					cp, err := createCheckpoint(sessName, info, strings.TrimSpace(strings.TrimPrefix(text, "/checkpoint")), time.Now())
+					if err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+						continue
+					}
+					sendMessage(config, chatID, threadID, fmt.Sprintf("💾 Checkpoint %s saved. /rewind %s goes back to it.", cp.Label, cp.Label))
+					continue
+				}
+				label := strings.TrimSpace(strings.TrimPrefix(text, "/rewind"))
+				if label == "" {
+					cps, err := listCheckpoints(info.Path, sessName)
+					if err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+						continue
 					}
+					sendMessage(config, chatID, threadID, formatCheckpoints(cps, time.Now()))
+					continue
 				}
+				go func() {
+					reply, err := rewindSession(sessName, info, label)
+					if err != nil {
+						reply = fmt.Sprintf("❌ Rewind failed: %v", err)
+					}
+					sendMessage(config, chatID, threadID, reply)
+				}()
+				continue
+			}
+
+			// /plan [on|off] - start Claude in plan mode
+			if (text == "/plan" || strings.HasPrefix(text, "/plan ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				sendMessage(config, chatID, threadID, handlePlanCommand(sessName, config.Sessions[sessName], strings.TrimSpace(strings.TrimPrefix(text, "/plan"))))
+				continue
+			}
+
+			// /flags [flags|off] and /model [name|default] - extra claude flags for the session
+			if (text == "/flags" || strings.HasPrefix(text, "/flags ") || text == "/model" || strings.HasPrefix(text, "/model ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				info := config.Sessions[sessName]
+				if strings.HasPrefix(text, "/flags") {
+					sendMessage(config, chatID, threadID, handleFlagsCommand(sessName, info, strings.TrimSpace(strings.TrimPrefix(text, "/flags"))))
+				} else {
+					sendMessage(config, chatID, threadID, handleModelCommand(sessName, info, strings.TrimSpace(strings.TrimPrefix(text, "/model"))))
+				}
+				continue
+			}
+
+			// /git status|diff|log|push|pull - quick repo operations in the session's directory
+			if (text == "/git" || strings.HasPrefix(text, "/git ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				info := config.Sessions[sessName]
+				arg := strings.TrimSpace(strings.TrimPrefix(text, "/git"))
+				go func() {
+					out, err := runSessionGit(config, info, arg)
+					if err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+						return
+					}
+					lang := ""
+					if strings.HasPrefix(arg, "diff") {
+						lang = "diff"
+					}
+					sendCodeBlock(config, chatID, threadID, lang, out)
+				}()
+				continue
+			}
+
+			// /handoff <target> [instructions] - send this session's last answer to another session
+			if (text == "/handoff" || strings.HasPrefix(text, "/handoff ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				target, prompt, err := buildHandoff(config, sessName, strings.TrimPrefix(text, "/handoff"))
+				if err != nil {
+					sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+					continue
+				}
+				targetTopic := config.Sessions[target].TopicID
+				sendMessage(config, chatID, targetTopic, fmt.Sprintf("📥 Handoff from %s", sessName))
+				forwardToSession(config, getMessenger(config), chatID, targetTopic, target, prompt)
+				sendMessage(config, chatID, threadID, fmt.Sprintf("📤 Handed off to %s", target))
+				continue
+			}
+
+			// /pr [title] - push the session's branch and open a GitHub pull request
+			if (text == "/pr" || strings.HasPrefix(text, "/pr ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				info := config.Sessions[sessName]
+				title := strings.TrimSpace(strings.TrimPrefix(text, "/pr"))
+				sendMessage(config, chatID, threadID, "⏳ Pushing and opening a pull request...")
+				go func() {
+					url, err := createPullRequest(config, info.Path, title)
+					if err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+						return
+					}
+					sendMessage(config, chatID, threadID, "🔀 Pull request: "+url)
+				}()
+				continue
+			}
+
+			// /issues <n> - send a GitHub issue to Claude as a prompt
+			if (text == "/issues" || strings.HasPrefix(text, "/issues ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				info := config.Sessions[sessName]
+				n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(text, "/issues")), "#"))
+				if err != nil || n <= 0 {
+					sendMessage(config, chatID, threadID, "Usage: /issues <number>")
+					continue
+				}
+				go func() {
+					issue, err := fetchIssue(config, info.Path, n)
+					if err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+						return
+					}
+					sendMessage(config, chatID, threadID, fmt.Sprintf("📥 Issue #%d: %s", issue.Number, issue.Title))
+					forwardToSession(config, getMessenger(config), chatID, threadID, sessName, issuePrompt(issue))
+				}()
+				continue
+			}
+
+			// /export - zip of transcripts, block cache and a Markdown log
+			if text == "/export" && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				sendMessage(config, chatID, threadID, "📦 Exporting...")
+				go func() {
+					if err := sendSessionExport(config, sessName); err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Export failed: %v", err))
+					}
+				}()
+				continue
+			}
+
+			// /tail [lines|on|off] - raw pane output, once or as a live pinned message
+			if (text == "/tail" || strings.HasPrefix(text, "/tail ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				lines, mode, err := parseTailArg(strings.TrimSpace(strings.TrimPrefix(text, "/tail")))
+				if err != nil {
+					sendMessage(config, chatID, threadID, err.Error())
+					continue
+				}
+				if mode == "off" {
+					if !stopTail(sessName) {
+						sendMessage(config, chatID, threadID, "No live tail running")
+					}
+					continue
+				}
+				if !tmuxSessionExists(sessionName(sessName)) {
+					sendMessage(config, chatID, threadID, "⚠️ Session is not running")
+					continue
+				}
+				if mode == "on" {
+					if err := startTail(config, chatID, threadID, sessName); err != nil {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+					}
+					continue
+				}
+				out, err := paneTail(sessName, lines)
+				if err != nil {
+					sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to capture pane: %v", err))
+					continue
+				}
+				sendCodeBlock(config, chatID, threadID, "", out)
+				continue
+			}
+
+			// /schedule <cron> <prompt> - fire a prompt into this session on a schedule
+			if (text == "/schedule" || strings.HasPrefix(text, "/schedule ")) && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				expr, prompt, err := parseScheduleArgs(strings.TrimPrefix(text, "/schedule"))
+				if err != nil {
+					sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v\n\nUsage: /schedule \"0 9 * * 1\" run the test suite and summarize failures", err))
+					continue
+				}
+				info := config.Sessions[sessName]
+				id := addSchedule(info, expr, prompt, time.Now())
+				if err := saveSession(sessName, info); err != nil {
+					sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to save schedule: %v", err))
+					continue
+				}
+				spec, _ := parseCron(expr)
+				next := "never (no matching date)"
+				if t := spec.next(time.Now()); !t.IsZero() {
+					next = t.Format("Mon Jan 2 15:04")
+				}
+				sendMessage(config, chatID, threadID, fmt.Sprintf("⏰ Scheduled #%d (%s), next run %s", id, expr, next))
+				continue
+			}
+
+			// /schedules - list scheduled prompts (this session in a topic, all elsewhere)
+			if text == "/schedules" {
+				config, _ = loadConfig()
+				only := ""
+				if isGroup && threadID > 0 {
+					only = getSessionByChatTopic(config, chatID, threadID)
+				}
+				sendMessage(config, chatID, threadID, formatSchedules(config, only, time.Now()))
+				continue
+			}
+
+			// /unschedule <id> - remove a scheduled prompt from this session
+			if strings.HasPrefix(text, "/unschedule") && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				id, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(text, "/unschedule")), "#"))
+				if err != nil {
+					sendMessage(config, chatID, threadID, "Usage: /unschedule <id> (see /schedules)")
+					continue
+				}
+				info := config.Sessions[sessName]
+				if !removeSchedule(info, id) {
+					sendMessage(config, chatID, threadID, fmt.Sprintf("❌ No schedule #%d in this session", id))
+					continue
+				}
+				saveSession(sessName, info)
+				sendMessage(config, chatID, threadID, fmt.Sprintf("🗑️ Removed schedule #%d", id))
+				continue
+			}
+
+			// /merge - merge a worktree session's branch back into its repository
+			if text == "/merge" && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
+					continue
+				}
+				info := config.Sessions[sessName]
+				if info.WorktreeRepo == "" {
+					sendMessage(config, chatID, threadID, "⚠️ This session is not a worktree. Create one with /new <name> --worktree <repo> [branch]")
+					continue
+				}
+				target, err := mergeWorktree(info)
+				if err != nil {
+					sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+					continue
+				}
+				sendMessage(config, chatID, threadID, fmt.Sprintf("🔀 Merged %s into %s in %s", info.Branch, target, info.WorktreeRepo))
+				continue
+			}
+
+			// /restart-claude command - restart only the Claude process, keeping the tmux session
+			if (text == "/restart-claude" || text == "/restart_claude") && isGroup && threadID > 0 {
+				config, _ = loadConfig()
+				sessName := getSessionByChatTopic(config, chatID, threadID)
+				if sessName == "" {
+					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic. Use /new <name> to create one.")
+					continue
+				}
+				tmuxName := sessionName(sessName)
+				if !tmuxSessionExists(tmuxName) {
+					sendMessage(config, chatID, threadID, "❌ Session is not running. Use /continue to start it.")
+					continue
+				}
+				markClaudeRestarting(sessName)
+				if err := restartClaudeInPane(tmuxName); err != nil {
+					sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to restart Claude: %v\n\nUse /continue to recreate the session.", err))
+					continue
+				}
+				ResetSessionMonitor(sessName)
+				sendMessage(config, chatID, threadID, fmt.Sprintf("🔄 Claude restarted in '%s' (tmux session kept)", sessName))
 				continue
 			}
 
 			// /delete command - delete session and thread
 			if text == "/delete" && isGroup && threadID > 0 {
 				config, _ = loadConfig()
-				sessName := getSessionByTopic(config, threadID)
+				sessName := getSessionByChatTopic(config, chatID, threadID)
 				if sessName == "" {
 					sendMessage(config, chatID, threadID, "❌ No session mapped to this topic.")
 					continue
 				}
 				// Kill tmux session
-				tmuxName := "claude-" + strings.ReplaceAll(sessName, ".", "_")
+				tmuxName := tmuxPrefix() + strings.ReplaceAll(sessName, ".", "_")
 				if tmuxSessionExists(tmuxName) {
 					killTmuxSession(tmuxName)
 				}
 				// Remove from config
-				topicID := config.Sessions[sessName].TopicID
+				topicID, groupID := config.Sessions[sessName].TopicID, sessionChat(config, sessName)
 				delete(config.Sessions, sessName)
-				saveConfig(config)
+				deleteSession(sessName)
 				// Clear monitor and cache
 				ClearSessionMonitor(sessName)
 				// Delete telegram thread
-				if err := deleteForumTopic(config, topicID); err != nil {
+				if err := deleteForumTopic(config, groupID, topicID); err != nil {
 					sendMessage(config, chatID, threadID, fmt.Sprintf("⚠️ Session deleted but failed to delete thread: %v", err))
 				}
 				// No message needed - thread is gone
 				continue
 			}
 
+			// /gc [remove] - reconcile config, tmux sessions and topics
+			if text == "/gc" || text == "/gc remove" {
+				config, _ = loadConfig()
+				r := checkSessionDrift(config)
+				remove := text == "/gc remove"
+				if remove {
+					removeOrphans(config, r)
+				}
+				sendMessage(config, chatID, threadID, formatGCReport(r, remove))
+				continue
+			}
+
 			// /cleanup command - delete tmux sessions and Telegram topics (NOT folders)
 			if text == "/cleanup" {
 				config, _ = loadConfig()
@@ -928,7 +2110,7 @@ func listen() error {
 
 				for sessName, info := range config.Sessions {
 					// Kill tmux session
-					tmuxName := "claude-" + strings.ReplaceAll(sessName, ".", "_")
+					tmuxName := tmuxPrefix() + strings.ReplaceAll(sessName, ".", "_")
 					if tmuxSessionExists(tmuxName) {
 						killTmuxSession(tmuxName)
 					}
@@ -940,8 +2122,8 @@ func listen() error {
 					ClearSessionMonitor(sessName)
 
 					// Delete telegram thread
-					if info.TopicID > 0 && config.GroupID > 0 {
-						if err := deleteForumTopic(config, info.TopicID); err != nil {
+					if group := sessionGroup(config, info); info.TopicID > 0 && group != 0 {
+						if err := deleteForumTopic(config, group, info.TopicID); err != nil {
 							errors = append(errors, fmt.Sprintf("%s: %v", sessName, err))
 						}
 					}
@@ -949,9 +2131,9 @@ func listen() error {
 					cleaned = append(cleaned, sessName)
 				}
 
-				// Clear all sessions from config
+				// Clear all sessions from the store
 				config.Sessions = make(map[string]*SessionInfo)
-				saveConfig(config)
+				deleteAllSessions()
 
 				msg := fmt.Sprintf("🧹 Cleaned %d sessions: %s", len(cleaned), strings.Join(cleaned, ", "))
 				if len(errors) > 0 {
@@ -966,48 +2148,60 @@ func listen() error {
 				config, _ = loadConfig()
 				arg := strings.TrimSpace(strings.TrimPrefix(text, "/new"))
 
-				// /new <name> - create brand new session + topic
+				// /new <name> [--worktree <repo> [branch]] - create brand new session + topic
 				if arg != "" {
-					if _, exists := config.Sessions[arg]; exists {
-						sendMessage(config, chatID, threadID, fmt.Sprintf("⚠️ Session '%s' already exists. Use /new without args in that topic to restart.", arg))
+					name, repo, branch, err := parseNewArgs(arg)
+					if err != nil {
+						sendMessage(config, chatID, threadID, "Usage: /new <name> [--worktree <repo-path> [branch]]")
 						continue
 					}
-					topicID, err := createForumTopic(config, arg)
+					if _, exists := config.Sessions[name]; exists {
+						sendMessage(config, chatID, threadID, fmt.Sprintf("⚠️ Session '%s' already exists. Use /new without args in that topic to restart.", name))
+						continue
+					}
+					workDir := resolveProjectPath(config, name)
+					info := &SessionInfo{Path: workDir, GroupID: newSessionGroup(config, chatID), Machine: sessionMachine(config)}
+					if repo != "" {
+						// Create the worktree first so a failure doesn't leave an empty topic behind
+						info.WorktreeRepo = resolveProjectPath(config, repo)
+						info.Branch = branch
+						if err := createWorktree(info.WorktreeRepo, workDir, branch); err != nil {
+							sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to create worktree: %v", err))
+							continue
+						}
+					}
+					topicID, err := createForumTopic(config, sessionGroup(config, info), name)
 					if err != nil {
 						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to create topic: %v", err))
 						continue
 					}
-					workDir := resolveProjectPath(config, arg)
-					config.Sessions[arg] = &SessionInfo{
-						TopicID: topicID,
-						Path:    workDir,
-					}
-					saveConfig(config)
+					info.TopicID = topicID
+					config.Sessions[name] = info
+					saveSession(name, info)
 					if _, err := os.Stat(workDir); os.IsNotExist(err) {
 						os.MkdirAll(workDir, 0755)
 					}
-					tmuxName := "claude-" + arg
+					started := fmt.Sprintf("🚀 Session '%s' started!\n\nSend messages here to interact with Claude.", name)
+					if repo != "" {
+						started = fmt.Sprintf("🚀 Session '%s' started on branch %s (worktree of %s)\n\nUse /merge to merge the branch back.", name, branch, info.WorktreeRepo)
+					}
+					tmuxName := sessionName(name)
 					if err := createTmuxSession(tmuxName, workDir, false); err != nil {
-						sendMessage(config, config.GroupID, topicID, fmt.Sprintf("❌ Failed to start tmux: %v", err))
+						sendMessage(config, sessionGroup(config, info), topicID, fmt.Sprintf("❌ Failed to start tmux: %v", err))
 					} else {
-						time.Sleep(500 * time.Millisecond)
-						if tmuxSessionExists(tmuxName) {
-							sendMessage(config, config.GroupID, topicID, fmt.Sprintf("🚀 Session '%s' started!\n\nSend messages here to interact with Claude.", arg))
-						} else {
-							sendMessage(config, config.GroupID, topicID, fmt.Sprintf("⚠️ Session '%s' created but died immediately. Check if ~/bin/ccc works.", arg))
-						}
+						go reportSessionStart(config, sessionGroup(config, info), topicID, tmuxName, started)
 					}
 					continue
 				}
 
 				// Without args - restart session in current topic
 				if threadID > 0 {
-					sessionName := getSessionByTopic(config, threadID)
+					sessionName := getSessionByChatTopic(config, chatID, threadID)
 					if sessionName == "" {
 						sendMessage(config, chatID, threadID, "❌ No session mapped to this topic. Use /new <name> to create one.")
 						continue
 					}
-					tmuxName := "claude-" + strings.ReplaceAll(sessionName, ".", "_")
+					tmuxName := tmuxPrefix() + strings.ReplaceAll(sessionName, ".", "_")
 					if tmuxSessionExists(tmuxName) {
 						killTmuxSession(tmuxName)
 						time.Sleep(300 * time.Millisecond)
@@ -1019,12 +2213,7 @@ func listen() error {
 					if err := createTmuxSession(tmuxName, workDir, false); err != nil {
 						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to start: %v", err))
 					} else {
-						time.Sleep(500 * time.Millisecond)
-						if tmuxSessionExists(tmuxName) {
-							sendMessage(config, chatID, threadID, fmt.Sprintf("🚀 Session '%s' restarted", sessionName))
-						} else {
-							sendMessage(config, chatID, threadID, "⚠️ Session died immediately")
-						}
+						go reportSessionStart(config, chatID, threadID, tmuxName, fmt.Sprintf("🚀 Session '%s' restarted", sessionName))
 					}
 				} else {
 					sendMessage(config, chatID, threadID, "Usage: /new <name> to create a new session")
@@ -1032,8 +2221,17 @@ func listen() error {
 				continue
 			}
 
+			// The group's General area forwards to the default session when one is set
+			if !strings.HasPrefix(text, "/") && isGroup && threadID == 0 {
+				config, _ = loadConfig()
+				if name := defaultSession(config); name != "" {
+					forwardFromGeneral(config, chatID, name, text)
+					continue
+				}
+			}
+
 			// Route through LLM for non-topic group messages and private chat
-			if !strings.HasPrefix(text, "/") && config.OpenRouterKey != "" {
+			if !strings.HasPrefix(text, "/") && routerEnabled(config) {
 				// For group messages not in a topic, always route
 				// For private chat, route to enable natural language session management
 				if (isGroup && threadID == 0) || !isGroup {
@@ -1048,36 +2246,21 @@ func listen() error {
 			if isGroup && threadID > 0 {
 				// Reload config to get latest sessions
 				config, _ = loadConfig()
-				sessName := getSessionByTopic(config, threadID)
+				sessName := getSessionByChatTopic(config, chatID, threadID)
 				if sessName != "" {
-					// Send to tmux session
-					tmuxName := sessionName(sessName)
-					if !tmuxSessionExists(tmuxName) {
-						// Auto-start session if not running
-						sessionInfo := config.Sessions[sessName]
-						workDir := sessionInfo.Path
-						if _, err := os.Stat(workDir); os.IsNotExist(err) {
-							os.MkdirAll(workDir, 0755)
-						}
-						if err := createTmuxSession(tmuxName, workDir, false); err != nil {
-							sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to start session: %v", err))
-							continue
-						}
-						sendMessage(config, chatID, threadID, fmt.Sprintf("🚀 Session '%s' auto-started", sessName))
-						time.Sleep(3 * time.Second) // Wait for Claude to fully start
-					}
-					ResetSessionMonitor(sessName)
-					if err := sendToTmux(tmuxName, text); err != nil {
-						sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to send: %v", err))
+					// A reply to one of Claude's blocks carries that block as context
+					if msg.ReplyToMessage != nil {
+						text = withReplyContext(cachedBlockForMessage(sessName, int64(msg.ReplyToMessage.MessageID)), text)
 					}
+					forwardToSession(config, getMessenger(config), chatID, threadID, sessName, text)
 				} else {
 					sendMessage(config, chatID, threadID, "⚠️ No session linked to this topic. Use /new <name> to create one.")
 				}
 				continue
 			}
 
-			// Private chat: run one-shot Claude
-			if !isGroup {
+			// Private chat and the group's General topic: run one-shot Claude
+			if !isGroup || threadID == 0 {
 				sendMessage(config, chatID, threadID, "🤖 Running Claude...")
 
 				prompt := text
@@ -1094,27 +2277,42 @@ func listen() error {
 					prompt = fmt.Sprintf("Original message:\n%s\n\nReply:\n%s", origText, prompt)
 				}
 
-				go func(p string, cid int64) {
-					defer func() {
-						if r := recover(); r != nil {
-							sendMessage(config, cid, 0, fmt.Sprintf("💥 Panic: %v", r))
-						}
-					}()
-					output, err := runClaude(p)
-					if err != nil {
-						if strings.Contains(err.Error(), "context deadline exceeded") {
-							output = fmt.Sprintf("⏱️ Timeout (10min)\n\n%s", output)
-						} else {
-							output = fmt.Sprintf("⚠️ %s\n\nExit: %v", output, err)
-						}
-					}
-					sendMessage(config, cid, 0, output)
-				}(prompt, chatID)
+				go runOneShotClaude(config, chatID, threadID, prompt)
 			}
 		}
 	}
 }
 
+const defaultCatchupBlocks = 5
+
+// buildCatchup formats an onboarding recap for a session: its current state
+// followed by the last n blocks from the persisted block cache.
+func buildCatchup(sessName string, n int) string {
+	var sb strings.Builder
+	state := sessionState(sessionName(sessName))
+	switch state {
+	case "working":
+		sb.WriteString(fmt.Sprintf("📋 %s — ⏳ working\n", sessName))
+	case "idle":
+		sb.WriteString(fmt.Sprintf("📋 %s — 💤 idle (waiting for input)\n", sessName))
+	default:
+		sb.WriteString(fmt.Sprintf("📋 %s — ⏹ stopped\n", sessName))
+	}
+
+	blocks := recentCachedBlocks(sessName, n)
+	if len(blocks) == 0 {
+		sb.WriteString("\nNo recent activity recorded.")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("\nLast %d message(s):\n", len(blocks)))
+	for _, block := range blocks {
+		sb.WriteString("\n───\n")
+		sb.WriteString(block)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
 func printHelp() {
 	fmt.Printf(`ccc - Claude Code Companion v%s
 
@@ -1124,45 +2322,142 @@ With LLM router: send natural language commands from Telegram.
 USAGE:
     ccc                     Start/attach tmux session in current directory
     ccc -c                  Continue previous session
-    ccc <message>           Send notification (if away mode is on)
+    ccc <message>           Send notification (if away)
+    away [on|off|auto]      Show or set away mode
+    away schedule <spec>    Away by the clock, e.g. "18:00-09:00 weekends"
+    away idle <hours>       Away after hours without terminal activity
 
 COMMANDS:
     setup <token>           Complete setup (bot, hook, service - all in one!)
     doctor                  Check all dependencies and configuration
+    health                  Exit non-zero unless the listener is running
+    gc [--remove]           Reconcile sessions with tmux and Telegram topics
     config                  Show/set configuration values
     config openrouter-key <key>  Set OpenRouter API key for LLM routing
+    config router-endpoint <url> Route with a local OpenAI-compatible server (Ollama)
+    config router-model <model>  Model used for routing
     config projects-dir <path>   Set base directory for projects
+    config secrets <backend>     Keep tokens in plain config, keychain or encrypted
     config oauth-token <token>   Set OAuth token
-    setgroup                Configure Telegram group for topics
+    config idle-notify <min>     Notify when all sessions idle (0 = off)
+    config command-jail <dir>    Restrict /c commands to a directory
+    config block-send-delay-ms <ms>  Delay between forwarded blocks
+    config max-sessions <n>      Headless prompts wait while n sessions run
+    config quote-prompt <on|off>  Quote the prompt in completion messages
+    setgroup [--name <n>]   Configure Telegram group for topics, or register
+                            another group sessions can be created in
     listen                  Start the Telegram bot listener
     install                 Install Claude hook
-    send <file>             Send file to session's Telegram topic
-    relay [port]            Start relay server for large files
+    headless <name> [on|off]
+                            Run a session as claude -p per message (off:
+                            back to tmux)
+    start [flags] <name> <dir> <prompt>
+                            Detached session: --continue, --headless,
+                            --timeout <dur>, --notify-on-complete, --from-stdin
+    batch <file.yaml>       Run each session's prompts in order, reporting to a
+                            topic; exits non-zero if a step fails
+    search <query>          Search transcripts and session directories
+    ls [--json]             List sessions with topic, state, last activity
+    logs [-f] [-n N] [--level L]  Show the log (-f follows, L = debug|info|warn|error)
+                            and Claude session ID
+    attach [name]           Attach to a session from any directory (lists
+                            sessions without a name)
+    completion <shell>      Print bash, zsh or fish completion
+    send [--encrypt|--store] <file|dir>...
+                            Send files to session's Telegram topic (dirs zipped,
+                            several on one page, --encrypt end to end via relay,
+                            --store via S3/R2 without staying attached)
+    receive [--latest|--id <msg>|--relay|<link>]
+                            List files posted in the session's topic, or
+                            download one into the current directory;
+                            --relay posts an upload link for large files,
+                            <link> decrypts an --encrypt link locally
+    export <session>        Zip transcripts, block cache and a Markdown log
+                            into the current directory and send it to the topic
+    relay [--control] [port]
+                            Start relay server for large files (--control: also
+                            the web client for the relay messenger)
+    pair [--reset]          Save the relay web client, creating its key
+    github-listen [port]    Start sessions from issues labeled ccc (default port 8090)
+    web [port]              Local web dashboard (default port 8377)
+    cost                    Show token usage and estimated cost per session
+    rpc <method> [params]   Call the listener's control socket
 
 TELEGRAM COMMANDS:
     /new <name>             Create new session with topic
     /new                    Restart session in current topic
-    /list                   List all sessions with status
+    /new <name> --worktree <repo> [branch]
+                            New session in a git worktree of repo
+    /list                   List sessions with status and Restart/Kill/Peek buttons
+    /json <cmd>             Run status/sessions/peek and reply with JSON
     /continue               Restart session keeping history
+    /restart-claude         Restart only Claude, keeping the tmux session
+    /catchup [n]            Recap last n messages and current status
+    /cost                   Token usage and estimated cost (today / all time)
+    /digest [now]           Daily activity digest (now: send it now)
+    /search <query>         Search transcripts and session directories
+    /default [name|off]     Session that messages in the group's General area go to
+    /machines               Machines sharing the bot and whether they're reachable
+    /autocommit [on|off]    Git checkpoint commit after each completed turn
+    /autocommit push <remote>[/<branch>]|off
+                            Push each checkpoint commit
+    /gate [command|off]     Run tests after each turn; failures go back to Claude
+    /merge                  Merge a worktree session's branch back
+    /mode [tmux|headless]   Interactive tmux session or claude -p per message
+    /verbose [on|off]       One message per block, or coalesce them (off)
+    /plan [on|off]          Plan mode: Claude plans, you approve
+    /checkpoint [label]     Save the work tree and conversation
+    /rewind [label]         Go back to a checkpoint (lists them without one)
+    /model [name|default]   Model Claude runs with in this session
+    /flags [flags|off]      Extra claude flags for this session
+    /handoff <session> [instructions]
+                            Send this session's last answer to another session
+    /pr [title]             Push the branch and open a GitHub pull request
+    /issues <n>             Send GitHub issue n to Claude
+    /git status|diff|log|push|pull
+                            Run git in the session's directory
+    /export                 Zip of transcripts, block cache and a Markdown log
+    /tail [lines]           Raw output of the session's tmux pane
+    /tail on|off            Keep a pinned message updated with the pane
+    /schedule <cron> <prompt>
+                            Fire a prompt into this session on a schedule
+    /schedules              List scheduled prompts
+    /unschedule <id>        Remove a scheduled prompt
     /delete                 Delete current session and thread
     /cleanup                Delete ALL sessions and threads
-    /c <cmd>                Execute shell command
+    /gc [remove]            Report (and remove) orphaned tmux sessions and topics
+    /c <cmd>                Execute shell command (long output streams live)
+    /stop                   Stop the running /c command, or Claude's
+                            current turn in a session topic
     /stats                  Show system stats
+    /away [on|off|auto]     Show or set away mode
+    /away schedule <spec>   Away by the clock, e.g. 18:00-09:00 weekends
+    /away idle <hours>      Away after hours without terminal activity
+    /help                   List the commands that work in this chat or topic
     /update                 Update ccc binary from GitHub
     /restart                Restart ccc service
     /auth                   Re-authenticate Claude OAuth
+    /cancel                 Abort an in-progress /auth
 
-NATURAL LANGUAGE (when OpenRouter key is configured):
+NATURAL LANGUAGE (when an OpenRouter key or router endpoint is configured):
     "start a new session to research X"    Creates session + sends prompt
     "what's the status"                    Shows all sessions
     "check on the research session"        Peeks at session output
     "stop the quantum session"             Kills session
     "switch to my-project"                 Shows topic link
-    (anything else)                        Forwarded to active session
+    "tell it to run the tests"             Forwarded to active session
+    "tell all sessions to commit"          Sent to every running session
+    "did the deploy session finish?"       Answered from recent session output
+    (anything else)                        One-shot Claude query
 
 FLAGS:
     -h, --help              Show this help
     -v, --version           Show version
+    --config <path>         Use another config file (also $CCC_CONFIG)
+    --profile <name>        Use a named profile: its own config, sessions, lock and service
+                            (also $CCC_PROFILE)
+    --<key> <value>         Override a config key, e.g. --group-id -100123
+                            (also $CCC_<KEY>, e.g. CCC_GROUP_ID; flags > env > file)
 
 For more info: https://github.com/rsh3khar/ccc
 `, version)
@@ -1171,8 +2466,9 @@ For more info: https://github.com/rsh3khar/ccc
 const authTmuxSession = "claude-auth"
 
 func handleAuth(config *Config, chatID, threadID int64) {
-	if !authInProgress.TryLock() {
-		sendMessage(config, chatID, threadID, "⚠️ Auth already in progress")
+	gen, ok := beginAuth(chatID, threadID)
+	if !ok {
+		sendMessage(config, chatID, threadID, "⚠️ Auth already in progress (send /cancel to abort)")
 		return
 	}
 
@@ -1184,7 +2480,7 @@ func handleAuth(config *Config, chatID, threadID int64) {
 	home, _ := os.UserHomeDir()
 	if err := exec.Command(tmuxPath, "new-session", "-d", "-s", authTmuxSession, "-c", home).Run(); err != nil {
 		sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to create tmux session: %v", err))
-		authInProgress.Unlock()
+		endAuth(gen)
 		return
 	}
 
@@ -1194,6 +2490,9 @@ func handleAuth(config *Config, chatID, threadID int64) {
 	var oauthURL string
 	for i := 0; i < 30; i++ {
 		time.Sleep(500 * time.Millisecond)
+		if !authActive(gen) {
+			return // cancelled
+		}
 		out, err := exec.Command(tmuxPath, "capture-pane", "-t", authTmuxSession, "-p", "-S", "-30").Output()
 		if err != nil {
 			continue
@@ -1203,7 +2502,7 @@ func handleAuth(config *Config, chatID, threadID int64) {
 		if strings.Contains(pane, "Dark mode") || strings.Contains(pane, "❯") || strings.Contains(pane, "Welcome back") {
 			sendMessage(config, chatID, threadID, "✅ Claude is already authenticated!")
 			killTmuxSession(authTmuxSession)
-			authInProgress.Unlock()
+			endAuth(gen)
 			return
 		}
 
@@ -1228,16 +2527,15 @@ func handleAuth(config *Config, chatID, threadID int64) {
 	if oauthURL == "" {
 		sendMessage(config, chatID, threadID, "❌ Could not find OAuth URL. Try again.")
 		killTmuxSession(authTmuxSession)
-		authInProgress.Unlock()
+		endAuth(gen)
 		return
 	}
 
-	authWaitingCode = true
-	sendMessage(config, chatID, threadID, fmt.Sprintf("🔗 Open this URL and authorize:\n\n%s\n\nThen paste the code here.", oauthURL))
+	waitForAuthCode(config, gen)
+	sendMessage(config, chatID, threadID, fmt.Sprintf("🔗 Open this URL and authorize:\n\n%s\n\nThen paste the code here (or /cancel). Expires in %d minutes.", oauthURL, int(authCodeTimeout.Minutes())))
 }
 
-func handleAuthCode(config *Config, chatID, threadID int64, code string) {
-	authWaitingCode = false
+func handleAuthCode(config *Config, chatID, threadID int64, code string, gen int) {
 	code = strings.TrimSpace(code)
 
 	sendMessage(config, chatID, threadID, "🔄 Sending code to Claude...")
@@ -1248,6 +2546,9 @@ func handleAuthCode(config *Config, chatID, threadID int64, code string) {
 
 	for i := 0; i < 10; i++ {
 		time.Sleep(2 * time.Second)
+		if !authActive(gen) {
+			return // cancelled or timed out
+		}
 		out, _ := exec.Command(tmuxPath, "capture-pane", "-t", authTmuxSession, "-p").Output()
 		pane := string(out)
 
@@ -1266,11 +2567,14 @@ func handleAuthCode(config *Config, chatID, threadID int64, code string) {
 		if strings.Contains(pane, "❯") {
 			sendMessage(config, chatID, threadID, "✅ Auth successful! Claude is ready.")
 			killTmuxSession(authTmuxSession)
-			authInProgress.Unlock()
+			endAuth(gen)
 			return
 		}
 	}
 
+	if !authActive(gen) {
+		return
+	}
 	out, _ := exec.Command(tmuxPath, "capture-pane", "-t", authTmuxSession, "-p").Output()
 	pane := string(out)
 	if strings.Contains(pane, "Login successful") || strings.Contains(pane, "❯") {
@@ -1280,5 +2584,5 @@ func handleAuthCode(config *Config, chatID, threadID int64, code string) {
 	}
 
 	killTmuxSession(authTmuxSession)
-	authInProgress.Unlock()
+	endAuth(gen)
 }
@@ -7,13 +7,26 @@ import (
 	"strings"
 )
 
+// getConfigPath returns ~/.config/ccc/config.json (config-<profile>.json with
+// a profile), moving an old ~/.ccc.json there, or the file named by --config /
+// $CCC_CONFIG
 func getConfigPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".ccc.json")
+	if path := os.Getenv(configPathEnv); path != "" {
+		if strings.HasPrefix(path, "~/") {
+			home, _ := os.UserHomeDir()
+			return filepath.Join(home, path[2:])
+		}
+		return path
+	}
+	return migrateLegacyFile(legacyFile(".json"), configFile())
 }
 
 func loadConfig() (*Config, error) {
 	data, err := os.ReadFile(getConfigPath())
+	if os.IsNotExist(err) && hasConfigOverrides() {
+		// Configured entirely from CCC_* variables (containers, CI)
+		data, err = []byte("{}"), nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -86,8 +99,6 @@ func loadConfig() (*Config, error) {
 				Path:    sessionPath,
 			}
 		}
-		// Save migrated config
-		saveConfig(&config)
 	} else {
 		// Parse with new format
 		if err := json.Unmarshal(data, &config); err != nil {
@@ -95,19 +106,54 @@ func loadConfig() (*Config, error) {
 		}
 	}
 
-	if config.Sessions == nil {
-		config.Sessions = make(map[string]*SessionInfo)
+	if err := loadSecrets(&config); err != nil {
+		return nil, err
+	}
+	if err := applyConfigOverrides(&config); err != nil {
+		return nil, err
+	}
+
+	// Sessions used to live in this file; move them into the store
+	if len(config.Sessions) > 0 {
+		if err := migrateSessions(config.Sessions); err != nil {
+			return nil, err
+		}
+		if err := saveConfig(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	sessions, err := loadSessions()
+	if err != nil {
+		return nil, err
 	}
+	config.Sessions = sessions
 
 	return &config, nil
 }
 
+// saveConfig writes settings and credentials, the latter through the secrets
+// backend when one is configured. Sessions are not written here;
+// use saveSession / deleteSession so concurrent changes don't overwrite each other.
 func saveConfig(config *Config) error {
-	data, err := json.MarshalIndent(config, "", "  ")
+	settings := *config
+	settings.Sessions = nil
+	withoutOverrides(&settings)
+	if err := storeSecrets(&settings); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(&settings, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(getConfigPath(), data, 0600)
+
+	// Write to a temp file and rename so readers never see a partial config
+	path := getConfigPath()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
 }
 
 // getProjectsDir returns the base directory for projects
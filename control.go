@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The listener's control socket speaks JSON-RPC 2.0, one request and one
+// response per line. Methods:
+//
+//	sessions                         list sessions with their state
+//	send {session, text}             type a prompt (queued if Claude is busy)
+//	peek {session, lines}            last output blocks, plus raw pane lines if asked
+//	restart                          restart the listener
+//	hook.event {event, cwd, ...}     PostToolUse/Stop output for the hooks monitor mode
+//	permission.wait {request_id, timeout}
+//	                                 block until a permission button is pressed
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+// errControlUnavailable means no listener is accepting control connections
+var errControlUnavailable = errors.New("ccc listen is not running")
+
+type controlRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type controlError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *controlError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+type controlResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *controlError   `json:"error,omitempty"`
+}
+
+// controlSocketPath is the unix socket `ccc listen` serves the control API on
+func controlSocketPath() string {
+	return stateFile(".sock")
+}
+
+// startControlServer serves the control API until the listener exits
+func startControlServer() {
+	path := controlSocketPath()
+	// A socket left by a previous listener blocks Listen; the listener lock
+	// guarantees no other instance still owns it
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		hookLog("control: listen %s failed: %v", path, err)
+		return
+	}
+	os.Chmod(path, 0600)
+	hookLog("control: listening on %s", path)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			hookLog("control: accept failed: %v", err)
+			return
+		}
+		go serveControlConn(conn)
+	}
+}
+
+func serveControlConn(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req controlRequest
+		resp := controlResponse{JSONRPC: "2.0"}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = &controlError{rpcParseError, "parse error"}
+		} else {
+			resp.ID = req.ID
+			resp.Result, resp.Error = handleControlRequest(req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// handleControlRequest runs one control method
+func handleControlRequest(req controlRequest) (interface{}, *controlError) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, &controlError{rpcServerError, err.Error()}
+	}
+	decode := func(v interface{}) *controlError {
+		if len(req.Params) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(req.Params, v); err != nil {
+			return &controlError{rpcInvalidParams, err.Error()}
+		}
+		return nil
+	}
+
+	switch req.Method {
+	case "sessions":
+		return collectSessionStatuses(config), nil
+
+	case "send":
+		var p struct {
+			Session string `json:"session"`
+			Text    string `json:"text"`
+		}
+		if cerr := decode(&p); cerr != nil {
+			return nil, cerr
+		}
+		info := config.Sessions[p.Session]
+		if info == nil || p.Text == "" {
+			return nil, &controlError{rpcInvalidParams, "unknown session or empty text"}
+		}
+		forwardToSession(config, getMessenger(config), sessionGroup(config, info), info.TopicID, p.Session, p.Text)
+		return map[string]int{"pending": pendingCount(p.Session)}, nil
+
+	case "peek":
+		var p struct {
+			Session string `json:"session"`
+			Lines   int    `json:"lines"`
+		}
+		if cerr := decode(&p); cerr != nil {
+			return nil, cerr
+		}
+		if config.Sessions[p.Session] == nil {
+			return nil, &controlError{rpcInvalidParams, "unknown session"}
+		}
+		return peekSession(p.Session, p.Lines), nil
+
+	case "restart":
+		go restartListener()
+		return map[string]bool{"restarting": true}, nil
+
+	case "hook.event":
+		var ev hookEvent
+		if cerr := decode(&ev); cerr != nil {
+			return nil, cerr
+		}
+		if configuredMonitorMode(config) == monitorModeHooks {
+			dispatchHookEvent(config, ev)
+		}
+		return map[string]bool{"ok": true}, nil
+
+	case "permission.wait":
+		var p struct {
+			RequestID string `json:"request_id"`
+			Timeout   int    `json:"timeout"` // seconds
+		}
+		if cerr := decode(&p); cerr != nil {
+			return nil, cerr
+		}
+		if !validPermissionRequestID(p.RequestID) || p.Timeout <= 0 {
+			return nil, &controlError{rpcInvalidParams, "request_id (8 hex digits) and timeout are required"}
+		}
+		return map[string]string{"decision": waitPermissionDecision(p.RequestID, time.Duration(p.Timeout)*time.Second)}, nil
+	}
+	return nil, &controlError{rpcMethodNotFound, "method not found: " + req.Method}
+}
+
+// sessionPeek is the result of the peek method
+type sessionPeek struct {
+	Name   string   `json:"name"`
+	State  string   `json:"state"`
+	Blocks []string `json:"blocks"`
+	Pane   string   `json:"pane,omitempty"`
+}
+
+// peekSession returns a session's last two output blocks and, when lines > 0,
+// that many raw lines of its pane
+func peekSession(name string, lines int) sessionPeek {
+	tmuxName := sessionName(name)
+	peek := sessionPeek{Name: name, State: sessionState(tmuxName), Blocks: []string{}}
+	if peek.State == "stopped" {
+		return peek
+	}
+	blocks := getLastBlocksFromTmux(tmuxName)
+	if len(blocks) > 2 {
+		blocks = blocks[len(blocks)-2:]
+	}
+	peek.Blocks = append(peek.Blocks, blocks...)
+	if lines > 0 {
+		if lines > maxTailLines {
+			lines = maxTailLines
+		}
+		peek.Pane, _ = capturePaneTail(tmuxName, lines)
+	}
+	return peek
+}
+
+// restartListener re-execs `ccc listen` and exits this one
+func restartListener() {
+	time.Sleep(500 * time.Millisecond)
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	exec.Command(exe, "listen").Start()
+	os.Exit(0)
+}
+
+var (
+	// permissionWaiters holds the decision channel of each permission request
+	// a hook is waiting on over the control socket
+	permissionWaiters   = make(map[string]chan string)
+	permissionWaitersMu sync.Mutex
+)
+
+// waitPermissionDecision blocks until the request's button is pressed or the
+// timeout passes, returning "" on timeout. A press that arrived before the
+// hook started waiting is picked up from the decision file.
+func waitPermissionDecision(requestID string, timeout time.Duration) string {
+	ch := make(chan string, 1)
+	permissionWaitersMu.Lock()
+	permissionWaiters[requestID] = ch
+	permissionWaitersMu.Unlock()
+	defer func() {
+		permissionWaitersMu.Lock()
+		delete(permissionWaiters, requestID)
+		permissionWaitersMu.Unlock()
+	}()
+
+	if data, err := os.ReadFile(permissionDecisionPath(requestID)); err == nil {
+		os.Remove(permissionDecisionPath(requestID))
+		return strings.TrimSpace(string(data))
+	}
+	select {
+	case decision := <-ch:
+		return decision
+	case <-time.After(timeout):
+		return ""
+	}
+}
+
+// deliverPermissionDecision hands a button press to the hook waiting on the
+// control socket, or leaves it in the decision file for a hook polling it
+func deliverPermissionDecision(requestID, decision string) error {
+	permissionWaitersMu.Lock()
+	ch, waiting := permissionWaiters[requestID]
+	permissionWaitersMu.Unlock()
+	if waiting {
+		select {
+		case ch <- decision:
+		default:
+			// Already decided by an earlier press
+		}
+		return nil
+	}
+	return os.WriteFile(permissionDecisionPath(requestID), []byte(decision), 0600)
+}
+
+// callControl makes one control API call. It returns errControlUnavailable
+// when the listener isn't running; timeout bounds the whole call.
+func callControl(method string, params interface{}, result interface{}, timeout time.Duration) error {
+	conn, err := net.DialTimeout("unix", controlSocketPath(), time.Second)
+	if err != nil {
+		return errControlUnavailable
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *controlError   `json:"error"`
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil && len(resp.Result) > 0 {
+		return json.Unmarshal(resp.Result, result)
+	}
+	return nil
+}
+
+// handleRPCCommand is `ccc rpc <method> [params-json]`: one control call with
+// the result printed as JSON
+func handleRPCCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: ccc rpc <sessions|send|peek|restart> [params-json]")
+	}
+	var params interface{}
+	if len(args) > 1 {
+		params = json.RawMessage(args[1])
+		if !json.Valid(params.(json.RawMessage)) {
+			return fmt.Errorf("params must be a JSON object")
+		}
+	}
+	var result json.RawMessage
+	if err := callControl(args[0], params, &result, 30*time.Second); err != nil {
+		return err
+	}
+	fmt.Println(string(result))
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestControlSocket(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	if err := callControl("sessions", nil, nil, time.Second); err != errControlUnavailable {
+		t.Fatalf("callControl without listener = %v, want errControlUnavailable", err)
+	}
+
+	data := []byte(`{"bot_token": "test", "chat_id": 123, "sessions": {"proj": {"topic_id": 100, "path": "/nonexistent/proj"}}}`)
+	if err := os.WriteFile(filepath.Join(tmpDir, ".ccc.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	go startControlServer()
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(controlSocketPath()); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var sessions []SessionStatus
+	if err := callControl("sessions", nil, &sessions, 5*time.Second); err != nil {
+		t.Fatalf("sessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Name != "proj" || sessions[0].TopicID != 100 {
+		t.Errorf("sessions = %+v, want proj on topic 100", sessions)
+	}
+
+	err := callControl("bogus", nil, nil, time.Second)
+	if cerr, ok := err.(*controlError); !ok || cerr.Code != rpcMethodNotFound {
+		t.Errorf("bogus method error = %v, want method not found", err)
+	}
+	err = callControl("send", map[string]string{"session": "missing", "text": "hi"}, nil, time.Second)
+	if cerr, ok := err.(*controlError); !ok || cerr.Code != rpcInvalidParams {
+		t.Errorf("send to missing session error = %v, want invalid params", err)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		deliverPermissionDecision("c0de1234", "deny")
+	}()
+	var result struct {
+		Decision string `json:"decision"`
+	}
+	params := map[string]interface{}{"request_id": "c0de1234", "timeout": 5}
+	if err := callControl("permission.wait", params, &result, 10*time.Second); err != nil {
+		t.Fatalf("permission.wait: %v", err)
+	}
+	if result.Decision != "deny" {
+		t.Errorf("decision = %q, want deny", result.Decision)
+	}
+}
+
+func TestWaitPermissionDecisionEarlyPress(t *testing.T) {
+	// A press delivered before anyone waits lands in the decision file
+	if err := deliverPermissionDecision("early123", "allow"); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitPermissionDecision("early123", time.Second); got != "allow" {
+		t.Errorf("waitPermissionDecision = %q, want allow", got)
+	}
+	if got := waitPermissionDecision("never123", 50*time.Millisecond); got != "" {
+		t.Errorf("waitPermissionDecision on timeout = %q, want empty", got)
+	}
+}
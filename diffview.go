@@ -0,0 +1,390 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxDiffLines and maxDiffChars cap the diff shown for one Edit/Write;
+	// beyond them the message offers the full file instead
+	maxDiffLines = 60
+	maxDiffChars = 3000
+	// maxDiffCells bounds the LCS table; larger edits are shown as a plain
+	// removal followed by an addition
+	maxDiffCells = 1000000
+)
+
+// fullFileCallbackPrefix marks "Show full file" buttons. Callback data is
+// "file:<id>".
+const fullFileCallbackPrefix = "file:"
+
+// fullFileTimeout is how long a "Show full file" button stays valid
+const fullFileTimeout = 24 * time.Hour
+
+var (
+	// diffHeader matches the first line of an edit block in the pane:
+	// "Update(main.go)", "Edit(main.go)" or "Write(notes.md)"
+	diffHeader = regexp.MustCompile(`^(Update|Edit|MultiEdit|Write|Create)\((.+)\)\s*$`)
+	// diffNumbered matches a numbered diff line: "11 -    a := 1", "12 +  b",
+	// "10    func main() {" or a bare "13" for an empty context line
+	diffNumbered = regexp.MustCompile(`^\d+(?: ([-+ ])(.*))?$`)
+	// writeNumbered matches a numbered line of a written file: "3 import "fmt""
+	writeNumbered = regexp.MustCompile(`^\d+(?: (.*))?$`)
+	// diffElided matches Claude's "… +39 lines (ctrl+r to expand)"
+	diffElided = regexp.MustCompile(`^…\s*\+\d+ lines?`)
+	// diffAdditions, diffRemovals and diffWrote pick the counts out of "Updated main.go with 2 additions and 1 removal"
+	// or "Wrote 42 lines to notes.md"
+	diffAdditions = regexp.MustCompile(`(\d+) additions?`)
+	diffRemovals  = regexp.MustCompile(`(\d+) removals?`)
+	diffWrote     = regexp.MustCompile(`^Wrote (\d+) lines?`)
+)
+
+// fileDiff is an Edit/Write tool call rendered as a unified diff
+type fileDiff struct {
+	Tool      string   `json:"tool"`
+	Path      string   `json:"path"`
+	Lines     []string `json:"lines"` // each starts with '+', '-' or ' '
+	Added     int      `json:"added"`
+	Removed   int      `json:"removed"`
+	Truncated bool     `json:"truncated,omitempty"` // Lines is not the whole change
+}
+
+// isDiffTool reports whether a tool call changes a file
+func isDiffTool(tool string) bool {
+	return tool == "Edit" || tool == "MultiEdit" || tool == "Write"
+}
+
+// lineDiff returns the lines of a unified diff turning old into new
+func lineDiff(old, new []string) []string {
+	if len(old)*len(new) > maxDiffCells {
+		var out []string
+		for _, l := range old {
+			out = append(out, "-"+l)
+		}
+		for _, l := range new {
+			out = append(out, "+"+l)
+		}
+		return out
+	}
+
+	// lcs[i][j] is the longest common subsequence of old[i:] and new[j:]
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			out = append(out, " "+old[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "-"+old[i])
+			i++
+		default:
+			out = append(out, "+"+new[j])
+			j++
+		}
+	}
+	for ; i < len(old); i++ {
+		out = append(out, "-"+old[i])
+	}
+	for ; j < len(new); j++ {
+		out = append(out, "+"+new[j])
+	}
+	return out
+}
+
+// countDiff counts the added and removed lines of a diff
+func countDiff(lines []string) (added, removed int) {
+	for _, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "+"):
+			added++
+		case strings.HasPrefix(l, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// splitLines splits file content into lines, ignoring a final newline
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffFromHook builds the diff of an Edit, MultiEdit or Write call from its
+// PostToolUse payload
+func diffFromHook(tool string, rawData []byte) (*fileDiff, bool) {
+	var data struct {
+		ToolInput struct {
+			FilePath  string `json:"file_path"`
+			OldString string `json:"old_string"`
+			NewString string `json:"new_string"`
+			Content   string `json:"content"`
+			Edits     []struct {
+				OldString string `json:"old_string"`
+				NewString string `json:"new_string"`
+			} `json:"edits"`
+		} `json:"tool_input"`
+	}
+	if !isDiffTool(tool) || json.Unmarshal(rawData, &data) != nil || data.ToolInput.FilePath == "" {
+		return nil, false
+	}
+	in := data.ToolInput
+	d := &fileDiff{Tool: tool, Path: in.FilePath}
+	switch tool {
+	case "Write":
+		for _, l := range splitLines(in.Content) {
+			d.Lines = append(d.Lines, "+"+l)
+		}
+	case "Edit":
+		d.Lines = lineDiff(splitLines(in.OldString), splitLines(in.NewString))
+	case "MultiEdit":
+		for i, e := range in.Edits {
+			if i > 0 {
+				d.Lines = append(d.Lines, "@@")
+			}
+			d.Lines = append(d.Lines, lineDiff(splitLines(e.OldString), splitLines(e.NewString))...)
+		}
+	}
+	d.Added, d.Removed = countDiff(d.Lines)
+	return d, true
+}
+
+// parseDiffBlock recognizes a pane block showing an edit, such as
+//
+//	Update(main.go)
+//	⎿  Updated main.go with 1 addition and 1 removal
+//	10  func main() {
+//	11 -  a := 1
+//	11 +  a := 2
+//
+// and returns it as a diff. Claude elides long output with "… +N lines",
+// which marks the diff truncated.
+func parseDiffBlock(block string) (*fileDiff, bool) {
+	lines := strings.Split(block, "\n")
+	m := diffHeader.FindStringSubmatch(strings.TrimSpace(lines[0]))
+	if m == nil {
+		return nil, false
+	}
+	d := &fileDiff{Tool: "Edit", Path: m[2]}
+	write := m[1] == "Write" || m[1] == "Create"
+	if write {
+		d.Tool = "Write"
+	}
+
+	added, removed := -1, -1
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "⎿"))
+		if line == "" {
+			continue
+		}
+		if diffElided.MatchString(line) {
+			d.Truncated = true
+			continue
+		}
+		if write {
+			if m := diffWrote.FindStringSubmatch(line); m != nil {
+				added, _ = strconv.Atoi(m[1])
+				continue
+			}
+			if m := writeNumbered.FindStringSubmatch(line); m != nil {
+				d.Lines = append(d.Lines, "+"+m[1])
+			}
+			continue
+		}
+		if m := diffNumbered.FindStringSubmatch(line); m != nil {
+			if m[1] == "" {
+				m[1] = " "
+			}
+			d.Lines = append(d.Lines, m[1]+m[2])
+			continue
+		}
+		if m := diffAdditions.FindStringSubmatch(line); m != nil {
+			added, _ = strconv.Atoi(m[1])
+		}
+		if m := diffRemovals.FindStringSubmatch(line); m != nil {
+			removed, _ = strconv.Atoi(m[1])
+		}
+	}
+	if len(d.Lines) == 0 {
+		return nil, false
+	}
+
+	d.Added, d.Removed = countDiff(d.Lines)
+	// Claude's summary line counts the whole change, not just what is shown
+	if added >= 0 {
+		d.Added = added
+	}
+	if removed >= 0 {
+		d.Removed = removed
+	}
+	return d, true
+}
+
+// formatDiff renders a diff as a header with the ±line counts and the diff
+// in a code fence, cut at maxDiffLines/maxDiffChars. It reports whether the
+// message shows less than the whole change.
+func formatDiff(d *fileDiff) (string, bool) {
+	var sb strings.Builder
+	if d.Tool == "Write" {
+		fmt.Fprintf(&sb, "📝 Write %s (+%d)", d.Path, d.Added)
+	} else {
+		fmt.Fprintf(&sb, "✏️ Edit %s (+%d −%d)", d.Path, d.Added, d.Removed)
+	}
+
+	truncated := d.Truncated
+	shown := 0
+	var body strings.Builder
+	for _, l := range d.Lines {
+		if shown == maxDiffLines || body.Len()+len(l) > maxDiffChars {
+			truncated = true
+			break
+		}
+		// A fence inside the diff would end the code block early
+		body.WriteString(strings.ReplaceAll(l, "```", "'''") + "\n")
+		shown++
+	}
+	if body.Len() > 0 {
+		sb.WriteString("\n```diff\n" + body.String() + "```")
+	}
+	if rest := len(d.Lines) - shown; rest > 0 {
+		fmt.Fprintf(&sb, "\n… %d more lines", rest)
+	}
+	return sb.String(), truncated
+}
+
+// renderDiffBlock renders a pane edit block as a diff, or returns ok=false
+// for any other block
+func renderDiffBlock(block string) (string, bool) {
+	d, ok := parseDiffBlock(block)
+	if !ok {
+		return "", false
+	}
+	text, _ := formatDiff(d)
+	return text, true
+}
+
+// fullFile is a file behind a "Show full file" button
+type fullFile struct {
+	ChatID, ThreadID int64
+	Path             string
+	Created          time.Time
+}
+
+var (
+	fullFiles   = make(map[string]*fullFile)
+	fullFilesMu sync.Mutex
+	// fullFileOffered remembers the pane blocks already given a button, as
+	// session + block hash, since a block is synced many times
+	fullFileOffered = make(map[string]time.Time)
+)
+
+// offerFullFile follows a truncated diff with a "Show full file" button.
+// Relative paths (as the pane shows them) are resolved against dir. Only
+// Telegram answers the button, so other messengers get nothing.
+func offerFullFile(config *Config, chatID, topicID int64, dir string, d *fileDiff) {
+	if configuredMessenger(config) != messengerTelegram {
+		return
+	}
+	path := d.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+		return
+	}
+
+	idBytes := make([]byte, 4)
+	rand.Read(idBytes)
+	id := hex.EncodeToString(idBytes)
+
+	fullFilesMu.Lock()
+	now := time.Now()
+	for k, f := range fullFiles {
+		if now.Sub(f.Created) > fullFileTimeout {
+			delete(fullFiles, k)
+		}
+	}
+	for k, t := range fullFileOffered {
+		if now.Sub(t) > fullFileTimeout {
+			delete(fullFileOffered, k)
+		}
+	}
+	fullFiles[id] = &fullFile{ChatID: chatID, ThreadID: topicID, Path: path, Created: now}
+	fullFilesMu.Unlock()
+
+	buttons := [][]InlineKeyboardButton{{
+		{Text: "📄 Show full file", CallbackData: fullFileCallbackPrefix + id},
+	}}
+	msg := fmt.Sprintf("Diff of %s truncated", filepath.Base(path))
+	if err := getMessenger(config).SendWithKeyboard(chatID, topicID, msg, buttons); err != nil {
+		hookLog("diffview: offering %s: %v", path, err)
+	}
+}
+
+// offerFullFileForBlock offers the full file once for a pane edit block
+// whose diff is truncated
+func offerFullFileForBlock(config *Config, sessName string, topicID int64, block string) {
+	d, ok := parseDiffBlock(block)
+	if !ok {
+		return
+	}
+	if _, truncated := formatDiff(d); !truncated {
+		return
+	}
+	key := sessName + "\x00" + blockHash(block)
+	fullFilesMu.Lock()
+	_, offered := fullFileOffered[key]
+	fullFileOffered[key] = time.Now()
+	fullFilesMu.Unlock()
+	if offered {
+		return
+	}
+	dir := ""
+	if info := config.Sessions[sessName]; info != nil {
+		dir = info.Path
+	}
+	offerFullFile(config, sessionChat(config, sessName), topicID, dir, d)
+}
+
+// takeFullFile resolves a "Show full file" button press. It returns the file
+// (nil if unknown or expired) and the label to show otherwise.
+func takeFullFile(data string) (*fullFile, string) {
+	id := strings.TrimPrefix(data, fullFileCallbackPrefix)
+	fullFilesMu.Lock()
+	f, ok := fullFiles[id]
+	fullFilesMu.Unlock()
+	if !ok || time.Since(f.Created) > fullFileTimeout {
+		return nil, "⌛ Expired"
+	}
+	return f, ""
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLineDiff(t *testing.T) {
+	got := lineDiff([]string{"a", "b", "c"}, []string{"a", "x", "c", "d"})
+	want := []string{" a", "-b", "+x", " c", "+d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lineDiff = %q, want %q", got, want)
+	}
+	if added, removed := countDiff(got); added != 2 || removed != 1 {
+		t.Errorf("countDiff = +%d -%d", added, removed)
+	}
+}
+
+func TestDiffFromHook(t *testing.T) {
+	edit := `{"tool_input":{"file_path":"/p/main.go","old_string":"a := 1\nb := 2","new_string":"a := 1\nb := 3"}}`
+	d, ok := diffFromHook("Edit", []byte(edit))
+	if !ok || d.Path != "/p/main.go" || d.Added != 1 || d.Removed != 1 {
+		t.Fatalf("Edit diff = %+v, %v", d, ok)
+	}
+
+	multi := `{"tool_input":{"file_path":"/p/a.go","edits":[{"old_string":"x","new_string":"y"},{"old_string":"z","new_string":""}]}}`
+	d, _ = diffFromHook("MultiEdit", []byte(multi))
+	if want := []string{"-x", "+y", "@@", "-z"}; !reflect.DeepEqual(d.Lines, want) {
+		t.Errorf("MultiEdit lines = %q, want %q", d.Lines, want)
+	}
+
+	write := `{"tool_input":{"file_path":"/p/new.md","content":"# Title\n\nbody\n"}}`
+	d, _ = diffFromHook("Write", []byte(write))
+	if d.Added != 3 || d.Removed != 0 {
+		t.Errorf("Write diff = %+v", d)
+	}
+
+	if _, ok := diffFromHook("Bash", []byte(`{"tool_input":{"command":"ls"}}`)); ok {
+		t.Error("Bash is not a diff tool")
+	}
+}
+
+func TestParseDiffBlock(t *testing.T) {
+	block := strings.Join([]string{
+		"Update(main.go)",
+		"⎿  Updated main.go with 2 additions and 1 removal",
+		"10  func main() {",
+		"11 -  a := 1",
+		"11 +  a := 2",
+		"12 +  b := 3",
+		"13",
+		"14  }",
+	}, "\n")
+	d, ok := parseDiffBlock(block)
+	if !ok {
+		t.Fatal("Update block not recognized")
+	}
+	want := []string{" func main() {", "-  a := 1", "+  a := 2", "+  b := 3", " ", " }"}
+	if d.Tool != "Edit" || d.Path != "main.go" || !reflect.DeepEqual(d.Lines, want) {
+		t.Errorf("diff = %+v", d)
+	}
+	if d.Added != 2 || d.Removed != 1 || d.Truncated {
+		t.Errorf("counts = +%d -%d truncated=%v", d.Added, d.Removed, d.Truncated)
+	}
+
+	write := "Write(notes.md)\n⎿  Wrote 42 lines to notes.md\n1 # Notes\n2\n3 todo\n… +39 lines (ctrl+r to expand)"
+	d, ok = parseDiffBlock(write)
+	if !ok || d.Tool != "Write" || d.Added != 42 || !d.Truncated {
+		t.Fatalf("Write diff = %+v, %v", d, ok)
+	}
+	if want := []string{"+# Notes", "+", "+todo"}; !reflect.DeepEqual(d.Lines, want) {
+		t.Errorf("Write lines = %q", d.Lines)
+	}
+
+	if _, ok := parseDiffBlock("Read(main.go)\n⎿  Read 20 lines"); ok {
+		t.Error("a Read block is not a diff")
+	}
+	if _, ok := parseDiffBlock("Update(main.go)\n⎿  Error: file not found"); ok {
+		t.Error("an Update block without diff lines should not be rendered as one")
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	text, truncated := formatDiff(&fileDiff{Tool: "Edit", Path: "main.go", Lines: []string{"-a", "+b"}, Added: 1, Removed: 1})
+	want := "✏️ Edit main.go (+1 −1)\n```diff\n-a\n+b\n```"
+	if text != want || truncated {
+		t.Errorf("formatDiff = %q, %v", text, truncated)
+	}
+
+	var lines []string
+	for i := 0; i < maxDiffLines+5; i++ {
+		lines = append(lines, fmt.Sprintf("+line %d", i))
+	}
+	text, truncated = formatDiff(&fileDiff{Tool: "Write", Path: "big.txt", Lines: lines, Added: len(lines)})
+	if !truncated || !strings.HasPrefix(text, "📝 Write big.txt (+65)") || !strings.HasSuffix(text, "```\n… 5 more lines") {
+		t.Errorf("long diff = %q, %v", text, truncated)
+	}
+
+	text, _ = formatDiff(&fileDiff{Tool: "Edit", Path: "README.md", Lines: []string{"+```go"}, Added: 1})
+	if strings.Count(text, "```") != 2 {
+		t.Errorf("a fence inside the diff should be escaped: %q", text)
+	}
+}
+
+func TestTakeFullFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "big.txt"), []byte("content"), 0644)
+
+	config := &Config{Messenger: messengerDiscord, GroupID: -100}
+	offerFullFile(config, config.GroupID, 5, dir, &fileDiff{Path: "big.txt"})
+	if len(fullFiles) != 0 {
+		t.Error("only Telegram answers Show full file buttons")
+	}
+
+	fullFilesMu.Lock()
+	fullFiles["abcd1234"] = &fullFile{ChatID: -100, ThreadID: 5, Path: filepath.Join(dir, "big.txt"), Created: time.Now()}
+	fullFilesMu.Unlock()
+	if f, _ := takeFullFile(fullFileCallbackPrefix + "abcd1234"); f == nil || f.ThreadID != 5 {
+		t.Errorf("takeFullFile = %+v", f)
+	}
+	if f, label := takeFullFile(fullFileCallbackPrefix + "unknown"); f != nil || label == "" {
+		t.Errorf("unknown button = %+v, %q", f, label)
+	}
+}
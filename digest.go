@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// digestPrompt asks the router LLM to turn the digest's numbers into prose
+const digestPrompt = `You write a short daily digest of coding sessions run with Claude Code. You get each session's prompts, commits and cost for the last 24 hours. In 3 to 6 sentences of plain text, say what got done and what seems unfinished, naming sessions. No markdown, no greeting, no numbers already obvious from the data.`
+
+// maxDigestItems caps the prompts and commits listed per session
+const maxDigestItems = 3
+
+// sessionActivity is what a session did over a digest period
+type sessionActivity struct {
+	Name        string
+	Prompts     []string // first lines, oldest first
+	Completions int      // prompts that got an answer
+	Commits     []string // subjects, newest first
+	CostUSD     float64
+}
+
+func (a sessionActivity) idle() bool {
+	return len(a.Prompts) == 0 && len(a.Commits) == 0
+}
+
+// transcriptActivity counts the prompts since a time in a session's
+// transcripts and how many of them were answered
+func transcriptActivity(workDir string, since time.Time) (prompts []string, completions int) {
+	files, _ := filepath.Glob(filepath.Join(claudeProjectDir(workDir), "*.jsonl"))
+	for _, file := range files {
+		if st, err := os.Stat(file); err != nil || st.ModTime().Before(since) {
+			continue
+		}
+		events, _ := readTranscriptEvents(file, 200)
+		answered := true
+		for _, ev := range events {
+			if ev.Time.Before(since) {
+				continue
+			}
+			switch ev.Kind {
+			case "prompt":
+				first, _, _ := strings.Cut(strings.TrimSpace(ev.Text), "\n")
+				prompts = append(prompts, first)
+				answered = false
+			case "reply":
+				if !answered {
+					completions++
+					answered = true
+				}
+			}
+		}
+	}
+	return prompts, completions
+}
+
+// gitCommitsSince returns the subjects of commits made in dir since a time
+func gitCommitsSince(dir string, since time.Time) []string {
+	if !isGitRepo(dir) {
+		return nil
+	}
+	out, err := runGit(dir, "log", "--since="+since.Format(time.RFC3339), "--format=%s")
+	if err != nil || out == "" {
+		return nil
+	}
+	return strings.Split(out, "\n")
+}
+
+// collectActivity gathers every session's activity since a time, busiest first
+func collectActivity(config *Config, since time.Time) []sessionActivity {
+	var activity []sessionActivity
+	for name, info := range config.Sessions {
+		if info == nil || info.Path == "" {
+			continue
+		}
+		a := sessionActivity{Name: name}
+		a.Prompts, a.Completions = transcriptActivity(info.Path, since)
+		a.Commits = gitCommitsSince(info.Path, since)
+		a.CostUSD = projectUsage(info.Path, since).CostUSD
+		activity = append(activity, a)
+	}
+	sort.Slice(activity, func(i, j int) bool {
+		if len(activity[i].Prompts) != len(activity[j].Prompts) {
+			return len(activity[i].Prompts) > len(activity[j].Prompts)
+		}
+		return activity[i].Name < activity[j].Name
+	})
+	return activity
+}
+
+// formatActivity renders the per-session lines of a digest
+func formatActivity(activity []sessionActivity) string {
+	var sb strings.Builder
+	var quiet []string
+	var cost float64
+	for _, a := range activity {
+		cost += a.CostUSD
+		if a.idle() {
+			quiet = append(quiet, a.Name)
+			continue
+		}
+		fmt.Fprintf(&sb, "\n• %s — %d prompts, %d answered, %d commits, $%.2f", a.Name, len(a.Prompts), a.Completions, len(a.Commits), a.CostUSD)
+		for i, p := range a.Prompts {
+			if i == maxDigestItems {
+				fmt.Fprintf(&sb, "\n   … %d more", len(a.Prompts)-i)
+				break
+			}
+			fmt.Fprintf(&sb, "\n   ❓ %s", truncate(p, 80))
+		}
+		for i, c := range a.Commits {
+			if i == maxDigestItems {
+				fmt.Fprintf(&sb, "\n   … %d more commits", len(a.Commits)-i)
+				break
+			}
+			fmt.Fprintf(&sb, "\n   📌 %s", truncate(c, 80))
+		}
+	}
+	if sb.Len() == 0 {
+		return "No activity."
+	}
+	if len(quiet) > 0 {
+		fmt.Fprintf(&sb, "\n\n💤 Quiet: %s", strings.Join(quiet, ", "))
+	}
+	fmt.Fprintf(&sb, "\n\n💰 Total: $%.2f (estimated)", cost)
+	return strings.TrimPrefix(sb.String(), "\n")
+}
+
+// buildDigest renders the digest for the 24 hours before now, led by a
+// prose summary when the router LLM is configured
+func buildDigest(config *Config, now time.Time) string {
+	activity := collectActivity(config, now.Add(-24*time.Hour))
+	details := formatActivity(activity)
+	header := "📰 Daily digest — last 24 hours\n\n"
+	if details == "No activity." || !routerEnabled(config) {
+		return header + details
+	}
+	prose, err := routerComplete(config, digestPrompt, details, 400)
+	if err != nil || strings.TrimSpace(prose) == "" {
+		hookLog("digest: summary failed: %v", err)
+		return header + details
+	}
+	return header + strings.TrimSpace(prose) + "\n\n" + details
+}
+
+// parseDigestTime checks an "HH:MM" digest time
+func parseDigestTime(s string) (time.Time, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return t, fmt.Errorf("invalid time %q (use HH:MM, e.g. 08:30)", s)
+	}
+	return t, nil
+}
+
+// digestDue reports whether the daily digest is due in the given minute
+func digestDue(config *Config, minute time.Time) bool {
+	if config.DigestTime == "" {
+		return false
+	}
+	t, err := parseDigestTime(config.DigestTime)
+	return err == nil && minute.Hour() == t.Hour() && minute.Minute() == t.Minute()
+}
+
+// sendDigest sends the daily digest to the private chat
+func sendDigest(config *Config, now time.Time) {
+	if config.ChatID == 0 {
+		return
+	}
+	hookLog("digest: sending")
+	getMessenger(config).Send(config.ChatID, 0, buildDigest(config, now))
+}
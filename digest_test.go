@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscriptActivity(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := filepath.Join(home, "api")
+	projDir := claudeProjectDir(workDir)
+	os.MkdirAll(projDir, 0755)
+	transcript := `{"type":"user","timestamp":"2026-01-01T08:00:00Z","message":{"content":"old prompt"}}
+{"type":"assistant","timestamp":"2026-01-01T08:01:00Z","message":{"content":[{"type":"text","text":"old answer"}]}}
+{"type":"user","timestamp":"2026-01-02T09:00:00Z","message":{"content":"fix the login bug\nit 500s"}}
+{"type":"assistant","timestamp":"2026-01-02T09:01:00Z","message":{"content":[{"type":"text","text":"Looking."}]}}
+{"type":"assistant","timestamp":"2026-01-02T09:05:00Z","message":{"content":[{"type":"text","text":"Fixed."}]}}
+{"type":"user","timestamp":"2026-01-02T10:00:00Z","message":{"content":"add tests"}}
+`
+	os.WriteFile(filepath.Join(projDir, "a.jsonl"), []byte(transcript), 0600)
+
+	since := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	prompts, completions := transcriptActivity(workDir, since)
+	if len(prompts) != 2 || prompts[0] != "fix the login bug" || prompts[1] != "add tests" {
+		t.Errorf("prompts = %q", prompts)
+	}
+	if completions != 1 {
+		t.Errorf("completions = %d, want 1", completions)
+	}
+}
+
+func TestFormatActivity(t *testing.T) {
+	if got := formatActivity([]sessionActivity{{Name: "idle"}}); got != "No activity." {
+		t.Errorf("formatActivity() with no activity = %q", got)
+	}
+	got := formatActivity([]sessionActivity{
+		{Name: "api", Prompts: []string{"a", "b", "c", "d"}, Completions: 3, Commits: []string{"Fix login"}, CostUSD: 1.5},
+		{Name: "web"},
+	})
+	for _, want := range []string{"• api — 4 prompts, 3 answered, 1 commits, $1.50", "… 1 more", "📌 Fix login", "💤 Quiet: web", "Total: $1.50"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatActivity() = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	at := time.Date(2026, 3, 1, 8, 30, 0, 0, time.Local)
+	if !digestDue(&Config{DigestTime: "08:30"}, at) {
+		t.Error("digest not due at its time")
+	}
+	if digestDue(&Config{DigestTime: "08:31"}, at) || digestDue(&Config{}, at) {
+		t.Error("digest due at another time or when off")
+	}
+	if _, err := parseDigestTime("8.30"); err == nil {
+		t.Error("parseDigestTime accepted 8.30")
+	}
+}
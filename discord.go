@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Discord backend: each session is a public thread in DiscordChannelID.
+// Output goes through the REST API; input is read by polling each session
+// thread, so no gateway connection is needed. Button presses (interactions)
+// need the gateway and are not supported: keyboards are rendered as text.
+
+// discordAPIBase is a variable so tests can point it at a local server
+var discordAPIBase = "https://discord.com/api/v10"
+
+// discordMaxLen is Discord's message length limit
+const discordMaxLen = 2000
+
+// discordPollInterval is how often session threads are checked for new messages
+const discordPollInterval = 3 * time.Second
+
+var discordClient = &http.Client{Timeout: 30 * time.Second}
+
+// discordMessage is the subset of a Discord message object we use
+type discordMessage struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+	Author  struct {
+		ID  string `json:"id"`
+		Bot bool   `json:"bot"`
+	} `json:"author"`
+}
+
+// discordMessenger delivers messages through the Discord REST API
+type discordMessenger struct {
+	config *Config
+}
+
+// discordRequest sends a request to the Discord API and returns the response body.
+// A 429 is retried once after the advertised delay.
+func discordRequest(config *Config, method, path, contentType string, body []byte) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, discordAPIBase+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bot "+config.DiscordBotToken)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := discordClient.Do(req)
+		if err != nil {
+			return nil, redactTokenError(err, config.DiscordBotToken)
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt == 0 {
+			var limit struct {
+				RetryAfter float64 `json:"retry_after"`
+			}
+			json.Unmarshal(data, &limit)
+			time.Sleep(time.Duration(limit.RetryAfter*1000) * time.Millisecond)
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("discord error %d: %s", resp.StatusCode, truncate(string(data), 200))
+		}
+		return data, nil
+	}
+}
+
+// discordJSON sends a JSON request to the Discord API
+func discordJSON(config *Config, method, path string, payload interface{}) ([]byte, error) {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+	return discordRequest(config, method, path, "application/json", body)
+}
+
+// channel returns the thread for a session, or the main channel when threadID is 0.
+// chatID is a Telegram concept and is ignored.
+func (d *discordMessenger) channel(threadID int64) int64 {
+	if threadID > 0 {
+		return threadID
+	}
+	return d.config.DiscordChannelID
+}
+
+func (d *discordMessenger) Send(chatID, threadID int64, text string) error {
+	_, err := d.SendGetID(chatID, threadID, text)
+	return err
+}
+
+func (d *discordMessenger) SendGetID(chatID, threadID int64, text string) (int64, error) {
+	var lastMsgID int64
+	for _, part := range splitMessage(text, discordMaxLen) {
+		data, err := discordJSON(d.config, "POST", fmt.Sprintf("/channels/%d/messages", d.channel(threadID)), map[string]string{"content": part})
+		if err != nil {
+			return 0, err
+		}
+		var msg discordMessage
+		if json.Unmarshal(data, &msg) == nil {
+			lastMsgID, _ = strconv.ParseInt(msg.ID, 10, 64)
+		}
+	}
+	return lastMsgID, nil
+}
+
+// Edit replaces a message's text, sending overflow as new messages
+func (d *discordMessenger) Edit(chatID, messageID, threadID int64, text string) error {
+	parts := splitMessage(text, discordMaxLen)
+	path := fmt.Sprintf("/channels/%d/messages/%d", d.channel(threadID), messageID)
+	if _, err := discordJSON(d.config, "PATCH", path, map[string]string{"content": parts[0]}); err != nil {
+		return err
+	}
+	for _, part := range parts[1:] {
+		if err := d.Send(chatID, threadID, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendFormatted sends text as is: Discord renders markdown itself
+func (d *discordMessenger) SendFormatted(chatID, threadID int64, text string) (int64, error) {
+	return d.SendGetID(chatID, threadID, text)
+}
+
+func (d *discordMessenger) EditFormatted(chatID, messageID, threadID int64, text string) error {
+	return d.Edit(chatID, messageID, threadID, text)
+}
+
+// SendWithKeyboard lists the button labels under the text; Discord buttons
+// would need a gateway connection to receive presses.
+func (d *discordMessenger) SendWithKeyboard(chatID, threadID int64, text string, buttons [][]InlineKeyboardButton) error {
+	var sb strings.Builder
+	sb.WriteString(text)
+	sb.WriteString("\n")
+	n := 0
+	for _, row := range buttons {
+		for _, b := range row {
+			n++
+			sb.WriteString(fmt.Sprintf("\n%d. %s", n, b.Text))
+		}
+	}
+	return d.Send(chatID, threadID, sb.String())
+}
+
+func (d *discordMessenger) SendFile(chatID, threadID int64, filePath string, caption string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	payload, _ := json.Marshal(map[string]string{"content": truncate(caption, discordMaxLen)})
+	writer.WriteField("payload_json", string(payload))
+	part, err := writer.CreateFormFile("files[0]", filepath.Base(filePath))
+	if err != nil {
+		return err
+	}
+	io.Copy(part, file)
+	writer.Close()
+
+	_, err = discordRequest(d.config, "POST", fmt.Sprintf("/channels/%d/messages", d.channel(threadID)), writer.FormDataContentType(), body.Bytes())
+	return err
+}
+
+// CreateTopic starts a public thread in the configured channel
+func (d *discordMessenger) CreateTopic(name string) (int64, error) {
+	if d.config.DiscordChannelID == 0 {
+		return 0, fmt.Errorf("no Discord channel configured. Run: ccc config discord-channel <id>")
+	}
+	data, err := discordJSON(d.config, "POST", fmt.Sprintf("/channels/%d/threads", d.config.DiscordChannelID), map[string]interface{}{
+		"name":                  name,
+		"type":                  11, // public thread
+		"auto_archive_duration": 10080,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create thread: %w", err)
+	}
+	var thread struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &thread); err != nil {
+		return 0, fmt.Errorf("failed to parse thread result: %w", err)
+	}
+	return strconv.ParseInt(thread.ID, 10, 64)
+}
+
+func (d *discordMessenger) DeleteTopic(topicID int64) error {
+	_, err := discordJSON(d.config, "DELETE", fmt.Sprintf("/channels/%d", topicID), nil)
+	return err
+}
+
+// fetchDiscordMessages returns messages in a channel newer than afterID, oldest first
+func fetchDiscordMessages(config *Config, channelID int64, afterID string) ([]discordMessage, error) {
+	path := fmt.Sprintf("/channels/%d/messages?limit=50", channelID)
+	if afterID != "" {
+		path += "&after=" + afterID
+	}
+	data, err := discordRequest(config, "GET", path, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []discordMessage
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, err
+	}
+	// Discord returns newest first
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs, nil
+}
+
+// listenDiscord polls every session thread and forwards the configured user's
+// messages to the matching Claude session.
+func listenDiscord(config *Config) error {
+	if config.DiscordBotToken == "" || config.DiscordChannelID == 0 || config.DiscordUserID == 0 {
+		return fmt.Errorf("discord not configured. Run: ccc config discord-token <token>, discord-channel <id>, discord-user <id>")
+	}
+
+	fmt.Printf("Discord bot listening... (channel: %d)\n", config.DiscordChannelID)
+	fmt.Printf("Active sessions: %d\n", len(config.Sessions))
+
+	go startSessionMonitor(config)
+	go startScheduler()
+	go startControlServer()
+
+	msgr := getMessenger(config)
+	userID := strconv.FormatInt(config.DiscordUserID, 10)
+	lastSeen := make(map[int64]string) // thread ID -> last message ID handled
+
+	for {
+		config, _ = loadConfig()
+		for sessName, info := range config.Sessions {
+			if info == nil || info.TopicID == 0 {
+				continue
+			}
+			after, seen := lastSeen[info.TopicID]
+			msgs, err := fetchDiscordMessages(config, info.TopicID, after)
+			if err != nil {
+				hookLog("discord: poll %s failed: %v", sessName, err)
+				continue
+			}
+			if len(msgs) == 0 {
+				if !seen {
+					lastSeen[info.TopicID] = ""
+				}
+				continue
+			}
+			lastSeen[info.TopicID] = msgs[len(msgs)-1].ID
+			if !seen {
+				// First poll of this thread: skip history
+				continue
+			}
+			for _, m := range msgs {
+				text := strings.TrimSpace(m.Content)
+				if m.Author.Bot || m.Author.ID != userID || text == "" {
+					continue
+				}
+				forwardToSession(config, msgr, config.DiscordChannelID, info.TopicID, sessName, text)
+			}
+		}
+		time.Sleep(discordPollInterval)
+	}
+}
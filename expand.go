@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxPreviewLen is how much of a block too long for one message is shown
+	// before the "Show more" button
+	maxPreviewLen = 3000
+	// expandCallbackPrefix marks "Show more" buttons. The button carries no
+	// ID: the block is found by the chat and message the button is on.
+	expandCallbackPrefix = "more:"
+	// expandTimeout is how long a "Show more" button stays valid
+	expandTimeout = 24 * time.Hour
+)
+
+// expandable is the rest of a block shown as a preview
+type expandable struct {
+	Text    string // the whole block
+	Rest    string // what the preview leaves out
+	Created time.Time
+}
+
+var (
+	expandables   = make(map[string]*expandable)
+	expandablesMu sync.Mutex
+)
+
+// previewText cuts a block to maxPreviewLen at a line break. A code fence left
+// open by the cut is closed in the preview and reopened in the rest, so both
+// render on their own.
+func previewText(text string) (preview, rest string) {
+	if len(text) <= maxPreviewLen {
+		return text, ""
+	}
+	cut := maxPreviewLen
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	if nl := strings.LastIndex(text[:cut], "\n"); nl > maxPreviewLen/2 {
+		cut = nl
+	}
+	preview, rest = text[:cut], strings.TrimPrefix(text[cut:], "\n")
+
+	open := ""
+	for _, line := range strings.Split(preview, "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "```") {
+			if open == "" {
+				open = trimmed
+			} else {
+				open = ""
+			}
+		}
+	}
+	if open != "" {
+		preview += "\n```"
+		rest = open + "\n" + rest
+	}
+	return preview + "\n…", rest
+}
+
+// expandKeyboard is the reply_markup of a preview
+func expandKeyboard() string {
+	keyboard, _ := json.Marshal(map[string]interface{}{
+		"inline_keyboard": [][]InlineKeyboardButton{{
+			{Text: "⬇️ Show more", CallbackData: expandCallbackPrefix},
+		}},
+	})
+	return string(keyboard)
+}
+
+func expandableKey(chatID, messageID int64) string {
+	return fmt.Sprintf("%d:%d", chatID, messageID)
+}
+
+// rememberExpandable keeps what a preview message leaves out. A block that
+// keeps growing is re-remembered on every edit.
+func rememberExpandable(chatID, messageID int64, text, rest string) {
+	expandablesMu.Lock()
+	defer expandablesMu.Unlock()
+	now := time.Now()
+	for k, e := range expandables {
+		if now.Sub(e.Created) > expandTimeout {
+			delete(expandables, k)
+		}
+	}
+	expandables[expandableKey(chatID, messageID)] = &expandable{Text: text, Rest: rest, Created: now}
+}
+
+// takeExpandable returns the rest of the block behind a "Show more" button,
+// or nil if it is unknown or expired
+func takeExpandable(chatID, messageID int64) *expandable {
+	key := expandableKey(chatID, messageID)
+	expandablesMu.Lock()
+	defer expandablesMu.Unlock()
+	e, ok := expandables[key]
+	delete(expandables, key)
+	if !ok || time.Since(e.Created) > expandTimeout {
+		return nil
+	}
+	return e
+}
+
+// sendExpanded answers a "Show more" button: the rest as one more message
+// when it fits, otherwise the whole block as a .txt document
+func sendExpanded(config *Config, chatID, threadID, messageID int64, e *expandable) error {
+	if len(e.Rest) <= 4000 {
+		_, err := sendFormattedGetID(config, chatID, threadID, e.Rest)
+		return err
+	}
+	dir, err := os.MkdirTemp("", "ccc-block-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, fmt.Sprintf("block-%d.txt", messageID))
+	if err := os.WriteFile(path, []byte(e.Text), 0600); err != nil {
+		return err
+	}
+	return sendFile(config, chatID, threadID, path, "")
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreviewText(t *testing.T) {
+	if preview, rest := previewText("short"); preview != "short" || rest != "" {
+		t.Errorf("short text = %q, %q", preview, rest)
+	}
+
+	line := strings.Repeat("x", 99) + "\n"
+	text := strings.Repeat(line, 50)
+	preview, rest := previewText(text)
+	if len(preview) > maxPreviewLen+10 || !strings.HasSuffix(preview, "x\n…") {
+		t.Errorf("preview should end at a line break: %q", preview[len(preview)-10:])
+	}
+	if strings.TrimSuffix(preview, "\n…")+"\n"+rest != text {
+		t.Error("preview and rest should add up to the block")
+	}
+
+	// A cut inside a code fence closes it in the preview and reopens it in the rest
+	text = "Here:\n```go\n" + strings.Repeat("fmt.Println(1)\n", 300) + "```"
+	preview, rest = previewText(text)
+	if strings.Count(preview, "```")%2 != 0 || !strings.HasSuffix(preview, "```\n…") {
+		t.Errorf("preview leaves a fence open: %q", preview[len(preview)-20:])
+	}
+	if !strings.HasPrefix(rest, "```go\n") {
+		t.Errorf("rest should reopen the fence: %q", rest[:20])
+	}
+
+	// Never cut inside a multi-byte character
+	preview, _ = previewText(strings.Repeat("é", maxPreviewLen))
+	if !strings.HasSuffix(preview, "é\n…") {
+		t.Errorf("preview cut a rune: %q", preview[len(preview)-6:])
+	}
+}
+
+func TestTakeExpandable(t *testing.T) {
+	rememberExpandable(-100, 7, "whole", "first rest")
+	rememberExpandable(-100, 7, "whole, grown", "longer rest")
+	e := takeExpandable(-100, 7)
+	if e == nil || e.Rest != "longer rest" {
+		t.Fatalf("takeExpandable = %+v, want the latest edit", e)
+	}
+	if takeExpandable(-100, 7) != nil {
+		t.Error("a button should deliver the rest once")
+	}
+	if takeExpandable(-100, 8) != nil {
+		t.Error("another message should have nothing to expand")
+	}
+}
+
+func TestSendFormattedPreview(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	calls, _, done := fakeTelegram(t)
+	defer done()
+
+	config := &Config{BotToken: "test"}
+	msgID, err := sendFormattedGetID(config, -100, 5, strings.Repeat("word ", 1000))
+	if err != nil || msgID != 7 {
+		t.Fatalf("sendFormattedGetID = %d, %v", msgID, err)
+	}
+	if *calls != 1 {
+		t.Errorf("a long block took %d messages, want 1 preview", *calls)
+	}
+	if e := takeExpandable(-100, 7); e == nil || e.Rest == "" {
+		t.Errorf("the rest of the block was not kept: %+v", e)
+	}
+}
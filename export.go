@@ -0,0 +1,190 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exportSession bundles a session's Claude transcripts, its block cache and a
+// Markdown rendering of the conversation into a zip in dir:
+//
+//	conversation.md
+//	blocks.json
+//	session.json
+//	transcripts/<id>.jsonl
+//	snapshots/<time>-<id>.jsonl   (transcripts kept before compaction)
+func exportSession(config *Config, name, dir string) (string, error) {
+	info := config.Sessions[name]
+	if info == nil {
+		return "", fmt.Errorf("session %s not found", name)
+	}
+	transcripts := sessionTranscripts(info.Path)
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.zip", name, time.Now().Format("20060102-150405")))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	zw := zip.NewWriter(f)
+	err = writeExport(zw, config, name, info, transcripts)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// writeExport writes the export entries into a zip
+func writeExport(zw *zip.Writer, config *Config, name string, info *SessionInfo, transcripts []string) error {
+	w, err := zw.Create("conversation.md")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, renderConversationMarkdown(name, info, transcripts)); err != nil {
+		return err
+	}
+
+	blocks, _ := json.MarshalIndent(loadBlockCache(name), "", "  ")
+	if w, err = zw.Create("blocks.json"); err != nil {
+		return err
+	}
+	if _, err := w.Write(blocks); err != nil {
+		return err
+	}
+
+	meta, _ := json.MarshalIndent(info, "", "  ")
+	if w, err = zw.Create("session.json"); err != nil {
+		return err
+	}
+	if _, err := w.Write(meta); err != nil {
+		return err
+	}
+
+	for _, t := range transcripts {
+		if err := addFileToZip(zw, t, "transcripts/"+filepath.Base(t)); err != nil {
+			return err
+		}
+	}
+	for _, s := range compactSnapshots(name) {
+		if err := addFileToZip(zw, s, "snapshots/"+filepath.Base(s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFileToZip copies a file into the zip under name
+func addFileToZip(zw *zip.Writer, path, name string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// sessionTranscripts returns a project's transcripts, oldest first
+func sessionTranscripts(workDir string) []string {
+	files, _ := filepath.Glob(filepath.Join(claudeProjectDir(workDir), "*.jsonl"))
+	mod := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		if st, err := os.Stat(file); err == nil {
+			mod[file] = st.ModTime()
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool { return mod[files[i]].Before(mod[files[j]]) })
+	return files
+}
+
+// renderConversationMarkdown renders the prompts, tool calls and replies of
+// each transcript as a readable log
+func renderConversationMarkdown(name string, info *SessionInfo, transcripts []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", name)
+	fmt.Fprintf(&sb, "Exported %s from `%s`\n", time.Now().Format("2006-01-02 15:04"), info.Path)
+	if len(transcripts) == 0 {
+		sb.WriteString("\nNo Claude transcripts found.\n")
+	}
+
+	for _, t := range transcripts {
+		events, err := readTranscriptEvents(t, 0)
+		if err != nil {
+			hookLog("export: %s: %v", t, err)
+		}
+		fmt.Fprintf(&sb, "\n## %s\n", strings.TrimSuffix(filepath.Base(t), ".jsonl"))
+		for _, ev := range events {
+			stamp := ev.Time.Local().Format("2006-01-02 15:04:05")
+			switch ev.Kind {
+			case "prompt":
+				fmt.Fprintf(&sb, "\n**You** · %s\n\n%s\n", stamp, strings.TrimSpace(ev.Text))
+			case "reply":
+				fmt.Fprintf(&sb, "\n**Claude** · %s\n\n%s\n", stamp, strings.TrimSpace(ev.Text))
+			case "tool":
+				fmt.Fprintf(&sb, "\n> 🔧 `%s`\n", strings.ReplaceAll(ev.Text, "`", "'"))
+			}
+		}
+	}
+	return sb.String()
+}
+
+// sendSessionExport builds an export in the temp dir, sends it to the session
+// topic and removes it once delivered
+func sendSessionExport(config *Config, name string) error {
+	info := config.Sessions[name]
+	if info == nil || info.TopicID == 0 || config.GroupID == 0 {
+		return fmt.Errorf("session %s has no topic", name)
+	}
+	path, err := exportSession(config, name, os.TempDir())
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return sendFileToTopic(config, name, info.TopicID, path, st.Size())
+}
+
+// handleExportCommand implements `ccc export <session>`: the zip is written to
+// the current directory and sent to the session's topic when it has one
+func handleExportCommand(name string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("no config found: %w", err)
+	}
+	cwd, _ := os.Getwd()
+	path, err := exportSession(config, name, cwd)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📦 Exported %s to %s\n", name, path)
+
+	info := config.Sessions[name]
+	if info.TopicID == 0 || config.GroupID == 0 {
+		return nil
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return sendFileToTopic(config, name, info.TopicID, path, st.Size())
+}
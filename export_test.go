@@ -0,0 +1,74 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportSession(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	workDir := filepath.Join(tmpDir, "proj")
+	projDir := claudeProjectDir(workDir)
+	if err := os.MkdirAll(projDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	transcript := `{"type":"user","timestamp":"2026-01-02T10:00:00Z","message":{"content":"fix the tests"}}
+{"type":"assistant","timestamp":"2026-01-02T10:00:05Z","message":{"content":[{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"user","timestamp":"2026-01-02T10:00:09Z","message":{"content":[{"type":"tool_result","content":"ok"}]}}
+{"type":"assistant","timestamp":"2026-01-02T10:00:10Z","message":{"content":[{"type":"text","text":"All tests pass now."}]}}
+`
+	if err := os.WriteFile(filepath.Join(projDir, "abc123.jsonl"), []byte(transcript), 0600); err != nil {
+		t.Fatal(err)
+	}
+	saveBlockCache("proj", &BlockCache{Blocks: []CachedBlock{{Text: "All tests pass now.", MsgID: 42, Hash: "h"}}})
+
+	config := &Config{Sessions: map[string]*SessionInfo{"proj": {TopicID: 100, Path: workDir}}}
+	if _, err := exportSession(config, "missing", tmpDir); err == nil {
+		t.Error("exportSession of unknown session should fail")
+	}
+	path, err := exportSession(config, "proj", tmpDir)
+	if err != nil {
+		t.Fatalf("exportSession: %v", err)
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[f.Name] = string(data)
+	}
+
+	if contents["transcripts/abc123.jsonl"] != transcript {
+		t.Errorf("transcript not copied verbatim: %q", contents["transcripts/abc123.jsonl"])
+	}
+	if !strings.Contains(contents["blocks.json"], `"msg_id": 42`) {
+		t.Errorf("blocks.json = %q, want the cached block", contents["blocks.json"])
+	}
+	if !strings.Contains(contents["session.json"], `"topic_id": 100`) {
+		t.Errorf("session.json = %q, want the session info", contents["session.json"])
+	}
+	md := contents["conversation.md"]
+	for _, want := range []string{"# proj", "## abc123", "**You**", "fix the tests", "🔧 `Bash", "**Claude**", "All tests pass now."} {
+		if !strings.Contains(md, want) {
+			t.Errorf("conversation.md missing %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "tool_result") || strings.Count(md, "**You**") != 1 {
+		t.Errorf("tool results should not be rendered as prompts:\n%s", md)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Several machines can share one bot. Telegram allows one getUpdates poller
+// per bot, so only the hub (federation_port) polls it; updates for topics
+// whose session it doesn't have are queued, and the other machines
+// (federation_hub) long-poll the hub for them instead of Telegram. Every
+// machine sends to Telegram directly.
+//
+// Polls and the hub's answers are signed with the shared secret, and the hub
+// only listens beyond loopback with a TLS certificate (federation_cert), so
+// updates can't be read or forged on the way.
+
+const (
+	// federationPollWait is how long the hub holds a poll with nothing new
+	federationPollWait = 25 * time.Second
+	// federationQueueSize is how many updates the hub keeps for followers
+	federationQueueSize = 500
+	// federationOnline is how recently a follower must have polled to count
+	// as reachable
+	federationOnline = 90 * time.Second
+	// federationMaxSkew rejects polls signed this far from the hub's clock
+	federationMaxSkew = 5 * time.Minute
+	// federationSignatureHeader carries the hex HMAC-SHA256 of a poll's body,
+	// or of the hub's answer (see signFederationAnswer)
+	federationSignatureHeader = "X-CCC-Signature"
+)
+
+// federationPoll is a follower's request for the updates after Offset
+type federationPoll struct {
+	Machine  string `json:"machine"`
+	Offset   int    `json:"offset"` // 0 = only updates queued from now on
+	Sessions int    `json:"sessions"`
+	Time     int64  `json:"time"`
+}
+
+// federationMachine is a follower as last seen by the hub
+type federationMachine struct {
+	Name     string
+	Sessions int
+	Seen     time.Time
+}
+
+// federationHub queues updates for followers and tracks when they polled
+type federationHub struct {
+	mu       sync.Mutex
+	queue    []TelegramUpdateItem
+	last     int
+	notify   chan struct{} // closed when an update is queued
+	machines map[string]*federationMachine
+}
+
+// federation is the hub the listener runs, nil unless federation_port is set
+var federation *federationHub
+
+func newFederationHub() *federationHub {
+	return &federationHub{
+		notify:   make(chan struct{}),
+		machines: make(map[string]*federationMachine),
+	}
+}
+
+// machineName is the name this machine's sessions are claimed under
+func machineName(config *Config) string {
+	if config.MachineName != "" {
+		return config.MachineName
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "local"
+	}
+	return strings.SplitN(host, ".", 2)[0]
+}
+
+// isFederated reports whether this machine is a hub or a follower
+func isFederated(config *Config) bool {
+	return config.FederationPort != 0 || config.FederationHub != ""
+}
+
+// sessionMachine is the machine field for a session created here, "" when
+// federation is off so single-machine configs don't change
+func sessionMachine(config *Config) string {
+	if !isFederated(config) {
+		return ""
+	}
+	return machineName(config)
+}
+
+// ownsSession reports whether a session runs on this machine. Sessions from
+// before federation have no machine and belong to whoever has them.
+func ownsSession(config *Config, info *SessionInfo) bool {
+	return info != nil && (info.Machine == "" || info.Machine == machineName(config))
+}
+
+// signFederation returns the hex HMAC-SHA256 of a poll body
+func signFederation(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyFederation checks a poll's signature. Unlike the relay, the hub
+// never accepts unsigned polls: they carry every message sent to the bot.
+func verifyFederation(secret string, body []byte, sig string) bool {
+	return secret != "" && hmac.Equal([]byte(signFederation(secret, body)), []byte(sig))
+}
+
+// signFederationAnswer signs the hub's answer to a poll, bound to the poll's
+// machine and time so an answer can't be replayed to another poll
+func signFederationAnswer(secret string, p federationPoll, body []byte) string {
+	bound := append([]byte(fmt.Sprintf("%s\n%d\n", p.Machine, p.Time)), body...)
+	return signFederation(secret, bound)
+}
+
+// checkFederationHub rejects hub URLs that would carry updates in the clear:
+// the hub must be https, or on loopback (e.g. through an SSH tunnel)
+func checkFederationHub(hubURL string) error {
+	u, err := url.Parse(hubURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid hub URL: %s", hubURL)
+	}
+	switch {
+	case u.Scheme == "https":
+		return nil
+	case u.Scheme == "http" && isLoopbackHost(u.Hostname()):
+		return nil
+	case u.Scheme == "http":
+		return fmt.Errorf("hub URL %s must use https (http is only allowed on loopback)", hubURL)
+	}
+	return fmt.Errorf("invalid hub URL: %s (https://)", hubURL)
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLocalUpdate reports whether an update is for this machine: anything
+// outside a session topic, or a topic or message of a session it owns
+func isLocalUpdate(config *Config, u TelegramUpdateItem) bool {
+	var chatID, threadID int64
+	switch {
+	case u.InlineQuery != nil:
+		return true
+	case u.Reaction != nil:
+		if !isSessionGroup(config, u.Reaction.Chat.ID) {
+			return true
+		}
+		sessName, _, ok := sessionForMessage(config, u.Reaction.Chat.ID, int64(u.Reaction.MessageID))
+		return ok && ownsSession(config, config.Sessions[sessName])
+	case u.CallbackQuery != nil:
+		if u.CallbackQuery.Message == nil {
+			return true
+		}
+		chatID, threadID = u.CallbackQuery.Message.Chat.ID, u.CallbackQuery.Message.MessageThreadID
+	default:
+		chatID, threadID = u.Message.Chat.ID, u.Message.MessageThreadID
+	}
+	if threadID == 0 || !isSessionGroup(config, chatID) {
+		return true
+	}
+	sessName := getSessionByChatTopic(config, chatID, threadID)
+	return sessName != "" && ownsSession(config, config.Sessions[sessName])
+}
+
+// push queues an update for the followers
+func (h *federationHub) push(u TelegramUpdateItem) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queue = append(h.queue, u)
+	if len(h.queue) > federationQueueSize {
+		h.queue = h.queue[len(h.queue)-federationQueueSize:]
+	}
+	h.last = u.UpdateID
+	close(h.notify)
+	h.notify = make(chan struct{})
+}
+
+// since returns the queued updates from offset on; h.mu must be held
+func (h *federationHub) since(offset int) []TelegramUpdateItem {
+	var items []TelegramUpdateItem
+	for _, u := range h.queue {
+		if u.UpdateID >= offset {
+			items = append(items, u)
+		}
+	}
+	return items
+}
+
+// wait returns the updates from offset on, waiting up to timeout for one
+func (h *federationHub) wait(offset int, timeout time.Duration) []TelegramUpdateItem {
+	deadline := time.After(timeout)
+	for {
+		h.mu.Lock()
+		if offset == 0 {
+			offset = h.last + 1
+		}
+		items := h.since(offset)
+		notify := h.notify
+		h.mu.Unlock()
+		if len(items) > 0 {
+			return items
+		}
+		select {
+		case <-notify:
+		case <-deadline:
+			return nil
+		}
+	}
+}
+
+// seen records a follower's poll
+func (h *federationHub) seen(p federationPoll, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.machines[p.Machine] = &federationMachine{Name: p.Machine, Sessions: p.Sessions, Seen: now}
+}
+
+// followersOnline reports whether any follower polled recently. Without one
+// the hub handles every update itself, as a single listener would.
+func (h *federationHub) followersOnline(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, m := range h.machines {
+		if now.Sub(m.Seen) < federationOnline {
+			return true
+		}
+	}
+	return false
+}
+
+// followers returns the followers that have polled, by name
+func (h *federationHub) followers() []federationMachine {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	list := make([]federationMachine, 0, len(h.machines))
+	for _, m := range h.machines {
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// handlePoll serves POST /federation/poll: a signed federationPoll, answered
+// like getUpdates once there are updates or after federationPollWait
+func (h *federationHub) handlePoll(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if !verifyFederation(secret, body, r.Header.Get(federationSignatureHeader)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		var p federationPoll
+		if err := json.Unmarshal(body, &p); err != nil || p.Machine == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		if skew := now.Sub(time.Unix(p.Time, 0)); skew > federationMaxSkew || skew < -federationMaxSkew {
+			http.Error(w, "stale request", http.StatusUnauthorized)
+			return
+		}
+		h.seen(p, now)
+
+		items := h.wait(p.Offset, federationPollWait)
+		answer, err := json.Marshal(TelegramUpdate{OK: true, Result: items})
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(federationSignatureHeader, signFederationAnswer(secret, p, answer))
+		w.Write(answer)
+	}
+}
+
+// startFederationHub serves followers' polls on the federation port: over
+// TLS on every interface with federation_cert and federation_key, otherwise
+// on loopback only (for followers coming in through an SSH tunnel)
+func startFederationHub(config *Config, h *federationHub) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/federation/poll", h.handlePoll(config.FederationSecret))
+	if config.FederationCert != "" && config.FederationKey != "" {
+		addr := fmt.Sprintf(":%d", config.FederationPort)
+		logf(levelInfo, "federation hub listening", "addr", addr, "tls", true, "machine", machineName(config))
+		err := http.ListenAndServeTLS(addr, expandPath(config.FederationCert), expandPath(config.FederationKey), mux)
+		logf(levelError, "federation hub stopped", "err", err)
+		return
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", config.FederationPort)
+	logf(levelInfo, "federation hub listening on loopback only (set federation_cert and federation_key to serve over TLS)", "addr", addr, "machine", machineName(config))
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		logf(levelError, "federation hub stopped", "err", err)
+	}
+}
+
+// pollFederationHub asks the hub for the updates from offset on, in place of
+// getUpdates on a follower
+func pollFederationHub(client *http.Client, config *Config, offset int) (TelegramUpdate, error) {
+	var updates TelegramUpdate
+	if err := checkFederationHub(config.FederationHub); err != nil {
+		return updates, err
+	}
+	owned := 0
+	for _, info := range config.Sessions {
+		if ownsSession(config, info) {
+			owned++
+		}
+	}
+	poll := federationPoll{Machine: machineName(config), Offset: offset, Sessions: owned, Time: time.Now().Unix()}
+	body, _ := json.Marshal(poll)
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(config.FederationHub, "/")+"/federation/poll", bytes.NewReader(body))
+	if err != nil {
+		return updates, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(federationSignatureHeader, signFederation(config.FederationSecret, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return updates, err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode != http.StatusOK {
+		return updates, fmt.Errorf("hub returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	// Only act on updates the hub vouches for: anyone on the path could
+	// otherwise send commands as the owner
+	if !hmac.Equal([]byte(signFederationAnswer(config.FederationSecret, poll, data)), []byte(resp.Header.Get(federationSignatureHeader))) {
+		return updates, fmt.Errorf("hub answer has a bad signature")
+	}
+	if err := json.Unmarshal(data, &updates); err != nil {
+		return updates, fmt.Errorf("parsing hub response: %w", err)
+	}
+	return updates, nil
+}
+
+// formatMachines renders /machines: this machine and, on the hub, every
+// follower with when it last polled
+func formatMachines(config *Config, h *federationHub, now time.Time) string {
+	if !isFederated(config) {
+		return "🖥 Only this machine (" + machineName(config) + "). Share the bot with others: ccc config federation-port <port> here, federation-hub <url> there."
+	}
+	local := 0
+	for _, info := range config.Sessions {
+		if ownsSession(config, info) {
+			local++
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("🖥 Machines\n\n")
+	role := "hub"
+	if config.FederationHub != "" {
+		role = "follower of " + config.FederationHub
+	}
+	fmt.Fprintf(&sb, "🟢 %s (this machine, %s) — sessions: %d\n", machineName(config), role, local)
+	if h == nil {
+		return strings.TrimRight(sb.String(), "\n")
+	}
+	followers := h.followers()
+	if len(followers) == 0 {
+		sb.WriteString("\nNo follower has polled yet.")
+	}
+	for _, m := range followers {
+		if ago := now.Sub(m.Seen); ago < federationOnline {
+			fmt.Fprintf(&sb, "🟢 %s — sessions: %d\n", m.Name, m.Sessions)
+		} else {
+			fmt.Fprintf(&sb, "🔴 %s — last seen %s ago\n", m.Name, formatDuration(ago.Truncate(time.Second)))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsLocalUpdate(t *testing.T) {
+	config := &Config{
+		GroupID:     -100,
+		MachineName: "vps",
+		Sessions: map[string]*SessionInfo{
+			"api":    {TopicID: 5, Machine: "vps"},
+			"old":    {TopicID: 6},
+			"webapp": {TopicID: 7, Machine: "laptop"},
+		},
+	}
+	inTopic := func(topicID int64) TelegramUpdateItem {
+		var u TelegramUpdateItem
+		u.Message.Chat.ID = -100
+		u.Message.MessageThreadID = topicID
+		return u
+	}
+	button := inTopic(7).Message
+	tests := []struct {
+		name string
+		u    TelegramUpdateItem
+		want bool
+	}{
+		{"own session", inTopic(5), true},
+		{"session without a machine", inTopic(6), true},
+		{"other machine's session", inTopic(7), false},
+		{"topic with no session here", inTopic(8), false},
+		{"General", inTopic(0), true},
+		{"inline query", TelegramUpdateItem{InlineQuery: &InlineQuery{}}, true},
+		{"button in another machine's topic", TelegramUpdateItem{CallbackQuery: &CallbackQuery{Message: &button}}, false},
+	}
+	for _, tt := range tests {
+		if got := isLocalUpdate(config, tt.u); got != tt.want {
+			t.Errorf("%s: isLocalUpdate() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFederationHubQueue(t *testing.T) {
+	h := newFederationHub()
+	for id := 1; id <= federationQueueSize+10; id++ {
+		h.push(TelegramUpdateItem{UpdateID: id})
+	}
+	if len(h.queue) != federationQueueSize {
+		t.Fatalf("queue holds %d updates, want %d", len(h.queue), federationQueueSize)
+	}
+	items := h.wait(federationQueueSize+8, time.Second)
+	if len(items) != 3 || items[0].UpdateID != federationQueueSize+8 {
+		t.Errorf("wait(offset) = %+v, want the last 3 updates", items)
+	}
+
+	// Offset 0 waits for the next update
+	done := make(chan []TelegramUpdateItem)
+	go func() { done <- h.wait(0, 5*time.Second) }()
+	time.Sleep(50 * time.Millisecond)
+	h.push(TelegramUpdateItem{UpdateID: 1000})
+	if items := <-done; len(items) != 1 || items[0].UpdateID != 1000 {
+		t.Errorf("wait(0) = %+v, want update 1000", items)
+	}
+	if items := h.wait(2000, 10*time.Millisecond); items != nil {
+		t.Errorf("wait with nothing new = %+v", items)
+	}
+}
+
+func TestFederationPoll(t *testing.T) {
+	h := newFederationHub()
+	h.push(TelegramUpdateItem{UpdateID: 41})
+	h.push(TelegramUpdateItem{UpdateID: 42})
+	server := httptest.NewServer(h.handlePoll("s3cret"))
+	defer server.Close()
+
+	config := &Config{MachineName: "laptop", FederationHub: server.URL, FederationSecret: "s3cret"}
+	updates, err := pollFederationHub(server.Client(), config, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updates.OK || len(updates.Result) != 1 || updates.Result[0].UpdateID != 42 {
+		t.Errorf("poll = %+v, want update 42", updates)
+	}
+	if !h.followersOnline(time.Now()) {
+		t.Error("the follower isn't recorded as online after polling")
+	}
+
+	config.FederationSecret = "wrong"
+	if _, err := pollFederationHub(server.Client(), config, 42); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("poll with the wrong secret: err = %v, want 401", err)
+	}
+
+	// An answer the hub didn't sign, e.g. forged on the way, is dropped
+	forged := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(federationSignatureHeader, "00")
+		w.Write([]byte(`{"ok":true,"result":[{"update_id":43,"message":{"text":"/c rm -rf ~"}}]}`))
+	}))
+	defer forged.Close()
+	config.FederationSecret = "s3cret"
+	config.FederationHub = forged.URL
+	if _, err := pollFederationHub(forged.Client(), config, 43); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Errorf("poll with a forged answer: err = %v, want a signature error", err)
+	}
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"machine":"laptop","time":0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unsigned poll: status %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestCheckFederationHub(t *testing.T) {
+	for _, hub := range []string{"https://vps.example.com:8391", "http://localhost:8391", "http://127.0.0.1:8391", "http://[::1]:8391"} {
+		if err := checkFederationHub(hub); err != nil {
+			t.Errorf("checkFederationHub(%q) = %v, want nil", hub, err)
+		}
+	}
+	for _, hub := range []string{"http://vps.example.com:8391", "http://10.0.0.2:8391", "ftp://vps", "vps:8391"} {
+		if err := checkFederationHub(hub); err == nil {
+			t.Errorf("checkFederationHub(%q) = nil, want an error", hub)
+		}
+	}
+}
+
+func TestFormatMachines(t *testing.T) {
+	now := time.Now()
+	config := &Config{MachineName: "vps", FederationPort: 8391, Sessions: map[string]*SessionInfo{
+		"api":    {TopicID: 5, Machine: "vps"},
+		"webapp": {TopicID: 7, Machine: "laptop"},
+	}}
+	h := newFederationHub()
+	h.seen(federationPoll{Machine: "laptop", Sessions: 2}, now.Add(-10*time.Second))
+	h.seen(federationPoll{Machine: "desktop", Sessions: 1}, now.Add(-2*time.Hour))
+
+	got := formatMachines(config, h, now)
+	for _, want := range []string{"🟢 vps (this machine, hub) — sessions: 1", "🟢 laptop — sessions: 2", "🔴 desktop — last seen"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatMachines() missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "desktop") > strings.Index(got, "laptop") {
+		t.Errorf("followers not sorted by name:\n%s", got)
+	}
+
+	if got := formatMachines(&Config{MachineName: "solo"}, nil, now); !strings.Contains(got, "Only this machine (solo)") {
+		t.Errorf("formatMachines() without federation = %q", got)
+	}
+}
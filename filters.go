@@ -0,0 +1,103 @@
+package main
+
+import (
+	"regexp"
+	"sync"
+)
+
+// BlockFilters is the filters section of the config: rules applied to
+// output before it is forwarded to the chat
+type BlockFilters struct {
+	KeepSecrets bool            `json:"keep_secrets,omitempty"` // Turn off the built-in redaction of API keys, tokens and .env values
+	Redact      []string        `json:"redact,omitempty"`       // Regexes whose matches are replaced with [redacted]
+	Skip        []string        `json:"skip,omitempty"`         // Regexes; blocks matching one are not forwarded
+	Replace     []FilterReplace `json:"replace,omitempty"`      // Regex replacements, applied in order
+}
+
+// FilterReplace replaces the matches of a regex. With may refer to groups
+// of the match: $1, ${name}.
+type FilterReplace struct {
+	Match string `json:"match"`
+	With  string `json:"with"`
+}
+
+// redactedText replaces what a redaction rule matched
+const redactedText = "[redacted]"
+
+// builtinRedactions catch the common credential formats. They err on the
+// side of redacting too much: a value assigned to a variable named like a
+// secret is hidden even when it's only code.
+var builtinRedactions = []FilterReplace{
+	{`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}`, redactedText},                        // Anthropic, OpenAI
+	{`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`, redactedText}, // GitHub
+	{`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`, redactedText},                                 // AWS access key IDs
+	{`\bxox[abprs]-[A-Za-z0-9-]{10,}`, redactedText},                                // Slack
+	{`\bAIza[0-9A-Za-z_-]{35}`, redactedText},                                       // Google
+	{`\b\d{8,10}:[A-Za-z0-9_-]{35}\b`, redactedText},                                // Telegram bot tokens
+	{`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`, redactedText},
+	// KEY=value lines of .env files and exports
+	{`\b([A-Za-z0-9_]*(?:KEY|TOKEN|SECRET|PASSWORD|PASSWD|CREDENTIALS?)[A-Za-z0-9_]*\s*[=:]\s*)["']?[^\s"']{6,}["']?`, "${1}" + redactedText},
+}
+
+var (
+	// filterRegexps caches compiled filter patterns; filters run on every
+	// poll. Invalid patterns are cached as nil.
+	filterRegexps   = make(map[string]*regexp.Regexp)
+	filterRegexpsMu sync.Mutex
+)
+
+// filterRegexp compiles a filter pattern once, returning nil if it's invalid
+func filterRegexp(pattern string) *regexp.Regexp {
+	filterRegexpsMu.Lock()
+	defer filterRegexpsMu.Unlock()
+	re, ok := filterRegexps[pattern]
+	if !ok {
+		var err error
+		if re, err = regexp.Compile(pattern); err != nil {
+			hookLog("filters: invalid pattern %q: %v", pattern, err)
+		}
+		filterRegexps[pattern] = re
+	}
+	return re
+}
+
+// filterBlock applies the configured filters to a block of output. It
+// returns the text to forward, or false if a skip rule matched. Skip rules
+// see the block before redaction, so they can match what gets redacted.
+func filterBlock(config *Config, text string) (string, bool) {
+	var f BlockFilters
+	if config.Filters != nil {
+		f = *config.Filters
+	}
+	for _, p := range f.Skip {
+		if re := filterRegexp(p); re != nil && re.MatchString(text) {
+			return "", false
+		}
+	}
+
+	var rules []FilterReplace
+	if !f.KeepSecrets {
+		rules = append(rules, builtinRedactions...)
+	}
+	for _, p := range f.Redact {
+		rules = append(rules, FilterReplace{Match: p, With: redactedText})
+	}
+	rules = append(rules, f.Replace...)
+	for _, r := range rules {
+		if re := filterRegexp(r.Match); re != nil {
+			text = re.ReplaceAllString(text, r.With)
+		}
+	}
+	return text, true
+}
+
+// filterBlocks applies filterBlock to each block, dropping skipped ones
+func filterBlocks(config *Config, blocks []string) []string {
+	var kept []string
+	for _, b := range blocks {
+		if text, ok := filterBlock(config, b); ok {
+			kept = append(kept, text)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// gateTimeout is how long a test gate may run
+	gateTimeout = 15 * time.Minute
+	// maxGateRetries is how many times a failing gate is sent back to Claude
+	// before ccc gives up and leaves it to the user
+	maxGateRetries = 3
+	// gateOutputTail is how much of a failing gate's output Claude is shown
+	gateOutputTail = 3000
+)
+
+// gateRun is a session's test gate state across turns
+type gateRun struct {
+	Attempts int    // failures sent back to Claude in a row
+	Prompt   string // the last fix-up prompt, to tell its turn from the user's
+}
+
+var (
+	gateRuns   = make(map[string]*gateRun)
+	gateRunsMu sync.Mutex
+)
+
+// runGateCommand runs a gate command in dir and returns its combined output
+// and whether it passed
+func runGateCommand(dir, command string, timeout time.Duration) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.Command(commandShell(), "-l", "-c", command)
+	cmd.Dir = dir
+	// Own process group so the timeout also kills what the tests started
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		return "", false, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return out.String(), false, fmt.Errorf("timed out after %s", timeout)
+	}
+	if _, failed := err.(*exec.ExitError); failed {
+		return out.String(), false, nil
+	}
+	return out.String(), err == nil, err
+}
+
+// outputTail returns the end of a command's output, cut at a line
+func outputTail(out string, n int) string {
+	out = strings.TrimSpace(out)
+	if len(out) <= n {
+		return out
+	}
+	tail := out[len(out)-n:]
+	if idx := strings.Index(tail, "\n"); idx != -1 {
+		tail = tail[idx+1:]
+	}
+	return "…\n" + tail
+}
+
+// gateFixPrompt is what Claude is asked after the gate failed
+func gateFixPrompt(command, output string, attempt int) string {
+	return fmt.Sprintf("The test gate `%s` failed after your changes (attempt %d of %d). Fix the failures:\n\n```\n%s\n```",
+		command, attempt, maxGateRetries, outputTail(output, gateOutputTail))
+}
+
+// nextGateAttempt records a gate failure for a session and returns the
+// attempt number, 0 once the retries are used up. A turn the user started
+// begins a new series.
+func nextGateAttempt(sessName, lastPrompt string) int {
+	gateRunsMu.Lock()
+	defer gateRunsMu.Unlock()
+	run := gateRuns[sessName]
+	if run == nil || run.Prompt != lastPrompt {
+		run = &gateRun{}
+		gateRuns[sessName] = run
+	}
+	if run.Attempts >= maxGateRetries {
+		delete(gateRuns, sessName)
+		return 0
+	}
+	run.Attempts++
+	return run.Attempts
+}
+
+// recordGatePrompt remembers the fix-up prompt sent for a session's gate
+func recordGatePrompt(sessName, prompt string) {
+	gateRunsMu.Lock()
+	defer gateRunsMu.Unlock()
+	if run := gateRuns[sessName]; run != nil {
+		run.Prompt = prompt
+	}
+}
+
+// runTestGate runs a session's test gate after a completed turn. A pass is
+// reported (and autocommitted if on); a failure goes back to Claude as a
+// follow-up prompt up to maxGateRetries times in a row.
+func runTestGate(config *Config, sessName string, info *SessionInfo, lastPrompt string) {
+	msgr := getMessenger(config)
+	dir, err := jailCommand(config, info.TestGate, info.Path)
+	if err == nil && dir != info.Path {
+		err = fmt.Errorf("session directory %s is outside the command jail", info.Path)
+	}
+	if err != nil {
+		msgr.Send(sessionGroup(config, info), info.TopicID, fmt.Sprintf("⚠️ Test gate not run: %v", err))
+		return
+	}
+
+	started := time.Now()
+	out, passed, err := runGateCommand(info.Path, info.TestGate, gateTimeout)
+	elapsed := time.Since(started).Round(time.Second)
+	outcome := "exit 0"
+	if !passed {
+		outcome = "failed"
+	}
+	auditCommand(sessionGroup(config, info), info.TopicID, info.Path, info.TestGate, "gate "+outcome)
+
+	if passed {
+		gateRunsMu.Lock()
+		delete(gateRuns, sessName)
+		gateRunsMu.Unlock()
+		msgr.Send(sessionGroup(config, info), info.TopicID, fmt.Sprintf("✅ Tests pass (%s, %s)", info.TestGate, formatDuration(elapsed)))
+		if info.AutoCommit {
+			autoCommitCheckpoint(config, sessName, info, lastPrompt)
+		}
+		return
+	}
+	if err != nil {
+		out = fmt.Sprintf("%s\n%v", out, err)
+	}
+
+	attempt := nextGateAttempt(sessName, lastPrompt)
+	if attempt == 0 {
+		hookLog("gate: session=%s still failing after %d fixes", sessName, maxGateRetries)
+		msgr.Send(sessionGroup(config, info), info.TopicID, fmt.Sprintf("❌ %s still fails after %d fixes; over to you.\n\n%s",
+			info.TestGate, maxGateRetries, outputTail(out, 1000)))
+		return
+	}
+	hookLog("gate: session=%s failed, sending it back (attempt %d)", sessName, attempt)
+	msgr.Send(sessionGroup(config, info), info.TopicID, fmt.Sprintf("❌ %s failed; asking Claude to fix it (%d/%d)", info.TestGate, attempt, maxGateRetries))
+	prompt := gateFixPrompt(info.TestGate, out, attempt)
+	recordGatePrompt(sessName, prompt)
+	forwardToSession(config, msgr, sessionGroup(config, info), info.TopicID, sessName, prompt)
+}
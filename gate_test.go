@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunGateCommand(t *testing.T) {
+	dir := t.TempDir()
+	if out, passed, err := runGateCommand(dir, "echo ok", time.Minute); !passed || err != nil || !strings.HasSuffix(strings.TrimSpace(out), "ok") {
+		t.Errorf("passing gate = %q, %v, %v", out, passed, err)
+	}
+	if out, passed, err := runGateCommand(dir, "echo FAIL: TestX; exit 1", time.Minute); passed || err != nil || !strings.Contains(out, "FAIL: TestX") {
+		t.Errorf("failing gate = %q, %v, %v", out, passed, err)
+	}
+	if _, passed, err := runGateCommand(dir, "sleep 5", 100*time.Millisecond); passed || err == nil {
+		t.Errorf("slow gate = %v, %v, want a timeout", passed, err)
+	}
+}
+
+func TestNextGateAttempt(t *testing.T) {
+	defer func() {
+		gateRunsMu.Lock()
+		delete(gateRuns, "gate-test")
+		gateRunsMu.Unlock()
+	}()
+
+	prompt := "fix the parser"
+	for want := 1; want <= maxGateRetries; want++ {
+		if got := nextGateAttempt("gate-test", prompt); got != want {
+			t.Fatalf("attempt = %d, want %d", got, want)
+		}
+		prompt = gateFixPrompt("go test ./...", "FAIL", want)
+		recordGatePrompt("gate-test", prompt)
+	}
+	if got := nextGateAttempt("gate-test", prompt); got != 0 {
+		t.Errorf("attempt after %d fixes = %d, want 0", maxGateRetries, got)
+	}
+
+	// A turn the user started begins a new series
+	nextGateAttempt("gate-test", "fix")
+	recordGatePrompt("gate-test", "gate prompt")
+	if got := nextGateAttempt("gate-test", "something else"); got != 1 {
+		t.Errorf("attempt after a user turn = %d, want 1", got)
+	}
+}
+
+func TestOutputTail(t *testing.T) {
+	out := strings.Repeat("ok line\n", 100) + "--- FAIL: TestParse"
+	tail := outputTail(out, 50)
+	if !strings.HasPrefix(tail, "…\n") || !strings.HasSuffix(tail, "--- FAIL: TestParse") || len(tail) > 60 {
+		t.Errorf("outputTail = %q", tail)
+	}
+	if got := outputTail("short\n", 50); got != "short" {
+		t.Errorf("outputTail(short) = %q", got)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// gcReport is how config, tmux and Telegram topics have drifted apart
+type gcReport struct {
+	TmuxOrphans  []string // tmux sessions (full names) with no config entry
+	TopicDeleted []string // sessions whose topic was deleted by hand
+	Stopped      []string // sessions with a topic but no tmux session
+}
+
+func (r gcReport) empty() bool {
+	return len(r.TmuxOrphans)+len(r.TopicDeleted)+len(r.Stopped) == 0
+}
+
+// otherProfilePrefixes returns the tmux prefixes of the other profiles with a
+// config file, so the default profile's "claude-" doesn't claim their sessions
+func otherProfilePrefixes() []string {
+	files, _ := filepath.Glob(filepath.Join(configDir(), "config-*.json"))
+	var prefixes []string
+	for _, f := range files {
+		p := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f), "config-"), ".json")
+		if p != currentProfile() && validateProfile(p) == nil {
+			prefixes = append(prefixes, "claude-"+p+"-")
+		}
+	}
+	return prefixes
+}
+
+// reconcileSessions compares the configured sessions with the running tmux
+// sessions and, through topicDeleted, the groups' topics. Nil topicDeleted
+// skips the topic check (no Telegram group).
+func reconcileSessions(config *Config, tmuxSessions []string, topicDeleted func(groupID, topicID int64) bool) gcReport {
+	var r gcReport
+	running := make(map[string]bool)
+	for _, name := range tmuxSessions {
+		running[name] = true
+	}
+	known := make(map[string]bool)
+	for _, name := range sortedSessionNames(config) {
+		info := config.Sessions[name]
+		tmuxName := sessionName(name)
+		known[tmuxName] = true
+		switch {
+		case topicDeleted != nil && info.TopicID > 0 && topicDeleted(sessionGroup(config, info), info.TopicID):
+			r.TopicDeleted = append(r.TopicDeleted, name)
+		case !running[tmuxName] && !isHeadless(info) && !info.Hibernated && info.TopicID > 0:
+			r.Stopped = append(r.Stopped, name)
+		}
+	}
+	others := otherProfilePrefixes()
+	for _, name := range tmuxSessions {
+		if known[name] || !strings.HasPrefix(name, tmuxPrefix()) || hasAnyPrefix(name, others) {
+			continue
+		}
+		r.TmuxOrphans = append(r.TmuxOrphans, name)
+	}
+	sort.Strings(r.TmuxOrphans)
+	return r
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// telegramTopicDeleted reports whether a topic of a group is gone. Telegram
+// can't look a topic up, so this sends a typing action into it; only a
+// "thread not found" answer counts, not network or permission errors.
+func telegramTopicDeleted(config *Config, groupID, topicID int64) bool {
+	result, err := telegramAPI(config, "sendChatAction", url.Values{
+		"chat_id":           {fmt.Sprintf("%d", groupID)},
+		"message_thread_id": {fmt.Sprintf("%d", topicID)},
+		"action":            {"typing"},
+	})
+	if err != nil || result.OK {
+		return false
+	}
+	desc := strings.ToLower(result.Description)
+	return strings.Contains(desc, "thread not found") || strings.Contains(desc, "topic_deleted") || strings.Contains(desc, "topic_id_invalid")
+}
+
+// checkSessionDrift builds the gc report for the live tmux server and group
+func checkSessionDrift(config *Config) gcReport {
+	var tmuxSessions []string
+	if tmuxPath != "" {
+		tmuxSessions, _ = listTmuxSessions()
+	}
+	var topicDeleted func(int64, int64) bool
+	if configuredMessenger(config) == messengerTelegram && config.GroupID != 0 {
+		topicDeleted = func(group, id int64) bool { return telegramTopicDeleted(config, group, id) }
+	}
+	return reconcileSessions(config, tmuxSessions, topicDeleted)
+}
+
+// removeOrphans kills orphaned tmux sessions and drops the sessions whose
+// topic is gone. Stopped sessions are left alone: /continue revives them.
+func removeOrphans(config *Config, r gcReport) {
+	for _, name := range r.TmuxOrphans {
+		killTmuxSession(name)
+	}
+	for _, name := range r.TopicDeleted {
+		if tmuxName := sessionName(name); tmuxSessionExists(tmuxName) {
+			killTmuxSession(tmuxName)
+		}
+		delete(config.Sessions, name)
+		deleteSession(name)
+		ClearSessionMonitor(name)
+	}
+}
+
+// formatGCReport renders the report for `ccc gc` and /gc
+func formatGCReport(r gcReport, removed bool) string {
+	if r.empty() {
+		return "🧹 Config, tmux and topics are in sync"
+	}
+	var sb strings.Builder
+	sb.WriteString("🧹 Session drift:")
+	if len(r.TmuxOrphans) > 0 {
+		verb := "no config entry"
+		if removed {
+			verb = "killed"
+		}
+		fmt.Fprintf(&sb, "\n\ntmux sessions with %s:\n  %s", verb, strings.Join(r.TmuxOrphans, "\n  "))
+	}
+	if len(r.TopicDeleted) > 0 {
+		verb := "topic deleted"
+		if removed {
+			verb = "topic deleted, removed"
+		}
+		fmt.Fprintf(&sb, "\n\nSessions whose %s:\n  %s", verb, strings.Join(r.TopicDeleted, "\n  "))
+	}
+	if len(r.Stopped) > 0 {
+		fmt.Fprintf(&sb, "\n\nTopics with no tmux session (/continue or /delete there):\n  %s", strings.Join(r.Stopped, "\n  "))
+	}
+	if !removed && len(r.TmuxOrphans)+len(r.TopicDeleted) > 0 {
+		sb.WriteString("\n\nRun with --remove (/gc remove) to kill the orphaned tmux sessions and drop the sessions whose topic is gone.")
+	}
+	return sb.String()
+}
+
+// handleGCCommand implements `ccc gc [--remove]`
+func handleGCCommand(args []string) error {
+	remove := false
+	for _, arg := range args {
+		if arg != "--remove" {
+			return fmt.Errorf("usage: ccc gc [--remove]")
+		}
+		remove = true
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("no config found: %w", err)
+	}
+	r := checkSessionDrift(config)
+	if remove {
+		removeOrphans(config, r)
+	}
+	fmt.Println(formatGCReport(r, remove))
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReconcileSessions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	os.MkdirAll(configDir(), 0700)
+	os.WriteFile(filepath.Join(configDir(), "config-work.json"), []byte("{}"), 0600)
+
+	config := &Config{Sessions: map[string]*SessionInfo{
+		"api":      {TopicID: 10},
+		"web.app":  {TopicID: 11},
+		"gone":     {TopicID: 12},
+		"stopped":  {TopicID: 13},
+		"headless": {TopicID: 14, Mode: sessionModeHeadless},
+		"asleep":   {TopicID: 15, Hibernated: true},
+	}}
+	tmux := []string{"claude-api", "claude-web_app", "claude-gone", "claude-old", "claude-work-api", "other"}
+	deleted := func(group, id int64) bool { return id == 12 }
+
+	r := reconcileSessions(config, tmux, deleted)
+	want := gcReport{
+		TmuxOrphans:  []string{"claude-old"},
+		TopicDeleted: []string{"gone"},
+		Stopped:      []string{"stopped"},
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("reconcileSessions = %+v, want %+v", r, want)
+	}
+
+	if r := reconcileSessions(config, tmux, nil); len(r.TopicDeleted) != 0 {
+		t.Errorf("topic check ran without a group: %+v", r)
+	}
+}
+
+func TestFormatGCReport(t *testing.T) {
+	if got := formatGCReport(gcReport{}, false); !strings.Contains(got, "in sync") {
+		t.Errorf("empty report = %q", got)
+	}
+	r := gcReport{TmuxOrphans: []string{"claude-old"}, Stopped: []string{"api"}}
+	got := formatGCReport(r, false)
+	if !strings.Contains(got, "claude-old") || !strings.Contains(got, "api") || !strings.Contains(got, "--remove") {
+		t.Errorf("report = %q", got)
+	}
+	if got := formatGCReport(r, true); !strings.Contains(got, "killed") || strings.Contains(got, "--remove") {
+		t.Errorf("removed report = %q", got)
+	}
+}
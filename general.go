@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultSession returns the session messages in the group's General area go
+// to, "" when none is set or it no longer exists
+func defaultSession(config *Config) string {
+	if info := config.Sessions[config.DefaultSession]; info == nil || info.TopicID == 0 {
+		return ""
+	}
+	return config.DefaultSession
+}
+
+// handleDefaultCommand implements /default [<session>|off]: show or set the
+// session the group's General area forwards messages to
+func handleDefaultCommand(config *Config, arg string) (string, error) {
+	switch arg {
+	case "":
+		if name := defaultSession(config); name != "" {
+			return fmt.Sprintf("💬 Messages in General go to '%s'. /default off to stop.", name), nil
+		}
+		return "💬 No default session: messages in General go to the router or a one-shot Claude. Set one with /default <session>.", nil
+	case "off":
+		config.DefaultSession = ""
+	default:
+		name := findSessionByFuzzyName(config, arg)
+		if name == "" || config.Sessions[name].TopicID == 0 {
+			return "", fmt.Errorf("session '%s' not found", arg)
+		}
+		config.DefaultSession = name
+	}
+	if err := saveConfig(config); err != nil {
+		return "", fmt.Errorf("failed to save config: %w", err)
+	}
+	if config.DefaultSession == "" {
+		return "💬 No default session", nil
+	}
+	return fmt.Sprintf("💬 Messages in General now go to '%s'", config.DefaultSession), nil
+}
+
+// forwardFromGeneral sends a message written in the General area to a
+// session, quoting it in the session's topic so its output there has context
+func forwardFromGeneral(config *Config, chatID int64, sessName, text string) {
+	info := config.Sessions[sessName]
+	msgr := getMessenger(config)
+	msgr.Send(sessionGroup(config, info), info.TopicID, "💬 From General: "+truncate(strings.TrimSpace(text), 500))
+	forwardToSession(config, msgr, sessionGroup(config, info), info.TopicID, sessName, text)
+	reply := fmt.Sprintf("📨 Sent to '%s'", sessName)
+	if link := topicLink(config, sessionGroup(config, info), info.TopicID); link != "" {
+		reply += ": " + link
+	}
+	sendMessage(config, chatID, 0, reply)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultSession(t *testing.T) {
+	config := &Config{DefaultSession: "api", Sessions: map[string]*SessionInfo{"api": {TopicID: 5}}}
+	if got := defaultSession(config); got != "api" {
+		t.Errorf("defaultSession() = %q, want api", got)
+	}
+	config.DefaultSession = "gone"
+	if got := defaultSession(config); got != "" {
+		t.Errorf("defaultSession() for a deleted session = %q", got)
+	}
+}
+
+func TestHandleDefaultCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	config := &Config{Sessions: map[string]*SessionInfo{"quantum-sim": {TopicID: 5}}}
+
+	reply, err := handleDefaultCommand(config, "quantum")
+	if err != nil || config.DefaultSession != "quantum-sim" || !strings.Contains(reply, "quantum-sim") {
+		t.Fatalf("/default quantum = %q, %v (default %q)", reply, err, config.DefaultSession)
+	}
+	if _, err := handleDefaultCommand(config, "missing"); err == nil {
+		t.Error("/default with an unknown session succeeded")
+	}
+	if _, err := handleDefaultCommand(config, "off"); err != nil || config.DefaultSession != "" {
+		t.Errorf("/default off left %q, %v", config.DefaultSession, err)
+	}
+}
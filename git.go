@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runGit runs a git command in dir and returns its trimmed combined output
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return strings.TrimSpace(out.String()), err
+}
+
+// runGitTimeout is runGit with a deadline and credential prompts disabled,
+// for network operations that would otherwise hang the listener
+func runGitTimeout(dir string, timeout time.Duration, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	return strings.TrimSpace(out.String()), err
+}
+
+// isGitRepo reports whether dir is inside a git work tree
+func isGitRepo(dir string) bool {
+	out, err := runGit(dir, "rev-parse", "--is-inside-work-tree")
+	return err == nil && out == "true"
+}
+
+// gitHasChanges reports whether the work tree has uncommitted changes (including untracked files)
+func gitHasChanges(dir string) bool {
+	out, err := runGit(dir, "status", "--porcelain")
+	return err == nil && out != ""
+}
+
+// checkpointMessage derives a commit message from the user's last prompt
+func checkpointMessage(prompt string) string {
+	summary := strings.TrimSpace(prompt)
+	if idx := strings.Index(summary, "\n"); idx != -1 {
+		summary = strings.TrimSpace(summary[:idx])
+	}
+	if summary == "" {
+		summary = "turn completed"
+	}
+	return "ccc checkpoint: " + truncate(summary, 60)
+}
+
+// gitCheckpoint commits all changes in dir and returns the short hash of the new commit.
+// Returns "" with no error when dir is not a git repo or has nothing to commit.
+func gitCheckpoint(dir string, message string) (string, error) {
+	if !isGitRepo(dir) || !gitHasChanges(dir) {
+		return "", nil
+	}
+	if out, err := runGit(dir, "add", "-A"); err != nil {
+		return "", fmt.Errorf("git add failed: %s", out)
+	}
+	if out, err := runGit(dir, "commit", "-q", "-m", message); err != nil {
+		return "", fmt.Errorf("git commit failed: %s", out)
+	}
+	return runGit(dir, "rev-parse", "--short", "HEAD")
+}
+
+// createWorktree adds a git worktree of repo at path on branch, creating the
+// branch from the repo's current HEAD if it doesn't exist yet.
+func createWorktree(repo, path, branch string) error {
+	if !isGitRepo(repo) {
+		return fmt.Errorf("%s is not a git repository", repo)
+	}
+	if out, err := runGit(repo, "check-ref-format", "--branch", branch); err != nil {
+		return fmt.Errorf("invalid branch name %q: %s", branch, out)
+	}
+	args := []string{"worktree", "add", path, branch}
+	if _, err := runGit(repo, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err != nil {
+		args = []string{"worktree", "add", "-b", branch, path}
+	}
+	if out, err := runGit(repo, args...); err != nil {
+		return fmt.Errorf("git worktree add failed: %s", out)
+	}
+	return nil
+}
+
+// mergeWorktree merges a worktree session's branch into whatever branch the
+// main repository has checked out and returns that branch's name. A failed
+// merge is aborted so the repository is left as it was.
+func mergeWorktree(info *SessionInfo) (string, error) {
+	if info.WorktreeRepo == "" || info.Branch == "" {
+		return "", fmt.Errorf("not a worktree session")
+	}
+	if gitHasChanges(info.Path) {
+		return "", fmt.Errorf("the worktree has uncommitted changes; commit them first")
+	}
+	target, err := runGit(info.WorktreeRepo, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s's branch: %s", info.WorktreeRepo, target)
+	}
+	if target == info.Branch {
+		return "", fmt.Errorf("%s has %s checked out itself", info.WorktreeRepo, info.Branch)
+	}
+	if out, err := runGit(info.WorktreeRepo, "merge", "--no-ff", "--no-edit", info.Branch); err != nil {
+		runGit(info.WorktreeRepo, "merge", "--abort")
+		return "", fmt.Errorf("merge into %s failed (aborted):\n%s", target, truncate(out, 1000))
+	}
+	return target, nil
+}
+
+// commitMessagePrompt asks the router LLM for a commit message
+const commitMessagePrompt = `Write a git commit message subject for the change below: one line, imperative mood, at most 72 characters, no quotes, no trailing period. Reply with the subject only.`
+
+// maxCommitDiffBytes is how much of the staged diff the router LLM sees
+const maxCommitDiffBytes = 6000
+
+// cleanCommitSubject turns an LLM reply into a commit subject, "" if unusable
+func cleanCommitSubject(reply string) string {
+	subject := strings.TrimSpace(reply)
+	if idx := strings.Index(subject, "\n"); idx != -1 {
+		subject = strings.TrimSpace(subject[:idx])
+	}
+	subject = strings.Trim(subject, "`\"' ")
+	subject = strings.TrimSuffix(subject, ".")
+	if len(subject) > 72 {
+		subject = truncate(subject, 69)
+	}
+	return subject
+}
+
+// generateCommitMessage asks the router LLM to describe the staged changes,
+// falling back to the prompt-based checkpoint message
+func generateCommitMessage(config *Config, dir, prompt string) string {
+	fallback := checkpointMessage(prompt)
+	if !routerEnabled(config) {
+		return fallback
+	}
+	if out, err := runGit(dir, "add", "-A"); err != nil {
+		hookLog("autocommit: git add failed: %s", out)
+		return fallback
+	}
+	diff, err := runGit(dir, "diff", "--cached", "--stat", "--patch")
+	if err != nil || diff == "" {
+		return fallback
+	}
+	if len(diff) > maxCommitDiffBytes {
+		diff = diff[:maxCommitDiffBytes] + "\n…"
+	}
+	reply, err := routerComplete(config, commitMessagePrompt, fmt.Sprintf("Request: %s\n\n%s", truncate(prompt, 500), diff), 60)
+	if err != nil {
+		hookLog("autocommit: commit message from router failed: %v", err)
+		return fallback
+	}
+	if subject := cleanCommitSubject(reply); subject != "" {
+		return subject
+	}
+	return fallback
+}
+
+// parsePushTarget splits "remote" or "remote/branch"; the branch is "" to
+// push to the current branch's name
+func parsePushTarget(target string) (remote, branch string) {
+	remote, branch, _ = strings.Cut(target, "/")
+	return remote, branch
+}
+
+// gitPushCheckpoint pushes HEAD to a session's auto-push target and returns
+// where it went
+func gitPushCheckpoint(dir, target string) (string, error) {
+	remote, branch := parsePushTarget(target)
+	if branch == "" {
+		current, err := runGit(dir, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil || current == "HEAD" {
+			return "", fmt.Errorf("HEAD is detached; set a branch with /autocommit push %s/<branch>", remote)
+		}
+		branch = current
+	}
+	if out, err := runGitTimeout(dir, gitNetworkTimeout, "push", remote, "HEAD:refs/heads/"+branch); err != nil {
+		return "", fmt.Errorf("%s", clampGitOutput(out))
+	}
+	return remote + "/" + branch, nil
+}
+
+// autoCommitCheckpoint creates a checkpoint commit for a session after a
+// completed turn, pushes it if the session has a push target, and reports
+// the hash to the session's topic.
+func autoCommitCheckpoint(config *Config, sessName string, info *SessionInfo, prompt string) {
+	if !isGitRepo(info.Path) || !gitHasChanges(info.Path) {
+		return
+	}
+	message := generateCommitMessage(config, info.Path, prompt)
+	hash, err := gitCheckpoint(info.Path, message)
+	if err != nil {
+		hookLog("autocommit: session=%s error: %v", sessName, err)
+		getMessenger(config).Send(sessionGroup(config, info), info.TopicID, fmt.Sprintf("⚠️ Checkpoint failed: %v", err))
+		return
+	}
+	if hash == "" {
+		return
+	}
+	hookLog("autocommit: session=%s commit=%s", sessName, hash)
+	report := fmt.Sprintf("📌 Checkpoint %s: %s", hash, message)
+	if info.AutoPush != "" {
+		if pushed, err := gitPushCheckpoint(info.Path, info.AutoPush); err != nil {
+			hookLog("autocommit: session=%s push to %s failed: %v", sessName, info.AutoPush, err)
+			report += fmt.Sprintf("\n⚠️ Push to %s failed: %v", info.AutoPush, err)
+		} else {
+			report += "\n⬆️ Pushed to " + pushed
+		}
+	}
+	getMessenger(config).Send(sessionGroup(config, info), info.TopicID, report)
+}
+
+const (
+	defaultGitLogCount = 10
+	maxGitLogCount     = 50
+	gitNetworkTimeout  = 2 * time.Minute
+	maxGitOutputBytes  = 3500 // leaves room for the header and code fence in one message
+)
+
+// gitCommandArgs maps "/git" arguments to the git command to run. Only
+// status, diff, log, push and pull are allowed; diff takes --staged and
+// paths, log takes a count.
+func gitCommandArgs(arg string) ([]string, error) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("usage: /git status|diff [--staged] [path...]|log [n]|push|pull")
+	}
+	sub, extra := fields[0], fields[1:]
+	switch sub {
+	case "status":
+		if len(extra) > 0 {
+			return nil, fmt.Errorf("usage: /git status")
+		}
+		return []string{"status", "--short", "--branch"}, nil
+	case "diff":
+		args := []string{"diff", "--stat", "--patch"}
+		var paths []string
+		for _, a := range extra {
+			switch {
+			case a == "--staged" || a == "--cached":
+				args = append(args, "--staged")
+			case strings.HasPrefix(a, "-"):
+				return nil, fmt.Errorf("unsupported diff option %s", a)
+			default:
+				paths = append(paths, a)
+			}
+		}
+		if len(paths) > 0 {
+			args = append(append(args, "--"), paths...)
+		}
+		return args, nil
+	case "log":
+		n := defaultGitLogCount
+		if len(extra) > 1 {
+			return nil, fmt.Errorf("usage: /git log [n]")
+		}
+		if len(extra) == 1 {
+			var err error
+			if n, err = strconv.Atoi(extra[0]); err != nil || n <= 0 {
+				return nil, fmt.Errorf("usage: /git log [n]")
+			}
+			if n > maxGitLogCount {
+				n = maxGitLogCount
+			}
+		}
+		return []string{"log", "--oneline", "--decorate", "-n", strconv.Itoa(n)}, nil
+	case "push", "pull":
+		if len(extra) > 0 {
+			return nil, fmt.Errorf("usage: /git %s (uses the branch's upstream)", sub)
+		}
+		if sub == "pull" {
+			return []string{"pull", "--ff-only"}, nil
+		}
+		return []string{"push"}, nil
+	}
+	return nil, fmt.Errorf("unsupported git command %q (status, diff, log, push, pull)", sub)
+}
+
+// clampGitOutput keeps output within one message, cutting at a line and
+// noting how many lines were dropped
+func clampGitOutput(out string) string {
+	if len(out) <= maxGitOutputBytes {
+		return out
+	}
+	cut := out[:maxGitOutputBytes]
+	if idx := strings.LastIndex(cut, "\n"); idx > 0 {
+		cut = cut[:idx]
+	}
+	dropped := strings.Count(out[len(cut):], "\n")
+	return fmt.Sprintf("%s\n… %d more lines", cut, dropped)
+}
+
+// runSessionGit runs a "/git" command in a session's directory. With a
+// command jail set, the session and any paths must be inside it.
+func runSessionGit(config *Config, info *SessionInfo, arg string) (string, error) {
+	args, err := gitCommandArgs(arg)
+	if err != nil {
+		return "", err
+	}
+	if config.CommandJailDir != "" {
+		jail := filepath.Clean(expandPath(config.CommandJailDir))
+		if !isInsideDir(jail, info.Path) {
+			return "", fmt.Errorf("session directory %s is outside the command jail (%s)", info.Path, jail)
+		}
+		if path := findPathOutsideJail(jail, info.Path, strings.Join(args, " ")); path != "" {
+			return "", fmt.Errorf("path %s is outside the command jail (%s)", path, jail)
+		}
+	}
+	if !isGitRepo(info.Path) {
+		return "", fmt.Errorf("%s is not a git repository", info.Path)
+	}
+
+	timeout := 30 * time.Second
+	if args[0] == "push" || args[0] == "pull" {
+		timeout = gitNetworkTimeout
+	}
+	out, err := runGitTimeout(info.Path, timeout, args...)
+	if err != nil {
+		if out == "" {
+			return "", fmt.Errorf("git %s failed: %v", args[0], err)
+		}
+		return "", fmt.Errorf("git %s failed:\n%s", args[0], clampGitOutput(out))
+	}
+	if out == "" {
+		switch args[0] {
+		case "diff":
+			out = "(no changes)"
+		case "log":
+			out = "(no commits)"
+		default:
+			out = "(no output)"
+		}
+	}
+	return clampGitOutput(out), nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		prompt   string
+		expected string
+	}{
+		{"simple", "fix the login bug", "ccc checkpoint: fix the login bug"},
+		{"first line only", "add tests\nfor the parser", "ccc checkpoint: add tests"},
+		{"empty", "  ", "ccc checkpoint: turn completed"},
+		{"long", strings.Repeat("a", 80), "ccc checkpoint: " + strings.Repeat("a", 60) + "..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := checkpointMessage(tt.prompt); result != tt.expected {
+				t.Errorf("checkpointMessage(%q) = %q, want %q", tt.prompt, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGitCheckpoint(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "ccc test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "ccc@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "ccc test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "ccc@example.com")
+
+	dir := t.TempDir()
+
+	// Not a repo: no-op
+	if hash, err := gitCheckpoint(dir, "msg"); hash != "" || err != nil {
+		t.Errorf("gitCheckpoint outside repo = (%q, %v), want no-op", hash, err)
+	}
+
+	if out, err := runGit(dir, "init", "-q"); err != nil {
+		t.Fatalf("git init failed: %s", out)
+	}
+
+	// Clean repo: nothing to commit
+	if hash, err := gitCheckpoint(dir, "msg"); hash != "" || err != nil {
+		t.Errorf("gitCheckpoint on clean repo = (%q, %v), want no-op", hash, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := gitCheckpoint(dir, "ccc checkpoint: add file")
+	if err != nil || hash == "" {
+		t.Fatalf("gitCheckpoint with changes = (%q, %v), want a commit", hash, err)
+	}
+	if msg, _ := runGit(dir, "log", "-1", "--format=%s"); msg != "ccc checkpoint: add file" {
+		t.Errorf("commit message = %q", msg)
+	}
+	if gitHasChanges(dir) {
+		t.Error("work tree should be clean after checkpoint")
+	}
+}
+
+func TestCleanCommitSubject(t *testing.T) {
+	tests := map[string]string{
+		"Fix login redirect loop":                    "Fix login redirect loop",
+		"`Add retry to upload client.`\n\nMore text": "Add retry to upload client",
+		"\"Rename config loader\"":                   "Rename config loader",
+		"  ":                                         "",
+		strings.Repeat("a", 100):                     strings.Repeat("a", 69) + "...",
+	}
+	for reply, want := range tests {
+		if got := cleanCommitSubject(reply); got != want {
+			t.Errorf("cleanCommitSubject(%q) = %q, want %q", reply, got, want)
+		}
+	}
+}
+
+func TestGitPushCheckpoint(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "ccc test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "ccc@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "ccc test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "ccc@example.com")
+
+	remote, dir := t.TempDir(), t.TempDir()
+	runGit(remote, "init", "-q", "--bare")
+	runGit(dir, "init", "-q", "-b", "main")
+	runGit(dir, "remote", "add", "origin", remote)
+	os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0644)
+	if _, err := gitCheckpoint(dir, "add file"); err != nil {
+		t.Fatal(err)
+	}
+
+	if where, err := gitPushCheckpoint(dir, "origin"); err != nil || where != "origin/main" {
+		t.Errorf("push to origin = %q, %v, want origin/main", where, err)
+	}
+	if where, err := gitPushCheckpoint(dir, "origin/ccc-work"); err != nil || where != "origin/ccc-work" {
+		t.Errorf("push to origin/ccc-work = %q, %v", where, err)
+	}
+	if out, _ := runGit(remote, "log", "-1", "--format=%s", "ccc-work"); out != "add file" {
+		t.Errorf("remote ccc-work = %q, want the checkpoint", out)
+	}
+	if _, err := gitPushCheckpoint(dir, "nowhere"); err == nil {
+		t.Error("pushing to a missing remote should fail")
+	}
+}
+
+func TestParseNewArgs(t *testing.T) {
+	tests := []struct {
+		arg                string
+		name, repo, branch string
+		wantErr            bool
+	}{
+		{"myproj", "myproj", "", "", false},
+		{"api-fix --worktree ~/api", "api-fix", "~/api", "api-fix", false},
+		{"api-fix --worktree api feature/login", "api-fix", "api", "feature/login", false},
+		{"api-fix --worktree", "", "", "", true},
+		{"api-fix --worktree a b c", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		name, repo, branch, err := parseNewArgs(tt.arg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseNewArgs(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if name != tt.name || repo != tt.repo || branch != tt.branch {
+			t.Errorf("parseNewArgs(%q) = %q, %q, %q, want %q, %q, %q", tt.arg, name, repo, branch, tt.name, tt.repo, tt.branch)
+		}
+	}
+}
+
+func TestWorktreeMerge(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "ccc test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "ccc@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "ccc test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "ccc@example.com")
+
+	repo := filepath.Join(t.TempDir(), "repo")
+	os.MkdirAll(repo, 0755)
+	if out, err := runGit(repo, "init", "-q"); err != nil {
+		t.Fatalf("git init failed: %s", out)
+	}
+	os.WriteFile(filepath.Join(repo, "a.txt"), []byte("a"), 0644)
+	if _, err := gitCheckpoint(repo, "initial"); err != nil {
+		t.Fatal(err)
+	}
+
+	worktree := filepath.Join(t.TempDir(), "feature")
+	if err := createWorktree(repo, worktree, "feature"); err != nil {
+		t.Fatalf("createWorktree failed: %v", err)
+	}
+	info := &SessionInfo{Path: worktree, WorktreeRepo: repo, Branch: "feature"}
+
+	os.WriteFile(filepath.Join(worktree, "b.txt"), []byte("b"), 0644)
+	if _, err := mergeWorktree(info); err == nil {
+		t.Error("mergeWorktree should refuse uncommitted changes")
+	}
+	if _, err := gitCheckpoint(worktree, "add b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mergeWorktree(info); err != nil {
+		t.Fatalf("mergeWorktree failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "b.txt")); err != nil {
+		t.Error("merged file missing from main repository")
+	}
+
+	// An existing branch is checked out rather than recreated
+	os.RemoveAll(worktree)
+	runGit(repo, "worktree", "prune")
+	if err := createWorktree(repo, worktree, "feature"); err != nil {
+		t.Errorf("createWorktree on existing branch failed: %v", err)
+	}
+	if err := createWorktree(t.TempDir(), filepath.Join(t.TempDir(), "x"), "x"); err == nil {
+		t.Error("createWorktree outside a repo should fail")
+	}
+}
+
+func TestGitCommandArgs(t *testing.T) {
+	tests := []struct {
+		arg     string
+		want    string
+		wantErr bool
+	}{
+		{"status", "status --short --branch", false},
+		{"diff", "diff --stat --patch", false},
+		{"diff --staged main.go", "diff --stat --patch --staged -- main.go", false},
+		{"log", "log --oneline --decorate -n 10", false},
+		{"log 500", "log --oneline --decorate -n 50", false},
+		{"pull", "pull --ff-only", false},
+		{"push", "push", false},
+		{"", "", true},
+		{"reset --hard", "", true},
+		{"diff --output=/etc/passwd", "", true},
+		{"log abc", "", true},
+		{"push --force", "", true},
+	}
+	for _, tt := range tests {
+		args, err := gitCommandArgs(tt.arg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("gitCommandArgs(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if got := strings.Join(args, " "); got != tt.want {
+			t.Errorf("gitCommandArgs(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestClampGitOutput(t *testing.T) {
+	if out := clampGitOutput("short"); out != "short" {
+		t.Errorf("short output changed: %q", out)
+	}
+	long := strings.Repeat("0123456789\n", 1000)
+	out := clampGitOutput(long)
+	if len(out) > maxGitOutputBytes+50 {
+		t.Errorf("clamped output is %d bytes", len(out))
+	}
+	if !strings.HasSuffix(out, "more lines") || strings.Contains(out, "0123456789012") {
+		t.Errorf("clamped output should end at a line with a note, got tail %q", out[len(out)-40:])
+	}
+}
+
+func TestRunSessionGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if out, err := runGit(dir, "init", "-q"); err != nil {
+		t.Fatalf("git init failed: %s", out)
+	}
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("x"), 0644)
+	info := &SessionInfo{Path: dir}
+
+	out, err := runSessionGit(&Config{}, info, "status")
+	if err != nil || !strings.Contains(out, "?? new.txt") {
+		t.Errorf("status = (%q, %v), want new.txt untracked", out, err)
+	}
+	if out, err := runSessionGit(&Config{}, info, "diff"); err != nil || out != "(no changes)" {
+		t.Errorf("diff = (%q, %v), want (no changes)", out, err)
+	}
+
+	jailed := &Config{CommandJailDir: t.TempDir()}
+	if _, err := runSessionGit(jailed, info, "status"); err == nil || !strings.Contains(err.Error(), "outside the command jail") {
+		t.Errorf("status outside jail error = %v", err)
+	}
+	jailed.CommandJailDir = dir
+	if _, err := runSessionGit(jailed, info, "diff ../other"); err == nil || !strings.Contains(err.Error(), "outside the command jail") {
+		t.Errorf("diff of path outside jail error = %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// `ccc github-listen` receives GitHub webhooks. Labeling an issue with
+// githubSpawnLabel starts a session on it in the repository's local checkout,
+// and each turn Claude completes is posted back to the issue as a comment.
+
+// githubSpawnLabel is the issue label that starts a session
+const githubSpawnLabel = "ccc"
+
+// maxIssueCommentLen stays under GitHub's 65536 character comment limit
+const maxIssueCommentLen = 60000
+
+// githubIssueEvent is the subset of an "issues" webhook payload we use
+type githubIssueEvent struct {
+	Action string      `json:"action"`
+	Issue  githubIssue `json:"issue"`
+	Label  struct {
+		Name string `json:"name"`
+	} `json:"label"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// githubUserAllowed reports whether login may start sessions. Anyone who can
+// label issues could otherwise have an untrusted issue body run as a prompt.
+func githubUserAllowed(allowed []string, login string) bool {
+	for _, a := range allowed {
+		if login != "" && strings.EqualFold(a, login) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyGitHubSignature checks a webhook's X-Hub-Signature-256 header, the
+// hex HMAC-SHA256 of the body under the webhook secret
+func verifyGitHubSignature(secret string, body []byte, header string) bool {
+	sig := strings.TrimPrefix(header, "sha256=")
+	if secret == "" || sig == header {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(sig))
+}
+
+// issueSessionName is the session started for an issue, named after the
+// repository so issues with the same number in two repos don't collide
+func issueSessionName(repo string, n int) string {
+	if _, name, ok := strings.Cut(repo, "/"); ok {
+		repo = name
+	}
+	return strings.ToLower(repo) + "-issue-" + strconv.Itoa(n)
+}
+
+// spawningIssues holds the issues whose session is being started. GitHub
+// may deliver a webhook twice, and the session only appears in the config
+// once startDetached has finished.
+var (
+	spawningIssues   = make(map[string]bool)
+	spawningIssuesMu sync.Mutex
+)
+
+// claimIssueSpawn reports whether the caller may start a session for ref,
+// false while another delivery is already starting one
+func claimIssueSpawn(ref string) bool {
+	spawningIssuesMu.Lock()
+	defer spawningIssuesMu.Unlock()
+	if spawningIssues[ref] {
+		return false
+	}
+	spawningIssues[ref] = true
+	return true
+}
+
+func releaseIssueSpawn(ref string) {
+	spawningIssuesMu.Lock()
+	delete(spawningIssues, ref)
+	spawningIssuesMu.Unlock()
+}
+
+// parseIssueRef splits an "owner/repo#n" reference
+func parseIssueRef(ref string) (string, int, error) {
+	repo, num, ok := strings.Cut(ref, "#")
+	n, err := strconv.Atoi(num)
+	if !ok || err != nil || repo == "" {
+		return "", 0, fmt.Errorf("invalid issue reference %q", ref)
+	}
+	return repo, n, nil
+}
+
+// githubRepoDir finds the local checkout of a GitHub repository: the
+// directory of a session working on it, else <projects_dir>/<repo name>
+func githubRepoDir(config *Config, repo string) (string, error) {
+	var dirs []string
+	for _, info := range config.Sessions {
+		if info != nil && info.WorktreeRepo == "" {
+			dirs = append(dirs, info.Path)
+		}
+	}
+	sort.Strings(dirs)
+	_, name, _ := strings.Cut(repo, "/")
+	candidate := filepath.Join(getProjectsDir(config), name)
+	for _, dir := range append(dirs, candidate) {
+		if r, err := githubRepo(dir); err == nil && strings.EqualFold(r, repo) {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no checkout of %s found; clone it to %s", repo, candidate)
+}
+
+// postIssueComment comments on an issue through the API or the gh CLI
+func postIssueComment(config *Config, repo string, n int, body string) error {
+	if len(body) > maxIssueCommentLen {
+		body = body[:maxIssueCommentLen] + "\n\n…"
+	}
+	if config.GitHubToken != "" {
+		return githubAPI(config.GitHubToken, "POST", fmt.Sprintf("/repos/%s/issues/%d/comments", repo, n),
+			map[string]string{"body": body}, nil)
+	}
+	home, _ := os.UserHomeDir()
+	_, err := runGH(home, "issue", "comment", strconv.Itoa(n), "--repo", repo, "--body", body)
+	return err
+}
+
+// postIssueProgress posts Claude's answer for a completed turn to the issue
+// a session was started from
+func postIssueProgress(config *Config, sessName string, info *SessionInfo) {
+	repo, n, err := parseIssueRef(info.GitHubIssue)
+	if err != nil {
+		return
+	}
+	text := strings.TrimSpace(getLastAssistantMessage(sessionTranscript(info)))
+	if text == "" {
+		return
+	}
+	if err := postIssueComment(config, repo, n, fmt.Sprintf("✅ **%s**\n\n%s", sessName, text)); err != nil {
+		hookLog("github: session=%s comment on %s failed: %v", sessName, info.GitHubIssue, err)
+	}
+}
+
+// spawnIssueSession starts a session working on an issue and reports it on
+// the issue
+func spawnIssueSession(config *Config, repo string, issue githubIssue) {
+	ref := fmt.Sprintf("%s#%d", repo, issue.Number)
+	name := issueSessionName(repo, issue.Number)
+	if !claimIssueSpawn(ref) {
+		hookLog("github: %s is already starting session %s", ref, name)
+		return
+	}
+	defer releaseIssueSpawn(ref)
+	if _, exists := config.Sessions[name]; exists {
+		hookLog("github: %s already has session %s", ref, name)
+		postIssueComment(config, repo, issue.Number, fmt.Sprintf("ccc session `%s` already exists; not starting another.", name))
+		return
+	}
+	dir, err := githubRepoDir(config, repo)
+	if err != nil {
+		hookLog("github: %s: %v", ref, err)
+		postIssueComment(config, repo, issue.Number, fmt.Sprintf("⚠️ ccc could not start a session: %v", err))
+		return
+	}
+
+	hookLog("github: starting session %s for %s in %s", name, ref, dir)
+	if err := startDetached(name, dir, issuePrompt(&issue), startOptions{}); err != nil {
+		hookLog("github: session %s failed: %v", name, err)
+		postIssueComment(config, repo, issue.Number, fmt.Sprintf("⚠️ ccc could not start a session: %v", err))
+		return
+	}
+	fresh, err := loadConfig()
+	if err != nil {
+		return
+	}
+	if info := fresh.Sessions[name]; info != nil {
+		info.GitHubIssue = ref
+		if err := saveSession(name, info); err != nil {
+			hookLog("github: session %s: %v", name, err)
+		}
+	}
+	postIssueComment(config, repo, issue.Number, fmt.Sprintf("🚀 ccc started session `%s` on this issue. Progress is posted here as Claude completes each turn.", name))
+}
+
+// newGitHubWebhookHandler returns the handler for GitHub webhook deliveries.
+// spawn runs in the background for each issue labeled githubSpawnLabel by
+// one of the allowed logins.
+func newGitHubWebhookHandler(secret string, allowed []string, spawn func(repo string, issue githubIssue)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxResponseSize))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if !verifyGitHubSignature(secret, body, r.Header.Get("X-Hub-Signature-256")) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Header.Get("X-GitHub-Event") {
+		case "ping":
+			w.WriteHeader(http.StatusOK)
+			return
+		case "issues":
+		default:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		var event githubIssueEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			http.Error(w, "bad payload", http.StatusBadRequest)
+			return
+		}
+		if event.Action != "labeled" || event.Label.Name != githubSpawnLabel || event.Issue.Number == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !githubUserAllowed(allowed, event.Sender.Login) {
+			hookLog("github: ignoring label on %s#%d by %q, not in github_users", event.Repository.FullName, event.Issue.Number, event.Sender.Login)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		// GitHub gives up on deliveries after 10 seconds; Claude takes longer to start
+		go spawn(event.Repository.FullName, event.Issue)
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// runGitHubListener serves GitHub webhooks on port
+func runGitHubListener(port string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("not configured. Run: ccc setup <bot_token>")
+	}
+	if config.GitHubWebhookSecret == "" {
+		return fmt.Errorf("set a webhook secret first: ccc config github-webhook-secret <secret>")
+	}
+	if len(config.GitHubUsers) == 0 {
+		return fmt.Errorf("set who may start sessions first: ccc config github-users <login,...>")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/webhook", newGitHubWebhookHandler(config.GitHubWebhookSecret, config.GitHubUsers, func(repo string, issue githubIssue) {
+		fresh, err := loadConfig()
+		if err != nil {
+			hookLog("github: %v", err)
+			return
+		}
+		spawnIssueSession(fresh, repo, issue)
+	}))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	fmt.Printf("🐙 GitHub webhook listener on :%s/webhook\n", port)
+	fmt.Printf("   Issues labeled %q by %s start a session\n", githubSpawnLabel, strings.Join(config.GitHubUsers, ", "))
+	return http.ListenAndServe(":"+port, mux)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signGitHubPayload(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyGitHubSignature(t *testing.T) {
+	body := []byte(`{"action":"labeled"}`)
+	sig := signGitHubPayload("s3cret", string(body))
+
+	if !verifyGitHubSignature("s3cret", body, sig) {
+		t.Error("valid signature rejected")
+	}
+	if verifyGitHubSignature("other", body, sig) {
+		t.Error("signature under another secret accepted")
+	}
+	if verifyGitHubSignature("s3cret", body, strings.TrimPrefix(sig, "sha256=")) {
+		t.Error("signature without the sha256= prefix accepted")
+	}
+	if verifyGitHubSignature("", body, sig) {
+		t.Error("signature accepted without a secret")
+	}
+}
+
+func TestParseIssueRef(t *testing.T) {
+	repo, n, err := parseIssueRef("rsh3khar/ccc#123")
+	if err != nil || repo != "rsh3khar/ccc" || n != 123 {
+		t.Errorf("parseIssueRef() = %q, %d, %v", repo, n, err)
+	}
+	for _, ref := range []string{"rsh3khar/ccc", "#12", "rsh3khar/ccc#x"} {
+		if _, _, err := parseIssueRef(ref); err == nil {
+			t.Errorf("parseIssueRef(%q) succeeded", ref)
+		}
+	}
+}
+
+func TestIssueSessionName(t *testing.T) {
+	tests := []struct {
+		repo string
+		n    int
+		want string
+	}{
+		{"rsh3khar/ccc", 12, "ccc-issue-12"},
+		{"someone/CCC", 12, "ccc-issue-12"},
+		{"rsh3khar/other", 12, "other-issue-12"},
+	}
+	for _, tt := range tests {
+		if got := issueSessionName(tt.repo, tt.n); got != tt.want {
+			t.Errorf("issueSessionName(%q, %d) = %q, want %q", tt.repo, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestClaimIssueSpawn(t *testing.T) {
+	if !claimIssueSpawn("rsh3khar/ccc#12") {
+		t.Fatal("first delivery not allowed to start a session")
+	}
+	if claimIssueSpawn("rsh3khar/ccc#12") {
+		t.Error("duplicate delivery allowed while the session is starting")
+	}
+	if !claimIssueSpawn("rsh3khar/other#12") {
+		t.Error("same issue number in another repo blocked")
+	}
+	releaseIssueSpawn("rsh3khar/ccc#12")
+	releaseIssueSpawn("rsh3khar/other#12")
+	if !claimIssueSpawn("rsh3khar/ccc#12") {
+		t.Error("issue still blocked after its spawn finished")
+	}
+	releaseIssueSpawn("rsh3khar/ccc#12")
+}
+
+func TestGitHubWebhookHandler(t *testing.T) {
+	spawned := make(chan string, 1)
+	handler := newGitHubWebhookHandler("s3cret", []string{"Owner"}, func(repo string, issue githubIssue) {
+		spawned <- repo + "#" + issue.Title
+	})
+
+	labeledBy := func(label, login string) string {
+		return `{"action":"labeled","label":{"name":"` + label + `"},"issue":{"number":7,"title":"Fix it","body":"..."},"repository":{"full_name":"rsh3khar/ccc"},"sender":{"login":"` + login + `"}}`
+	}
+	labeled := func(label string) string { return labeledBy(label, "owner") }
+	tests := []struct {
+		name   string
+		event  string
+		body   string
+		sig    string
+		status int
+		spawn  bool
+	}{
+		{"ping", "ping", `{}`, "", http.StatusOK, false},
+		{"unsigned", "issues", labeled("ccc"), "sha256=00", http.StatusUnauthorized, false},
+		{"other label", "issues", labeled("bug"), "", http.StatusNoContent, false},
+		{"other event", "push", `{}`, "", http.StatusNoContent, false},
+		{"labeled ccc", "issues", labeled("ccc"), "", http.StatusAccepted, true},
+		{"labeled by someone else", "issues", labeledBy("ccc", "triager"), "", http.StatusNoContent, false},
+		{"labeled without a sender", "issues", labeledBy("ccc", ""), "", http.StatusNoContent, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/webhook", strings.NewReader(tt.body))
+			req.Header.Set("X-GitHub-Event", tt.event)
+			sig := tt.sig
+			if sig == "" {
+				sig = signGitHubPayload("s3cret", tt.body)
+			}
+			req.Header.Set("X-Hub-Signature-256", sig)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+
+			if !tt.spawn {
+				select {
+				case got := <-spawned:
+					t.Errorf("unexpected spawn for %s", got)
+				default:
+				}
+				return
+			}
+			select {
+			case got := <-spawned:
+				if got != "rsh3khar/ccc#Fix it" {
+					t.Errorf("spawned %q", got)
+				}
+			case <-time.After(2 * time.Second):
+				t.Error("no session spawned")
+			}
+		})
+	}
+}
@@ -1,3 +1,16 @@
 module github.com/rsh3khar/ccc
 
 go 1.18
+
+require (
+	github.com/gorilla/websocket v1.5.0
+	go.etcd.io/bbolt v1.3.8
+	golang.org/x/crypto v0.9.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/net v0.10.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.9.0 // indirect
+)
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sessions live in the main group (group_id) unless they were created in a
+// group registered with `ccc setgroup --name <name>`, which they remember in
+// their own group_id. Topic IDs are only unique within a group.
+
+// sessionGroup returns the group a session's topic is in
+func sessionGroup(config *Config, info *SessionInfo) int64 {
+	if info != nil && info.GroupID != 0 {
+		return info.GroupID
+	}
+	return config.GroupID
+}
+
+// sessionChat returns the group of a session by name
+func sessionChat(config *Config, sessName string) int64 {
+	return sessionGroup(config, config.Sessions[sessName])
+}
+
+// isSessionGroup reports whether a chat is the main group or a registered one
+func isSessionGroup(config *Config, chatID int64) bool {
+	if chatID == 0 {
+		return false
+	}
+	if chatID == config.GroupID {
+		return true
+	}
+	for _, id := range config.Groups {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
+}
+
+// newSessionGroup returns the group_id to store for a session created in a
+// chat: the chat when it is a registered group other than the main one
+func newSessionGroup(config *Config, chatID int64) int64 {
+	if chatID != config.GroupID && isSessionGroup(config, chatID) {
+		return chatID
+	}
+	return 0
+}
+
+// getSessionByChatTopic returns the session whose topic is topicID in a group
+func getSessionByChatTopic(config *Config, chatID, topicID int64) string {
+	for name, info := range config.Sessions {
+		if info != nil && info.TopicID == topicID && sessionGroup(config, info) == chatID {
+			return name
+		}
+	}
+	return ""
+}
+
+// registerGroup stores a group under a name; "" makes it the main group
+func registerGroup(config *Config, name string, chatID int64) error {
+	if name == "" {
+		config.GroupID = chatID
+		return saveConfig(config)
+	}
+	if strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("group name %q can't contain spaces", name)
+	}
+	if config.Groups == nil {
+		config.Groups = make(map[string]int64)
+	}
+	config.Groups[name] = chatID
+	return saveConfig(config)
+}
+
+// formatGroups lists the main and registered groups for `ccc config`
+func formatGroups(config *Config) string {
+	names := make([]string, 0, len(config.Groups))
+	for name := range config.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, config.Groups[name]))
+	}
+	return strings.Join(parts, ", ")
+}
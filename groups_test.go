@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestGetSessionByChatTopic(t *testing.T) {
+	config := &Config{
+		GroupID: -100,
+		Groups:  map[string]int64{"clientA": -200},
+		Sessions: map[string]*SessionInfo{
+			"personal": {TopicID: 5},
+			"client":   {TopicID: 5, GroupID: -200},
+		},
+	}
+	if got := getSessionByChatTopic(config, -100, 5); got != "personal" {
+		t.Errorf("topic 5 in the main group = %q, want personal", got)
+	}
+	if got := getSessionByChatTopic(config, -200, 5); got != "client" {
+		t.Errorf("topic 5 in clientA = %q, want client", got)
+	}
+	if got := getSessionByChatTopic(config, -300, 5); got != "" {
+		t.Errorf("topic 5 in an unknown group = %q, want none", got)
+	}
+}
+
+func TestNewSessionGroup(t *testing.T) {
+	config := &Config{GroupID: -100, Groups: map[string]int64{"clientA": -200}}
+	if got := newSessionGroup(config, -200); got != -200 {
+		t.Errorf("newSessionGroup(clientA) = %d", got)
+	}
+	if got := newSessionGroup(config, -100); got != 0 {
+		t.Errorf("newSessionGroup(main) = %d, want 0", got)
+	}
+	if got := newSessionGroup(config, -300); got != 0 {
+		t.Errorf("newSessionGroup(unregistered) = %d, want 0", got)
+	}
+	if got := sessionGroup(config, &SessionInfo{GroupID: -200}); got != -200 {
+		t.Errorf("sessionGroup() = %d", got)
+	}
+	if got := sessionGroup(config, nil); got != -100 {
+		t.Errorf("sessionGroup(nil) = %d", got)
+	}
+}
+
+func TestRegisterGroup(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", "")
+	config := &Config{GroupID: -100}
+	if err := registerGroup(config, "clientA", -200); err != nil {
+		t.Fatal(err)
+	}
+	if config.GroupID != -100 || config.Groups["clientA"] != -200 {
+		t.Errorf("after registering clientA: group %d, groups %v", config.GroupID, config.Groups)
+	}
+	if !isSessionGroup(config, -200) || isSessionGroup(config, -300) {
+		t.Error("isSessionGroup() doesn't follow the registered groups")
+	}
+	if err := registerGroup(config, "client A", -300); err == nil {
+		t.Error("registered a group name with a space")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxHandoffLen caps the summary handed to another session
+const maxHandoffLen = 20000
+
+// sessionTranscript returns the transcript of a session's conversation: the
+// one it resumes when known, else the latest in its directory
+func sessionTranscript(info *SessionInfo) string {
+	if info.ClaudeSessionID != "" {
+		path := transcriptFile(info.Path, info.ClaudeSessionID)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return latestTranscript(info.Path)
+}
+
+// handoffPrompt is what the target session is asked, with the source
+// session's last answer quoted
+func handoffPrompt(from, summary, instructions string) string {
+	summary = strings.TrimSpace(summary)
+	if len(summary) > maxHandoffLen {
+		summary = summary[:maxHandoffLen] + "\n…(truncated)"
+	}
+	if instructions == "" {
+		instructions = "Pick up from here."
+	}
+	return fmt.Sprintf("Handoff from the %s session. Its latest summary:\n\n---\n%s\n---\n\n%s", from, summary, instructions)
+}
+
+// parseHandoffArgs splits "/handoff <target> [instructions]"
+func parseHandoffArgs(arg string) (target, instructions string, err error) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return "", "", fmt.Errorf("usage: /handoff <target-session> [instructions]")
+	}
+	target = fields[0]
+	instructions = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(arg), target))
+	return target, instructions, nil
+}
+
+// buildHandoff returns the target session and the prompt that hands a
+// session's last answer to it
+func buildHandoff(config *Config, from, arg string) (string, string, error) {
+	target, instructions, err := parseHandoffArgs(arg)
+	if err != nil {
+		return "", "", err
+	}
+	if target == from {
+		return "", "", fmt.Errorf("can't hand off a session to itself")
+	}
+	if config.Sessions[target] == nil {
+		return "", "", fmt.Errorf("session '%s' not found", target)
+	}
+	summary := getLastAssistantMessage(sessionTranscript(config.Sessions[from]))
+	if strings.TrimSpace(summary) == "" {
+		return "", "", fmt.Errorf("%s has no answer to hand off yet", from)
+	}
+	return target, handoffPrompt(from, summary, instructions), nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseHandoffArgs(t *testing.T) {
+	target, instructions, err := parseHandoffArgs(" impl  build the parser as described ")
+	if err != nil || target != "impl" || instructions != "build the parser as described" {
+		t.Errorf("parseHandoffArgs() = %q, %q, %v", target, instructions, err)
+	}
+	if _, _, err := parseHandoffArgs("  "); err == nil {
+		t.Error("parseHandoffArgs without a target succeeded")
+	}
+}
+
+func TestBuildHandoff(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := filepath.Join(home, "research")
+	config := &Config{Sessions: map[string]*SessionInfo{
+		"research": {TopicID: 1, Path: workDir, ClaudeSessionID: "abc"},
+		"impl":     {TopicID: 2, Path: filepath.Join(home, "impl")},
+	}}
+
+	if _, _, err := buildHandoff(config, "research", "impl"); err == nil || !strings.Contains(err.Error(), "no answer") {
+		t.Errorf("handoff without a transcript: %v", err)
+	}
+
+	projDir := claudeProjectDir(workDir)
+	os.MkdirAll(projDir, 0755)
+	transcript := `{"type":"user","message":{"content":[{"type":"text","text":"look into it"}]}}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Use a Pratt parser."}]}}
+`
+	os.WriteFile(filepath.Join(projDir, "abc.jsonl"), []byte(transcript), 0600)
+
+	target, prompt, err := buildHandoff(config, "research", " impl build it")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "impl" {
+		t.Errorf("target = %q", target)
+	}
+	for _, want := range []string{"research session", "Use a Pratt parser.", "build it"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt %q missing %q", prompt, want)
+		}
+	}
+
+	if _, _, err := buildHandoff(config, "research", "research"); err == nil {
+		t.Error("handoff to itself succeeded")
+	}
+	if _, _, err := buildHandoff(config, "research", "missing"); err == nil {
+		t.Error("handoff to an unknown session succeeded")
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session modes: tmux sessions run an interactive Claude in a pane that the
+// monitor scrapes; headless sessions run one `claude -p --resume` per message
+const (
+	sessionModeTmux     = "tmux"
+	sessionModeHeadless = "headless"
+)
+
+// headlessTurnTimeout bounds one headless Claude run
+const headlessTurnTimeout = 30 * time.Minute
+
+// headlessBusy holds the sessions with a headless turn in flight and the
+// cancel func of the running claude (nil between queued turns)
+var (
+	headlessBusy   = make(map[string]context.CancelFunc)
+	headlessBusyMu sync.Mutex
+)
+
+// runSlotPollInterval is how often a headless turn waiting for a free slot rechecks
+const runSlotPollInterval = 2 * time.Second
+
+// runningTurns holds the headless sessions whose claude is running, with start times
+var (
+	runningTurns   = make(map[string]time.Time)
+	runningTurnsMu sync.Mutex
+)
+
+// busySession is a session Claude is currently working in
+type busySession struct {
+	Name     string
+	Started  time.Time
+	Headless bool
+}
+
+// busySessions lists headless runs and tmux turns in progress, oldest first
+func busySessions() []busySession {
+	runningTurnsMu.Lock()
+	defer runningTurnsMu.Unlock()
+	return busySessionsLocked()
+}
+
+// busySessionsLocked is busySessions with runningTurnsMu held
+func busySessionsLocked() []busySession {
+	var busy []busySession
+	for name, started := range runningTurns {
+		busy = append(busy, busySession{name, started, true})
+	}
+	monitorsMu.Lock()
+	for name, mon := range monitors {
+		if !mon.TurnStarted.IsZero() {
+			busy = append(busy, busySession{Name: name, Started: mon.TurnStarted})
+		}
+	}
+	monitorsMu.Unlock()
+	sort.Slice(busy, func(i, j int) bool { return busy[i].Started.Before(busy[j].Started) })
+	return busy
+}
+
+// acquireRunSlot blocks until fewer than max sessions are busy (0 = no limit)
+// and registers sessName as running. waiting is called once if it has to wait.
+func acquireRunSlot(max int, sessName string, waiting func(busy int)) {
+	notified := false
+	for {
+		runningTurnsMu.Lock()
+		busy := len(busySessionsLocked())
+		if max <= 0 || busy < max {
+			runningTurns[sessName] = time.Now()
+			runningTurnsMu.Unlock()
+			return
+		}
+		runningTurnsMu.Unlock()
+		if !notified {
+			waiting(busy)
+			notified = true
+		}
+		time.Sleep(runSlotPollInterval)
+	}
+}
+
+// releaseRunSlot frees the slot taken by acquireRunSlot
+func releaseRunSlot(sessName string) {
+	runningTurnsMu.Lock()
+	delete(runningTurns, sessName)
+	runningTurnsMu.Unlock()
+}
+
+// formatBusySessions renders the sessions Claude is working in for /stats
+func formatBusySessions(busy []busySession, max int, now time.Time) string {
+	limit := ""
+	if max > 0 {
+		limit = fmt.Sprintf(" (max %d)", max)
+	}
+	if len(busy) == 0 {
+		return "🤖 No sessions running" + limit
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🤖 %d running%s:", len(busy), limit)
+	for _, b := range busy {
+		kind := "tmux"
+		if b.Headless {
+			kind = "headless"
+		}
+		elapsed := now.Sub(b.Started)
+		if elapsed < time.Minute {
+			fmt.Fprintf(&sb, "\n  • %s (%s) — %ds", b.Name, kind, int(elapsed.Seconds()))
+		} else {
+			fmt.Fprintf(&sb, "\n  • %s (%s) — %s", b.Name, kind, formatDuration(elapsed))
+		}
+	}
+	return sb.String()
+}
+
+// isHeadless reports whether a session runs without tmux
+func isHeadless(info *SessionInfo) bool {
+	return info != nil && info.Mode == sessionModeHeadless
+}
+
+// headlessRunning reports whether a headless session is mid-turn
+func headlessRunning(sessName string) bool {
+	headlessBusyMu.Lock()
+	defer headlessBusyMu.Unlock()
+	_, busy := headlessBusy[sessName]
+	return busy
+}
+
+// stopHeadlessTurn cancels the claude run in flight for a session
+func stopHeadlessTurn(sessName string) bool {
+	headlessBusyMu.Lock()
+	defer headlessBusyMu.Unlock()
+	cancel := headlessBusy[sessName]
+	if cancel == nil {
+		return false
+	}
+	cancel()
+	return true
+}
+
+// setSessionMode switches a session between tmux and headless. Going headless
+// stops the tmux session; going back starts it with the conversation resumed.
+func setSessionMode(config *Config, name, mode string) error {
+	info := config.Sessions[name]
+	if info == nil {
+		return fmt.Errorf("session '%s' not found", name)
+	}
+	tmuxName := sessionName(name)
+	switch mode {
+	case sessionModeHeadless:
+		if tmuxSessionExists(tmuxName) {
+			killTmuxSession(tmuxName)
+		}
+		ClearSessionMonitor(name)
+		info.Mode = sessionModeHeadless
+	case sessionModeTmux:
+		if headlessRunning(name) {
+			return fmt.Errorf("a headless turn is still running")
+		}
+		info.Mode = ""
+		if !tmuxSessionExists(tmuxName) {
+			if _, err := os.Stat(info.Path); os.IsNotExist(err) {
+				os.MkdirAll(info.Path, 0755)
+			}
+			if err := createTmuxSession(tmuxName, info.Path, latestTranscript(info.Path) != ""); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown mode %q (use tmux or headless)", mode)
+	}
+	return saveSession(name, info)
+}
+
+// startHeadlessTurn runs a message in a headless session, queueing it while
+// an earlier one is still running
+func startHeadlessTurn(config *Config, msgr Messenger, chatID, threadID int64, sessName, text string) {
+	headlessBusyMu.Lock()
+	if _, busy := headlessBusy[sessName]; busy {
+		headlessBusyMu.Unlock()
+		ahead := enqueueMessage(sessName, text)
+		msgr.Send(chatID, threadID, fmt.Sprintf("%s (%d pending)", formatQueueWait(ahead, averageTurnDuration(sessName)), ahead+1))
+		return
+	}
+	headlessBusy[sessName] = nil
+	headlessBusyMu.Unlock()
+
+	go func() {
+		defer func() {
+			headlessBusyMu.Lock()
+			delete(headlessBusy, sessName)
+			headlessBusyMu.Unlock()
+		}()
+		for {
+			if fresh, err := loadConfig(); err == nil {
+				config = fresh
+			}
+			acquireRunSlot(config.MaxConcurrentSessions, sessName, func(busy int) {
+				msgr.Send(chatID, threadID, fmt.Sprintf("⏳ %d sessions already running (max %d) — this prompt starts when one finishes", busy, config.MaxConcurrentSessions))
+			})
+			started := time.Now()
+			if runHeadlessTurn(config, msgr, chatID, threadID, sessName, text) {
+				recordTurnDuration(sessName, time.Since(started))
+			}
+			releaseRunSlot(sessName)
+			next, ok := dequeueMessage(sessName)
+			if !ok {
+				return
+			}
+			msgr.Send(chatID, threadID, formatQueueAdvance(pendingCount(sessName), averageTurnDuration(sessName)))
+			text = next
+		}
+	}()
+}
+
+// streamEvent is one line of `claude -p --output-format stream-json`:
+// system init, assistant and user (tool result) messages, then a result
+type streamEvent struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	Result    string `json:"result"`
+	IsError   bool   `json:"is_error"`
+	Message   struct {
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+// chatLines returns what an assistant event shows in the topic: its text
+// and a "● Tool(summary)" line per tool call
+func (ev *streamEvent) chatLines() []string {
+	if ev.Type != "assistant" {
+		return nil
+	}
+	var parts []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	}
+	if json.Unmarshal(ev.Message.Content, &parts) != nil {
+		return nil
+	}
+	var lines []string
+	for _, p := range parts {
+		switch {
+		case p.Type == "text" && strings.TrimSpace(p.Text) != "":
+			lines = append(lines, strings.TrimSpace(p.Text))
+		case p.Type == "tool_use":
+			summary := summarizeToolInput(p.Name, []byte(fmt.Sprintf(`{"tool_input":%s}`, p.Input)))
+			lines = append(lines, formatToolLine(p.Name, summary))
+		}
+	}
+	return lines
+}
+
+// runHeadlessTurn runs one prompt with `claude -p`, resuming the session's
+// Claude conversation, and streams its text and tool calls to the topic as
+// they happen. It reports whether Claude ran to a result, so only such turns
+// count towards the queue's wait estimate.
+func runHeadlessTurn(config *Config, msgr Messenger, chatID, threadID int64, sessName, text string) bool {
+	fresh, err := loadConfig()
+	if err == nil {
+		config = fresh
+	}
+	info := config.Sessions[sessName]
+	if info == nil {
+		return false
+	}
+	if claudePath == "" {
+		msgr.Send(chatID, threadID, "❌ claude binary not found")
+		return false
+	}
+
+	SetSessionPrompt(sessName, text)
+	ctx, cancel := context.WithTimeout(context.Background(), headlessTurnTimeout)
+	headlessBusyMu.Lock()
+	headlessBusy[sessName] = cancel
+	headlessBusyMu.Unlock()
+	defer func() {
+		cancel()
+		headlessBusyMu.Lock()
+		if _, busy := headlessBusy[sessName]; busy {
+			headlessBusy[sessName] = nil
+		}
+		headlessBusyMu.Unlock()
+	}()
+	cmd := exec.CommandContext(ctx, claudePath, headlessArgs(info, text)...)
+	cmd.Dir = info.Path
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		msgr.Send(chatID, threadID, fmt.Sprintf("❌ Failed to run claude: %v", err))
+		return false
+	}
+
+	var result *streamEvent
+	var sessionID string
+	var other strings.Builder // anything that isn't an event, for error reports
+	sent := 0
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var ev streamEvent
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			other.WriteString(scanner.Text() + "\n")
+			continue
+		}
+		if ev.SessionID != "" {
+			sessionID = ev.SessionID
+		}
+		for _, line := range ev.chatLines() {
+			msgr.SendFormatted(chatID, threadID, line)
+			sent++
+		}
+		if ev.Type == "result" {
+			result = &ev
+		}
+	}
+	runErr := cmd.Wait()
+
+	if sessionID != "" && sessionID != info.ClaudeSessionID {
+		info.ClaudeSessionID = sessionID
+		saveSession(sessName, info)
+	}
+	if ctx.Err() == context.Canceled {
+		msgr.Send(chatID, threadID, "🛑 Stopped")
+		return false
+	}
+	if result == nil {
+		msg := strings.TrimSpace(other.String() + "\n" + stderr.String())
+		if ctx.Err() == context.DeadlineExceeded {
+			msg = fmt.Sprintf("⏱️ Timeout (%s)\n\n%s", formatDuration(headlessTurnTimeout), msg)
+		} else if runErr != nil {
+			msg = fmt.Sprintf("⚠️ %s\n\nExit: %v", msg, runErr)
+		}
+		msgr.Send(chatID, threadID, msg)
+		notifyTurnComplete(config, sessName, info, "⚠️ Failed:")
+		return false
+	}
+	if result.IsError {
+		msgr.Send(chatID, threadID, "⚠️ "+strings.TrimSpace(result.Result))
+		notifyTurnComplete(config, sessName, info, "⚠️ Failed:")
+		return true
+	}
+	// The answer was streamed already; the completion only repeats it when nothing was
+	done := completionHeader(config, sessName)
+	if sent == 0 {
+		done += result.Result
+	}
+	msgr.SendFormatted(chatID, threadID, strings.TrimSpace(done))
+	notifyTurnComplete(config, sessName, info, "✅ Finished:")
+	return true
+}
+
+// headlessArgs builds the claude arguments for a headless turn: resume the
+// session's conversation, or continue the directory's latest one the first
+// time, with the session's extra flags. A session ID without a transcript
+// yet (ccc start --headless) starts a new conversation under that ID.
+func headlessArgs(info *SessionInfo, text string) []string {
+	args := append([]string{"--dangerously-skip-permissions"}, info.ClaudeFlags...)
+	args = append(args, "-p", text, "--output-format", "stream-json", "--verbose")
+	if info.ClaudeSessionID != "" {
+		if _, err := os.Stat(transcriptFile(info.Path, info.ClaudeSessionID)); os.IsNotExist(err) {
+			return append(args, "--session-id", info.ClaudeSessionID)
+		}
+		args = append(args, "--resume", info.ClaudeSessionID)
+	} else if latestTranscript(info.Path) != "" {
+		args = append(args, "--continue")
+	}
+	return args
+}
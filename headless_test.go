@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHeadlessArgs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	workDir := t.TempDir()
+
+	info := &SessionInfo{Path: workDir}
+	want := []string{"--dangerously-skip-permissions", "-p", "hi", "--output-format", "stream-json", "--verbose"}
+	if got := headlessArgs(info, "hi"); !reflect.DeepEqual(got, want) {
+		t.Errorf("new conversation args = %v, want %v", got, want)
+	}
+
+	// An existing transcript is continued the first time
+	projDir := claudeProjectDir(workDir)
+	os.MkdirAll(projDir, 0755)
+	os.WriteFile(filepath.Join(projDir, "old.jsonl"), []byte("{}\n"), 0600)
+	if got := headlessArgs(info, "hi"); got[len(got)-1] != "--continue" {
+		t.Errorf("args with a transcript = %v, want --continue", got)
+	}
+
+	// A session ID without a transcript starts that conversation
+	info.ClaudeSessionID = "abc-123"
+	got := headlessArgs(info, "hi")
+	if got[len(got)-2] != "--session-id" || got[len(got)-1] != "abc-123" {
+		t.Errorf("args with a new session ID = %v, want --session-id abc-123", got)
+	}
+
+	os.WriteFile(filepath.Join(projDir, "abc-123.jsonl"), []byte("{}\n"), 0600)
+	got = headlessArgs(info, "hi")
+	if got[len(got)-2] != "--resume" || got[len(got)-1] != "abc-123" {
+		t.Errorf("args with a session ID = %v, want --resume abc-123", got)
+	}
+}
+
+func TestSetSessionMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	config := &Config{Sessions: map[string]*SessionInfo{"proj": {TopicID: 100, Path: tmpDir}}}
+
+	if err := setSessionMode(config, "missing", sessionModeHeadless); err == nil {
+		t.Error("setSessionMode on unknown session should fail")
+	}
+	if err := setSessionMode(config, "proj", "vm"); err == nil {
+		t.Error("setSessionMode with unknown mode should fail")
+	}
+	if err := setSessionMode(config, "proj", sessionModeHeadless); err != nil {
+		t.Fatal(err)
+	}
+	if !isHeadless(config.Sessions["proj"]) {
+		t.Error("session should be headless")
+	}
+
+	stored, err := loadSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isHeadless(stored["proj"]) {
+		t.Errorf("headless mode not saved: %+v", stored["proj"])
+	}
+}
+
+func TestStreamEventChatLines(t *testing.T) {
+	lines := []string{
+		`{"type":"system","subtype":"init","session_id":"s1"}`,
+		`{"type":"assistant","session_id":"s1","message":{"content":[{"type":"text","text":"Let me look."},{"type":"tool_use","name":"Bash","input":{"command":"ls"}}]}}`,
+		`{"type":"user","session_id":"s1","message":{"content":[{"type":"tool_result","content":"a.go"}]}}`,
+		`{"type":"assistant","session_id":"s1","message":{"content":[{"type":"text","text":"  \n"}]}}`,
+		`{"type":"result","subtype":"success","session_id":"s1","result":"Done.","is_error":false}`,
+	}
+	var got []string
+	for _, l := range lines {
+		var ev streamEvent
+		if err := json.Unmarshal([]byte(l), &ev); err != nil {
+			t.Fatalf("unmarshal %s: %v", l, err)
+		}
+		got = append(got, ev.chatLines()...)
+	}
+	want := []string{"Let me look.", "● Bash(ls)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("chat lines = %q, want %q", got, want)
+	}
+}
+
+func TestStopHeadlessTurn(t *testing.T) {
+	if stopHeadlessTurn("idle-sess") {
+		t.Error("stopHeadlessTurn with nothing running should report false")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	headlessBusyMu.Lock()
+	headlessBusy["busy-sess"] = cancel
+	headlessBusyMu.Unlock()
+	defer func() {
+		headlessBusyMu.Lock()
+		delete(headlessBusy, "busy-sess")
+		headlessBusyMu.Unlock()
+	}()
+
+	config := &Config{Sessions: map[string]*SessionInfo{"busy-sess": {Mode: sessionModeHeadless}}}
+	enqueueMessage("busy-sess", "next")
+	report := interruptSession(config, "busy-sess")
+	if ctx.Err() != context.Canceled {
+		t.Error("interruptSession did not cancel the run")
+	}
+	if !strings.Contains(report, "Cancelled") || !strings.Contains(report, "Dropped 1") {
+		t.Errorf("report = %q, want cancellation and dropped queue", report)
+	}
+	if pendingCount("busy-sess") != 0 {
+		t.Error("queued messages were not dropped")
+	}
+}
+
+func TestAcquireRunSlot(t *testing.T) {
+	// Turns other tests left in progress would count as busy
+	monitorsMu.Lock()
+	saved := monitors
+	monitors = make(map[string]*SessionMonitor)
+	monitorsMu.Unlock()
+	defer func() {
+		monitorsMu.Lock()
+		monitors = saved
+		monitorsMu.Unlock()
+	}()
+
+	acquireRunSlot(1, "first", func(int) { t.Error("first slot should not wait") })
+	busy := busySessions()
+	if len(busy) != 1 || busy[0].Name != "first" || !busy[0].Headless {
+		t.Fatalf("busySessions = %+v, want first", busy)
+	}
+
+	acquired := make(chan bool)
+	waited := make(chan int, 1)
+	go func() {
+		acquireRunSlot(1, "second", func(n int) { waited <- n })
+		acquired <- true
+	}()
+	if n := <-waited; n != 1 {
+		t.Errorf("second waited with %d busy, want 1", n)
+	}
+	releaseRunSlot("first")
+	select {
+	case <-acquired:
+	case <-time.After(3 * runSlotPollInterval):
+		t.Fatal("second never got the freed slot")
+	}
+	releaseRunSlot("second")
+	if busy := busySessions(); len(busy) != 0 {
+		t.Errorf("busySessions after release = %+v", busy)
+	}
+}
+
+func TestFormatBusySessions(t *testing.T) {
+	now := time.Now()
+	got := formatBusySessions([]busySession{
+		{Name: "api", Started: now.Add(-90 * time.Minute), Headless: true},
+		{Name: "web", Started: now.Add(-20 * time.Second)},
+	}, 2, now)
+	want := "🤖 2 running (max 2):\n  • api (headless) — 1h30m\n  • web (tmux) — 20s"
+	if got != want {
+		t.Errorf("formatBusySessions = %q, want %q", got, want)
+	}
+	if got := formatBusySessions(nil, 0, now); got != "🤖 No sessions running" {
+		t.Errorf("formatBusySessions(nil) = %q", got)
+	}
+}
+
+// recordingMessenger keeps the text of everything sent through it
+type recordingMessenger struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (m *recordingMessenger) record(text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, text)
+}
+
+func (m *recordingMessenger) texts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.sent...)
+}
+
+func (m *recordingMessenger) Send(chatID, threadID int64, text string) error {
+	m.record(text)
+	return nil
+}
+
+func (m *recordingMessenger) SendGetID(chatID, threadID int64, text string) (int64, error) {
+	m.record(text)
+	return 1, nil
+}
+
+func (m *recordingMessenger) Edit(chatID, messageID, threadID int64, text string) error {
+	return nil
+}
+
+func (m *recordingMessenger) SendFormatted(chatID, threadID int64, text string) (int64, error) {
+	m.record(text)
+	return 1, nil
+}
+
+func (m *recordingMessenger) EditFormatted(chatID, messageID, threadID int64, text string) error {
+	return nil
+}
+
+func (m *recordingMessenger) SendWithKeyboard(chatID, threadID int64, text string, buttons [][]InlineKeyboardButton) error {
+	m.record(text)
+	return nil
+}
+
+func (m *recordingMessenger) SendFile(chatID, threadID int64, filePath string, caption string) error {
+	return nil
+}
+
+func (m *recordingMessenger) CreateTopic(name string) (int64, error) { return 0, nil }
+
+func (m *recordingMessenger) DeleteTopic(topicID int64) error { return nil }
+
+func TestHeadlessQueueEstimates(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv(configPathEnv, filepath.Join(tmpDir, "config.json"))
+	fake := filepath.Join(tmpDir, "claude")
+	os.WriteFile(fake, []byte("#!/bin/sh\nsleep 0.2\necho '{\"type\":\"result\",\"result\":\"done\"}'\n"), 0755)
+	origClaude := claudePath
+	claudePath = fake
+	defer func() { claudePath = origClaude }()
+
+	info := &SessionInfo{TopicID: 100, Path: tmpDir, Mode: sessionModeHeadless}
+	config := &Config{BotToken: "test", Sessions: map[string]*SessionInfo{"hl": info}}
+	saveConfig(config)
+	saveSession("hl", info)
+	turnDurationsMu.Lock()
+	delete(turnDurations, "hl")
+	turnDurationsMu.Unlock()
+
+	waitIdle := func() {
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			headlessBusyMu.Lock()
+			_, busy := headlessBusy["hl"]
+			headlessBusyMu.Unlock()
+			if !busy {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatal("headless turns did not finish")
+	}
+
+	// The first turn gives the session a duration to estimate from
+	msgr := &recordingMessenger{}
+	startHeadlessTurn(config, msgr, 1, 100, "hl", "first")
+	waitIdle()
+	if averageTurnDuration("hl") <= 0 {
+		t.Fatal("a headless turn recorded no duration")
+	}
+
+	msgr = &recordingMessenger{}
+	startHeadlessTurn(config, msgr, 1, 100, "hl", "second")
+	startHeadlessTurn(config, msgr, 1, 100, "hl", "third")
+	startHeadlessTurn(config, msgr, 1, 100, "hl", "fourth")
+	waitIdle()
+
+	sent := strings.Join(msgr.texts(), "\n")
+	for _, want := range []string{
+		"⏳ queued, 0 ahead, <1 min (1 pending)",
+		"⏳ queued, 1 ahead, <1 min (2 pending)",
+		"▶️ Next queued prompt started, 1 still queued, last in <1 min",
+		"▶️ Next queued prompt started\n",
+	} {
+		if !strings.Contains(sent+"\n", want) {
+			t.Errorf("messages missing %q:\n%s", want, sent)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Where a Telegram command works
+const (
+	inPrivate = 1 << iota // private chat with the bot
+	inGroup               // the group outside any topic
+	inTopic               // a session topic
+
+	anywhere = inPrivate | inGroup | inTopic
+)
+
+// botCommand describes a Telegram command for /help and the slash menu
+type botCommand struct {
+	Name        string
+	Args        string
+	Description string
+	Where       int
+}
+
+// botCommands is every Telegram command the listener handles, in menu order
+var botCommands = []botCommand{
+	{"new", "<name>", "Create a session with its own topic (add --worktree <repo> [branch] for a git worktree)", inGroup | inTopic},
+	{"new", "", "Restart this topic's session (kills it if running)", inTopic},
+	{"continue", "", "Restart this session keeping the conversation history", inTopic},
+	{"restart_claude", "", "Restart only Claude, keeping the tmux window and scrollback", inTopic},
+	{"catchup", "[n]", "Recap the last n messages and current status", inTopic},
+	{"tail", "[lines|on|off]", "Raw tmux pane output, once or as a live pinned message", inTopic},
+	{"git", "<status|diff|log|push|pull>", "Run git in this session's directory", inTopic},
+	{"export", "", "Zip of the conversation: transcripts, block cache and a Markdown log", inTopic},
+	{"handoff", "<session> [instructions]", "Send this session's last answer to another session as a prompt", inTopic},
+	{"pr", "[title]", "Push the session's branch and open a GitHub pull request", inTopic},
+	{"issues", "<n>", "Send a GitHub issue to Claude as a prompt", inTopic},
+	{"gate", "[command|off]", "Run a test command after each completed turn; failures go back to Claude", inTopic},
+	{"autocommit", "[on|off|push <remote>[/<branch>]|push off]", "Git checkpoint commit after each completed turn, optionally pushed", inTopic},
+	{"mode", "[tmux|headless]", "Run Claude in tmux or one claude -p per message", inTopic},
+	{"verbose", "[on|off]", "One message per block (on) or blocks combined into one (off)", inTopic},
+	{"checkpoint", "[label]", "Save this session's work tree and conversation to go back to", inTopic},
+	{"rewind", "[label]", "Restore a checkpoint and resume Claude from there (lists them without a label)", inTopic},
+	{"plan", "[on|off]", "Plan mode: Claude sends a plan to approve before changing anything", inTopic},
+	{"model", "[name|default]", "Model Claude runs with in this session", inTopic},
+	{"flags", "[flags|off]", "Extra claude flags for this session, e.g. --permission-mode plan", inTopic},
+	{"merge", "", "Merge this worktree session's branch back into its repository", inTopic},
+	{"schedule", "<cron> <prompt>", "Fire a prompt into this session on a cron schedule", inTopic},
+	{"unschedule", "<id>", "Remove a scheduled prompt", inTopic},
+	{"delete", "", "Delete this session and its topic", inTopic},
+	{"list", "", "List sessions with status and Restart / Kill / Peek buttons", anywhere},
+	{"schedules", "", "List scheduled prompts", anywhere},
+	{"cost", "", "Token usage and estimated cost", anywhere},
+	{"digest", "[now]", "Activity digest of the last 24 hours: prompts, commits and cost per session", anywhere},
+	{"default", "[name|off]", "Show or set the session that messages in the group's General area go to", anywhere},
+	{"search", "<query>", "Search every transcript and session directory, with buttons to jump to the sessions", anywhere},
+	{"c", "<cmd>", "Run a shell command on your machine", anywhere},
+	{"stop", "", "Stop the /c command running here, or Claude's current turn", anywhere},
+	{"json", "<status|sessions|peek name>", "Command results as JSON for automation", anywhere},
+	{"stats", "", "System stats and the sessions Claude is working in", anywhere},
+	{"machines", "", "Machines sharing this bot and whether they're reachable", anywhere},
+	{"logs", "[lines]", "Last lines of the ccc log (default 20)", anywhere},
+	{"away", "[on|off|auto|schedule <spec>|idle <hours>]", "Whether notifications reach you here", anywhere},
+	{"cleanup", "", "Delete ALL sessions and their topics", inGroup | inPrivate},
+	{"gc", "[remove]", "Find tmux sessions and topics that drifted from the config", inGroup | inPrivate},
+	{"update", "[stable|beta|<tag>]", "Update the ccc binary from GitHub (checksum verified)", anywhere},
+	{"rollback", "", "Go back to the binary the last update replaced", anywhere},
+	{"restart", "", "Restart the ccc service", anywhere},
+	{"version", "", "Show the ccc version", anywhere},
+	{"auth", "", "Re-authenticate Claude (OAuth)", anywhere},
+	{"cancel", "", "Abort an in-progress /auth", anywhere},
+	{"help", "", "Show the commands that work here", anywhere},
+}
+
+// menuCommands returns the slash-menu entries for commands that work in any
+// of the given contexts, one per name
+func menuCommands(where int) []map[string]string {
+	var commands []map[string]string
+	seen := make(map[string]bool)
+	for _, c := range botCommands {
+		if c.Where&where == 0 || seen[c.Name] {
+			continue
+		}
+		seen[c.Name] = true
+		desc := c.Description
+		if c.Args != "" {
+			desc = fmt.Sprintf("%s %s", c.Args, desc)
+		}
+		commands = append(commands, map[string]string{"command": c.Name, "description": truncate(desc, 256)})
+	}
+	return commands
+}
+
+// formatHelp lists commands grouped by where they work, starting with the
+// context the user asked from
+func formatHelp(current int) string {
+	sections := []struct {
+		where int
+		title string
+	}{
+		{inTopic, "In a session topic"},
+		{inGroup, "In the group (outside topics)"},
+		{inPrivate, "In private chat"},
+	}
+	for i, s := range sections {
+		if s.where == current {
+			sections[0], sections[i] = sections[i], sections[0]
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📖 ccc commands\n")
+	for _, s := range sections {
+		title := s.title
+		if s.where == current {
+			title += " (here)"
+		}
+		sb.WriteString(fmt.Sprintf("\n%s:\n", title))
+		for _, c := range botCommands {
+			if c.Where&s.where == 0 || (c.Where == anywhere && s.where != current) {
+				continue
+			}
+			usage := "/" + c.Name
+			if c.Args != "" {
+				usage += " " + c.Args
+			}
+			sb.WriteString(fmt.Sprintf("%s — %s\n", usage, c.Description))
+		}
+	}
+	sb.WriteString("\nCommands available everywhere are listed once, under the first section.")
+	switch current {
+	case inTopic:
+		sb.WriteString("\nAnything else you send here goes to Claude.")
+	case inPrivate, inGroup:
+		sb.WriteString("\nAnything else you send here runs a one-shot Claude query.")
+	}
+	return sb.String()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMenuCommands(t *testing.T) {
+	validName := regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+	for _, where := range []int{anywhere, inPrivate, inGroup | inTopic} {
+		seen := make(map[string]bool)
+		for _, c := range menuCommands(where) {
+			if !validName.MatchString(c["command"]) {
+				t.Errorf("invalid Telegram command name %q", c["command"])
+			}
+			if seen[c["command"]] {
+				t.Errorf("duplicate menu entry %q", c["command"])
+			}
+			seen[c["command"]] = true
+			if len(c["description"]) > 256 {
+				t.Errorf("description of %q too long", c["command"])
+			}
+		}
+	}
+
+	private := make(map[string]bool)
+	for _, c := range menuCommands(inPrivate) {
+		private[c["command"]] = true
+	}
+	if private["tail"] || private["new"] {
+		t.Error("topic/group commands should not be in the private chat menu")
+	}
+	if !private["list"] || !private["help"] {
+		t.Error("private chat menu missing commands that work there")
+	}
+}
+
+func TestFormatHelp(t *testing.T) {
+	topic := formatHelp(inTopic)
+	if !strings.Contains(topic, "In a session topic (here):") || !strings.Contains(topic, "/tail") {
+		t.Errorf("topic help should lead with topic commands:\n%s", topic)
+	}
+	if strings.Index(topic, "In a session topic") > strings.Index(topic, "In private chat") {
+		t.Error("current context should be listed first")
+	}
+
+	private := formatHelp(inPrivate)
+	if !strings.HasPrefix(strings.SplitN(private, "\n\n", 2)[1], "In private chat (here):") {
+		t.Errorf("private help should lead with private commands:\n%s", private)
+	}
+	// Commands that work everywhere appear once
+	if strings.Count(private, "/version") != 1 {
+		t.Error("/version should be listed once")
+	}
+}
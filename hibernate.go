@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hibernateSnapshotLines is how much of a pane is kept when its session
+// hibernates
+const hibernateSnapshotLines = 2000
+
+// hibernateSnapshotPath is where the pane of a hibernated session is kept
+func hibernateSnapshotPath(sessName string) string {
+	return filepath.Join(stateFile("-hibernated"), sessName+".txt")
+}
+
+// hibernateAfter returns how long a session must be idle to hibernate, 0 if
+// hibernation is off
+func hibernateAfter(config *Config) time.Duration {
+	return time.Duration(config.HibernateHours) * time.Hour
+}
+
+// shouldHibernate reports whether a session has been idle long enough to
+// hibernate: its turn is over, nothing is queued or waiting for the usage
+// limit, and there was no activity for HibernateHours
+func shouldHibernate(config *Config, sessName string, mon *SessionMonitor, now time.Time) bool {
+	after := hibernateAfter(config)
+	if after <= 0 || pendingCount(sessName) > 0 || usageLimited(sessName) {
+		return false
+	}
+	monitorsMu.Lock()
+	defer monitorsMu.Unlock()
+	last := mon.LastActivity
+	if mon.LastUserMessage.After(last) {
+		last = mon.LastUserMessage
+	}
+	return mon.Completed && now.Sub(last) >= after
+}
+
+// tmuxSessionAttached reports whether a terminal is attached to a tmux session
+func tmuxSessionAttached(tmuxName string) bool {
+	out, err := exec.Command(tmuxPath, "display-message", "-p", "-t", tmuxName, "#{session_attached}").Output()
+	return err == nil && strings.TrimSpace(string(out)) != "0"
+}
+
+// hibernateSession keeps a copy of a session's pane, stops its tmux session
+// and marks it hibernated so the next message resumes the conversation
+func hibernateSession(config *Config, sessName string, info *SessionInfo) error {
+	tmuxName := sessionName(sessName)
+	if pane, err := capturePaneTail(tmuxName, hibernateSnapshotLines); err == nil {
+		path := hibernateSnapshotPath(sessName)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, []byte(pane+"\n"), 0600); err != nil {
+			hookLog("hibernate: session=%s saving pane: %v", sessName, err)
+		}
+	}
+	if err := killTmuxSession(tmuxName); err != nil {
+		return err
+	}
+	info.Hibernated = true
+	if err := saveSession(sessName, info); err != nil {
+		return err
+	}
+	// The block cache stays, so output Claude shows again on resume isn't resent
+	monitorsMu.Lock()
+	delete(monitors, sessName)
+	monitorsMu.Unlock()
+	return nil
+}
+
+// checkHibernation hibernates a session that has been idle for
+// HibernateHours, unless Claude is busy or someone is attached to it. It
+// reports whether the session was hibernated.
+func checkHibernation(config *Config, sessName string, info *SessionInfo, mon *SessionMonitor, tmuxName string) bool {
+	if isHeadless(info) || !shouldHibernate(config, sessName, mon, time.Now()) {
+		return false
+	}
+	if tmuxSessionAttached(tmuxName) || !isClaudeIdle(tmuxName) {
+		return false
+	}
+	if err := hibernateSession(config, sessName, info); err != nil {
+		hookLog("hibernate: session=%s failed: %v", sessName, err)
+		return false
+	}
+	hookLog("hibernate: session=%s hibernated after %s idle", sessName, formatDuration(hibernateAfter(config)))
+	getMessenger(config).Send(sessionGroup(config, info), info.TopicID, fmt.Sprintf("🛌 Session '%s' hibernated after %s idle to free memory. Your next message here resumes the conversation.", sessName, formatDuration(hibernateAfter(config))))
+	return true
+}
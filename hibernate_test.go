@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShouldHibernate(t *testing.T) {
+	now := time.Now()
+	config := &Config{HibernateHours: 2}
+	idle := func(ago time.Duration) *SessionMonitor {
+		return &SessionMonitor{Completed: true, LastActivity: now.Add(-ago), LastUserMessage: now.Add(-ago)}
+	}
+
+	if !shouldHibernate(config, "hib-test", idle(3*time.Hour), now) {
+		t.Error("a session idle for 3 hours should hibernate after 2")
+	}
+	if shouldHibernate(config, "hib-test", idle(time.Hour), now) {
+		t.Error("a session idle for an hour should not hibernate yet")
+	}
+	if shouldHibernate(&Config{}, "hib-test", idle(48*time.Hour), now) {
+		t.Error("hibernation should be off by default")
+	}
+
+	busy := idle(3 * time.Hour)
+	busy.Completed = false
+	if shouldHibernate(config, "hib-test", busy, now) {
+		t.Error("a session in the middle of a turn should not hibernate")
+	}
+
+	recent := idle(3 * time.Hour)
+	recent.LastUserMessage = now.Add(-time.Minute)
+	if shouldHibernate(config, "hib-test", recent, now) {
+		t.Error("a message sent a minute ago should keep the session awake")
+	}
+
+	enqueueMessage("hib-test", "later")
+	defer clearPendingMessages("hib-test")
+	if shouldHibernate(config, "hib-test", idle(3*time.Hour), now) {
+		t.Error("a session with queued messages should not hibernate")
+	}
+}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -65,10 +70,20 @@ func handlePermissionHook() error {
 		}
 	}
 
-	if sessionName == "" || config.GroupID == 0 {
+	if sessionName == "" || !hasSessionChannel(config) {
 		return nil
 	}
 
+	// Permission dialogs; AskUserQuestion is answered through hook-question and
+	// plans through hook-plan instead.
+	// hook-permission also gates tools when set up as a PreToolUse hook.
+	if hookData.HookEventName == "PermissionRequest" || (hookData.HookEventName == "PreToolUse" && hookData.ToolName != "AskUserQuestion") {
+		if hookData.ToolName == "" || hookData.ToolName == "AskUserQuestion" || hookData.ToolName == "ExitPlanMode" {
+			return nil
+		}
+		return requestPermission(config, sessionName, topicID, hookData.HookEventName, hookData.ToolName, rawData)
+	}
+
 	// Handle AskUserQuestion
 	if hookData.ToolName == "AskUserQuestion" && len(hookData.ToolInput.Questions) > 0 {
 		go func() {
@@ -95,7 +110,7 @@ func handlePermissionHook() error {
 				}
 
 				if len(buttons) > 0 {
-					sendMessageWithKeyboard(config, config.GroupID, topicID, msg, buttons)
+					sendQuestion(config, sessionName, topicID, msg, buttons)
 				}
 			}
 		}()
@@ -105,6 +120,197 @@ func handlePermissionHook() error {
 	return nil
 }
 
+// permissionCallbackPrefix marks permission buttons. Callback data is
+// "perm:<requestID>:<allow|deny|always>".
+const permissionCallbackPrefix = "perm:"
+
+// How long the hook waits for a button press before leaving the decision to
+// the terminal dialog: permission_timeout, 5 minutes by default. The installed
+// hook timeout covers the maximum, so changing it needs no reinstall.
+const (
+	defaultPermissionTimeout = 5 * time.Minute
+	maxPermissionTimeout     = time.Hour
+)
+
+// permissionTimeout returns how long permission hooks wait for a button
+func permissionTimeout(config *Config) time.Duration {
+	if config.PermissionTimeout <= 0 {
+		return defaultPermissionTimeout
+	}
+	if d := time.Duration(config.PermissionTimeout) * time.Second; d < maxPermissionTimeout {
+		return d
+	}
+	return maxPermissionTimeout
+}
+
+// validPermissionRequestID reports whether id is one requestPermission makes:
+// 8 hex digits. IDs come back in button data, so check them before they name a file.
+func validPermissionRequestID(id string) bool {
+	if len(id) != 8 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func permissionDecisionPath(requestID string) string {
+	return filepath.Join(os.TempDir(), "ccc-perm-"+requestID)
+}
+
+// summarizeToolInput returns a short description of what a tool is about to do
+func summarizeToolInput(toolName string, rawData []byte) string {
+	var data struct {
+		ToolInput map[string]interface{} `json:"tool_input"`
+	}
+	if json.Unmarshal(rawData, &data) != nil || len(data.ToolInput) == 0 {
+		return ""
+	}
+	for _, key := range []string{"command", "file_path", "url", "pattern"} {
+		if v, ok := data.ToolInput[key].(string); ok && v != "" {
+			return truncate(v, 500)
+		}
+	}
+	compact, _ := json.Marshal(data.ToolInput)
+	return truncate(string(compact), 500)
+}
+
+// permissionHookOutput is the PermissionRequest hook response telling Claude the decision
+func permissionHookOutput(behavior string) []byte {
+	out, _ := json.Marshal(map[string]interface{}{
+		"hookSpecificOutput": map[string]interface{}{
+			"hookEventName": "PermissionRequest",
+			"decision":      map[string]string{"behavior": behavior},
+		},
+	})
+	return out
+}
+
+// preToolUseHookOutput is the PreToolUse hook response: {"decision":"approve"}
+// or {"decision":"block"} with the reason Claude is shown
+func preToolUseHookOutput(behavior string) []byte {
+	out := map[string]string{"decision": "approve"}
+	if behavior == "deny" {
+		out = map[string]string{"decision": "block", "reason": "Denied from Telegram"}
+	}
+	data, _ := json.Marshal(out)
+	return data
+}
+
+// writePermissionDecision prints a decision in the format of the hook event
+func writePermissionDecision(event, behavior string) {
+	if event == "PreToolUse" {
+		os.Stdout.Write(preToolUseHookOutput(behavior))
+		return
+	}
+	os.Stdout.Write(permissionHookOutput(behavior))
+}
+
+// requestPermission asks in Telegram whether Claude may use a tool and waits for
+// Approve / Deny / Always allow. Tools marked "always" are approved without asking.
+// On timeout nothing is printed, so the terminal's own dialog stays in charge.
+func requestPermission(config *Config, sessName string, topicID int64, event, toolName string, rawData []byte) error {
+	info := config.Sessions[sessName]
+	for _, allowed := range info.AlwaysAllowTools {
+		if allowed == toolName {
+			writePermissionDecision(event, "allow")
+			return nil
+		}
+	}
+
+	// Without pressable buttons the prompt would only stall the tool call
+	// until it times out: let the terminal decide right away
+	msgr := getMessenger(config)
+	if !hasButtons(config) {
+		msgr.Send(sessionChat(config, sessName), topicID, fmt.Sprintf("🔐 Permission requested: %s (answer in the terminal)", toolName))
+		return nil
+	}
+
+	idBytes := make([]byte, 4)
+	rand.Read(idBytes)
+	requestID := hex.EncodeToString(idBytes)
+	decisionPath := permissionDecisionPath(requestID)
+	defer os.Remove(decisionPath)
+
+	msg := fmt.Sprintf("🔐 Permission requested: %s", toolName)
+	if summary := summarizeToolInput(toolName, rawData); summary != "" {
+		msg += "\n\n" + summary
+	}
+	buttons := [][]InlineKeyboardButton{{
+		{Text: "✅ Approve", CallbackData: permissionCallbackPrefix + requestID + ":allow"},
+		{Text: "❌ Deny", CallbackData: permissionCallbackPrefix + requestID + ":deny"},
+		{Text: "♾ Always allow", CallbackData: permissionCallbackPrefix + requestID + ":always"},
+	}}
+	if err := msgr.SendWithKeyboard(sessionChat(config, sessName), topicID, msg, buttons); err != nil {
+		return nil
+	}
+
+	decision, err := awaitPermissionDecision(requestID, decisionPath, permissionTimeout(config))
+	if err != nil {
+		return nil
+	}
+	switch decision {
+	case "allow":
+		writePermissionDecision(event, "allow")
+	case "always":
+		info.AlwaysAllowTools = append(info.AlwaysAllowTools, toolName)
+		saveSession(sessName, info)
+		writePermissionDecision(event, "allow")
+	case "deny":
+		writePermissionDecision(event, "deny")
+	}
+	return nil
+}
+
+// awaitPermissionDecision waits for the button press through the listener's
+// control socket, polling the decision file if the listener can't be reached
+func awaitPermissionDecision(requestID, decisionPath string, timeout time.Duration) (string, error) {
+	var result struct {
+		Decision string `json:"decision"`
+	}
+	params := map[string]interface{}{"request_id": requestID, "timeout": int(timeout / time.Second)}
+	err := callControl("permission.wait", params, &result, timeout+5*time.Second)
+	if err != errControlUnavailable {
+		return result.Decision, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if data, err := os.ReadFile(decisionPath); err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return "", nil
+}
+
+// handlePermissionCallback records a permission button press for the waiting
+// hook and returns the text to show on the message
+func handlePermissionCallback(data string) (string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(data, permissionCallbackPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	requestID, decision := parts[0], parts[1]
+	if !validPermissionRequestID(requestID) {
+		return "", false
+	}
+	var label string
+	switch decision {
+	case "allow":
+		label = "✅ Approved"
+	case "deny":
+		label = "❌ Denied"
+	case "always":
+		label = "♾ Always allowed"
+	default:
+		return "", false
+	}
+	if err := deliverPermissionDecision(requestID, decision); err != nil {
+		return "", false
+	}
+	return label, true
+}
+
 func handlePromptHook() error {
 	// Legacy - now handled by monitor polling
 	return nil
@@ -144,7 +350,7 @@ func handleQuestionHook() error {
 		}
 	}
 
-	if sessionName == "" || config.GroupID == 0 || topicID == 0 {
+	if sessionName == "" || !hasSessionChannel(config) || topicID == 0 {
 		return nil
 	}
 
@@ -170,9 +376,9 @@ func handleQuestionHook() error {
 		}
 
 		if len(buttons) > 0 {
-			sendMessageWithKeyboard(config, config.GroupID, topicID, msg, buttons)
+			sendQuestion(config, sessionName, topicID, msg, buttons)
 		} else {
-			sendMessage(config, config.GroupID, topicID, msg)
+			getMessenger(config).Send(sessionChat(config, sessionName), topicID, msg)
 		}
 	}
 
@@ -234,9 +440,23 @@ func installHook() error {
 		hooks = make(map[string]interface{})
 	}
 
-	// Only install hooks for interactive features (AskUserQuestion)
-	// Output syncing is handled by the polling monitor
+	// Interactive features (AskUserQuestion, plan approval, permission prompts), plus the
+	// PostToolUse/Stop events the "hooks" monitor mode streams output from.
+	// hook-event exits at once when the listener isn't taking events.
+	// SessionStart/PreCompact record the Claude session and report compaction.
 	cccHooks := map[string][]interface{}{
+		"PermissionRequest": {
+			map[string]interface{}{
+				"hooks": []interface{}{
+					map[string]interface{}{
+						"command": cccPath + " hook-permission",
+						"type":    "command",
+						"timeout": int(maxPermissionTimeout/time.Second) + 10,
+					},
+				},
+				"matcher": "*",
+			},
+		},
 		"PreToolUse": {
 			map[string]interface{}{
 				"hooks": []interface{}{
@@ -247,11 +467,77 @@ func installHook() error {
 				},
 				"matcher": "AskUserQuestion",
 			},
+			map[string]interface{}{
+				"hooks": []interface{}{
+					map[string]interface{}{
+						"command": cccPath + " hook-plan",
+						"type":    "command",
+						"timeout": 10,
+					},
+				},
+				"matcher": "ExitPlanMode",
+			},
+		},
+		"PostToolUse": {
+			map[string]interface{}{
+				"hooks": []interface{}{
+					map[string]interface{}{
+						"command": cccPath + " hook-event",
+						"type":    "command",
+						"timeout": 5,
+					},
+				},
+				"matcher": "*",
+			},
+		},
+		"Stop": {
+			map[string]interface{}{
+				"hooks": []interface{}{
+					map[string]interface{}{
+						"command": cccPath + " hook-event",
+						"type":    "command",
+						"timeout": 5,
+					},
+				},
+			},
+		},
+		"SubagentStop": {
+			map[string]interface{}{
+				"hooks": []interface{}{
+					map[string]interface{}{
+						"command": cccPath + " hook-event",
+						"type":    "command",
+						"timeout": 5,
+					},
+				},
+			},
+		},
+		"SessionStart": {
+			map[string]interface{}{
+				"hooks": []interface{}{
+					map[string]interface{}{
+						"command": cccPath + " hook-lifecycle",
+						"type":    "command",
+						"timeout": 10,
+					},
+				},
+			},
+		},
+		"PreCompact": {
+			map[string]interface{}{
+				"hooks": []interface{}{
+					map[string]interface{}{
+						"command": cccPath + " hook-lifecycle",
+						"type":    "command",
+						"timeout": 30,
+					},
+				},
+			},
 		},
 	}
 
 	// Remove ALL existing ccc hooks from all hook types
-	allHookTypes := []string{"Stop", "Notification", "PermissionRequest", "PostToolUse", "PreToolUse", "UserPromptSubmit"}
+	allHookTypes := []string{"Stop", "Notification", "PermissionRequest", "PostToolUse", "PreToolUse", "UserPromptSubmit", "SessionStart", "PreCompact", "SubagentStop"}
 	for _, hookType := range allHookTypes {
 		if existing, ok := hooks[hookType].([]interface{}); ok {
 			filtered := removeCccHooks(existing)
@@ -307,7 +593,7 @@ func uninstallHook() error {
 		return nil
 	}
 
-	hookTypes := []string{"Stop", "Notification", "PermissionRequest", "PostToolUse", "PreToolUse", "UserPromptSubmit"}
+	hookTypes := []string{"Stop", "Notification", "PermissionRequest", "PostToolUse", "PreToolUse", "UserPromptSubmit", "SessionStart", "PreCompact", "SubagentStop"}
 	for _, hookType := range hookTypes {
 		if existing, ok := hooks[hookType].([]interface{}); ok {
 			filtered := removeCccHooks(existing)
@@ -358,6 +644,8 @@ ccc send <file_path>
 ## How it works
 - **Small files (< 50MB)**: Sent directly via Telegram
 - **Large files (≥ 50MB)**: Streamed via relay server with a one-time download link
+- **Directories**: Zipped on the fly and streamed via the relay
+- **Several paths**: One relay download page listing them all
 
 ## Examples
 
@@ -376,6 +664,32 @@ ccc send ./output/report.pdf
 ccc send ~/Downloads/large-file.zip
 ` + "```" + `
 
+### Send something sensitive (encrypted end to end through the relay)
+` + "```bash" + `
+ccc send --encrypt ./secrets-report.pdf
+` + "```" + `
+
+### Send a large file without waiting for the download (needs storage configured)
+` + "```bash" + `
+ccc send --store ./build/app.apk
+` + "```" + `
+
+### Send a folder, or several files at once
+` + "```bash" + `
+ccc send ./dist
+ccc send report.pdf chart.png data.csv
+` + "```" + `
+
+## Receiving files
+Files the user posts in the session's topic are saved into the project when they arrive. To fetch one again (e.g. after deleting it, or from an earlier message), use:
+
+` + "```bash" + `
+ccc receive            # list recent files with their message ids
+ccc receive --latest   # download the newest file into the current directory
+ccc receive --id 1234  # download a specific file
+ccc receive --relay    # post an upload link for files over 20 MB and wait for the upload
+` + "```" + `
+
 ## Important Notes
 - The command detects the current session from your working directory
 - For large files, the command will wait up to 10 minutes for the user to download
@@ -406,18 +720,94 @@ func truncate(s string, n int) string {
 	return s[:n] + "..."
 }
 
-// getLastAssistantMessage reads the transcript and returns the last assistant text
+// getLastAssistantMessage reads the transcript and returns the text of the last
+// assistant entry that has any, skipping tool calls
 func getLastAssistantMessage(transcriptPath string) string {
-	// Legacy - kept for compatibility but no longer actively used
-	return ""
+	f, err := os.Open(transcriptPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var last string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			Type    string `json:"type"`
+			Message struct {
+				Content []struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"message"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.Type != "assistant" {
+			continue
+		}
+		var parts []string
+		for _, c := range entry.Message.Content {
+			if c.Type == "text" && strings.TrimSpace(c.Text) != "" {
+				parts = append(parts, c.Text)
+			}
+		}
+		if len(parts) > 0 {
+			last = strings.Join(parts, "\n\n")
+		}
+	}
+	return last
 }
 
-// hookLog writes debug log entries
+// hookLog writes an info entry to the log
 func hookLog(format string, args ...interface{}) {
-	f, err := os.OpenFile("/tmp/ccc-hook-debug.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
+	logf(levelInfo, fmt.Sprintf(format, args...))
+}
+
+// questionCallback is a parsed AskUserQuestion button press
+type questionCallback struct {
+	Session        string
+	QuestionIndex  int
+	TotalQuestions int // 0 for the legacy format
+	OptionIndex    int
+}
+
+// parseQuestionCallback parses "session:questionIndex:totalQuestions:optionIndex"
+// (or the legacy "session:questionIndex:optionIndex") button data
+func parseQuestionCallback(data string) (*questionCallback, bool) {
+	parts := strings.Split(data, ":")
+	if len(parts) < 3 {
+		return nil, false
+	}
+	qc := &questionCallback{Session: parts[0]}
+	qc.QuestionIndex, _ = strconv.Atoi(parts[1])
+	if len(parts) == 4 {
+		qc.TotalQuestions, _ = strconv.Atoi(parts[2])
+		qc.OptionIndex, _ = strconv.Atoi(parts[3])
+	} else {
+		// Legacy format: session:questionIndex:optionIndex
+		qc.OptionIndex, _ = strconv.Atoi(parts[2])
+	}
+	return qc, true
+}
+
+// answerQuestion selects an option in Claude's question prompt with arrow keys + Enter
+func answerQuestion(qc *questionCallback) {
+	tmuxName := sessionName(qc.Session)
+	if !tmuxSessionExists(tmuxName) {
 		return
 	}
-	defer f.Close()
-	fmt.Fprintf(f, "[%s] %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+	// Send arrow down keys to select option, then Enter
+	for i := 0; i < qc.OptionIndex; i++ {
+		exec.Command(tmuxPath, "send-keys", "-t", tmuxName, "Down").Run()
+		time.Sleep(50 * time.Millisecond)
+	}
+	exec.Command(tmuxPath, "send-keys", "-t", tmuxName, "Enter").Run()
+	fmt.Printf("[callback] Selected option %d for %s (question %d/%d)\n", qc.OptionIndex, qc.Session, qc.QuestionIndex+1, qc.TotalQuestions)
+
+	// After the last question, send Enter to confirm "Submit answers"
+	if qc.TotalQuestions > 0 && qc.QuestionIndex == qc.TotalQuestions-1 {
+		time.Sleep(300 * time.Millisecond)
+		exec.Command(tmuxPath, "send-keys", "-t", tmuxName, "Enter").Run()
+		fmt.Printf("[callback] Auto-submitted answers for %s\n", qc.Session)
+	}
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Monitor modes
+const (
+	monitorModeTmux  = "tmux"  // poll capture-pane and parse ❯/● blocks (default)
+	monitorModeHooks = "hooks" // consume hook events, polling only as a fallback
+)
+
+// configuredMonitorMode returns the monitor mode, defaulting to tmux
+func configuredMonitorMode(config *Config) string {
+	if config.MonitorMode == "" {
+		return monitorModeTmux
+	}
+	return config.MonitorMode
+}
+
+// hookEventTimeout is how long a running turn may go without a hook event
+// before the monitor falls back to capture-pane for that session
+const hookEventTimeout = 5 * time.Minute
+
+// hookEvent is what `ccc hook-event` sends to the listener's control socket
+type hookEvent struct {
+	Event  string    `json:"event"` // PostToolUse, SubagentStop or Stop
+	Cwd    string    `json:"cwd"`
+	Tool   string    `json:"tool,omitempty"`
+	Text   string    `json:"text,omitempty"`   // tool summary, subagent result or the final assistant message
+	Agent  string    `json:"agent,omitempty"`  // subagent description, for Task tool calls
+	Nested bool      `json:"nested,omitempty"` // a tool call made by a subagent
+	Diff   *fileDiff `json:"diff,omitempty"`   // the change made by an Edit/Write call
+}
+
+// hookEventFromData builds the event for a PostToolUse, SubagentStop or Stop hook payload
+func hookEventFromData(hookData HookData, rawData []byte) (hookEvent, bool) {
+	ev := hookEvent{Event: hookData.HookEventName, Cwd: hookData.Cwd}
+	switch hookData.HookEventName {
+	case "PostToolUse":
+		if hookData.ToolName == "" {
+			return ev, false
+		}
+		ev.Tool = hookData.ToolName
+		ev.Nested = hookData.AgentID != ""
+		if isSubagentTool(hookData.ToolName) {
+			ev.Agent, ev.Text = subagentFromHook(rawData)
+		} else if d, ok := diffFromHook(hookData.ToolName, rawData); ok {
+			ev.Diff = d
+		} else {
+			ev.Text = summarizeToolInput(hookData.ToolName, rawData)
+		}
+	case "SubagentStop":
+	case "Stop":
+		ev.Text = getLastAssistantMessage(hookData.TranscriptPath)
+	default:
+		return ev, false
+	}
+	return ev, true
+}
+
+// handleHookEvent is the PostToolUse/SubagentStop/Stop hook. It forwards the event to the
+// listener's control socket and stays silent when no listener is running.
+func handleHookEvent() error {
+	defer func() {
+		recover()
+	}()
+
+	rawData, err := io.ReadAll(io.LimitReader(os.Stdin, 16*1024*1024))
+	if err != nil || len(rawData) == 0 {
+		return nil
+	}
+	var hookData HookData
+	if json.Unmarshal(rawData, &hookData) != nil {
+		return nil
+	}
+	ev, ok := hookEventFromData(hookData, rawData)
+	if !ok {
+		return nil
+	}
+
+	callControl("hook.event", ev, nil, 2*time.Second)
+	return nil
+}
+
+// dispatchHookEvent sends an event to its session's topic and updates the
+// session's monitor the way a capture-pane poll would
+func dispatchHookEvent(config *Config, ev hookEvent) {
+	sessName, info := sessionForDir(config, ev.Cwd)
+	if info == nil || info.TopicID == 0 || !hasSessionChannel(config) {
+		return
+	}
+	hookLog("hookstream: session=%s event=%s tool=%s", sessName, ev.Event, ev.Tool)
+
+	now := time.Now()
+	monitorsMu.Lock()
+	mon, exists := monitors[sessName]
+	if !exists {
+		mon = &SessionMonitor{LastUserMessage: now}
+		monitors[sessName] = mon
+	}
+	mon.LastHookEvent = now
+	mon.LastActivity = now
+	monitorsMu.Unlock()
+
+	msgr := outputMessenger(config)
+	switch ev.Event {
+	case "PostToolUse", "SubagentStop":
+		monitorsMu.Lock()
+		mon.Completed = false
+		if ev.Tool != "" {
+			mon.CurrentTool = ev.Tool
+		}
+		monitorsMu.Unlock()
+		switch {
+		case ev.Event == "SubagentStop" || ev.Nested:
+			// Subagent internals only keep the turn alive; the Task call's
+			// PostToolUse carries the result
+		case isSubagentTool(ev.Tool):
+			text, ok := filterBlock(config, ev.Text)
+			if !ok {
+				break
+			}
+			var body []string
+			if text != "" {
+				body = strings.Split(text, "\n")
+			}
+			msgr.SendFormatted(sessionGroup(config, info), info.TopicID, formatSubagentSummary(ev.Agent, body, ""))
+		case ev.Diff != nil:
+			text, truncated := formatDiff(ev.Diff)
+			if text, ok := filterBlock(config, text); ok {
+				msgr.SendFormatted(sessionGroup(config, info), info.TopicID, text)
+				if truncated {
+					offerFullFile(config, sessionGroup(config, info), info.TopicID, info.Path, ev.Diff)
+				}
+			}
+		default:
+			if text, ok := filterBlock(config, formatToolLine(ev.Tool, ev.Text)); ok {
+				msgr.SendFormatted(sessionGroup(config, info), info.TopicID, text)
+			}
+		}
+	case "Stop":
+		// A skipped answer still completes the turn, with the header alone
+		text, _ := filterBlock(config, ev.Text)
+		msgID, _ := msgr.SendFormatted(sessionGroup(config, info), info.TopicID, strings.TrimSpace(completionHeader(config, sessName)+text))
+		// Hook output has no block cache; remember the message for reactions
+		if msgID > 0 {
+			recordReactionMessage(sessName, reactionMessage{MessageID: msgID})
+		}
+		completeTurn(config, sessName, info, mon)
+	}
+}
+
+// formatToolLine renders a tool call the way Claude's pane shows it
+func formatToolLine(tool, summary string) string {
+	if summary == "" {
+		return "● " + tool
+	}
+	return fmt.Sprintf("● %s(%s)", tool, summary)
+}
+
+// hookDriven reports whether hook events currently cover this session, so
+// capture-pane parsing can be skipped. Between turns no events are expected;
+// during a turn the last event (or the turn's start) must be recent.
+func (m *SessionMonitor) hookDriven(now time.Time) bool {
+	if m.LastHookEvent.IsZero() {
+		return false
+	}
+	if m.TurnStarted.IsZero() {
+		return true
+	}
+	since := m.LastHookEvent
+	if m.TurnStarted.After(since) {
+		since = m.TurnStarted
+	}
+	return now.Sub(since) < hookEventTimeout
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHookEventFromData(t *testing.T) {
+	raw := []byte(`{"hook_event_name":"PostToolUse","cwd":"/p","tool_name":"Bash","tool_input":{"command":"go test ./..."}}`)
+	var hookData HookData
+	if err := json.Unmarshal(raw, &hookData); err != nil {
+		t.Fatal(err)
+	}
+	ev, ok := hookEventFromData(hookData, raw)
+	if !ok || ev.Event != "PostToolUse" || ev.Tool != "Bash" || ev.Text != "go test ./..." || ev.Cwd != "/p" {
+		t.Errorf("PostToolUse event = %+v, %v", ev, ok)
+	}
+
+	transcript := filepath.Join(t.TempDir(), "t.jsonl")
+	os.WriteFile(transcript, []byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"All done"}]}}
+{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Bash"}]}}`), 0644)
+	hookData = HookData{HookEventName: "Stop", Cwd: "/p", TranscriptPath: transcript}
+	ev, ok = hookEventFromData(hookData, nil)
+	if !ok || ev.Text != "All done" {
+		t.Errorf("Stop event = %+v, %v", ev, ok)
+	}
+
+	if _, ok := hookEventFromData(HookData{HookEventName: "Notification"}, nil); ok {
+		t.Error("Notification should not produce an event")
+	}
+	if _, ok := hookEventFromData(HookData{HookEventName: "PostToolUse"}, nil); ok {
+		t.Error("PostToolUse without a tool should not produce an event")
+	}
+}
+
+func TestHookDriven(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		mon  SessionMonitor
+		want bool
+	}{
+		{"no hook events yet", SessionMonitor{TurnStarted: now}, false},
+		{"between turns", SessionMonitor{LastHookEvent: now.Add(-time.Hour)}, true},
+		{"recent event in turn", SessionMonitor{LastHookEvent: now.Add(-time.Minute), TurnStarted: now.Add(-2 * time.Minute)}, true},
+		{"events stopped in turn", SessionMonitor{LastHookEvent: now.Add(-10 * time.Minute), TurnStarted: now.Add(-20 * time.Minute)}, false},
+		{"new turn just started", SessionMonitor{LastHookEvent: now.Add(-time.Hour), TurnStarted: now.Add(-time.Minute)}, true},
+		{"new turn with no events", SessionMonitor{LastHookEvent: now.Add(-time.Hour), TurnStarted: now.Add(-10 * time.Minute)}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.mon.hookDriven(now); got != tt.want {
+			t.Errorf("%s: hookDriven = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
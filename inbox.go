@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// inboxDir is where photos and documents without a running session are saved
+func inboxDir(config *Config) string {
+	if config.InboxDir != "" {
+		return expandPath(config.InboxDir)
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "ccc-inbox")
+}
+
+// mediaFile returns the file ID and a file name for a message's photo
+// (largest size) or document
+func mediaFile(msg *TelegramMessage) (fileID, name string, ok bool) {
+	switch {
+	case len(msg.Photo) > 0:
+		photo := msg.Photo[len(msg.Photo)-1]
+		return photo.FileID, fmt.Sprintf("photo_%s.jpg", time.Unix(msg.Date, 0).Format("20060102_150405")), true
+	case msg.Document != nil:
+		name := filepath.Base(msg.Document.FileName)
+		if name == "." || name == "/" || name == "" {
+			name = fmt.Sprintf("file_%d", msg.MessageID)
+		}
+		return msg.Document.FileID, name, true
+	}
+	return "", "", false
+}
+
+// saveMediaTo downloads a message's photo or document into dir without
+// overwriting existing files and returns the path
+func saveMediaTo(config *Config, msg *TelegramMessage, dir string) (string, error) {
+	fileID, name, ok := mediaFile(msg)
+	if !ok {
+		return "", fmt.Errorf("no photo or document")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	path := uniqueFilePath(filepath.Join(dir, name))
+	if err := downloadTelegramFile(config, fileID, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// handleInboxMedia saves a photo or document to the inbox. With a caption,
+// the caption and file path go to a one-shot Claude run.
+func handleInboxMedia(config *Config, chatID, threadID int64, msg *TelegramMessage, note string) {
+	path, err := saveMediaTo(config, msg, inboxDir(config))
+	if err != nil {
+		sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Download failed: %v", err))
+		return
+	}
+	reply := fmt.Sprintf("📥 Saved to %s", path)
+	if note != "" {
+		reply = note + "\n" + reply
+	}
+	caption := strings.TrimSpace(msg.Caption)
+	if caption == "" {
+		sendMessage(config, chatID, threadID, reply+"\n\nAdd a caption to have Claude work on it.")
+		return
+	}
+	sendMessage(config, chatID, threadID, reply+"\n🤖 Running Claude...")
+	go runOneShotClaude(config, chatID, threadID, fmt.Sprintf("%s\n\nFile: %s", caption, path))
+}
+
+// runOneShotClaude runs `claude -p` and replies with its output
+func runOneShotClaude(config *Config, chatID, threadID int64, prompt string) {
+	defer func() {
+		if r := recover(); r != nil {
+			sendMessage(config, chatID, threadID, fmt.Sprintf("💥 Panic: %v", r))
+		}
+	}()
+	output, err := runClaude(prompt)
+	if err != nil {
+		if strings.Contains(err.Error(), "context deadline exceeded") {
+			output = fmt.Sprintf("⏱️ Timeout (10min)\n\n%s", output)
+		} else {
+			output = fmt.Sprintf("⚠️ %s\n\nExit: %v", output, err)
+		}
+	}
+	sendMessage(config, chatID, threadID, output)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMediaFile(t *testing.T) {
+	date := time.Date(2026, 3, 4, 5, 6, 7, 0, time.Local).Unix()
+	photo := &TelegramMessage{Date: date, Photo: []TelegramPhoto{{FileID: "small"}, {FileID: "large"}}}
+	if id, name, ok := mediaFile(photo); !ok || id != "large" || name != "photo_20260304_050607.jpg" {
+		t.Errorf("photo = (%q, %q, %v)", id, name, ok)
+	}
+
+	doc := &TelegramMessage{MessageID: 42, Document: &TelegramDocument{FileID: "doc", FileName: "../../etc/report.pdf"}}
+	if id, name, ok := mediaFile(doc); !ok || id != "doc" || name != "report.pdf" {
+		t.Errorf("document = (%q, %q, %v)", id, name, ok)
+	}
+	doc.Document.FileName = ""
+	if _, name, _ := mediaFile(doc); name != "file_42" {
+		t.Errorf("unnamed document = %q, want file_42", name)
+	}
+
+	if _, _, ok := mediaFile(&TelegramMessage{Text: "hi"}); ok {
+		t.Error("text message should have no media")
+	}
+}
+
+func TestInboxDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if dir := inboxDir(&Config{}); dir != filepath.Join(home, "ccc-inbox") {
+		t.Errorf("default inbox = %q", dir)
+	}
+	if dir := inboxDir(&Config{InboxDir: "~/Downloads/ccc"}); dir != filepath.Join(home, "Downloads", "ccc") {
+		t.Errorf("configured inbox = %q", dir)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxInlineResults caps the sessions an inline query returns (Telegram allows 50)
+	maxInlineResults = 20
+	// maxCardAnswerLen caps the last answer quoted on a status card
+	maxCardAnswerLen = 500
+)
+
+// InlineQuery is what the user typed after the bot's username in any chat
+type InlineQuery struct {
+	ID   string `json:"id"`
+	From struct {
+		ID int64 `json:"id"`
+	} `json:"from"`
+	Query string `json:"query"`
+}
+
+// inlineArticle is an InlineQueryResultArticle: a result that posts a text message
+type inlineArticle struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Content     struct {
+		Text string `json:"message_text"`
+	} `json:"input_message_content"`
+}
+
+// matchInlineSessions returns the statuses whose session name contains the
+// query, all of them for an empty query, most recently active first
+func matchInlineSessions(statuses []SessionStatus, query string) []SessionStatus {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var matched []SessionStatus
+	for _, st := range statuses {
+		if strings.Contains(strings.ToLower(st.Name), query) {
+			matched = append(matched, st)
+		}
+	}
+	lastActive := func(st SessionStatus) time.Time {
+		if st.LastActivity == nil {
+			return time.Time{}
+		}
+		return *st.LastActivity
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return lastActive(matched[i]).After(lastActive(matched[j])) })
+	if len(matched) > maxInlineResults {
+		matched = matched[:maxInlineResults]
+	}
+	return matched
+}
+
+// sessionCard renders the status card an inline result posts
+func sessionCard(config *Config, st SessionStatus, lastAnswer string, now time.Time) string {
+	var last time.Time
+	if st.LastActivity != nil {
+		last = *st.LastActivity
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📊 %s — %s, last active %s\n📁 %s", st.Name, st.State, formatLastActivity(last, now), st.Path)
+	if answer := strings.TrimSpace(lastAnswer); answer != "" {
+		fmt.Fprintf(&sb, "\n\n💬 %s", truncate(answer, maxCardAnswerLen))
+	}
+	if link := topicLink(config, sessionChat(config, st.Name), st.TopicID); link != "" {
+		fmt.Fprintf(&sb, "\n\n🔗 %s", link)
+	}
+	return sb.String()
+}
+
+// handleInlineQuery answers "@bot <name>" with matching sessions. Anyone can
+// query a bot inline, so others get no results.
+func handleInlineQuery(config *Config, q *InlineQuery) {
+	articles := []inlineArticle{}
+	if q.From.ID == config.ChatID {
+		now := time.Now()
+		for _, st := range matchInlineSessions(collectSessionStatuses(config), q.Query) {
+			var answer string
+			if info := config.Sessions[st.Name]; info != nil {
+				answer = getLastAssistantMessage(sessionTranscript(info))
+			}
+			a := inlineArticle{Type: "article", ID: st.Name, Title: st.Name}
+			a.Description = fmt.Sprintf("%s · %s", st.State, st.Path)
+			a.Content.Text = sessionCard(config, st, answer, now)
+			if len(a.ID) > 64 {
+				a.ID = a.ID[:64]
+			}
+			articles = append(articles, a)
+		}
+	}
+	results, _ := json.Marshal(articles)
+	params := url.Values{
+		"inline_query_id": {q.ID},
+		"results":         {string(results)},
+		"cache_time":      {"5"},
+		"is_personal":     {"true"},
+	}
+	result, err := telegramAPI(config, "answerInlineQuery", params)
+	if err != nil {
+		hookLog("inline: answering query failed: %v", err)
+	} else if !result.OK {
+		hookLog("inline: answering query failed: %s", result.Description)
+	}
+}
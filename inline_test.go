@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchInlineSessions(t *testing.T) {
+	older := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	statuses := []SessionStatus{
+		{Name: "api"},
+		{Name: "quantum-sim", LastActivity: &older},
+		{Name: "quantum-viz", LastActivity: &newer},
+	}
+	got := matchInlineSessions(statuses, " Quantum ")
+	if len(got) != 2 || got[0].Name != "quantum-viz" || got[1].Name != "quantum-sim" {
+		t.Errorf("matchInlineSessions(quantum) = %+v", got)
+	}
+	if got := matchInlineSessions(statuses, ""); len(got) != 3 || got[2].Name != "api" {
+		t.Errorf("matchInlineSessions(\"\") = %+v", got)
+	}
+}
+
+func TestSessionCard(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	last := now.Add(-2 * time.Hour)
+	config := &Config{GroupID: -1001234567890}
+	st := SessionStatus{Name: "api", Path: "/srv/api", TopicID: 42, State: "idle", LastActivity: &last}
+	card := sessionCard(config, st, "All tests pass.", now)
+	for _, want := range []string{"📊 api — idle, last active 2h", "📁 /srv/api", "💬 All tests pass.", "https://t.me/c/1234567890/42"} {
+		if !strings.Contains(card, want) {
+			t.Errorf("sessionCard() = %q, missing %q", card, want)
+		}
+	}
+}
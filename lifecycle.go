@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxCompactSnapshots is how many pre-compaction transcripts are kept per session
+const maxCompactSnapshots = 10
+
+// handleLifecycleHook is the SessionStart/PreCompact hook. Unlike hook-event
+// it works without the listener: SessionStart records Claude's session ID,
+// PreCompact tells the topic and may keep a transcript snapshot for /export.
+func handleLifecycleHook() error {
+	defer func() {
+		recover()
+	}()
+
+	rawData, err := io.ReadAll(io.LimitReader(os.Stdin, 1024*1024))
+	if err != nil || len(rawData) == 0 {
+		return nil
+	}
+	var hookData HookData
+	if json.Unmarshal(rawData, &hookData) != nil {
+		return nil
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+	sessName, info := sessionForDir(config, hookData.Cwd)
+	if info == nil {
+		return nil
+	}
+
+	switch hookData.HookEventName {
+	case "SessionStart":
+		recordClaudeSession(sessName, info, hookData.SessionID)
+	case "PreCompact":
+		notifyCompaction(config, sessName, info, hookData)
+	}
+	return nil
+}
+
+// recordClaudeSession saves the Claude session a ccc session is running, so
+// it can be resumed and exported
+func recordClaudeSession(sessName string, info *SessionInfo, claudeID string) {
+	if claudeID == "" || claudeID == info.ClaudeSessionID {
+		return
+	}
+	info.ClaudeSessionID = claudeID
+	if err := saveSession(sessName, info); err != nil {
+		hookLog("lifecycle: %s: saving Claude session: %v", sessName, err)
+	}
+}
+
+// notifyCompaction tells the topic that Claude is compacting its context,
+// keeping a copy of the transcript first when compact_snapshots is on
+func notifyCompaction(config *Config, sessName string, info *SessionInfo, hookData HookData) {
+	msg := "🗜️ Compacting context"
+	if hookData.Trigger == "auto" {
+		msg += " (context window full)"
+	}
+	msg += "..."
+	if config.CompactSnapshots && hookData.TranscriptPath != "" {
+		if _, err := saveCompactSnapshot(sessName, hookData.TranscriptPath, time.Now()); err != nil {
+			hookLog("lifecycle: %s: snapshot: %v", sessName, err)
+		} else {
+			msg += "\n📸 Transcript snapshot kept for /export"
+		}
+	}
+	if info.TopicID != 0 && hasSessionChannel(config) {
+		getMessenger(config).Send(sessionGroup(config, info), info.TopicID, msg)
+	}
+}
+
+// compactSnapshotDir is where a session's pre-compaction transcripts are kept
+func compactSnapshotDir(sessName string) string {
+	return filepath.Join(stateFile("-snapshots"), sessName)
+}
+
+// saveCompactSnapshot copies a transcript into the session's snapshot
+// directory and drops the oldest beyond maxCompactSnapshots
+func saveCompactSnapshot(sessName, transcript string, now time.Time) (string, error) {
+	dir := compactSnapshotDir(sessName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(transcript)
+	if err != nil {
+		return "", err
+	}
+	name := now.Format("20060102-150405") + "-" + strings.TrimSuffix(filepath.Base(transcript), ".jsonl") + ".jsonl"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+
+	snapshots := compactSnapshots(sessName)
+	for len(snapshots) > maxCompactSnapshots {
+		os.Remove(snapshots[0])
+		snapshots = snapshots[1:]
+	}
+	return path, nil
+}
+
+// compactSnapshots returns a session's snapshots, oldest first (their names
+// start with the time they were taken)
+func compactSnapshots(sessName string) []string {
+	files, _ := filepath.Glob(filepath.Join(compactSnapshotDir(sessName), "*.jsonl"))
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordClaudeSession(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	info := &SessionInfo{TopicID: 100, Path: "/tmp/proj"}
+
+	recordClaudeSession("proj", info, "")
+	if info.ClaudeSessionID != "" {
+		t.Error("an empty session ID should be ignored")
+	}
+	recordClaudeSession("proj", info, "abc-123")
+	stored, err := loadSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored["proj"] == nil || stored["proj"].ClaudeSessionID != "abc-123" {
+		t.Errorf("Claude session not saved: %+v", stored["proj"])
+	}
+}
+
+func TestSaveCompactSnapshot(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	transcript := filepath.Join(t.TempDir(), "abc-123.jsonl")
+	os.WriteFile(transcript, []byte(`{"type":"user"}`+"\n"), 0600)
+
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	var first string
+	for i := 0; i < maxCompactSnapshots+2; i++ {
+		path, err := saveCompactSnapshot("proj", transcript, start.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first = path
+		}
+	}
+
+	snapshots := compactSnapshots("proj")
+	if len(snapshots) != maxCompactSnapshots {
+		t.Fatalf("kept %d snapshots, want %d", len(snapshots), maxCompactSnapshots)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Error("the oldest snapshot should have been dropped")
+	}
+	if name := filepath.Base(snapshots[len(snapshots)-1]); name != "20261016-091100-abc-123.jsonl" {
+		t.Errorf("newest snapshot = %s", name)
+	}
+	if data, _ := os.ReadFile(snapshots[0]); !strings.Contains(string(data), `"user"`) {
+		t.Errorf("snapshot content = %q", data)
+	}
+	if len(compactSnapshots("other")) != 0 {
+		t.Error("another session should have no snapshots")
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log levels, least severe first
+const (
+	levelDebug = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// The log is rotated past maxLogSize, keeping logBackups old files (ccc.log.1 ...)
+const (
+	maxLogSize = 5 << 20
+	logBackups = 3
+)
+
+// Log settings are read once per process from the config file (log_level,
+// log_format) and the CCC_LOG_* overrides, without loading the whole config
+var (
+	logMu       sync.Mutex
+	logOnce     sync.Once
+	logMinLevel = levelInfo
+	logJSON     bool
+	logEcho     bool // also print warnings and errors to stderr (the listener in a terminal)
+)
+
+// parseLogLevel parses a level name
+func parseLogLevel(s string) (int, bool) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return i, true
+		}
+	}
+	return levelInfo, false
+}
+
+// configuredLogLevel returns the log_level of a config, info by default
+func configuredLogLevel(config *Config) int {
+	level, _ := parseLogLevel(config.LogLevel)
+	return level
+}
+
+// logPath is where all ccc processes log
+func logPath() string {
+	return stateFile(".log")
+}
+
+func loadLogSettings() {
+	var settings struct {
+		LogLevel  string `json:"log_level"`
+		LogFormat string `json:"log_format"`
+	}
+	if data, err := os.ReadFile(getConfigPath()); err == nil {
+		json.Unmarshal(data, &settings)
+	}
+	if v, ok := os.LookupEnv(configEnvName("log_level")); ok {
+		settings.LogLevel = v
+	}
+	if v, ok := os.LookupEnv(configEnvName("log_format")); ok {
+		settings.LogFormat = v
+	}
+	if level, ok := parseLogLevel(settings.LogLevel); ok {
+		logMinLevel = level
+	}
+	logJSON = settings.LogFormat == "json"
+}
+
+// logProc names the process writing an entry: the subcommand, e.g. listen or hook-stop
+func logProc() string {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") && !strings.Contains(os.Args[1], " ") {
+		return os.Args[1]
+	}
+	return "ccc"
+}
+
+// logf writes a log entry with alternating key/value fields:
+// logf(levelWarn, "send failed", "session", name, "err", err)
+func logf(level int, msg string, fields ...interface{}) {
+	logOnce.Do(loadLogSettings)
+	if level < logMinLevel {
+		return
+	}
+	line := formatLogEntry(time.Now(), level, logProc(), msg, fields, logJSON)
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logEcho && level >= levelWarn {
+		fmt.Fprint(os.Stderr, line)
+	}
+	path := logPath()
+	if info, err := os.Stat(path); err == nil && info.Size() > maxLogSize {
+		rotateLog(path)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line)
+}
+
+// formatLogEntry renders one entry as a line of text or JSON
+func formatLogEntry(t time.Time, level int, proc, msg string, fields []interface{}, asJSON bool) string {
+	if asJSON {
+		entry := map[string]interface{}{
+			"time":  t.Format(time.RFC3339Nano),
+			"level": levelNames[level],
+			"proc":  proc,
+			"msg":   msg,
+		}
+		for i := 0; i+1 < len(fields); i += 2 {
+			v := fields[i+1]
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			entry[fmt.Sprint(fields[i])] = v
+		}
+		data, _ := json.Marshal(entry)
+		return string(data) + "\n"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %-5s [%s] %s", t.Format("2006-01-02T15:04:05.000Z07:00"), strings.ToUpper(levelNames[level]), proc, msg)
+	for i := 0; i+1 < len(fields); i += 2 {
+		v := fmt.Sprint(fields[i+1])
+		if strings.ContainsAny(v, " \"=\n") {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&sb, " %v=%s", fields[i], v)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// rotateLog shifts ccc.log to ccc.log.1, ccc.log.1 to .2 and so on
+func rotateLog(path string) {
+	for i := logBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+	os.Rename(path, path+".1")
+}
+
+// logLineLevel returns the level of a text or JSON log line
+func logLineLevel(line string) int {
+	if strings.HasPrefix(line, "{") {
+		var entry struct {
+			Level string `json:"level"`
+		}
+		json.Unmarshal([]byte(line), &entry)
+		level, _ := parseLogLevel(entry.Level)
+		return level
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return levelInfo
+	}
+	level, _ := parseLogLevel(fields[1])
+	return level
+}
+
+// readLogTail returns the last n log lines at minLevel or above
+func readLogTail(path string, n, minLevel int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" && logLineLevel(line) >= minLevel {
+			lines = append(lines, line)
+			if len(lines) > n {
+				lines = lines[1:]
+			}
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// followLog prints lines appended to the log at minLevel or above until
+// interrupted, starting over when the log is rotated
+func followLog(path string, minLevel int) error {
+	var offset int64
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+	for {
+		time.Sleep(500 * time.Millisecond)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.Size() < offset {
+			offset = 0 // rotated
+		}
+		if info.Size() == offset {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		f.Seek(offset, io.SeekStart)
+		reader := bufio.NewReader(f)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				break // a partial line is read again next time
+			}
+			offset += int64(len(line))
+			if logLineLevel(line) >= minLevel {
+				fmt.Print(line)
+			}
+		}
+		f.Close()
+	}
+}
+
+// handleLogsCommand implements `ccc logs [-f] [-n lines] [--level level]`
+func handleLogsCommand(args []string) error {
+	n, minLevel, follow := 50, levelDebug, false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-f" || arg == "--follow":
+			follow = true
+		case (arg == "-n" || arg == "--level") && i+1 < len(args):
+			i++
+			if arg == "-n" {
+				if _, err := fmt.Sscanf(args[i], "%d", &n); err != nil || n <= 0 {
+					return fmt.Errorf("invalid line count %q", args[i])
+				}
+			} else if level, ok := parseLogLevel(args[i]); ok {
+				minLevel = level
+			} else {
+				return fmt.Errorf("unknown level %q (use debug, info, warn or error)", args[i])
+			}
+		default:
+			return fmt.Errorf("usage: ccc logs [-f] [-n lines] [--level debug|info|warn|error]")
+		}
+	}
+	path := logPath()
+	lines, err := readLogTail(path, n, minLevel)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	if follow {
+		return followLog(path, minLevel)
+	}
+	if len(lines) == 0 {
+		fmt.Fprintf(os.Stderr, "No log entries in %s\n", path)
+	}
+	return nil
+}
+
+// Telegram /logs [n] line counts
+const (
+	defaultLogLines = 20
+	maxLogLines     = 200
+)
+
+// recentLogText returns the last lines of the log for /logs, fitted to a message
+func recentLogText(arg string) (string, error) {
+	n := defaultLogLines
+	if arg != "" {
+		if _, err := fmt.Sscanf(arg, "%d", &n); err != nil || n <= 0 {
+			return "", fmt.Errorf("usage: /logs [lines]")
+		}
+		if n > maxLogLines {
+			n = maxLogLines
+		}
+	}
+	lines, err := readLogTail(logPath(), n, levelDebug)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "(log is empty)", nil
+	}
+	return clampTail(strings.Join(lines, "\n")), nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatLogEntry(t *testing.T) {
+	ts := time.Date(2026, 10, 16, 9, 12, 3, 114000000, time.UTC)
+
+	text := formatLogEntry(ts, levelWarn, "listen", "send failed", []interface{}{"session", "api", "err", errors.New("dial tcp: timeout")}, false)
+	want := "2026-10-16T09:12:03.114Z WARN  [listen] send failed session=api err=\"dial tcp: timeout\"\n"
+	if text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+	if got := logLineLevel(text); got != levelWarn {
+		t.Errorf("logLineLevel(text) = %d, want warn", got)
+	}
+
+	js := formatLogEntry(ts, levelError, "hook-stop", "boom", []interface{}{"err", errors.New("x"), "n", 3}, true)
+	for _, part := range []string{`"level":"error"`, `"proc":"hook-stop"`, `"msg":"boom"`, `"err":"x"`, `"n":3`} {
+		if !strings.Contains(js, part) {
+			t.Errorf("json %q missing %s", js, part)
+		}
+	}
+	if got := logLineLevel(js); got != levelError {
+		t.Errorf("logLineLevel(json) = %d, want error", got)
+	}
+}
+
+func TestReadLogTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ccc.log")
+	ts := time.Now()
+	var sb strings.Builder
+	for i := 0; i < 6; i++ {
+		level := levelInfo
+		if i%2 == 1 {
+			level = levelError
+		}
+		sb.WriteString(formatLogEntry(ts, level, "listen", fmt.Sprintf("entry %d", i), nil, i >= 4))
+	}
+	os.WriteFile(path, []byte(sb.String()), 0600)
+
+	lines, err := readLogTail(path, 2, levelDebug)
+	if err != nil || len(lines) != 2 || !strings.Contains(lines[1], "entry 5") {
+		t.Fatalf("tail 2 = %q, %v", lines, err)
+	}
+	lines, _ = readLogTail(path, 10, levelError)
+	if len(lines) != 3 {
+		t.Fatalf("errors only = %q", lines)
+	}
+	for _, line := range lines {
+		if logLineLevel(line) != levelError {
+			t.Errorf("unexpected line %q", line)
+		}
+	}
+}
+
+func TestRotateLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ccc.log")
+	for i := 1; i <= logBackups+1; i++ {
+		os.WriteFile(path, []byte(fmt.Sprint(i)), 0600)
+		rotateLog(path)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("log still present after rotation")
+	}
+	for i := 1; i <= logBackups; i++ {
+		data, _ := os.ReadFile(fmt.Sprintf("%s.%d", path, i))
+		if want := fmt.Sprint(logBackups + 2 - i); string(data) != want {
+			t.Errorf("backup %d = %q, want %q", i, data, want)
+		}
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s.%d", path, logBackups+1)); !os.IsNotExist(err) {
+		t.Errorf("kept more than %d backups", logBackups)
+	}
+}
+
+func TestRecentLogText(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if out, err := recentLogText(""); err != nil || out != "(log is empty)" {
+		t.Errorf("empty log = %q, %v", out, err)
+	}
+	var sb strings.Builder
+	for i := 0; i < maxLogLines+10; i++ {
+		sb.WriteString(formatLogEntry(time.Now(), levelDebug, "listen", fmt.Sprintf("entry %d", i), nil, false))
+	}
+	os.WriteFile(logPath(), []byte(sb.String()), 0600)
+
+	out, err := recentLogText("")
+	if err != nil || strings.Count(out, "\n") != defaultLogLines-1 {
+		t.Errorf("default = %d lines, %v", strings.Count(out, "\n")+1, err)
+	}
+	out, _ = recentLogText("1000")
+	if !strings.Contains(out, fmt.Sprintf("entry %d", maxLogLines+9)) || strings.Contains(out, "entry 9\n") {
+		t.Errorf("clamped output has the wrong lines")
+	}
+	if _, err := recentLogText("abc"); err == nil {
+		t.Error("expected usage error")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// formatSessionTable renders statuses as an aligned table for `ccc ls`
+func formatSessionTable(statuses []SessionStatus, now time.Time) string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTOPIC\tSTATE\tLAST ACTIVITY\tCLAUDE SESSION\tPATH")
+	for _, st := range statuses {
+		activity := "-"
+		if st.LastActivity != nil {
+			activity = formatLastActivity(*st.LastActivity, now)
+		}
+		claudeID := st.ClaudeSessionID
+		if claudeID == "" {
+			claudeID = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", st.Name, st.TopicID, st.State, activity, claudeID, st.Path)
+	}
+	tw.Flush()
+	return sb.String()
+}
+
+// handleLsCommand implements `ccc ls [--json]`. Statuses come from the
+// listener when it runs, so they include the monitor's last activity.
+func handleLsCommand(args []string) error {
+	asJSON := len(args) > 0 && args[0] == "--json"
+	var statuses []SessionStatus
+	err := callControl("sessions", nil, &statuses, 10*time.Second)
+	if err == errControlUnavailable {
+		config, err := loadConfig()
+		if err != nil {
+			return fmt.Errorf("no config found: %w", err)
+		}
+		statuses = collectSessionStatuses(config)
+	} else if err != nil {
+		return err
+	}
+
+	if asJSON {
+		if statuses == nil {
+			statuses = []SessionStatus{}
+		}
+		data, _ := json.MarshalIndent(statuses, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+	if len(statuses) == 0 {
+		fmt.Println("No sessions.")
+		return nil
+	}
+	fmt.Print(formatSessionTable(statuses, time.Now()))
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatSessionTable(t *testing.T) {
+	now := time.Now()
+	last := now.Add(-90 * time.Minute)
+	table := formatSessionTable([]SessionStatus{
+		{Name: "api", TopicID: 100, Path: "/srv/api", State: "working", ClaudeSessionID: "abc-123", LastActivity: &last},
+		{Name: "web", TopicID: 200, Path: "/srv/web", State: "stopped"},
+	}, now)
+
+	lines := strings.Split(strings.TrimSpace(table), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("table has %d lines, want header + 2:\n%s", len(lines), table)
+	}
+	for _, want := range []string{"api", "100", "working", "1h30m ago", "abc-123", "/srv/api"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("row %q missing %q", lines[1], want)
+		}
+	}
+	if fields := strings.Fields(lines[2]); len(fields) != 6 || fields[3] != "-" || fields[4] != "-" {
+		t.Errorf("row without activity or Claude session = %q, want dashes", lines[2])
+	}
+	if strings.Index(lines[0], "PATH") != strings.Index(lines[1], "/srv/api") {
+		t.Errorf("columns not aligned:\n%s", table)
+	}
+}
@@ -4,35 +4,128 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const version = "2.0.0"
 
 // SessionInfo stores information about a session
 type SessionInfo struct {
-	TopicID         int64  `json:"topic_id"`
-	Path            string `json:"path"`
-	ClaudeSessionID string `json:"claude_session_id,omitempty"`
+	TopicID          int64      `json:"topic_id"`
+	GroupID          int64      `json:"group_id,omitempty"` // Group the topic is in, when not the main group
+	Path             string     `json:"path"`
+	ClaudeSessionID  string     `json:"claude_session_id,omitempty"`
+	AutoCommit       bool       `json:"auto_commit,omitempty"`        // Commit a git checkpoint after each completed turn
+	AutoPush         string     `json:"auto_push,omitempty"`          // "remote" or "remote/branch" auto-commit checkpoints are pushed to
+	TestGate         string     `json:"test_gate,omitempty"`          // Command run after each completed turn; failures go back to Claude (/gate)
+	AlwaysAllowTools []string   `json:"always_allow_tools,omitempty"` // Tools approved with "Always allow" from Telegram
+	WorktreeRepo     string     `json:"worktree_repo,omitempty"`      // Main repository when Path is a git worktree of it
+	Branch           string     `json:"branch,omitempty"`             // Worktree branch, merged back with /merge
+	Schedules        []Schedule `json:"schedules,omitempty"`          // Cron-scheduled prompts
+	Mode             string     `json:"mode,omitempty"`               // "headless" runs claude -p per message instead of a tmux session
+	Coalesce         bool       `json:"coalesce,omitempty"`           // /verbose off: batch consecutive blocks into one edited message
+	StatusMsgID      int64      `json:"status_msg_id,omitempty"`      // Pinned live status message (status_pin)
+	Hibernated       bool       `json:"hibernated,omitempty"`         // tmux session stopped for being idle; the next message resumes it
+	ClaudeFlags      []string   `json:"claude_flags,omitempty"`       // Extra claude flags (/flags, /model)
+	Deadline         int64      `json:"deadline,omitempty"`           // Unix time the session is stopped at (ccc start --timeout)
+	NotifyOnComplete bool       `json:"notify_on_complete,omitempty"` // Message the private chat when the next turn completes (ccc start --notify-on-complete)
+	GitHubIssue      string     `json:"github_issue,omitempty"`       // "owner/repo#n" the session was started from; completed turns are posted there
+	Machine          string     `json:"machine,omitempty"`            // Machine running the session, when several share the bot (federation)
 }
 
 // Config stores bot configuration and session mappings
 type Config struct {
-	BotToken      string                  `json:"bot_token"`
-	ChatID        int64                   `json:"chat_id"`                // Private chat for simple commands
-	GroupID       int64                   `json:"group_id,omitempty"`     // Group with topics for sessions
-	Sessions      map[string]*SessionInfo `json:"sessions,omitempty"`     // session name -> session info
-	ProjectsDir   string                  `json:"projects_dir,omitempty"` // Base directory for new projects (default: ~)
-	RelayURL      string                  `json:"relay_url,omitempty"`    // Relay server URL for large file transfers
-	Away          bool                    `json:"away"`
-	OAuthToken    string                  `json:"oauth_token,omitempty"`
-	OpenRouterKey string                  `json:"openrouter_key,omitempty"` // OpenRouter API key for LLM router
+	BotToken                string                  `json:"bot_token"`
+	ChatID                  int64                   `json:"chat_id"`                    // Private chat for simple commands
+	GroupID                 int64                   `json:"group_id,omitempty"`         // Group with topics for sessions
+	Groups                  map[string]int64        `json:"groups,omitempty"`           // More groups for sessions, by name (ccc setgroup --name)
+	Sessions                map[string]*SessionInfo `json:"sessions,omitempty"`         // session name -> session info
+	ProjectsDir             string                  `json:"projects_dir,omitempty"`     // Base directory for new projects (default: ~)
+	RelayURL                string                  `json:"relay_url,omitempty"`        // Relay server URL for large file transfers
+	RelaySecret             string                  `json:"relay_secret,omitempty"`     // Shared secret for signing relay transfers
+	StorageEndpoint         string                  `json:"storage_endpoint,omitempty"` // S3-compatible endpoint for ccc send --store (e.g. https://<account>.r2.cloudflarestorage.com)
+	StorageBucket           string                  `json:"storage_bucket,omitempty"`
+	StorageRegion           string                  `json:"storage_region,omitempty"` // default: us-east-1
+	StorageAccessKey        string                  `json:"storage_access_key,omitempty"`
+	StorageSecretKey        string                  `json:"storage_secret_key,omitempty"`
+	StorageExpiryHours      int                     `json:"storage_expiry_hours,omitempty"` // How long stored links stay valid (default: 24, max: 168)
+	Away                    bool                    `json:"away"`
+	AwayAuto                bool                    `json:"away_auto,omitempty"`       // Decide away from the schedule and idle detection instead of the away flag
+	AwaySchedule            string                  `json:"away_schedule,omitempty"`   // e.g. "18:00-09:00 weekends"
+	AwayIdleHours           int                     `json:"away_idle_hours,omitempty"` // Away after this long without terminal activity (0 = off)
+	OAuthToken              string                  `json:"oauth_token,omitempty"`
+	OpenRouterKey           string                  `json:"openrouter_key,omitempty"`             // OpenRouter API key for LLM router
+	GitHubToken             string                  `json:"github_token,omitempty"`               // GitHub token for /pr and /issues (the gh CLI is used without one)
+	GitHubWebhookSecret     string                  `json:"github_webhook_secret,omitempty"`      // Secret GitHub signs webhooks to ccc github-listen with
+	GitHubUsers             []string                `json:"github_users,omitempty"`               // GitHub logins whose ccc label starts a session
+	RouterEndpoint          string                  `json:"router_endpoint,omitempty"`            // OpenAI-compatible API base for the router instead of OpenRouter (e.g. Ollama)
+	RouterModel             string                  `json:"router_model,omitempty"`               // Model for intent classification (default: defaultRouterModel)
+	DefaultSession          string                  `json:"default_session,omitempty"`            // Session that messages in the group's General area go to ("" = router or one-shot Claude)
+	IdleNotifyMinutes       int                     `json:"idle_notify_minutes,omitempty"`        // Notify private chat when all sessions idle this long (0 = off)
+	DigestTime              string                  `json:"digest_time,omitempty"`                // "HH:MM" the daily activity digest is sent to the private chat ("" = off)
+	SummarizeBlocks         int                     `json:"summarize_blocks,omitempty"`           // Summarize a turn's output past this many blocks with the router LLM (0 = off)
+	SummarizeChars          int                     `json:"summarize_chars,omitempty"`            // Summarize a turn's output past this many characters with the router LLM (0 = off)
+	HibernateHours          int                     `json:"hibernate_hours,omitempty"`            // Stop tmux sessions idle this long; the next message restarts them with claude -c (0 = off)
+	CommandJailDir          string                  `json:"command_jail_dir,omitempty"`           // Restrict /c and git commands to this directory (guardrail, not a sandbox)
+	ClaudeStartTimeout      int                     `json:"claude_start_timeout,omitempty"`       // Seconds to wait for Claude's prompt after starting a session (default: 30)
+	PermissionTimeout       int                     `json:"permission_timeout,omitempty"`         // Seconds a permission prompt waits for a button before the terminal decides (default: 300, max: 3600)
+	MaxConcurrentSessions   int                     `json:"max_concurrent_sessions,omitempty"`    // Headless prompts wait while this many sessions are busy (0 = no limit)
+	SessionCPUPercent       int                     `json:"session_cpu_percent,omitempty"`        // CPU cap per tmux session in percent of one core, via a systemd scope (0 = off)
+	SessionMemoryMB         int                     `json:"session_memory_mb,omitempty"`          // Memory cap per tmux session in MB, via a systemd scope (0 = off)
+	SessionNice             int                     `json:"session_nice,omitempty"`               // Niceness Claude runs at in tmux sessions (0 = off)
+	BlockSendDelayMs        int                     `json:"block_send_delay_ms,omitempty"`        // Delay between blocks sent in one sync pass (default: 0)
+	QuotePromptInCompletion bool                    `json:"quote_prompt_in_completion,omitempty"` // Quote the triggering prompt in ✅ completion messages
+	Messenger               string                  `json:"messenger,omitempty"`                  // "telegram" (default), "discord", "slack", "relay" or "matrix"
+	DiscordBotToken         string                  `json:"discord_bot_token,omitempty"`
+	DiscordChannelID        int64                   `json:"discord_channel_id,omitempty"`           // Channel whose threads hold sessions
+	DiscordUserID           int64                   `json:"discord_user_id,omitempty"`              // Only messages from this user are accepted
+	SlackAppToken           string                  `json:"slack_app_token,omitempty"`              // xapp- token for Socket Mode
+	SlackBotToken           string                  `json:"slack_bot_token,omitempty"`              // xoxb- token for the Web API
+	SlackChannelID          string                  `json:"slack_channel_id,omitempty"`             // Channel whose threads hold sessions
+	SlackUserID             string                  `json:"slack_user_id,omitempty"`                // Only messages from this user are accepted
+	ControlChannel          string                  `json:"control_channel,omitempty"`              // Relay control channel the web client talks through (ccc pair)
+	ControlKey              string                  `json:"control_key,omitempty"`                  // Key sealing control channel messages, only shared in the pairing link
+	MatrixHomeserver        string                  `json:"matrix_homeserver,omitempty"`            // Homeserver URL, or a pantalaimon proxy for encrypted rooms
+	MatrixAccessToken       string                  `json:"matrix_access_token,omitempty"`          // The bot account's access token
+	MatrixUserID            string                  `json:"matrix_user_id,omitempty"`               // Invited to session rooms; only messages from this user are accepted
+	MatrixEncrypt           bool                    `json:"matrix_encrypt,omitempty"`               // Create session rooms with end-to-end encryption enabled
+	MatrixRooms             map[int64]string        `json:"matrix_rooms,omitempty"`                 // Session topic ID -> room ID
+	MonitorMode             string                  `json:"monitor_mode,omitempty"`                 // "tmux" (default) or "hooks"
+	CompactSnapshots        bool                    `json:"compact_snapshots,omitempty"`            // Keep a transcript copy before each compaction, included in /export
+	TopicStatus             bool                    `json:"topic_status,omitempty"`                 // Show the git branch in topic names and the session state as the topic icon
+	StatusPin               bool                    `json:"status_pin,omitempty"`                   // Keep a pinned, live-edited status message in each session topic
+	AutoResume              bool                    `json:"auto_resume,omitempty"`                  // Resume sessions stopped by the usage limit once it resets
+	ConfirmDestructive      bool                    `json:"confirm_destructive_commands,omitempty"` // Ask before /c runs a command matching DestructivePatterns
+	DestructivePatterns     []string                `json:"destructive_patterns,omitempty"`         // Regexes for ConfirmDestructive (default: rm -rf, dd, shutdown, ...)
+	TranscriptionBackend    string                  `json:"transcription_backend,omitempty"`        // "local", "openai" or "deepgram" for voice messages
+	TranscriptionCmd        string                  `json:"transcription_cmd,omitempty"`            // Local backend: command given the audio path, prints the text
+	TranscriptionAPIKey     string                  `json:"transcription_api_key,omitempty"`        // API key for the openai or deepgram backend
+	InboxDir                string                  `json:"inbox_dir,omitempty"`                    // Where media outside running sessions is saved (default: ~/ccc-inbox)
+	MachineName             string                  `json:"machine_name,omitempty"`                 // Name this machine claims sessions under (default: hostname)
+	FederationPort          int                     `json:"federation_port,omitempty"`              // Port the hub serves other machines' polls on (0 = not a hub)
+	FederationHub           string                  `json:"federation_hub,omitempty"`               // Hub URL polled instead of Telegram, on the other machines sharing the bot
+	FederationSecret        string                  `json:"federation_secret,omitempty"`            // Shared secret polls to the hub and its answers are signed with
+	FederationCert          string                  `json:"federation_cert,omitempty"`              // TLS certificate the hub serves with; without one it listens on loopback only
+	FederationKey           string                  `json:"federation_key,omitempty"`               // Private key for FederationCert
+	WatchdogMinutes         int                     `json:"watchdog_minutes,omitempty"`             // Restart the listener when polling or the monitor stalls this long (default: 10, -1 = off)
+	LogLevel                string                  `json:"log_level,omitempty"`                    // debug, info (default), warn or error
+	LogFormat               string                  `json:"log_format,omitempty"`                   // text (default) or json
+	SecretsBackend          string                  `json:"secrets_backend,omitempty"`              // "plain" (default), "keychain" or "encrypted"
+	KeychainSecrets         []string                `json:"keychain_secrets,omitempty"`             // Secrets kept in the OS keychain
+	EncryptedSecrets        string                  `json:"encrypted_secrets,omitempty"`            // Secrets sealed with $CCC_SECRETS_KEY
+	Filters                 *BlockFilters           `json:"filters,omitempty"`                      // Redaction, skip and replace rules for forwarded output
+
+	overrides  map[string]configOverride // Keys set from CCC_* variables or flags, never saved
+	outputLane bool                      // Telegram sends use the output budget, see outputMessenger
 }
 
 // TelegramMessage represents a Telegram message
 type TelegramMessage struct {
 	MessageID       int    `json:"message_id"`
 	MessageThreadID int64  `json:"message_thread_id,omitempty"` // Topic ID
+	Date            int64  `json:"date"`                        // Unix time sent
 	Chat            struct {
 		ID   int64  `json:"id"`
 		Type string `json:"type"` // "private", "group", "supergroup"
@@ -79,13 +172,38 @@ type CallbackQuery struct {
 
 // TelegramUpdate represents an update from Telegram
 type TelegramUpdate struct {
-	OK          bool   `json:"ok"`
-	Description string `json:"description"`
-	Result      []struct {
-		UpdateID      int             `json:"update_id"`
-		Message       TelegramMessage `json:"message"`
-		CallbackQuery *CallbackQuery  `json:"callback_query"`
-	} `json:"result"`
+	OK          bool                 `json:"ok"`
+	Description string               `json:"description"`
+	Result      []TelegramUpdateItem `json:"result"`
+}
+
+// TelegramUpdateItem is one update in a getUpdates response
+type TelegramUpdateItem struct {
+	UpdateID      int              `json:"update_id"`
+	Message       TelegramMessage  `json:"message"`
+	CallbackQuery *CallbackQuery   `json:"callback_query"`
+	InlineQuery   *InlineQuery     `json:"inline_query"`
+	Reaction      *MessageReaction `json:"message_reaction"`
+}
+
+// MessageReaction is a change to the reactions a user put on a message. It
+// carries no topic, only the chat and message.
+type MessageReaction struct {
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	MessageID int `json:"message_id"`
+	User      *struct {
+		ID int64 `json:"id"`
+	} `json:"user"`
+	OldReaction []ReactionType `json:"old_reaction"`
+	NewReaction []ReactionType `json:"new_reaction"`
+}
+
+// ReactionType is one reaction: a standard emoji or a custom one
+type ReactionType struct {
+	Type  string `json:"type"` // "emoji" or "custom_emoji"
+	Emoji string `json:"emoji,omitempty"`
 }
 
 // TelegramResponse represents a response from Telegram API
@@ -93,6 +211,9 @@ type TelegramResponse struct {
 	OK          bool            `json:"ok"`
 	Description string          `json:"description,omitempty"`
 	Result      json.RawMessage `json:"result,omitempty"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
 }
 
 // TopicResult represents the result of creating a forum topic
@@ -110,6 +231,8 @@ type HookData struct {
 	ToolName       string `json:"tool_name"`
 	Prompt         string `json:"prompt"`       // For UserPromptSubmit hook
 	Notification   string `json:"notification"` // For Notification hook
+	Trigger        string `json:"trigger"`      // For PreCompact hook: "manual" or "auto"
+	AgentID        string `json:"agent_id"`     // Set on tool events from inside a subagent
 	ToolInput      struct {
 		Questions []struct {
 			Question    string `json:"question"`
@@ -120,6 +243,7 @@ type HookData struct {
 				Description string `json:"description"`
 			} `json:"options"`
 		} `json:"questions"`
+		Plan string `json:"plan"` // ExitPlanMode
 	} `json:"tool_input"`
 }
 
@@ -134,6 +258,17 @@ func init() {
 }
 
 func main() {
+	// Config overrides (--config, --profile, --group-id ...) come before the subcommand
+	args, err := parseGlobalFlags(os.Args[1:])
+	if err == nil {
+		err = validateProfile(currentProfile())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = append(os.Args[:1], args...)
+
 	// Handle flags
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -166,7 +301,11 @@ func main() {
 	case "run":
 		// Run claude directly (used inside tmux sessions)
 		continueSession := len(os.Args) > 2 && os.Args[2] == "-c"
-		if err := runClaudeRaw(continueSession); err != nil {
+		resumeID := ""
+		if len(os.Args) > 3 && os.Args[2] == "-r" {
+			resumeID = os.Args[3]
+		}
+		if err := runClaudeRaw(continueSession, resumeID); err != nil {
 			os.Exit(1)
 		}
 		return
@@ -183,6 +322,12 @@ func main() {
 	case "doctor":
 		doctor()
 
+	case "health":
+		if err := handleHealthCommand(); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			os.Exit(1)
+		}
+
 	case "config":
 		config, err := loadConfig()
 		if err != nil {
@@ -202,10 +347,191 @@ func main() {
 			} else {
 				fmt.Println("openrouter_key: not set")
 			}
+			if config.GitHubToken != "" {
+				fmt.Println("github_token: configured")
+			} else {
+				fmt.Println("github_token: not set")
+			}
+			if config.GitHubWebhookSecret != "" {
+				fmt.Println("github_webhook_secret: configured")
+			} else {
+				fmt.Println("github_webhook_secret: not set")
+			}
+			if len(config.GitHubUsers) > 0 {
+				fmt.Printf("github_users: %s\n", strings.Join(config.GitHubUsers, ","))
+			} else {
+				fmt.Println("github_users: not set (github-listen starts no sessions)")
+			}
+			if config.RouterEndpoint != "" {
+				fmt.Printf("router_endpoint: %s\n", config.RouterEndpoint)
+			} else {
+				fmt.Printf("router_endpoint: %s (default)\n", openRouterEndpoint)
+			}
+			fmt.Printf("router_model: %s\n", routerModel(config))
+			if config.DefaultSession != "" {
+				fmt.Printf("default_session: %s\n", config.DefaultSession)
+			} else {
+				fmt.Println("default_session: none")
+			}
+			if config.CommandJailDir != "" {
+				fmt.Printf("command_jail_dir: %s\n", config.CommandJailDir)
+			} else {
+				fmt.Println("command_jail_dir: not set")
+			}
+			fmt.Printf("block_send_delay_ms: %d\n", config.BlockSendDelayMs)
+			if config.MaxConcurrentSessions > 0 {
+				fmt.Printf("max_concurrent_sessions: %d\n", config.MaxConcurrentSessions)
+			} else {
+				fmt.Println("max_concurrent_sessions: no limit")
+			}
+			if config.SessionCPUPercent > 0 {
+				fmt.Printf("session_cpu_percent: %d\n", config.SessionCPUPercent)
+			} else {
+				fmt.Println("session_cpu_percent: no limit")
+			}
+			if config.SessionMemoryMB > 0 {
+				fmt.Printf("session_memory_mb: %d\n", config.SessionMemoryMB)
+			} else {
+				fmt.Println("session_memory_mb: no limit")
+			}
+			fmt.Printf("session_nice: %d\n", config.SessionNice)
+			if config.IdleNotifyMinutes > 0 {
+				fmt.Printf("idle_notify_minutes: %d\n", config.IdleNotifyMinutes)
+			} else {
+				fmt.Println("idle_notify_minutes: off")
+			}
+			if config.DigestTime != "" {
+				fmt.Printf("digest_time: %s\n", config.DigestTime)
+			} else {
+				fmt.Println("digest_time: off")
+			}
+			if config.SummarizeBlocks > 0 {
+				fmt.Printf("summarize_blocks: %d\n", config.SummarizeBlocks)
+			} else {
+				fmt.Println("summarize_blocks: off")
+			}
+			if config.SummarizeChars > 0 {
+				fmt.Printf("summarize_chars: %d\n", config.SummarizeChars)
+			} else {
+				fmt.Println("summarize_chars: off")
+			}
+			if config.HibernateHours > 0 {
+				fmt.Printf("hibernate_hours: %d\n", config.HibernateHours)
+			} else {
+				fmt.Println("hibernate_hours: off")
+			}
+			if limit := watchdogLimit(config); limit > 0 {
+				fmt.Printf("watchdog_minutes: %d\n", int(limit.Minutes()))
+			} else {
+				fmt.Println("watchdog_minutes: off")
+			}
+			fmt.Printf("permission_timeout: %ds\n", int(permissionTimeout(config).Seconds()))
+			fmt.Printf("claude_start_timeout: %ds\n", int(claudeStartTimeout(config).Seconds()))
+			fmt.Printf("quote_prompt_in_completion: %v\n", config.QuotePromptInCompletion)
+			fmt.Printf("messenger: %s\n", configuredMessenger(config))
+			if config.RelayURL != "" {
+				fmt.Printf("relay_url: %s\n", config.RelayURL)
+			} else {
+				fmt.Printf("relay_url: %s (default)\n", defaultRelayURL)
+			}
+			if config.RelaySecret != "" {
+				fmt.Println("relay_secret: configured")
+			} else {
+				fmt.Println("relay_secret: not set")
+			}
+			fmt.Printf("machine_name: %s\n", machineName(config))
+			switch {
+			case config.FederationHub != "":
+				fmt.Printf("federation: following %s\n", config.FederationHub)
+			case config.FederationPort != 0 && config.FederationCert != "" && config.FederationKey != "":
+				fmt.Printf("federation: hub on port %d (TLS)\n", config.FederationPort)
+			case config.FederationPort != 0:
+				fmt.Printf("federation: hub on port %d (loopback only)\n", config.FederationPort)
+			default:
+				fmt.Println("federation: off")
+			}
+			if config.StorageBucket != "" {
+				fmt.Printf("storage: %s/%s (links expire in %s)\n", config.StorageEndpoint, config.StorageBucket, formatDuration(storageExpiry(config)))
+			} else {
+				fmt.Println("storage: not set (ccc send --store off)")
+			}
+			fmt.Printf("monitor_mode: %s\n", configuredMonitorMode(config))
+			fmt.Printf("confirm_destructive_commands: %v\n", config.ConfirmDestructive)
+			fmt.Printf("compact_snapshots: %v\n", config.CompactSnapshots)
+			fmt.Printf("topic_status: %v\n", config.TopicStatus)
+			fmt.Printf("status_pin: %v\n", config.StatusPin)
+			fmt.Printf("auto_resume: %v\n", config.AutoResume)
+			if backend := configuredTranscriptionBackend(config); backend != "" {
+				fmt.Printf("transcription_backend: %s\n", backend)
+			} else {
+				fmt.Println("transcription_backend: not set (voice messages off)")
+			}
+			fmt.Printf("secrets_backend: %s\n", secretsBackend(config))
+			fmt.Printf("log_level: %s\n", levelNames[configuredLogLevel(config)])
 			fmt.Println("\nUsage: ccc config <key> <value>")
 			fmt.Println("  ccc config projects-dir ~/Projects")
 			fmt.Println("  ccc config oauth-token <token>")
 			fmt.Println("  ccc config openrouter-key <key>")
+			fmt.Println("  ccc config github-token <token>    (\"off\" to use the gh CLI)")
+			fmt.Println("  ccc config github-webhook-secret <secret>")
+			fmt.Println("  ccc config github-users <login,...>")
+			fmt.Println("  ccc config router-endpoint <url>   (e.g. http://localhost:11434/v1, \"off\" for OpenRouter)")
+			fmt.Println("  ccc config router-model <model>")
+			fmt.Println("  ccc config default-session <name>  (\"off\" to route General messages as before)")
+			fmt.Println("  ccc config idle-notify <minutes>   (0 = off)")
+			fmt.Println("  ccc config digest-time <HH:MM|off>")
+			fmt.Println("  ccc config summarize-blocks <n>    (0 = off, needs the router)")
+			fmt.Println("  ccc config summarize-chars <n>     (0 = off, needs the router)")
+			fmt.Println("  ccc config hibernate <hours>       (0 = off)")
+			fmt.Println("  ccc config command-jail <dir>      (\"off\" to disable)")
+			fmt.Println("  ccc config block-send-delay-ms <ms>")
+			fmt.Println("  ccc config max-sessions <n>        (0 = no limit)")
+			fmt.Println("  ccc config session-cpu <percent>   (per session, 0 = no limit)")
+			fmt.Println("  ccc config session-memory <MB>     (per session, 0 = no limit)")
+			fmt.Println("  ccc config session-nice <0-19>")
+			fmt.Println("  ccc config watchdog <minutes>      (\"off\" to disable)")
+			fmt.Println("  ccc config permission-timeout <seconds>")
+			fmt.Println("  ccc config claude-start-timeout <seconds>")
+			fmt.Println("  ccc config quote-prompt <on|off>")
+			fmt.Println("  ccc config messenger <telegram|discord|slack|relay|matrix>")
+			fmt.Println("  ccc config discord-token <token>")
+			fmt.Println("  ccc config discord-channel <channel_id>")
+			fmt.Println("  ccc config discord-user <user_id>")
+			fmt.Println("  ccc config slack-app-token <xapp-...>")
+			fmt.Println("  ccc config slack-bot-token <xoxb-...>")
+			fmt.Println("  ccc config slack-channel <channel_id>")
+			fmt.Println("  ccc config slack-user <user_id>")
+			fmt.Println("  ccc config matrix-homeserver <url>")
+			fmt.Println("  ccc config matrix-token <token>")
+			fmt.Println("  ccc config matrix-user <@user:server>")
+			fmt.Println("  ccc config matrix-encrypt <on|off>")
+			fmt.Println("  ccc config relay-url <url>")
+			fmt.Println("  ccc config relay-secret <secret>")
+			fmt.Println("  ccc config machine-name <name>")
+			fmt.Println("  ccc config federation-port <port|off>")
+			fmt.Println("  ccc config federation-hub <url|off>")
+			fmt.Println("  ccc config federation-secret <secret>")
+			fmt.Println("  ccc config federation-cert <file|off>")
+			fmt.Println("  ccc config federation-key <file|off>")
+			fmt.Println("  ccc config storage-endpoint <url>  (S3 or R2, for ccc send --store)")
+			fmt.Println("  ccc config storage-bucket <bucket>")
+			fmt.Println("  ccc config storage-region <region>")
+			fmt.Println("  ccc config storage-access-key <key>")
+			fmt.Println("  ccc config storage-secret-key <secret>")
+			fmt.Println("  ccc config storage-expiry <hours>")
+			fmt.Println("  ccc config monitor-mode <tmux|hooks>")
+			fmt.Println("  ccc config confirm-commands <on|off>")
+			fmt.Println("  ccc config compact-snapshots <on|off>")
+			fmt.Println("  ccc config topic-status <on|off>")
+			fmt.Println("  ccc config status-pin <on|off>")
+			fmt.Println("  ccc config auto-resume <on|off>")
+			fmt.Println("  ccc config transcription-backend <local|openai|deepgram>")
+			fmt.Println("  ccc config transcription-key <key>")
+			fmt.Println("  ccc config transcription-cmd <command>")
+			fmt.Println("  ccc config inbox-dir <path>")
+			fmt.Println("  ccc config secrets <plain|keychain|encrypted>")
+			fmt.Println("  ccc config log-level <debug|info|warn|error>")
+			fmt.Println("  ccc config log-format <text|json>")
 			os.Exit(0)
 		}
 		key := os.Args[2]
@@ -232,6 +558,210 @@ func main() {
 				} else {
 					fmt.Println("not set")
 				}
+			case "github-token":
+				if config.GitHubToken != "" {
+					fmt.Println("configured")
+				} else {
+					fmt.Println("not set")
+				}
+			case "github-webhook-secret":
+				if config.GitHubWebhookSecret != "" {
+					fmt.Println("configured")
+				} else {
+					fmt.Println("not set")
+				}
+			case "github-users":
+				fmt.Println(strings.Join(config.GitHubUsers, ","))
+			case "router-endpoint":
+				if config.RouterEndpoint != "" {
+					fmt.Println(config.RouterEndpoint)
+				} else {
+					fmt.Println(openRouterEndpoint)
+				}
+			case "router-model":
+				fmt.Println(routerModel(config))
+			case "default-session":
+				if config.DefaultSession != "" {
+					fmt.Println(config.DefaultSession)
+				} else {
+					fmt.Println("none")
+				}
+			case "idle-notify":
+				fmt.Println(config.IdleNotifyMinutes)
+			case "digest-time":
+				if config.DigestTime != "" {
+					fmt.Println(config.DigestTime)
+				} else {
+					fmt.Println("off")
+				}
+			case "summarize-blocks":
+				fmt.Println(config.SummarizeBlocks)
+			case "summarize-chars":
+				fmt.Println(config.SummarizeChars)
+			case "hibernate":
+				fmt.Println(config.HibernateHours)
+			case "block-send-delay-ms":
+				fmt.Println(config.BlockSendDelayMs)
+			case "max-sessions":
+				fmt.Println(config.MaxConcurrentSessions)
+			case "session-cpu":
+				fmt.Println(config.SessionCPUPercent)
+			case "session-memory":
+				fmt.Println(config.SessionMemoryMB)
+			case "session-nice":
+				fmt.Println(config.SessionNice)
+			case "watchdog":
+				if limit := watchdogLimit(config); limit > 0 {
+					fmt.Println(int(limit.Minutes()))
+				} else {
+					fmt.Println("off")
+				}
+			case "permission-timeout":
+				fmt.Println(int(permissionTimeout(config).Seconds()))
+			case "claude-start-timeout":
+				fmt.Println(int(claudeStartTimeout(config).Seconds()))
+			case "quote-prompt":
+				fmt.Println(config.QuotePromptInCompletion)
+			case "command-jail":
+				if config.CommandJailDir != "" {
+					fmt.Println(config.CommandJailDir)
+				} else {
+					fmt.Println("not set")
+				}
+			case "messenger":
+				fmt.Println(configuredMessenger(config))
+			case "discord-token":
+				if config.DiscordBotToken != "" {
+					fmt.Println("configured")
+				} else {
+					fmt.Println("not set")
+				}
+			case "discord-channel":
+				fmt.Println(config.DiscordChannelID)
+			case "discord-user":
+				fmt.Println(config.DiscordUserID)
+			case "slack-app-token", "slack-bot-token":
+				token := config.SlackAppToken
+				if key == "slack-bot-token" {
+					token = config.SlackBotToken
+				}
+				if token != "" {
+					fmt.Println("configured")
+				} else {
+					fmt.Println("not set")
+				}
+			case "slack-channel":
+				fmt.Println(config.SlackChannelID)
+			case "slack-user":
+				fmt.Println(config.SlackUserID)
+			case "matrix-homeserver":
+				fmt.Println(config.MatrixHomeserver)
+			case "matrix-token":
+				if config.MatrixAccessToken != "" {
+					fmt.Println("configured")
+				} else {
+					fmt.Println("not set")
+				}
+			case "matrix-user":
+				fmt.Println(config.MatrixUserID)
+			case "matrix-encrypt":
+				fmt.Println(config.MatrixEncrypt)
+			case "relay-url":
+				if config.RelayURL != "" {
+					fmt.Println(config.RelayURL)
+				} else {
+					fmt.Println(defaultRelayURL)
+				}
+			case "relay-secret":
+				if config.RelaySecret != "" {
+					fmt.Println("configured")
+				} else {
+					fmt.Println("not set")
+				}
+			case "machine-name":
+				fmt.Println(machineName(config))
+			case "federation-port":
+				if config.FederationPort != 0 {
+					fmt.Println(config.FederationPort)
+				} else {
+					fmt.Println("off")
+				}
+			case "federation-hub":
+				if config.FederationHub != "" {
+					fmt.Println(config.FederationHub)
+				} else {
+					fmt.Println("off")
+				}
+			case "federation-secret":
+				if config.FederationSecret != "" {
+					fmt.Println("configured")
+				} else {
+					fmt.Println("not set")
+				}
+			case "federation-cert":
+				fmt.Println(config.FederationCert)
+			case "federation-key":
+				fmt.Println(config.FederationKey)
+			case "storage-endpoint":
+				fmt.Println(config.StorageEndpoint)
+			case "storage-bucket":
+				fmt.Println(config.StorageBucket)
+			case "storage-region":
+				if config.StorageRegion != "" {
+					fmt.Println(config.StorageRegion)
+				} else {
+					fmt.Println("us-east-1 (default)")
+				}
+			case "storage-access-key", "storage-secret-key":
+				if (key == "storage-access-key" && config.StorageAccessKey != "") || (key == "storage-secret-key" && config.StorageSecretKey != "") {
+					fmt.Println("configured")
+				} else {
+					fmt.Println("not set")
+				}
+			case "storage-expiry":
+				fmt.Println(formatDuration(storageExpiry(config)))
+			case "monitor-mode":
+				fmt.Println(configuredMonitorMode(config))
+			case "confirm-commands":
+				fmt.Println(config.ConfirmDestructive)
+			case "compact-snapshots":
+				fmt.Println(config.CompactSnapshots)
+			case "topic-status":
+				fmt.Println(config.TopicStatus)
+			case "status-pin":
+				fmt.Println(config.StatusPin)
+			case "auto-resume":
+				fmt.Println(config.AutoResume)
+			case "transcription-backend":
+				if backend := configuredTranscriptionBackend(config); backend != "" {
+					fmt.Println(backend)
+				} else {
+					fmt.Println("not set")
+				}
+			case "transcription-key":
+				if config.TranscriptionAPIKey != "" {
+					fmt.Println("configured")
+				} else {
+					fmt.Println("not set")
+				}
+			case "inbox-dir":
+				fmt.Println(inboxDir(config))
+			case "secrets":
+				fmt.Println(secretsBackend(config))
+			case "log-level":
+				fmt.Println(levelNames[configuredLogLevel(config)])
+			case "log-format":
+				if config.LogFormat == "json" {
+					fmt.Println("json")
+				} else {
+					fmt.Println("text")
+				}
+			case "transcription-cmd":
+				if config.TranscriptionCmd != "" {
+					fmt.Println(config.TranscriptionCmd)
+				} else {
+					fmt.Println("not set")
+				}
 			default:
 				fmt.Fprintf(os.Stderr, "Unknown config key: %s\n", key)
 				os.Exit(1)
@@ -268,113 +798,905 @@ func main() {
 				os.Exit(1)
 			}
 			fmt.Println("OpenRouter API key saved")
-		default:
-			fmt.Fprintf(os.Stderr, "Unknown config key: %s\n", key)
-			os.Exit(1)
-		}
-
-	case "setgroup":
-		config, err := loadConfig()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-		if err := setGroup(config); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "listen":
-		if err := listen(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "hook":
-		if err := handleHook(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "hook-permission":
-		if err := handlePermissionHook(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "hook-prompt":
-		if err := handlePromptHook(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "hook-question":
-		if err := handleQuestionHook(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "hook-output":
-		if err := handleOutputHook(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "hook-notification":
-		if err := handleNotificationHook(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "install":
-		if err := installHook(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-		if err := installSkill(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-		if err := installService(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "uninstall":
-		if err := uninstallHook(); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Could not uninstall hooks: %v\n", err)
-		}
-		uninstallSkill()
-		fmt.Println("CCC uninstalled")
-
-	case "send":
-		if len(os.Args) < 3 {
-			fmt.Fprintf(os.Stderr, "Usage: ccc send <file>\n")
-			os.Exit(1)
-		}
-		if err := handleSendFile(os.Args[2]); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "start":
-		// start <name> <work-dir> <prompt>
-		// Creates a Telegram topic, tmux session with Claude, and sends the prompt (detached)
-		if len(os.Args) < 5 {
-			fmt.Fprintf(os.Stderr, "Usage: ccc start <session-name> <work-dir> <prompt>\n")
-			os.Exit(1)
-		}
-		if err := startDetached(os.Args[2], os.Args[3], os.Args[4]); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
-		}
-
-	case "relay":
-		port := "8080"
-		if len(os.Args) >= 3 {
-			port = os.Args[2]
+		case "github-token":
+			if value == "off" {
+				value = ""
+			}
+			config.GitHubToken = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if value == "" {
+				fmt.Println("GitHub token removed; /pr and /issues use the gh CLI")
+			} else {
+				fmt.Println("GitHub token saved")
+			}
+		case "github-webhook-secret":
+			config.GitHubWebhookSecret = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("GitHub webhook secret saved")
+		case "github-users":
+			var users []string
+			for _, login := range strings.Split(value, ",") {
+				if login = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(login), "@")); login != "" {
+					users = append(users, login)
+				}
+			}
+			config.GitHubUsers = users
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if len(users) == 0 {
+				fmt.Println("GitHub users cleared: github-listen starts no sessions")
+			} else {
+				fmt.Printf("Issues labeled by %s start sessions\n", strings.Join(users, ", "))
+			}
+		case "router-endpoint":
+			if value == "off" {
+				value = ""
+			}
+			config.RouterEndpoint = strings.TrimRight(value, "/")
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Router endpoint set to: %s\n", routerChatURL(config))
+		case "router-model":
+			config.RouterModel = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Router model set to: %s\n", value)
+		case "default-session":
+			if value == "off" {
+				value = ""
+			} else if info := config.Sessions[value]; info == nil || info.TopicID == 0 {
+				fmt.Fprintf(os.Stderr, "Session '%s' not found\n", value)
+				os.Exit(1)
+			}
+			config.DefaultSession = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if value == "" {
+				fmt.Println("No default session")
+			} else {
+				fmt.Printf("Messages in the group's General area go to: %s\n", value)
+			}
+		case "watchdog":
+			minutes := -1
+			if value != "off" {
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 {
+					fmt.Fprintf(os.Stderr, "Invalid minutes: %s (use a number or \"off\")\n", value)
+					os.Exit(1)
+				}
+				minutes = n
+			}
+			config.WatchdogMinutes = minutes
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if minutes < 0 {
+				fmt.Println("Watchdog disabled (restart the listener to apply)")
+			} else {
+				fmt.Printf("Watchdog set to %d minutes (restart the listener to apply)\n", minutes)
+			}
+		case "permission-timeout":
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > maxPermissionTimeout {
+				fmt.Fprintf(os.Stderr, "Invalid seconds: %s (1-%d)\n", value, int(maxPermissionTimeout.Seconds()))
+				os.Exit(1)
+			}
+			config.PermissionTimeout = seconds
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Permission prompts wait %d seconds for a button\n", seconds)
+		case "claude-start-timeout":
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				fmt.Fprintf(os.Stderr, "Invalid seconds: %s\n", value)
+				os.Exit(1)
+			}
+			config.ClaudeStartTimeout = seconds
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("New sessions wait %d seconds for Claude's prompt\n", seconds)
+		case "quote-prompt":
+			if value != "on" && value != "off" {
+				fmt.Fprintf(os.Stderr, "Invalid value: %s (use on or off)\n", value)
+				os.Exit(1)
+			}
+			config.QuotePromptInCompletion = value == "on"
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Quote the prompt in completion messages: %s\n", value)
+		case "digest-time":
+			if value == "off" {
+				value = ""
+			} else if _, err := parseDigestTime(value); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			config.DigestTime = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if value == "" {
+				fmt.Println("Daily digest disabled")
+			} else {
+				fmt.Printf("Daily digest at %s\n", value)
+			}
+		case "idle-notify":
+			minutes, err := strconv.Atoi(value)
+			if err != nil || minutes < 0 {
+				fmt.Fprintf(os.Stderr, "Invalid minutes: %s\n", value)
+				os.Exit(1)
+			}
+			config.IdleNotifyMinutes = minutes
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if minutes == 0 {
+				fmt.Println("Idle notification disabled")
+			} else {
+				fmt.Printf("Idle notification set to %d minutes\n", minutes)
+			}
+		case "summarize-blocks", "summarize-chars":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "Invalid number: %s\n", value)
+				os.Exit(1)
+			}
+			unit := "blocks"
+			if key == "summarize-blocks" {
+				config.SummarizeBlocks = n
+			} else {
+				config.SummarizeChars = n
+				unit = "characters"
+			}
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if n == 0 {
+				fmt.Printf("Summarizing by %s disabled\n", unit)
+			} else {
+				fmt.Printf("Turns past %d %s are summarized\n", n, unit)
+				if !routerEnabled(config) {
+					fmt.Println("Note: summaries need the router LLM (ccc config openrouter-key or router-endpoint)")
+				}
+			}
+		case "hibernate":
+			hours, err := strconv.Atoi(value)
+			if err != nil || hours < 0 {
+				fmt.Fprintf(os.Stderr, "Invalid hours: %s\n", value)
+				os.Exit(1)
+			}
+			config.HibernateHours = hours
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if hours == 0 {
+				fmt.Println("Hibernation disabled")
+			} else {
+				fmt.Printf("Sessions idle for %d hours are hibernated\n", hours)
+			}
+		case "block-send-delay-ms":
+			ms, err := strconv.Atoi(value)
+			if err != nil || ms < 0 {
+				fmt.Fprintf(os.Stderr, "Invalid delay: %s\n", value)
+				os.Exit(1)
+			}
+			config.BlockSendDelayMs = ms
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Block send delay set to %dms\n", ms)
+		case "max-sessions":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "Invalid number: %s\n", value)
+				os.Exit(1)
+			}
+			config.MaxConcurrentSessions = n
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if n == 0 {
+				fmt.Println("Concurrent session limit removed")
+			} else {
+				fmt.Printf("At most %d sessions run at once\n", n)
+			}
+		case "session-cpu", "session-memory":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				fmt.Fprintf(os.Stderr, "Invalid number: %s\n", value)
+				os.Exit(1)
+			}
+			if key == "session-cpu" {
+				config.SessionCPUPercent = n
+			} else {
+				config.SessionMemoryMB = n
+			}
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if n == 0 {
+				fmt.Println("Limit removed")
+			} else {
+				fmt.Println("Limit set; it applies to sessions started from now on")
+			}
+		case "session-nice":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 19 {
+				fmt.Fprintf(os.Stderr, "Invalid niceness (0-19): %s\n", value)
+				os.Exit(1)
+			}
+			config.SessionNice = n
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Session niceness set to %d; it applies to sessions started from now on\n", n)
+		case "command-jail":
+			if value == "off" {
+				value = ""
+			}
+			config.CommandJailDir = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if value == "" {
+				fmt.Println("Command jail disabled")
+			} else {
+				fmt.Printf("Command jail set to: %s\n", expandPath(value))
+			}
+		case "messenger":
+			if value != messengerTelegram && value != messengerDiscord && value != messengerSlack && value != messengerRelay && value != messengerMatrix {
+				fmt.Fprintf(os.Stderr, "Unknown messenger: %s (use telegram, discord, slack, relay or matrix)\n", value)
+				os.Exit(1)
+			}
+			config.Messenger = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Messenger set to: %s (restart the listener to apply)\n", value)
+		case "discord-token":
+			config.DiscordBotToken = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("Discord bot token saved")
+		case "discord-channel", "discord-user":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				fmt.Fprintf(os.Stderr, "Invalid Discord ID: %s\n", value)
+				os.Exit(1)
+			}
+			if key == "discord-channel" {
+				config.DiscordChannelID = id
+			} else {
+				config.DiscordUserID = id
+			}
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s set to: %d\n", key, id)
+		case "slack-app-token", "slack-bot-token", "slack-channel", "slack-user":
+			switch key {
+			case "slack-app-token":
+				config.SlackAppToken = value
+			case "slack-bot-token":
+				config.SlackBotToken = value
+			case "slack-channel":
+				config.SlackChannelID = value
+			case "slack-user":
+				config.SlackUserID = value
+			}
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s saved\n", key)
+		case "matrix-homeserver", "matrix-token", "matrix-user":
+			switch key {
+			case "matrix-homeserver":
+				config.MatrixHomeserver = strings.TrimRight(value, "/")
+			case "matrix-token":
+				config.MatrixAccessToken = value
+			case "matrix-user":
+				if !strings.HasPrefix(value, "@") || !strings.Contains(value, ":") {
+					fmt.Fprintf(os.Stderr, "Invalid Matrix user ID: %s (use @user:server)\n", value)
+					os.Exit(1)
+				}
+				config.MatrixUserID = value
+			}
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s saved\n", key)
+		case "matrix-encrypt":
+			if value != "on" && value != "off" {
+				fmt.Fprintf(os.Stderr, "Invalid value: %s (use on or off)\n", value)
+				os.Exit(1)
+			}
+			config.MatrixEncrypt = value == "on"
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Matrix room encryption: %s (applies to new sessions; run ccc through pantalaimon)\n", value)
+		case "relay-url":
+			config.RelayURL = strings.TrimRight(value, "/")
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Relay URL set to: %s\n", config.RelayURL)
+		case "relay-secret":
+			config.RelaySecret = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("Relay secret saved")
+		case "machine-name":
+			if strings.ContainsAny(value, " \t") {
+				fmt.Fprintf(os.Stderr, "Invalid machine name: %s (no spaces)\n", value)
+				os.Exit(1)
+			}
+			config.MachineName = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Machine name set to: %s\n", machineName(config))
+		case "federation-port":
+			port := 0
+			if value != "off" {
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 || n > 65535 {
+					fmt.Fprintf(os.Stderr, "Invalid port: %s (1-65535 or off)\n", value)
+					os.Exit(1)
+				}
+				port = n
+			}
+			config.FederationPort = port
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if port == 0 {
+				fmt.Println("Federation hub off")
+			} else {
+				fmt.Printf("Federation hub on port %d (restart the listener)\n", port)
+			}
+		case "federation-hub":
+			if value == "off" {
+				value = ""
+			} else if err := checkFederationHub(value); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+			config.FederationHub = strings.TrimRight(value, "/")
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if config.FederationHub == "" {
+				fmt.Println("Federation hub cleared: this machine polls Telegram again")
+			} else {
+				fmt.Printf("Following federation hub %s (restart the listener)\n", config.FederationHub)
+			}
+		case "federation-secret":
+			config.FederationSecret = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("Federation secret saved")
+		case "federation-cert", "federation-key":
+			if value == "off" {
+				value = ""
+			} else if _, err := os.Stat(expandPath(value)); err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot read %s: %v\n", value, err)
+				os.Exit(1)
+			}
+			if key == "federation-cert" {
+				config.FederationCert = value
+			} else {
+				config.FederationKey = value
+			}
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s saved (restart the listener)\n", key)
+		case "storage-endpoint", "storage-bucket", "storage-region", "storage-access-key", "storage-secret-key":
+			switch key {
+			case "storage-endpoint":
+				config.StorageEndpoint = strings.TrimRight(value, "/")
+			case "storage-bucket":
+				config.StorageBucket = value
+			case "storage-region":
+				config.StorageRegion = value
+			case "storage-access-key":
+				config.StorageAccessKey = value
+			case "storage-secret-key":
+				config.StorageSecretKey = value
+			}
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s saved\n", key)
+		case "storage-expiry":
+			hours, err := strconv.Atoi(value)
+			if err != nil || hours <= 0 || hours > maxStorageExpiryHours {
+				fmt.Fprintf(os.Stderr, "Invalid expiry: %s (hours, 1-%d)\n", value, maxStorageExpiryHours)
+				os.Exit(1)
+			}
+			config.StorageExpiryHours = hours
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Stored links expire in %s\n", formatDuration(storageExpiry(config)))
+		case "transcription-backend":
+			if value != transcribeLocal && value != transcribeOpenAI && value != transcribeDeepgram && value != "off" {
+				fmt.Fprintf(os.Stderr, "Unknown transcription backend: %s (use local, openai, deepgram or off)\n", value)
+				os.Exit(1)
+			}
+			if value == "off" {
+				value = ""
+			}
+			config.TranscriptionBackend = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			if value == "" {
+				fmt.Println("Transcription backend cleared")
+			} else {
+				fmt.Printf("Transcription backend set to: %s\n", value)
+			}
+		case "transcription-key":
+			config.TranscriptionAPIKey = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("Transcription API key saved")
+		case "inbox-dir":
+			config.InboxDir = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Inbox set to: %s\n", inboxDir(config))
+		case "secrets":
+			if err := setSecretsBackend(config, value); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Secrets now stored: %s\n", secretsBackend(config))
+		case "log-level":
+			if _, ok := parseLogLevel(value); !ok {
+				fmt.Fprintf(os.Stderr, "Invalid level: %s (use debug, info, warn or error)\n", value)
+				os.Exit(1)
+			}
+			config.LogLevel = strings.ToLower(value)
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Log level set to: %s (restart the listener to apply)\n", config.LogLevel)
+		case "log-format":
+			if value != "text" && value != "json" {
+				fmt.Fprintf(os.Stderr, "Invalid format: %s (use text or json)\n", value)
+				os.Exit(1)
+			}
+			config.LogFormat = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Log format set to: %s (restart the listener to apply)\n", value)
+		case "transcription-cmd":
+			config.TranscriptionCmd = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Transcription command set to: %s\n", value)
+		case "confirm-commands":
+			if value != "on" && value != "off" {
+				fmt.Fprintf(os.Stderr, "Invalid value: %s (use on or off)\n", value)
+				os.Exit(1)
+			}
+			config.ConfirmDestructive = value == "on"
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Confirm destructive /c commands: %s\n", value)
+		case "compact-snapshots":
+			if value != "on" && value != "off" {
+				fmt.Fprintf(os.Stderr, "Invalid value: %s (use on or off)\n", value)
+				os.Exit(1)
+			}
+			config.CompactSnapshots = value == "on"
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Transcript snapshots before compaction: %s\n", value)
+		case "topic-status":
+			if value != "on" && value != "off" {
+				fmt.Fprintf(os.Stderr, "Invalid value: %s (use on or off)\n", value)
+				os.Exit(1)
+			}
+			config.TopicStatus = value == "on"
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Branch and state in topic names: %s\n", value)
+		case "status-pin":
+			if value != "on" && value != "off" {
+				fmt.Fprintf(os.Stderr, "Invalid value: %s (use on or off)\n", value)
+				os.Exit(1)
+			}
+			config.StatusPin = value == "on"
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Pinned status message in session topics: %s\n", value)
+		case "auto-resume":
+			if value != "on" && value != "off" {
+				fmt.Fprintf(os.Stderr, "Invalid value: %s (use on or off)\n", value)
+				os.Exit(1)
+			}
+			config.AutoResume = value == "on"
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Resume after the usage limit resets: %s\n", value)
+		case "monitor-mode":
+			if value != monitorModeTmux && value != monitorModeHooks {
+				fmt.Fprintf(os.Stderr, "Unknown monitor mode: %s (use tmux or hooks)\n", value)
+				os.Exit(1)
+			}
+			config.MonitorMode = value
+			if err := saveConfig(config); err != nil {
+				fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Monitor mode set to: %s (restart the listener to apply)\n", value)
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown config key: %s\n", key)
+			os.Exit(1)
+		}
+
+	case "setgroup":
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		name := ""
+		if len(os.Args) > 2 {
+			if len(os.Args) != 4 || os.Args[2] != "--name" {
+				fmt.Fprintf(os.Stderr, "Usage: ccc setgroup [--name <name>]\n")
+				os.Exit(1)
+			}
+			name = os.Args[3]
+		}
+		if err := setGroup(config, name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "listen":
+		if err := listen(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "hook":
+		if err := handleHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "hook-event":
+		if err := handleHookEvent(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "hook-lifecycle":
+		if err := handleLifecycleHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "hook-permission":
+		if err := handlePermissionHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "hook-prompt":
+		if err := handlePromptHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "hook-question":
+		if err := handleQuestionHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "hook-plan":
+		if err := handlePlanHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "hook-output":
+		if err := handleOutputHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "hook-notification":
+		if err := handleNotificationHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "install":
+		if err := installHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := installSkill(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := installService(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "uninstall":
+		if err := uninstallHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not uninstall hooks: %v\n", err)
+		}
+		uninstallSkill()
+		fmt.Println("CCC uninstalled")
+
+	case "send":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Usage: ccc send [--encrypt|--store] <file|dir>...\n")
+			os.Exit(1)
+		}
+		if err := handleSendFile(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "headless":
+		// headless <session> [on|off]
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Usage: ccc headless <session> [on|off]\n")
+			os.Exit(1)
+		}
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		mode := sessionModeHeadless
+		if len(os.Args) > 3 && os.Args[3] == "off" {
+			mode = sessionModeTmux
+		}
+		if err := setSessionMode(config, os.Args[2], mode); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Session %s is now %s\n", os.Args[2], mode)
+
+	case "ls":
+		if err := handleLsCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "gc":
+		if err := handleGCCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "logs":
+		if err := handleLogsCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "attach":
+		if err := handleAttachCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "completion":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Usage: ccc completion <bash|zsh|fish>\n")
+			os.Exit(1)
+		}
+		script, err := completionScript(os.Args[2])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(script)
+
+	case "export":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Usage: ccc export <session>\n")
+			os.Exit(1)
+		}
+		if err := handleExportCommand(os.Args[2]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "receive":
+		if err := handleReceiveFile(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "start":
+		// start [flags] <name> <work-dir> <prompt>
+		// Creates a Telegram topic, a session with Claude, and sends the prompt (detached)
+		name, workDir, prompt, opts, err := parseStartArgs(os.Args[2:], os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := startDetached(name, workDir, prompt, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "batch":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Usage: ccc batch <file.yaml>\n")
+			os.Exit(1)
+		}
+		if err := runBatch(os.Args[2]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "search":
+		if len(os.Args) < 3 {
+			fmt.Fprintf(os.Stderr, "Usage: ccc search <query>\n")
+			os.Exit(1)
+		}
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		query := strings.Join(os.Args[2:], " ")
+		fmt.Println(formatSearchHits(query, searchSessions(config, query)))
+
+	case "pair":
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := pairRelay(config, len(os.Args) >= 3 && os.Args[2] == "--reset"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "rpc":
+		if err := handleRPCCommand(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "away":
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		reply, err := handleAwayCommand(config, strings.Join(os.Args[2:], " "))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(reply)
+
+	case "cost":
+		config, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(formatCostReport(collectSessionUsage(config, time.Now())))
+
+	case "web":
+		port := "8377"
+		if len(os.Args) >= 3 {
+			port = os.Args[2]
+		}
+		if err := runWebServer(port); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "github-listen":
+		port := "8090"
+		if len(os.Args) >= 3 {
+			port = os.Args[2]
+		}
+		if err := runGitHubListener(port); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "relay":
+		port := "8080"
+		opts := relayOptionsFromEnv()
+		for _, arg := range os.Args[2:] {
+			if arg == "--control" {
+				opts.Control = true
+			} else {
+				port = arg
+			}
+		}
+		if err := runRelayServer(port, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-		runRelayServer(port)
 
 	default:
 		if err := send(strings.Join(os.Args[1:], " ")); err != nil {
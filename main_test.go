@@ -2,9 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
 )
 
 // TestSessionName tests the sessionName function
@@ -104,9 +111,14 @@ func TestConfigSaveLoad(t *testing.T) {
 	if err := saveConfig(config); err != nil {
 		t.Fatalf("saveConfig failed: %v", err)
 	}
+	for name, info := range config.Sessions {
+		if err := saveSession(name, info); err != nil {
+			t.Fatalf("saveSession failed: %v", err)
+		}
+	}
 
 	// Verify file exists
-	configPath := filepath.Join(tmpDir, ".ccc.json")
+	configPath := getConfigPath()
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		t.Fatal("Config file was not created")
 	}
@@ -141,6 +153,46 @@ func TestConfigSaveLoad(t *testing.T) {
 	}
 }
 
+// TestConfigSessionsMigration tests that sessions in ~/.ccc.json move to the store
+func TestConfigSessionsMigration(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+
+	configPath := filepath.Join(tmpDir, ".ccc.json")
+	data := []byte(`{"bot_token": "test", "chat_id": 123, "sessions": {"project1": {"topic_id": 100, "path": "/home/user/project1"}}}`)
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	loaded, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if info := loaded.Sessions["project1"]; info == nil || info.TopicID != 100 {
+		t.Fatalf("Sessions[project1] = %+v, want topic 100", info)
+	}
+
+	rewritten, err := os.ReadFile(getConfigPath())
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if strings.Contains(string(rewritten), "project1") {
+		t.Errorf("config still contains sessions after migration: %s", rewritten)
+	}
+
+	// Deleting from the store must stick: the JSON no longer re-seeds it
+	if err := deleteSession("project1"); err != nil {
+		t.Fatalf("deleteSession failed: %v", err)
+	}
+	loaded, err = loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if len(loaded.Sessions) != 0 {
+		t.Errorf("Sessions = %v, want empty after delete", loaded.Sessions)
+	}
+}
+
 // TestConfigLoadNonExistent tests loading non-existent config
 func TestConfigLoadNonExistent(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "ccc-test-*")
@@ -463,7 +515,7 @@ func TestConfigFilePermissions(t *testing.T) {
 		t.Fatalf("saveConfig failed: %v", err)
 	}
 
-	configPath := filepath.Join(tmpDir, ".ccc.json")
+	configPath := getConfigPath()
 	info, err := os.Stat(configPath)
 	if err != nil {
 		t.Fatalf("Failed to stat config file: %v", err)
@@ -588,3 +640,414 @@ func findSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+// TestJailCommand tests the best-effort command jail
+func TestJailCommand(t *testing.T) {
+	jail := "/srv/jail"
+	config := &Config{CommandJailDir: jail}
+
+	tests := []struct {
+		name    string
+		cmd     string
+		dir     string
+		wantDir string
+		wantErr bool
+	}{
+		{"plain command", "ls -la", "/home/user", jail, false},
+		{"absolute inside", "cat /srv/jail/notes.txt", jail, jail, false},
+		{"absolute outside", "cat /etc/passwd", jail, "", true},
+		{"redirect outside", "echo hi >/tmp/x", jail, "", true},
+		{"parent escape", "ls ../..", jail, "", true},
+		{"parent inside", "ls sub/../other", "/srv/jail/sub", "/srv/jail/sub", false},
+		{"home outside", "ls ~/secrets", jail, "", true},
+		{"prefix sibling", "ls /srv/jailbreak", jail, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := jailCommand(config, tt.cmd, tt.dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("jailCommand(%q) error = %v, wantErr %v", tt.cmd, err, tt.wantErr)
+			}
+			if !tt.wantErr && dir != tt.wantDir {
+				t.Errorf("jailCommand(%q) dir = %q, want %q", tt.cmd, dir, tt.wantDir)
+			}
+		})
+	}
+
+	// No jail configured: everything passes through unchanged
+	dir, err := jailCommand(&Config{}, "cat /etc/passwd", "/home/user")
+	if err != nil || dir != "/home/user" {
+		t.Errorf("jailCommand without jail = (%q, %v), want (/home/user, nil)", dir, err)
+	}
+}
+
+// TestClaudeStartTimeout tests the configurable startup timeout
+func TestClaudeStartTimeout(t *testing.T) {
+	if got := claudeStartTimeout(&Config{}); got != defaultClaudeStartTimeout {
+		t.Errorf("default timeout = %v, want %v", got, defaultClaudeStartTimeout)
+	}
+	if got := claudeStartTimeout(nil); got != defaultClaudeStartTimeout {
+		t.Errorf("nil config timeout = %v, want %v", got, defaultClaudeStartTimeout)
+	}
+	if got := claudeStartTimeout(&Config{ClaudeStartTimeout: 90}); got != 90*time.Second {
+		t.Errorf("configured timeout = %v, want 90s", got)
+	}
+}
+
+// TestAuthCodeScoping tests that only messages in the /auth chat are taken as the code
+func TestAuthCodeScoping(t *testing.T) {
+	gen, ok := beginAuth(100, 5)
+	if !ok {
+		t.Fatal("beginAuth should succeed when no auth is running")
+	}
+	defer endAuth(gen)
+
+	if _, ok := beginAuth(100, 5); ok {
+		t.Error("second beginAuth should fail while auth is in progress")
+	}
+
+	if _, ok := isAuthCodeMessage(100, 5, "code123"); ok {
+		t.Error("message should not be taken as code before the URL is sent")
+	}
+
+	waitForAuthCode(&Config{}, gen)
+
+	if _, ok := isAuthCodeMessage(100, 7, "code123"); ok {
+		t.Error("message in another thread should not be taken as code")
+	}
+	if _, ok := isAuthCodeMessage(100, 5, "/list"); ok {
+		t.Error("commands should not be taken as code")
+	}
+	if got, ok := isAuthCodeMessage(100, 5, "code123"); !ok || got != gen {
+		t.Errorf("isAuthCodeMessage = (%d, %v), want (%d, true)", got, ok, gen)
+	}
+
+	if !endAuth(gen) {
+		t.Error("endAuth should end the running flow")
+	}
+	if endAuth(gen) {
+		t.Error("endAuth should be a no-op for an already ended flow")
+	}
+	if authActive(gen) {
+		t.Error("flow should not be active after endAuth")
+	}
+}
+
+func TestPermissionCallback(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	label, ok := handlePermissionCallback("perm:abcd1234:always")
+	if !ok || label != "♾ Always allowed" {
+		t.Fatalf("handlePermissionCallback = %q, %v", label, ok)
+	}
+	data, err := os.ReadFile(permissionDecisionPath("abcd1234"))
+	if err != nil || string(data) != "always" {
+		t.Errorf("decision file = %q, %v; want always", data, err)
+	}
+
+	for _, bad := range []string{"perm:abcd1234", "perm:abcd1234:maybe", "perm:../../x:allow"} {
+		if _, ok := handlePermissionCallback(bad); ok {
+			t.Errorf("handlePermissionCallback(%q) should fail", bad)
+		}
+	}
+}
+
+func TestSummarizeToolInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected string
+	}{
+		{"bash command", `{"tool_input":{"command":"rm -rf build","description":"clean"}}`, "rm -rf build"},
+		{"file path", `{"tool_input":{"file_path":"/tmp/a.go","content":"package a"}}`, "/tmp/a.go"},
+		{"other input", `{"tool_input":{"query":"x"}}`, `{"query":"x"}`},
+		{"no input", `{}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := summarizeToolInput("Tool", []byte(tt.raw)); result != tt.expected {
+				t.Errorf("summarizeToolInput() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPermissionHookOutput(t *testing.T) {
+	var out struct {
+		HookSpecificOutput struct {
+			HookEventName string `json:"hookEventName"`
+			Decision      struct {
+				Behavior string `json:"behavior"`
+			} `json:"decision"`
+		} `json:"hookSpecificOutput"`
+	}
+	if err := json.Unmarshal(permissionHookOutput("deny"), &out); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if out.HookSpecificOutput.HookEventName != "PermissionRequest" || out.HookSpecificOutput.Decision.Behavior != "deny" {
+		t.Errorf("permissionHookOutput = %+v", out)
+	}
+}
+
+func TestPreToolUseHookOutput(t *testing.T) {
+	if got := string(preToolUseHookOutput("allow")); got != `{"decision":"approve"}` {
+		t.Errorf("allow = %s", got)
+	}
+	var out struct {
+		Decision string `json:"decision"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.Unmarshal(preToolUseHookOutput("deny"), &out); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if out.Decision != "block" || out.Reason == "" {
+		t.Errorf("deny = %+v, want block with a reason", out)
+	}
+}
+
+func TestPermissionTimeout(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    time.Duration
+	}{
+		{0, 5 * time.Minute},
+		{90, 90 * time.Second},
+		{100000, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := permissionTimeout(&Config{PermissionTimeout: tt.seconds}); got != tt.want {
+			t.Errorf("permissionTimeout(%d) = %v, want %v", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+func TestRelayAuth(t *testing.T) {
+	secret := "relay-secret"
+	server := httptest.NewServer(newRelayMux(relayOptions{Secret: secret, MaxTransfers: 1}))
+	defer server.Close()
+
+	register := func(token, sig string) int {
+		body := strings.NewReader(`{"token":"` + token + `","filename":"a.zip","size":1}`)
+		req, _ := http.NewRequest("POST", server.URL+"/register", body)
+		if sig != "" {
+			req.Header.Set(relaySignatureHeader, sig)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("register failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	token := "0123456789abcdef0123456789abcdef"
+	if code := register(token, ""); code != http.StatusUnauthorized {
+		t.Errorf("unsigned register = %d, want 401", code)
+	}
+	if code := register(token, signRelayToken("wrong", token)); code != http.StatusUnauthorized {
+		t.Errorf("wrongly signed register = %d, want 401", code)
+	}
+	if code := register(token, signRelayToken(secret, token)); code != http.StatusOK {
+		t.Errorf("signed register = %d, want 200", code)
+	}
+	other := "fedcba9876543210fedcba9876543210"
+	if code := register(other, signRelayToken(secret, other)); code != http.StatusServiceUnavailable {
+		t.Errorf("register over limit = %d, want 503", code)
+	}
+
+	// The download link exposes the token, so cancelling must be signed too
+	resp, err := http.Get(server.URL + "/cancel/" + token)
+	if err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unsigned cancel = %d, want 401", resp.StatusCode)
+	}
+	cancelRelayTransfer(server.URL, secret, token)
+	if code := register(other, signRelayToken(secret, other)); code != http.StatusOK {
+		t.Errorf("register after cancel = %d, want 200", code)
+	}
+	cancelRelayTransfer(server.URL, secret, other)
+}
+
+func TestVerifyRelaySignatureOpen(t *testing.T) {
+	if !verifyRelaySignature("", "token", "") {
+		t.Error("relay without a secret should accept unsigned requests")
+	}
+	if verifyRelaySignature("s", "token", "") {
+		t.Error("relay with a secret should reject unsigned requests")
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		header     string
+		start, end int64
+		partial    bool
+		wantErr    bool
+	}{
+		{"", 0, 0, false, false},
+		{"bytes=100-", 100, 999, true, false},
+		{"bytes=100-199", 100, 199, true, false},
+		{"bytes=900-5000", 900, 999, true, false},
+		{"bytes=-100", 900, 999, true, false},
+		{"bytes=0-1,5-6", 0, 0, false, false},
+		{"bytes=1000-", 0, 0, false, true},
+		{"bytes=200-100", 0, 0, false, true},
+		{"bytes=abc", 0, 0, false, true},
+	}
+
+	for _, tt := range tests {
+		start, end, partial, err := parseByteRange(tt.header, 1000)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseByteRange(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			continue
+		}
+		if start != tt.start || end != tt.end || partial != tt.partial {
+			t.Errorf("parseByteRange(%q) = %d, %d, %v, want %d, %d, %v", tt.header, start, end, partial, tt.start, tt.end, tt.partial)
+		}
+	}
+}
+
+func TestRelayRangeDownload(t *testing.T) {
+	server := httptest.NewServer(newRelayMux(relayOptions{}))
+	defer server.Close()
+
+	content := strings.Repeat("0123456789", 10)
+	token := "range0123456789abcdef0123456789"
+	body := strings.NewReader(`{"token":"` + token + `","filename":"a.bin","size":100}`)
+	resp, err := http.Post(server.URL+"/register", "application/json", body)
+	if err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	resp.Body.Close()
+	defer cancelRelayTransfer(server.URL, "", token)
+
+	type result struct {
+		code         int
+		contentRange string
+		body         string
+	}
+	done := make(chan result)
+	go func() {
+		req, _ := http.NewRequest("GET", server.URL+"/d/"+token+"/a.bin", nil)
+		req.Header.Set("Range", "bytes=95-")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			done <- result{}
+			return
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		done <- result{resp.StatusCode, resp.Header.Get("Content-Range"), string(data)}
+	}()
+
+	// Wait for the download to be requested, then stream like an old sender: from byte 0
+	var offset string
+	for i := 0; i < 100; i++ {
+		resp, err := http.Get(server.URL + "/status/" + token)
+		if err != nil {
+			t.Fatalf("status failed: %v", err)
+		}
+		status, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(status) == "ready" {
+			offset = resp.Header.Get(relayOffsetHeader)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if offset != "95" {
+		t.Fatalf("status offset = %q, want 95", offset)
+	}
+	streamResp, err := http.Post(server.URL+"/stream/"+token, "application/octet-stream", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	streamResp.Body.Close()
+
+	got := <-done
+	if got.code != http.StatusPartialContent {
+		t.Errorf("status = %d, want 206", got.code)
+	}
+	if got.contentRange != "bytes 95-99/100" {
+		t.Errorf("Content-Range = %q, want bytes 95-99/100", got.contentRange)
+	}
+	if got.body != "56789" {
+		t.Errorf("body = %q, want 56789", got.body)
+	}
+}
+
+func TestSessionFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	for i := 1; i <= maxSessionFiles+5; i++ {
+		if err := recordSessionFile("proj", SessionFile{MessageID: int64(i), FileName: "f.txt"}); err != nil {
+			t.Fatalf("recordSessionFile failed: %v", err)
+		}
+	}
+	files, err := loadSessionFiles("proj")
+	if err != nil {
+		t.Fatalf("loadSessionFiles failed: %v", err)
+	}
+	if len(files) != maxSessionFiles {
+		t.Fatalf("kept %d files, want %d", len(files), maxSessionFiles)
+	}
+	if files[0].MessageID != 6 || files[len(files)-1].MessageID != int64(maxSessionFiles+5) {
+		t.Errorf("kept messages %d..%d, want the newest", files[0].MessageID, files[len(files)-1].MessageID)
+	}
+
+	if other, _ := loadSessionFiles("other"); len(other) != 0 {
+		t.Errorf("unrelated session has %d files", len(other))
+	}
+}
+
+func TestStoreLookupsWithoutBuckets(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// No store yet: lookups read it as empty and don't create it
+	if sessions, err := loadSessions(); err != nil || len(sessions) != 0 {
+		t.Errorf("loadSessions = %v, %v; want an empty map", sessions, err)
+	}
+	if _, err := os.Stat(getStorePath()); !os.IsNotExist(err) {
+		t.Error("a lookup created the store")
+	}
+
+	// A store from before a bucket existed
+	db, err := bolt.Open(getStorePath(), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket(sessionsBucket)
+		return err
+	})
+	db.Close()
+	if files, err := loadSessionFiles("proj"); err != nil || len(files) != 0 {
+		t.Errorf("loadSessionFiles = %v, %v", files, err)
+	}
+	if _, _, ok := findReactionMessage(1); ok {
+		t.Error("findReactionMessage found a message in an empty store")
+	}
+	if seen, err := loadControlReplays(); err != nil || len(seen) != 0 {
+		t.Errorf("loadControlReplays = %v, %v", seen, err)
+	}
+	if cache, err := loadStoredBlockCache("proj"); err != nil || cache == nil {
+		t.Errorf("loadStoredBlockCache = %v, %v", cache, err)
+	}
+}
+
+func TestUniqueFilePath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.pdf")
+	if got := uniqueFilePath(path); got != path {
+		t.Errorf("uniqueFilePath = %q, want %q", got, path)
+	}
+	os.WriteFile(path, nil, 0600)
+	if got, want := uniqueFilePath(path), filepath.Join(dir, "report (1).pdf"); got != want {
+		t.Errorf("uniqueFilePath = %q, want %q", got, want)
+	}
+}
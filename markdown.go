@@ -0,0 +1,116 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// markdownV2Special are the characters Telegram MarkdownV2 requires escaping
+// outside code entities
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// escapeMarkdownV2 escapes text for use outside code entities
+func escapeMarkdownV2(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownV2Special, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// escapeMarkdownV2Code escapes text inside code and pre entities, where only ` and \ are special
+func escapeMarkdownV2Code(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s)
+}
+
+var (
+	// markdownInline matches inline code or **bold** spans within a line
+	markdownInline = regexp.MustCompile("`[^`\n]+`|\\*\\*[^*\n]+\\*\\*")
+	// markdownHeader matches "# Title" through "###### Title"
+	markdownHeader = regexp.MustCompile(`^#{1,6}\s+(.+)$`)
+	// fenceLang keeps only language names Telegram accepts after an opening fence
+	fenceLang = regexp.MustCompile(`^[A-Za-z0-9_+-]*$`)
+)
+
+// formatLineMarkdownV2 converts one non-code line: headers and **bold**
+// become bold, `code` stays inline code, everything else is escaped.
+func formatLineMarkdownV2(line string) string {
+	if m := markdownHeader.FindStringSubmatch(line); m != nil {
+		return "*" + escapeMarkdownV2(strings.TrimSpace(m[1])) + "*"
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, loc := range markdownInline.FindAllStringIndex(line, -1) {
+		sb.WriteString(escapeMarkdownV2(line[last:loc[0]]))
+		span := line[loc[0]:loc[1]]
+		if strings.HasPrefix(span, "`") {
+			sb.WriteString("`" + escapeMarkdownV2Code(span[1:len(span)-1]) + "`")
+		} else {
+			sb.WriteString("*" + escapeMarkdownV2(span[2:len(span)-2]) + "*")
+		}
+		last = loc[1]
+	}
+	sb.WriteString(escapeMarkdownV2(line[last:]))
+	return sb.String()
+}
+
+// maxOpenQuoteLines is the longest quote shown in full; longer ones become
+// expandable blockquotes that start collapsed
+const maxOpenQuoteLines = 3
+
+// toMarkdownV2 converts Claude's markdown-ish output to Telegram MarkdownV2.
+// Fenced code becomes pre blocks; a fence left open (e.g. by splitting a long
+// message) is closed at the end so the result always parses. "> " lines
+// become blockquotes.
+func toMarkdownV2(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	inFence := false
+	quoteStart := -1 // index in out of the open quote's first line
+	closeQuote := func() {
+		if quoteStart >= 0 && len(out)-quoteStart > maxOpenQuoteLines {
+			out[quoteStart] = "**" + out[quoteStart]
+			out[len(out)-1] += "||"
+		}
+		quoteStart = -1
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !inFence && strings.HasPrefix(trimmed, ">") {
+			if quoteStart < 0 {
+				quoteStart = len(out)
+			}
+			out = append(out, ">"+formatLineMarkdownV2(strings.TrimPrefix(trimmed[1:], " ")))
+			continue
+		}
+		closeQuote()
+		if strings.HasPrefix(trimmed, "```") {
+			if inFence {
+				out = append(out, "```")
+				inFence = false
+				continue
+			}
+			lang := strings.TrimPrefix(trimmed, "```")
+			if !fenceLang.MatchString(lang) {
+				lang = ""
+			}
+			out = append(out, "```"+lang)
+			inFence = true
+			continue
+		}
+		if inFence {
+			out = append(out, escapeMarkdownV2Code(line))
+		} else {
+			out = append(out, formatLineMarkdownV2(line))
+		}
+	}
+	closeQuote()
+	if inFence {
+		out = append(out, "```")
+	}
+	return strings.Join(out, "\n")
+}
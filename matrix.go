@@ -0,0 +1,629 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Matrix backend: each session is a private room the bot creates and invites
+// MatrixUserID to, recorded in MatrixRooms under the session's topic ID.
+// Input is read with /sync long polling. Matrix event IDs are strings, so
+// messages get local numeric IDs (newLocalID) mapped to them in memory.
+//
+// ccc doesn't speak Olm/Megolm itself: for end-to-end encrypted rooms
+// (matrix_encrypt), point matrix_homeserver at a pantalaimon proxy, which
+// encrypts and decrypts on the bot's behalf.
+
+// matrixMaxLen keeps messages well under Matrix's 64 KB event limit
+const matrixMaxLen = 16000
+
+// matrixButtonKeys are the reactions offered for keyboard buttons, in order
+var matrixButtonKeys = []string{"1️⃣", "2️⃣", "3️⃣", "4️⃣", "5️⃣", "6️⃣", "7️⃣", "8️⃣", "9️⃣", "🔟"}
+
+// matrixMaxEvents caps the message IDs remembered for edits and buttons
+const matrixMaxEvents = 2000
+
+var matrixClient = &http.Client{Timeout: 60 * time.Second}
+
+// matrixEvents maps local message IDs to Matrix event IDs, and messages sent
+// with a keyboard to their buttons' callback data
+var matrixEvents = struct {
+	sync.Mutex
+	ids     map[int64]string
+	order   []int64
+	buttons map[string]matrixKeyboard // event ID -> keyboard
+}{ids: make(map[int64]string), buttons: make(map[string]matrixKeyboard)}
+
+// matrixKeyboard is a message sent with buttons, answered by reacting
+type matrixKeyboard struct {
+	MessageID int64
+	Text      string
+	Data      []string // callback data, one per matrixButtonKeys entry
+}
+
+// press returns the callback data of the button a reaction picks, "" for
+// other reactions
+func (kb matrixKeyboard) press(reaction string) string {
+	// Clients differ in whether keycaps carry the U+FE0F selector
+	reaction = strings.ReplaceAll(reaction, "\ufe0f", "")
+	for i, data := range kb.Data {
+		if reaction == strings.ReplaceAll(matrixButtonKeys[i], "\ufe0f", "") {
+			return data
+		}
+	}
+	return ""
+}
+
+// rememberMatrixEvent returns a local ID for an event
+func rememberMatrixEvent(eventID string) int64 {
+	id := newLocalID()
+	matrixEvents.Lock()
+	defer matrixEvents.Unlock()
+	matrixEvents.ids[id] = eventID
+	matrixEvents.order = append(matrixEvents.order, id)
+	if len(matrixEvents.order) > matrixMaxEvents {
+		old := matrixEvents.order[0]
+		delete(matrixEvents.buttons, matrixEvents.ids[old])
+		delete(matrixEvents.ids, old)
+		matrixEvents.order = matrixEvents.order[1:]
+	}
+	return id
+}
+
+func matrixEventID(id int64) string {
+	matrixEvents.Lock()
+	defer matrixEvents.Unlock()
+	return matrixEvents.ids[id]
+}
+
+// matrixRequest sends a request to the homeserver and returns the response
+// body. A 429 is retried once after the advertised delay.
+func matrixRequest(config *Config, method, path, contentType string, body []byte) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, strings.TrimRight(config.MatrixHomeserver, "/")+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+config.MatrixAccessToken)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := matrixClient.Do(req)
+		if err != nil {
+			return nil, redactTokenError(err, config.MatrixAccessToken)
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		resp.Body.Close()
+
+		var result struct {
+			ErrCode      string `json:"errcode"`
+			Error        string `json:"error"`
+			RetryAfterMs int64  `json:"retry_after_ms"`
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && attempt == 0 {
+			json.Unmarshal(data, &result)
+			time.Sleep(time.Duration(result.RetryAfterMs) * time.Millisecond)
+			continue
+		}
+		if resp.StatusCode >= 300 {
+			if json.Unmarshal(data, &result) == nil && result.ErrCode != "" {
+				return nil, fmt.Errorf("matrix %s: %s", result.ErrCode, result.Error)
+			}
+			return nil, fmt.Errorf("matrix error %d: %s", resp.StatusCode, truncate(string(data), 200))
+		}
+		return data, nil
+	}
+}
+
+// matrixAPI calls a client-server API endpoint with a JSON body and decodes the reply into out
+func matrixAPI(config *Config, method, path string, payload, out interface{}) error {
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return err
+		}
+	}
+	data, err := matrixRequest(config, method, "/_matrix/client/v3"+path, "application/json", body)
+	if err != nil {
+		return err
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// matrixRoom returns a session's room, rereading the config for rooms
+// another ccc process created
+func matrixRoom(config *Config, threadID int64) (string, error) {
+	if room := config.MatrixRooms[threadID]; room != "" {
+		return room, nil
+	}
+	if fresh, err := loadConfig(); err == nil && fresh.MatrixRooms[threadID] != "" {
+		setMatrixRoom(config, threadID, fresh.MatrixRooms[threadID])
+		return fresh.MatrixRooms[threadID], nil
+	}
+	return "", fmt.Errorf("no Matrix room for topic %d", threadID)
+}
+
+// matrixRoomTopic returns the topic ID of a session's room, 0 for other rooms
+func matrixRoomTopic(config *Config, roomID string) int64 {
+	for topicID, room := range config.MatrixRooms {
+		if room == roomID {
+			return topicID
+		}
+	}
+	return 0
+}
+
+// saveMatrixRoom records a session's room in the config, or forgets it
+// when roomID is ""
+func saveMatrixRoom(config *Config, topicID int64, roomID string) error {
+	setMatrixRoom(config, topicID, roomID)
+	fresh, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	setMatrixRoom(fresh, topicID, roomID)
+	return saveConfig(fresh)
+}
+
+func setMatrixRoom(config *Config, topicID int64, roomID string) {
+	if roomID == "" {
+		delete(config.MatrixRooms, topicID)
+		return
+	}
+	if config.MatrixRooms == nil {
+		config.MatrixRooms = make(map[int64]string)
+	}
+	config.MatrixRooms[topicID] = roomID
+}
+
+// matrixHTML renders the markdown in Claude's output that Telegram gets as
+// MarkdownV2 (code fences, inline code, bold) as Matrix HTML
+func matrixHTML(text string) string {
+	var sb strings.Builder
+	inFence := false
+	for i, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if inFence {
+				sb.WriteString("</code></pre>")
+			} else {
+				if i > 0 {
+					sb.WriteString("<br>")
+				}
+				sb.WriteString("<pre><code>")
+			}
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			sb.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+		if i > 0 && !strings.HasSuffix(sb.String(), "</code></pre>") {
+			sb.WriteString("<br>")
+		}
+		sb.WriteString(matrixInlineHTML(line))
+	}
+	if inFence {
+		sb.WriteString("</code></pre>")
+	}
+	return sb.String()
+}
+
+// matrixInlineHTML renders `code` and **bold** in one line
+func matrixInlineHTML(line string) string {
+	var sb strings.Builder
+	for line != "" {
+		switch {
+		case strings.HasPrefix(line, "`"):
+			if end := strings.Index(line[1:], "`"); end >= 0 {
+				sb.WriteString("<code>" + html.EscapeString(line[1:end+1]) + "</code>")
+				line = line[end+2:]
+				continue
+			}
+		case strings.HasPrefix(line, "**"):
+			if end := strings.Index(line[2:], "**"); end > 0 {
+				sb.WriteString("<strong>" + html.EscapeString(line[2:end+2]) + "</strong>")
+				line = line[end+4:]
+				continue
+			}
+		}
+		next := strings.IndexAny(line[1:], "`*")
+		if next < 0 {
+			sb.WriteString(html.EscapeString(line))
+			break
+		}
+		sb.WriteString(html.EscapeString(line[:next+1]))
+		line = line[next+1:]
+	}
+	return sb.String()
+}
+
+// matrixMessenger delivers messages through the Matrix client-server API
+type matrixMessenger struct {
+	config *Config
+}
+
+// send posts an event to a session's room and returns its event ID
+func (m *matrixMessenger) send(threadID int64, eventType string, content interface{}) (string, error) {
+	room, err := matrixRoom(m.config, threadID)
+	if err != nil {
+		return "", err
+	}
+	var result struct {
+		EventID string `json:"event_id"`
+	}
+	path := fmt.Sprintf("/rooms/%s/send/%s/ccc%d", url.PathEscape(room), eventType, newLocalID())
+	if err := matrixAPI(m.config, "PUT", path, content, &result); err != nil {
+		return "", err
+	}
+	return result.EventID, nil
+}
+
+// matrixTextContent is an m.text message, with an HTML rendering when formatted
+func matrixTextContent(text string, formatted bool) map[string]interface{} {
+	content := map[string]interface{}{"msgtype": "m.text", "body": text}
+	if formatted {
+		content["format"] = "org.matrix.custom.html"
+		content["formatted_body"] = matrixHTML(text)
+	}
+	return content
+}
+
+func (m *matrixMessenger) sendParts(threadID int64, text string, formatted bool) (int64, error) {
+	var lastMsgID int64
+	for _, part := range splitMessage(text, matrixMaxLen) {
+		eventID, err := m.send(threadID, "m.room.message", matrixTextContent(part, formatted))
+		if err != nil {
+			return 0, err
+		}
+		lastMsgID = rememberMatrixEvent(eventID)
+	}
+	return lastMsgID, nil
+}
+
+func (m *matrixMessenger) edit(messageID, threadID int64, text string, formatted bool) error {
+	parts := splitMessage(text, matrixMaxLen)
+	eventID := matrixEventID(messageID)
+	if eventID == "" {
+		// Sent before the listener restarted: post it again instead
+		_, err := m.sendParts(threadID, text, formatted)
+		return err
+	}
+	content := matrixTextContent("* "+parts[0], false)
+	content["m.new_content"] = matrixTextContent(parts[0], formatted)
+	content["m.relates_to"] = map[string]string{"rel_type": "m.replace", "event_id": eventID}
+	if _, err := m.send(threadID, "m.room.message", content); err != nil {
+		return err
+	}
+	for _, part := range parts[1:] {
+		if _, err := m.sendParts(threadID, part, formatted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *matrixMessenger) Send(chatID, threadID int64, text string) error {
+	_, err := m.sendParts(threadID, text, false)
+	return err
+}
+
+func (m *matrixMessenger) SendGetID(chatID, threadID int64, text string) (int64, error) {
+	return m.sendParts(threadID, text, false)
+}
+
+// Edit replaces a message's text (an m.replace event), sending overflow as new messages
+func (m *matrixMessenger) Edit(chatID, messageID, threadID int64, text string) error {
+	return m.edit(messageID, threadID, text, false)
+}
+
+func (m *matrixMessenger) SendFormatted(chatID, threadID int64, text string) (int64, error) {
+	return m.sendParts(threadID, text, true)
+}
+
+func (m *matrixMessenger) EditFormatted(chatID, messageID, threadID int64, text string) error {
+	return m.edit(messageID, threadID, text, true)
+}
+
+// SendWithKeyboard numbers the buttons under the text and reacts with
+// 1️⃣ 2️⃣ ... so a button is pressed by reacting with its number
+func (m *matrixMessenger) SendWithKeyboard(chatID, threadID int64, text string, buttons [][]InlineKeyboardButton) error {
+	var sb strings.Builder
+	sb.WriteString(text)
+	sb.WriteString("\n")
+	var data []string
+	for _, row := range buttons {
+		for _, b := range row {
+			if len(data) == len(matrixButtonKeys) {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("\n%s %s", matrixButtonKeys[len(data)], b.Text))
+			data = append(data, b.CallbackData)
+		}
+	}
+	body := truncate(sb.String(), matrixMaxLen)
+	eventID, err := m.send(threadID, "m.room.message", matrixTextContent(body, false))
+	if err != nil {
+		return err
+	}
+	msgID := rememberMatrixEvent(eventID)
+	matrixEvents.Lock()
+	matrixEvents.buttons[eventID] = matrixKeyboard{MessageID: msgID, Text: body, Data: data}
+	matrixEvents.Unlock()
+
+	for _, key := range matrixButtonKeys[:len(data)] {
+		if _, err := m.send(threadID, "m.reaction", map[string]interface{}{
+			"m.relates_to": map[string]string{"rel_type": "m.annotation", "event_id": eventID, "key": key},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendFile uploads a file to the media repository and posts it to the room
+func (m *matrixMessenger) SendFile(chatID, threadID int64, filePath string, caption string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(filePath)
+	mimeType := mime.TypeByExtension(filepath.Ext(name))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	resp, err := matrixRequest(m.config, "POST", "/_matrix/media/v3/upload?filename="+url.QueryEscape(name), mimeType, data)
+	if err != nil {
+		return err
+	}
+	var upload struct {
+		ContentURI string `json:"content_uri"`
+	}
+	if err := json.Unmarshal(resp, &upload); err != nil || upload.ContentURI == "" {
+		return fmt.Errorf("matrix upload: no content URI")
+	}
+
+	msgtype := "m.file"
+	if strings.HasPrefix(mimeType, "image/") {
+		msgtype = "m.image"
+	}
+	if _, err := m.send(threadID, "m.room.message", map[string]interface{}{
+		"msgtype": msgtype,
+		"body":    name,
+		"url":     upload.ContentURI,
+		"info":    map[string]interface{}{"mimetype": mimeType, "size": len(data)},
+	}); err != nil {
+		return err
+	}
+	if caption != "" {
+		return m.Send(chatID, threadID, caption)
+	}
+	return nil
+}
+
+// CreateTopic creates a private room for the session and invites the user
+func (m *matrixMessenger) CreateTopic(name string) (int64, error) {
+	if m.config.MatrixHomeserver == "" || m.config.MatrixUserID == "" {
+		return 0, fmt.Errorf("matrix not configured. Run: ccc config matrix-homeserver <url>, matrix-token <token>, matrix-user <@you:server>")
+	}
+	req := map[string]interface{}{
+		"name":   name,
+		"topic":  "ccc session " + name,
+		"preset": "private_chat",
+		"invite": []string{m.config.MatrixUserID},
+	}
+	if m.config.MatrixEncrypt {
+		req["initial_state"] = []map[string]interface{}{{
+			"type":      "m.room.encryption",
+			"state_key": "",
+			"content":   map[string]string{"algorithm": "m.megolm.v1.aes-sha2"},
+		}}
+	}
+	var room struct {
+		RoomID string `json:"room_id"`
+	}
+	if err := matrixAPI(m.config, "POST", "/createRoom", req, &room); err != nil {
+		return 0, fmt.Errorf("failed to create room: %w", err)
+	}
+	topicID := newLocalID()
+	if err := saveMatrixRoom(m.config, topicID, room.RoomID); err != nil {
+		return 0, fmt.Errorf("failed to save room: %w", err)
+	}
+	return topicID, nil
+}
+
+// DeleteTopic leaves and forgets the session's room
+func (m *matrixMessenger) DeleteTopic(topicID int64) error {
+	room, err := matrixRoom(m.config, topicID)
+	if err != nil {
+		return err
+	}
+	path := "/rooms/" + url.PathEscape(room)
+	if err := matrixAPI(m.config, "POST", path+"/leave", map[string]string{}, nil); err != nil {
+		return err
+	}
+	matrixAPI(m.config, "POST", path+"/forget", map[string]string{}, nil)
+	return saveMatrixRoom(m.config, topicID, "")
+}
+
+// matrixEvent is the subset of a room event we use
+type matrixEvent struct {
+	Type    string `json:"type"`
+	Sender  string `json:"sender"`
+	EventID string `json:"event_id"`
+	Content struct {
+		MsgType   string `json:"msgtype"`
+		Body      string `json:"body"`
+		RelatesTo *struct {
+			RelType string `json:"rel_type"`
+			EventID string `json:"event_id"`
+			Key     string `json:"key"`
+		} `json:"m.relates_to"`
+	} `json:"content"`
+}
+
+// matrixSyncResponse is the subset of a /sync response we use
+type matrixSyncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []matrixEvent `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+	} `json:"rooms"`
+}
+
+// matrixSyncFilter leaves out presence and account data, which we don't use
+const matrixSyncFilter = `{"presence":{"not_types":["*"]},"account_data":{"not_types":["*"]},"room":{"timeline":{"limit":50}}}`
+
+// matrixSync fetches the events after since, waiting up to timeout for one
+func matrixSync(config *Config, since string, timeout time.Duration) (*matrixSyncResponse, error) {
+	params := url.Values{"filter": {matrixSyncFilter}, "timeout": {fmt.Sprintf("%d", timeout.Milliseconds())}}
+	if since != "" {
+		params.Set("since", since)
+	}
+	var s matrixSyncResponse
+	if err := matrixAPI(config, "GET", "/sync?"+params.Encode(), nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// stripMatrixReplyFallback drops the "> <@user> quoted" lines clients put
+// in front of a reply's body
+func stripMatrixReplyFallback(body string) string {
+	if !strings.HasPrefix(body, "> ") {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, ">") {
+			return strings.TrimSpace(strings.Join(lines[i:], "\n"))
+		}
+	}
+	return ""
+}
+
+// listenMatrix long-polls /sync and handles the configured user's messages
+// and reactions in session rooms until killed
+func listenMatrix(config *Config) error {
+	if config.MatrixHomeserver == "" || config.MatrixAccessToken == "" || config.MatrixUserID == "" {
+		return fmt.Errorf("matrix not configured. Run: ccc config matrix-homeserver <url>, matrix-token <token>, matrix-user <@you:server>")
+	}
+
+	fmt.Printf("Matrix bot listening... (%s)\n", config.MatrixHomeserver)
+	fmt.Printf("Active sessions: %d\n", len(config.Sessions))
+
+	go startSessionMonitor(config)
+	go startScheduler()
+	go startControlServer()
+
+	since := ""
+	warnedEncrypted := false
+	for {
+		timeout := 30 * time.Second
+		if since == "" {
+			timeout = 0
+		}
+		s, err := matrixSync(config, since, timeout)
+		if err != nil {
+			hookLog("matrix: sync failed: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		first := since == ""
+		since = s.NextBatch
+		if first {
+			// Skip history from before the listener started
+			continue
+		}
+
+		config, _ = loadConfig()
+		for roomID, room := range s.Rooms.Join {
+			topicID := matrixRoomTopic(config, roomID)
+			if topicID == 0 {
+				continue
+			}
+			for _, ev := range room.Timeline.Events {
+				if ev.Sender != config.MatrixUserID {
+					continue
+				}
+				if ev.Type == "m.room.encrypted" && !warnedEncrypted {
+					warnedEncrypted = true
+					hookLog("matrix: can't read encrypted messages in %s; run ccc through pantalaimon", roomID)
+				}
+				handleMatrixEvent(config, topicID, ev)
+			}
+		}
+	}
+}
+
+// handleMatrixEvent forwards a message to the room's session, or presses
+// the button a reaction picks
+func handleMatrixEvent(config *Config, topicID int64, ev matrixEvent) {
+	msgr := getMessenger(config)
+	rel := ev.Content.RelatesTo
+
+	switch ev.Type {
+	case "m.room.message":
+		if ev.Content.MsgType != "m.text" || (rel != nil && rel.RelType == "m.replace") {
+			return
+		}
+		text := strings.TrimSpace(stripMatrixReplyFallback(ev.Content.Body))
+		if text == "" {
+			return
+		}
+		sessName := getSessionByTopic(config, topicID)
+		if sessName == "" {
+			msgr.Send(0, topicID, "⚠️ No session linked to this room.")
+			return
+		}
+		go forwardToSession(config, msgr, 0, topicID, sessName, text)
+
+	case "m.reaction":
+		if rel == nil || rel.RelType != "m.annotation" {
+			return
+		}
+		matrixEvents.Lock()
+		kb, ok := matrixEvents.buttons[rel.EventID]
+		matrixEvents.Unlock()
+		if !ok {
+			return
+		}
+		data := kb.press(rel.Key)
+		if data == "" {
+			return
+		}
+		matrixEvents.Lock()
+		delete(matrixEvents.buttons, rel.EventID)
+		matrixEvents.Unlock()
+
+		if strings.HasPrefix(data, permissionCallbackPrefix) {
+			if label, ok := handlePermissionCallback(data); ok {
+				msgr.Edit(0, kb.MessageID, topicID, kb.Text+"\n\n"+label)
+			}
+			return
+		}
+		if qc, ok := parseQuestionCallback(data); ok {
+			msgr.Edit(0, kb.MessageID, topicID, fmt.Sprintf("%s\n\n✓ Selected option %d", kb.Text, qc.OptionIndex+1))
+			answerQuestion(qc)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Messenger is the chat platform session output is delivered to. chatID is the
+// group or channel, threadID the per-session topic or thread (0 for none).
+type Messenger interface {
+	Send(chatID, threadID int64, text string) error
+	SendGetID(chatID, threadID int64, text string) (int64, error)
+	Edit(chatID, messageID, threadID int64, text string) error
+	// SendFormatted and EditFormatted render markdown in Claude's output
+	// (code fences, inline code, bold) where the platform needs conversion
+	SendFormatted(chatID, threadID int64, text string) (int64, error)
+	EditFormatted(chatID, messageID, threadID int64, text string) error
+	SendWithKeyboard(chatID, threadID int64, text string, buttons [][]InlineKeyboardButton) error
+	SendFile(chatID, threadID int64, filePath string, caption string) error
+	CreateTopic(name string) (int64, error)
+	DeleteTopic(topicID int64) error
+}
+
+const (
+	messengerTelegram = "telegram"
+	messengerDiscord  = "discord"
+	messengerSlack    = "slack"
+	messengerRelay    = "relay"
+	messengerMatrix   = "matrix"
+)
+
+// getMessenger returns the configured messaging backend (Telegram by default)
+func getMessenger(config *Config) Messenger {
+	switch config.Messenger {
+	case messengerDiscord:
+		return &discordMessenger{config: config}
+	case messengerSlack:
+		return &slackMessenger{config: config}
+	case messengerRelay:
+		return &relayMessenger{config: config}
+	case messengerMatrix:
+		return &matrixMessenger{config: config}
+	}
+	return &telegramMessenger{config: config}
+}
+
+// outputMessenger is getMessenger for session output. Telegram sends it
+// from its own part of a group's budget, so replies to commands don't wait
+// behind a burst of it.
+func outputMessenger(config *Config) Messenger {
+	c := *config
+	c.outputLane = true
+	return getMessenger(&c)
+}
+
+// configuredMessenger returns the name of the configured backend
+func configuredMessenger(config *Config) string {
+	if config.Messenger == "" {
+		return messengerTelegram
+	}
+	return config.Messenger
+}
+
+// hasSessionChannel reports whether a group (Telegram), channel (Discord,
+// Slack), control channel (relay) or homeserver (Matrix) is configured to hold per-session topics
+func hasSessionChannel(config *Config) bool {
+	switch config.Messenger {
+	case messengerDiscord:
+		return config.DiscordChannelID != 0
+	case messengerSlack:
+		return config.SlackChannelID != ""
+	case messengerRelay:
+		return config.ControlChannel != ""
+	case messengerMatrix:
+		return config.MatrixHomeserver != ""
+	}
+	return config.GroupID != 0
+}
+
+// hasButtons reports whether the backend's keyboard buttons can be pressed.
+// Discord lists them as numbered text.
+func hasButtons(config *Config) bool {
+	return config.Messenger != messengerDiscord
+}
+
+// telegramMessenger delivers messages through the Telegram Bot API
+type telegramMessenger struct {
+	config *Config
+}
+
+func (t *telegramMessenger) Send(chatID, threadID int64, text string) error {
+	return sendMessage(t.config, chatID, threadID, text)
+}
+
+func (t *telegramMessenger) SendGetID(chatID, threadID int64, text string) (int64, error) {
+	return sendMessageGetID(t.config, chatID, threadID, text)
+}
+
+func (t *telegramMessenger) Edit(chatID, messageID, threadID int64, text string) error {
+	return editMessage(t.config, chatID, messageID, threadID, text)
+}
+
+func (t *telegramMessenger) SendFormatted(chatID, threadID int64, text string) (int64, error) {
+	return sendFormattedGetID(t.config, chatID, threadID, text)
+}
+
+func (t *telegramMessenger) EditFormatted(chatID, messageID, threadID int64, text string) error {
+	return editFormattedMessage(t.config, chatID, messageID, threadID, text)
+}
+
+func (t *telegramMessenger) SendWithKeyboard(chatID, threadID int64, text string, buttons [][]InlineKeyboardButton) error {
+	return sendMessageWithKeyboard(t.config, chatID, threadID, text, buttons)
+}
+
+func (t *telegramMessenger) SendFile(chatID, threadID int64, filePath string, caption string) error {
+	return sendFile(t.config, chatID, threadID, filePath, caption)
+}
+
+func (t *telegramMessenger) CreateTopic(name string) (int64, error) {
+	return createForumTopic(t.config, t.config.GroupID, name)
+}
+
+func (t *telegramMessenger) DeleteTopic(topicID int64) error {
+	return deleteForumTopic(t.config, t.config.GroupID, topicID)
+}
+
+var localIDs = struct {
+	sync.Mutex
+	last int64
+}{}
+
+// newLocalID returns a unique topic or message ID for backends that don't
+// assign numeric ones: microseconds since the epoch, bumped past the last
+func newLocalID() int64 {
+	localIDs.Lock()
+	defer localIDs.Unlock()
+	id := time.Now().UnixNano() / 1000
+	if id <= localIDs.last {
+		id = localIDs.last + 1
+	}
+	localIDs.last = id
+	return id
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetMessenger(t *testing.T) {
+	if _, ok := getMessenger(&Config{}).(*telegramMessenger); !ok {
+		t.Error("default messenger should be Telegram")
+	}
+	if _, ok := getMessenger(&Config{Messenger: "discord"}).(*discordMessenger); !ok {
+		t.Error("messenger \"discord\" should select the Discord backend")
+	}
+
+	if hasSessionChannel(&Config{Messenger: "discord", GroupID: -100}) {
+		t.Error("Discord without a channel ID should have no session channel")
+	}
+	if !hasSessionChannel(&Config{Messenger: "discord", DiscordChannelID: 42}) {
+		t.Error("Discord with a channel ID should have a session channel")
+	}
+	if !hasSessionChannel(&Config{GroupID: -100}) {
+		t.Error("Telegram with a group should have a session channel")
+	}
+	if hasButtons(&Config{Messenger: "discord"}) || !hasButtons(&Config{}) {
+		t.Error("only Discord should lack pressable buttons")
+	}
+	if _, ok := getMessenger(&Config{Messenger: "matrix"}).(*matrixMessenger); !ok {
+		t.Error("messenger \"matrix\" should select the Matrix backend")
+	}
+	if !hasSessionChannel(&Config{Messenger: "matrix", MatrixHomeserver: "https://matrix.example.org"}) {
+		t.Error("Matrix with a homeserver should have a session channel")
+	}
+}
+
+func TestDiscordMessenger(t *testing.T) {
+	var requests []string
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		bodies = append(bodies, string(body))
+		switch {
+		case r.Method == "GET":
+			// Newest first, as Discord returns them
+			w.Write([]byte(`[{"id":"3","content":"third"},{"id":"2","content":"second"}]`))
+		case strings.HasSuffix(r.URL.Path, "/threads"):
+			w.Write([]byte(`{"id":"555"}`))
+		default:
+			w.Write([]byte(`{"id":"777"}`))
+		}
+	}))
+	defer server.Close()
+
+	originalBase := discordAPIBase
+	discordAPIBase = server.URL
+	defer func() { discordAPIBase = originalBase }()
+
+	config := &Config{Messenger: "discord", DiscordBotToken: "test-token", DiscordChannelID: 100}
+	msgr := getMessenger(config)
+
+	msgID, err := msgr.SendGetID(0, 200, "hello")
+	if err != nil || msgID != 777 {
+		t.Fatalf("SendGetID = %d, %v; want 777, nil", msgID, err)
+	}
+	if requests[0] != "POST /channels/200/messages" {
+		t.Errorf("SendGetID request = %q, want POST to the session thread", requests[0])
+	}
+
+	if err := msgr.Send(0, 0, "to channel"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if requests[1] != "POST /channels/100/messages" {
+		t.Errorf("Send without thread = %q, want POST to the main channel", requests[1])
+	}
+
+	buttons := [][]InlineKeyboardButton{{{Text: "Yes", CallbackData: "s:0:0"}, {Text: "No", CallbackData: "s:0:1"}}}
+	if err := msgr.SendWithKeyboard(0, 200, "Proceed?", buttons); err != nil {
+		t.Fatalf("SendWithKeyboard failed: %v", err)
+	}
+	var payload map[string]string
+	json.Unmarshal([]byte(bodies[2]), &payload)
+	if payload["content"] != "Proceed?\n\n1. Yes\n2. No" {
+		t.Errorf("keyboard content = %q, want options listed as text", payload["content"])
+	}
+
+	topicID, err := msgr.CreateTopic("my-project")
+	if err != nil || topicID != 555 {
+		t.Fatalf("CreateTopic = %d, %v; want 555, nil", topicID, err)
+	}
+	if requests[3] != "POST /channels/100/threads" {
+		t.Errorf("CreateTopic request = %q", requests[3])
+	}
+
+	msgs, err := fetchDiscordMessages(config, 200, "1")
+	if err != nil {
+		t.Fatalf("fetchDiscordMessages failed: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].Content != "second" || msgs[1].Content != "third" {
+		t.Errorf("fetchDiscordMessages = %+v, want oldest first", msgs)
+	}
+}
+
+func TestMatrixMessenger(t *testing.T) {
+	var requests []string
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token"}`))
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		bodies = append(bodies, body)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/createRoom"):
+			w.Write([]byte(`{"room_id":"!new:example.org"}`))
+		default:
+			w.Write([]byte(fmt.Sprintf(`{"event_id":"$e%d"}`, len(requests))))
+		}
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv(configPathEnv, configPath)
+	config := &Config{
+		Messenger:         "matrix",
+		MatrixHomeserver:  server.URL,
+		MatrixAccessToken: "test-token",
+		MatrixUserID:      "@me:example.org",
+		MatrixEncrypt:     true,
+	}
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	msgr := getMessenger(config)
+
+	topicID, err := msgr.CreateTopic("my-project")
+	if err != nil {
+		t.Fatalf("CreateTopic failed: %v", err)
+	}
+	if invite := bodies[0]["invite"].([]interface{}); invite[0] != "@me:example.org" {
+		t.Errorf("createRoom invite = %v, want the configured user", invite)
+	}
+	if _, ok := bodies[0]["initial_state"]; !ok {
+		t.Error("createRoom with matrix_encrypt should enable encryption")
+	}
+	if saved, _ := loadConfig(); saved.MatrixRooms[topicID] != "!new:example.org" {
+		t.Errorf("saved matrix_rooms = %v, want the new room under topic %d", saved.MatrixRooms, topicID)
+	}
+
+	msgID, err := msgr.SendGetID(0, topicID, "hello")
+	if err != nil {
+		t.Fatalf("SendGetID failed: %v", err)
+	}
+	if !strings.HasPrefix(requests[1], "PUT /_matrix/client/v3/rooms/%21new:example.org/send/m.room.message/") {
+		t.Errorf("SendGetID request = %q, want a PUT to the session room", requests[1])
+	}
+
+	if err := msgr.EditFormatted(0, msgID, topicID, "**done**"); err != nil {
+		t.Fatalf("EditFormatted failed: %v", err)
+	}
+	relates := bodies[2]["m.relates_to"].(map[string]interface{})
+	if relates["rel_type"] != "m.replace" || relates["event_id"] != "$e2" {
+		t.Errorf("edit relates_to = %v, want m.replace of $e2", relates)
+	}
+	if newContent := bodies[2]["m.new_content"].(map[string]interface{}); newContent["formatted_body"] != "<strong>done</strong>" {
+		t.Errorf("edit formatted_body = %v", newContent["formatted_body"])
+	}
+
+	buttons := [][]InlineKeyboardButton{{{Text: "Yes", CallbackData: "s:0:0"}, {Text: "No", CallbackData: "s:0:1"}}}
+	if err := msgr.SendWithKeyboard(0, topicID, "Proceed?", buttons); err != nil {
+		t.Fatalf("SendWithKeyboard failed: %v", err)
+	}
+	if bodies[3]["body"] != "Proceed?\n\n1️⃣ Yes\n2️⃣ No" {
+		t.Errorf("keyboard body = %q, want numbered options", bodies[3]["body"])
+	}
+	if len(requests) != 6 || !strings.Contains(requests[4], "/send/m.reaction/") {
+		t.Errorf("keyboard requests = %v, want a reaction per button", requests[3:])
+	}
+	matrixEvents.Lock()
+	kb := matrixEvents.buttons["$e4"]
+	matrixEvents.Unlock()
+	if kb.press("2⃣") != "s:0:1" || kb.press("👍") != "" {
+		t.Errorf("keyboard = %+v, want reacting 2 to press the second button", kb)
+	}
+
+	if err := msgr.Send(0, 12345, "lost"); err == nil {
+		t.Error("Send to a topic without a room should fail")
+	}
+	config.MatrixAccessToken = "wrong"
+	if err := msgr.Send(0, topicID, "hi"); err == nil || !strings.Contains(err.Error(), "M_UNKNOWN_TOKEN") {
+		t.Errorf("Send with a bad token: err = %v, want M_UNKNOWN_TOKEN", err)
+	}
+}
+
+func TestMatrixHTML(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a < b", "a &lt; b"},
+		{"Edit `main.go` **now**", "Edit <code>main.go</code> <strong>now</strong>"},
+		{"line one\nline two", "line one<br>line two"},
+		{"Run:\n```sh\nx && y\n```\nok", "Run:<br><pre><code>x &amp;&amp; y\n</code></pre>ok"},
+		{"a * b ** c", "a * b ** c"},
+	}
+	for _, tt := range tests {
+		if result := matrixHTML(tt.input); result != tt.expected {
+			t.Errorf("matrixHTML(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+
+	if got := stripMatrixReplyFallback("> <@me:example.org> earlier\n> more\n\nnew text"); got != "new text" {
+		t.Errorf("stripMatrixReplyFallback = %q, want the reply only", got)
+	}
+}
+
+func TestSlackTSConversion(t *testing.T) {
+	id, err := slackTSToID("1700000000.000123")
+	if err != nil {
+		t.Fatalf("slackTSToID failed: %v", err)
+	}
+	if id != 1700000000000123 {
+		t.Errorf("slackTSToID = %d, want 1700000000000123", id)
+	}
+	if ts := slackIDToTS(id); ts != "1700000000.000123" {
+		t.Errorf("slackIDToTS = %q, want round trip", ts)
+	}
+
+	for _, bad := range []string{"", "1700000000", "1700000000.12", "abc.123456"} {
+		if _, err := slackTSToID(bad); err == nil {
+			t.Errorf("slackTSToID(%q) should fail", bad)
+		}
+	}
+}
+
+func TestSlackButtonBlocks(t *testing.T) {
+	buttons := [][]InlineKeyboardButton{
+		{{Text: "Yes", CallbackData: "proj:0:1:0"}},
+		{{Text: "No", CallbackData: "proj:0:1:1"}},
+	}
+	blocks := slackButtonBlocks("Proceed?", buttons)
+	if len(blocks) != 3 {
+		t.Fatalf("len(blocks) = %d, want section + one actions block per row", len(blocks))
+	}
+	elements := blocks[2]["elements"].([]map[string]interface{})
+	if elements[0]["value"] != "proj:0:1:1" || elements[0]["action_id"] != "ccc_1_0" {
+		t.Errorf("second row button = %v, want callback data as value", elements[0])
+	}
+}
+
+func TestParseQuestionCallback(t *testing.T) {
+	qc, ok := parseQuestionCallback("proj:1:3:2")
+	if !ok || qc.Session != "proj" || qc.QuestionIndex != 1 || qc.TotalQuestions != 3 || qc.OptionIndex != 2 {
+		t.Errorf("parseQuestionCallback(4 parts) = %+v, %v", qc, ok)
+	}
+	qc, ok = parseQuestionCallback("proj:0:1")
+	if !ok || qc.TotalQuestions != 0 || qc.OptionIndex != 1 {
+		t.Errorf("parseQuestionCallback(legacy) = %+v, %v", qc, ok)
+	}
+	if _, ok := parseQuestionCallback("list"); ok {
+		t.Error("parseQuestionCallback should reject short data")
+	}
+}
+
+func TestToMarkdownV2(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"escapes specials", "Done. See foo_bar (v1.2)!", "Done\\. See foo\\_bar \\(v1\\.2\\)\\!"},
+		{"inline code", "Edit `main.go` now", "Edit `main.go` now"},
+		{"bold", "**Note:** ok", "*Note:* ok"},
+		{"header", "## Summary", "*Summary*"},
+		{"code fence", "```go\nx := a_b * 2 // `q`\n```", "```go\nx := a_b * 2 // \\`q\\`\n```"},
+		{"unclosed fence", "```\nfmt.Println()", "```\nfmt.Println()\n```"},
+		{"bad fence lang", "```go run\nx\n```", "```\nx\n```"},
+		{"quote", "Said:\n> hi.\nok", "Said:\n>hi\\.\nok"},
+		{"long quote collapses", "> a\n> b\n> c\n> d", "**>a\n>b\n>c\n>d||"},
+		{"quote in fence", "```\n> x\n```", "```\n> x\n```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := toMarkdownV2(tt.input); result != tt.expected {
+				t.Errorf("toMarkdownV2(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
@@ -1,11 +1,8 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -18,20 +15,157 @@ type SessionMonitor struct {
 	Completed       bool      // whether we've already sent ✅
 	LastPromptIdx   int       // track which prompt we're on
 	LastUserMessage time.Time // when user last sent a message (for slow polling)
+	LastPrompt      string    // text of the last message the user sent
 	LastActivity    time.Time // last time blocks changed or new blocks appeared
 	SlowPollCounter int       // counter for slow polling (poll every 10th tick = 30s)
+	TurnStarted     time.Time // when the current user turn started (zero if none pending)
+	ClaudeSeen      bool      // whether Claude has been seen running in the pane
+	Crashed         bool      // Claude exited and the pane dropped to a shell
+	LastHookEvent   time.Time // when the last PostToolUse/Stop hook event arrived (hooks monitor mode)
+	HookDriven      bool      // whether the last poll left output to hook events
+	CurrentTool     string    // tool of the latest tool call, for the status message
+	Alert           string    // failure shown in the pane and already alerted, "" if none
+	AlertLine       string    // the pane line that showed it
+}
+
+// maxTurnSamples is how many recent turn durations are kept for the rolling average
+const maxTurnSamples = 10
+
+var (
+	// turnDurations holds each session's recent completed turn durations,
+	// newest last. Headless sessions have no monitor, so they live here.
+	turnDurations   = make(map[string][]time.Duration)
+	turnDurationsMu sync.Mutex
+)
+
+// recordTurnDuration appends a session's completed turn duration, keeping the last maxTurnSamples
+func recordTurnDuration(sessName string, d time.Duration) {
+	turnDurationsMu.Lock()
+	defer turnDurationsMu.Unlock()
+	samples := append(turnDurations[sessName], d)
+	if len(samples) > maxTurnSamples {
+		samples = samples[len(samples)-maxTurnSamples:]
+	}
+	turnDurations[sessName] = samples
+}
+
+// averageTurnDuration returns the rolling average turn duration for a session,
+// or 0 if no turns have completed yet.
+func averageTurnDuration(sessName string) time.Duration {
+	turnDurationsMu.Lock()
+	defer turnDurationsMu.Unlock()
+
+	samples := turnDurations[sessName]
+	if len(samples) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, d := range samples {
+		total += d
+	}
+	return total / time.Duration(len(samples))
+}
+
+// queueWaitEstimate renders how long turns average turns take, e.g. "~4 min"
+// or "<1 min", or "" when no turn durations are known yet
+func queueWaitEstimate(turns float64, avgTurn time.Duration) string {
+	if avgTurn <= 0 {
+		return ""
+	}
+	minutes := int(time.Duration(float64(avgTurn) * turns).Round(time.Minute).Minutes())
+	if minutes < 1 {
+		return "<1 min"
+	}
+	return fmt.Sprintf("~%d min", minutes)
+}
+
+// formatQueueWait describes a queued prompt's position and estimated wait,
+// e.g. "⏳ queued, 2 ahead, ~4 min". The estimate is omitted when no turn
+// durations are known yet.
+func formatQueueWait(ahead int, avgTurn time.Duration) string {
+	msg := fmt.Sprintf("⏳ queued, %d ahead", ahead)
+	// The running turn is counted as half done on average
+	if est := queueWaitEstimate(float64(ahead)+0.5, avgTurn); est != "" {
+		msg += ", " + est
+	}
+	return msg
+}
+
+// formatQueueAdvance is posted when a queued prompt starts, with how many
+// are still queued and when the last of them should start, e.g.
+// "▶️ Next queued prompt started, 2 still queued, last in ~8 min"
+func formatQueueAdvance(remaining int, avgTurn time.Duration) string {
+	msg := "▶️ Next queued prompt started"
+	if remaining == 0 {
+		return msg
+	}
+	msg += fmt.Sprintf(", %d still queued", remaining)
+	// The turn that just started and every prompt but the last are ahead of it
+	if est := queueWaitEstimate(float64(remaining), avgTurn); est != "" {
+		msg += ", last in " + est
+	}
+	return msg
+}
+
+var (
+	// pendingMessages holds messages that arrived while Claude was busy,
+	// per session, oldest first. They are typed in one per completed turn.
+	pendingMessages   = make(map[string][]string)
+	pendingMessagesMu sync.Mutex
+)
+
+// enqueueMessage queues a message for a busy session and returns how many
+// queued messages are ahead of it
+func enqueueMessage(sessName, text string) int {
+	pendingMessagesMu.Lock()
+	defer pendingMessagesMu.Unlock()
+	ahead := len(pendingMessages[sessName])
+	pendingMessages[sessName] = append(pendingMessages[sessName], text)
+	return ahead
+}
+
+// dequeueMessage pops the oldest queued message for a session
+func dequeueMessage(sessName string) (string, bool) {
+	pendingMessagesMu.Lock()
+	defer pendingMessagesMu.Unlock()
+	queue := pendingMessages[sessName]
+	if len(queue) == 0 {
+		return "", false
+	}
+	if len(queue) == 1 {
+		delete(pendingMessages, sessName)
+	} else {
+		pendingMessages[sessName] = queue[1:]
+	}
+	return queue[0], true
+}
+
+// pendingCount returns how many messages are queued for a session
+func pendingCount(sessName string) int {
+	pendingMessagesMu.Lock()
+	defer pendingMessagesMu.Unlock()
+	return len(pendingMessages[sessName])
+}
+
+func clearPendingMessages(sessName string) {
+	pendingMessagesMu.Lock()
+	defer pendingMessagesMu.Unlock()
+	delete(pendingMessages, sessName)
 }
 
 var (
 	monitors   = make(map[string]*SessionMonitor)
 	monitorsMu sync.Mutex
+
+	allIdleNotified bool // whether the all-idle notice was sent for the current idle period
 )
 
 // BlockCache stores the mapping of terminal blocks to Telegram messages
 // Uses content hash for deduplication instead of position
 type BlockCache struct {
-	Blocks []CachedBlock `json:"blocks"`
-	Hashes map[string]int64 `json:"hashes"` // hash -> msgID for dedup
+	Blocks    []CachedBlock    `json:"blocks"`
+	Hashes    map[string]int64 `json:"hashes"`               // hash -> msgID for dedup
+	OpenBatch int64            `json:"open_batch,omitempty"` // message new blocks are appended to when coalescing
 }
 
 type CachedBlock struct {
@@ -50,210 +184,134 @@ func blockHash(text string) string {
 }
 
 func loadBlockCache(sessionName string) *BlockCache {
-	cacheFile := filepath.Join(os.TempDir(), "ccc-blocks-"+sessionName+".json")
-	data, err := os.ReadFile(cacheFile)
+	cache, err := loadStoredBlockCache(sessionName)
 	if err != nil {
+		hookLog("store: failed to load block cache for %s: %v", sessionName, err)
 		return &BlockCache{}
 	}
-	var cache BlockCache
-	if json.Unmarshal(data, &cache) != nil {
-		return &BlockCache{}
-	}
-	return &cache
+	return cache
 }
 
 func saveBlockCache(sessionName string, cache *BlockCache) {
-	cacheFile := filepath.Join(os.TempDir(), "ccc-blocks-"+sessionName+".json")
-	data, _ := json.Marshal(cache)
-	os.WriteFile(cacheFile, data, 0600)
-}
-
-func clearBlockCache(sessionName string) {
-	cacheFile := filepath.Join(os.TempDir(), "ccc-blocks-"+sessionName+".json")
-	os.Remove(cacheFile)
+	if err := saveStoredBlockCache(sessionName, cache); err != nil {
+		hookLog("store: failed to save block cache for %s: %v", sessionName, err)
+	}
 }
 
-// getLastBlocksFromTmux captures the tmux pane and extracts assistant blocks
-// after the last user prompt (❯) that has response blocks. Each block starts
-// with ● and ends at the next ● or the input box (────).
-func getLastBlocksFromTmux(tmuxSession string) []string {
-	cmd := exec.Command(tmuxPath, "capture-pane", "-t", tmuxSession, "-p", "-S", "-500")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil
+// cachedBlockForMessage returns the blocks a Telegram message showed (several
+// when they were coalesced), or "" if the message is not in the block cache
+func cachedBlockForMessage(sessionName string, msgID int64) string {
+	if msgID <= 0 {
+		return ""
 	}
-
-	lines := strings.Split(string(output), "\n")
-
-	// Collect all ❯ prompt positions and ──── input box positions
-	var prompts []int   // indices of ❯ lines with content
-	var inputBoxes []int // indices of ──── lines
-
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "───") {
-			inputBoxes = append(inputBoxes, i)
-		} else if strings.HasPrefix(trimmed, "❯") {
-			content := strings.TrimSpace(strings.TrimPrefix(trimmed, "❯"))
-			// Also trim non-breaking space (U+00A0) which Claude Code uses
-			content = strings.TrimSpace(strings.ReplaceAll(content, "\u00a0", ""))
-			// Skip ❯ prompts inside the input box (between two ──── lines)
-			insideInputBox := false
-			for _, ib := range inputBoxes {
-				if ib == i-1 {
-					insideInputBox = true
-					break
-				}
-			}
-			if content != "" && !insideInputBox {
-				prompts = append(prompts, i)
+	var parts []string
+	for _, b := range loadBlockCache(sessionName).Blocks {
+		if b.MsgID == msgID {
+			if text := strings.TrimSpace(b.Text); text != "" {
+				parts = append(parts, text)
 			}
 		}
 	}
+	return strings.Join(parts, "\n\n")
+}
 
-	if len(prompts) == 0 {
-		return nil
+// recentCachedBlocks returns the text of the last n blocks in a session's block cache
+func recentCachedBlocks(sessionName string, n int) []string {
+	cache := loadBlockCache(sessionName)
+	start := len(cache.Blocks) - n
+	if start < 0 {
+		start = 0
 	}
-
-	// Try each prompt from most recent to oldest, return first one with blocks
-	hookLog("parser: %d prompts, %d inputBoxes, %d total lines", len(prompts), len(inputBoxes), len(lines))
-	for p := len(prompts) - 1; p >= 0; p-- {
-		promptIdx := prompts[p]
-
-		// Find the next user prompt after this one (or end of capture)
-		// We don't stop at ─── lines because blocks can appear between
-		// input box separators (e.g. after a diff block with ─── borders)
-		endIdx := len(lines)
-		for pp := p + 1; pp < len(prompts); pp++ {
-			endIdx = prompts[pp]
-			break
-		}
-
-		hookLog("parser: trying prompt %d at line %d (end %d): %s", p, promptIdx, endIdx, truncate(strings.TrimSpace(lines[promptIdx]), 40))
-		blocks := extractBlocks(lines, promptIdx+1, endIdx)
-		hookLog("parser: found %d blocks", len(blocks))
-		if len(blocks) > 0 {
-			return blocks
+	var blocks []string
+	for _, b := range cache.Blocks[start:] {
+		if text := strings.TrimSpace(b.Text); text != "" {
+			blocks = append(blocks, text)
 		}
 	}
-
-	return nil
+	return blocks
 }
 
-// extractBlocks extracts ● bullet blocks from lines[start:end]
-// Skips status lines (spinners) but continues parsing - they appear during work, not after
-func extractBlocks(lines []string, start, end int) []string {
-	var blocks []string
-	var currentBlock strings.Builder
-	inBlock := false
-
-	for i := start; i < end; i++ {
-		line := lines[i]
-		trimmed := strings.TrimSpace(line)
-
-		// Stop at input box (the final ─── before the empty prompt)
-		if strings.HasPrefix(trimmed, "───") {
-			// Check if this is the final input box (followed by empty ❯)
-			// by looking at the next few lines
-			isFinalInputBox := false
-			for j := i + 1; j < end && j < i+4; j++ {
-				nextTrimmed := strings.TrimSpace(lines[j])
-				if nextTrimmed == "" {
-					continue
-				}
-				if strings.HasPrefix(nextTrimmed, "❯") {
-					isFinalInputBox = true
-				}
-				break
-			}
-			if isFinalInputBox {
-				break
-			}
-			// Not the final input box - close current block but continue looking for more
-			if inBlock && currentBlock.Len() > 0 {
-				blocks = append(blocks, strings.TrimSpace(currentBlock.String()))
-				currentBlock.Reset()
-				inBlock = false
-			}
-			continue
-		}
-
-		// Skip status indicators (spinners) - they appear during work
-		// Don't break, just skip - there may be more content after
-		if isStatusLine(trimmed) {
-			continue
-		}
-
-		// Skip bottom status line and ❯ prompts
-		if strings.HasPrefix(trimmed, "⏵⏵") || strings.HasPrefix(trimmed, "❯") {
-			continue
-		}
-
-		if isBulletLine(trimmed) {
-			if inBlock && currentBlock.Len() > 0 {
-				blocks = append(blocks, strings.TrimSpace(currentBlock.String()))
-			}
-			currentBlock.Reset()
-			blockText := removeBulletPrefix(trimmed)
-			currentBlock.WriteString(blockText)
-			inBlock = true
-		} else if inBlock {
-			if trimmed == "" {
-				currentBlock.WriteString("\n")
-			} else {
-				currentBlock.WriteString("\n")
-				currentBlock.WriteString(trimmed)
-			}
+// maxCoalescedLen is how long a message of coalesced blocks may grow
+const maxCoalescedLen = 4000
+
+// batchText joins the blocks shown in one message
+func batchText(blocks []CachedBlock, msgID int64) string {
+	var parts []string
+	seen := make(map[string]bool)
+	for _, b := range blocks {
+		if b.MsgID == msgID && !seen[b.Hash] {
+			seen[b.Hash] = true
+			parts = append(parts, renderBlock(b.Text))
 		}
 	}
-
-	if inBlock && currentBlock.Len() > 0 {
-		blocks = append(blocks, strings.TrimSpace(currentBlock.String()))
-	}
-
-	return blocks
+	return strings.Join(parts, "\n\n")
 }
 
-func isBulletLine(trimmed string) bool {
-	return strings.HasPrefix(trimmed, "⏺") ||
-		strings.HasPrefix(trimmed, "● ") ||
-		strings.HasPrefix(trimmed, "✻ ")
+func clearBlockCache(sessionName string) {
+	if err := deleteStoredBlockCache(sessionName); err != nil {
+		hookLog("store: failed to clear block cache for %s: %v", sessionName, err)
+	}
 }
 
-// isStatusLine checks for transient status indicators that should stop block capture
-func isStatusLine(trimmed string) bool {
-	return strings.HasPrefix(trimmed, "✱") || // Hashing, Thinking
-		strings.HasPrefix(trimmed, "✢") || // Symbioting, Computing
-		strings.HasPrefix(trimmed, "✽") || // Other status
-		strings.HasPrefix(trimmed, "✻") || // Sautéed, etc
-		strings.HasPrefix(trimmed, "+") || // Progress indicator
-		strings.HasPrefix(trimmed, "*") // Alternative status
+// getLastBlocksFromTmux captures the tmux pane and extracts assistant blocks
+// after the last user prompt (❯) that has response blocks. Each block starts
+// with ● and ends at the next ● or the input box (────). The capture starts at
+// the prompt found last time, so blocks aren't lost when a turn outgrows a
+// fixed-size capture.
+func getLastBlocksFromTmux(tmuxSession string) []string {
+	capture, err := capturePaneHistory(tmuxSession)
+	if err != nil {
+		return nil
+	}
+	lines, origin := normalizePane(capture.Lines, capture.Width)
+	blocks, promptIdx := lastTurnBlocks(lines)
+	if promptIdx >= 0 {
+		recordTurnStart(tmuxSession, capture, origin[promptIdx])
+	}
+	return blocks
 }
 
-// isStatusBlock checks if a block content looks like a transient status message
-func isStatusBlock(text string) bool {
-	lower := strings.ToLower(text)
-	// Skip short blocks that are just status words
-	if len(text) < 50 {
-		statusWords := []string{"thinking", "transfiguring", "spinning", "sautéed", "sauteed",
-			"hashing", "computing", "processing", "loading", "churned", "working", "concocting"}
-		for _, word := range statusWords {
-			if strings.Contains(lower, word) {
+// looksLikeShellPrompt reports whether the last non-empty line of a pane
+// capture is a bare shell prompt rather than Claude's UI.
+func looksLikeShellPrompt(paneText string) bool {
+	lines := strings.Split(strings.TrimRight(paneText, "\n "), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "❯") || strings.HasPrefix(trimmed, "───") || strings.HasPrefix(trimmed, "⏵⏵") {
+			return false
+		}
+		for _, suffix := range []string{"$", "#", "%", ">"} {
+			if strings.HasSuffix(trimmed, suffix) {
 				return true
 			}
 		}
+		return false
 	}
 	return false
 }
 
-func removeBulletPrefix(s string) string {
-	// Order matters: longer prefixes first to match correctly
-	for _, prefix := range []string{"⏺  ", "⏺ ", "● ", "✻ "} {
-		if strings.HasPrefix(s, prefix) {
-			return strings.TrimPrefix(s, prefix)
-		}
+// paneRunsShell reports whether the pane's foreground process is a bare shell,
+// meaning Claude is not running. Falls back to inspecting the pane text when
+// tmux can't report the current command.
+func paneRunsShell(command string, paneText string) bool {
+	if command != "" {
+		return isShellCommand(command)
+	}
+	return looksLikeShellPrompt(paneText)
+}
+
+// isClaudeExited checks whether a tmux session's pane has dropped to a shell
+func isClaudeExited(tmuxSession string) bool {
+	command := paneCurrentCommand(tmuxSession)
+	var paneText string
+	if command == "" {
+		out, _ := exec.Command(tmuxPath, "capture-pane", "-t", tmuxSession, "-p").Output()
+		paneText = string(out)
 	}
-	return s
+	return paneRunsShell(command, paneText)
 }
 
 // isClaudeIdle checks if Claude is waiting for input (empty ❯ prompt visible, no spinner)
@@ -264,7 +322,7 @@ func isClaudeIdle(tmuxSession string) bool {
 		return false
 	}
 
-	lines := strings.Split(string(output), "\n")
+	lines, _ := normalizePane(strings.Split(string(output), "\n"), 0)
 
 	// First, check if there's an active spinner/status - if so, not idle
 	for i := len(lines) - 1; i >= 0 && i >= len(lines)-10; i-- {
@@ -302,7 +360,8 @@ func isClaudeIdle(tmuxSession string) bool {
 // Uses content hash for deduplication to avoid sending duplicate messages.
 func syncBlocksToTelegram(config *Config, sessName string, topicID int64, isFinal bool) int {
 	tmuxName := sessionName(sessName)
-	blocks := getLastBlocksFromTmux(tmuxName)
+	chatID := sessionChat(config, sessName)
+	blocks := filterBlocks(config, getLastBlocksFromTmux(tmuxName))
 	hookLog("sync: session=%s blocks=%d isFinal=%v", sessName, len(blocks), isFinal)
 	if len(blocks) == 0 {
 		return 0
@@ -316,6 +375,27 @@ func syncBlocksToTelegram(config *Config, sessName string, topicID int64, isFina
 
 	// Track which blocks we're sending this round
 	newBlocks := make([]CachedBlock, 0, len(blocks))
+	sentThisPass := 0
+
+	// With /verbose off, consecutive blocks share one message that is edited
+	// as they arrive; dirty holds the messages to re-render at the end
+	coalesce := false
+	if info := config.Sessions[sessName]; info != nil {
+		coalesce = info.Coalesce
+	}
+	dirty := make(map[int64]bool)
+	var finalMsgID int64
+
+	// Past the summarize threshold, blocks are held for the turn's summary
+	summarize := summarizeEnabled(config)
+	turnChars := 0
+	var held []string
+	heldBefore := false
+	for _, b := range cache.Blocks {
+		if b.MsgID == heldBlockMsgID {
+			heldBefore = true
+		}
+	}
 
 	for i, block := range blocks {
 		// Skip blocks that look like transient status messages
@@ -325,9 +405,10 @@ func syncBlocksToTelegram(config *Config, sessName string, topicID int64, isFina
 		}
 
 		hash := blockHash(block)
-		displayText := block
+		turnChars += len(block)
+		displayText := renderBlock(block)
 		if isFinal && i == len(blocks)-1 {
-			displayText = "✅ " + sessName + "\n\n" + block
+			displayText = completionHeader(config, sessName) + displayText
 		}
 
 		// Check if we already sent this block (by hash)
@@ -337,6 +418,13 @@ func syncBlocksToTelegram(config *Config, sessName string, topicID int64, isFina
 				newBlocks = append(newBlocks, CachedBlock{Text: block, MsgID: -1, Hash: hash})
 				continue
 			}
+			if existingMsgID == heldBlockMsgID || existingMsgID == summarizedBlockMsgID {
+				if existingMsgID == heldBlockMsgID {
+					held = append(held, block)
+				}
+				newBlocks = append(newBlocks, CachedBlock{Text: block, MsgID: existingMsgID, Hash: hash})
+				continue
+			}
 			if existingMsgID > 0 {
 				// Block already sent - check if content changed (for edits)
 				for j := range cache.Blocks {
@@ -344,21 +432,57 @@ func syncBlocksToTelegram(config *Config, sessName string, topicID int64, isFina
 						if strings.TrimSpace(cache.Blocks[j].Text) != strings.TrimSpace(block) {
 							// Content changed, edit the message
 							cache.Blocks[j].Text = block
-							editMessage(config, config.GroupID, existingMsgID, topicID, displayText)
+							offerFullFileForBlock(config, sessName, topicID, block)
+							if coalesce {
+								dirty[existingMsgID] = true
+							} else {
+								outputMessenger(config).EditFormatted(chatID, existingMsgID, topicID, displayText)
+							}
 						} else if isFinal && i == len(blocks)-1 {
 							// Add ✅ prefix on final
-							editMessage(config, config.GroupID, existingMsgID, topicID, displayText)
+							if coalesce {
+								dirty[existingMsgID] = true
+							} else {
+								outputMessenger(config).EditFormatted(chatID, existingMsgID, topicID, displayText)
+							}
 						}
 						break
 					}
 				}
+				if isFinal && i == len(blocks)-1 {
+					finalMsgID = existingMsgID
+				}
 				newBlocks = append(newBlocks, CachedBlock{Text: block, MsgID: existingMsgID, Hash: hash})
 				continue
 			}
 		}
-		// New block - send it
+		if summarize && overSummaryThreshold(config, i, turnChars) {
+			hookLog("sync: session=%s holding block %d for the summary", sessName, i)
+			cache.Hashes[hash] = heldBlockMsgID
+			newBlocks = append(newBlocks, CachedBlock{Text: block, MsgID: heldBlockMsgID, Hash: hash})
+			held = append(held, block)
+			continue
+		}
+		// New block right after the open batch - append it while it fits
+		if batch := cache.OpenBatch; coalesce && batch > 0 && len(newBlocks) > 0 && newBlocks[len(newBlocks)-1].MsgID == batch &&
+			len(batchText(newBlocks, batch))+2+len(block) <= maxCoalescedLen {
+			hookLog("sync: session=%s appending block %d to msgID=%d", sessName, i, batch)
+			cache.Hashes[hash] = batch
+			newBlocks = append(newBlocks, CachedBlock{Text: block, MsgID: batch, Hash: hash})
+			dirty[batch] = true
+			offerFullFileForBlock(config, sessName, topicID, block)
+			if isFinal && i == len(blocks)-1 {
+				finalMsgID = batch
+			}
+			continue
+		}
+		// New block - send it, spacing out bursts to stay under Telegram flood limits
+		if sentThisPass > 0 && config.BlockSendDelayMs > 0 {
+			time.Sleep(time.Duration(config.BlockSendDelayMs) * time.Millisecond)
+		}
+		sentThisPass++
 		hookLog("sync: session=%s sending NEW block %d hash=%s", sessName, i, truncate(hash, 30))
-		msgID, err := sendMessageGetID(config, config.GroupID, topicID, displayText)
+		msgID, err := outputMessenger(config).SendFormatted(chatID, topicID, displayText)
 		if err != nil {
 			hookLog("sync: session=%s ERROR sending block %d: %v", sessName, i, err)
 			newBlocks = append(newBlocks, CachedBlock{Text: block, MsgID: 0, Hash: hash})
@@ -366,7 +490,38 @@ func syncBlocksToTelegram(config *Config, sessName string, topicID int64, isFina
 			hookLog("sync: session=%s block %d sent msgID=%d", sessName, i, msgID)
 			cache.Hashes[hash] = msgID
 			newBlocks = append(newBlocks, CachedBlock{Text: block, MsgID: msgID, Hash: hash})
+			offerFullFileForBlock(config, sessName, topicID, block)
+			if coalesce {
+				cache.OpenBatch = msgID
+			}
+		}
+	}
+
+	for _, b := range newBlocks {
+		if !dirty[b.MsgID] {
+			continue
+		}
+		delete(dirty, b.MsgID)
+		text := batchText(newBlocks, b.MsgID)
+		if isFinal && b.MsgID == finalMsgID {
+			text = completionHeader(config, sessName) + text
+		}
+		outputMessenger(config).EditFormatted(chatID, b.MsgID, topicID, text)
+	}
+	if len(held) > 0 && isFinal {
+		go sendOutputSummary(config, sessName, topicID, completionHeader(config, sessName), held)
+		for i := range newBlocks {
+			if newBlocks[i].MsgID == heldBlockMsgID {
+				newBlocks[i].MsgID = summarizedBlockMsgID
+				cache.Hashes[newBlocks[i].Hash] = summarizedBlockMsgID
+			}
 		}
+	} else if len(held) > 0 && !heldBefore {
+		outputMessenger(config).Send(chatID, topicID, "📝 Long output — the rest of this turn will be summarized when it finishes.")
+	}
+	if isFinal {
+		// The turn is over; the next one starts a fresh message
+		cache.OpenBatch = 0
 	}
 
 	cache.Blocks = newBlocks
@@ -374,6 +529,30 @@ func syncBlocksToTelegram(config *Config, sessName string, topicID int64, isFina
 	return len(blocks)
 }
 
+// maxQuotedPromptLen caps how much of the user's prompt is quoted in completions
+const maxQuotedPromptLen = 200
+
+// completionHeader returns the "✅ session" header for a completion message,
+// quoting the user's last prompt when QuotePromptInCompletion is enabled.
+func completionHeader(config *Config, sessName string) string {
+	header := "✅ " + sessName + "\n\n"
+	if !config.QuotePromptInCompletion {
+		return header
+	}
+
+	monitorsMu.Lock()
+	var prompt string
+	if mon, exists := monitors[sessName]; exists {
+		prompt = strings.TrimSpace(mon.LastPrompt)
+	}
+	monitorsMu.Unlock()
+
+	if prompt == "" {
+		return header
+	}
+	return header + "❓ " + truncate(prompt, maxQuotedPromptLen) + "\n\n"
+}
+
 // initializeMonitors prepares all existing sessions for monitoring after a restart.
 // This ensures messages sent after /update are properly forwarded.
 func initializeMonitors(config *Config) {
@@ -381,7 +560,7 @@ func initializeMonitors(config *Config) {
 	defer monitorsMu.Unlock()
 
 	for sessName, info := range config.Sessions {
-		if info == nil || info.TopicID == 0 {
+		if info == nil || info.TopicID == 0 || !ownsSession(config, info) {
 			continue
 		}
 		tmuxName := sessionName(sessName)
@@ -404,26 +583,59 @@ func initializeMonitors(config *Config) {
 		}
 
 		// Populate hash cache with existing blocks to prevent re-sending after restart
-		// Use msgID = -1 as marker for "already shown, don't resend"
-		cache := loadBlockCache(sessName)
-		if cache.Hashes == nil {
-			cache.Hashes = make(map[string]int64)
-		}
-		for _, block := range currentBlocks {
-			hash := blockHash(block)
-			if _, exists := cache.Hashes[hash]; !exists {
-				cache.Hashes[hash] = -1 // Mark as shown but no telegram msg
-				cache.Blocks = append(cache.Blocks, CachedBlock{Text: block, MsgID: -1, Hash: hash})
-			}
+		n := seedBlockCache(sessName, currentBlocks)
+		hookLog("monitor: initialized session=%s blocks=%d idle=%v cache=%d", sessName, len(currentBlocks), idle, n)
+	}
+}
+
+// seedBlockCache marks blocks as already shown so the monitor never sends them,
+// using msgID = -1 as the "already shown, don't resend" marker. It returns the
+// cache size.
+func seedBlockCache(sessName string, blocks []string) int {
+	cache := loadBlockCache(sessName)
+	if cache.Hashes == nil {
+		cache.Hashes = make(map[string]int64)
+	}
+	for _, block := range blocks {
+		hash := blockHash(block)
+		if _, exists := cache.Hashes[hash]; !exists {
+			cache.Hashes[hash] = -1 // Mark as shown but no telegram msg
+			cache.Blocks = append(cache.Blocks, CachedBlock{Text: block, MsgID: -1, Hash: hash})
 		}
-		saveBlockCache(sessName, cache)
-		hookLog("monitor: initialized session=%s blocks=%d idle=%v cache=%d", sessName, len(currentBlocks), idle, len(cache.Hashes))
 	}
+	saveBlockCache(sessName, cache)
+	return len(cache.Hashes)
+}
+
+// completeTurn marks a session's turn finished: it records the turn duration
+// and starts the test gate or autocommit checkpoint if enabled
+func completeTurn(config *Config, sessName string, info *SessionInfo, mon *SessionMonitor) {
+	monitorsMu.Lock()
+	mon.Completed = true
+	started := mon.TurnStarted
+	mon.TurnStarted = time.Time{}
+	prompt := mon.LastPrompt
+	monitorsMu.Unlock()
+	if !started.IsZero() {
+		recordTurnDuration(sessName, time.Since(started))
+	}
+	// With a test gate, only a passing turn is autocommitted
+	if info.TestGate != "" {
+		go runTestGate(config, sessName, info, prompt)
+	} else if info.AutoCommit {
+		go autoCommitCheckpoint(config, sessName, info, prompt)
+	}
+	if info.GitHubIssue != "" {
+		go postIssueProgress(config, sessName, info)
+	}
+	notifyTurnComplete(config, sessName, info, "✅ Finished:")
 }
 
 // startSessionMonitor runs a background goroutine that polls all active tmux
 // sessions every few seconds, parses their terminal output, and syncs blocks
-// to Telegram.
+// to Telegram. Panes are also streamed through tmux pipe-pane so a session is
+// polled as soon as it prints, and idle sessions that print nothing aren't
+// captured at all.
 func startSessionMonitor(config *Config) {
 	// Initialize all existing sessions first
 	initializeMonitors(config)
@@ -431,100 +643,276 @@ func startSessionMonitor(config *Config) {
 	ticker := time.NewTicker(3 * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		// Reload config to pick up new sessions
-		freshConfig, err := loadConfig()
-		if err != nil {
-			continue
-		}
-
-		for sessName, info := range freshConfig.Sessions {
-			if info == nil || info.TopicID == 0 || freshConfig.GroupID == 0 {
+	streams := newPaneStreams()
+	for {
+		select {
+		case sessName := <-streams.activity:
+			// Output arrived: forward it now rather than on the next tick
+			freshConfig, err := loadConfig()
+			if err != nil {
 				continue
 			}
+			pollSession(freshConfig, streams, sessName, freshConfig.Sessions[sessName], false)
 
-			tmuxName := sessionName(sessName)
-			if !tmuxSessionExists(tmuxName) {
-				continue
-			}
+		case <-ticker.C:
+			markMonitorTick()
 
-			monitorsMu.Lock()
-			mon, exists := monitors[sessName]
-			if !exists {
-				now := time.Now()
-				mon = &SessionMonitor{LastActivity: now, LastUserMessage: now}
-				monitors[sessName] = mon
-			}
-			monitorsMu.Unlock()
-
-			// Always poll every 3s - slow polling caused missed messages
-			// The completed flag prevents unnecessary syncs when idle
-			_ = mon.SlowPollCounter // unused now, kept for struct compat
-
-			blocks := getLastBlocksFromTmux(tmuxName)
-			hookLog("monitor: session=%s blocks=%d firstPoll=%v", sessName, len(blocks), !exists)
-
-			// First time seeing this session: seed with existing blocks without sending
-			if !exists && len(blocks) > 0 {
-				mon.LastBlocks = blocks
-				mon.StableCount = 0
-				// If Claude is idle, mark completed immediately
-				if isClaudeIdle(tmuxName) {
-					mon.Completed = true
-				}
-				// Populate cache so we don't re-send these blocks later
-				cache := loadBlockCache(sessName)
-				if len(cache.Blocks) == 0 {
-					for _, b := range blocks {
-						cache.Blocks = append(cache.Blocks, CachedBlock{Text: b, MsgID: 0})
-					}
-					saveBlockCache(sessName, cache)
-				}
-				hookLog("monitor: seeded session=%s with %d existing blocks (idle=%v)", sessName, len(blocks), mon.Completed)
+			// Reload config to pick up new sessions
+			freshConfig, err := loadConfig()
+			if err != nil {
 				continue
 			}
 
-			// No blocks = nothing to do
-			if len(blocks) == 0 {
-				if mon.Completed {
-					// Still idle, nothing to do
+			streams.sync(freshConfig)
+			for sessName, info := range freshConfig.Sessions {
+				if !ownsSession(freshConfig, info) {
+					// Another machine's session: its listener forwards it
 					continue
 				}
-				mon.LastBlocks = nil
-				mon.StableCount = 0
-				continue
+				if checkSessionDeadline(freshConfig, sessName, info) {
+					continue
+				}
+				pollSession(freshConfig, streams, sessName, info, true)
 			}
 
-			// Check if blocks changed
-			changed := !blocksEqual(blocks, mon.LastBlocks)
-			hookLog("monitor: session=%s changed=%v blocks=%d lastBlocks=%d", sessName, changed, len(blocks), len(mon.LastBlocks))
-
-			if changed {
-				mon.LastBlocks = blocks
-				mon.StableCount = 0
-				mon.Completed = false
-				mon.LastActivity = time.Now()
-				// Sync intermediate state
-				syncBlocksToTelegram(freshConfig, sessName, info.TopicID, false)
+			checkAllSessionsIdle(freshConfig)
+		}
+	}
+}
+
+// pollSession captures a session's pane and forwards new output. Only polls
+// on the ticker count towards the stable polls that complete a turn, since
+// polls on stream activity come as often as the pane redraws.
+func pollSession(freshConfig *Config, streams *paneStreams, sessName string, info *SessionInfo, tick bool) {
+	if info == nil || info.TopicID == 0 || !hasSessionChannel(freshConfig) {
+		return
+	}
+
+	tmuxName := sessionName(sessName)
+	if !tmuxSessionExists(tmuxName) {
+		return
+	}
+
+	monitorsMu.Lock()
+	mon, exists := monitors[sessName]
+	if !exists {
+		now := time.Now()
+		mon = &SessionMonitor{LastActivity: now, LastUserMessage: now}
+		monitors[sessName] = mon
+	}
+	monitorsMu.Unlock()
+
+	// Detect Claude exiting (crash, kill, OOM) and the pane dropping to a shell.
+	// markClaudeRestarting resets the crash state from other goroutines.
+	if isClaudeExited(tmuxName) {
+		monitorsMu.Lock()
+		crashed := mon.ClaudeSeen && !mon.Crashed
+		if crashed {
+			mon.Crashed = true
+		}
+		monitorsMu.Unlock()
+		if crashed {
+			hookLog("monitor: session=%s claude exited", sessName)
+			sendPaneAlert(freshConfig, sessName, info, &crashedPaneError, "")
+		}
+		updateTopicStatus(freshConfig, sessName, info, topicError)
+		updateStatusPin(freshConfig, sessName, info, mon, topicError)
+		return
+	}
+	monitorsMu.Lock()
+	mon.ClaudeSeen = true
+	mon.Crashed = false
+	monitorsMu.Unlock()
+
+	// Failures shown in the pane (usage limit, expired login, API errors)
+	// end a turn like any answer would; alert instead of letting them pass
+	// as idle. An unchanged pane keeps what was found last time.
+	failed := mon.Alert != ""
+	if !mon.Completed || streams.changed(sessName) {
+		failed = checkPaneError(freshConfig, sessName, info, mon, tmuxName)
+	}
+	if freshConfig.TopicStatus || freshConfig.StatusPin {
+		state := topicWorking
+		if failed {
+			state = topicError
+		} else if isClaudeIdle(tmuxName) {
+			state = topicIdle
+		}
+		updateTopicStatus(freshConfig, sessName, info, state)
+		updateStatusPin(freshConfig, sessName, info, mon, state)
+	}
+
+	if tick && checkHibernation(freshConfig, sessName, info, mon, tmuxName) {
+		return
+	}
+
+	// Turn finished and Claude is waiting: type in the next queued message,
+	// unless the usage limit stopped the session or its first message after
+	// an auto-start hasn't gone in yet
+	checkUsageResume(freshConfig, sessName, info, mon, tmuxName, time.Now())
+	if mon.Completed && pendingCount(sessName) > 0 && !usageLimited(sessName) && !sessionStarting(sessName) && isClaudeIdle(tmuxName) {
+		if text, ok := dequeueMessage(sessName); ok {
+			hookLog("monitor: session=%s delivering queued message (%d left)", sessName, pendingCount(sessName))
+			if err := typeIntoSession(sessName, text); err != nil {
+				getMessenger(freshConfig).Send(sessionGroup(freshConfig, info), info.TopicID, fmt.Sprintf("❌ Failed to send queued message: %v", err))
 			} else {
-				mon.StableCount++
+				getMessenger(freshConfig).Send(sessionGroup(freshConfig, info), info.TopicID, formatQueueAdvance(pendingCount(sessName), averageTurnDuration(sessName)))
 			}
+			return
+		}
+	}
 
-			// If blocks are stable for 3+ polls AND Claude is idle → mark complete
-			// Increased from 2 to 3 polls (9s) to avoid premature completion
-			idle := isClaudeIdle(tmuxName)
-			hookLog("monitor: session=%s stable=%d completed=%v idle=%v", sessName, mon.StableCount, mon.Completed, idle)
-			if !mon.Completed && mon.StableCount >= 3 && idle {
-				n := syncBlocksToTelegram(freshConfig, sessName, info.TopicID, true)
-				if n == 0 {
-					sendMessage(freshConfig, freshConfig.GroupID, info.TopicID, fmt.Sprintf("✅ %s", sessName))
-				}
-				mon.Completed = true
+	// Hook events are delivering output; fall back to parsing the pane
+	// once they stop, without resending what's already on screen
+	monitorsMu.Lock()
+	hookDriven := mon.hookDriven(time.Now())
+	wasHookDriven := mon.HookDriven
+	mon.HookDriven = hookDriven
+	monitorsMu.Unlock()
+	if hookDriven {
+		return
+	}
+	if wasHookDriven {
+		blocks := getLastBlocksFromTmux(tmuxName)
+		seedBlockCache(sessName, blocks)
+		mon.LastBlocks = blocks
+		mon.StableCount = 0
+		hookLog("monitor: session=%s hook events stopped, polling pane (%d blocks seeded)", sessName, len(blocks))
+		return
+	}
+
+	// Always poll every 3s - slow polling caused missed messages
+	// The completed flag prevents unnecessary syncs when idle
+	_ = mon.SlowPollCounter // unused now, kept for struct compat
+
+	// A streamed pane that printed nothing since the last capture is unchanged
+	if streams.quiet(sessName) && mon.Completed {
+		return
+	}
+
+	blocks := getLastBlocksFromTmux(tmuxName)
+	hookLog("monitor: session=%s blocks=%d firstPoll=%v", sessName, len(blocks), !exists)
+
+	// First time seeing this session: seed with existing blocks without sending
+	if !exists && len(blocks) > 0 {
+		mon.LastBlocks = blocks
+		mon.StableCount = 0
+		// If Claude is idle, mark completed immediately
+		if isClaudeIdle(tmuxName) {
+			mon.Completed = true
+		}
+		// Populate cache so we don't re-send these blocks later
+		cache := loadBlockCache(sessName)
+		if len(cache.Blocks) == 0 {
+			for _, b := range blocks {
+				cache.Blocks = append(cache.Blocks, CachedBlock{Text: b, MsgID: 0})
 			}
-			// Removed: force completion after 30s stable - this caused missed messages
-			// Now we only complete when truly idle
+			saveBlockCache(sessName, cache)
+		}
+		hookLog("monitor: seeded session=%s with %d existing blocks (idle=%v)", sessName, len(blocks), mon.Completed)
+		return
+	}
+
+	// No blocks = nothing to do
+	if len(blocks) == 0 {
+		if mon.Completed {
+			// Still idle, nothing to do
+			return
+		}
+		mon.LastBlocks = nil
+		mon.StableCount = 0
+		return
+	}
+
+	// Check if blocks changed
+	changed := !blocksEqual(blocks, mon.LastBlocks)
+	hookLog("monitor: session=%s changed=%v blocks=%d lastBlocks=%d", sessName, changed, len(blocks), len(mon.LastBlocks))
+
+	if changed {
+		monitorsMu.Lock()
+		mon.CurrentTool = currentToolFromBlocks(blocks)
+		monitorsMu.Unlock()
+		mon.LastBlocks = blocks
+		mon.StableCount = 0
+		mon.Completed = false
+		mon.LastActivity = time.Now()
+		// Sync intermediate state
+		syncBlocksToTelegram(freshConfig, sessName, info.TopicID, false)
+	} else if tick {
+		mon.StableCount++
+	}
+
+	// If blocks are stable for 3+ polls AND Claude is idle → mark complete
+	// Increased from 2 to 3 polls (9s) to avoid premature completion
+	idle := isClaudeIdle(tmuxName)
+	hookLog("monitor: session=%s stable=%d completed=%v idle=%v", sessName, mon.StableCount, mon.Completed, idle)
+	if !mon.Completed && mon.StableCount >= 3 && idle {
+		n := syncBlocksToTelegram(freshConfig, sessName, info.TopicID, true)
+		if n == 0 {
+			outputMessenger(freshConfig).Send(sessionGroup(freshConfig, info), info.TopicID, strings.TrimSpace(completionHeader(freshConfig, sessName)))
+		}
+		completeTurn(freshConfig, sessName, info, mon)
+	}
+	// Removed: force completion after 30s stable - this caused missed messages
+	// Now we only complete when truly idle
+}
+
+// allSessionsIdleFor returns the number of monitored sessions and how long
+// all of them have been idle. The duration is 0 if any session is still working.
+func allSessionsIdleFor(now time.Time) (int, time.Duration) {
+	monitorsMu.Lock()
+	defer monitorsMu.Unlock()
+
+	if len(monitors) == 0 {
+		return 0, 0
+	}
+	var lastActivity time.Time
+	for _, mon := range monitors {
+		if !mon.Completed {
+			return len(monitors), 0
+		}
+		if mon.LastActivity.After(lastActivity) {
+			lastActivity = mon.LastActivity
 		}
 	}
+	return len(monitors), now.Sub(lastActivity)
+}
+
+// checkAllSessionsIdle notifies the private chat once when every session has
+// been idle for at least IdleNotifyMinutes. The notice re-arms as soon as any
+// session becomes active again.
+func checkAllSessionsIdle(config *Config) {
+	if config.IdleNotifyMinutes <= 0 || config.ChatID == 0 {
+		return
+	}
+
+	count, idle := allSessionsIdleFor(time.Now())
+	threshold := time.Duration(config.IdleNotifyMinutes) * time.Minute
+	if count == 0 || idle < threshold {
+		allIdleNotified = false
+		return
+	}
+	if allIdleNotified || !isAway(config) {
+		return
+	}
+	allIdleNotified = true
+	hookLog("monitor: all %d sessions idle for %v", count, idle)
+	getMessenger(config).Send(config.ChatID, 0, fmt.Sprintf("💤 All %d sessions idle for %s", count, formatDuration(threshold)))
+}
+
+// formatDuration renders a duration compactly, e.g. "1h", "1h30m" or "45m"
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	switch {
+	case h > 0 && m > 0:
+		return fmt.Sprintf("%dh%dm", h, m)
+	case h > 0:
+		return fmt.Sprintf("%dh", h)
+	default:
+		return fmt.Sprintf("%dm", m)
+	}
 }
 
 // ResetSessionMonitor marks a session as actively awaiting new output (called when user sends a message)
@@ -539,17 +927,58 @@ func ResetSessionMonitor(sessionName string) {
 		mon.StableCount = 0
 		mon.LastUserMessage = time.Now()
 		mon.LastActivity = time.Now()
+		mon.TurnStarted = time.Now()
 		// Keep LastBlocks - only new blocks will be detected as changes
 	} else {
 		monitors[sessionName] = &SessionMonitor{
 			LastUserMessage: time.Now(),
 			LastActivity:    time.Now(),
+			TurnStarted:     time.Now(),
 			Completed:       false,
 		}
 	}
 	// Don't clear cache - hash dedup handles everything
 }
 
+// sessionLastActivity returns when a session last produced output or received a
+// message, or the zero time if it hasn't been monitored yet.
+func sessionLastActivity(sessionName string) time.Time {
+	monitorsMu.Lock()
+	defer monitorsMu.Unlock()
+
+	mon, exists := monitors[sessionName]
+	if !exists {
+		return time.Time{}
+	}
+	if mon.LastUserMessage.After(mon.LastActivity) {
+		return mon.LastUserMessage
+	}
+	return mon.LastActivity
+}
+
+// markClaudeRestarting suppresses the crash alert while Claude is deliberately
+// restarted; it re-arms once Claude is seen running again.
+func markClaudeRestarting(sessionName string) {
+	monitorsMu.Lock()
+	defer monitorsMu.Unlock()
+
+	if mon, exists := monitors[sessionName]; exists {
+		mon.ClaudeSeen = false
+		mon.Crashed = false
+	}
+}
+
+// SetSessionPrompt records the text the user last sent to a session so the
+// completion message can quote it.
+func SetSessionPrompt(sessionName string, prompt string) {
+	monitorsMu.Lock()
+	defer monitorsMu.Unlock()
+
+	if mon, exists := monitors[sessionName]; exists {
+		mon.LastPrompt = prompt
+	}
+}
+
 // ClearSessionMonitor completely removes monitor state and cache (called on /continue, /new, /delete)
 // Use this when the session is being restarted from scratch.
 func ClearSessionMonitor(sessionName string) {
@@ -557,6 +986,7 @@ func ClearSessionMonitor(sessionName string) {
 	defer monitorsMu.Unlock()
 	delete(monitors, sessionName)
 	clearBlockCache(sessionName)
+	clearPendingMessages(sessionName)
 }
 
 func blocksEqual(a, b []string) bool {
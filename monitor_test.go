@@ -219,6 +219,20 @@ func TestExtractBlocks(t *testing.T) {
 			end:      3,
 			expected: []string{"Block"},
 		},
+		{
+			name: "nested subagent bullets stay in their block",
+			lines: []string{
+				"❯ explore",
+				"⏺ Task(Find the config loader)",
+				"  ⎿  ⏺ Read(config.go)",
+				"     ⏺ Search(pattern: \"loadConfig\")",
+				"  ⎿  Done (2 tool uses · 8.1k tokens · 12s)",
+				"⏺ Found it.",
+			},
+			start:    1,
+			end:      6,
+			expected: []string{"Task(Find the config loader)\n⎿  ⏺ Read(config.go)\n⏺ Search(pattern: \"loadConfig\")\n⎿  Done (2 tool uses · 8.1k tokens · 12s)", "Found it."},
+		},
 		{
 			name: "handles real Claude output format",
 			lines: []string{
@@ -261,6 +275,7 @@ func TestBlockCache(t *testing.T) {
 	originalTmp := os.Getenv("TMPDIR")
 	os.Setenv("TMPDIR", tmpDir)
 	defer os.Setenv("TMPDIR", originalTmp)
+	t.Setenv("HOME", tmpDir)
 
 	sessionName := "test-session"
 	cacheFile := filepath.Join(tmpDir, "ccc-blocks-"+sessionName+".json")
@@ -282,9 +297,12 @@ func TestBlockCache(t *testing.T) {
 	}
 	saveBlockCache(sessionName, cache)
 
-	// Verify file exists
-	if _, err := os.Stat(cacheFile); os.IsNotExist(err) {
-		t.Error("Cache file was not created")
+	// Verify it went to the store, not a /tmp file
+	if _, err := os.Stat(getStorePath()); os.IsNotExist(err) {
+		t.Error("Store was not created")
+	}
+	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
+		t.Error("Legacy cache file should not be written")
 	}
 
 	// Load and verify
@@ -304,8 +322,31 @@ func TestBlockCache(t *testing.T) {
 
 	// Test clear
 	clearBlockCache(sessionName)
+	if cleared := loadBlockCache(sessionName); len(cleared.Blocks) != 0 {
+		t.Errorf("cache has %d blocks after clear, want 0", len(cleared.Blocks))
+	}
+}
+
+func TestBlockCacheLegacyMigration(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	t.Setenv("HOME", tmpDir)
+
+	cacheFile := filepath.Join(tmpDir, "ccc-blocks-legacy.json")
+	legacy := []byte(`{"blocks":[{"text":"old","msg_id":42,"hash":"old"}],"hashes":{"old":42}}`)
+	if err := os.WriteFile(cacheFile, legacy, 0600); err != nil {
+		t.Fatalf("Failed to write legacy cache: %v", err)
+	}
+
+	cache := loadBlockCache("legacy")
+	if len(cache.Blocks) != 1 || cache.Hashes["old"] != 42 {
+		t.Fatalf("migrated cache = %+v, want the legacy block", cache)
+	}
 	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
-		t.Error("Cache file should be deleted after clear")
+		t.Error("Legacy cache file should be removed after migration")
+	}
+	if again := loadBlockCache("legacy"); len(again.Blocks) != 1 {
+		t.Errorf("cache has %d blocks after migration, want 1 from the store", len(again.Blocks))
 	}
 }
 
@@ -319,6 +360,7 @@ func TestBlockCacheInvalidJSON(t *testing.T) {
 	originalTmp := os.Getenv("TMPDIR")
 	os.Setenv("TMPDIR", tmpDir)
 	defer os.Setenv("TMPDIR", originalTmp)
+	t.Setenv("HOME", tmpDir)
 
 	// Write invalid JSON
 	cacheFile := filepath.Join(tmpDir, "ccc-blocks-invalid.json")
@@ -403,6 +445,8 @@ func TestSessionMonitorReset(t *testing.T) {
 }
 
 func TestClearSessionMonitor(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
 	// Clear and set up test state
 	monitorsMu.Lock()
 	monitors = make(map[string]*SessionMonitor)
@@ -618,6 +662,8 @@ func TestExtractBlocksRealWorldScenario(t *testing.T) {
 }
 
 func TestMonitorMutexSafety(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
 	// Test concurrent access to monitors
 	monitorsMu.Lock()
 	monitors = make(map[string]*SessionMonitor)
@@ -648,3 +694,294 @@ func TestMonitorMutexSafety(t *testing.T) {
 
 	// Should not panic or deadlock
 }
+
+func TestAllSessionsIdleFor(t *testing.T) {
+	now := time.Now()
+
+	monitorsMu.Lock()
+	monitors = make(map[string]*SessionMonitor)
+	monitorsMu.Unlock()
+
+	if count, _ := allSessionsIdleFor(now); count != 0 {
+		t.Errorf("empty monitors count = %d, want 0", count)
+	}
+
+	monitorsMu.Lock()
+	monitors["a"] = &SessionMonitor{Completed: true, LastActivity: now.Add(-2 * time.Hour)}
+	monitors["b"] = &SessionMonitor{Completed: true, LastActivity: now.Add(-1 * time.Hour)}
+	monitorsMu.Unlock()
+
+	count, idle := allSessionsIdleFor(now)
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+	if idle != time.Hour {
+		t.Errorf("idle = %v, want 1h (most recent activity)", idle)
+	}
+
+	monitorsMu.Lock()
+	monitors["c"] = &SessionMonitor{Completed: false, LastActivity: now.Add(-3 * time.Hour)}
+	monitorsMu.Unlock()
+
+	if _, idle := allSessionsIdleFor(now); idle != 0 {
+		t.Errorf("idle with a working session = %v, want 0", idle)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		input    time.Duration
+		expected string
+	}{
+		{time.Hour, "1h"},
+		{90 * time.Minute, "1h30m"},
+		{45 * time.Minute, "45m"},
+		{0, "0m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := formatDuration(tt.input); result != tt.expected {
+				t.Errorf("formatDuration(%v) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompletionHeader(t *testing.T) {
+	monitorsMu.Lock()
+	monitors = make(map[string]*SessionMonitor)
+	monitorsMu.Unlock()
+
+	ResetSessionMonitor("quoted")
+	SetSessionPrompt("quoted", "fix the login bug")
+
+	if got := completionHeader(&Config{}, "quoted"); got != "✅ quoted\n\n" {
+		t.Errorf("completionHeader without quoting = %q", got)
+	}
+
+	config := &Config{QuotePromptInCompletion: true}
+	if got := completionHeader(config, "quoted"); got != "✅ quoted\n\n❓ fix the login bug\n\n" {
+		t.Errorf("completionHeader with quoting = %q", got)
+	}
+
+	SetSessionPrompt("quoted", strings.Repeat("x", maxQuotedPromptLen+50))
+	if got := completionHeader(config, "quoted"); !strings.HasSuffix(got, "...\n\n") {
+		t.Errorf("long prompt should be truncated, got %q", got)
+	}
+
+	if got := completionHeader(config, "unknown"); got != "✅ unknown\n\n" {
+		t.Errorf("completionHeader for unmonitored session = %q", got)
+	}
+}
+
+func TestRecentCachedBlocks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	sessName := "test-recent"
+
+	if blocks := recentCachedBlocks(sessName, 3); len(blocks) != 0 {
+		t.Errorf("empty cache returned %d blocks", len(blocks))
+	}
+
+	saveBlockCache(sessName, &BlockCache{Blocks: []CachedBlock{
+		{Text: "one", MsgID: 1},
+		{Text: "two", MsgID: 2},
+		{Text: "  ", MsgID: 3},
+		{Text: "four", MsgID: -1},
+	}})
+
+	blocks := recentCachedBlocks(sessName, 3)
+	if len(blocks) != 2 || blocks[0] != "two" || blocks[1] != "four" {
+		t.Errorf("recentCachedBlocks(3) = %q, want [two four]", blocks)
+	}
+	if blocks := recentCachedBlocks(sessName, 10); len(blocks) != 3 {
+		t.Errorf("recentCachedBlocks(10) returned %d blocks, want 3", len(blocks))
+	}
+}
+
+func TestCachedBlockForMessage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	sessName := "test-reply"
+	saveBlockCache(sessName, &BlockCache{Blocks: []CachedBlock{
+		{Text: "first", MsgID: 10},
+		{Text: "second", MsgID: 11},
+		{Text: "third", MsgID: 11},
+		{Text: "old", MsgID: -1},
+	}})
+
+	if got := cachedBlockForMessage(sessName, 10); got != "first" {
+		t.Errorf("message 10 = %q, want first", got)
+	}
+	if got := cachedBlockForMessage(sessName, 11); got != "second\n\nthird" {
+		t.Errorf("coalesced message 11 = %q", got)
+	}
+	if got := cachedBlockForMessage(sessName, -1); got != "" {
+		t.Errorf("blocks shown before a restart have no message, got %q", got)
+	}
+
+	if got := withReplyContext("", "hi"); got != "hi" {
+		t.Errorf("without a block the message should pass through, got %q", got)
+	}
+	want := "Regarding this output:\n\"\"\"\nfirst\n\"\"\"\n\nUser says: why?"
+	if got := withReplyContext("first", "why?"); got != want {
+		t.Errorf("withReplyContext = %q", got)
+	}
+}
+
+func TestAverageTurnDuration(t *testing.T) {
+	turnDurationsMu.Lock()
+	delete(turnDurations, "turns")
+	turnDurationsMu.Unlock()
+
+	if avg := averageTurnDuration("turns"); avg != 0 {
+		t.Errorf("average with no turns = %v, want 0", avg)
+	}
+
+	for i := 0; i < maxTurnSamples+5; i++ {
+		recordTurnDuration("turns", time.Minute)
+	}
+	recordTurnDuration("turns", 3*time.Minute)
+
+	turnDurationsMu.Lock()
+	kept := len(turnDurations["turns"])
+	turnDurationsMu.Unlock()
+	if kept != maxTurnSamples {
+		t.Errorf("kept %d samples, want %d", kept, maxTurnSamples)
+	}
+	want := (time.Duration(maxTurnSamples-1)*time.Minute + 3*time.Minute) / maxTurnSamples
+	if avg := averageTurnDuration("turns"); avg != want {
+		t.Errorf("average = %v, want %v", avg, want)
+	}
+}
+
+func TestFormatQueueAdvance(t *testing.T) {
+	tests := []struct {
+		remaining int
+		avg       time.Duration
+		expected  string
+	}{
+		{0, 2 * time.Minute, "▶️ Next queued prompt started"},
+		{2, 0, "▶️ Next queued prompt started, 2 still queued"},
+		{2, 3 * time.Minute, "▶️ Next queued prompt started, 2 still queued, last in ~6 min"},
+		{1, 10 * time.Second, "▶️ Next queued prompt started, 1 still queued, last in <1 min"},
+	}
+	for _, tt := range tests {
+		if result := formatQueueAdvance(tt.remaining, tt.avg); result != tt.expected {
+			t.Errorf("formatQueueAdvance(%d, %v) = %q, want %q", tt.remaining, tt.avg, result, tt.expected)
+		}
+	}
+}
+
+func TestFormatQueueWait(t *testing.T) {
+	tests := []struct {
+		ahead    int
+		avg      time.Duration
+		expected string
+	}{
+		{2, 0, "⏳ queued, 2 ahead"},
+		{2, 2 * time.Minute, "⏳ queued, 2 ahead, ~5 min"},
+		{0, 20 * time.Second, "⏳ queued, 0 ahead, <1 min"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := formatQueueWait(tt.ahead, tt.avg); result != tt.expected {
+				t.Errorf("formatQueueWait(%d, %v) = %q, want %q", tt.ahead, tt.avg, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLooksLikeShellPrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		pane     string
+		expected bool
+	}{
+		{"bash prompt", "⏺ Done\n\nuser@host:~/project$ ", true},
+		{"root prompt", "Killed\nroot@host:/# \n\n", true},
+		{"zsh prompt", "user@host project %", true},
+		{"claude input box", "⏺ Done\n────────\n❯ \n────────\n", false},
+		{"claude status bar", "❯ \n────────\n  ⏵⏵ bypass permissions on", false},
+		{"claude working", "⏺ Reading files\n✻ Thinking…", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := looksLikeShellPrompt(tt.pane); result != tt.expected {
+				t.Errorf("looksLikeShellPrompt(%q) = %v, want %v", tt.pane, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPaneRunsShell(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		pane     string
+		expected bool
+	}{
+		{"bash command", "bash", "", true},
+		{"login zsh", "-zsh", "", true},
+		{"claude running", "claude", "user@host:~$ ", false},
+		{"ccc wrapper", "ccc", "", false},
+		{"node", "node", "", false},
+		{"unknown command falls back to pane", "", "user@host:~$ ", true},
+		{"unknown command claude pane", "", "────────\n❯ \n────────", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := paneRunsShell(tt.command, tt.pane); result != tt.expected {
+				t.Errorf("paneRunsShell(%q, %q) = %v, want %v", tt.command, tt.pane, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMessageQueue(t *testing.T) {
+	sess := "test-queue"
+	defer clearPendingMessages(sess)
+
+	if ahead := enqueueMessage(sess, "first"); ahead != 0 {
+		t.Errorf("first message ahead = %d, want 0", ahead)
+	}
+	if ahead := enqueueMessage(sess, "second"); ahead != 1 {
+		t.Errorf("second message ahead = %d, want 1", ahead)
+	}
+	if n := pendingCount(sess); n != 2 {
+		t.Errorf("pendingCount = %d, want 2", n)
+	}
+
+	for _, want := range []string{"first", "second"} {
+		if got, ok := dequeueMessage(sess); !ok || got != want {
+			t.Errorf("dequeueMessage = %q, %v, want %q", got, ok, want)
+		}
+	}
+	if _, ok := dequeueMessage(sess); ok {
+		t.Error("dequeueMessage on empty queue should return false")
+	}
+
+	enqueueMessage(sess, "dropped")
+	clearPendingMessages(sess)
+	if n := pendingCount(sess); n != 0 {
+		t.Errorf("pendingCount after clear = %d, want 0", n)
+	}
+}
+
+func TestBatchText(t *testing.T) {
+	blocks := []CachedBlock{
+		{Text: "● Read(a.go)", MsgID: 10, Hash: "a"},
+		{Text: "● Edit(a.go)", MsgID: 10, Hash: "b"},
+		{Text: "● Edit(a.go)", MsgID: 10, Hash: "b"}, // same block seen twice
+		{Text: "● Done", MsgID: 11, Hash: "c"},
+	}
+	if got, want := batchText(blocks, 10), "● Read(a.go)\n\n● Edit(a.go)"; got != want {
+		t.Errorf("batchText(10) = %q, want %q", got, want)
+	}
+	if got := batchText(blocks, 11); got != "● Done" {
+		t.Errorf("batchText(11) = %q, want the single block", got)
+	}
+}
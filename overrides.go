@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// configEnvPrefix prefixes the environment variables that override config
+// keys: bot_token is overridden by CCC_BOT_TOKEN
+const configEnvPrefix = "CCC_"
+
+// configPathEnv points ccc at another config file, like --config
+const configPathEnv = "CCC_CONFIG"
+
+// configEnvName returns the variable that overrides a config key
+func configEnvName(key string) string {
+	return configEnvPrefix + strings.ToUpper(key)
+}
+
+// configOverride is a config value replaced from the environment, kept so
+// saveConfig writes the file's own value back instead of the override
+type configOverride struct {
+	file    interface{}
+	applied interface{}
+}
+
+// overridableFields maps the config keys that can be overridden to their
+// struct field index. Sessions and fields ccc manages itself are left out.
+func overridableFields() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := strings.Split(f.Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" || key == "sessions" || key == "keychain_secrets" || key == "encrypted_secrets" {
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64:
+		case reflect.Slice:
+			if f.Type.Elem().Kind() != reflect.String {
+				continue
+			}
+		default:
+			continue
+		}
+		fields[key] = i
+	}
+	return fields
+}
+
+// hasConfigOverrides reports whether any CCC_* variable overrides a config key
+func hasConfigOverrides() bool {
+	for key := range overridableFields() {
+		if _, ok := os.LookupEnv(configEnvName(key)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyConfigOverrides sets config keys from their CCC_* variables. Lists
+// are comma-separated. The replaced values are remembered for saveConfig.
+func applyConfigOverrides(config *Config) error {
+	v := reflect.ValueOf(config).Elem()
+	for key, i := range overridableFields() {
+		raw, ok := os.LookupEnv(configEnvName(key))
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+		file := field.Interface()
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(raw)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("%s: invalid boolean %q", configEnvName(key), raw)
+			}
+			field.SetBool(b)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s: invalid number %q", configEnvName(key), raw)
+			}
+			field.SetInt(n)
+		case reflect.Slice:
+			var list []string
+			for _, item := range strings.Split(raw, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			field.Set(reflect.ValueOf(list))
+		}
+		if config.overrides == nil {
+			config.overrides = make(map[string]configOverride)
+		}
+		config.overrides[key] = configOverride{file: file, applied: field.Interface()}
+	}
+	return nil
+}
+
+// withoutOverrides restores the file's values of keys that still hold their
+// environment override, so saving never persists CCC_* variables. Keys
+// changed since loading (e.g. by `ccc config`) keep the new value.
+func withoutOverrides(settings *Config) {
+	v := reflect.ValueOf(settings).Elem()
+	fields := overridableFields()
+	for key, o := range settings.overrides {
+		field := v.Field(fields[key])
+		if reflect.DeepEqual(field.Interface(), o.applied) {
+			field.Set(reflect.ValueOf(o.file))
+		}
+	}
+}
+
+// parseGlobalFlags consumes the flags before the subcommand: --config <path>,
+// --profile <name> and --<key> <value> for any config key (--group-id, --projects-dir...).
+// They are exported as CCC_* variables, so they win over the environment
+// and reach the hooks and tmux sessions ccc starts.
+func parseGlobalFlags(args []string) ([]string, error) {
+	fields := overridableFields()
+	for len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(args[0], "--"), "=")
+		key := strings.ReplaceAll(name, "-", "_")
+		_, isKey := fields[key]
+		if name != "config" && name != "profile" && !isKey {
+			break // a subcommand flag such as --help
+		}
+		args = args[1:]
+		if !hasValue {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("--%s needs a value", name)
+			}
+			value, args = args[0], args[1:]
+		}
+		switch name {
+		case "config":
+			os.Setenv(configPathEnv, value)
+		case "profile":
+			os.Setenv(profileEnv, value)
+		default:
+			os.Setenv(configEnvName(key), value)
+		}
+	}
+	return args, nil
+}
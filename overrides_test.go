@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigEnvOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("CCC_BOT_TOKEN", "env-token")
+	t.Setenv("CCC_GROUP_ID", "-100123")
+	t.Setenv("CCC_AWAY", "true")
+	t.Setenv("CCC_DESTRUCTIVE_PATTERNS", "rm -rf, shutdown")
+
+	// No config file: the environment is enough
+	config, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig without a file: %v", err)
+	}
+	if config.BotToken != "env-token" || config.GroupID != -100123 || !config.Away {
+		t.Errorf("config = %+v, want values from CCC_* variables", config)
+	}
+	if !reflect.DeepEqual(config.DestructivePatterns, []string{"rm -rf", "shutdown"}) {
+		t.Errorf("DestructivePatterns = %q", config.DestructivePatterns)
+	}
+
+	// Saving keeps overrides out of the file but writes real changes
+	path := getConfigPath()
+	os.WriteFile(path, []byte(`{"bot_token": "file-token", "chat_id": 5}`), 0600)
+	config, err = loadConfig()
+	if err != nil || config.BotToken != "env-token" || config.ChatID != 5 {
+		t.Fatalf("loadConfig = %+v, %v; want the env token over the file's", config, err)
+	}
+	config.ProjectsDir = "/srv/projects"
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "env-token") || !strings.Contains(string(data), "file-token") || !strings.Contains(string(data), "/srv/projects") {
+		t.Errorf("saved config leaked overrides or lost changes:\n%s", data)
+	}
+
+	t.Setenv("CCC_GROUP_ID", "not-a-number")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "CCC_GROUP_ID") {
+		t.Errorf("loadConfig with a bad CCC_GROUP_ID = %v, want an error naming it", err)
+	}
+}
+
+func TestParseGlobalFlags(t *testing.T) {
+	t.Setenv("CCC_CONFIG", "")
+	t.Setenv("CCC_GROUP_ID", "1")
+	t.Setenv("CCC_PROJECTS_DIR", "")
+
+	args, err := parseGlobalFlags([]string{"--config", "/tmp/ccc.json", "--group-id=-100999", "--projects-dir", "/p", "ls", "--json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(args, []string{"ls", "--json"}) {
+		t.Errorf("remaining args = %q, want the subcommand and its flags", args)
+	}
+	if os.Getenv("CCC_CONFIG") != "/tmp/ccc.json" || os.Getenv("CCC_GROUP_ID") != "-100999" || os.Getenv("CCC_PROJECTS_DIR") != "/p" {
+		t.Error("flags were not exported as CCC_* variables (flags must win over the environment)")
+	}
+	if getConfigPath() != "/tmp/ccc.json" {
+		t.Errorf("getConfigPath = %q, want the --config path", getConfigPath())
+	}
+
+	args, _ = parseGlobalFlags([]string{"--help"})
+	if !reflect.DeepEqual(args, []string{"--help"}) {
+		t.Errorf("--help was consumed: %q", args)
+	}
+	if _, err := parseGlobalFlags([]string{"--bot-token"}); err == nil {
+		t.Error("a flag without a value should fail")
+	}
+}
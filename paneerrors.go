@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// Kinds of failure shown in a pane
+const (
+	paneErrorUsageLimit = "usage_limit"
+	paneErrorAuth       = "auth"
+	paneErrorAPI        = "api"
+	paneErrorCrash      = "crash"
+)
+
+// paneError is a failure Claude reports in its pane, with what to do about it
+type paneError struct {
+	Kind    string
+	Title   string
+	Action  string
+	Pattern *regexp.Regexp
+}
+
+// paneErrorPrefix allows the ⎿ or bullet Claude puts before a message
+const paneErrorPrefix = `^(?:[⎿●⏺]\s*)?`
+
+// paneErrors are the failures looked for, first match wins. Patterns are
+// anchored at the start of a line so answers that merely mention an error
+// don't match.
+var paneErrors = []paneError{
+	{paneErrorUsageLimit, "Claude usage limit reached", "Wait for the limit to reset, then /continue",
+		regexp.MustCompile(paneErrorPrefix + `(?i)(?:Claude (?:AI )?usage limit reached|5-hour limit reached|You've hit your (?:usage )?limit)`)},
+	{paneErrorAuth, "Claude is not logged in", "/auth to log in again",
+		regexp.MustCompile(paneErrorPrefix + `(?i)(?:OAuth token (?:has )?expired|Invalid API key|Please run /login|OAuth token revoked|API Error: 401)`)},
+	{paneErrorAPI, "Claude API error", "/continue to retry the turn",
+		regexp.MustCompile(paneErrorPrefix + `(?i)(?:API Error|Request timed out|Connection error|overloaded_error)`)},
+}
+
+// paneErrorOfKind returns the failure of a kind from paneErrors
+func paneErrorOfKind(kind string) *paneError {
+	for i := range paneErrors {
+		if paneErrors[i].Kind == kind {
+			return &paneErrors[i]
+		}
+	}
+	return &crashedPaneError
+}
+
+// crashedPaneError is the alert for Claude exiting to a shell
+var crashedPaneError = paneError{Kind: paneErrorCrash, Title: "Claude exited", Action: "/restart_claude to start it again, or /continue to restart the session"}
+
+// detectPaneError finds a failure in the current turn of the visible pane,
+// returning it and the line that showed it. The turn ends at the last prompt
+// the user sent, so a failure of an earlier turn is not reported again.
+func detectPaneError(pane string) (*paneError, string) {
+	lines, _ := normalizePane(strings.Split(pane, "\n"), 0)
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if content, ok := promptContent(line); ok {
+			if content != "" {
+				break
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		for j := range paneErrors {
+			if paneErrors[j].Pattern.MatchString(line) {
+				return &paneErrors[j], line
+			}
+		}
+	}
+	return nil, ""
+}
+
+// formatPaneAlert renders the alert for a failure in a session
+func formatPaneAlert(sessName string, perr *paneError, detail string) string {
+	msg := fmt.Sprintf("🚨 %s in session %s", perr.Title, sessName)
+	if detail != "" {
+		msg += "\n\n" + truncate(detail, 300)
+	}
+	return msg + "\n\n→ " + perr.Action
+}
+
+// sendPaneAlert posts an alert to the session's topic and the private chat
+func sendPaneAlert(config *Config, sessName string, info *SessionInfo, perr *paneError, detail string) {
+	msg := formatPaneAlert(sessName, perr, detail)
+	hookLog("monitor: session=%s alert: %s: %s", sessName, perr.Title, detail)
+	msgr := getMessenger(config)
+	msgr.Send(sessionGroup(config, info), info.TopicID, msg)
+	if config.ChatID != 0 && config.ChatID != config.GroupID {
+		msgr.Send(config.ChatID, 0, msg)
+	}
+}
+
+// checkPaneError looks for a failure in a session's pane and alerts once per
+// failure shown. It reports whether the pane shows one.
+func checkPaneError(config *Config, sessName string, info *SessionInfo, mon *SessionMonitor, tmuxName string) bool {
+	out, err := exec.Command(tmuxPath, "capture-pane", "-t", tmuxName, "-p").Output()
+	if err != nil {
+		return false
+	}
+	perr, line := detectPaneError(string(out))
+
+	monitorsMu.Lock()
+	title := ""
+	if perr != nil {
+		title = perr.Title
+	}
+	isNew := perr != nil && (title != mon.Alert || line != mon.AlertLine)
+	mon.Alert, mon.AlertLine = title, line
+	monitorsMu.Unlock()
+
+	if isNew {
+		sendPaneAlert(config, sessName, info, perr, line)
+		if perr.Kind == paneErrorUsageLimit {
+			noteUsageLimit(config, sessName, info, line)
+		}
+	}
+	return perr != nil
+}
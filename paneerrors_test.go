@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectPaneError(t *testing.T) {
+	tests := []struct {
+		name, pane string
+		title      string
+	}{
+		{"usage limit", "❯ fix the tests\n\n● Claude usage limit reached. Your limit will reset at 3pm.\n\n────\n❯ \n────", "Claude usage limit reached"},
+		{"expired login", "❯ hi\n  ⎿  API Error: 401 {\"type\":\"error\",\"error\":{\"type\":\"authentication_error\",\"message\":\"OAuth token has expired.\"}}\n────\n❯ \n────", "Claude is not logged in"},
+		{"overloaded", "❯ hi\n\x1b[31m  ⎿  API Error: 529 {\"type\":\"overloaded_error\"}\x1b[0m\n────\n❯ \n────", "Claude API error"},
+		{"1.x prompt", "> hi\n⏺ API Error: Request timed out.\n╭────╮\n│ >  │\n╰────╯", "Claude API error"},
+		{"answer mentioning an error", "❯ why does it fail?\n\n● The handler returns API Error: 500 when the body is empty.\n────\n❯ \n────", ""},
+		{"error of an earlier turn", "❯ hi\n  ⎿  API Error: 529\n❯ try again\n\n● Done.\n────\n❯ \n────", ""},
+		{"typed by the user", "❯ Please run /login\n\n● Sure.\n────\n❯ \n────", ""},
+	}
+	for _, tt := range tests {
+		perr, line := detectPaneError(tt.pane)
+		got := ""
+		if perr != nil {
+			got = perr.Title
+		}
+		if got != tt.title {
+			t.Errorf("%s: detected %q (%q), want %q", tt.name, got, line, tt.title)
+		}
+	}
+}
+
+func TestFormatPaneAlert(t *testing.T) {
+	got := formatPaneAlert("api", &paneErrors[0], "Claude usage limit reached. Your limit will reset at 3pm.")
+	if !strings.HasPrefix(got, "🚨 Claude usage limit reached in session api\n\nClaude usage limit") || !strings.HasSuffix(got, "→ Wait for the limit to reset, then /continue") {
+		t.Errorf("alert = %q", got)
+	}
+	if got := formatPaneAlert("api", &crashedPaneError, ""); strings.Count(got, "\n\n") != 1 {
+		t.Errorf("an alert without detail should have no empty detail section: %q", got)
+	}
+}
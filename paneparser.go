@@ -0,0 +1,336 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// The block parser works on pane lines normalized by normalizePane: escape
+// sequences removed, lines the terminal wrapped joined again, and the
+// prompt and input box of every supported Claude Code version rewritten to
+// the current ones, ❯ and ────. Golden captures for each version are in
+// testdata/panes.
+const (
+	panePrompt = "❯"
+	paneRule   = "───"
+)
+
+var (
+	// ansiEscape matches CSI sequences (colors, cursor moves), OSC sequences
+	// (titles, hyperlinks) and two-character escapes
+	ansiEscape = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+	// boxTop and boxBottom are the borders of a 1.x input box
+	boxTop    = regexp.MustCompile(`^╭─+╮$`)
+	boxBottom = regexp.MustCompile(`^╰─+╯$`)
+	// boxedPrompt is the input line of a 1.x input box: "│ > text   │"
+	boxedPrompt = regexp.MustCompile(`^│\s*>(?:\s+(.*?))?\s*│$`)
+	// legacyPrompt is a prompt 1.x left in the scrollback: "> text" in the
+	// first column. Quotes in Claude's answers are indented, so don't match.
+	legacyPrompt = regexp.MustCompile(`^>(?:\s+(.*))?$`)
+)
+
+// normalizePane prepares captured pane lines for the block parser. width is
+// the pane's width, 0 if unknown. The second result maps each returned line
+// to the captured line it starts at.
+func normalizePane(captured []string, width int) ([]string, []int) {
+	lines := make([]string, len(captured))
+	for i, line := range captured {
+		lines[i] = stripANSI(line)
+	}
+	lines, origin := reflowLines(lines, width)
+	for i, line := range lines {
+		lines[i] = normalizePaneLine(line)
+	}
+	// A 1.x input box: its borders are rules, like the ones around the 2.x
+	// input line. Other boxes (diffs) are part of the output.
+	for i, line := range lines {
+		if !strings.HasPrefix(line, panePrompt) || strings.HasPrefix(captured[origin[i]], panePrompt) {
+			continue
+		}
+		if i > 0 && boxTop.MatchString(strings.TrimSpace(lines[i-1])) {
+			lines[i-1] = paneRule + "─"
+		}
+		if i+1 < len(lines) && boxBottom.MatchString(strings.TrimSpace(lines[i+1])) {
+			lines[i+1] = paneRule + "─"
+		}
+	}
+	return lines, origin
+}
+
+// stripANSI removes terminal escape sequences from s
+func stripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// normalizePaneLine rewrites a 1.x prompt to ❯, leaving other lines as they are
+func normalizePaneLine(line string) string {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case boxedPrompt.MatchString(trimmed):
+		return promptLine(boxedPrompt.FindStringSubmatch(trimmed)[1])
+	case legacyPrompt.MatchString(line):
+		return promptLine(legacyPrompt.FindStringSubmatch(line)[1])
+	}
+	return line
+}
+
+func promptLine(content string) string {
+	if content = strings.TrimSpace(content); content == "" {
+		return panePrompt
+	}
+	return panePrompt + " " + content
+}
+
+// promptContent returns the text typed at a ❯ prompt line
+func promptContent(trimmed string) (string, bool) {
+	if !strings.HasPrefix(trimmed, panePrompt) {
+		return "", false
+	}
+	content := strings.TrimPrefix(trimmed, panePrompt)
+	// Claude Code puts a non-breaking space (U+00A0) after the ❯
+	return strings.TrimSpace(strings.ReplaceAll(content, "\u00a0", "")), true
+}
+
+// reflowLines joins lines that fill the pane's width with the line below,
+// which continues them: tmux wraps long lines without marking it in a
+// capture. A continuation that is indented was wrapped by Claude's renderer
+// at a word, so the words are joined with a space. Borders span the width on
+// their own and are never joined, and a prompt never continues a line. The second result maps each
+// returned line to the first line it was built from.
+func reflowLines(lines []string, width int) ([]string, []int) {
+	var out []string
+	var origin []int
+	for i := 0; i < len(lines); i++ {
+		line, start := lines[i], i
+		for width > 0 && displayWidth(line) >= width && i+1 < len(lines) && wrappable(line) && wrappable(lines[i+1]) && !strings.HasPrefix(strings.TrimSpace(lines[i+1]), panePrompt) {
+			i++
+			next := lines[i]
+			if trimmed := strings.TrimLeft(next, " "); trimmed != next {
+				next = " " + trimmed
+			}
+			line += next
+			if displayWidth(next) < width {
+				break
+			}
+		}
+		out = append(out, line)
+		origin = append(origin, start)
+	}
+	return out, origin
+}
+
+// wrappable reports whether a line can be one half of a wrapped line: not
+// blank, a border or a box line
+func wrappable(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		return false
+	}
+	for _, prefix := range []string{"─", "━", "│", "╭", "╰", "⏵⏵"} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// displayWidth is how many terminal columns s takes: East Asian wide
+// characters and most emoji take two, combining marks and variation
+// selectors none
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		switch {
+		case r < 0x20 || r == utf8.RuneError:
+		case r >= 0x0300 && r <= 0x036f, r >= 0x200b && r <= 0x200f, r >= 0xfe00 && r <= 0xfe0f:
+		case r >= 0x1100 && r <= 0x115f, r >= 0x2e80 && r <= 0xa4cf, r >= 0xac00 && r <= 0xd7a3,
+			r >= 0xf900 && r <= 0xfaff, r >= 0xfe30 && r <= 0xfe4f, r >= 0xff00 && r <= 0xff60,
+			r >= 0xffe0 && r <= 0xffe6, r >= 0x1f300 && r <= 0x1f64f, r >= 0x1f900 && r <= 0x1f9ff,
+			r >= 0x20000 && r <= 0x3fffd:
+			w += 2
+		default:
+			w++
+		}
+	}
+	return w
+}
+
+// lastTurnBlocks returns the blocks after the last prompt that has any, and
+// that prompt's line, or -1 if there is none
+func lastTurnBlocks(lines []string) ([]string, int) {
+	// Collect all ❯ prompt positions and ──── input box positions
+	var prompts []int    // indices of ❯ lines with content
+	var inputBoxes []int // indices of ──── lines
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, paneRule) {
+			inputBoxes = append(inputBoxes, i)
+		} else if content, ok := promptContent(trimmed); ok {
+			// Skip ❯ prompts inside the input box (between two ──── lines)
+			insideInputBox := false
+			for _, ib := range inputBoxes {
+				if ib == i-1 {
+					insideInputBox = true
+					break
+				}
+			}
+			if content != "" && !insideInputBox {
+				prompts = append(prompts, i)
+			}
+		}
+	}
+
+	if len(prompts) == 0 {
+		return nil, -1
+	}
+
+	// Try each prompt from most recent to oldest, return first one with blocks
+	hookLog("parser: %d prompts, %d inputBoxes, %d total lines", len(prompts), len(inputBoxes), len(lines))
+	for p := len(prompts) - 1; p >= 0; p-- {
+		promptIdx := prompts[p]
+
+		// Find the next user prompt after this one (or end of capture)
+		// We don't stop at ─── lines because blocks can appear between
+		// input box separators (e.g. after a diff block with ─── borders)
+		endIdx := len(lines)
+		for pp := p + 1; pp < len(prompts); pp++ {
+			endIdx = prompts[pp]
+			break
+		}
+
+		hookLog("parser: trying prompt %d at line %d (end %d): %s", p, promptIdx, endIdx, truncate(strings.TrimSpace(lines[promptIdx]), 40))
+		blocks := extractBlocks(lines, promptIdx+1, endIdx)
+		hookLog("parser: found %d blocks", len(blocks))
+		if len(blocks) > 0 {
+			return blocks, promptIdx
+		}
+	}
+
+	return nil, -1
+}
+
+// extractBlocks extracts ● bullet blocks from lines[start:end]
+// Skips status lines (spinners) but continues parsing - they appear during work, not after
+func extractBlocks(lines []string, start, end int) []string {
+	var blocks []string
+	var currentBlock strings.Builder
+	inBlock := false
+
+	for i := start; i < end; i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		// Stop at input box (the final ─── before the empty prompt)
+		if strings.HasPrefix(trimmed, paneRule) {
+			// Check if this is the final input box (followed by empty ❯)
+			// by looking at the next few lines
+			isFinalInputBox := false
+			for j := i + 1; j < end && j < i+4; j++ {
+				nextTrimmed := strings.TrimSpace(lines[j])
+				if nextTrimmed == "" {
+					continue
+				}
+				if strings.HasPrefix(nextTrimmed, panePrompt) {
+					isFinalInputBox = true
+				}
+				break
+			}
+			if isFinalInputBox {
+				break
+			}
+			// Not the final input box - close current block but continue looking for more
+			if inBlock && currentBlock.Len() > 0 {
+				blocks = append(blocks, strings.TrimSpace(currentBlock.String()))
+				currentBlock.Reset()
+				inBlock = false
+			}
+			continue
+		}
+
+		// Skip status indicators (spinners) - they appear during work
+		// Don't break, just skip - there may be more content after
+		if isStatusLine(trimmed) {
+			continue
+		}
+
+		// Skip bottom status line and ❯ prompts
+		if strings.HasPrefix(trimmed, "⏵⏵") || strings.HasPrefix(trimmed, panePrompt) {
+			continue
+		}
+
+		// Indented bullets are nested output (a subagent's tool calls under
+		// its Task block), not blocks of their own
+		nested := inBlock && strings.HasPrefix(line, "  ")
+		if isBulletLine(trimmed) && !nested {
+			if inBlock && currentBlock.Len() > 0 {
+				blocks = append(blocks, strings.TrimSpace(currentBlock.String()))
+			}
+			currentBlock.Reset()
+			blockText := removeBulletPrefix(trimmed)
+			currentBlock.WriteString(blockText)
+			inBlock = true
+		} else if inBlock {
+			if trimmed == "" {
+				currentBlock.WriteString("\n")
+			} else {
+				currentBlock.WriteString("\n")
+				currentBlock.WriteString(trimmed)
+			}
+		}
+	}
+
+	if inBlock && currentBlock.Len() > 0 {
+		blocks = append(blocks, strings.TrimSpace(currentBlock.String()))
+	}
+
+	return blocks
+}
+
+func isBulletLine(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "⏺") ||
+		strings.HasPrefix(trimmed, "● ") ||
+		strings.HasPrefix(trimmed, "✻ ")
+}
+
+// isStatusLine checks for transient status indicators that should stop block capture
+func isStatusLine(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "✱") || // Hashing, Thinking
+		strings.HasPrefix(trimmed, "✢") || // Symbioting, Computing
+		strings.HasPrefix(trimmed, "✽") || // Other status
+		strings.HasPrefix(trimmed, "✻") || // Sautéed, etc
+		strings.HasPrefix(trimmed, "✶") || // Spinner frames of older versions
+		strings.HasPrefix(trimmed, "✳") ||
+		strings.HasPrefix(trimmed, "+") || // Progress indicator
+		strings.HasPrefix(trimmed, "*") // Alternative status
+}
+
+// isStatusBlock checks if a block content looks like a transient status message
+func isStatusBlock(text string) bool {
+	lower := strings.ToLower(text)
+	// Skip short blocks that are just status words
+	if len(text) < 50 {
+		statusWords := []string{"thinking", "transfiguring", "spinning", "sautéed", "sauteed",
+			"hashing", "computing", "processing", "loading", "churned", "working", "concocting"}
+		for _, word := range statusWords {
+			if strings.Contains(lower, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func removeBulletPrefix(s string) string {
+	// Order matters: longer prefixes first to match correctly
+	for _, prefix := range []string{"⏺  ", "⏺ ", "● ", "✻ "} {
+		if strings.HasPrefix(s, prefix) {
+			return strings.TrimPrefix(s, prefix)
+		}
+	}
+	return s
+}
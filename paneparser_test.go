@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata/panes")
+
+// TestPaneGolden parses the pane captures in testdata/panes and compares the
+// blocks of the last turn with the .golden file next to each
+func TestPaneGolden(t *testing.T) {
+	captures := []struct {
+		file  string
+		width int
+	}{
+		{"claude-2.x.txt", 80},
+		{"claude-1.x.txt", 53},
+		{"ansi-light-theme.txt", 40},
+		{"wrapped.txt", 40},
+	}
+	for _, c := range captures {
+		t.Run(c.file, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", "panes", c.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines, _ := normalizePane(strings.Split(string(raw), "\n"), c.width)
+			blocks, _ := lastTurnBlocks(lines)
+			var got strings.Builder
+			for i, b := range blocks {
+				fmt.Fprintf(&got, "--- block %d ---\n%s\n", i+1, b)
+			}
+
+			golden := filepath.Join("testdata", "panes", strings.TrimSuffix(c.file, ".txt")+".golden")
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got.String()), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("blocks differ from %s:\n%s", golden, got.String())
+			}
+		})
+	}
+}
+
+func TestNormalizePaneLine(t *testing.T) {
+	tests := []struct {
+		line, want string
+	}{
+		{"│ > fix the tests        │", "❯ fix the tests"},
+		{"│ >                       │", "❯"},
+		{"> rename main.go", "❯ rename main.go"},
+		{"  > a quote in an answer", "  > a quote in an answer"},
+		{"● Done.", "● Done."},
+	}
+	for _, tt := range tests {
+		if got := normalizePaneLine(tt.line); got != tt.want {
+			t.Errorf("normalizePaneLine(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestReflowLines(t *testing.T) {
+	lines := []string{
+		"● one two three f",
+		"our five",
+		"● wrapped at a wo",
+		"  rd boundary",
+		"─────────────────",
+		"❯ ",
+	}
+	got, origin := reflowLines(lines, 17)
+	want := []string{"● one two three four five", "● wrapped at a wo rd boundary", lines[4], lines[5]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reflowLines = %q, want %q", got, want)
+	}
+	if !reflect.DeepEqual(origin, []int{0, 2, 4, 5}) {
+		t.Errorf("origin = %v", origin)
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := map[string]int{"abc": 3, "要約": 4, "✔\ufe0f": 1, "e\u0301": 1}
+	for s, want := range tests {
+		if got := displayWidth(s); got != want {
+			t.Errorf("displayWidth(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// paneStreamDebounce is how long output may keep arriving before the pane is
+// parsed, so a burst of redraws costs one capture
+const paneStreamDebounce = 500 * time.Millisecond
+
+// Claude's TUI redraws parts of the screen rather than appending lines, so
+// the stream itself can't be parsed as text. It tells the monitor when a
+// pane changed: the pane is captured right after output (instead of up to
+// 3 seconds later) and not at all while an idle session prints nothing.
+
+// paneStream is one session's tmux pipe-pane feeding a FIFO the listener reads
+type paneStream struct {
+	fifo  string
+	file  *os.File
+	dirty bool // output arrived since the pane was last captured
+	timer *time.Timer
+}
+
+// paneStreams holds the listener's pane streams, keyed by session name
+type paneStreams struct {
+	mu       sync.Mutex
+	streams  map[string]*paneStream
+	activity chan string // session names with fresh output, debounced
+}
+
+func newPaneStreams() *paneStreams {
+	return &paneStreams{streams: make(map[string]*paneStream), activity: make(chan string, 64)}
+}
+
+// paneFifoPath is the FIFO a session's pane output is piped into
+func paneFifoPath(tmuxName string) string {
+	return filepath.Join(stateFile("-panes"), tmuxName+".fifo")
+}
+
+// start pipes a session's pane into a FIFO, unless it already is. A failure
+// leaves the session to plain polling.
+func (ps *paneStreams) start(sessName, tmuxName string) error {
+	ps.mu.Lock()
+	_, running := ps.streams[sessName]
+	ps.mu.Unlock()
+	if running {
+		return nil
+	}
+
+	fifo := paneFifoPath(tmuxName)
+	if err := os.MkdirAll(filepath.Dir(fifo), 0700); err != nil {
+		return err
+	}
+	os.Remove(fifo)
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		return fmt.Errorf("mkfifo: %w", err)
+	}
+	// Opened read-write so opening doesn't wait for a writer and the reader
+	// sees no EOF when the writer goes away
+	file, err := os.OpenFile(fifo, os.O_RDWR, 0)
+	if err != nil {
+		os.Remove(fifo)
+		return err
+	}
+	// Without -o this replaces a pipe left by a previous listener
+	if err := exec.Command(tmuxPath, "pipe-pane", "-t", tmuxName, "cat >> '"+fifo+"'").Run(); err != nil {
+		file.Close()
+		os.Remove(fifo)
+		return fmt.Errorf("pipe-pane: %w", err)
+	}
+
+	s := &paneStream{fifo: fifo, file: file}
+	ps.mu.Lock()
+	ps.streams[sessName] = s
+	ps.mu.Unlock()
+	go ps.read(sessName, s)
+	hookLog("panestream: session=%s streaming to %s", sessName, fifo)
+	return nil
+}
+
+// read drains a FIFO, marking the session dirty and signalling activity once
+// per burst of output
+func (ps *paneStreams) read(sessName string, s *paneStream) {
+	buf := make([]byte, 32*1024)
+	for {
+		if _, err := s.file.Read(buf); err != nil {
+			return
+		}
+		ps.mu.Lock()
+		s.dirty = true
+		if s.timer == nil {
+			s.timer = time.AfterFunc(paneStreamDebounce, func() {
+				ps.mu.Lock()
+				s.timer = nil
+				ps.mu.Unlock()
+				ps.activity <- sessName
+			})
+		}
+		ps.mu.Unlock()
+	}
+}
+
+// stop ends a session's stream
+func (ps *paneStreams) stop(sessName string) {
+	ps.mu.Lock()
+	s, ok := ps.streams[sessName]
+	delete(ps.streams, sessName)
+	ps.mu.Unlock()
+	if !ok {
+		return
+	}
+	exec.Command(tmuxPath, "pipe-pane", "-t", sessionName(sessName)).Run()
+	s.file.Close()
+	os.Remove(s.fifo)
+}
+
+// sync streams every running session and stops streams of sessions that
+// are gone
+func (ps *paneStreams) sync(config *Config) {
+	ps.mu.Lock()
+	var names []string
+	for name := range ps.streams {
+		names = append(names, name)
+	}
+	ps.mu.Unlock()
+	for _, name := range names {
+		if config.Sessions[name] == nil || !tmuxSessionExists(sessionName(name)) {
+			ps.stop(name)
+		}
+	}
+
+	for name, info := range config.Sessions {
+		if info == nil || info.TopicID == 0 {
+			continue
+		}
+		tmuxName := sessionName(name)
+		if !tmuxSessionExists(tmuxName) {
+			continue
+		}
+		if err := ps.start(name, tmuxName); err != nil {
+			hookLog("panestream: session=%s falling back to polling: %v", name, err)
+		}
+	}
+}
+
+// changed reports whether a session's pane may have changed since it was
+// last captured: it printed, or it isn't streamed
+func (ps *paneStreams) changed(sessName string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	s, ok := ps.streams[sessName]
+	return !ok || s.dirty
+}
+
+// quiet reports whether a session is streamed and printed nothing since its
+// pane was last captured, and clears the dirty mark for the capture to come
+func (ps *paneStreams) quiet(sessName string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	s, ok := ps.streams[sessName]
+	if !ok {
+		return false
+	}
+	quiet := !s.dirty
+	s.dirty = false
+	return quiet
+}
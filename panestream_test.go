@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPaneStreamActivity(t *testing.T) {
+	fifo := filepath.Join(t.TempDir(), "pane.fifo")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	file, err := os.OpenFile(fifo, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	ps := newPaneStreams()
+	s := &paneStream{fifo: fifo, file: file}
+	ps.streams["api"] = s
+	go ps.read("api", s)
+
+	if ps.quiet("web") {
+		t.Error("a session without a stream should never be quiet")
+	}
+	if !ps.quiet("api") {
+		t.Error("a stream without output should be quiet")
+	}
+
+	w, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	for i := 0; i < 5; i++ {
+		w.WriteString("redraw\n")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case name := <-ps.activity:
+		if name != "api" {
+			t.Errorf("activity for %q, want api", name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no activity signalled")
+	}
+	select {
+	case <-ps.activity:
+		t.Error("a burst of output should be signalled once")
+	case <-time.After(2 * paneStreamDebounce):
+	}
+
+	if ps.quiet("api") {
+		t.Error("a stream with output should not be quiet")
+	}
+	if !ps.quiet("api") {
+		t.Error("checking should clear the output mark")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// configDir returns where settings live: $XDG_CONFIG_HOME/ccc, ~/.config/ccc by default
+func configDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "ccc")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "ccc")
+}
+
+// stateDir returns where the session store, lock, socket and logs live:
+// $XDG_STATE_HOME/ccc, ~/.local/state/ccc by default. Unlike /tmp it
+// survives reboots, so deduplication keeps working after a restart.
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "ccc")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "state", "ccc")
+}
+
+// configFile returns the profile's config: config.json, or config-<profile>.json
+func configFile() string {
+	name := "config.json"
+	if p := currentProfile(); p != "" {
+		name = "config-" + p + ".json"
+	}
+	os.MkdirAll(configDir(), 0700)
+	return filepath.Join(configDir(), name)
+}
+
+// stateFile returns a file of the profile in the state dir: ccc<suffix>, or
+// ccc-<profile><suffix>
+func stateFile(suffix string) string {
+	name := "ccc"
+	if p := currentProfile(); p != "" {
+		name += "-" + p
+	}
+	os.MkdirAll(stateDir(), 0700)
+	return filepath.Join(stateDir(), name+suffix)
+}
+
+// legacyFile returns where a profile's file lived before the XDG directories:
+// ~/.ccc<suffix>, or ~/.ccc-<profile><suffix>
+func legacyFile(suffix string) string {
+	home, _ := os.UserHomeDir()
+	name := ".ccc"
+	if p := currentProfile(); p != "" {
+		name += "-" + p
+	}
+	return filepath.Join(home, name+suffix)
+}
+
+// migrateLegacyFile moves a file from its pre-XDG location the first time
+// the new one is looked up. A file already at the new location wins.
+func migrateLegacyFile(legacy, current string) string {
+	if _, err := os.Stat(current); os.IsNotExist(err) {
+		if _, err := os.Stat(legacy); err == nil {
+			if err := os.Rename(legacy, current); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to move %s to %s: %v\n", legacy, current, err)
+				return legacy
+			}
+		}
+	}
+	return current
+}
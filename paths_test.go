@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain keeps tests that set HOME away from the developer's real
+// config and state, wherever XDG variables point
+func TestMain(m *testing.M) {
+	for _, v := range []string{"XDG_CONFIG_HOME", "XDG_STATE_HOME", profileEnv, configPathEnv} {
+		os.Unsetenv(v)
+	}
+	os.Exit(m.Run())
+}
+
+func TestLegacyFilesMigrate(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "cfg"))
+	t.Setenv("XDG_STATE_HOME", "relative/is/ignored")
+	t.Setenv(configPathEnv, "")
+	t.Setenv(profileEnv, "")
+
+	os.WriteFile(filepath.Join(home, ".ccc.json"), []byte(`{"bot_token": "legacy", "chat_id": 1}`), 0600)
+	os.WriteFile(filepath.Join(home, ".ccc-audit.log"), []byte("{}\n"), 0600)
+
+	config, err := loadConfig()
+	if err != nil || config.BotToken != "legacy" {
+		t.Fatalf("loadConfig = %+v, %v; want the legacy config", config, err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "cfg", "ccc", "config.json")); err != nil {
+		t.Errorf("config not moved under $XDG_CONFIG_HOME: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".ccc.json")); !os.IsNotExist(err) {
+		t.Error("legacy config left behind")
+	}
+
+	if got, want := auditLogPath(), filepath.Join(home, ".local", "state", "ccc", "ccc-audit.log"); got != want {
+		t.Errorf("auditLogPath = %s, want %s", got, want)
+	}
+	if data, _ := os.ReadFile(auditLogPath()); string(data) != "{}\n" {
+		t.Error("audit log not migrated")
+	}
+
+	// A file already at the new location wins over a stale legacy one
+	os.WriteFile(filepath.Join(home, ".ccc.json"), []byte(`{"bot_token": "stale"}`), 0600)
+	if config, _ := loadConfig(); config.BotToken != "legacy" {
+		t.Errorf("BotToken = %q, want the migrated config to win", config.BotToken)
+	}
+}
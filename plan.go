@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// planCallbackPrefix marks the buttons under a plan. Callback data is
+// "plan:<approve|edit|reject>:<session>".
+const planCallbackPrefix = "plan:"
+
+// planPromptText is shown by Claude while a plan waits for approval
+const planPromptText = "Would you like to proceed?"
+
+// planModeOn reports whether a session's Claude starts in plan mode
+func planModeOn(info *SessionInfo) bool {
+	return claudeFlagValue(info.ClaudeFlags, "--permission-mode") == "plan"
+}
+
+// handlePlanCommand shows or toggles plan mode: "/plan", "/plan on" or
+// "/plan off". A running tmux session restarts Claude in the new mode,
+// keeping the conversation.
+func handlePlanCommand(sessName string, info *SessionInfo, arg string) string {
+	var mode string
+	switch arg {
+	case "":
+		if planModeOn(info) {
+			return "📝 Plan mode is on: Claude plans and asks before changing anything. /plan off to leave it."
+		}
+		return "📝 Plan mode is off. /plan on makes Claude plan and ask before changing anything."
+	case "on":
+		mode = "plan"
+	case "off":
+	default:
+		return "Usage: /plan [on|off]"
+	}
+	info.ClaudeFlags = withClaudeFlag(info.ClaudeFlags, "--permission-mode", mode)
+	if err := saveSession(sessName, info); err != nil {
+		return "❌ " + err.Error()
+	}
+
+	state := "off"
+	if mode == "plan" {
+		state = "on"
+	}
+	tmuxName := sessionName(sessName)
+	if isHeadless(info) || !tmuxSessionExists(tmuxName) {
+		return fmt.Sprintf("📝 Plan mode %s. Takes effect from the next message.", state)
+	}
+	markClaudeRestarting(sessName)
+	if err := restartClaudeInPane(tmuxName); err != nil {
+		return fmt.Sprintf("📝 Plan mode %s, but restarting Claude failed: %v\n\nUse /continue to restart the session.", state, err)
+	}
+	ResetSessionMonitor(sessName)
+	return fmt.Sprintf("📝 Plan mode %s: Claude restarted, keeping the conversation.", state)
+}
+
+// handlePlanHook forwards a plan Claude wants approved (the ExitPlanMode
+// PreToolUse hook) with Approve / Edit / Reject buttons. It answers nothing,
+// so the approval prompt in the terminal stays in charge.
+func handlePlanHook() error {
+	defer func() {
+		recover()
+	}()
+
+	rawData, err := io.ReadAll(io.LimitReader(os.Stdin, 1024*1024))
+	if err != nil || len(rawData) == 0 {
+		return nil
+	}
+	var hookData HookData
+	if json.Unmarshal(rawData, &hookData) != nil || hookData.ToolInput.Plan == "" {
+		return nil
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return nil
+	}
+	sessName, info := sessionForDir(config, hookData.Cwd)
+	if info == nil || info.TopicID == 0 || !hasSessionChannel(config) {
+		return nil
+	}
+
+	msgr := getMessenger(config)
+	msgr.SendFormatted(sessionGroup(config, info), info.TopicID, "📋 Plan\n\n"+hookData.ToolInput.Plan)
+	// Telegram caps callback data at 64 bytes
+	if len(planCallbackPrefix+"approve:"+sessName) > 64 {
+		msgr.Send(sessionGroup(config, info), info.TopicID, "Answer the plan prompt in the terminal.")
+		return nil
+	}
+	msgr.SendWithKeyboard(sessionGroup(config, info), info.TopicID, "Proceed with this plan?", planButtons(sessName))
+	return nil
+}
+
+// planButtons are the Approve / Edit / Reject buttons for a session's plan
+func planButtons(sessName string) [][]InlineKeyboardButton {
+	var row []InlineKeyboardButton
+	for _, b := range []struct{ text, action string }{
+		{"✅ Approve", "approve"},
+		{"✏️ Edit", "edit"},
+		{"❌ Reject", "reject"},
+	} {
+		row = append(row, InlineKeyboardButton{Text: b.text, CallbackData: planCallbackPrefix + b.action + ":" + sessName})
+	}
+	return [][]InlineKeyboardButton{row}
+}
+
+// planPromptShown reports whether a pane shows the plan approval prompt
+func planPromptShown(lines []string) bool {
+	start := len(lines) - 30
+	if start < 0 {
+		start = 0
+	}
+	for _, line := range lines[start:] {
+		if strings.Contains(line, planPromptText) {
+			return true
+		}
+	}
+	return false
+}
+
+// handlePlanCallback answers Claude's plan approval prompt for a button
+// press and returns the text to show on the message. Approve picks the
+// first option; Edit and Reject dismiss the prompt, leaving Claude in plan
+// mode to take the next message as feedback.
+func handlePlanCallback(data string) (string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(data, planCallbackPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	action, sessName := parts[0], parts[1]
+	var key, label string
+	switch action {
+	case "approve":
+		key, label = "Enter", "✅ Approved: Claude goes ahead"
+	case "edit":
+		key, label = "Escape", "✏️ Send what to change; Claude keeps planning"
+	case "reject":
+		key, label = "Escape", "❌ Rejected"
+	default:
+		return "", false
+	}
+
+	tmuxName := sessionName(sessName)
+	capture, err := capturePaneHistory(tmuxName)
+	if err != nil {
+		return "⌛ The session is no longer running", true
+	}
+	lines, _ := normalizePane(capture.Lines, capture.Width)
+	if !planPromptShown(lines) {
+		return "⌛ The plan is no longer waiting for approval", true
+	}
+	if err := exec.Command(tmuxPath, "send-keys", "-t", tmuxName, key).Run(); err != nil {
+		return "", false
+	}
+	return label, true
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanPromptShown(t *testing.T) {
+	pane := []string{
+		"╭────────────────────────────╮",
+		"│ Ready to code?             │",
+		"│ Here is Claude's plan:     │",
+		"╰────────────────────────────╯",
+		" Would you like to proceed?",
+		" ❯ 1. Yes, and bypass permissions",
+		"   2. Yes, and manually approve edits",
+		"   3. No, keep planning",
+	}
+	if !planPromptShown(pane) {
+		t.Error("the approval prompt was not found")
+	}
+	if planPromptShown([]string{"● Done.", "", "❯ "}) {
+		t.Error("an idle prompt is not a plan approval")
+	}
+}
+
+func TestHandlePlanCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	info := &SessionInfo{Path: t.TempDir(), ClaudeFlags: []string{"--model", "opus"}}
+
+	if got := handlePlanCommand("plan-ccc-test", info, "on"); !strings.Contains(got, "Plan mode on") {
+		t.Errorf("/plan on = %q", got)
+	}
+	if !planModeOn(info) || claudeFlagValue(info.ClaudeFlags, "--model") != "opus" {
+		t.Errorf("flags after /plan on = %q", info.ClaudeFlags)
+	}
+	handlePlanCommand("plan-ccc-test", info, "off")
+	if planModeOn(info) || len(info.ClaudeFlags) != 2 {
+		t.Errorf("flags after /plan off = %q", info.ClaudeFlags)
+	}
+	if got := handlePlanCommand("plan-ccc-test", info, "maybe"); !strings.HasPrefix(got, "Usage") {
+		t.Errorf("/plan maybe = %q, want usage", got)
+	}
+}
+
+func TestPlanButtons(t *testing.T) {
+	row := planButtons("api")[0]
+	if len(row) != 3 || row[0].CallbackData != "plan:approve:api" || row[2].CallbackData != "plan:reject:api" {
+		t.Errorf("planButtons = %+v", row)
+	}
+	if _, ok := handlePlanCallback("plan:later:api"); ok {
+		t.Error("an unknown action should not be handled")
+	}
+}
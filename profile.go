@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// profileEnv selects a named profile. Each profile has its own config file,
+// session store, listener lock, control socket, log and service, so one
+// machine can serve several bots (say a personal and a team one).
+const profileEnv = "CCC_PROFILE"
+
+var profileNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// currentProfile returns the active profile, "" for the default one
+func currentProfile() string {
+	return os.Getenv(profileEnv)
+}
+
+// validateProfile rejects profile names that can't be used in file names
+func validateProfile(name string) error {
+	if name != "" && !profileNameRe.MatchString(name) {
+		return fmt.Errorf("invalid profile %q (use letters, digits, - and _)", name)
+	}
+	return nil
+}
+
+// serviceName returns the service the listener runs as: ccc or ccc-<profile>
+func serviceName() string {
+	if p := currentProfile(); p != "" {
+		return "ccc-" + p
+	}
+	return "ccc"
+}
+
+// tmuxPrefix starts the tmux session names of the profile's sessions, so two
+// profiles can both have a session called "api"
+func tmuxPrefix() string {
+	if p := currentProfile(); p != "" {
+		return "claude-" + p + "-"
+	}
+	return "claude-"
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestProfilePaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv(configPathEnv, "")
+	t.Setenv(profileEnv, "")
+	state := filepath.Join(home, ".local", "state", "ccc")
+
+	if getConfigPath() != filepath.Join(home, ".config", "ccc", "config.json") || getStorePath() != filepath.Join(state, "ccc.db") {
+		t.Errorf("default profile paths = %s, %s", getConfigPath(), getStorePath())
+	}
+	if sessionName("api") != "claude-api" || serviceName() != "ccc" {
+		t.Errorf("default profile names = %s, %s", sessionName("api"), serviceName())
+	}
+
+	if _, err := parseGlobalFlags([]string{"--profile", "work", "listen"}); err != nil {
+		t.Fatal(err)
+	}
+	if getConfigPath() != filepath.Join(home, ".config", "ccc", "config-work.json") || getStorePath() != filepath.Join(state, "ccc-work.db") ||
+		controlSocketPath() != filepath.Join(state, "ccc-work.sock") || stateFile(".lock") != filepath.Join(state, "ccc-work.lock") {
+		t.Errorf("work profile paths = %s, %s, %s", getConfigPath(), getStorePath(), controlSocketPath())
+	}
+	if sessionName("my.api") != "claude-work-my_api" || serviceName() != "ccc-work" {
+		t.Errorf("work profile names = %s, %s", sessionName("my.api"), serviceName())
+	}
+
+	for _, bad := range []string{"../etc", "a b", "x/y"} {
+		if validateProfile(bad) == nil {
+			t.Errorf("validateProfile(%q) should fail", bad)
+		}
+	}
+}
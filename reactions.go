@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reactions that act on a message in a session topic
+const (
+	reactionSelect = "👍" // on a question: pick the first option
+	reactionStop   = "⏹" // stop the session's turn
+	reactionRetry  = "🔁" // send the last prompt again
+)
+
+// telegramAllowedUpdates are the update types the listener asks for.
+// message_reaction is only delivered when requested explicitly.
+const telegramAllowedUpdates = `["message","callback_query","message_reaction","inline_query"]`
+
+// addedReactions returns the emoji in a reaction update that were not there before
+func addedReactions(r *MessageReaction) []string {
+	had := make(map[string]bool)
+	for _, old := range r.OldReaction {
+		had[old.Emoji] = true
+	}
+	var added []string
+	for _, rt := range r.NewReaction {
+		if rt.Type == "emoji" && !had[rt.Emoji] {
+			// Some clients send ⏹ with a variation selector
+			added = append(added, strings.TrimSuffix(rt.Emoji, "\ufe0f"))
+		}
+	}
+	return added
+}
+
+// sessionForMessage finds the session a message in a group belongs to: a
+// recorded question or status message, or a block in a block cache
+func sessionForMessage(config *Config, chatID, messageID int64) (string, reactionMessage, bool) {
+	if sessName, msg, ok := findReactionMessage(messageID); ok && config.Sessions[sessName] != nil && sessionChat(config, sessName) == chatID {
+		return sessName, msg, true
+	}
+	for name := range config.Sessions {
+		if sessionChat(config, name) == chatID && cachedBlockForMessage(name, messageID) != "" {
+			return name, reactionMessage{MessageID: messageID}, true
+		}
+	}
+	return "", reactionMessage{}, false
+}
+
+// handleReaction runs the quick action for a reaction the user put on a
+// message in a session topic
+func handleReaction(config *Config, r *MessageReaction) {
+	if r.User == nil || r.User.ID != config.ChatID || !isSessionGroup(config, r.Chat.ID) {
+		return
+	}
+	added := addedReactions(r)
+	if len(added) == 0 {
+		return
+	}
+	sessName, msg, ok := sessionForMessage(config, r.Chat.ID, int64(r.MessageID))
+	if !ok {
+		return
+	}
+	topicID := config.Sessions[sessName].TopicID
+
+	for _, emoji := range added {
+		switch emoji {
+		case reactionSelect:
+			qc, ok := parseQuestionCallback(msg.Question)
+			if msg.Question == "" || !ok {
+				continue
+			}
+			removeKeyboard(config, r.Chat.ID, r.MessageID)
+			answerQuestion(qc)
+			sendMessage(config, r.Chat.ID, topicID, fmt.Sprintf("✓ Selected option %d", qc.OptionIndex+1))
+		case reactionStop:
+			sendMessage(config, r.Chat.ID, topicID, interruptSession(config, sessName))
+		case reactionRetry:
+			prompt := lastSessionPrompt(sessName)
+			if prompt == "" {
+				sendMessage(config, r.Chat.ID, topicID, "Nothing to retry: no prompt sent since the listener started.")
+				continue
+			}
+			sendMessage(config, r.Chat.ID, topicID, "🔁 Retrying: "+truncate(prompt, 100))
+			forwardToSession(config, getMessenger(config), r.Chat.ID, topicID, sessName, prompt)
+		}
+	}
+}
+
+// lastSessionPrompt returns the last message the user sent to a session
+func lastSessionPrompt(sessName string) string {
+	monitorsMu.Lock()
+	defer monitorsMu.Unlock()
+	if mon, exists := monitors[sessName]; exists {
+		return mon.LastPrompt
+	}
+	return ""
+}
+
+// sendQuestion sends a question with its option buttons. In Telegram the
+// message is recorded so a 👍 reaction can pick the first option.
+func sendQuestion(config *Config, sessName string, topicID int64, msg string, buttons [][]InlineKeyboardButton) {
+	if configuredMessenger(config) != messengerTelegram {
+		getMessenger(config).SendWithKeyboard(sessionChat(config, sessName), topicID, msg, buttons)
+		return
+	}
+	msgID, err := sendMessageWithKeyboardGetID(config, sessionChat(config, sessName), topicID, msg, buttons)
+	if err != nil || msgID == 0 {
+		return
+	}
+	if err := recordReactionMessage(sessName, reactionMessage{MessageID: msgID, Question: buttons[0][0].CallbackData}); err != nil {
+		hookLog("reactions: %s: recording question: %v", sessName, err)
+	}
+}
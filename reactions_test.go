@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddedReactions(t *testing.T) {
+	r := &MessageReaction{
+		OldReaction: []ReactionType{{Type: "emoji", Emoji: "👍"}},
+		NewReaction: []ReactionType{
+			{Type: "emoji", Emoji: "👍"},
+			{Type: "emoji", Emoji: "⏹️"},
+			{Type: "custom_emoji"},
+		},
+	}
+	if got := addedReactions(r); !reflect.DeepEqual(got, []string{reactionStop}) {
+		t.Errorf("addedReactions = %q, want only the new ⏹", got)
+	}
+	if got := addedReactions(&MessageReaction{OldReaction: r.NewReaction}); len(got) != 0 {
+		t.Errorf("removing reactions should trigger nothing, got %q", got)
+	}
+}
+
+func TestSessionForMessage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	config := &Config{Sessions: map[string]*SessionInfo{
+		"api": {TopicID: 10},
+		"web": {TopicID: 20},
+	}}
+
+	for i := int64(1); i <= maxReactionMessages+1; i++ {
+		recordReactionMessage("api", reactionMessage{MessageID: i})
+	}
+	recordReactionMessage("api", reactionMessage{MessageID: 500, Question: "api:0:1:0"})
+	saveBlockCache("web", &BlockCache{Blocks: []CachedBlock{{Text: "done", MsgID: 600}}})
+
+	if name, msg, ok := sessionForMessage(config, 0, 500); !ok || name != "api" || msg.Question != "api:0:1:0" {
+		t.Errorf("question message = %q, %+v, %v", name, msg, ok)
+	}
+	if name, _, ok := sessionForMessage(config, 0, 600); !ok || name != "web" {
+		t.Errorf("block message = %q, %v", name, ok)
+	}
+	if _, _, ok := sessionForMessage(config, 0, 1); ok {
+		t.Error("the oldest recorded messages should have been dropped")
+	}
+	if _, _, ok := sessionForMessage(config, 0, 999); ok {
+		t.Error("an unknown message should belong to no session")
+	}
+}
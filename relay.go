@@ -1,121 +1,377 @@
 package main
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"golang.org/x/crypto/acme/autocert"
 )
 
 const maxTelegramFileSize = 50 * 1024 * 1024 // 50MB
 const defaultRelayURL = "https://ccc-relay.fly.dev"
 
-// handleSendFile sends a file to the current session's Telegram topic
-func handleSendFile(filePath string) error {
+// relaySignatureHeader carries the HMAC of a transfer token on authenticated relay requests
+const relaySignatureHeader = "X-Relay-Signature"
+
+// relayOffsetHeader carries the byte offset a download resumes from: set by the
+// relay on /status and echoed by the sender on /stream
+const relayOffsetHeader = "X-Relay-Offset"
+
+// defaultRelayMaxTransfers caps concurrently registered transfers on a relay server
+const defaultRelayMaxTransfers = 20
+
+// signRelayToken returns the hex HMAC-SHA256 of a transfer token under the shared secret
+func signRelayToken(secret, token string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyRelaySignature reports whether sig is a valid signature of token.
+// Without a secret every request is accepted.
+func verifyRelaySignature(secret, token, sig string) bool {
+	if secret == "" {
+		return true
+	}
+	return hmac.Equal([]byte(signRelayToken(secret, token)), []byte(sig))
+}
+
+// newRelayRequest builds a relay request, signing the token when a secret is configured
+func newRelayRequest(method, url, secret, token string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if secret != "" {
+		req.Header.Set(relaySignatureHeader, signRelayToken(secret, token))
+	}
+	return req, nil
+}
+
+// handleSendFile sends files and directories to the current session's
+// Telegram topic: one file as before, a directory as a zip streamed through
+// the relay, several paths as one relay download page. --encrypt sends
+// everything through the relay encrypted end to end, --store uploads to the
+// configured bucket and posts expiring links.
+func handleSendFile(args []string) error {
 	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("no config found: %w", err)
 	}
 
-	// Get absolute path
-	if !filepath.IsAbs(filePath) {
-		cwd, _ := os.Getwd()
-		filePath = filepath.Join(cwd, filePath)
+	var items []relayItem
+	encrypt, store := false, false
+	for _, arg := range args {
+		switch arg {
+		case "--encrypt":
+			encrypt = true
+			continue
+		case "--store":
+			store = true
+			continue
+		}
+		item, err := newRelayItem(arg)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
 	}
-
-	// Check file exists
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		return fmt.Errorf("file not found: %w", err)
+	if len(items) == 0 {
+		return fmt.Errorf("usage: ccc send [--encrypt|--store] <file|dir>...")
+	}
+	if encrypt && store {
+		return fmt.Errorf("--encrypt and --store can't be combined: stored links are plain downloads")
+	}
+	if encrypt {
+		for i := range items {
+			items[i].Key = newE2EKey()
+		}
 	}
 
 	// Find session from current directory
 	cwd, _ := os.Getwd()
-	var sessionName string
+	sessionName, info := sessionForDir(config, cwd)
 	var topicID int64
-	for name, info := range config.Sessions {
-		if info == nil {
-			continue
-		}
-		if cwd == info.Path || strings.HasPrefix(cwd, info.Path+"/") {
-			sessionName = name
-			topicID = info.TopicID
-			break
-		}
+	if info != nil {
+		topicID = info.TopicID
 	}
 
-	if topicID == 0 || config.GroupID == 0 {
+	if topicID == 0 || sessionGroup(config, info) == 0 {
 		return fmt.Errorf("no session found for current directory")
 	}
+	switch {
+	case store:
+		return sendStoredToTopic(config, sessionName, topicID, items)
+	case len(items) > 1:
+		return sendBundleToTopic(config, sessionName, topicID, items)
+	case items[0].Dir || encrypt:
+		return sendRelayLink(config, sessionName, topicID, items[0])
+	}
+	return sendFileToTopic(config, sessionName, topicID, items[0].Path, items[0].Size)
+}
 
+// sendFileToTopic sends a file to a session topic, directly when Telegram
+// accepts its size and as a streaming relay link otherwise
+func sendFileToTopic(config *Config, sessionName string, topicID int64, filePath string, fileSize int64) error {
 	fileName := filepath.Base(filePath)
-	fileSize := fileInfo.Size()
 
 	// Small file: send directly via Telegram
 	if fileSize < maxTelegramFileSize {
 		fmt.Printf("📤 Sending %s (%d MB) via Telegram...\n", fileName, fileSize/(1024*1024))
-		return sendFile(config, config.GroupID, topicID, filePath, "")
+		return sendFile(config, sessionChat(config, sessionName), topicID, filePath, "")
 	}
 
 	// Large file: use streaming relay
-	relayURL := config.RelayURL
-	if relayURL == "" {
-		relayURL = defaultRelayURL
-	}
+	return sendRelayLink(config, sessionName, topicID, relayItem{Path: filePath, Name: fileName, Size: fileSize})
+}
 
-	fmt.Printf("📤 Preparing %s (%d MB) for streaming relay...\n", fileName, fileSize/(1024*1024))
+// relayURLFor returns the configured relay server
+func relayURLFor(config *Config) string {
+	if config.RelayURL == "" {
+		return defaultRelayURL
+	}
+	return config.RelayURL
+}
 
-	// Generate one-time token
+// newRelayToken returns a one-time transfer token
+func newRelayToken() string {
 	tokenBytes := make([]byte, 16)
 	rand.Read(tokenBytes)
-	token := hex.EncodeToString(tokenBytes)
+	return hex.EncodeToString(tokenBytes)
+}
 
-	// Register with relay
-	regPayload, _ := json.Marshal(map[string]interface{}{
-		"token":    token,
-		"filename": fileName,
-		"size":     fileSize,
-	})
-	regData := string(regPayload)
-	resp, err := http.Post(relayURL+"/register", "application/json", strings.NewReader(regData))
+// relayPost posts a signed JSON payload to a relay endpoint
+func relayPost(relayURL, secret, token, path string, payload interface{}) error {
+	data, _ := json.Marshal(payload)
+	req, err := newRelayRequest("POST", relayURL+path, secret, token, strings.NewReader(string(data)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to register with relay: %w", err)
 	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
 	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("relay rejected transfer (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
 
-	// Send download link to Telegram (include filename in URL for browser compatibility)
-	downloadURL := fmt.Sprintf("%s/d/%s/%s", relayURL, token, fileName)
-	msg := fmt.Sprintf("📦 %s (%d MB)\n\n🔗 Download:\n%s", fileName, fileSize/(1024*1024), downloadURL)
+// registerRelayTransfer registers one download with the relay
+func registerRelayTransfer(relayURL, secret, token string, item relayItem) error {
+	return relayPost(relayURL, secret, token, "/register", map[string]interface{}{
+		"token":    token,
+		"filename": item.Name,
+		"size":     item.relaySize(),
+	})
+}
 
+// relayDownloadURL is an item's link (with the name in the URL for browser compatibility)
+func relayDownloadURL(relayURL, token, name string) string {
+	return fmt.Sprintf("%s/d/%s/%s", relayURL, token, url.PathEscape(name))
+}
+
+// sendRelayLink posts a relay download link for an item to the topic and
+// streams it to whoever opens the link
+func sendRelayLink(config *Config, sessionName string, topicID int64, item relayItem) error {
+	relayURL := relayURLFor(config)
+	fmt.Printf("📤 Preparing %s (%s) for streaming relay...\n", item.Name, item.sizeLabel())
+
+	token := newRelayToken()
+	if err := registerRelayTransfer(relayURL, config.RelaySecret, token, item); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("📦 %s (%s)\n\n🔗 Download:\n%s", item.Name, item.sizeLabel(), relayLinkURL(relayURL, token, item))
+	if item.Key != nil {
+		msg = strings.Replace(msg, "🔗 Download:", "🔒 Encrypted end to end, download:", 1)
+	}
 	fmt.Printf("📤 Sending link to %s...\n", sessionName)
-	if err := sendMessage(config, config.GroupID, topicID, msg); err != nil {
+	msgID, err := sendMessageGetID(config, sessionChat(config, sessionName), topicID, msg)
+	if err != nil {
 		return err
 	}
+	progress := &relayProgress{config: config, chatID: sessionChat(config, sessionName), threadID: topicID, messageID: msgID, text: msg, name: item.Name, size: item.relaySize()}
 
 	// Wait for download request and stream
 	fmt.Printf("⏳ Waiting for download (link expires in 10 min)...\n")
-	return streamFileToRelay(relayURL, token, filePath, fileName, fileSize)
+	return streamToRelay(relayURL, config.RelaySecret, token, item, progress)
+}
+
+// cancelRelayTransfer tells the relay to drop a transfer
+func cancelRelayTransfer(relayURL, secret, token string) {
+	req, err := newRelayRequest("GET", relayURL+"/cancel/"+token, secret, token, nil)
+	if err != nil {
+		return
+	}
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+	}
 }
 
-func streamFileToRelay(relayURL, token, filePath, fileName string, fileSize int64) error {
+// sessionForDir returns the session whose project directory contains dir
+func sessionForDir(config *Config, dir string) (string, *SessionInfo) {
+	for name, info := range config.Sessions {
+		if info == nil || info.Path == "" {
+			continue
+		}
+		if dir == info.Path || strings.HasPrefix(dir, info.Path+"/") {
+			return name, info
+		}
+	}
+	return "", nil
+}
+
+// maxTelegramDownloadSize is the largest file the Bot API lets bots download
+const maxTelegramDownloadSize = 20 * 1024 * 1024 // 20MB
+
+// handleReceiveFile lists files posted in the current session's topic, or
+// downloads one into the working directory (--latest or --id <message id>).
+// --relay posts an upload link instead, for files too big for Telegram, and
+// an encrypted `ccc send --encrypt` link is downloaded and decrypted locally.
+func handleReceiveFile(args []string) error {
+	if len(args) == 1 && strings.Contains(args[0], "/e/") {
+		return receiveEncrypted(args[0])
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("no config found: %w", err)
+	}
+
+	var latest, viaRelay bool
+	var msgID int64
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--latest":
+			latest = true
+		case "--relay":
+			viaRelay = true
+		case "--id":
+			if i+1 >= len(args) {
+				return fmt.Errorf("usage: ccc receive [--latest|--id <msg>|--relay|<link>]")
+			}
+			i++
+			if msgID, err = strconv.ParseInt(args[i], 10, 64); err != nil {
+				return fmt.Errorf("invalid message id: %s", args[i])
+			}
+		default:
+			return fmt.Errorf("usage: ccc receive [--latest|--id <msg>|--relay|<link>]")
+		}
+	}
+
+	cwd, _ := os.Getwd()
+	sessName, info := sessionForDir(config, cwd)
+	if sessName == "" {
+		return fmt.Errorf("no session found for current directory")
+	}
+	if viaRelay {
+		return receiveViaRelay(config, sessName, info)
+	}
+	files, err := loadSessionFiles(sessName)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no files posted in %s yet", sessName)
+	}
+
+	var chosen *SessionFile
+	switch {
+	case latest:
+		chosen = &files[len(files)-1]
+	case msgID != 0:
+		for i := range files {
+			if files[i].MessageID == msgID {
+				chosen = &files[i]
+			}
+		}
+		if chosen == nil {
+			return fmt.Errorf("no file with message id %d in %s", msgID, sessName)
+		}
+	default:
+		fmt.Printf("📎 Files posted in %s (newest last):\n", sessName)
+		for _, f := range files {
+			fmt.Printf("  %-8d %s  %s (%s)\n", f.MessageID, f.Posted.Format("2006-01-02 15:04"), f.FileName, formatFileSize(f.FileSize))
+		}
+		fmt.Println("\nDownload with: ccc receive --latest  or  ccc receive --id <msg>")
+		return nil
+	}
+
+	if chosen.FileSize > maxTelegramDownloadSize {
+		return fmt.Errorf("%s is %s; bots can only download files up to 20 MB", chosen.FileName, formatFileSize(chosen.FileSize))
+	}
+	destPath := uniqueFilePath(filepath.Join(cwd, filepath.Base(chosen.FileName)))
+	fmt.Printf("📥 Downloading %s...\n", chosen.FileName)
+	if err := downloadTelegramFile(config, chosen.FileID, destPath); err != nil {
+		os.Remove(destPath)
+		return fmt.Errorf("download failed: %w", err)
+	}
+	fmt.Printf("✅ Saved %s\n", destPath)
+	return nil
+}
+
+// uniqueFilePath returns path, or path with a " (n)" suffix if it already exists
+func uniqueFilePath(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return path
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// formatFileSize renders a byte count as KB or MB
+func formatFileSize(n int64) string {
+	if n >= 1024*1024 {
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
+	return fmt.Sprintf("%.1f KB", float64(n)/1024)
+}
+
+// streamToRelay streams an item to the relay each time its link is opened,
+// until the link expires. A non-nil progress keeps the link message updated.
+// The SHA-256 of the first complete stream is added to the message; encrypted
+// items are skipped, since GCM already authenticates every chunk.
+func streamToRelay(relayURL, secret, token string, item relayItem, progress *relayProgress) error {
+	fileName, fileSize := item.Name, item.relaySize()
+	checksum := ""
+	var salts e2eSalt
 	// Poll for download requests - loop to allow multiple downloads
 	timeout := time.After(10 * time.Minute)
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
 	downloadCount := 0
+	completed := 0
+	defer func() { progress.finish(completed) }()
 
 	for {
 		select {
 		case <-timeout:
-			http.Get(relayURL + "/cancel/" + token)
+			cancelRelayTransfer(relayURL, secret, token)
 			if downloadCount > 0 {
 				fmt.Printf("⏰ Session expired after %d download(s)\n", downloadCount)
 				return nil
@@ -128,6 +384,10 @@ func streamFileToRelay(relayURL, token, filePath, fileName string, fileSize int6
 			}
 			body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
 			resp.Body.Close()
+			offset, _ := strconv.ParseInt(resp.Header.Get(relayOffsetHeader), 10, 64)
+			if offset < 0 || offset > fileSize {
+				offset = 0
+			}
 
 			status := string(body)
 			if status == "waiting" {
@@ -135,27 +395,55 @@ func streamFileToRelay(relayURL, token, filePath, fileName string, fileSize int6
 			} else if status == "ready" {
 				// Someone requested download, start streaming
 				downloadCount++
-				fmt.Printf("📤 Streaming %s (download #%d)...\n", fileName, downloadCount)
+				if offset > 0 {
+					fmt.Printf("📤 Resuming %s from %d MB (download #%d)...\n", fileName, offset/(1024*1024), downloadCount)
+				} else {
+					fmt.Printf("📤 Streaming %s (download #%d)...\n", fileName, downloadCount)
+				}
 
-				file, err := os.Open(filePath)
+				if item.Key != nil {
+					item.Salt = salts.next(item.Path, offset)
+				}
+				file, err := item.open(offset)
 				if err != nil {
 					return err
 				}
 
-				// Stream to relay
-				req, _ := http.NewRequest("POST", relayURL+"/stream/"+token, file)
+				// Stream to relay, hashing full plain streams
+				var src io.Reader = file
+				var hashed *checksumReader
+				if offset == 0 && item.Key == nil && checksum == "" {
+					hashed = newChecksumReader(file)
+					src = hashed
+				}
+				counter := &countingReader{r: src}
+				req, _ := newRelayRequest("POST", relayURL+"/stream/"+token, secret, token, counter)
 				req.Header.Set("Content-Type", "application/octet-stream")
 				req.Header.Set("X-Filename", fileName)
-				req.ContentLength = fileSize
+				req.Header.Set(relayOffsetHeader, strconv.FormatInt(offset, 10))
+				if fileSize > 0 {
+					req.ContentLength = fileSize - offset
+				} else {
+					req.ContentLength = -1 // a zipped directory: size unknown, so no resuming
+				}
 
 				client := &http.Client{Timeout: 30 * time.Minute}
+				stopProgress := progress.track(counter, offset)
 				streamResp, err := client.Do(req)
+				stopProgress()
 				file.Close()
 				if err != nil {
 					fmt.Printf("⚠️ Streaming error: %v\n", err)
 					continue
 				}
 				streamResp.Body.Close()
+				if hashed != nil && hashed.sum() != "" {
+					checksum = hashed.sum()
+					fmt.Printf("🔐 SHA-256: %s\n", checksum)
+					progress.setChecksum(checksum)
+				}
+				completed++
+				progress.downloaded(completed)
 
 				fmt.Printf("✅ Download #%d complete! Waiting for more requests...\n", downloadCount)
 				// Continue looping for more downloads
@@ -179,13 +467,53 @@ type relayTransfer struct {
 	Token    string
 	Filename string
 	Size     int64
+	Upload   bool   // browser → ccc (see relayupload.go) instead of ccc → browser
 	Status   string // "waiting", "ready", "streaming", "done", "cancelled"
 	Created  time.Time
+	Offset   int64  // first byte the current download asked for
+	End      int64  // last byte (inclusive) the current download asked for
+	Checksum string // SHA-256 of the whole file, once one download has carried all of it
 	DataChan chan []byte
 	DoneChan chan struct{}
 }
 
-func runRelayServer(port string) {
+// relayOptions configures a relay server
+type relayOptions struct {
+	Secret       string // shared secret for /register, /stream and /cancel ("" = open relay)
+	CertFile     string // TLS certificate (with KeyFile)
+	KeyFile      string
+	Domain       string // obtain a Let's Encrypt certificate for this domain
+	CertCacheDir string // where Let's Encrypt certificates are cached
+	MaxTransfers int    // concurrently registered transfers (0 = unlimited)
+	Control      bool   // serve control channels and the web client (ccc relay --control)
+	MaxChannels  int    // control channels at once (0 = unlimited)
+}
+
+// relayOptionsFromEnv reads relay server options from CCC_RELAY_* environment variables
+func relayOptionsFromEnv() relayOptions {
+	opts := relayOptions{
+		Secret:       os.Getenv("CCC_RELAY_SECRET"),
+		CertFile:     os.Getenv("CCC_RELAY_CERT"),
+		KeyFile:      os.Getenv("CCC_RELAY_KEY"),
+		Domain:       os.Getenv("CCC_RELAY_DOMAIN"),
+		CertCacheDir: os.Getenv("CCC_RELAY_CERT_CACHE"),
+		MaxTransfers: defaultRelayMaxTransfers,
+		MaxChannels:  defaultRelayMaxChannels,
+	}
+	if opts.CertCacheDir == "" {
+		home, _ := os.UserHomeDir()
+		opts.CertCacheDir = filepath.Join(home, ".ccc-relay-certs")
+	}
+	if n, err := strconv.Atoi(os.Getenv("CCC_RELAY_MAX_TRANSFERS")); err == nil && n >= 0 {
+		opts.MaxTransfers = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("CCC_RELAY_MAX_CHANNELS")); err == nil && n >= 0 {
+		opts.MaxChannels = n
+	}
+	return opts
+}
+
+func runRelayServer(port string, opts relayOptions) error {
 	// Clean up old transfers periodically
 	go func() {
 		for {
@@ -203,11 +531,51 @@ func runRelayServer(port string) {
 				}
 			}
 			relayTransfers.Unlock()
+			expireRelayManifests(15 * time.Minute)
+			expireControlChannels(controlChannelIdle)
 		}
 	}()
 
+	server := &http.Server{Addr: ":" + port, Handler: newRelayMux(opts)}
+
+	fmt.Printf("🚀 Streaming relay server on :%s\n", port)
+	fmt.Println("   No files stored - direct sender→relay→receiver streaming!")
+	if opts.Secret == "" {
+		fmt.Println("   ⚠️ No CCC_RELAY_SECRET set - anyone can register transfers")
+	}
+	if opts.Control {
+		fmt.Println("   📱 Control channels on /c/, web client on /app (pair with: ccc pair)")
+	}
+
+	switch {
+	case opts.Domain != "":
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(opts.Domain),
+			Cache:      autocert.DirCache(opts.CertCacheDir),
+		}
+		// HTTP-01 challenges need port 80; TLS-ALPN-01 works on the TLS port alone
+		go http.ListenAndServe(":80", m.HTTPHandler(nil))
+		server.TLSConfig = m.TLSConfig()
+		fmt.Printf("   🔒 TLS via Let's Encrypt for %s\n", opts.Domain)
+		return server.ListenAndServeTLS("", "")
+	case opts.CertFile != "" || opts.KeyFile != "":
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return fmt.Errorf("both CCC_RELAY_CERT and CCC_RELAY_KEY are required for TLS")
+		}
+		server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		fmt.Println("   🔒 TLS with provided certificate")
+		return server.ListenAndServeTLS(opts.CertFile, opts.KeyFile)
+	}
+	return server.ListenAndServe()
+}
+
+// newRelayMux returns the relay's HTTP handlers
+func newRelayMux(opts relayOptions) *http.ServeMux {
+	mux := http.NewServeMux()
+
 	// Register a new transfer
-	http.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
@@ -217,17 +585,32 @@ func runRelayServer(port string) {
 			Token    string `json:"token"`
 			Filename string `json:"filename"`
 			Size     int64  `json:"size"`
+			Upload   bool   `json:"upload"` // a browser uploads to ccc through /u/<token>
 		}
-		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxResponseSize)).Decode(&data); err != nil {
 			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
+		if len(data.Token) < 16 {
+			http.Error(w, "Invalid token", http.StatusBadRequest)
+			return
+		}
+		if !verifyRelaySignature(opts.Secret, data.Token, r.Header.Get(relaySignatureHeader)) {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
 
 		relayTransfers.Lock()
+		if _, exists := relayTransfers.transfers[data.Token]; !exists && opts.MaxTransfers > 0 && len(relayTransfers.transfers) >= opts.MaxTransfers {
+			relayTransfers.Unlock()
+			http.Error(w, "Too many active transfers, try again later", http.StatusServiceUnavailable)
+			return
+		}
 		relayTransfers.transfers[data.Token] = &relayTransfer{
 			Token:    data.Token,
 			Filename: data.Filename,
 			Size:     data.Size,
+			Upload:   data.Upload,
 			Status:   "waiting",
 			Created:  time.Now(),
 			DataChan: make(chan []byte, 100),
@@ -240,7 +623,7 @@ func runRelayServer(port string) {
 	})
 
 	// Check transfer status
-	http.HandleFunc("/status/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/status/", func(w http.ResponseWriter, r *http.Request) {
 		token := strings.TrimPrefix(r.URL.Path, "/status/")
 		relayTransfers.RLock()
 		t, exists := relayTransfers.transfers[token]
@@ -250,12 +633,20 @@ func runRelayServer(port string) {
 			fmt.Fprint(w, "not_found")
 			return
 		}
+		if t.Status == "ready" {
+			w.Header().Set(relayOffsetHeader, strconv.FormatInt(t.Offset, 10))
+		}
 		fmt.Fprint(w, t.Status)
 	})
 
 	// Cancel transfer
-	http.HandleFunc("/cancel/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/cancel/", func(w http.ResponseWriter, r *http.Request) {
 		token := strings.TrimPrefix(r.URL.Path, "/cancel/")
+		// The token is in the public download link, so only the sender may cancel
+		if !verifyRelaySignature(opts.Secret, token, r.Header.Get(relaySignatureHeader)) {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
 		relayTransfers.Lock()
 		if t, exists := relayTransfers.transfers[token]; exists {
 			t.Status = "cancelled"
@@ -271,24 +662,37 @@ func runRelayServer(port string) {
 	})
 
 	// Sender streams file data
-	http.HandleFunc("/stream/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/stream/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
 		token := strings.TrimPrefix(r.URL.Path, "/stream/")
+		if !verifyRelaySignature(opts.Secret, token, r.Header.Get(relaySignatureHeader)) {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
 		relayTransfers.RLock()
 		t, exists := relayTransfers.transfers[token]
 		relayTransfers.RUnlock()
 
-		if !exists || t.Status != "ready" {
+		if !exists || t.Upload || t.Status != "ready" {
 			http.Error(w, "Transfer not ready", http.StatusBadRequest)
 			return
 		}
 
 		t.Status = "streaming"
-		fmt.Printf("📤 Streaming: %s (%s)\n", t.Filename, token[:8])
+		fmt.Printf("📤 Streaming: %s (%s) from byte %d\n", t.Filename, token[:8], t.Offset)
+
+		// Senders that can't seek start at 0; skip up to the requested offset
+		senderOffset, _ := strconv.ParseInt(r.Header.Get(relayOffsetHeader), 10, 64)
+		if skip := t.Offset - senderOffset; skip > 0 {
+			if _, err := io.CopyN(io.Discard, r.Body, skip); err != nil {
+				http.Error(w, "Stream ended before requested offset", http.StatusBadRequest)
+				return
+			}
+		}
 
 		var bytesSent int64
 		// Read from sender and send to channel
@@ -319,8 +723,11 @@ func runRelayServer(port string) {
 		fmt.Printf("✅ Stream complete: %s (%s) - %d bytes\n", t.Filename, token[:8], bytesSent)
 	})
 
-	// Download endpoint - receiver gets file
-	http.HandleFunc("/d/", func(w http.ResponseWriter, r *http.Request) {
+	// Download endpoint - receiver gets file. Range requests are served by having
+	// the sender re-stream from the requested offset, so downloads can resume.
+	mux.HandleFunc("/d/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+
 		// Ignore Telegram link preview bots and HEAD requests
 		ua := r.UserAgent()
 		if strings.Contains(ua, "TelegramBot") || strings.Contains(ua, "Telegram") {
@@ -339,8 +746,23 @@ func runRelayServer(port string) {
 		token := pathParts[0]
 		relayTransfers.Lock()
 		t, exists := relayTransfers.transfers[token]
+		if exists && t.Upload {
+			exists = false
+		}
 		if exists && t.Status == "waiting" {
+			start, end, partial, err := parseByteRange(r.Header.Get("Range"), t.Size)
+			if err != nil {
+				size := t.Size
+				relayTransfers.Unlock()
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+				http.Error(w, "Requested range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			if !partial {
+				start, end = 0, t.Size-1
+			}
 			t.Status = "ready"
+			t.Offset, t.End = start, end
 			// Create fresh channels for this download
 			t.DataChan = make(chan []byte, 100)
 			t.DoneChan = make(chan struct{})
@@ -368,8 +790,26 @@ func runRelayServer(port string) {
 		}, t.Filename)
 		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, safeName))
 		w.Header().Set("Content-Type", "application/octet-stream")
+		// Bytes still owed to this receiver; unknown (negative) when the size is
+		// not known up front, as for a directory zipped while it streams
+		remaining := int64(-1)
 		if t.Size > 0 {
-			w.Header().Set("Content-Length", fmt.Sprintf("%d", t.Size))
+			remaining = t.End - t.Offset + 1
+			w.Header().Set("Content-Length", fmt.Sprintf("%d", remaining))
+		}
+		if t.Checksum != "" {
+			w.Header().Set(relayChecksumHeader, t.Checksum)
+		}
+		// A download of the whole file also yields its checksum for later ones
+		// (not for zipped directories: without a size, a cut stream looks complete)
+		var whole hash.Hash
+		if t.Size > 0 && t.Offset == 0 && t.End == t.Size-1 && t.Checksum == "" {
+			whole = sha256.New()
+		}
+		if t.Offset > 0 || (t.Size > 0 && t.End < t.Size-1) {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", t.Offset, t.End, t.Size))
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Printf("📥 Resuming at byte %d: %s (%s)\n", t.Offset, t.Filename, token[:8])
 		}
 
 		flusher, _ := w.(http.Flusher)
@@ -391,8 +831,14 @@ func runRelayServer(port string) {
 					// Channel closed, transfer complete
 					break downloadLoop
 				}
+				if remaining >= 0 && int64(len(data)) > remaining {
+					data = data[:remaining]
+				}
 				n, err := w.Write(data)
 				bytesWritten += int64(n)
+				if whole != nil {
+					whole.Write(data[:n])
+				}
 				if err != nil {
 					fmt.Printf("❌ Write error: %s (%s) after %d bytes: %v\n", t.Filename, token[:8], bytesWritten, err)
 					writeErr = err
@@ -401,6 +847,13 @@ func runRelayServer(port string) {
 				if flusher != nil {
 					flusher.Flush()
 				}
+				if remaining >= 0 {
+					remaining -= int64(n)
+					if remaining == 0 {
+						// Requested range delivered; the sender is told to stop below
+						break downloadLoop
+					}
+				}
 			}
 		}
 
@@ -410,6 +863,9 @@ func runRelayServer(port string) {
 			close(t.DoneChan)
 			if writeErr == nil {
 				t.Status = "waiting"
+				if whole != nil && bytesWritten == t.Size {
+					t.Checksum = hex.EncodeToString(whole.Sum(nil))
+				}
 				fmt.Printf("📥 Download complete: %s (%s) - %d bytes sent\n", t.Filename, token[:8], bytesWritten)
 			} else {
 				t.Status = "waiting" // Still allow retry
@@ -419,11 +875,59 @@ func runRelayServer(port string) {
 		relayTransfers.Unlock()
 	})
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	handleRelayManifests(mux, opts)
+	handleRelayUploads(mux, opts)
+	handleRelayE2E(mux)
+	if opts.Control {
+		handleRelayControl(mux, opts)
+	}
+
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprint(w, "OK")
 	})
 
-	fmt.Printf("🚀 Streaming relay server on :%s\n", port)
-	fmt.Println("   No files stored - direct sender→relay→receiver streaming!")
-	http.ListenAndServe(":"+port, nil)
+	return mux
+}
+
+// parseByteRange parses a single-range "bytes=" Range header against a file size,
+// returning the inclusive byte span. partial is false when the whole file should
+// be sent: no header, an unknown size, or a form we don't serve (multiple ranges).
+func parseByteRange(header string, size int64) (start, end int64, partial bool, err error) {
+	if header == "" || size <= 0 || !strings.HasPrefix(header, "bytes=") || strings.Contains(header, ",") {
+		return 0, 0, false, nil
+	}
+	spec := strings.TrimSpace(strings.TrimPrefix(header, "bytes="))
+	dash := strings.Index(spec, "-")
+	if dash < 0 {
+		return 0, 0, false, fmt.Errorf("invalid range %q", header)
+	}
+	first, last := spec[:dash], spec[dash+1:]
+
+	if first == "" {
+		// Suffix range: the last N bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, fmt.Errorf("invalid range %q", header)
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true, nil
+	}
+
+	start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false, fmt.Errorf("unsatisfiable range %q", header)
+	}
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false, fmt.Errorf("invalid range %q", header)
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	return start, end, true, nil
 }
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// relayItem is something `ccc send` delivers: a file, or a directory zipped
+// on the fly while it streams
+type relayItem struct {
+	Path string
+	Name string // download name: the file name, or <dir>.zip
+	Size int64  // 0 for a directory, whose zip size isn't known up front
+	Dir  bool
+	Key  []byte // end-to-end encryption key (--encrypt), nil for a plain transfer
+	Salt []byte // salt of the current encrypted stream, see e2eSalt
+}
+
+// relaySize is what the relay streams: the size, or the encrypted size
+func (it relayItem) relaySize() int64 {
+	if it.Key != nil && !it.Dir {
+		return encryptedSize(it.Size)
+	}
+	return it.Size
+}
+
+// newRelayItem resolves a path given to `ccc send`
+func newRelayItem(path string) (relayItem, error) {
+	if !filepath.IsAbs(path) {
+		cwd, _ := os.Getwd()
+		path = filepath.Join(cwd, path)
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return relayItem{}, fmt.Errorf("file not found: %w", err)
+	}
+	if st.IsDir() {
+		return relayItem{Path: path, Name: filepath.Base(path) + ".zip", Dir: true}, nil
+	}
+	return relayItem{Path: path, Name: filepath.Base(path), Size: st.Size()}, nil
+}
+
+// sizeLabel describes the item's size for links and progress output
+func (it relayItem) sizeLabel() string {
+	if it.Dir {
+		return "folder, zipped on the fly"
+	}
+	return formatFileSize(it.Size)
+}
+
+// open returns the item's bytes from offset on, encrypted when it has a key.
+// A directory is zipped into a pipe as it is read and always starts from the
+// beginning.
+func (it relayItem) open(offset int64) (io.ReadCloser, error) {
+	if it.Key != nil {
+		return openEncrypted(it, offset)
+	}
+	if it.Dir {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(zipDir(it.Path, pw))
+		}()
+		return pr, nil
+	}
+	f, err := os.Open(it.Path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// zipDir writes a zip of dir to w, with paths under the directory's name.
+// Only regular files are included; symlinks and sockets are skipped.
+func zipDir(dir string, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	root := filepath.Dir(dir)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return addFileToZip(zw, path, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// relayManifestFile is one download listed on a manifest page
+type relayManifestFile struct {
+	Token    string `json:"token"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
+// maxManifestFiles caps the files one manifest may list
+const maxManifestFiles = 100
+
+// sendBundleToTopic registers every item with the relay, posts one download
+// page listing them to the topic, and streams each item when its link is opened
+func sendBundleToTopic(config *Config, sessionName string, topicID int64, items []relayItem) error {
+	relayURL := relayURLFor(config)
+	if len(items) > maxManifestFiles {
+		return fmt.Errorf("too many files (max %d)", maxManifestFiles)
+	}
+
+	tokens := make([]string, len(items))
+	var files []relayManifestFile
+	for i, item := range items {
+		tokens[i] = newRelayToken()
+		if err := registerRelayTransfer(relayURL, config.RelaySecret, tokens[i], item); err != nil {
+			for _, t := range tokens[:i] {
+				cancelRelayTransfer(relayURL, config.RelaySecret, t)
+			}
+			return err
+		}
+		files = append(files, relayManifestFile{Token: tokens[i], Filename: item.Name, Size: item.Size})
+	}
+	token := newRelayToken()
+	if err := relayPost(relayURL, config.RelaySecret, token, "/manifest", map[string]interface{}{
+		"token": token,
+		"title": fmt.Sprintf("%d files from %s", len(items), sessionName),
+		"files": files,
+	}); err != nil {
+		for _, t := range tokens {
+			cancelRelayTransfer(relayURL, config.RelaySecret, t)
+		}
+		return err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📦 %d files\n", len(items))
+	for _, item := range items {
+		fmt.Fprintf(&sb, "\n• %s (%s)", item.Name, item.sizeLabel())
+	}
+	fmt.Fprintf(&sb, "\n\n🔗 Download page:\n%s/m/%s", relayURL, token)
+	if items[0].Key != nil {
+		// The keys ride in the fragment; the page hands each to its file's link
+		var keys []string
+		for _, item := range items {
+			keys = append(keys, e2eKeyText(item.Key))
+		}
+		fmt.Fprintf(&sb, "#%s\n\n🔒 Encrypted end to end", strings.Join(keys, "."))
+	}
+	fmt.Printf("📤 Sending download page for %d files to %s...\n", len(items), sessionName)
+	if err := sendMessage(config, sessionChat(config, sessionName), topicID, sb.String()); err != nil {
+		return err
+	}
+
+	fmt.Printf("⏳ Waiting for downloads (links expire in 10 min)...\n")
+	errs := make([]error, len(items))
+	var wg sync.WaitGroup
+	for i := range items {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = streamToRelay(relayURL, config.RelaySecret, tokens[i], items[i], nil)
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			fmt.Printf("⚠️ %s: %v\n", items[i].Name, err)
+			failed++
+		}
+	}
+	if failed == len(items) {
+		return fmt.Errorf("none of the files were downloaded")
+	}
+	return nil
+}
+
+// Relay server manifests: download pages listing several transfers
+var relayManifests = struct {
+	sync.RWMutex
+	manifests map[string]*relayManifest
+}{manifests: make(map[string]*relayManifest)}
+
+type relayManifest struct {
+	Title   string
+	Files   []relayManifestFile
+	Created time.Time
+}
+
+var manifestPage = template.Must(template.New("manifest").Funcs(template.FuncMap{
+	"size": func(n int64) string {
+		if n <= 0 {
+			return "zip"
+		}
+		return formatFileSize(n)
+	},
+	"link": func(f relayManifestFile) string {
+		return relayDownloadURL("", f.Token, f.Filename)
+	},
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>body{font-family:system-ui,sans-serif;max-width:40em;margin:2em auto;padding:0 1em}li{margin:.6em 0}span{color:#888}</style>
+</head><body>
+<h2>📦 {{.Title}}</h2>
+<ul>{{range .Files}}
+<li><a href="{{link .}}">{{.Filename}}</a> <span>{{size .Size}}</span></li>{{end}}
+</ul>
+<p><span>Links stay open while the sender is waiting (10 minutes).</span></p>
+<script>
+// Encrypted files: the page's #fragment holds one key per file, in order
+var keys = location.hash.slice(1).split('.');
+document.querySelectorAll('li a').forEach(function(a, i) {
+  if (keys[i]) a.href = a.getAttribute('href').replace('/d/', '/e/') + '#' + keys[i];
+});
+</script>
+</body></html>
+`))
+
+// handleRelayManifests adds /manifest (register a page) and /m/<token> (show
+// it) to a relay. Every listed file must already be registered.
+func handleRelayManifests(mux *http.ServeMux, opts relayOptions) {
+	mux.HandleFunc("/manifest", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var data struct {
+			Token string              `json:"token"`
+			Title string              `json:"title"`
+			Files []relayManifestFile `json:"files"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxResponseSize)).Decode(&data); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if len(data.Token) < 16 {
+			http.Error(w, "Invalid token", http.StatusBadRequest)
+			return
+		}
+		if !verifyRelaySignature(opts.Secret, data.Token, r.Header.Get(relaySignatureHeader)) {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if len(data.Files) == 0 || len(data.Files) > maxManifestFiles {
+			http.Error(w, "Invalid file list", http.StatusBadRequest)
+			return
+		}
+		relayTransfers.RLock()
+		for _, f := range data.Files {
+			if _, ok := relayTransfers.transfers[f.Token]; !ok {
+				relayTransfers.RUnlock()
+				http.Error(w, "Unknown transfer in file list", http.StatusBadRequest)
+				return
+			}
+		}
+		relayTransfers.RUnlock()
+
+		relayManifests.Lock()
+		relayManifests.manifests[data.Token] = &relayManifest{Title: data.Title, Files: data.Files, Created: time.Now()}
+		relayManifests.Unlock()
+		fmt.Printf("📋 Registered manifest: %d files (%s)\n", len(data.Files), data.Token[:8])
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.HandleFunc("/m/", func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/m/")
+		relayManifests.RLock()
+		m, exists := relayManifests.manifests[token]
+		relayManifests.RUnlock()
+		if !exists {
+			http.Error(w, "Download page not found - it may have expired", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		manifestPage.Execute(w, m)
+	})
+}
+
+// expireRelayManifests drops manifest pages older than maxAge
+func expireRelayManifests(maxAge time.Duration) {
+	relayManifests.Lock()
+	for token, m := range relayManifests.manifests {
+		if time.Since(m.Created) > maxAge {
+			delete(relayManifests.manifests, token)
+		}
+	}
+	relayManifests.Unlock()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRelayItemDirectoryZip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dist")
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("alpha"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("beta"), 0644)
+	os.Symlink(filepath.Join(dir, "a.txt"), filepath.Join(dir, "link"))
+
+	item, err := newRelayItem(dir)
+	if err != nil {
+		t.Fatalf("newRelayItem: %v", err)
+	}
+	if !item.Dir || item.Name != "dist.zip" || item.Size != 0 {
+		t.Fatalf("item = %+v", item)
+	}
+	rc, err := item.open(0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "dist/a.txt,dist/sub/b.txt" {
+		t.Errorf("zip entries = %v", names)
+	}
+}
+
+func TestRelayItemFileOffset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.bin")
+	os.WriteFile(path, []byte("0123456789"), 0644)
+	item, err := newRelayItem(path)
+	if err != nil || item.Dir || item.Size != 10 || item.Name != "f.bin" {
+		t.Fatalf("item = %+v, %v", item, err)
+	}
+	rc, _ := item.open(7)
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "789" {
+		t.Errorf("open(7) = %q", data)
+	}
+	if _, err := newRelayItem(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for a missing path")
+	}
+}
+
+func TestRelayManifest(t *testing.T) {
+	secret := "relay-secret"
+	server := httptest.NewServer(newRelayMux(relayOptions{Secret: secret}))
+	defer server.Close()
+
+	fileToken := "file0123456789abcdef0123456789ab"
+	if err := registerRelayTransfer(server.URL, secret, fileToken, relayItem{Name: "my report.pdf", Size: 2048}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	defer cancelRelayTransfer(server.URL, secret, fileToken)
+
+	token := "mani0123456789abcdef0123456789ab"
+	files := []relayManifestFile{{Token: fileToken, Filename: "my report.pdf", Size: 2048}}
+	if err := relayPost(server.URL, "wrong", token, "/manifest", map[string]interface{}{"token": token, "files": files}); err == nil {
+		t.Error("manifest with a bad signature was accepted")
+	}
+	unknown := append(files, relayManifestFile{Token: "nope0123456789abcdef0123456789ab", Filename: "x"})
+	if err := relayPost(server.URL, secret, token, "/manifest", map[string]interface{}{"token": token, "files": unknown}); err == nil {
+		t.Error("manifest with an unregistered file was accepted")
+	}
+	if err := relayPost(server.URL, secret, token, "/manifest", map[string]interface{}{"token": token, "title": "1 file from api", "files": files}); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+
+	resp, err := http.Get(server.URL + "/m/" + token)
+	if err != nil {
+		t.Fatalf("page: %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	for _, want := range []string{"1 file from api", "/d/" + fileToken + "/my%20report.pdf", "2.0 KB"} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("page missing %q:\n%s", want, page)
+		}
+	}
+
+	resp, _ = http.Get(server.URL + "/m/missing")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown manifest = %d, want 404", resp.StatusCode)
+	}
+}
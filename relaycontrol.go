@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Control channels (`ccc relay --control`) carry messages between the
+// listener and a web client on the relay's /app page, for networks where
+// Telegram is blocked. A channel has two mailboxes: "down" from the listener
+// to the client and "up" back. The relay only queues opaque envelopes; both
+// ends seal them with AES-256-GCM under a key that is only in the pairing
+// link's #fragment or in the saved page (see relaymessenger.go).
+const (
+	controlMaxMessage       = 128 * 1024 // bytes per envelope
+	controlQueueSize        = 200        // envelopes kept per mailbox
+	controlPollWait         = 25 * time.Second
+	controlChannelIdle      = 24 * time.Hour // channels unused this long are dropped
+	defaultRelayMaxChannels = 100
+)
+
+// controlEnvelope is one sealed message in a mailbox
+type controlEnvelope struct {
+	Seq  int64  `json:"seq"`
+	Data string `json:"data"`
+}
+
+type controlMailbox struct {
+	last   int64
+	queue  []controlEnvelope
+	notify chan struct{} // closed when an envelope arrives
+}
+
+type controlChannel struct {
+	boxes map[string]*controlMailbox
+	used  time.Time
+}
+
+var relayControl = struct {
+	sync.Mutex
+	channels map[string]*controlChannel
+}{channels: make(map[string]*controlChannel)}
+
+// validControlChannel reports whether id can name a channel: a long random
+// token from `ccc pair`, so channels can't be guessed
+func validControlChannel(id string) bool {
+	if len(id) < 16 || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// controlBox returns a channel's mailbox, creating the channel unless that
+// would pass maxChannels; relayControl must be held
+func controlBox(id, box string, maxChannels int, now time.Time) *controlMailbox {
+	ch, exists := relayControl.channels[id]
+	if !exists {
+		if maxChannels > 0 && len(relayControl.channels) >= maxChannels {
+			return nil
+		}
+		ch = &controlChannel{boxes: make(map[string]*controlMailbox)}
+		relayControl.channels[id] = ch
+	}
+	ch.used = now
+	mb, exists := ch.boxes[box]
+	if !exists {
+		mb = &controlMailbox{notify: make(chan struct{})}
+		ch.boxes[box] = mb
+	}
+	return mb
+}
+
+// expireControlChannels drops channels neither end has used for maxAge
+func expireControlChannels(maxAge time.Duration) {
+	relayControl.Lock()
+	defer relayControl.Unlock()
+	for id, ch := range relayControl.channels {
+		if time.Since(ch.used) > maxAge {
+			delete(relayControl.channels, id)
+		}
+	}
+}
+
+// handleRelayControl adds control channels to a relay: POST /c/<channel>/<up|down>
+// queues an envelope, GET /c/<channel>/<up|down>?after=<seq> long-polls for
+// the ones after seq, and /app is the web client
+func handleRelayControl(mux *http.ServeMux, opts relayOptions) {
+	mux.HandleFunc("/c/", func(w http.ResponseWriter, r *http.Request) {
+		// The page saved by `ccc pair` is opened from a file, another origin.
+		// Mailboxes only hold ciphertext, so any origin may use them.
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		id, box, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/c/"), "/")
+		if !validControlChannel(id) || (box != "up" && box != "down") {
+			http.Error(w, "Unknown channel", http.StatusNotFound)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPost:
+			body, err := io.ReadAll(io.LimitReader(r.Body, controlMaxMessage+1))
+			if err != nil || len(body) == 0 {
+				http.Error(w, "Empty message", http.StatusBadRequest)
+				return
+			}
+			if len(body) > controlMaxMessage {
+				http.Error(w, "Message too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			relayControl.Lock()
+			mb := controlBox(id, box, opts.MaxChannels, time.Now())
+			if mb == nil {
+				relayControl.Unlock()
+				http.Error(w, "Too many channels, try again later", http.StatusServiceUnavailable)
+				return
+			}
+			mb.last++
+			mb.queue = append(mb.queue, controlEnvelope{Seq: mb.last, Data: string(body)})
+			if len(mb.queue) > controlQueueSize {
+				mb.queue = mb.queue[len(mb.queue)-controlQueueSize:]
+			}
+			close(mb.notify)
+			mb.notify = make(chan struct{})
+			seq := mb.last
+			relayControl.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]int64{"seq": seq})
+
+		case http.MethodGet:
+			after, _ := strconv.ParseInt(r.URL.Query().Get("after"), 10, 64)
+			deadline := time.After(controlPollWait)
+			for {
+				relayControl.Lock()
+				mb := controlBox(id, box, opts.MaxChannels, time.Now())
+				if mb == nil {
+					relayControl.Unlock()
+					http.Error(w, "Too many channels, try again later", http.StatusServiceUnavailable)
+					return
+				}
+				var msgs []controlEnvelope
+				for _, env := range mb.queue {
+					if env.Seq > after {
+						msgs = append(msgs, env)
+					}
+				}
+				notify := mb.notify
+				relayControl.Unlock()
+
+				if len(msgs) > 0 {
+					w.Header().Set("Content-Type", "application/json")
+					json.NewEncoder(w).Encode(map[string]interface{}{"messages": msgs})
+					return
+				}
+				select {
+				case <-notify:
+				case <-deadline:
+					w.Header().Set("Content-Type", "application/json")
+					fmt.Fprint(w, `{"messages":[]}`)
+					return
+				case <-r.Context().Done():
+					return
+				}
+			}
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/app", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		controlAppPage.Execute(w, controlAppData{})
+	})
+}
+
+// controlAppData fills in the web client: empty when the relay serves it,
+// the relay URL and pairing when `ccc pair` saves it
+type controlAppData struct {
+	Relay   string
+	Pairing string // "<channel>.<key>"
+}
+
+// controlAppPage is the web client. The pairing is the saved page's, or the
+// link's fragment; messages are sealed as in sealControl, with
+// "<channel>/<box>" as additional data.
+var controlAppPage = template.Must(template.New("app").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>ccc</title>
+<style>
+body{font-family:system-ui,sans-serif;margin:0;display:flex;flex-direction:column;height:100vh}
+header{padding:.5em;border-bottom:1px solid #ccc;display:flex;gap:.5em}
+header select{flex:1;font-size:1em}
+#log{flex:1;overflow-y:auto;padding:.5em}
+.msg{white-space:pre-wrap;word-wrap:break-word;margin:.4em 0;padding:.4em .6em;border-radius:.4em;background:#f0f0f0}
+.msg.me{background:#dcf0ff;margin-left:2em}
+.msg button{margin:.3em .3em 0 0}
+form{display:flex;border-top:1px solid #ccc}
+form textarea{flex:1;font-size:1em;padding:.5em;border:0;resize:none}
+#status{font-size:.8em;color:#888;padding:0 .5em}
+</style>
+</head><body>
+<header><select id="sessions"><option value="0">General</option></select><button id="refresh">↻</button></header>
+<div id="status">Connecting…</div>
+<div id="log"></div>
+<form id="send"><textarea id="text" rows="2" placeholder="Message"></textarea><button>Send</button></form>
+<script>
+(async function() {
+  var status = document.getElementById('status'), log = document.getElementById('log');
+  var picker = document.getElementById('sessions');
+  var relay = {{.Relay}};
+  var frag = (location.hash.slice(1) || {{.Pairing}}).split('.');
+  if (frag.length != 2) { status.textContent = '❌ Open the page or link from ccc pair: it is missing its key'; return; }
+  var channel = frag[0];
+  function b64(bytes) { return btoa(String.fromCharCode.apply(null, bytes)).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, ''); }
+  function unb64(text) { return Uint8Array.from(atob(text.replace(/-/g, '+').replace(/_/g, '/')), function(c) { return c.charCodeAt(0); }); }
+  var key = await crypto.subtle.importKey('raw', unb64(frag[1]), 'AES-GCM', false, ['encrypt', 'decrypt']);
+  var enc = new TextEncoder(), dec = new TextDecoder();
+
+  async function post(msg) {
+    msg.id = b64(crypto.getRandomValues(new Uint8Array(12)));
+    msg.time = Date.now();
+    var iv = crypto.getRandomValues(new Uint8Array(12));
+    var ct = new Uint8Array(await crypto.subtle.encrypt({name: 'AES-GCM', iv: iv, additionalData: enc.encode(channel + '/up')}, key, enc.encode(JSON.stringify(msg))));
+    var sealed = new Uint8Array(iv.length + ct.length);
+    sealed.set(iv); sealed.set(ct, iv.length);
+    await fetch(relay + '/c/' + channel + '/up', {method: 'POST', body: b64(sealed)});
+  }
+  async function open(data) {
+    var raw = unb64(data);
+    var plain = await crypto.subtle.decrypt({name: 'AES-GCM', iv: raw.subarray(0, 12), additionalData: enc.encode(channel + '/down')}, key, raw.subarray(12));
+    return JSON.parse(dec.decode(plain));
+  }
+
+  var topics = {}, messages = {}, current = '0';
+  function topicMessages(topic) { return messages[topic] = messages[topic] || []; }
+  function render() {
+    log.textContent = '';
+    topicMessages(current).forEach(function(m) {
+      var div = document.createElement('div');
+      div.className = 'msg' + (m.me ? ' me' : '');
+      div.textContent = m.text;
+      if (m.file) {
+        var a = document.createElement('a');
+        a.href = URL.createObjectURL(new Blob([unb64(m.file)]));
+        a.download = m.name;
+        a.textContent = '\n📎 ' + m.name;
+        div.appendChild(a);
+      }
+      (m.buttons || []).forEach(function(row) {
+        var line = document.createElement('div');
+        row.forEach(function(b) {
+          var btn = document.createElement('button');
+          btn.textContent = b.text;
+          btn.onclick = function() { m.buttons = null; render(); post({type: 'button', topic: Number(current), message: m.id, data: b.callback_data, text: m.text}); };
+          line.appendChild(btn);
+        });
+        div.appendChild(line);
+      });
+      log.appendChild(div);
+    });
+    log.scrollTop = log.scrollHeight;
+  }
+  function setSessions(list) {
+    topics = {};
+    picker.length = 1;
+    list.forEach(function(s) {
+      topics[s.topic] = s.session;
+      var o = document.createElement('option');
+      o.value = String(s.topic); o.textContent = s.session;
+      picker.appendChild(o);
+    });
+    picker.value = topics[current] ? current : '0';
+    current = picker.value;
+  }
+  function handle(m) {
+    var topic = String(m.topic || 0);
+    switch (m.type) {
+    case 'sessions': setSessions(m.sessions || []); break;
+    case 'message': topicMessages(topic).push({id: m.message, text: m.text, buttons: m.buttons}); break;
+    case 'edit':
+      topicMessages(topic).forEach(function(x) { if (x.id == m.message) { x.text = m.text; x.buttons = m.buttons; } });
+      break;
+    case 'file': topicMessages(topic).push({id: m.message, text: m.text || '', file: m.data, name: m.name}); break;
+    }
+  }
+
+  picker.onchange = function() { current = picker.value; render(); };
+  document.getElementById('refresh').onclick = function() { post({type: 'sessions'}); };
+  document.getElementById('send').onsubmit = function(e) {
+    e.preventDefault();
+    var box = document.getElementById('text'), text = box.value.trim();
+    if (!text) return;
+    box.value = '';
+    topicMessages(current).push({text: text, me: true});
+    render();
+    post({type: 'text', topic: Number(current), text: text});
+  };
+
+  var after = 0;
+  post({type: 'sessions'});
+  for (;;) {
+    try {
+      var resp = await fetch(relay + '/c/' + channel + '/down?after=' + after);
+      if (!resp.ok) throw new Error(await resp.text());
+      var body = await resp.json();
+      for (var i = 0; i < body.messages.length; i++) {
+        after = body.messages[i].seq;
+        try { handle(await open(body.messages[i].data)); } catch (e) {}
+      }
+      status.textContent = relay ? '🔒 Connected, end-to-end encrypted' : '🔒 Connected, encrypted (this page came from the relay, which you must trust)';
+      if (body.messages.length) render();
+    } catch (e) {
+      status.textContent = '⚠️ ' + e.message + ', retrying…';
+      await new Promise(function(r) { setTimeout(r, 5000); });
+    }
+  }
+})();
+</script>
+</body></html>
+`))
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSealControl(t *testing.T) {
+	channel, key := newControlPairing()
+	data, err := sealControl(key, channel, "down", []byte(`{"type":"message"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := openControl(key, channel, "down", data)
+	if err != nil || string(plain) != `{"type":"message"}` {
+		t.Fatalf("openControl() = %q, %v", plain, err)
+	}
+	if _, err := openControl(key, channel, "up", data); err == nil {
+		t.Error("an envelope moved to the other mailbox was accepted")
+	}
+	_, otherKey := newControlPairing()
+	if _, err := openControl(otherKey, channel, "down", data); err == nil {
+		t.Error("an envelope opened with the wrong key")
+	}
+	if _, err := sealControl("short", channel, "down", nil); err == nil {
+		t.Error("sealControl() accepted an invalid key")
+	}
+}
+
+func TestControlReplays(t *testing.T) {
+	now := time.Now()
+	seen := make(controlReplays)
+	msg := controlMessage{Type: "text", ID: "a", Time: now.UnixMilli()}
+	if !seen.fresh(msg, now) {
+		t.Fatal("a new message was rejected")
+	}
+	if seen.fresh(msg, now) {
+		t.Error("a replayed message was accepted")
+	}
+	if seen.fresh(controlMessage{ID: "b", Time: now.Add(-10 * time.Minute).UnixMilli()}, now) {
+		t.Error("an old message was accepted")
+	}
+	if seen.fresh(controlMessage{Time: now.UnixMilli()}, now) {
+		t.Error("a message without an ID was accepted")
+	}
+
+	// Accepted IDs outlive a restart of the listener
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if err := recordControlReplay("a", now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordControlReplay("b", now); err != nil {
+		t.Fatal(err)
+	}
+	restarted, err := loadControlReplays()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restarted["a"]; ok || len(restarted) != 1 {
+		t.Errorf("loadControlReplays() = %v, want only the recent ID", restarted)
+	}
+	if restarted.fresh(controlMessage{ID: "b", Time: now.UnixMilli()}, now) {
+		t.Error("a message seen before the restart was accepted")
+	}
+}
+
+func TestRelayControlChannel(t *testing.T) {
+	if _, pattern := newRelayMux(relayOptions{}).Handler(httptest.NewRequest("GET", "/app", nil)); pattern == "/app" {
+		t.Error("/app served without --control")
+	}
+	server := httptest.NewServer(newRelayMux(relayOptions{Control: true}))
+	defer server.Close()
+
+	channel, key := newControlPairing()
+	config := &Config{RelayURL: server.URL, ControlChannel: channel, ControlKey: key}
+	msgr := &relayMessenger{config: config}
+	id, err := msgr.SendGetID(0, 42, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msgr.Edit(0, id, 42, "hello again"); err != nil {
+		t.Fatal(err)
+	}
+
+	envs, err := pollControl(config, "down", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envs) != 2 {
+		t.Fatalf("got %d envelopes, want 2", len(envs))
+	}
+	for _, env := range envs {
+		if strings.Contains(env.Data, "hello") {
+			t.Error("the relay saw plaintext")
+		}
+	}
+	plain, err := openControl(key, channel, "down", envs[1].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg controlMessage
+	json.Unmarshal(plain, &msg)
+	if msg.Type != "edit" || msg.Topic != 42 || msg.Message != id || msg.Text != "hello again" {
+		t.Errorf("second message = %+v", msg)
+	}
+
+	page := filepath.Join(t.TempDir(), "app.html")
+	if err := writeControlApp(config, page); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := os.ReadFile(page)
+	if !strings.Contains(string(saved), `var relay = "`+server.URL) || !strings.Contains(string(saved), channel+"."+key) {
+		t.Error("the saved web client doesn't have the relay URL and pairing built in")
+	}
+
+	resp, err := http.Post(server.URL+"/c/short/up", "text/plain", strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("mailboxes can't be used from the saved page's origin")
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("short channel ID: status %d, want 404", resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/app: status %d", resp.StatusCode)
+	}
+}
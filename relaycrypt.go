@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// End-to-end encrypted relay transfers (`ccc send --encrypt`). The key only
+// travels in the link's #fragment, which browsers never send. Each stream
+// starts with a random salt, and the chunks after it are sealed with
+// AES-256-GCM under HMAC-SHA256(key, salt), so a file that changes between
+// downloads is never encrypted twice under the same key and nonce. The file
+// is split into e2eChunkSize chunks, and the nonce is the chunk index (8
+// bytes, big endian), 3 zero bytes and a final flag, so chunks can't be
+// reordered, dropped or cut off at the end.
+const (
+	e2eChunkSize = 64 * 1024
+	e2eOverhead  = 16 // GCM tag per chunk
+	e2eSaltSize  = 16
+)
+
+// newE2EKey returns a random transfer key
+func newE2EKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// e2eKeyText encodes a key for a link fragment
+func e2eKeyText(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// e2eSubkey is the key one stream's chunks are sealed with
+func e2eSubkey(key, salt []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(salt)
+	return mac.Sum(nil)
+}
+
+func newE2EAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func e2eNonce(index uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, index)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptedSize is the size of n bytes once encrypted, salt included; empty
+// input is one empty final chunk
+func encryptedSize(n int64) int64 {
+	chunks := (n + e2eChunkSize - 1) / e2eChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return e2eSaltSize + n + chunks*e2eOverhead
+}
+
+// e2eSalt picks the salt of each encrypted stream of one item: a fresh one
+// for a download from the start, and the previous one to resume a download,
+// unless the file has changed since
+type e2eSalt struct {
+	salt  []byte
+	stamp string
+}
+
+func (s *e2eSalt) next(path string, offset int64) []byte {
+	stamp := ""
+	if st, err := os.Stat(path); err == nil {
+		stamp = fmt.Sprintf("%d/%d", st.Size(), st.ModTime().UnixNano())
+	}
+	if offset == 0 || s.salt == nil || stamp == "" || stamp != s.stamp {
+		s.salt = make([]byte, e2eSaltSize)
+		rand.Read(s.salt)
+	}
+	s.stamp = stamp
+	return s.salt
+}
+
+// e2eReader encrypts a plaintext stream chunk by chunk
+type e2eReader struct {
+	src   *bufio.Reader
+	aead  cipher.AEAD
+	index uint64
+	buf   []byte
+	out   []byte
+	done  bool
+}
+
+// newE2EReader encrypts src, which starts at chunk index, under a stream's subkey
+func newE2EReader(src io.Reader, key []byte, index uint64) (*e2eReader, error) {
+	aead, err := newE2EAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &e2eReader{src: bufio.NewReaderSize(src, e2eChunkSize), aead: aead, index: index, buf: make([]byte, e2eChunkSize)}, nil
+}
+
+func (r *e2eReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(r.src, r.buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		final := err != nil
+		if !final {
+			if _, err := r.src.Peek(1); err == io.EOF {
+				final = true
+			} else if err != nil {
+				return 0, err
+			}
+		}
+		r.out = r.aead.Seal(nil, e2eNonce(r.index, final), r.buf[:n], nil)
+		r.index++
+		r.done = final
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// openEncrypted returns the ciphertext of an item from offset on, under the
+// item's Salt (a fresh one when it has none). It encrypts from the chunk
+// holding offset and skips to it.
+func openEncrypted(it relayItem, offset int64) (io.ReadCloser, error) {
+	salt := it.Salt
+	if salt == nil {
+		salt = make([]byte, e2eSaltSize)
+		rand.Read(salt)
+	}
+	var header []byte
+	if offset < e2eSaltSize {
+		header = salt[offset:]
+		offset = 0
+	} else {
+		offset -= e2eSaltSize
+	}
+	index := offset / (e2eChunkSize + e2eOverhead)
+	plain := relayItem{Path: it.Path, Name: it.Name, Size: it.Size, Dir: it.Dir}
+	src, err := plain.open(index * e2eChunkSize)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := newE2EReader(src, e2eSubkey(it.Key, salt), uint64(index))
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	if _, err := io.CopyN(io.Discard, enc, offset-index*(e2eChunkSize+e2eOverhead)); err != nil {
+		src.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(header), enc), src}, nil
+}
+
+// writeDecrypted writes the plaintext of an encrypted stream to w, failing
+// on an altered, reordered or missing chunk. It returns the bytes written.
+func writeDecrypted(w io.Writer, r io.Reader, key []byte) (int64, error) {
+	salt := make([]byte, e2eSaltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return 0, fmt.Errorf("download cut off: %w", err)
+	}
+	aead, err := newE2EAEAD(e2eSubkey(key, salt))
+	if err != nil {
+		return 0, err
+	}
+	src := bufio.NewReaderSize(r, e2eChunkSize+e2eOverhead)
+	buf := make([]byte, e2eChunkSize+e2eOverhead)
+	var written int64
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(src, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return written, err
+		}
+		final := err != nil
+		if !final {
+			if _, err := src.Peek(1); err == io.EOF {
+				final = true
+			} else if err != nil {
+				return written, err
+			}
+		}
+		plain, err := aead.Open(buf[:0], e2eNonce(index, final), buf[:n], nil)
+		if err != nil {
+			return written, fmt.Errorf("could not decrypt: the link is wrong or the download was cut off")
+		}
+		m, err := w.Write(plain)
+		written += int64(m)
+		if err != nil || final {
+			return written, err
+		}
+	}
+}
+
+// receiveEncrypted downloads an encrypted link (`ccc receive <link>`) into
+// the current directory and decrypts it here, so unlike the relay's page it
+// doesn't trust the relay with anything but the ciphertext
+func receiveEncrypted(link string) error {
+	u, err := url.Parse(link)
+	if err != nil {
+		return fmt.Errorf("invalid link: %w", err)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/e/"), "/", 2)
+	if !strings.HasPrefix(u.Path, "/e/") || len(parts) != 2 || u.Fragment == "" {
+		return fmt.Errorf("not an encrypted link: expected <relay>/e/<token>/<name>#<key>")
+	}
+	key, err := base64.RawURLEncoding.DecodeString(u.Fragment)
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("the link's key (the part after #) is invalid")
+	}
+	name := filepath.Base(parts[1])
+	if name == "." || name == ".." || name == "/" {
+		return fmt.Errorf("the link has no file name")
+	}
+	if _, err := os.Stat(name); err == nil {
+		return fmt.Errorf("%s already exists", name)
+	}
+
+	download := *u
+	download.Path, download.RawPath, download.Fragment = "/d/"+parts[0]+"/"+parts[1], "", ""
+	fmt.Printf("📥 Downloading %s...\n", name)
+	resp, err := http.Get(download.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		return fmt.Errorf("relay: %s", strings.TrimSpace(string(body)))
+	}
+
+	tmp, err := os.CreateTemp(".", "."+name+".*")
+	if err != nil {
+		return err
+	}
+	n, err := writeDecrypted(tmp, resp.Body, key)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	fmt.Printf("✅ Saved %s (%s)\n", name, formatFileSize(n))
+	return nil
+}
+
+var decryptPage = template.Must(template.New("decrypt").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Name}}</title>
+<style>body{font-family:system-ui,sans-serif;max-width:40em;margin:2em auto;padding:0 1em}</style>
+</head><body>
+<h2>🔒 {{.Name}}</h2>
+<p id="status">Decrypting in your browser with the key from the link.</p>
+<p><small>This page comes from the relay. To decrypt without trusting it, run <code>ccc receive '&lt;link&gt;'</code> instead.</small></p>
+<script>
+(async function() {
+  var status = document.getElementById('status');
+  try {
+    var keyText = location.hash.slice(1).replace(/-/g, '+').replace(/_/g, '/');
+    if (!keyText) { status.textContent = '❌ The link is missing its key (the part after #)'; return; }
+    var raw = Uint8Array.from(atob(keyText), function(c) { return c.charCodeAt(0); });
+    var mac = await crypto.subtle.importKey('raw', raw, {name: 'HMAC', hash: 'SHA-256'}, false, ['sign']);
+    status.textContent = 'Downloading…';
+    var resp = await fetch(location.pathname.replace('/e/', '/d/'));
+    if (!resp.ok) { status.textContent = '❌ ' + await resp.text(); return; }
+    // Decrypt each chunk as it arrives; one is held back until the next
+    // shows the stream goes on, since only the last is sealed as final
+    var reader = resp.body.getReader(), chunk = {{.ChunkSize}}, saltSize = {{.SaltSize}};
+    var buf = new Uint8Array(0), key = null, parts = [], i = 0, done = false, received = 0;
+    async function open(data, final) {
+      var iv = new Uint8Array(12);
+      new DataView(iv.buffer).setBigUint64(0, BigInt(i++));
+      if (final) iv[11] = 1;
+      parts.push(await crypto.subtle.decrypt({name: 'AES-GCM', iv: iv}, key, data));
+    }
+    while (!done) {
+      var r = await reader.read();
+      done = r.done;
+      if (r.value) {
+        var joined = new Uint8Array(buf.length + r.value.length);
+        joined.set(buf);
+        joined.set(r.value, buf.length);
+        buf = joined;
+        received += r.value.length;
+        status.textContent = 'Downloading and decrypting… ' + (received / 1048576).toFixed(1) + ' MB';
+      }
+      if (!key && buf.length >= saltSize) {
+        var sub = await crypto.subtle.sign('HMAC', mac, buf.slice(0, saltSize));
+        key = await crypto.subtle.importKey('raw', sub, 'AES-GCM', false, ['decrypt']);
+        buf = buf.slice(saltSize);
+      }
+      while (key && buf.length > chunk) {
+        await open(buf.slice(0, chunk), false);
+        buf = buf.slice(chunk);
+      }
+    }
+    if (!key) throw new Error('cut off');
+    await open(buf, true);
+    var a = document.createElement('a');
+    a.href = URL.createObjectURL(new Blob(parts));
+    a.download = {{.Name}};
+    a.textContent = 'Save ' + {{.Name}};
+    status.textContent = '✅ Decrypted. ';
+    status.appendChild(a);
+    a.click();
+  } catch (e) {
+    status.textContent = '❌ Could not decrypt: the link is wrong or the download was cut off';
+  }
+})();
+</script>
+</body></html>
+`))
+
+// handleRelayE2E adds /e/<token>/<name> to a relay: a page that downloads
+// /d/<token>/<name> and decrypts it with the key in the link's fragment
+func handleRelayE2E(mux *http.ServeMux) {
+	mux.HandleFunc("/e/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/e/"), "/", 2)
+		relayTransfers.RLock()
+		t, exists := relayTransfers.transfers[parts[0]]
+		relayTransfers.RUnlock()
+		if !exists || t.Upload {
+			http.Error(w, "File not found - sender may have disconnected", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		decryptPage.Execute(w, struct {
+			Name      string
+			ChunkSize int
+			SaltSize  int
+		}{t.Filename, e2eChunkSize + e2eOverhead, e2eSaltSize})
+	})
+}
+
+// relayLinkURL is the link posted for an item: the plain download link, or
+// the decrypt page with the key in the fragment
+func relayLinkURL(relayURL, token string, item relayItem) string {
+	if item.Key == nil {
+		return relayDownloadURL(relayURL, token, item.Name)
+	}
+	return fmt.Sprintf("%s/e/%s/%s#%s", relayURL, token, url.PathEscape(item.Name), e2eKeyText(item.Key))
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decryptE2E reverses the chunked encryption the way the browser page does:
+// the salt, then chunks under the subkey
+func decryptE2E(t *testing.T, data, key []byte) []byte {
+	t.Helper()
+	block, _ := aes.NewCipher(e2eSubkey(key, data[:e2eSaltSize]))
+	aead, _ := cipher.NewGCM(block)
+	data = data[e2eSaltSize:]
+	var out []byte
+	for off, i := 0, uint64(0); off < len(data); off, i = off+e2eChunkSize+e2eOverhead, i+1 {
+		end := off + e2eChunkSize + e2eOverhead
+		if end > len(data) {
+			end = len(data)
+		}
+		plain, err := aead.Open(nil, e2eNonce(i, end == len(data)), data[off:end], nil)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		out = append(out, plain...)
+	}
+	return out
+}
+
+func TestE2EReaderRoundTrip(t *testing.T) {
+	key := newE2EKey()
+	for _, n := range []int{0, 10, e2eChunkSize, e2eChunkSize + 1, 3*e2eChunkSize + 123} {
+		plain := bytes.Repeat([]byte{'x'}, n)
+		salt := bytes.Repeat([]byte{byte(n)}, e2eSaltSize)
+		r, err := newE2EReader(bytes.NewReader(plain), e2eSubkey(key, salt), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc = append(salt, enc...)
+		if int64(len(enc)) != encryptedSize(int64(n)) {
+			t.Errorf("n=%d: %d encrypted bytes, encryptedSize says %d", n, len(enc), encryptedSize(int64(n)))
+		}
+		if got := decryptE2E(t, enc, key); !bytes.Equal(got, plain) {
+			t.Errorf("n=%d: round trip lost data", n)
+		}
+		var out bytes.Buffer
+		if _, err := writeDecrypted(&out, bytes.NewReader(enc), key); err != nil || !bytes.Equal(out.Bytes(), plain) {
+			t.Errorf("n=%d: writeDecrypted = %v", n, err)
+		}
+		if n > 0 {
+			if _, err := writeDecrypted(io.Discard, bytes.NewReader(enc[:len(enc)-1]), key); err == nil {
+				t.Errorf("n=%d: a cut off stream decrypted", n)
+			}
+		}
+	}
+}
+
+func TestOpenEncryptedResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.bin")
+	plain := bytes.Repeat([]byte("0123456789abcdef"), 3*e2eChunkSize/16+50)
+	os.WriteFile(path, plain, 0644)
+	item, _ := newRelayItem(path)
+	item.Key = newE2EKey()
+	var salts e2eSalt
+	item.Salt = salts.next(path, 0)
+
+	rc, _ := item.open(0)
+	full, _ := io.ReadAll(rc)
+	rc.Close()
+	if int64(len(full)) != item.relaySize() {
+		t.Fatalf("ciphertext %d bytes, relaySize %d", len(full), item.relaySize())
+	}
+	if got := decryptE2E(t, full, item.Key); !bytes.Equal(got, plain) {
+		t.Fatal("round trip lost data")
+	}
+	for _, offset := range []int64{1, e2eSaltSize, e2eSaltSize + e2eChunkSize + e2eOverhead, e2eChunkSize + 100, int64(len(full)) - 3} {
+		if salt := salts.next(path, offset); !bytes.Equal(salt, item.Salt) {
+			t.Fatalf("resuming at %d changed the salt", offset)
+		}
+		rc, err := item.open(offset)
+		if err != nil {
+			t.Fatalf("open(%d): %v", offset, err)
+		}
+		rest, _ := io.ReadAll(rc)
+		rc.Close()
+		if !bytes.Equal(rest, full[offset:]) {
+			t.Errorf("open(%d) doesn't match the tail of the full ciphertext", offset)
+		}
+	}
+
+	// Cutting off the final chunk must not decrypt
+	truncated := full[e2eSaltSize : e2eSaltSize+2*(e2eChunkSize+e2eOverhead)]
+	block, _ := aes.NewCipher(e2eSubkey(item.Key, item.Salt))
+	aead, _ := cipher.NewGCM(block)
+	if _, err := aead.Open(nil, e2eNonce(1, true), truncated[e2eChunkSize+e2eOverhead:], nil); err == nil {
+		t.Error("a truncated stream decrypted as complete")
+	}
+
+	// A new download, or resuming one after the file changed, gets a new salt
+	if bytes.Equal(salts.next(path, 0), item.Salt) {
+		t.Error("a download from the start reused the salt")
+	}
+	first := salts.next(path, 100)
+	os.WriteFile(path, append(plain, 'x'), 0644)
+	os.Chtimes(path, time.Now().Add(time.Hour), time.Now().Add(time.Hour))
+	if bytes.Equal(salts.next(path, 100), first) {
+		t.Error("resuming a changed file reused the salt")
+	}
+}
+
+func TestRelayDecryptPage(t *testing.T) {
+	server := httptest.NewServer(newRelayMux(relayOptions{}))
+	defer server.Close()
+
+	token := "e2ee0123456789abcdef0123456789ab"
+	item := relayItem{Name: "app.apk", Size: 100, Key: newE2EKey()}
+	if err := registerRelayTransfer(server.URL, "", token, item); err != nil {
+		t.Fatal(err)
+	}
+	defer cancelRelayTransfer(server.URL, "", token)
+
+	link := relayLinkURL(server.URL, token, item)
+	if !strings.HasPrefix(link, server.URL+"/e/"+token+"/app.apk#") || !strings.HasSuffix(link, e2eKeyText(item.Key)) {
+		t.Errorf("link = %s", link)
+	}
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(page), "crypto.subtle") || !strings.Contains(string(page), `"app.apk"`) {
+		t.Errorf("decrypt page = %s", page)
+	}
+	if strings.Contains(string(page), "arrayBuffer") || !strings.Contains(string(page), "getReader") {
+		t.Error("the decrypt page should decrypt the stream as it arrives")
+	}
+	if relayLinkURL(server.URL, token, relayItem{Name: "a b"}) != server.URL+"/d/"+token+"/a%20b" {
+		t.Error("plain items should keep the download link")
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Relay backend: sessions are driven from a web client talking to a relay
+// running `ccc relay --control`, for networks where Telegram is blocked.
+// Messages go through the control channel in ControlChannel, sealed with
+// ControlKey, so the relay only queues ciphertext. The client's script must
+// not come from a relay that isn't trusted, or it could read the key: `ccc
+// pair` saves a copy of the page, and the relay's own /app is only offered
+// for relays other than the public default. Topic and message IDs are made
+// up here (newLocalID), since there is no server to assign them.
+
+const (
+	// controlMaxLen splits long messages well under controlMaxMessage once
+	// sealed and base64 encoded
+	controlMaxLen = 16000
+	// controlMaxFile is the largest file sent inline to the web client
+	controlMaxFile = 64 * 1024
+	// controlMaxAge drops messages from the client older than this, so the
+	// relay can't replay them later
+	controlMaxAge = 5 * time.Minute
+)
+
+// controlMessage is the plaintext of an envelope. Down (listener → client):
+// message, edit, file, sessions. Up (client → listener): text, button,
+// sessions.
+type controlMessage struct {
+	Type     string                   `json:"type"`
+	ID       string                   `json:"id,omitempty"`   // up: random, for replay protection
+	Time     int64                    `json:"time,omitempty"` // up: Unix milliseconds sent
+	Topic    int64                    `json:"topic,omitempty"`
+	Message  int64                    `json:"message,omitempty"`
+	Text     string                   `json:"text,omitempty"`
+	Buttons  [][]InlineKeyboardButton `json:"buttons,omitempty"`
+	Data     string                   `json:"data,omitempty"` // button callback data, or base64 file contents
+	Name     string                   `json:"name,omitempty"` // file name
+	Sessions []controlSession         `json:"sessions,omitempty"`
+}
+
+// controlSession is a session as listed to the web client
+type controlSession struct {
+	Session string `json:"session"`
+	Topic   int64  `json:"topic"`
+}
+
+var controlClient = &http.Client{Timeout: controlPollWait + 10*time.Second}
+
+// newControlPairing returns a random channel ID and key for `ccc pair`
+func newControlPairing() (channel, key string) {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return base64.RawURLEncoding.EncodeToString(id), e2eKeyText(newE2EKey())
+}
+
+// controlLink is the pairing link: the relay's web client with the channel
+// and key in the fragment, which the browser never sends to the relay
+func controlLink(config *Config) string {
+	return fmt.Sprintf("%s/app#%s.%s", relayURLFor(config), config.ControlChannel, config.ControlKey)
+}
+
+// controlAppPath is where `ccc pair` saves the web client
+func controlAppPath() string {
+	return stateFile("-app.html")
+}
+
+// writeControlApp saves the web client with the relay URL, channel and key
+// built in, to be opened from the file instead of the relay's /app
+func writeControlApp(config *Config, path string) error {
+	var page bytes.Buffer
+	if err := controlAppPage.Execute(&page, controlAppData{
+		Relay:   relayURLFor(config),
+		Pairing: config.ControlChannel + "." + config.ControlKey,
+	}); err != nil {
+		return err
+	}
+	return os.WriteFile(path, page.Bytes(), 0600)
+}
+
+func controlAEAD(keyText string) (cipher.AEAD, error) {
+	key, err := base64.RawURLEncoding.DecodeString(keyText)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("invalid control key. Run: ccc pair --reset")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealControl encrypts a message for a mailbox: base64url of a random
+// 12-byte nonce and the AES-GCM ciphertext, with "<channel>/<box>" as
+// additional data so an envelope can't be moved to another mailbox
+func sealControl(keyText, channel, box string, plain []byte) (string, error) {
+	aead, err := controlAEAD(keyText)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	sealed := aead.Seal(nonce, nonce, plain, []byte(channel+"/"+box))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openControl decrypts an envelope from sealControl
+func openControl(keyText, channel, box, data string) ([]byte, error) {
+	aead, err := controlAEAD(keyText)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed envelope")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, sealed[:n], sealed[n:], []byte(channel+"/"+box))
+}
+
+// postControl seals a message and queues it in a mailbox
+func postControl(config *Config, box string, msg controlMessage) error {
+	plain, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data, err := sealControl(config.ControlKey, config.ControlChannel, box, plain)
+	if err != nil {
+		return err
+	}
+	resp, err := controlClient.Post(relayURLFor(config)+"/c/"+config.ControlChannel+"/"+box, "text/plain", strings.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("relay: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// pollControl waits for the envelopes in a mailbox after seq
+func pollControl(config *Config, box string, after int64) ([]controlEnvelope, error) {
+	resp, err := controlClient.Get(fmt.Sprintf("%s/c/%s/%s?after=%d", relayURLFor(config), config.ControlChannel, box, after))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("relay: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var result struct {
+		Messages []controlEnvelope `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("relay: bad response: %w", err)
+	}
+	return result.Messages, nil
+}
+
+// relayMessenger delivers messages to the web client through the control channel
+type relayMessenger struct {
+	config *Config
+}
+
+func (m *relayMessenger) Send(chatID, threadID int64, text string) error {
+	_, err := m.SendGetID(chatID, threadID, text)
+	return err
+}
+
+func (m *relayMessenger) SendGetID(chatID, threadID int64, text string) (int64, error) {
+	var lastMsgID int64
+	for _, part := range splitMessage(text, controlMaxLen) {
+		id := newLocalID()
+		if err := postControl(m.config, "down", controlMessage{Type: "message", Topic: threadID, Message: id, Text: part}); err != nil {
+			return 0, err
+		}
+		lastMsgID = id
+	}
+	return lastMsgID, nil
+}
+
+// Edit replaces a message's text, sending overflow as new messages
+func (m *relayMessenger) Edit(chatID, messageID, threadID int64, text string) error {
+	parts := splitMessage(text, controlMaxLen)
+	if err := postControl(m.config, "down", controlMessage{Type: "edit", Topic: threadID, Message: messageID, Text: parts[0]}); err != nil {
+		return err
+	}
+	for _, part := range parts[1:] {
+		if err := m.Send(chatID, threadID, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendFormatted sends text as is: the web client shows it preformatted
+func (m *relayMessenger) SendFormatted(chatID, threadID int64, text string) (int64, error) {
+	return m.SendGetID(chatID, threadID, text)
+}
+
+func (m *relayMessenger) EditFormatted(chatID, messageID, threadID int64, text string) error {
+	return m.Edit(chatID, messageID, threadID, text)
+}
+
+func (m *relayMessenger) SendWithKeyboard(chatID, threadID int64, text string, buttons [][]InlineKeyboardButton) error {
+	return postControl(m.config, "down", controlMessage{Type: "message", Topic: threadID, Message: newLocalID(), Text: truncate(text, controlMaxLen), Buttons: buttons})
+}
+
+// SendFile sends small files inline, to be saved from the web client.
+// Larger ones need Telegram or `ccc send` through a plain relay link.
+func (m *relayMessenger) SendFile(chatID, threadID int64, filePath string, caption string) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	if info.Size() > controlMaxFile {
+		return fmt.Errorf("%s is %s; the relay backend sends files up to %s", filepath.Base(filePath), formatFileSize(info.Size()), formatFileSize(controlMaxFile))
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	return postControl(m.config, "down", controlMessage{
+		Type:    "file",
+		Topic:   threadID,
+		Message: newLocalID(),
+		Text:    caption,
+		Name:    filepath.Base(filePath),
+		Data:    base64.RawURLEncoding.EncodeToString(data),
+	})
+}
+
+// CreateTopic makes up a topic ID; the web client learns the session from
+// the next session list, sent once the listener sees it saved
+func (m *relayMessenger) CreateTopic(name string) (int64, error) {
+	if m.config.ControlChannel == "" {
+		return 0, fmt.Errorf("no control channel. Run: ccc pair")
+	}
+	return newLocalID(), nil
+}
+
+// DeleteTopic has nothing to delete: the session drops out of the next list
+func (m *relayMessenger) DeleteTopic(topicID int64) error {
+	return nil
+}
+
+// controlSessions lists the sessions with a topic, by name
+func controlSessions(config *Config) []controlSession {
+	var list []controlSession
+	for name, info := range config.Sessions {
+		if info != nil && info.TopicID != 0 {
+			list = append(list, controlSession{Session: name, Topic: info.TopicID})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Session < list[j].Session })
+	return list
+}
+
+// sendControlSessions sends the session list to the web client
+func sendControlSessions(config *Config) error {
+	return postControl(config, "down", controlMessage{Type: "sessions", Sessions: controlSessions(config)})
+}
+
+// controlReplays remembers the IDs of recent messages from the client
+type controlReplays map[string]time.Time
+
+// fresh reports whether a message from the client is new: recent and not
+// seen before. Old IDs are forgotten once their messages would be too old.
+func (seen controlReplays) fresh(msg controlMessage, now time.Time) bool {
+	sent := time.UnixMilli(msg.Time)
+	if msg.ID == "" || now.Sub(sent) > controlMaxAge || sent.Sub(now) > controlMaxAge {
+		return false
+	}
+	for id, t := range seen {
+		if now.Sub(t) > 2*controlMaxAge {
+			delete(seen, id)
+		}
+	}
+	if _, dup := seen[msg.ID]; dup {
+		return false
+	}
+	seen[msg.ID] = now
+	return true
+}
+
+// listenRelay polls the control channel for the web client's messages until killed
+func listenRelay(config *Config) error {
+	if config.ControlChannel == "" || config.ControlKey == "" {
+		return fmt.Errorf("relay not paired. Run: ccc pair")
+	}
+	if _, err := controlAEAD(config.ControlKey); err != nil {
+		return err
+	}
+
+	fmt.Printf("Relay control listening... (%s)\n", relayURLFor(config))
+	fmt.Printf("Active sessions: %d\n", len(config.Sessions))
+
+	go startSessionMonitor(config)
+	go startScheduler()
+	go startControlServer()
+
+	seen, err := loadControlReplays()
+	if err != nil {
+		hookLog("relay control: loading seen messages failed: %v", err)
+		seen = make(controlReplays)
+	}
+	var after int64
+	var listed string
+	for {
+		// Send the session list whenever sessions come or go
+		config, _ = loadConfig()
+		if sessions, _ := json.Marshal(controlSessions(config)); string(sessions) != listed {
+			if err := sendControlSessions(config); err != nil {
+				hookLog("relay control: sending sessions failed: %v", err)
+			} else {
+				listed = string(sessions)
+			}
+		}
+
+		envs, err := pollControl(config, "up", after)
+		if err != nil {
+			hookLog("relay control: poll failed: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		for _, env := range envs {
+			after = env.Seq
+			plain, err := openControl(config.ControlKey, config.ControlChannel, "up", env.Data)
+			if err != nil {
+				hookLog("relay control: dropping envelope %d: %v", env.Seq, err)
+				continue
+			}
+			var msg controlMessage
+			now := time.Now()
+			if json.Unmarshal(plain, &msg) != nil || !seen.fresh(msg, now) {
+				continue
+			}
+			if err := recordControlReplay(msg.ID, now); err != nil {
+				hookLog("relay control: saving seen message failed: %v", err)
+			}
+			go handleControlMessage(config, msg)
+		}
+	}
+}
+
+// handleControlMessage acts on a message from the web client
+func handleControlMessage(config *Config, msg controlMessage) {
+	msgr := getMessenger(config)
+	switch msg.Type {
+	case "sessions":
+		sendControlSessions(config)
+
+	case "text":
+		text := strings.TrimSpace(msg.Text)
+		if text == "" {
+			return
+		}
+		if msg.Topic == 0 {
+			msgr.Send(0, 0, "Pick a session above to talk to it. Sessions are started from the terminal: ccc start <name> <dir> <prompt>")
+			return
+		}
+		sessName := getSessionByTopic(config, msg.Topic)
+		if sessName == "" {
+			msgr.Send(0, msg.Topic, "⚠️ No session linked to this topic.")
+			return
+		}
+		forwardToSession(config, msgr, 0, msg.Topic, sessName, text)
+
+	case "button":
+		if strings.HasPrefix(msg.Data, permissionCallbackPrefix) {
+			if label, ok := handlePermissionCallback(msg.Data); ok {
+				msgr.Edit(0, msg.Message, msg.Topic, msg.Text+"\n\n"+label)
+			}
+			return
+		}
+		qc, ok := parseQuestionCallback(msg.Data)
+		if !ok {
+			return
+		}
+		msgr.Edit(0, msg.Message, msg.Topic, fmt.Sprintf("%s\n\n✓ Selected option %d", msg.Text, qc.OptionIndex+1))
+		answerQuestion(qc)
+	}
+}
+
+// pairRelay implements `ccc pair [--reset]`: create the control channel if
+// needed and print the link that opens the web client
+func pairRelay(config *Config, reset bool) error {
+	if config.ControlChannel == "" || config.ControlKey == "" || reset {
+		config.ControlChannel, config.ControlKey = newControlPairing()
+		if err := saveConfig(config); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+	}
+	path := controlAppPath()
+	if err := writeControlApp(config, path); err != nil {
+		return fmt.Errorf("failed to save the web client: %w", err)
+	}
+	fmt.Println("Web client saved to (keep it private: it holds the key):")
+	fmt.Println()
+	fmt.Println("  " + path)
+	fmt.Println()
+	fmt.Println("Open it in a browser on your phone or laptop. It talks to the relay, which")
+	fmt.Println("only sees ciphertext.")
+	if relayURLFor(config) != defaultRelayURL {
+		fmt.Println()
+		fmt.Println("The relay serves the client too, but its script then comes from the relay,")
+		fmt.Println("which could read the key. Only if you run the relay yourself:")
+		fmt.Println()
+		fmt.Println("  " + controlLink(config))
+	}
+	fmt.Println()
+	if configuredMessenger(config) != messengerRelay {
+		fmt.Println("Then switch to it: ccc config messenger relay (and restart the listener)")
+	}
+	fmt.Printf("The relay at %s must run with: ccc relay --control\n", relayURLFor(config))
+	return nil
+}
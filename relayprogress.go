@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// relayProgressInterval is how often a relay link message is edited with progress
+const relayProgressInterval = 3 * time.Second
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func (c *countingReader) count() int64 { return atomic.LoadInt64(&c.n) }
+
+// relayProgress keeps a relay link message up to date with the transfer:
+// live progress while streaming, then how many clients downloaded it
+type relayProgress struct {
+	config    *Config
+	chatID    int64
+	threadID  int64
+	messageID int64
+	text      string // the link message; status lines go below it
+	name      string
+	size      int64 // 0 when unknown (a zipped directory)
+}
+
+func (p *relayProgress) edit(status string) {
+	editMessage(p.config, p.chatID, p.messageID, p.threadID, p.text+"\n\n"+status)
+}
+
+// track edits the message with the progress of one download every few
+// seconds until the returned stop func is called
+func (p *relayProgress) track(counter *countingReader, offset int64) (stop func()) {
+	if p == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	started := time.Now()
+	go func() {
+		ticker := time.NewTicker(relayProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				p.edit(formatRelayProgress(p.name, offset+counter.count(), p.size, counter.count(), time.Since(started)))
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// setChecksum adds the file's SHA-256 to the link message
+func (p *relayProgress) setChecksum(sum string) {
+	if p != nil {
+		p.text += "\n🔐 SHA-256: " + sum
+	}
+}
+
+// downloaded notes a finished download; the link stays open for more
+func (p *relayProgress) downloaded(count int) {
+	if p != nil {
+		p.edit(formatDownloadCount(count, false))
+	}
+}
+
+// finish records the final count once the link has expired
+func (p *relayProgress) finish(count int) {
+	if p != nil {
+		p.edit(formatDownloadCount(count, true))
+	}
+}
+
+// formatRelayProgress renders "📦 app.apk — 45% · 12.0 MB/s · ETA 40s".
+// done is where the transfer is in the file, sent what this download has
+// moved in elapsed (they differ when it resumed). Without a size only the
+// bytes sent and the rate are shown.
+func formatRelayProgress(name string, done, size, sent int64, elapsed time.Duration) string {
+	rate := float64(0)
+	if elapsed > 0 {
+		rate = float64(sent) / elapsed.Seconds()
+	}
+	speed := formatFileSize(int64(rate)) + "/s"
+	if size <= 0 {
+		return fmt.Sprintf("📦 %s — %s · %s", name, formatFileSize(done), speed)
+	}
+	line := fmt.Sprintf("📦 %s — %d%% · %s", name, done*100/size, speed)
+	if rate > 0 {
+		eta := time.Duration(float64(size-done)/rate) * time.Second
+		if eta < time.Minute {
+			line += fmt.Sprintf(" · ETA %ds", int(eta.Seconds()))
+		} else {
+			line += " · ETA " + formatDuration(eta)
+		}
+	}
+	return line
+}
+
+// formatDownloadCount renders how many clients downloaded a relay link
+func formatDownloadCount(count int, expired bool) string {
+	switch {
+	case count == 0 && expired:
+		return "⏰ Link expired without a download"
+	case count == 0:
+		return ""
+	}
+	clients := "client"
+	if count > 1 {
+		clients = "clients"
+	}
+	line := fmt.Sprintf("✅ Downloaded by %d %s", count, clients)
+	if expired {
+		line += " · link expired"
+	}
+	return line
+}
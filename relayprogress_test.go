@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatRelayProgress(t *testing.T) {
+	const mb = 1024 * 1024
+	tests := []struct {
+		done, size, sent int64
+		elapsed          time.Duration
+		want             string
+	}{
+		{45 * mb, 100 * mb, 45 * mb, 5 * time.Second, "📦 app.apk — 45% · 9.0 MB/s · ETA 6s"},
+		{30 * mb, 100 * mb, 10 * mb, 10 * time.Second, "📦 app.apk — 30% · 1.0 MB/s · ETA 1m"},
+		{30 * mb, 0, 30 * mb, 10 * time.Second, "📦 app.apk — 30.0 MB · 3.0 MB/s"},
+		{0, 100 * mb, 0, 0, "📦 app.apk — 0% · 0.0 KB/s"},
+	}
+	for _, tt := range tests {
+		if got := formatRelayProgress("app.apk", tt.done, tt.size, tt.sent, tt.elapsed); got != tt.want {
+			t.Errorf("formatRelayProgress(%d, %d, %d, %v) = %q, want %q", tt.done, tt.size, tt.sent, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDownloadCount(t *testing.T) {
+	tests := []struct {
+		count   int
+		expired bool
+		want    string
+	}{
+		{0, false, ""},
+		{0, true, "⏰ Link expired without a download"},
+		{1, false, "✅ Downloaded by 1 client"},
+		{3, true, "✅ Downloaded by 3 clients · link expired"},
+	}
+	for _, tt := range tests {
+		if got := formatDownloadCount(tt.count, tt.expired); got != tt.want {
+			t.Errorf("formatDownloadCount(%d, %v) = %q, want %q", tt.count, tt.expired, got, tt.want)
+		}
+	}
+}
+
+func TestCountingReader(t *testing.T) {
+	c := &countingReader{r: strings.NewReader("hello world")}
+	io.Copy(io.Discard, c)
+	if c.count() != 11 {
+		t.Errorf("count = %d, want 11", c.count())
+	}
+	// A nil progress is a no-op
+	var p *relayProgress
+	p.track(c, 0)()
+	p.downloaded(1)
+	p.finish(1)
+}
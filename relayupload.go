@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// relayUploadStall is how long an upload waits for ccc to take the next
+// chunk (or to pick the upload up at all) before giving up
+const relayUploadStall = 2 * time.Minute
+
+var uploadPage = template.Must(template.New("upload").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Upload to ccc</title>
+<style>body{font-family:system-ui,sans-serif;max-width:40em;margin:2em auto;padding:0 1em}progress{width:100%}</style>
+</head><body>
+<h2>📥 Upload a file to your session</h2>
+<p><input type="file" id="file"> <button onclick="upload()">Upload</button></p>
+<progress id="progress" max="1" value="0" hidden></progress>
+<p id="status"></p>
+<script>
+function upload() {
+  var file = document.getElementById('file').files[0];
+  if (!file) return;
+  var progress = document.getElementById('progress'), status = document.getElementById('status');
+  var xhr = new XMLHttpRequest();
+  xhr.open('POST', location.pathname);
+  xhr.setRequestHeader('X-Filename', encodeURIComponent(file.name));
+  xhr.upload.onprogress = function(e) { if (e.lengthComputable) progress.value = e.loaded / e.total; };
+  xhr.onload = function() { status.textContent = xhr.status == 200 ? '✅ Uploaded' : '❌ ' + xhr.responseText; };
+  xhr.onerror = function() { status.textContent = '❌ Upload failed, try again'; };
+  progress.hidden = false;
+  status.textContent = 'Uploading ' + file.name + '...';
+  xhr.send(file);
+}
+</script>
+</body></html>
+`))
+
+// closeDone closes a transfer's DoneChan unless it already is; callers hold relayTransfers
+func closeDone(done chan struct{}) {
+	select {
+	case <-done:
+	default:
+		close(done)
+	}
+}
+
+// handleRelayUploads adds the upload direction to a relay: /u/<token> shows
+// an upload page and takes the browser's POST, and /pull/<token> hands that
+// stream to the ccc waiting for it. Nothing is stored, as with downloads.
+func handleRelayUploads(mux *http.ServeMux, opts relayOptions) {
+	mux.HandleFunc("/u/", func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/u/")
+		relayTransfers.Lock()
+		t, exists := relayTransfers.transfers[token]
+		if !exists || !t.Upload {
+			relayTransfers.Unlock()
+			http.Error(w, "Upload link not found - it may have expired", http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodPost {
+			relayTransfers.Unlock()
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			uploadPage.Execute(w, nil)
+			return
+		}
+		if t.Status != "waiting" {
+			relayTransfers.Unlock()
+			http.Error(w, "Another upload is in progress", http.StatusConflict)
+			return
+		}
+		if r.ContentLength < 0 {
+			relayTransfers.Unlock()
+			http.Error(w, "Content-Length required", http.StatusLengthRequired)
+			return
+		}
+		name, _ := url.PathUnescape(r.Header.Get("X-Filename"))
+		t.Filename, t.Size, t.Status = name, r.ContentLength, "ready"
+		t.DataChan = make(chan []byte, 100)
+		t.DoneChan = make(chan struct{})
+		data, done := t.DataChan, t.DoneChan
+		relayTransfers.Unlock()
+		fmt.Printf("📥 Upload started: %s (%s)\n", name, token[:8])
+
+		err := pumpUpload(r.Body, data, done)
+		close(data)
+		if err == nil {
+			// Wait for ccc to write out the last chunks
+			select {
+			case <-done:
+			case <-time.After(relayUploadStall):
+				err = fmt.Errorf("receiver stalled")
+			}
+		}
+
+		relayTransfers.Lock()
+		if err != nil {
+			t.Status = "waiting" // allow another try
+		} else {
+			t.Status = "done"
+		}
+		closeDone(done)
+		relayTransfers.Unlock()
+		if err != nil {
+			fmt.Printf("📥 Upload failed: %s (%s): %v\n", name, token[:8], err)
+			http.Error(w, "Upload failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		fmt.Printf("✅ Upload complete: %s (%s) - %d bytes\n", name, token[:8], t.Size)
+		fmt.Fprint(w, "Uploaded")
+	})
+
+	// ccc collects the upload
+	mux.HandleFunc("/pull/", func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, "/pull/")
+		if !verifyRelaySignature(opts.Secret, token, r.Header.Get(relaySignatureHeader)) {
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		relayTransfers.Lock()
+		t, exists := relayTransfers.transfers[token]
+		if !exists || !t.Upload || t.Status != "ready" {
+			relayTransfers.Unlock()
+			http.Error(w, "No upload in progress", http.StatusConflict)
+			return
+		}
+		t.Status = "streaming"
+		data, done, name, size := t.DataChan, t.DoneChan, t.Filename, t.Size
+		relayTransfers.Unlock()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("X-Filename", url.PathEscape(name))
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		flusher, _ := w.(http.Flusher)
+	pullLoop:
+		for {
+			select {
+			case <-r.Context().Done():
+				break pullLoop
+			case <-done:
+				break pullLoop
+			case chunk, ok := <-data:
+				if !ok {
+					break pullLoop
+				}
+				if _, err := w.Write(chunk); err != nil {
+					break pullLoop
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+		}
+		relayTransfers.Lock()
+		closeDone(done)
+		relayTransfers.Unlock()
+	})
+}
+
+// pumpUpload copies an upload body into the transfer's channel until it ends,
+// ccc goes away, or ccc stops reading for relayUploadStall
+func pumpUpload(body io.Reader, data chan<- []byte, done <-chan struct{}) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buf[:n])
+			select {
+			case data <- chunk:
+			case <-done:
+				return fmt.Errorf("receiver disconnected")
+			case <-time.After(relayUploadStall):
+				return fmt.Errorf("receiver not responding")
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// safeUploadName reduces an uploaded file name to a base name that can't
+// leave the destination directory
+func safeUploadName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == ".." || name == "/" || strings.TrimSpace(name) == "" {
+		return "upload"
+	}
+	return name
+}
+
+// pullUpload downloads the upload in progress into dir
+func pullUpload(relayURL, secret, token, dir string) (string, int64, error) {
+	req, err := newRelayRequest("GET", relayURL+"/pull/"+token, secret, token, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		return "", 0, fmt.Errorf("relay refused (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	name, _ := url.PathUnescape(resp.Header.Get("X-Filename"))
+	dest := uniqueFilePath(filepath.Join(dir, safeUploadName(name)))
+	f, err := os.Create(dest)
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := io.Copy(f, resp.Body)
+	f.Close()
+	if err == nil && resp.ContentLength >= 0 && n != resp.ContentLength {
+		err = fmt.Errorf("upload interrupted after %s", formatFileSize(n))
+	}
+	if err != nil {
+		os.Remove(dest)
+		return "", 0, err
+	}
+	return dest, n, nil
+}
+
+// receiveViaRelay posts a relay upload link to the session's topic and saves
+// the file uploaded through it into the session directory. Failed uploads can
+// be retried on the same link until it expires.
+func receiveViaRelay(config *Config, sessName string, info *SessionInfo) error {
+	if info.TopicID == 0 || config.GroupID == 0 {
+		return fmt.Errorf("session %s has no topic", sessName)
+	}
+	relayURL := relayURLFor(config)
+	token := newRelayToken()
+	if err := relayPost(relayURL, config.RelaySecret, token, "/register", map[string]interface{}{
+		"token":  token,
+		"upload": true,
+	}); err != nil {
+		return err
+	}
+	defer cancelRelayTransfer(relayURL, config.RelaySecret, token)
+
+	msg := fmt.Sprintf("📥 Upload a file to %s:\n%s/u/%s", sessName, relayURL, token)
+	if err := sendMessage(config, sessionGroup(config, info), info.TopicID, msg); err != nil {
+		return err
+	}
+	fmt.Printf("⏳ Upload link posted to %s, waiting (expires in 10 min)...\n", sessName)
+
+	timeout := time.After(10 * time.Minute)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timeout:
+			return fmt.Errorf("no upload within 10 min")
+		case <-ticker.C:
+			resp, err := http.Get(relayURL + "/status/" + token)
+			if err != nil {
+				continue
+			}
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+			resp.Body.Close()
+			switch string(body) {
+			case "ready":
+				fmt.Println("📥 Receiving upload...")
+				dest, n, err := pullUpload(relayURL, config.RelaySecret, token, info.Path)
+				if err != nil {
+					fmt.Printf("⚠️ %v, waiting for another try...\n", err)
+					continue
+				}
+				fmt.Printf("✅ Saved %s (%s)\n", dest, formatFileSize(n))
+				sendMessage(config, sessionGroup(config, info), info.TopicID, fmt.Sprintf("✅ Received %s (%s)", filepath.Base(dest), formatFileSize(n)))
+				return nil
+			case "cancelled", "not_found":
+				return fmt.Errorf("upload link %s", body)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSafeUploadName(t *testing.T) {
+	tests := map[string]string{
+		"report.pdf":           "report.pdf",
+		"../../etc/passwd":     "passwd",
+		`C:\Users\me\file.txt`: "file.txt",
+		"..":                   "upload",
+		"":                     "upload",
+		"/":                    "upload",
+	}
+	for in, want := range tests {
+		if got := safeUploadName(in); got != want {
+			t.Errorf("safeUploadName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRelayUpload(t *testing.T) {
+	secret := "relay-secret"
+	server := httptest.NewServer(newRelayMux(relayOptions{Secret: secret}))
+	defer server.Close()
+
+	token := "upld0123456789abcdef0123456789ab"
+	if err := relayPost(server.URL, secret, token, "/register", map[string]interface{}{"token": token, "upload": true}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	defer cancelRelayTransfer(server.URL, secret, token)
+
+	resp, err := http.Get(server.URL + "/u/" + token)
+	if err != nil {
+		t.Fatalf("page: %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(page), "<input type=\"file\"") {
+		t.Errorf("upload page = %s", page)
+	}
+	// An upload token is not a download link
+	resp, _ = http.Get(server.URL + "/d/" + token + "/x")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("download of an upload token = %d, want 404", resp.StatusCode)
+	}
+
+	content := strings.Repeat("upload data ", 10000)
+	uploaded := make(chan int)
+	go func() {
+		req, _ := http.NewRequest("POST", server.URL+"/u/"+token, strings.NewReader(content))
+		req.Header.Set("X-Filename", "my%20notes.txt")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			uploaded <- 0
+			return
+		}
+		resp.Body.Close()
+		uploaded <- resp.StatusCode
+	}()
+
+	for i := 0; ; i++ {
+		resp, err := http.Get(server.URL + "/status/" + token)
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+		status, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(status) == "ready" {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("upload never became ready (%s)", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, _, err := pullUpload(server.URL, "wrong", token, t.TempDir()); err == nil {
+		t.Error("pull with a bad signature succeeded")
+	}
+	dir := t.TempDir()
+	dest, n, err := pullUpload(server.URL, secret, token, dir)
+	if err != nil {
+		t.Fatalf("pullUpload: %v", err)
+	}
+	if dest != filepath.Join(dir, "my notes.txt") || n != int64(len(content)) {
+		t.Errorf("pullUpload = %s, %d", dest, n)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != content {
+		t.Errorf("saved %d bytes, want %d", len(data), len(content))
+	}
+	if code := <-uploaded; code != http.StatusOK {
+		t.Errorf("upload response = %d, want 200", code)
+	}
+}
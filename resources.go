@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Sessions are capped so a runaway build in one of them can't starve the
+// others. On Linux with systemd each session runs in its own scope with
+// CPUQuota and MemoryMax; elsewhere only nice applies, since ulimit -v would
+// kill Node, which reserves far more address space than it uses.
+
+var (
+	systemdScopes     bool
+	systemdScopesOnce sync.Once
+)
+
+// systemdScopeArgs returns the systemd-run invocation that starts a
+// transient scope: the system manager for root, the user manager otherwise
+func systemdScopeArgs() []string {
+	args := []string{"systemd-run"}
+	if os.Geteuid() != 0 {
+		args = append(args, "--user")
+	}
+	return append(args, "--scope", "--quiet", "--collect")
+}
+
+// canUseSystemdScopes reports whether sessions can run in systemd scopes,
+// trying it once
+func canUseSystemdScopes() bool {
+	systemdScopesOnce.Do(func() {
+		if runtime.GOOS != "linux" {
+			return
+		}
+		if _, err := exec.LookPath("systemd-run"); err != nil {
+			return
+		}
+		args := append(systemdScopeArgs(), "true")
+		systemdScopes = exec.Command(args[0], args[1:]...).Run() == nil
+	})
+	return systemdScopes
+}
+
+// hasSessionLimits reports whether any resource cap is configured
+func hasSessionLimits(config *Config) bool {
+	return config.SessionCPUPercent > 0 || config.SessionMemoryMB > 0 || config.SessionNice > 0
+}
+
+// sessionLimitPrefix returns the words put before a session's command to
+// apply the configured caps, nil if there are none
+func sessionLimitPrefix(config *Config, systemd bool) []string {
+	var prefix []string
+	if systemd && (config.SessionCPUPercent > 0 || config.SessionMemoryMB > 0) {
+		prefix = systemdScopeArgs()
+		if config.SessionCPUPercent > 0 {
+			prefix = append(prefix, "-p", fmt.Sprintf("CPUQuota=%d%%", config.SessionCPUPercent))
+		}
+		if config.SessionMemoryMB > 0 {
+			prefix = append(prefix, "-p", fmt.Sprintf("MemoryMax=%dM", config.SessionMemoryMB))
+		}
+		prefix = append(prefix, "--")
+	}
+	if config.SessionNice > 0 {
+		prefix = append(prefix, "nice", "-n", strconv.Itoa(config.SessionNice))
+	}
+	return prefix
+}
+
+// limitSessionCommand wraps the command typed into a session's shell so it
+// runs under the configured caps
+func limitSessionCommand(config *Config, command string) string {
+	if !hasSessionLimits(config) {
+		return command
+	}
+	prefix := sessionLimitPrefix(config, canUseSystemdScopes())
+	if len(prefix) == 0 {
+		return command
+	}
+	return strings.Join(prefix, " ") + " " + command
+}
+
+// processUsage is the CPU and memory use of a process tree
+type processUsage struct {
+	CPU   float64 // percent of one core
+	RSSKB int64
+}
+
+// treeUsage adds up the usage of root and its descendants from
+// `ps -A -o pid=,ppid=,rss=,%cpu=` output
+func treeUsage(psOutput string, root int) processUsage {
+	children := make(map[int][]int)
+	usage := make(map[int]processUsage)
+	for _, line := range strings.Split(psOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		rss, err3 := strconv.ParseInt(fields[2], 10, 64)
+		cpu, err4 := strconv.ParseFloat(strings.ReplaceAll(fields[3], ",", "."), 64)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			continue
+		}
+		children[ppid] = append(children[ppid], pid)
+		usage[pid] = processUsage{CPU: cpu, RSSKB: rss}
+	}
+
+	var total processUsage
+	seen := make(map[int]bool)
+	stack := []int{root}
+	for len(stack) > 0 {
+		pid := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		total.CPU += usage[pid].CPU
+		total.RSSKB += usage[pid].RSSKB
+		stack = append(stack, children[pid]...)
+	}
+	return total
+}
+
+// tmuxPanePID returns the PID of the shell in a session's pane
+func tmuxPanePID(tmuxName string) (int, error) {
+	out, err := exec.Command(tmuxPath, "display-message", "-p", "-t", tmuxName, "#{pane_pid}").Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+// formatSessionResources renders the CPU and memory use of each running
+// tmux session for /stats
+func formatSessionResources(config *Config) string {
+	ps, err := exec.Command("ps", "-A", "-o", "pid=,ppid=,rss=,%cpu=").Output()
+	if err != nil {
+		return ""
+	}
+	var names []string
+	for name, info := range config.Sessions {
+		if info != nil && !isHeadless(info) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		pid, err := tmuxPanePID(sessionName(name))
+		if err != nil {
+			continue
+		}
+		u := treeUsage(string(ps), pid)
+		fmt.Fprintf(&sb, "\n  • %s — %.0f%% CPU, %s RSS", name, u.CPU, formatKB(u.RSSKB))
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "📈 Session usage:" + sb.String()
+}
+
+// formatKB renders a size in KB as MB or GB
+func formatKB(kb int64) string {
+	if kb >= 1024*1024 {
+		return fmt.Sprintf("%.1f GB", float64(kb)/(1024*1024))
+	}
+	return fmt.Sprintf("%d MB", kb/1024)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSessionLimitPrefix(t *testing.T) {
+	scope := systemdScopeArgs()
+	config := &Config{SessionCPUPercent: 200, SessionMemoryMB: 4096, SessionNice: 10}
+
+	want := append(append([]string{}, scope...), "-p", "CPUQuota=200%", "-p", "MemoryMax=4096M", "--", "nice", "-n", "10")
+	if got := sessionLimitPrefix(config, true); !reflect.DeepEqual(got, want) {
+		t.Errorf("with systemd = %q, want %q", got, want)
+	}
+	if got := sessionLimitPrefix(config, false); !reflect.DeepEqual(got, []string{"nice", "-n", "10"}) {
+		t.Errorf("without systemd = %q, want only nice", got)
+	}
+	if got := sessionLimitPrefix(&Config{}, true); got != nil {
+		t.Errorf("no limits = %q, want nil", got)
+	}
+	if got := limitSessionCommand(&Config{}, "ccc run"); got != "ccc run" {
+		t.Errorf("limitSessionCommand without limits = %q", got)
+	}
+}
+
+func TestTreeUsage(t *testing.T) {
+	ps := `    1     0  1000   0.0
+  100     1  2048   1.5
+  101   100 51200  40.0
+  102   101 10240  55,5
+  200     1  9999  99.0
+garbage line
+`
+	got := treeUsage(ps, 100)
+	if got.RSSKB != 2048+51200+10240 || got.CPU != 1.5+40+55.5 {
+		t.Errorf("treeUsage = %+v", got)
+	}
+	if got := treeUsage(ps, 12345); got != (processUsage{}) {
+		t.Errorf("unknown root = %+v, want zero", got)
+	}
+}
@@ -7,15 +7,17 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // RouterIntent represents the classified intent from the LLM router
 type RouterIntent struct {
-	Action  string // new_session, send, switch, status, peek, kill, passthrough, list
+	Action  string // new_session, send, broadcast, switch, status, peek, kill, ask, passthrough, list
 	Name    string // session name (for new_session, switch, peek, kill)
-	Message string // message content (for new_session prompt, send message)
+	Message string // message content (for new_session prompt, send/broadcast message, ask question)
 }
 
 const routerSystemPrompt = `You are a command router for a Claude Code session manager. Classify the user's message into one of these intents:
@@ -28,6 +30,8 @@ INTENTS:
 - peek:<name> — User wants to see the latest output from a specific session.
 - kill:<name> — User wants to stop/kill a session.
 - list — User wants to list all sessions.
+- broadcast:<message> — User wants the same message sent to every running session ("tell all sessions to...", "everyone commit your work"). Extract the message.
+- ask:<question> — User asks about the sessions themselves (did one finish, what is one doing, did anything fail) and wants an answer, not to act on them.
 - passthrough — The message should be forwarded as-is to the active session (default for most messages).
 
 RULES:
@@ -39,7 +43,9 @@ RULES:
 6. If the user says "stop", "kill", "end", "cancel" + session name → kill.
 7. If the user says "switch to", "go to", "open" + session name → switch.
 8. If the user says "list sessions", "show sessions", "what sessions" → list.
-9. Most messages that look like instructions, questions, or code should be "passthrough".
+9. If the user addresses "all", "every" or "each" session → broadcast.
+10. If the user asks whether a session finished, failed, or what it did or is doing → ask.
+11. Most messages that look like instructions, questions, or code should be "passthrough".
 
 Respond with ONLY the intent string, nothing else. Examples:
 - "start a new session to research quantum computing" → new_session:quantum-research:research quantum computing and summarize key findings
@@ -49,54 +55,103 @@ Respond with ONLY the intent string, nothing else. Examples:
 - "switch to my-project" → switch:my-project
 - "implement the login form with React" → passthrough
 - "list all sessions" → list
+- "tell all running sessions to commit their work" → broadcast:commit your work
+- "did the deploy session finish?" → ask:did the deploy session finish?
 - "hey can you fix the bug in auth.go" → passthrough`
 
 const defaultRouterModel = "google/gemini-2.0-flash-lite-001"
 
-// classifyIntent sends the message to OpenRouter for intent classification
+// openRouterEndpoint is the API base used when router_endpoint is not set
+const openRouterEndpoint = "https://openrouter.ai/api/v1"
+
+// routerEnabled reports whether messages are classified: an OpenRouter key
+// or a local endpoint (Ollama, llama.cpp, LM Studio...) is configured
+func routerEnabled(config *Config) bool {
+	return config.OpenRouterKey != "" || config.RouterEndpoint != ""
+}
+
+// routerChatURL returns the chat completions URL of the router endpoint.
+// router_endpoint is an OpenAI-compatible API base like http://localhost:11434/v1.
+func routerChatURL(config *Config) string {
+	base := strings.TrimRight(config.RouterEndpoint, "/")
+	if base == "" {
+		base = openRouterEndpoint
+	}
+	if strings.HasSuffix(base, "/chat/completions") {
+		return base
+	}
+	return base + "/chat/completions"
+}
+
+// routerModel returns the model used for intent classification
+func routerModel(config *Config) string {
+	if config.RouterModel != "" {
+		return config.RouterModel
+	}
+	return defaultRouterModel
+}
+
+// classifyIntent sends the message to the router LLM for intent classification
 func classifyIntent(config *Config, text string) (*RouterIntent, error) {
-	if config.OpenRouterKey == "" {
-		// No router key configured — treat everything as passthrough
+	if !routerEnabled(config) {
+		// No router configured — treat everything as passthrough
 		return &RouterIntent{Action: "passthrough", Message: text}, nil
 	}
 
+	response, err := routerComplete(config, routerSystemPrompt, text, 100)
+	if err != nil {
+		return nil, err
+	}
+	return parseIntent(response, text)
+}
+
+// routerComplete runs one chat completion on the router LLM and returns its
+// reply, or "" when the model returned no choices
+func routerComplete(config *Config, system, user string, maxTokens int) (string, error) {
 	reqBody := map[string]interface{}{
-		"model": defaultRouterModel,
+		"model": routerModel(config),
 		"messages": []map[string]string{
-			{"role": "system", "content": routerSystemPrompt},
-			{"role": "user", "content": text},
+			{"role": "system", "content": system},
+			{"role": "user", "content": user},
 		},
-		"max_tokens":  100,
+		"max_tokens":  maxTokens,
 		"temperature": 0.0,
 	}
 
 	bodyJSON, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewReader(bodyJSON))
+	req, err := http.NewRequest("POST", routerChatURL(config), bytes.NewReader(bodyJSON))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+config.OpenRouterKey)
+	if config.OpenRouterKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.OpenRouterKey)
+	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	// Local models may need loading into memory on the first call
+	timeout := 10 * time.Second
+	if config.RouterEndpoint != "" {
+		timeout = 60 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("router API call failed: %w", err)
+		return "", fmt.Errorf("router API call failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024))
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return "", fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("router API error %d: %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("router API error %d: %s", resp.StatusCode, string(body))
 	}
 
 	var result struct {
@@ -107,14 +162,13 @@ func classifyIntent(config *Config, text string) (*RouterIntent, error) {
 		} `json:"choices"`
 	}
 	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return "", fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if len(result.Choices) == 0 {
-		return &RouterIntent{Action: "passthrough", Message: text}, nil
+		return "", nil
 	}
-
-	return parseIntent(result.Choices[0].Message.Content, text)
+	return result.Choices[0].Message.Content, nil
 }
 
 // parseIntent parses the LLM response into a RouterIntent
@@ -156,6 +210,21 @@ func parseIntent(response string, originalText string) (*RouterIntent, error) {
 		return &RouterIntent{Action: "peek", Name: strings.TrimSpace(name)}, nil
 	}
 
+	// Handle broadcast:<message>
+	if strings.HasPrefix(response, "broadcast:") {
+		msg := strings.TrimPrefix(response, "broadcast:")
+		return &RouterIntent{Action: "broadcast", Message: strings.TrimSpace(msg)}, nil
+	}
+
+	// Handle ask:<question>
+	if strings.HasPrefix(response, "ask:") {
+		question := strings.TrimSpace(strings.TrimPrefix(response, "ask:"))
+		if question == "" {
+			question = originalText
+		}
+		return &RouterIntent{Action: "ask", Message: question}, nil
+	}
+
 	// Handle kill:<name>
 	if strings.HasPrefix(response, "kill:") {
 		name := strings.TrimPrefix(response, "kill:")
@@ -193,15 +262,21 @@ func routeMessage(config *Config, chatID int64, threadID int64, text string) boo
 	case "new_session":
 		return handleRouterNewSession(config, chatID, threadID, intent)
 	case "status":
-		return handleRouterStatus(config, chatID, threadID)
+		return handleRouterStatus(config, chatID, threadID, formatText)
 	case "list":
-		return handleRouterStatus(config, chatID, threadID)
+		return handleRouterStatus(config, chatID, threadID, formatText)
 	case "peek":
-		return handleRouterPeek(config, chatID, threadID, intent)
+		return handleRouterPeek(config, chatID, threadID, intent, formatText)
 	case "kill":
 		return handleRouterKill(config, chatID, threadID, intent)
 	case "switch":
 		return handleRouterSwitch(config, chatID, threadID, intent)
+	case "send":
+		return handleRouterSend(config, chatID, threadID, intent)
+	case "broadcast":
+		return handleRouterBroadcast(config, chatID, threadID, intent)
+	case "ask":
+		return handleRouterAsk(config, chatID, threadID, intent)
 	case "passthrough":
 		return false // Let normal message handling take over
 	}
@@ -224,32 +299,31 @@ func handleRouterNewSession(config *Config, chatID int64, threadID int64, intent
 		return true
 	}
 
-	// Create topic
-	topicID, err := createForumTopic(config, name)
+	// Create topic, in the registered group the request came from if any
+	workDir := resolveProjectPath(config, name)
+	info := &SessionInfo{Path: workDir, GroupID: newSessionGroup(config, chatID), Machine: sessionMachine(config)}
+	group := sessionGroup(config, info)
+	topicID, err := createForumTopic(config, group, name)
 	if err != nil {
 		sendMessage(config, chatID, threadID, fmt.Sprintf("Failed to create topic: %v", err))
 		return true
 	}
-
-	workDir := resolveProjectPath(config, name)
-	config.Sessions[name] = &SessionInfo{
-		TopicID: topicID,
-		Path:    workDir,
-	}
-	saveConfig(config)
+	info.TopicID = topicID
+	config.Sessions[name] = info
+	saveSession(name, info)
 
 	os.MkdirAll(workDir, 0755)
 
-	tmuxName := "claude-" + strings.ReplaceAll(name, ".", "_")
+	tmuxName := tmuxPrefix() + strings.ReplaceAll(name, ".", "_")
 	if err := createTmuxSession(tmuxName, workDir, false); err != nil {
-		sendMessage(config, config.GroupID, topicID, fmt.Sprintf("Failed to start tmux: %v", err))
+		sendMessage(config, group, topicID, fmt.Sprintf("Failed to start tmux: %v", err))
 		return true
 	}
 
 	// Wait for Claude and send the initial prompt
 	go func() {
-		if err := waitForClaude(tmuxName, 30*time.Second); err != nil {
-			sendMessage(config, config.GroupID, topicID, fmt.Sprintf("Claude didn't start in time: %v", err))
+		if err := waitForSessionStart(config, tmuxName); err != nil {
+			sendMessage(config, group, topicID, fmt.Sprintf("Claude didn't start in time: %v", err))
 			return
 		}
 		if prompt != "" {
@@ -258,52 +332,194 @@ func handleRouterNewSession(config *Config, chatID int64, threadID int64, intent
 	}()
 
 	sendMessage(config, chatID, threadID, fmt.Sprintf("Session '%s' created! Check the new topic.", name))
-	sendMessage(config, config.GroupID, topicID, fmt.Sprintf("Session '%s' started.\n\nPrompt: %s", name, prompt))
+	sendMessage(config, group, topicID, fmt.Sprintf("Session '%s' started.\n\nPrompt: %s", name, prompt))
 	return true
 }
 
-func handleRouterStatus(config *Config, chatID int64, threadID int64) bool {
-	if len(config.Sessions) == 0 {
+// outputFormat selects how command handlers render their results
+type outputFormat int
+
+const (
+	formatText outputFormat = iota // human-readable prose
+	formatJSON                     // JSON code block for automation
+)
+
+// SessionStatus is the machine-readable state of a single session
+type SessionStatus struct {
+	Name            string     `json:"name"`
+	Path            string     `json:"path"`
+	TopicID         int64      `json:"topic_id"`
+	State           string     `json:"state"` // stopped, hibernated, idle, working
+	ClaudeSessionID string     `json:"claude_session_id,omitempty"`
+	LastActivity    *time.Time `json:"last_activity,omitempty"` // known only inside the listener
+}
+
+// sessionState reports whether a tmux session is stopped, idle or working
+func sessionState(tmuxName string) string {
+	if !tmuxSessionExists(tmuxName) {
+		return "stopped"
+	}
+	if isClaudeIdle(tmuxName) {
+		return "idle"
+	}
+	return "working"
+}
+
+// collectSessionStatuses returns the status of every configured session, sorted by name
+func collectSessionStatuses(config *Config) []SessionStatus {
+	statuses := make([]SessionStatus, 0, len(config.Sessions))
+	for name, info := range config.Sessions {
+		if info == nil {
+			continue
+		}
+		st := SessionStatus{
+			Name:            name,
+			Path:            info.Path,
+			TopicID:         info.TopicID,
+			State:           sessionState(sessionName(name)),
+			ClaudeSessionID: info.ClaudeSessionID,
+		}
+		if st.State == "stopped" && info.Hibernated {
+			st.State = "hibernated"
+		}
+		if isHeadless(info) {
+			st.State = "idle"
+			if headlessRunning(name) {
+				st.State = "working"
+			}
+		}
+		if last := sessionLastActivity(name); !last.IsZero() {
+			st.LastActivity = &last
+		}
+		statuses = append(statuses, st)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func handleRouterStatus(config *Config, chatID int64, threadID int64, format outputFormat) bool {
+	statuses := collectSessionStatuses(config)
+
+	if format == formatJSON {
+		sendJSON(config, chatID, threadID, statuses)
+		return true
+	}
+
+	if len(statuses) == 0 {
 		sendMessage(config, chatID, threadID, "No active sessions.")
 		return true
 	}
 
 	var sb strings.Builder
 	sb.WriteString("Sessions:\n\n")
-	for name := range config.Sessions {
-		tmuxName := sessionName(name)
-		status := "stopped"
-		if tmuxSessionExists(tmuxName) {
-			if isClaudeIdle(tmuxName) {
-				status = "idle (waiting for input)"
-			} else {
-				status = "working..."
-			}
+	for _, st := range statuses {
+		status := st.State
+		switch st.State {
+		case "idle":
+			status = "idle (waiting for input)"
+		case "working":
+			status = "working..."
 		}
-		info := config.Sessions[name]
-		sb.WriteString(fmt.Sprintf("- %s [%s]\n  Path: %s\n", name, status, info.Path))
+		sb.WriteString(fmt.Sprintf("- %s [%s]\n  Path: %s\n", st.Name, status, st.Path))
 	}
 	sendMessage(config, chatID, threadID, sb.String())
 	return true
 }
 
-func handleRouterPeek(config *Config, chatID int64, threadID int64, intent *RouterIntent) bool {
+// listCallbackPrefix marks inline button presses from the /list keyboard.
+// Callback data is "list:<action>:<session>".
+const listCallbackPrefix = "list:"
+
+// formatLastActivity renders how long ago a session was last active
+func formatLastActivity(last time.Time, now time.Time) string {
+	if last.IsZero() {
+		return "no activity yet"
+	}
+	if now.Sub(last) < time.Minute {
+		return "just now"
+	}
+	return formatDuration(now.Sub(last)) + " ago"
+}
+
+// handleListCommand shows every session with its state, path and last activity,
+// with Restart / Kill / Peek buttons per session.
+func handleListCommand(config *Config, chatID int64, threadID int64) {
+	statuses := collectSessionStatuses(config)
+	if len(statuses) == 0 {
+		sendMessage(config, chatID, threadID, "No active sessions.")
+		return
+	}
+
+	now := time.Now()
+	var sb strings.Builder
+	var buttons [][]InlineKeyboardButton
+	sb.WriteString("Sessions:\n\n")
+	for _, st := range statuses {
+		icon := "⚪"
+		switch st.State {
+		case "idle":
+			icon = "🟢"
+		case "working":
+			icon = "🟡"
+		case "hibernated":
+			icon = "🛌"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s [%s]\n  Path: %s\n  Last activity: %s\n",
+			icon, st.Name, st.State, st.Path, formatLastActivity(sessionLastActivity(st.Name), now)))
+		// Telegram caps callback data at 64 bytes; skip buttons for very long names
+		if len(listCallbackPrefix+"restart:"+st.Name) > 64 {
+			continue
+		}
+		buttons = append(buttons, []InlineKeyboardButton{
+			{Text: "🔄 " + st.Name, CallbackData: listCallbackPrefix + "restart:" + st.Name},
+			{Text: "🛑 Kill", CallbackData: listCallbackPrefix + "kill:" + st.Name},
+			{Text: "👀 Peek", CallbackData: listCallbackPrefix + "peek:" + st.Name},
+		})
+	}
+
+	if err := sendMessageWithKeyboard(config, chatID, threadID, sb.String(), buttons); err != nil {
+		hookLog("list: failed to send session list: %v", err)
+	}
+}
+
+// handleListCallback runs the action for a /list button press
+func handleListCallback(config *Config, chatID int64, threadID int64, data string) {
+	parts := strings.SplitN(strings.TrimPrefix(data, listCallbackPrefix), ":", 2)
+	if len(parts) != 2 {
+		return
+	}
+	action, name := parts[0], parts[1]
+	if _, exists := config.Sessions[name]; !exists {
+		sendMessage(config, chatID, threadID, fmt.Sprintf("Session '%s' not found.", name))
+		return
+	}
+
+	switch action {
+	case "restart":
+		restartSession(config, chatID, threadID, name)
+	case "kill":
+		handleRouterKill(config, chatID, threadID, &RouterIntent{Action: "kill", Name: name})
+	case "peek":
+		handleRouterPeek(config, chatID, threadID, &RouterIntent{Action: "peek", Name: name}, formatText)
+	}
+}
+
+func handleRouterPeek(config *Config, chatID int64, threadID int64, intent *RouterIntent, format outputFormat) bool {
 	name := findSessionByFuzzyName(config, intent.Name)
 	if name == "" {
+		if format == formatJSON {
+			sendJSON(config, chatID, threadID, map[string]string{"error": "not_found", "query": intent.Name})
+			return true
+		}
 		sendMessage(config, chatID, threadID, fmt.Sprintf("Session '%s' not found.", intent.Name))
 		return true
 	}
 
 	tmuxName := sessionName(name)
-	if !tmuxSessionExists(tmuxName) {
-		sendMessage(config, chatID, threadID, fmt.Sprintf("Session '%s' is not running.", name))
-		return true
-	}
-
-	blocks := getLastBlocksFromTmux(tmuxName)
-	if len(blocks) == 0 {
-		sendMessage(config, chatID, threadID, fmt.Sprintf("Session '%s': no output yet.", name))
-		return true
+	state := sessionState(tmuxName)
+	var blocks []string
+	if state != "stopped" {
+		blocks = getLastBlocksFromTmux(tmuxName)
 	}
 
 	// Show last 2 blocks max
@@ -311,10 +527,29 @@ func handleRouterPeek(config *Config, chatID int64, threadID int64, intent *Rout
 	if start < 0 {
 		start = 0
 	}
+	blocks = blocks[start:]
+
+	if format == formatJSON {
+		sendJSON(config, chatID, threadID, struct {
+			Name   string   `json:"name"`
+			State  string   `json:"state"`
+			Blocks []string `json:"blocks"`
+		}{name, state, blocks})
+		return true
+	}
+
+	if state == "stopped" {
+		sendMessage(config, chatID, threadID, fmt.Sprintf("Session '%s' is not running.", name))
+		return true
+	}
+	if len(blocks) == 0 {
+		sendMessage(config, chatID, threadID, fmt.Sprintf("Session '%s': no output yet.", name))
+		return true
+	}
 
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Peek at '%s':\n\n", name))
-	for _, block := range blocks[start:] {
+	for _, block := range blocks {
 		sb.WriteString(block)
 		sb.WriteString("\n\n")
 	}
@@ -322,6 +557,29 @@ func handleRouterPeek(config *Config, chatID int64, threadID int64, intent *Rout
 	return true
 }
 
+// handleJSONCommand runs a supported command and replies with its result as JSON.
+// Supported: status, sessions, peek <name>
+func handleJSONCommand(config *Config, chatID int64, threadID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		sendMessage(config, chatID, threadID, "Usage: /json <status|sessions|peek <name>>")
+		return
+	}
+
+	switch fields[0] {
+	case "status", "sessions":
+		handleRouterStatus(config, chatID, threadID, formatJSON)
+	case "peek":
+		if len(fields) < 2 {
+			sendMessage(config, chatID, threadID, "Usage: /json peek <name>")
+			return
+		}
+		handleRouterPeek(config, chatID, threadID, &RouterIntent{Action: "peek", Name: fields[1]}, formatJSON)
+	default:
+		sendJSON(config, chatID, threadID, map[string]string{"error": "unsupported_command", "command": fields[0]})
+	}
+}
+
 func handleRouterKill(config *Config, chatID int64, threadID int64, intent *RouterIntent) bool {
 	name := findSessionByFuzzyName(config, intent.Name)
 	if name == "" {
@@ -356,6 +614,171 @@ func handleRouterSwitch(config *Config, chatID int64, threadID int64, intent *Ro
 	return true
 }
 
+// handleRouterSend forwards a message to the active session, the one that
+// was used last. With no session to send to it falls through to general chat.
+func handleRouterSend(config *Config, chatID int64, threadID int64, intent *RouterIntent) bool {
+	name := activeSession(config)
+	if name == "" || intent.Message == "" {
+		return false
+	}
+	info := config.Sessions[name]
+	forwardToSession(config, getMessenger(config), sessionGroup(config, info), info.TopicID, name, intent.Message)
+	sendMessage(config, chatID, threadID, fmt.Sprintf("📨 Sent to '%s'", name))
+	return true
+}
+
+const routerAskPrompt = `You answer questions about Claude Code sessions managed from Telegram. Below the question is each session's state and its latest output. Answer briefly and only from that information; say so if it doesn't tell. Plain text, no markdown headings.`
+
+// askBlocksPerSession is how many recent blocks of each session the ask
+// intent shows the LLM; askBlockLen caps each of them
+const (
+	askBlocksPerSession = 3
+	askBlockLen         = 1500
+)
+
+// handleRouterAsk answers a question about the sessions from their states and
+// recent output, without sending anything to them
+func handleRouterAsk(config *Config, chatID int64, threadID int64, intent *RouterIntent) bool {
+	statuses := collectSessionStatuses(config)
+	if len(statuses) == 0 {
+		sendMessage(config, chatID, threadID, "No active sessions.")
+		return true
+	}
+	prompt := fmt.Sprintf("Question: %s\n\n%s", intent.Message, sessionDigest(statuses, time.Now()))
+	answer, err := routerComplete(config, routerAskPrompt, prompt, 500)
+	if err != nil {
+		hookLog("router: ask failed: %v", err)
+		sendMessage(config, chatID, threadID, fmt.Sprintf("⚠️ Couldn't answer: %v", err))
+		return true
+	}
+	if strings.TrimSpace(answer) == "" {
+		answer = "No answer."
+	}
+	sendMessage(config, chatID, threadID, strings.TrimSpace(answer))
+	return true
+}
+
+// sessionDigest describes every session's state and latest cached blocks
+// as context for the ask intent
+func sessionDigest(statuses []SessionStatus, now time.Time) string {
+	var sb strings.Builder
+	for _, st := range statuses {
+		activity := "no activity yet"
+		if st.LastActivity != nil {
+			activity = "last active " + formatLastActivity(*st.LastActivity, now)
+		}
+		fmt.Fprintf(&sb, "## %s (%s, %s)\nPath: %s\n", st.Name, st.State, activity, st.Path)
+		blocks := recentCachedBlocks(st.Name, askBlocksPerSession)
+		if len(blocks) == 0 {
+			sb.WriteString("No output recorded.\n")
+		}
+		for _, block := range blocks {
+			if len(block) > askBlockLen {
+				cut := len(block) - askBlockLen
+				for cut < len(block) && !utf8.RuneStart(block[cut]) {
+					cut++
+				}
+				block = "..." + block[cut:]
+			}
+			sb.WriteString("---\n" + block + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// broadcastResult is how a broadcast message fared in one session
+type broadcastResult struct {
+	Name   string
+	Status string
+}
+
+// handleRouterBroadcast sends one message to every running session and
+// replies with a delivery report. Stopped sessions are left alone.
+func handleRouterBroadcast(config *Config, chatID int64, threadID int64, intent *RouterIntent) bool {
+	if intent.Message == "" {
+		return false
+	}
+	msgr := getMessenger(config)
+	var results []broadcastResult
+	var skipped []string
+	for _, name := range sortedSessionNames(config) {
+		info := config.Sessions[name]
+		if !isHeadless(info) && !tmuxSessionExists(sessionName(name)) {
+			skipped = append(skipped, name)
+			continue
+		}
+		if info.TopicID != 0 {
+			msgr.Send(sessionGroup(config, info), info.TopicID, "📣 Broadcast: "+intent.Message)
+		}
+		results = append(results, broadcastResult{name, broadcastToSession(config, msgr, name, intent.Message)})
+	}
+	sendMessage(config, chatID, threadID, formatBroadcastReport(results, skipped))
+	return true
+}
+
+// broadcastToSession delivers a broadcast message to one running session,
+// queueing it behind a turn in progress, and returns the outcome
+func broadcastToSession(config *Config, msgr Messenger, sessName, text string) string {
+	info := config.Sessions[sessName]
+	if isHeadless(info) {
+		queued := headlessRunning(sessName)
+		startHeadlessTurn(config, msgr, sessionGroup(config, info), info.TopicID, sessName, text)
+		if queued {
+			return "⏳ queued"
+		}
+		return "✅ sent"
+	}
+	tmuxName := sessionName(sessName)
+	if isClaudeExited(tmuxName) {
+		return "💥 Claude not running"
+	}
+	if pendingCount(sessName) > 0 || !isClaudeIdle(tmuxName) {
+		ahead := enqueueMessage(sessName, text)
+		return fmt.Sprintf("⏳ queued (%d ahead)", ahead)
+	}
+	if err := typeIntoSession(sessName, text); err != nil {
+		return fmt.Sprintf("❌ %v", err)
+	}
+	return "✅ sent"
+}
+
+// formatBroadcastReport renders the per-session outcome of a broadcast
+func formatBroadcastReport(results []broadcastResult, skipped []string) string {
+	if len(results) == 0 {
+		return "No running sessions to broadcast to."
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📣 Broadcast to %d session(s):", len(results))
+	for _, r := range results {
+		fmt.Fprintf(&sb, "\n• %s — %s", r.Name, r.Status)
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&sb, "\n\nSkipped (stopped): %s", strings.Join(skipped, ", "))
+	}
+	return sb.String()
+}
+
+// activeSession returns the session with the most recent activity, or the
+// only session when none has been active since the listener started
+func activeSession(config *Config) string {
+	var best string
+	var bestTime time.Time
+	names := sortedSessionNames(config)
+	for _, name := range names {
+		if config.Sessions[name].TopicID == 0 {
+			continue
+		}
+		if t := sessionLastActivity(name); t.After(bestTime) {
+			best, bestTime = name, t
+		}
+	}
+	if best == "" && len(names) == 1 && config.Sessions[names[0]].TopicID != 0 {
+		return names[0]
+	}
+	return best
+}
+
 // findSessionByFuzzyName tries to find a session by exact name first,
 // then by prefix match, then by substring match.
 func findSessionByFuzzyName(config *Config, query string) string {
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseIntent(t *testing.T) {
@@ -97,6 +102,27 @@ func TestParseIntent(t *testing.T) {
 			wantName:     "session",
 			wantMessage:  "",
 		},
+		{
+			name:         "broadcast",
+			response:     "broadcast:commit your work",
+			originalText: "tell all running sessions to commit their work",
+			wantAction:   "broadcast",
+			wantMessage:  "commit your work",
+		},
+		{
+			name:         "ask",
+			response:     "ask:did the deploy session finish?",
+			originalText: "hey did deploy finish",
+			wantAction:   "ask",
+			wantMessage:  "did the deploy session finish?",
+		},
+		{
+			name:         "ask without question",
+			response:     "ask:",
+			originalText: "is anything broken",
+			wantAction:   "ask",
+			wantMessage:  "is anything broken",
+		},
 	}
 
 	for _, tt := range tests {
@@ -150,6 +176,39 @@ func TestFindSessionByFuzzyName(t *testing.T) {
 	}
 }
 
+func TestActiveSession(t *testing.T) {
+	config := &Config{
+		Sessions: map[string]*SessionInfo{
+			"alpha": {TopicID: 100, Path: "/a"},
+		},
+	}
+	if got := activeSession(config); got != "alpha" {
+		t.Errorf("activeSession with one session = %q, want alpha", got)
+	}
+
+	config.Sessions["beta"] = &SessionInfo{TopicID: 200, Path: "/b"}
+	if got := activeSession(config); got != "" {
+		t.Errorf("activeSession with no activity = %q, want none", got)
+	}
+
+	monitorsMu.Lock()
+	saved := monitors
+	now := time.Now()
+	monitors = map[string]*SessionMonitor{
+		"alpha": {LastActivity: now.Add(-time.Hour)},
+		"beta":  {LastActivity: now.Add(-2 * time.Hour), LastUserMessage: now.Add(-time.Minute)},
+	}
+	monitorsMu.Unlock()
+	defer func() {
+		monitorsMu.Lock()
+		monitors = saved
+		monitorsMu.Unlock()
+	}()
+	if got := activeSession(config); got != "beta" {
+		t.Errorf("activeSession = %q, want beta (messaged last)", got)
+	}
+}
+
 func TestClassifyIntentNoKey(t *testing.T) {
 	config := &Config{OpenRouterKey: ""}
 	intent, err := classifyIntent(config, "hello world")
@@ -163,3 +222,112 @@ func TestClassifyIntentNoKey(t *testing.T) {
 		t.Errorf("Message = %q, want original text", intent.Message)
 	}
 }
+
+func TestClassifyIntentLocalEndpoint(t *testing.T) {
+	var gotPath, gotAuth, gotModel string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		gotPath, gotAuth, gotModel = r.URL.Path, r.Header.Get("Authorization"), body.Model
+		w.Write([]byte(`{"choices":[{"message":{"content":"status"}}]}`))
+	}))
+	defer srv.Close()
+
+	config := &Config{RouterEndpoint: srv.URL + "/v1/", RouterModel: "qwen2.5:3b"}
+	intent, err := classifyIntent(config, "what's going on")
+	if err != nil {
+		t.Fatalf("classifyIntent: %v", err)
+	}
+	if intent.Action != "status" {
+		t.Errorf("Action = %q, want status", intent.Action)
+	}
+	if gotPath != "/v1/chat/completions" || gotModel != "qwen2.5:3b" || gotAuth != "" {
+		t.Errorf("request: path=%q model=%q auth=%q; want the local endpoint, model and no key", gotPath, gotModel, gotAuth)
+	}
+
+	if got := routerChatURL(&Config{}); got != "https://openrouter.ai/api/v1/chat/completions" {
+		t.Errorf("default routerChatURL = %q", got)
+	}
+}
+
+func TestSessionDigest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	saveBlockCache("deploy", &BlockCache{Blocks: []CachedBlock{
+		{Text: "Running the deploy script", MsgID: 1},
+		{Text: "✅ Deployed v1.4 to production", MsgID: 2},
+	}})
+	now := time.Now()
+	last := now.Add(-5 * time.Minute)
+	digest := sessionDigest([]SessionStatus{
+		{Name: "deploy", Path: "/srv/app", State: "idle", LastActivity: &last},
+		{Name: "docs", Path: "/srv/docs", State: "stopped"},
+	}, now)
+
+	for _, want := range []string{"## deploy (idle, last active 5m ago)", "Deployed v1.4", "## docs (stopped, no activity yet)", "No output recorded."} {
+		if !strings.Contains(digest, want) {
+			t.Errorf("digest missing %q:\n%s", want, digest)
+		}
+	}
+}
+
+func TestFormatBroadcastReport(t *testing.T) {
+	if got := formatBroadcastReport(nil, []string{"old"}); got != "No running sessions to broadcast to." {
+		t.Errorf("report with nothing running = %q", got)
+	}
+	got := formatBroadcastReport([]broadcastResult{
+		{"api", "✅ sent"},
+		{"web", "⏳ queued (0 ahead)"},
+	}, []string{"docs"})
+	want := "📣 Broadcast to 2 session(s):\n• api — ✅ sent\n• web — ⏳ queued (0 ahead)\n\nSkipped (stopped): docs"
+	if got != want {
+		t.Errorf("formatBroadcastReport =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCollectSessionStatuses(t *testing.T) {
+	config := &Config{
+		Sessions: map[string]*SessionInfo{
+			"zeta-ccc-test":  {TopicID: 200, Path: "/tmp/zeta"},
+			"alpha-ccc-test": {TopicID: 100, Path: "/tmp/alpha"},
+			"broken":         nil,
+		},
+	}
+
+	statuses := collectSessionStatuses(config)
+	if len(statuses) != 2 {
+		t.Fatalf("len(statuses) = %d, want 2 (nil sessions skipped)", len(statuses))
+	}
+	if statuses[0].Name != "alpha-ccc-test" || statuses[1].Name != "zeta-ccc-test" {
+		t.Errorf("statuses not sorted by name: %q, %q", statuses[0].Name, statuses[1].Name)
+	}
+	if statuses[0].TopicID != 100 || statuses[0].Path != "/tmp/alpha" {
+		t.Errorf("statuses[0] = %+v, want topic 100 path /tmp/alpha", statuses[0])
+	}
+	if statuses[0].State != "stopped" {
+		t.Errorf("State = %q, want stopped for a session without tmux", statuses[0].State)
+	}
+}
+
+func TestFormatLastActivity(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		last     time.Time
+		expected string
+	}{
+		{"never active", time.Time{}, "no activity yet"},
+		{"seconds ago", now.Add(-20 * time.Second), "just now"},
+		{"minutes ago", now.Add(-5 * time.Minute), "5m ago"},
+		{"hours ago", now.Add(-2*time.Hour - 10*time.Minute), "2h10m ago"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := formatLastActivity(tt.last, now); result != tt.expected {
+				t.Errorf("formatLastActivity() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
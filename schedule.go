@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a prompt fired into a session on a cron schedule
+type Schedule struct {
+	ID      int       `json:"id"`
+	Cron    string    `json:"cron"`
+	Prompt  string    `json:"prompt"`
+	Created time.Time `json:"created"`
+}
+
+// cronSpec is a parsed five-field cron expression (minute hour day-of-month
+// month day-of-week), evaluated in local time
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64 // bit n set = value n allowed
+	domStar, dowStar              bool
+}
+
+// cronMacros are the supported @ shorthands
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// parseCron parses a cron expression: "*", numbers, ranges (1-5), lists (1,3)
+// and steps (*/15, 0-30/5) in each field, or one of cronMacros
+func parseCron(expr string) (*cronSpec, error) {
+	if macro, ok := cronMacros[strings.TrimSpace(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression needs 5 fields (minute hour day month weekday), got %d", len(fields))
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("field %d (%q): %w", i+1, field, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cronSpec{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domStar: fields[2] == "*", dowStar: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx != -1 {
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step")
+			}
+			step = n
+			part = part[:idx]
+		}
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || lo > hi {
+				return 0, fmt.Errorf("invalid range")
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value")
+			}
+			lo, hi = n, n
+			if step > 1 {
+				// "5/15" means every 15 starting at 5
+				hi = max
+			}
+		}
+		if lo < min || hi > max {
+			return 0, fmt.Errorf("value out of range %d-%d", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether the spec fires in the minute containing t. As in
+// cron, when both day fields are restricted either one matching is enough.
+func (c *cronSpec) matches(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 || c.hour&(1<<uint(t.Hour())) == 0 || c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// next returns the first matching minute after t, or the zero time if none
+// falls within a year (e.g. "0 0 31 2 *")
+func (c *cronSpec) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(1, 0, 1); t.Before(end); t = t.Add(time.Minute) {
+		if c.matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseScheduleArgs splits "/schedule" arguments into a cron expression and a
+// prompt. The expression may be quoted ("0 9 * * 1"), a @macro, or the first
+// five words.
+func parseScheduleArgs(arg string) (string, string, error) {
+	arg = strings.TrimSpace(arg)
+	var expr, prompt string
+	switch {
+	case strings.HasPrefix(arg, `"`) || strings.HasPrefix(arg, "'"):
+		end := strings.Index(arg[1:], arg[:1])
+		if end == -1 {
+			return "", "", fmt.Errorf("unterminated quote")
+		}
+		expr, prompt = arg[1:end+1], arg[end+2:]
+	case strings.HasPrefix(arg, "@"):
+		fields := strings.SplitN(arg, " ", 2)
+		expr = fields[0]
+		if len(fields) == 2 {
+			prompt = fields[1]
+		}
+	default:
+		fields := strings.Fields(arg)
+		if len(fields) < 6 {
+			return "", "", fmt.Errorf("missing cron expression or prompt")
+		}
+		expr = strings.Join(fields[:5], " ")
+		prompt = strings.Join(fields[5:], " ")
+	}
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return "", "", fmt.Errorf("missing prompt")
+	}
+	if _, err := parseCron(expr); err != nil {
+		return "", "", err
+	}
+	return expr, prompt, nil
+}
+
+// addSchedule attaches a schedule to a session and returns its ID
+func addSchedule(info *SessionInfo, expr, prompt string, now time.Time) int {
+	id := 1
+	for _, s := range info.Schedules {
+		if s.ID >= id {
+			id = s.ID + 1
+		}
+	}
+	info.Schedules = append(info.Schedules, Schedule{ID: id, Cron: expr, Prompt: prompt, Created: now})
+	return id
+}
+
+// removeSchedule detaches a schedule by ID, reporting whether it existed
+func removeSchedule(info *SessionInfo, id int) bool {
+	for i, s := range info.Schedules {
+		if s.ID == id {
+			info.Schedules = append(info.Schedules[:i], info.Schedules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// formatSchedules lists schedules with their next run time
+func formatSchedules(config *Config, only string, now time.Time) string {
+	var names []string
+	for name, info := range config.Sessions {
+		if info != nil && len(info.Schedules) > 0 && (only == "" || name == only) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "No scheduled prompts. Add one in a session topic with /schedule \"0 9 * * 1\" <prompt>"
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("⏰ Scheduled prompts:\n")
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("\n%s\n", name))
+		for _, s := range config.Sessions[name].Schedules {
+			next := "never"
+			if spec, err := parseCron(s.Cron); err == nil {
+				if t := spec.next(now); !t.IsZero() {
+					next = t.Format("Mon Jan 2 15:04")
+				}
+			}
+			sb.WriteString(fmt.Sprintf("  #%d `%s` → %s\n      next: %s\n", s.ID, s.Cron, truncate(s.Prompt, 80), next))
+		}
+	}
+	return sb.String()
+}
+
+// dueSchedules returns the schedules of a session that fire in the minute of now
+func dueSchedules(info *SessionInfo, now time.Time) []Schedule {
+	var due []Schedule
+	for _, s := range info.Schedules {
+		spec, err := parseCron(s.Cron)
+		if err == nil && spec.matches(now) {
+			due = append(due, s)
+		}
+	}
+	return due
+}
+
+// startScheduler fires scheduled prompts into their sessions. It checks once a
+// minute, just after the minute turns; busy sessions queue the prompt.
+func startScheduler() {
+	lastRun := time.Now().Truncate(time.Minute)
+	for {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute + time.Second).Sub(now))
+
+		minute := time.Now().Truncate(time.Minute)
+		if !minute.After(lastRun) {
+			continue
+		}
+		lastRun = minute
+
+		config, err := loadConfig()
+		if err != nil {
+			continue
+		}
+		msgr := getMessenger(config)
+		for name, info := range config.Sessions {
+			if info == nil || info.TopicID == 0 {
+				continue
+			}
+			for _, s := range dueSchedules(info, minute) {
+				hookLog("scheduler: session=%s firing #%d", name, s.ID)
+				msgr.Send(sessionGroup(config, info), info.TopicID, fmt.Sprintf("⏰ Scheduled prompt #%d: %s", s.ID, s.Prompt))
+				forwardToSession(config, msgr, sessionGroup(config, info), info.TopicID, name, s.Prompt)
+			}
+		}
+		if digestDue(config, minute) {
+			go sendDigest(config, minute)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	monday9 := time.Date(2026, 1, 5, 9, 0, 0, 0, time.Local) // a Monday
+	tests := []struct {
+		expr    string
+		at      time.Time
+		matches bool
+	}{
+		{"0 9 * * 1", monday9, true},
+		{"0 9 * * 1", monday9.Add(time.Minute), false},
+		{"0 9 * * 2", monday9, false},
+		{"*/15 * * * *", monday9.Add(45 * time.Minute), true},
+		{"*/15 * * * *", monday9.Add(40 * time.Minute), false},
+		{"0 8-10 * * 1-5", monday9, true},
+		{"0 9,17 * * *", monday9.Add(8 * time.Hour), true},
+		{"0 9 * * 7", monday9.AddDate(0, 0, 6), true}, // Sunday as 7
+		{"0 9 5 * 3", monday9, true},                  // day-of-month or weekday
+		{"@daily", time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local), true},
+	}
+
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		if got := spec.matches(tt.at); got != tt.matches {
+			t.Errorf("%q matches %v = %v, want %v", tt.expr, tt.at, got, tt.matches)
+		}
+	}
+
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(bad); err == nil {
+			t.Errorf("parseCron(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	spec, _ := parseCron("0 9 * * 1")
+	from := time.Date(2026, 1, 5, 9, 0, 30, 0, time.Local)
+	want := time.Date(2026, 1, 12, 9, 0, 0, 0, time.Local)
+	if got := spec.next(from); !got.Equal(want) {
+		t.Errorf("next = %v, want %v", got, want)
+	}
+
+	never, _ := parseCron("0 0 31 2 *")
+	if got := never.next(from); !got.IsZero() {
+		t.Errorf("next for Feb 31 = %v, want zero", got)
+	}
+}
+
+func TestParseScheduleArgs(t *testing.T) {
+	tests := []struct {
+		arg          string
+		expr, prompt string
+		wantErr      bool
+	}{
+		{`"0 9 * * 1" run the test suite`, "0 9 * * 1", "run the test suite", false},
+		{`0 9 * * 1 run the test suite`, "0 9 * * 1", "run the test suite", false},
+		{`@hourly check CI`, "@hourly", "check CI", false},
+		{`"0 9 * * 1"`, "", "", true},
+		{`"0 9 * * 1 run`, "", "", true},
+		{`"0 25 * * 1" run`, "", "", true},
+	}
+
+	for _, tt := range tests {
+		expr, prompt, err := parseScheduleArgs(tt.arg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseScheduleArgs(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if expr != tt.expr || prompt != tt.prompt {
+			t.Errorf("parseScheduleArgs(%q) = %q, %q, want %q, %q", tt.arg, expr, prompt, tt.expr, tt.prompt)
+		}
+	}
+}
+
+func TestAddRemoveSchedule(t *testing.T) {
+	info := &SessionInfo{}
+	now := time.Now()
+	if id := addSchedule(info, "@daily", "a", now); id != 1 {
+		t.Errorf("first id = %d, want 1", id)
+	}
+	if id := addSchedule(info, "@daily", "b", now); id != 2 {
+		t.Errorf("second id = %d, want 2", id)
+	}
+	if !removeSchedule(info, 1) {
+		t.Error("removeSchedule(1) = false")
+	}
+	if removeSchedule(info, 1) {
+		t.Error("removing twice should fail")
+	}
+	if id := addSchedule(info, "@daily", "c", now); id != 3 {
+		t.Errorf("id after removal = %d, want 3", id)
+	}
+
+	due := dueSchedules(info, time.Date(2026, 1, 5, 0, 0, 0, 0, time.Local))
+	if len(due) != 2 {
+		t.Errorf("due at midnight = %d schedules, want 2", len(due))
+	}
+}
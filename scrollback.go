@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+const (
+	// paneCaptureLines is how far back a pane is captured before a turn's
+	// prompt has been found in it
+	paneCaptureLines = 500
+	// paneOffsetMargin is captured above a turn's prompt, in case a resize
+	// reflowed the scrollback since the prompt was found
+	paneOffsetMargin = 50
+)
+
+// paneOffset is where a pane's current turn starts in its scrollback.
+// Lines are numbered from the oldest line tmux keeps, so the number of a
+// line stays put while output is added below it.
+type paneOffset struct {
+	Line    int // the turn's ❯ prompt
+	History int // history_size when it was found
+}
+
+var (
+	paneOffsets   = make(map[string]*paneOffset)
+	paneOffsetsMu sync.Mutex
+)
+
+// paneHistory returns the number of lines in a pane's scrollback, the most
+// it keeps, and the pane's width
+func paneHistory(tmuxSession string) (size, limit, width int, err error) {
+	out, err := exec.Command(tmuxPath, "display-message", "-p", "-t", tmuxSession, "#{history_size} #{history_limit} #{pane_width}").Output()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if _, err := fmt.Sscan(string(out), &size, &limit, &width); err != nil {
+		return 0, 0, 0, fmt.Errorf("parsing history size %q: %w", strings.TrimSpace(string(out)), err)
+	}
+	return size, limit, width, nil
+}
+
+// captureStart returns the first line to capture: a little above the current
+// turn's prompt, so a turn of any length is captured whole. Without a known
+// prompt it's the last paneCaptureLines lines. Once the scrollback is full
+// tmux drops its oldest lines and line numbers shift, so the whole
+// scrollback is captured until a prompt is found again.
+func captureStart(off *paneOffset, history, limit int) int {
+	start := history - paneCaptureLines
+	switch {
+	case off == nil || history < off.History:
+		// No turn seen yet, or the scrollback was cleared
+	case limit > 0 && history >= limit:
+		start = 0
+	default:
+		start = off.Line - paneOffsetMargin
+	}
+	if start < 0 {
+		start = 0
+	}
+	return start
+}
+
+// paneCapture is a pane captured from the start of its current turn
+type paneCapture struct {
+	Lines   []string
+	Start   int // line number of Lines[0], -1 if the history size is unknown
+	History int
+	Width   int // pane width, 0 if unknown
+}
+
+// capturePaneHistory captures a pane from the start of its current turn, or
+// its last paneCaptureLines lines if tmux doesn't report the history size
+func capturePaneHistory(tmuxSession string) (paneCapture, error) {
+	history, limit, width, err := paneHistory(tmuxSession)
+	if err != nil {
+		out, err := exec.Command(tmuxPath, "capture-pane", "-t", tmuxSession, "-p", "-S", fmt.Sprintf("-%d", paneCaptureLines)).Output()
+		if err != nil {
+			return paneCapture{}, err
+		}
+		return paneCapture{Lines: strings.Split(string(out), "\n"), Start: -1}, nil
+	}
+
+	paneOffsetsMu.Lock()
+	start := captureStart(paneOffsets[tmuxSession], history, limit)
+	paneOffsetsMu.Unlock()
+
+	// capture-pane counts lines from the top of the visible pane, so
+	// scrollback lines are negative. -N keeps trailing spaces, so a line
+	// tmux wrapped still fills the width (tmux before 3.1 lacks it).
+	from := fmt.Sprintf("%d", start-history)
+	out, err := exec.Command(tmuxPath, "capture-pane", "-t", tmuxSession, "-p", "-N", "-S", from).Output()
+	if err != nil {
+		width = 0
+		out, err = exec.Command(tmuxPath, "capture-pane", "-t", tmuxSession, "-p", "-S", from).Output()
+	}
+	if err != nil {
+		return paneCapture{}, err
+	}
+	return paneCapture{Lines: strings.Split(string(out), "\n"), Start: start, History: history, Width: width}, nil
+}
+
+// recordTurnStart remembers where the prompt at Lines[i] of a capture is, so
+// the next capture of the pane starts there
+func recordTurnStart(tmuxSession string, c paneCapture, i int) {
+	if c.Start < 0 {
+		return
+	}
+	paneOffsetsMu.Lock()
+	paneOffsets[tmuxSession] = &paneOffset{Line: c.Start + i, History: c.History}
+	paneOffsetsMu.Unlock()
+}
+
+// forgetPaneOffset drops what is known about a pane, for a session that was
+// killed
+func forgetPaneOffset(tmuxSession string) {
+	paneOffsetsMu.Lock()
+	delete(paneOffsets, tmuxSession)
+	paneOffsetsMu.Unlock()
+}
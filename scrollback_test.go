@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestCaptureStart(t *testing.T) {
+	tests := []struct {
+		name           string
+		off            *paneOffset
+		history, limit int
+		want           int
+	}{
+		{"no turn seen", nil, 1200, 2000, 1200 - paneCaptureLines},
+		{"short pane", nil, 100, 2000, 0},
+		{"turn prompt known", &paneOffset{Line: 300, History: 400}, 1900, 2000, 300 - paneOffsetMargin},
+		{"prompt near the top", &paneOffset{Line: 10, History: 400}, 900, 2000, 0},
+		{"scrollback cleared", &paneOffset{Line: 1500, History: 1600}, 700, 2000, 700 - paneCaptureLines},
+		{"scrollback full", &paneOffset{Line: 1500, History: 1900}, 2000, 2000, 0},
+	}
+	for _, tt := range tests {
+		if got := captureStart(tt.off, tt.history, tt.limit); got != tt.want {
+			t.Errorf("%s: captureStart = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxSearchHits caps the matches /search and ccc search show
+	maxSearchHits = 15
+	// searchContext is how much text is kept on each side of a match
+	searchContext = 60
+	// maxSearchFileSize skips files too big to be worth searching
+	maxSearchFileSize = 1 << 20
+	// maxSearchFiles caps how many files of a directory outside git are read
+	maxSearchFiles = 2000
+	// jumpCallbackPrefix marks the session buttons under search results: jump:<session>
+	jumpCallbackPrefix = "jump:"
+)
+
+// searchHit is one match of a search
+type searchHit struct {
+	Session string // session name, or the transcript directory when no session uses it
+	Known   bool   // whether Session is a ccc session
+	Time    time.Time
+	Where   string // "transcript" or the file and line in the session directory
+	Snippet string
+}
+
+// errSearchLimit stops walking a directory with too many files
+var errSearchLimit = errors.New("too many files")
+
+// looksBinary reports whether file contents have a NUL byte near the start
+func looksBinary(data []byte) bool {
+	if len(data) > 512 {
+		data = data[:512]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// searchSnippet returns the text around the first match of query in text,
+// on one line, or "" if it doesn't match
+func searchSnippet(text, query string) string {
+	i := strings.Index(strings.ToLower(text), strings.ToLower(query))
+	if i < 0 {
+		return ""
+	}
+	start, end := i-searchContext, i+len(query)+searchContext
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(text) {
+		end, suffix = len(text), ""
+	}
+	// Move the cuts off the middle of UTF-8 sequences
+	for start > 0 && text[start]&0xC0 == 0x80 {
+		start--
+	}
+	for end < len(text) && text[end]&0xC0 == 0x80 {
+		end++
+	}
+	return prefix + strings.Join(strings.Fields(text[start:end]), " ") + suffix
+}
+
+// searchTranscripts searches the prompts and replies of every transcript in
+// ~/.claude/projects, naming each hit after the session working there
+func searchTranscripts(config *Config, query string) []searchHit {
+	owners := make(map[string]string)
+	for name, info := range config.Sessions {
+		if info != nil && info.Path != "" {
+			owners[claudeProjectDir(info.Path)] = name
+		}
+	}
+	home, _ := os.UserHomeDir()
+	files, _ := filepath.Glob(filepath.Join(home, ".claude", "projects", "*", "*.jsonl"))
+	var hits []searchHit
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		name, known := owners[dir]
+		if !known {
+			name = filepath.Base(dir)
+		}
+		events, _ := readTranscriptEvents(file, 0)
+		for _, ev := range events {
+			if ev.Kind == "tool" {
+				continue
+			}
+			if snippet := searchSnippet(ev.Text, query); snippet != "" {
+				hits = append(hits, searchHit{Session: name, Known: known, Time: ev.Time, Where: ev.Kind, Snippet: snippet})
+			}
+		}
+	}
+	return hits
+}
+
+// searchDir searches the files of a session directory: with git grep in a
+// repository, so ignored files are skipped, else by walking it
+func searchDir(dir, query string) []searchHit {
+	type match struct{ file, line, text string }
+	var matches []match
+	if isGitRepo(dir) {
+		out, _ := runGitTimeout(dir, 10*time.Second, "grep", "-I", "-n", "-i", "-F", "--untracked", "-m", "3", "-e", query)
+		for _, l := range strings.Split(out, "\n") {
+			parts := strings.SplitN(l, ":", 3)
+			if len(parts) == 3 {
+				matches = append(matches, match{parts[0], parts[1], parts[2]})
+			}
+		}
+	} else {
+		files := 0
+		filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if fi.IsDir() {
+				if path != dir && (strings.HasPrefix(fi.Name(), ".") || fi.Name() == "node_modules") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if files++; files > maxSearchFiles {
+				return errSearchLimit
+			}
+			if !fi.Mode().IsRegular() || fi.Size() > maxSearchFileSize {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil || looksBinary(data) {
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, path)
+			found := 0
+			for n, l := range strings.Split(string(data), "\n") {
+				if found < 3 && strings.Contains(strings.ToLower(l), strings.ToLower(query)) {
+					matches = append(matches, match{rel, fmt.Sprint(n + 1), l})
+					found++
+				}
+			}
+			return nil
+		})
+	}
+
+	var hits []searchHit
+	for _, m := range matches {
+		var mod time.Time
+		if fi, err := os.Stat(filepath.Join(dir, m.file)); err == nil {
+			mod = fi.ModTime()
+		}
+		hits = append(hits, searchHit{Time: mod, Where: m.file + ":" + m.line, Snippet: searchSnippet(m.text, query)})
+	}
+	return hits
+}
+
+// searchSessions searches transcripts and session directories, newest first
+func searchSessions(config *Config, query string) []searchHit {
+	hits := searchTranscripts(config, query)
+	for _, name := range sortedSessionNames(config) {
+		info := config.Sessions[name]
+		if info == nil || info.Path == "" {
+			continue
+		}
+		for _, h := range searchDir(info.Path, query) {
+			h.Session, h.Known = name, true
+			hits = append(hits, h)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Time.After(hits[j].Time) })
+	return hits
+}
+
+// formatSearchHits renders search results, at most maxSearchHits of them
+func formatSearchHits(query string, hits []searchHit) string {
+	if len(hits) == 0 {
+		return fmt.Sprintf("🔎 No matches for %q.", query)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔎 %d matches for %q", len(hits), query)
+	for i, h := range hits {
+		if i == maxSearchHits {
+			fmt.Fprintf(&sb, "\n\n… %d more", len(hits)-i)
+			break
+		}
+		when := "unknown time"
+		if !h.Time.IsZero() {
+			when = h.Time.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(&sb, "\n\n• %s — %s, %s\n   %s", h.Session, h.Where, when, h.Snippet)
+	}
+	return sb.String()
+}
+
+// searchButtons returns a button per ccc session among the shown hits, to jump to its topic
+func searchButtons(config *Config, hits []searchHit) [][]InlineKeyboardButton {
+	var rows [][]InlineKeyboardButton
+	seen := make(map[string]bool)
+	for i, h := range hits {
+		if i == maxSearchHits {
+			break
+		}
+		info := config.Sessions[h.Session]
+		if !h.Known || seen[h.Session] || info == nil || info.TopicID == 0 {
+			continue
+		}
+		seen[h.Session] = true
+		rows = append(rows, []InlineKeyboardButton{{Text: "📍 " + h.Session, CallbackData: jumpCallbackPrefix + h.Session}})
+	}
+	return rows
+}
+
+// jumpToSession posts in a session's topic so it surfaces at the top of the
+// topic list, and returns what to tell the chat the button was pressed in
+func jumpToSession(config *Config, name string) string {
+	info := config.Sessions[name]
+	if info == nil || info.TopicID == 0 {
+		return fmt.Sprintf("❌ Session '%s' not found.", name)
+	}
+	getMessenger(config).Send(sessionGroup(config, info), info.TopicID, "📍 Here from /search")
+	if link := topicLink(config, sessionGroup(config, info), info.TopicID); link != "" {
+		return fmt.Sprintf("📍 %s: %s", name, link)
+	}
+	return fmt.Sprintf("📍 Posted in the %s topic.", name)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSearchSnippet(t *testing.T) {
+	if got := searchSnippet("nothing here", "parser"); got != "" {
+		t.Errorf("searchSnippet() without a match = %q", got)
+	}
+	if got := searchSnippet("use a Pratt\nParser here", "parser"); got != "use a Pratt Parser here" {
+		t.Errorf("searchSnippet() = %q", got)
+	}
+	long := strings.Repeat("a", 100) + " needle " + strings.Repeat("b", 100)
+	got := searchSnippet(long, "NEEDLE")
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "needle") {
+		t.Errorf("searchSnippet() of a long text = %q", got)
+	}
+}
+
+func TestSearchSessions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := filepath.Join(home, "api")
+	os.MkdirAll(workDir, 0755)
+	os.WriteFile(filepath.Join(workDir, "notes.txt"), []byte("first\nthe Tokenizer is slow\n"), 0644)
+	projDir := claudeProjectDir(workDir)
+	os.MkdirAll(projDir, 0755)
+	transcript := `{"type":"user","timestamp":"2026-01-02T09:00:00Z","message":{"content":"speed up the tokenizer"}}
+{"type":"assistant","timestamp":"2026-01-02T09:05:00Z","message":{"content":[{"type":"text","text":"Done."}]}}
+`
+	os.WriteFile(filepath.Join(projDir, "a.jsonl"), []byte(transcript), 0600)
+	other := filepath.Join(home, ".claude", "projects", "-tmp-scratch")
+	os.MkdirAll(other, 0755)
+	os.WriteFile(filepath.Join(other, "b.jsonl"), []byte(`{"type":"user","timestamp":"2026-01-01T09:00:00Z","message":{"content":"tokenizer ideas"}}`+"\n"), 0600)
+
+	config := &Config{Sessions: map[string]*SessionInfo{"api": {TopicID: 7, Path: workDir}}}
+	hits := searchSessions(config, "tokenizer")
+	if len(hits) != 3 {
+		t.Fatalf("searchSessions() = %+v, want 3 hits", hits)
+	}
+	var where []string
+	for _, h := range hits {
+		where = append(where, h.Session+" "+h.Where)
+	}
+	got := strings.Join(where, ", ")
+	for _, want := range []string{"api notes.txt:2", "api prompt", "-tmp-scratch prompt"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("hits %q missing %q", got, want)
+		}
+	}
+
+	buttons := searchButtons(config, hits)
+	if len(buttons) != 1 || buttons[0][0].CallbackData != jumpCallbackPrefix+"api" {
+		t.Errorf("searchButtons() = %+v", buttons)
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Where tokens and API keys are kept: in the config file (plain), in the OS
+// keychain, or in an encrypted section of the config file unlocked by
+// $CCC_SECRETS_KEY
+const (
+	secretsPlain     = "plain"
+	secretsKeychain  = "keychain"
+	secretsEncrypted = "encrypted"
+)
+
+// secretsKeyEnv holds the passphrase for the encrypted backend
+const secretsKeyEnv = "CCC_SECRETS_KEY"
+
+// keychainService is the service name secrets are filed under in the keychain
+const keychainService = "ccc"
+
+// keychainCacheTTL is how long keychain lookups are reused. loadConfig runs on
+// every message, and a keychain lookup spawns a process.
+const keychainCacheTTL = time.Minute
+
+// configSecrets maps each secret config key to its field
+var configSecrets = []struct {
+	key   string
+	field func(*Config) *string
+}{
+	{"bot_token", func(c *Config) *string { return &c.BotToken }},
+	{"oauth_token", func(c *Config) *string { return &c.OAuthToken }},
+	{"openrouter_key", func(c *Config) *string { return &c.OpenRouterKey }},
+	{"github_token", func(c *Config) *string { return &c.GitHubToken }},
+	{"github_webhook_secret", func(c *Config) *string { return &c.GitHubWebhookSecret }},
+	{"relay_secret", func(c *Config) *string { return &c.RelaySecret }},
+	{"federation_secret", func(c *Config) *string { return &c.FederationSecret }},
+	{"storage_secret_key", func(c *Config) *string { return &c.StorageSecretKey }},
+	{"transcription_api_key", func(c *Config) *string { return &c.TranscriptionAPIKey }},
+	{"discord_bot_token", func(c *Config) *string { return &c.DiscordBotToken }},
+	{"slack_app_token", func(c *Config) *string { return &c.SlackAppToken }},
+	{"slack_bot_token", func(c *Config) *string { return &c.SlackBotToken }},
+	{"control_key", func(c *Config) *string { return &c.ControlKey }},
+	{"matrix_access_token", func(c *Config) *string { return &c.MatrixAccessToken }},
+}
+
+// secretStore is a place secrets can be kept outside the config file
+type secretStore interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+	Delete(key string) error
+}
+
+// keychain is the OS secret store; replaced in tests
+var keychain secretStore = osKeychain{}
+
+// osKeychain uses the macOS Keychain through `security` and libsecret
+// (GNOME Keyring, KWallet) through `secret-tool` elsewhere
+type osKeychain struct{}
+
+func (osKeychain) Get(key string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", key, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "key", key)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("keychain lookup of %s failed: %w", key, err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (osKeychain) Set(key, value string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		// Commands read with -i keep the value out of argv, where ps shows it
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("keychain store of %s failed: value has a line break", key)
+		}
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(securityCommand("add-generic-password", "-U", "-s", keychainService, "-a", key, "-w", value))
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label", "ccc "+key, "service", keychainService, "key", key)
+		cmd.Stdin = strings.NewReader(value)
+	}
+	out, err := cmd.CombinedOutput()
+	if err == nil && strings.Contains(string(out), "security: ") {
+		// -i reports a failed command but still exits 0
+		err = fmt.Errorf("security failed")
+	}
+	if err != nil {
+		return fmt.Errorf("keychain store of %s failed: %v %s", key, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// securityCommand quotes a command line for `security -i`
+func securityCommand(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+	}
+	return strings.Join(quoted, " ") + "\n"
+}
+
+func (osKeychain) Delete(key string) error {
+	if runtime.GOOS == "darwin" {
+		return exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", key).Run()
+	}
+	return exec.Command("secret-tool", "clear", "service", keychainService, "key", key).Run()
+}
+
+// keychainTool returns the command the keychain backend needs on this OS
+func keychainTool() string {
+	if runtime.GOOS == "darwin" {
+		return "security"
+	}
+	return "secret-tool"
+}
+
+type cachedSecret struct {
+	value   string
+	fetched time.Time
+}
+
+var (
+	keychainCache   = make(map[string]cachedSecret)
+	keychainCacheMu sync.Mutex
+)
+
+// keychainGet reads a secret from the keychain, reusing recent lookups
+func keychainGet(key string) (string, error) {
+	keychainCacheMu.Lock()
+	defer keychainCacheMu.Unlock()
+	if c, ok := keychainCache[key]; ok && time.Since(c.fetched) < keychainCacheTTL {
+		return c.value, nil
+	}
+	value, err := keychain.Get(key)
+	if err != nil {
+		return "", err
+	}
+	keychainCache[key] = cachedSecret{value, time.Now()}
+	return value, nil
+}
+
+// keychainSet writes a secret to the keychain, skipping unchanged values
+func keychainSet(key, value string) error {
+	keychainCacheMu.Lock()
+	defer keychainCacheMu.Unlock()
+	if c, ok := keychainCache[key]; ok && c.value == value && time.Since(c.fetched) < keychainCacheTTL {
+		return nil
+	}
+	if err := keychain.Set(key, value); err != nil {
+		return err
+	}
+	keychainCache[key] = cachedSecret{value, time.Now()}
+	return nil
+}
+
+// keychainDelete removes a secret from the keychain
+func keychainDelete(key string) {
+	keychainCacheMu.Lock()
+	defer keychainCacheMu.Unlock()
+	delete(keychainCache, key)
+	keychain.Delete(key)
+}
+
+// secretsBackend returns the configured backend
+func secretsBackend(config *Config) string {
+	if config.SecretsBackend == "" {
+		return secretsPlain
+	}
+	return config.SecretsBackend
+}
+
+// loadSecrets fills the secret fields of a config just read from disk from
+// its backend. Values still in the file are kept.
+func loadSecrets(config *Config) error {
+	switch secretsBackend(config) {
+	case secretsPlain:
+		return nil
+	case secretsKeychain:
+		for _, s := range configSecrets {
+			if !containsString(config.KeychainSecrets, s.key) || *s.field(config) != "" {
+				continue
+			}
+			value, err := keychainGet(s.key)
+			if err != nil {
+				return err
+			}
+			*s.field(config) = value
+		}
+		return nil
+	case secretsEncrypted:
+		if config.EncryptedSecrets == "" {
+			return nil
+		}
+		values, err := decryptSecrets(config.EncryptedSecrets)
+		if err != nil {
+			return err
+		}
+		for _, s := range configSecrets {
+			if v, ok := values[s.key]; ok && *s.field(config) == "" {
+				*s.field(config) = v
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown secrets_backend %q (use plain, keychain or encrypted)", config.SecretsBackend)
+}
+
+// storeSecrets moves the secrets of settings about to be written into their
+// backend, leaving settings without them. Secrets left in a backend that is
+// no longer used are removed from it.
+func storeSecrets(settings *Config) error {
+	backend := secretsBackend(settings)
+	if backend != secretsPlain && backend != secretsKeychain && backend != secretsEncrypted {
+		return fmt.Errorf("unknown secrets_backend %q (use plain, keychain or encrypted)", settings.SecretsBackend)
+	}
+
+	var stored []string
+	for _, s := range configSecrets {
+		value := *s.field(settings)
+		if backend == secretsKeychain && value != "" {
+			if err := keychainSet(s.key, value); err != nil {
+				return err
+			}
+			stored = append(stored, s.key)
+		}
+	}
+	for _, name := range settings.KeychainSecrets {
+		if !containsString(stored, name) {
+			keychainDelete(name)
+		}
+	}
+	settings.KeychainSecrets = stored
+
+	previous := settings.EncryptedSecrets
+	settings.EncryptedSecrets = ""
+	if backend == secretsEncrypted {
+		values := make(map[string]string)
+		for _, s := range configSecrets {
+			if value := *s.field(settings); value != "" {
+				values[s.key] = value
+			}
+		}
+		if len(values) > 0 {
+			blob, err := encryptSecrets(values, secretsSalt(previous))
+			if err != nil {
+				return err
+			}
+			settings.EncryptedSecrets = blob
+		}
+	}
+
+	if backend != secretsPlain {
+		for _, s := range configSecrets {
+			*s.field(settings) = ""
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// The encrypted section is "v1:" + base64(salt | nonce | ciphertext): a JSON
+// object of secrets sealed with XChaCha20-Poly1305 under a scrypt-derived key
+const (
+	secretsFormat  = "v1:"
+	secretsSaltLen = 16
+)
+
+var (
+	secretsKeys   = make(map[string][]byte) // salt+passphrase -> derived key, scrypt is slow
+	secretsKeysMu sync.Mutex
+)
+
+// secretsKey derives the encryption key from $CCC_SECRETS_KEY and a salt
+func secretsKey(salt []byte) ([]byte, error) {
+	passphrase := os.Getenv(secretsKeyEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("secrets are encrypted: set %s to unlock them", secretsKeyEnv)
+	}
+	secretsKeysMu.Lock()
+	defer secretsKeysMu.Unlock()
+	id := string(salt) + "\x00" + passphrase
+	if key, ok := secretsKeys[id]; ok {
+		return key, nil
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	secretsKeys[id] = key
+	return key, nil
+}
+
+// secretsSalt returns the salt of an encrypted section, or nil
+func secretsSalt(blob string) []byte {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(blob, secretsFormat))
+	if !strings.HasPrefix(blob, secretsFormat) || err != nil || len(sealed) < secretsSaltLen {
+		return nil
+	}
+	return sealed[:secretsSaltLen]
+}
+
+// encryptSecrets seals secrets for the config file. Reusing the salt of the
+// previous section keeps the derived key cached across saves.
+func encryptSecrets(values map[string]string, salt []byte) (string, error) {
+	plaintext, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	if len(salt) != secretsSaltLen {
+		salt = make([]byte, secretsSaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+	}
+	key, err := secretsKey(salt)
+	if err != nil {
+		return "", err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := append(append(append([]byte{}, salt...), nonce...), aead.Seal(nil, nonce, plaintext, nil)...)
+	return secretsFormat + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecrets opens a section written by encryptSecrets
+func decryptSecrets(blob string) (map[string]string, error) {
+	if !strings.HasPrefix(blob, secretsFormat) {
+		return nil, errors.New("encrypted_secrets: unknown format")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(blob, secretsFormat))
+	if err != nil || len(sealed) < secretsSaltLen+chacha20poly1305.NonceSizeX {
+		return nil, errors.New("encrypted_secrets: corrupt")
+	}
+	salt := sealed[:secretsSaltLen]
+	nonce := sealed[secretsSaltLen : secretsSaltLen+chacha20poly1305.NonceSizeX]
+	key, err := secretsKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, sealed[secretsSaltLen+chacha20poly1305.NonceSizeX:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted_secrets: wrong %s or corrupt data", secretsKeyEnv)
+	}
+	var values map[string]string
+	if err := json.Unmarshal(plaintext, &values); err != nil {
+		return nil, fmt.Errorf("encrypted_secrets: %w", err)
+	}
+	return values, nil
+}
+
+// setSecretsBackend moves all secrets to another backend
+func setSecretsBackend(config *Config, backend string) error {
+	switch backend {
+	case secretsPlain, secretsEncrypted:
+	case secretsKeychain:
+		if _, isOS := keychain.(osKeychain); isOS {
+			if _, err := exec.LookPath(keychainTool()); err != nil {
+				return fmt.Errorf("%s not found (needed for the keychain backend)", keychainTool())
+			}
+		}
+	default:
+		return fmt.Errorf("unknown backend %q (use plain, keychain or encrypted)", backend)
+	}
+	if backend == secretsEncrypted && os.Getenv(secretsKeyEnv) == "" {
+		return fmt.Errorf("set %s to the passphrase first", secretsKeyEnv)
+	}
+	config.SecretsBackend = backend
+	if backend == secretsPlain {
+		config.SecretsBackend = ""
+	}
+	return saveConfig(config)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// memKeychain is an in-memory secretStore
+type memKeychain map[string]string
+
+func (m memKeychain) Get(key string) (string, error) { return m[key], nil }
+func (m memKeychain) Set(key, value string) error    { m[key] = value; return nil }
+func (m memKeychain) Delete(key string) error        { delete(m, key); return nil }
+
+func TestEncryptedSecrets(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv(secretsKeyEnv, "correct horse")
+
+	config := &Config{BotToken: "123:secret-bot-token", ChatID: 42, OpenRouterKey: "sk-or-xyz", SecretsBackend: secretsEncrypted}
+	if err := saveConfig(config); err != nil {
+		t.Fatalf("saveConfig: %v", err)
+	}
+	data, _ := os.ReadFile(getConfigPath())
+	if strings.Contains(string(data), "secret-bot-token") || strings.Contains(string(data), "sk-or-xyz") {
+		t.Fatalf("secrets written in plaintext:\n%s", data)
+	}
+
+	loaded, err := loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	if loaded.BotToken != "123:secret-bot-token" || loaded.OpenRouterKey != "sk-or-xyz" || loaded.ChatID != 42 {
+		t.Errorf("loaded config = %+v, want the secrets decrypted", loaded)
+	}
+
+	t.Setenv(secretsKeyEnv, "wrong")
+	if _, err := loadConfig(); err == nil {
+		t.Error("loadConfig with the wrong passphrase should fail")
+	}
+	t.Setenv(secretsKeyEnv, "")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), secretsKeyEnv) {
+		t.Errorf("loadConfig without a passphrase = %v, want a hint to set %s", err, secretsKeyEnv)
+	}
+}
+
+func TestKeychainSecrets(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	store := memKeychain{}
+	origKeychain := keychain
+	keychain = store
+	defer func() {
+		keychain = origKeychain
+		keychainCache = make(map[string]cachedSecret)
+	}()
+
+	config := &Config{BotToken: "bot-token", OAuthToken: "oauth-token", ChatID: 7}
+	if err := saveConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := setSecretsBackend(config, secretsKeychain); err != nil {
+		t.Fatalf("setSecretsBackend(keychain): %v", err)
+	}
+	data, _ := os.ReadFile(getConfigPath())
+	if strings.Contains(string(data), "bot-token") || store["bot_token"] != "bot-token" || store["oauth_token"] != "oauth-token" {
+		t.Fatalf("secrets not moved to the keychain: store=%v file=%s", store, data)
+	}
+
+	loaded, err := loadConfig()
+	if err != nil || loaded.BotToken != "bot-token" || loaded.OAuthToken != "oauth-token" {
+		t.Fatalf("loadConfig = %+v, %v; want secrets from the keychain", loaded, err)
+	}
+
+	// Back to plain: secrets return to the file and leave the keychain
+	if err := setSecretsBackend(loaded, secretsPlain); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(getConfigPath())
+	if !strings.Contains(string(data), "bot-token") || len(store) != 0 {
+		t.Errorf("switching to plain: store=%v file=%s", store, data)
+	}
+}
+
+func TestSecurityCommand(t *testing.T) {
+	got := securityCommand("add-generic-password", "-a", "bot_token", "-w", `p"a\ss word`)
+	want := `"add-generic-password" "-a" "bot_token" "-w" "p\"a\\ss word"` + "\n"
+	if got != want {
+		t.Errorf("securityCommand() = %q, want %q", got, want)
+	}
+}
@@ -20,23 +20,28 @@ func installService() error {
 }
 
 func installLaunchdService(home string) error {
+	profileArgs := ""
+	if p := currentProfile(); p != "" {
+		profileArgs = "\n        <string>--profile</string>\n        <string>" + p + "</string>"
+	}
 	plistDir := filepath.Join(home, "Library", "LaunchAgents")
 	if err := os.MkdirAll(plistDir, 0755); err != nil {
 		return fmt.Errorf("failed to create LaunchAgents dir: %w", err)
 	}
 
-	plistPath := filepath.Join(plistDir, "com.ccc.plist")
-	logPath := filepath.Join(home, ".ccc.log")
+	label := "com." + serviceName()
+	plistPath := filepath.Join(plistDir, label+".plist")
+	logPath := stateFile("-service.log")
 
 	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
 <plist version="1.0">
 <dict>
     <key>Label</key>
-    <string>com.ccc</string>
+    <string>%s</string>
     <key>ProgramArguments</key>
     <array>
-        <string>%s</string>
+        <string>%s</string>%s
         <string>listen</string>
     </array>
     <key>RunAtLoad</key>
@@ -49,7 +54,7 @@ func installLaunchdService(home string) error {
     <string>%s</string>
 </dict>
 </plist>
-`, cccPath, logPath, logPath)
+`, label, cccPath, profileArgs, logPath, logPath)
 
 	if err := os.WriteFile(plistPath, []byte(plist), 0644); err != nil {
 		return fmt.Errorf("failed to write plist: %w", err)
@@ -71,7 +76,11 @@ func installSystemdService(home string) error {
 		return fmt.Errorf("failed to create systemd dir: %w", err)
 	}
 
-	servicePath := filepath.Join(serviceDir, "ccc.service")
+	servicePath := filepath.Join(serviceDir, serviceName()+".service")
+	execStart := cccPath
+	if p := currentProfile(); p != "" {
+		execStart += " --profile " + p
+	}
 	// Include PATH so the service can find claude, tmux, node, etc.
 	service := fmt.Sprintf(`[Unit]
 Description=Claude Code Companion
@@ -86,7 +95,7 @@ Environment=HOME=%s
 
 [Install]
 WantedBy=default.target
-`, cccPath, home, home, home)
+`, execStart, home, home, home)
 
 	if err := os.WriteFile(servicePath, []byte(service), 0644); err != nil {
 		return fmt.Errorf("failed to write service file: %w", err)
@@ -94,8 +103,8 @@ WantedBy=default.target
 
 	// Reload and start
 	exec.Command("systemctl", "--user", "daemon-reload").Run()
-	exec.Command("systemctl", "--user", "enable", "ccc").Run()
-	if err := exec.Command("systemctl", "--user", "start", "ccc").Run(); err != nil {
+	exec.Command("systemctl", "--user", "enable", serviceName()).Run()
+	if err := exec.Command("systemctl", "--user", "start", serviceName()).Run(); err != nil {
 		return fmt.Errorf("failed to start service: %w", err)
 	}
 
@@ -2,17 +2,20 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 func sessionName(name string) string {
 	// Replace dots with underscores - tmux interprets dots as window/pane separators
 	safeName := strings.ReplaceAll(name, ".", "_")
-	return "claude-" + safeName
+	return tmuxPrefix() + safeName
 }
 
 func createSession(config *Config, name string) error {
@@ -21,8 +24,8 @@ func createSession(config *Config, name string) error {
 		return fmt.Errorf("session '%s' already exists", name)
 	}
 
-	// Create Telegram topic
-	topicID, err := createForumTopic(config, name)
+	// Create Telegram topic / Discord thread
+	topicID, err := getMessenger(config).CreateTopic(name)
 	if err != nil {
 		return fmt.Errorf("failed to create topic: %w", err)
 	}
@@ -42,14 +45,272 @@ func createSession(config *Config, name string) error {
 	config.Sessions[name] = &SessionInfo{
 		TopicID: topicID,
 		Path:    workDir,
+		Machine: sessionMachine(config),
 	}
-	if err := saveConfig(config); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+	if err := saveSession(name, config.Sessions[name]); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
 	}
 
 	return nil
 }
 
+// parseNewArgs splits "/new" arguments: "<name>" or
+// "<name> --worktree <repo-path> [branch]". The branch defaults to the name.
+func parseNewArgs(arg string) (name, repo, branch string, err error) {
+	fields := strings.Fields(arg)
+	if len(fields) == 0 {
+		return "", "", "", fmt.Errorf("missing session name")
+	}
+	if len(fields) == 1 || fields[1] != "--worktree" {
+		return strings.TrimSpace(arg), "", "", nil
+	}
+	name = fields[0]
+	if len(fields) < 3 || len(fields) > 4 {
+		return "", "", "", fmt.Errorf("usage: /new <name> [--worktree <repo-path> [branch]]")
+	}
+	repo, branch = fields[2], name
+	if len(fields) == 4 {
+		branch = fields[3]
+	}
+	return name, repo, branch, nil
+}
+
+const defaultClaudeStartTimeout = 30 * time.Second
+
+// claudeStartTimeout returns how long to wait for Claude to become ready
+func claudeStartTimeout(config *Config) time.Duration {
+	if config != nil && config.ClaudeStartTimeout > 0 {
+		return time.Duration(config.ClaudeStartTimeout) * time.Second
+	}
+	return defaultClaudeStartTimeout
+}
+
+// waitForSessionStart waits for Claude's prompt to appear in a freshly created
+// tmux session. On failure the error includes the last lines of the pane so
+// the actual startup error can be reported.
+func waitForSessionStart(config *Config, tmuxName string) error {
+	timeout := claudeStartTimeout(config)
+	if err := waitForClaude(tmuxName, timeout); err == nil {
+		return nil
+	}
+	if !tmuxSessionExists(tmuxName) {
+		return fmt.Errorf("tmux session exited")
+	}
+	out, err := exec.Command(tmuxPath, "capture-pane", "-t", tmuxName, "-p").Output()
+	pane := strings.TrimSpace(string(out))
+	if err != nil || pane == "" {
+		return fmt.Errorf("no prompt after %v", timeout)
+	}
+	lines := strings.Split(pane, "\n")
+	if len(lines) > 15 {
+		lines = lines[len(lines)-15:]
+	}
+	return fmt.Errorf("no prompt after %v. Pane output:\n\n%s", timeout, strings.Join(lines, "\n"))
+}
+
+// reportSessionStart waits for Claude to start and reports the outcome to Telegram.
+// Run it in a goroutine so the listener is not blocked while Claude boots.
+func reportSessionStart(config *Config, chatID, threadID int64, tmuxName, successMsg string) {
+	if err := waitForSessionStart(config, tmuxName); err != nil {
+		sendMessage(config, chatID, threadID, fmt.Sprintf("⚠️ Claude failed to start: %v", err))
+		return
+	}
+	sendMessage(config, chatID, threadID, successMsg)
+}
+
+// maxReplyContextLen caps the quoted block put before a reply
+const maxReplyContextLen = 2000
+
+// withReplyContext puts the block a user replied to before their message, so
+// Claude knows which output they mean
+func withReplyContext(block, text string) string {
+	if block == "" {
+		return text
+	}
+	return fmt.Sprintf("Regarding this output:\n\"\"\"\n%s\n\"\"\"\n\nUser says: %s", truncate(block, maxReplyContextLen), text)
+}
+
+var (
+	// startingSessions are sessions a message auto-started whose Claude isn't
+	// ready yet. Messages to them queue until it is.
+	startingSessions   = make(map[string]bool)
+	startingSessionsMu sync.Mutex
+)
+
+// sessionStarting reports whether a session is auto-starting
+func sessionStarting(sessName string) bool {
+	startingSessionsMu.Lock()
+	defer startingSessionsMu.Unlock()
+	return startingSessions[sessName]
+}
+
+// forwardToSession types a user message into a session's Claude pane,
+// auto-starting the session if its tmux session is gone. Headless sessions
+// run it with claude -p instead.
+func forwardToSession(config *Config, msgr Messenger, chatID, threadID int64, sessName, text string) {
+	if isHeadless(config.Sessions[sessName]) {
+		startHeadlessTurn(config, msgr, chatID, threadID, sessName, text)
+		return
+	}
+	tmuxName := sessionName(sessName)
+	startingSessionsMu.Lock()
+	starting := startingSessions[sessName]
+	if !starting && !tmuxSessionExists(tmuxName) {
+		startingSessions[sessName] = true
+		startingSessionsMu.Unlock()
+		autoStartSession(config, msgr, chatID, threadID, sessName, text)
+		return
+	}
+	startingSessionsMu.Unlock()
+
+	if starting {
+		// Claude is still booting: hold the message until it has the first one
+		ahead := enqueueMessage(sessName, text)
+		msgr.Send(chatID, threadID, fmt.Sprintf("%s (%d pending)", formatQueueWait(ahead, averageTurnDuration(sessName)), ahead+1))
+		return
+	} else if isClaudeExited(tmuxName) {
+		// Don't type messages into a bare shell
+		msgr.Send(chatID, threadID, "💥 Claude is not running in this session. Use /restart-claude to start it again.")
+		return
+	} else if pendingCount(sessName) > 0 || !isClaudeIdle(tmuxName) {
+		// Claude is mid-task: hold the message until the turn completes
+		ahead := enqueueMessage(sessName, text)
+		msgr.Send(chatID, threadID, fmt.Sprintf("%s (%d pending)", formatQueueWait(ahead, averageTurnDuration(sessName)), ahead+1))
+		return
+	}
+	if err := typeIntoSession(sessName, text); err != nil {
+		msgr.Send(chatID, threadID, fmt.Sprintf("❌ Failed to send: %v", err))
+	}
+}
+
+// autoStartSession starts a session whose tmux session is gone, resuming a
+// hibernated one's conversation, and types text in once Claude is ready.
+// The wait runs in a goroutine so the listener keeps handling updates;
+// the caller has marked the session as starting.
+func autoStartSession(config *Config, msgr Messenger, chatID, threadID int64, sessName, text string) {
+	tmuxName := sessionName(sessName)
+	done := func() {
+		startingSessionsMu.Lock()
+		delete(startingSessions, sessName)
+		startingSessionsMu.Unlock()
+	}
+	sessionInfo := config.Sessions[sessName]
+	workDir := sessionInfo.Path
+	if _, err := os.Stat(workDir); os.IsNotExist(err) {
+		os.MkdirAll(workDir, 0755)
+	}
+	if err := createTmuxSession(tmuxName, workDir, sessionInfo.Hibernated); err != nil {
+		done()
+		msgr.Send(chatID, threadID, fmt.Sprintf("❌ Failed to start session: %v", err))
+		return
+	}
+	if sessionInfo.Hibernated {
+		msgr.Send(chatID, threadID, fmt.Sprintf("⏰ Waking session '%s' from hibernation...", sessName))
+	} else {
+		msgr.Send(chatID, threadID, fmt.Sprintf("🚀 Session '%s' auto-starting...", sessName))
+	}
+
+	go func() {
+		defer done()
+		if err := waitForSessionStart(config, tmuxName); err != nil {
+			msg := fmt.Sprintf("⚠️ Claude failed to start: %v", err)
+			if dropped := pendingCount(sessName); dropped > 0 {
+				clearPendingMessages(sessName)
+				msg += fmt.Sprintf("\nDropped %d queued message(s)", dropped)
+			}
+			msgr.Send(chatID, threadID, msg)
+			return
+		}
+		if sessionInfo.Hibernated {
+			sessionInfo.Hibernated = false
+			if err := saveSession(sessName, sessionInfo); err != nil {
+				hookLog("hibernate: session=%s saving wake-up: %v", sessName, err)
+			}
+		}
+		// Messages queued meanwhile are typed in as each turn completes
+		if err := typeIntoSession(sessName, text); err != nil {
+			msgr.Send(chatID, threadID, fmt.Sprintf("❌ Failed to send: %v", err))
+		}
+	}()
+}
+
+// interruptSession stops what Claude is doing in a session — cancelling a
+// headless run, or pressing Escape in the tmux pane — drops queued messages
+// and returns a report of what was interrupted
+func interruptSession(config *Config, sessName string) string {
+	dropped := pendingCount(sessName)
+	clearPendingMessages(sessName)
+	var report string
+	if isHeadless(config.Sessions[sessName]) {
+		if !stopHeadlessTurn(sessName) {
+			return "Nothing running in this session."
+		}
+		report = "🛑 Cancelled the headless Claude run"
+	} else {
+		tmuxName := sessionName(sessName)
+		if !tmuxSessionExists(tmuxName) || isClaudeExited(tmuxName) {
+			return "Claude is not running in this session."
+		}
+		if isClaudeIdle(tmuxName) && dropped == 0 {
+			return "Claude is idle — nothing to stop."
+		}
+		if err := exec.Command(tmuxPath, "send-keys", "-t", tmuxName, "Escape").Run(); err != nil {
+			return fmt.Sprintf("❌ Failed to interrupt: %v", err)
+		}
+		report = "🛑 Interrupted Claude"
+		monitorsMu.Lock()
+		if mon, exists := monitors[sessName]; exists && mon.LastPrompt != "" {
+			report += ": " + truncate(mon.LastPrompt, 100)
+		}
+		monitorsMu.Unlock()
+	}
+	if dropped > 0 {
+		report += fmt.Sprintf("\n🗑️ Dropped %d queued message(s)", dropped)
+	}
+	return report
+}
+
+// typeIntoSession starts a new turn: resets the monitor and types text into Claude's pane
+func typeIntoSession(sessName, text string) error {
+	ResetSessionMonitor(sessName)
+	SetSessionPrompt(sessName, text)
+	return sendToTmux(sessionName(sessName), text)
+}
+
+// restartSession recreates a session's tmux pane with `claude --continue` and
+// reports the outcome to Telegram once Claude is up.
+func restartSession(config *Config, chatID, threadID int64, name string) {
+	tmuxName := sessionName(name)
+	if tmuxSessionExists(tmuxName) {
+		killTmuxSession(tmuxName)
+		time.Sleep(300 * time.Millisecond)
+	}
+	// Clear monitor state and block cache for fresh start
+	ClearSessionMonitor(name)
+	// Use the stored path from config, fallback to resolveProjectPath
+	workDir := ""
+	if info := config.Sessions[name]; info != nil {
+		workDir = info.Path
+	}
+	if workDir == "" {
+		workDir = resolveProjectPath(config, name)
+	}
+	if _, err := os.Stat(workDir); os.IsNotExist(err) {
+		os.MkdirAll(workDir, 0755)
+	}
+	if err := createTmuxSession(tmuxName, workDir, true); err != nil {
+		sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to start: %v", err))
+		return
+	}
+	if info := config.Sessions[name]; info != nil && info.Hibernated {
+		info.Hibernated = false
+		if err := saveSession(name, info); err != nil {
+			hookLog("hibernate: session=%s saving restart: %v", name, err)
+		}
+	}
+	go reportSessionStart(config, chatID, threadID, tmuxName, fmt.Sprintf("🔄 Session '%s' restarted with conversation history", name))
+}
+
 func killSession(config *Config, name string) error {
 	if _, exists := config.Sessions[name]; !exists {
 		return fmt.Errorf("session '%s' not found", name)
@@ -60,7 +321,7 @@ func killSession(config *Config, name string) error {
 
 	// Remove from config
 	delete(config.Sessions, name)
-	saveConfig(config)
+	deleteSession(name)
 
 	return nil
 }
@@ -88,65 +349,121 @@ func startSession(continueSession bool) error {
 	config, err := loadConfig()
 	if err != nil {
 		// No config, just run claude directly
-		return runClaudeRaw(continueSession)
+		return runClaudeRaw(continueSession, "")
 	}
 
 	// Create topic if it doesn't exist and we have a group configured
-	if config.GroupID != 0 {
+	if hasSessionChannel(config) {
 		if _, exists := config.Sessions[name]; !exists {
-			topicID, err := createForumTopic(config, name)
+			topicID, err := getMessenger(config).CreateTopic(name)
 			if err == nil {
 				config.Sessions[name] = &SessionInfo{
 					TopicID: topicID,
 					Path:    cwd,
+					Machine: sessionMachine(config),
 				}
-				saveConfig(config)
+				saveSession(name, config.Sessions[name])
 				fmt.Printf("Created Telegram topic: %s\n", name)
 			}
 		}
 	}
 
-	// Check if tmux session exists
-	if tmuxSessionExists(tmuxName) {
-		// Check if we're already inside tmux
-		if os.Getenv("TMUX") != "" {
-			// Inside tmux: switch to the session
-			cmd := exec.Command(tmuxPath, "switch-client", "-t", tmuxName)
-			cmd.Stdin = os.Stdin
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			return cmd.Run()
-		}
-		// Outside tmux: attach to existing session
-		cmd := exec.Command(tmuxPath, "attach-session", "-t", tmuxName)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		return cmd.Run()
-	}
-
-	// Create new tmux session and attach
-	if err := createTmuxSession(tmuxName, cwd, continueSession); err != nil {
-		return err
+	// Create the tmux session unless it's already running
+	if !tmuxSessionExists(tmuxName) {
+		if err := createTmuxSession(tmuxName, cwd, continueSession); err != nil {
+			return err
+		}
 	}
+	return attachTmuxSession(tmuxName)
+}
 
-	// Check if we're already inside tmux
+// attachTmuxSession attaches the terminal to a tmux session, switching the
+// client instead when already inside tmux
+func attachTmuxSession(tmuxName string) error {
+	cmd := exec.Command(tmuxPath, "attach-session", "-t", tmuxName)
 	if os.Getenv("TMUX") != "" {
-		cmd := exec.Command(tmuxPath, "switch-client", "-t", tmuxName)
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		return cmd.Run()
+		cmd = exec.Command(tmuxPath, "switch-client", "-t", tmuxName)
 	}
-	cmd := exec.Command(tmuxPath, "attach-session", "-t", tmuxName)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
-// startDetached creates a Telegram topic, tmux session with Claude, and sends a prompt (no attach)
-func startDetached(name string, workDir string, prompt string) error {
+// startOptions configure a session started with ccc start
+type startOptions struct {
+	Continue         bool          // resume the directory's latest conversation
+	Headless         bool          // run claude -p per message instead of a tmux session
+	Timeout          time.Duration // stop the session after this long (0 = never)
+	NotifyOnComplete bool          // message the private chat when the first turn completes
+}
+
+const startUsage = "usage: ccc start [--continue] [--headless] [--timeout <duration>] [--notify-on-complete] <name> <work-dir> (<prompt> | --from-stdin)"
+
+// parseStartArgs parses `ccc start` arguments. Flags may come anywhere;
+// --from-stdin reads the prompt from stdin instead of the third argument.
+func parseStartArgs(args []string, stdin io.Reader) (name, workDir, prompt string, opts startOptions, err error) {
+	var positional []string
+	fromStdin := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--continue" || arg == "-c":
+			opts.Continue = true
+		case arg == "--headless":
+			opts.Headless = true
+		case arg == "--notify-on-complete":
+			opts.NotifyOnComplete = true
+		case arg == "--from-stdin":
+			fromStdin = true
+		case arg == "--timeout" && i+1 < len(args):
+			i++
+			opts.Timeout, err = time.ParseDuration(args[i])
+			if err != nil || opts.Timeout <= 0 {
+				return "", "", "", opts, fmt.Errorf("invalid timeout %q (e.g. 30m, 2h)", args[i])
+			}
+		case strings.HasPrefix(arg, "--"):
+			return "", "", "", opts, fmt.Errorf("unknown flag %s\n%s", arg, startUsage)
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	want := 3
+	if fromStdin {
+		want = 2
+	}
+	if len(positional) != want {
+		return "", "", "", opts, fmt.Errorf(startUsage)
+	}
+	name, workDir = positional[0], positional[1]
+	if fromStdin {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", "", "", opts, fmt.Errorf("reading prompt: %w", err)
+		}
+		prompt = string(data)
+	} else {
+		prompt = positional[2]
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return "", "", "", opts, fmt.Errorf("empty prompt")
+	}
+	return name, workDir, strings.TrimSpace(prompt), opts, nil
+}
+
+// topicLink returns a link to a session's topic, "" where the platform's
+// links need more than ccc knows
+func topicLink(config *Config, groupID, topicID int64) string {
+	if configuredMessenger(config) != messengerTelegram || groupID == 0 || topicID == 0 {
+		return ""
+	}
+	// Supergroup IDs are -100<id>; t.me/c links take the bare id
+	group := strings.TrimPrefix(strconv.FormatInt(groupID, 10), "-100")
+	return fmt.Sprintf("https://t.me/c/%s/%d", group, topicID)
+}
+
+// startDetached creates a Telegram topic and a session with Claude, and sends a prompt (no attach)
+func startDetached(name string, workDir string, prompt string, opts startOptions) error {
 	config, err := loadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
@@ -156,8 +473,8 @@ func startDetached(name string, workDir string, prompt string) error {
 		config.Sessions = make(map[string]*SessionInfo)
 	}
 
-	// Create Telegram topic
-	topicID, err := createForumTopic(config, name)
+	// Create Telegram topic / Discord thread
+	topicID, err := getMessenger(config).CreateTopic(name)
 	if err != nil {
 		return fmt.Errorf("failed to create topic: %w", err)
 	}
@@ -169,30 +486,102 @@ func startDetached(name string, workDir string, prompt string) error {
 		killTmuxSession(tmuxName)
 	}
 
-	// Create tmux session (detached)
-	if err := createTmuxSession(tmuxName, workDir, false); err != nil {
-		return fmt.Errorf("failed to create tmux session: %w", err)
+	info := &SessionInfo{
+		TopicID:          topicID,
+		Path:             workDir,
+		NotifyOnComplete: opts.NotifyOnComplete,
+		Machine:          sessionMachine(config),
 	}
-
-	// Save session info
-	config.Sessions[name] = &SessionInfo{
-		TopicID: topicID,
-		Path:    workDir,
+	if opts.Timeout > 0 {
+		info.Deadline = time.Now().Add(opts.Timeout).Unix()
 	}
-	if err := saveConfig(config); err != nil {
-		return fmt.Errorf("failed to save config: %w", err)
+
+	if opts.Headless {
+		info.Mode = sessionModeHeadless
+		if !opts.Continue {
+			// A new ID starts a new conversation instead of continuing the directory's latest
+			info.ClaudeSessionID = newClaudeSessionID()
+		}
+		config.Sessions[name] = info
+		if err := saveSession(name, info); err != nil {
+			return fmt.Errorf("failed to save session: %w", err)
+		}
+		// Headless turns run in the listener
+		err := callControl("send", map[string]string{"session": name, "text": prompt}, nil, 10*time.Second)
+		if err == errControlUnavailable {
+			return fmt.Errorf("headless sessions run in the listener; start it with ccc listen")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to send prompt: %w", err)
+		}
+	} else {
+		// Create tmux session (detached)
+		if err := createTmuxSession(tmuxName, workDir, opts.Continue); err != nil {
+			return fmt.Errorf("failed to create tmux session: %w", err)
+		}
+
+		config.Sessions[name] = info
+		if err := saveSession(name, info); err != nil {
+			return fmt.Errorf("failed to save session: %w", err)
+		}
+
+		// Wait for Claude to be ready before sending prompt
+		if err := waitForSessionStart(config, tmuxName); err != nil {
+			return fmt.Errorf("claude did not start in time: %w", err)
+		}
+
+		// Send the prompt to the tmux session
+		if err := sendToTmux(tmuxName, prompt); err != nil {
+			return fmt.Errorf("failed to send prompt: %w", err)
+		}
 	}
 
-	// Wait for Claude to be ready before sending prompt
-	if err := waitForClaude(tmuxName, 30*time.Second); err != nil {
-		return fmt.Errorf("claude did not start in time: %w", err)
+	if link := topicLink(config, config.GroupID, topicID); link != "" {
+		fmt.Printf("Session '%s' started: %s\n", name, link)
+	} else {
+		fmt.Printf("Session '%s' started with topic %d\n", name, topicID)
 	}
+	return nil
+}
 
-	// Send the prompt to the tmux session
-	if err := sendToTmux(tmuxName, prompt); err != nil {
-		return fmt.Errorf("failed to send prompt: %w", err)
+// notifyTurnComplete tells the private chat a session started with
+// --notify-on-complete has finished its first turn
+func notifyTurnComplete(config *Config, sessName string, info *SessionInfo, outcome string) {
+	if !info.NotifyOnComplete || config.ChatID == 0 {
+		return
 	}
+	info.NotifyOnComplete = false
+	saveSession(sessName, info)
+	text := fmt.Sprintf("%s %s", outcome, sessName)
+	if link := topicLink(config, sessionGroup(config, info), info.TopicID); link != "" {
+		text += "\n" + link
+	}
+	getMessenger(config).Send(config.ChatID, 0, text)
+}
 
-	fmt.Printf("Session '%s' started in tmux '%s' with topic %d\n", name, tmuxName, topicID)
-	return nil
+// checkSessionDeadline stops a session started with --timeout once its time
+// is up. It reports whether the session was stopped.
+func checkSessionDeadline(config *Config, sessName string, info *SessionInfo) bool {
+	if info == nil || info.Deadline == 0 || time.Now().Unix() < info.Deadline {
+		return false
+	}
+	info.Deadline = 0
+	if err := saveSession(sessName, info); err != nil {
+		hookLog("deadline: session=%s: %v", sessName, err)
+		return false
+	}
+	resume := "Your next message resumes the conversation."
+	if isHeadless(info) {
+		stopHeadlessTurn(sessName)
+	} else {
+		if tmuxName := sessionName(sessName); tmuxSessionExists(tmuxName) {
+			killTmuxSession(tmuxName)
+		}
+		ClearSessionMonitor(sessName)
+		resume = "/continue restarts it keeping the conversation."
+	}
+	hookLog("deadline: session=%s stopped at its timeout", sessName)
+	getMessenger(config).Send(sessionGroup(config, info), info.TopicID, fmt.Sprintf("⏱️ Session '%s' reached its timeout and was stopped. %s", sessName, resume))
+	notifyTurnComplete(config, sessName, info, "⏱️ Timed out:")
+	return true
 }
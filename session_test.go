@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStartArgs(t *testing.T) {
+	name, dir, prompt, opts, err := parseStartArgs([]string{"nightly", "--headless", "/srv/app", "--timeout", "2h", "run the tests", "--notify-on-complete"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "nightly" || dir != "/srv/app" || prompt != "run the tests" {
+		t.Errorf("positional = %q %q %q", name, dir, prompt)
+	}
+	want := startOptions{Headless: true, Timeout: 2 * time.Hour, NotifyOnComplete: true}
+	if opts != want {
+		t.Errorf("opts = %+v, want %+v", opts, want)
+	}
+
+	_, _, prompt, opts, err = parseStartArgs([]string{"--continue", "--from-stdin", "nightly", "/srv/app"}, strings.NewReader("fix the build\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt != "fix the build" || !opts.Continue {
+		t.Errorf("from stdin: prompt = %q, opts = %+v", prompt, opts)
+	}
+
+	for _, args := range [][]string{
+		{"nightly", "/srv/app"},
+		{"nightly", "/srv/app", "go", "extra"},
+		{"--from-stdin", "nightly", "/srv/app", "prompt"},
+		{"--timeout", "soon", "nightly", "/srv/app", "go"},
+		{"--verbose", "nightly", "/srv/app", "go"},
+	} {
+		if _, _, _, _, err := parseStartArgs(args, strings.NewReader("x")); err == nil {
+			t.Errorf("parseStartArgs(%q) succeeded", args)
+		}
+	}
+	if _, _, _, _, err := parseStartArgs([]string{"--from-stdin", "nightly", "/srv/app"}, strings.NewReader("  \n")); err == nil {
+		t.Error("empty stdin prompt accepted")
+	}
+}
+
+func TestTopicLink(t *testing.T) {
+	config := &Config{GroupID: -1001234567890}
+	if got := topicLink(config, config.GroupID, 42); got != "https://t.me/c/1234567890/42" {
+		t.Errorf("topicLink() = %q", got)
+	}
+	if got := topicLink(&Config{Messenger: messengerDiscord}, -1001234567890, 42); got != "" {
+		t.Errorf("topicLink() on Discord = %q, want none", got)
+	}
+}
+
+func TestForwardToStartingSession(t *testing.T) {
+	startingSessionsMu.Lock()
+	startingSessions["boot"] = true
+	startingSessionsMu.Unlock()
+	defer func() {
+		startingSessionsMu.Lock()
+		delete(startingSessions, "boot")
+		startingSessionsMu.Unlock()
+		clearPendingMessages("boot")
+	}()
+
+	config := &Config{Sessions: map[string]*SessionInfo{"boot": {TopicID: 100, Path: t.TempDir()}}}
+	msgr := &recordingMessenger{}
+	forwardToSession(config, msgr, 1, 100, "boot", "hello")
+	if pendingCount("boot") != 1 {
+		t.Errorf("message to a starting session wasn't queued: %d pending", pendingCount("boot"))
+	}
+	if sent := msgr.texts(); len(sent) != 1 || !strings.HasPrefix(sent[0], "⏳ queued, 0 ahead") {
+		t.Errorf("sent %q, want the queue notice", sent)
+	}
+	if !sessionStarting("boot") || sessionStarting("other") {
+		t.Error("sessionStarting should only report the starting session")
+	}
+}
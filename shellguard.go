@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDestructivePatterns are the commands /c asks about before running
+// when confirmation is on and no destructive_patterns are configured
+var defaultDestructivePatterns = []string{
+	`\brm\s+(-\S+\s+)*-[a-zA-Z]*[rRf]`, // rm -rf, rm -r, rm -f
+	`\bdd\s`,
+	`\bmkfs`,
+	`\b(shutdown|reboot|halt|poweroff)\b`,
+	`\bkill(all)?\s+-(9|KILL)\b`,
+	`>\s*/dev/(sd|nvme|disk|hd)`,
+	`\bgit\s+push\b.*(--force|\s-f\b)`,
+	`\bgit\s+(reset\s+--hard|clean\s+-[a-zA-Z]*f)`,
+	`\b(chmod|chown)\s+-R\b`,
+	`:\(\)\s*\{`, // fork bomb
+}
+
+// commandCallbackPrefix marks /c confirmation buttons. Callback data is
+// "cmd:<id>:<run|cancel>".
+const commandCallbackPrefix = "cmd:"
+
+// commandConfirmTimeout is how long a confirmation keyboard stays valid
+const commandConfirmTimeout = 5 * time.Minute
+
+// pendingCommand is a /c command waiting for Run / Cancel
+type pendingCommand struct {
+	ChatID, ThreadID int64
+	Command, Dir     string
+	Created          time.Time
+}
+
+var (
+	pendingCommands   = make(map[string]*pendingCommand)
+	pendingCommandsMu sync.Mutex
+)
+
+// matchDestructive returns the pattern a command matches, or "" if none.
+// Invalid configured patterns are skipped.
+func matchDestructive(config *Config, cmdStr string) string {
+	patterns := config.DestructivePatterns
+	if len(patterns) == 0 {
+		patterns = defaultDestructivePatterns
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			hookLog("shellguard: invalid pattern %q: %v", p, err)
+			continue
+		}
+		if re.MatchString(cmdStr) {
+			return p
+		}
+	}
+	return ""
+}
+
+// requestCommandConfirmation holds a command and asks Run / Cancel
+func requestCommandConfirmation(config *Config, chatID, threadID int64, cmdStr, dir string) error {
+	idBytes := make([]byte, 4)
+	rand.Read(idBytes)
+	id := hex.EncodeToString(idBytes)
+
+	pendingCommandsMu.Lock()
+	now := time.Now()
+	for k, p := range pendingCommands {
+		if now.Sub(p.Created) > commandConfirmTimeout {
+			delete(pendingCommands, k)
+		}
+	}
+	pendingCommands[id] = &pendingCommand{ChatID: chatID, ThreadID: threadID, Command: cmdStr, Dir: dir, Created: now}
+	pendingCommandsMu.Unlock()
+
+	auditCommand(chatID, threadID, dir, cmdStr, "held for confirmation")
+	msg := fmt.Sprintf("⚠️ This looks destructive:\n\n$ %s\n\nin %s", cmdStr, dir)
+	buttons := [][]InlineKeyboardButton{{
+		{Text: "▶️ Run", CallbackData: commandCallbackPrefix + id + ":run"},
+		{Text: "✖️ Cancel", CallbackData: commandCallbackPrefix + id + ":cancel"},
+	}}
+	return sendMessageWithKeyboard(config, chatID, threadID, msg, buttons)
+}
+
+// takePendingCommand resolves a confirmation button press. It returns the
+// held command (nil if unknown or expired) and the label to show.
+func takePendingCommand(data string) (*pendingCommand, bool, string) {
+	parts := strings.SplitN(strings.TrimPrefix(data, commandCallbackPrefix), ":", 2)
+	if len(parts) != 2 {
+		return nil, false, ""
+	}
+	pendingCommandsMu.Lock()
+	p, ok := pendingCommands[parts[0]]
+	delete(pendingCommands, parts[0])
+	pendingCommandsMu.Unlock()
+	if !ok || time.Since(p.Created) > commandConfirmTimeout {
+		return nil, false, "⌛ Expired — send the command again"
+	}
+
+	if parts[1] == "run" {
+		auditCommand(p.ChatID, p.ThreadID, p.Dir, p.Command, "confirmed")
+		return p, true, "▶️ Running"
+	}
+	auditCommand(p.ChatID, p.ThreadID, p.Dir, p.Command, "cancelled")
+	return p, false, "✖️ Cancelled"
+}
+
+// auditEntry is one line of the /c audit log
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	ChatID   int64     `json:"chat_id"`
+	ThreadID int64     `json:"thread_id,omitempty"`
+	Dir      string    `json:"dir"`
+	Command  string    `json:"command"`
+	Event    string    `json:"event"`
+}
+
+// auditLogPath is where every /c execution is recorded, one JSON object per line
+func auditLogPath() string {
+	return migrateLegacyFile(legacyFile("-audit.log"), stateFile("-audit.log"))
+}
+
+// auditCommand appends an event for a /c command to the audit log
+func auditCommand(chatID, threadID int64, dir, cmdStr, event string) {
+	data, _ := json.Marshal(auditEntry{time.Now(), chatID, threadID, dir, cmdStr, event})
+	f, err := os.OpenFile(auditLogPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		hookLog("audit: %v", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
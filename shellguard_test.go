@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchDestructive(t *testing.T) {
+	config := &Config{}
+	for _, cmd := range []string{
+		"rm -rf /tmp/x",
+		"rm -r build",
+		"sudo rm -v -f file",
+		"dd if=/dev/zero of=/dev/sda",
+		"mkfs.ext4 /dev/sdb1",
+		"sudo shutdown -h now",
+		"kill -9 1234",
+		"echo x > /dev/sda",
+		"git push --force origin main",
+		"git reset --hard HEAD~3",
+		"git clean -fdx",
+		"chmod -R 777 /",
+	} {
+		if matchDestructive(config, cmd) == "" {
+			t.Errorf("%q should be destructive", cmd)
+		}
+	}
+	for _, cmd := range []string{"ls -la", "rm file.txt", "git push", "git status", "df -h", "echo add"} {
+		if p := matchDestructive(config, cmd); p != "" {
+			t.Errorf("%q matched %q", cmd, p)
+		}
+	}
+
+	// Configured patterns replace the defaults; invalid ones are skipped
+	config.DestructivePatterns = []string{"(", `\bdocker\s+rm\b`}
+	if matchDestructive(config, "docker rm web") == "" {
+		t.Error("configured pattern should match")
+	}
+	if matchDestructive(config, "rm -rf /") != "" {
+		t.Error("defaults should not apply when patterns are configured")
+	}
+}
+
+func TestCommandConfirmationFlow(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	pendingCommandsMu.Lock()
+	pendingCommands["aaaa1111"] = &pendingCommand{ChatID: 1, ThreadID: 2, Command: "rm -rf build", Dir: "/p", Created: time.Now()}
+	pendingCommands["bbbb2222"] = &pendingCommand{ChatID: 1, Command: "dd", Dir: "/p", Created: time.Now().Add(-time.Hour)}
+	pendingCommandsMu.Unlock()
+
+	p, run, label := takePendingCommand("cmd:aaaa1111:run")
+	if !run || p == nil || p.Command != "rm -rf build" || label == "" {
+		t.Errorf("run press = (%+v, %v, %q)", p, run, label)
+	}
+	if _, run, _ := takePendingCommand("cmd:aaaa1111:run"); run {
+		t.Error("a command must run at most once")
+	}
+	if _, run, label := takePendingCommand("cmd:bbbb2222:run"); run || !strings.Contains(label, "Expired") {
+		t.Errorf("expired press = (%v, %q)", run, label)
+	}
+
+	data, err := os.ReadFile(auditLogPath())
+	if err != nil {
+		t.Fatalf("audit log not written: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var entry auditEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil || entry.Command != "rm -rf build" || entry.Event != "confirmed" || entry.ThreadID != 2 {
+		t.Errorf("audit entry = %+v (%v)", entry, err)
+	}
+}
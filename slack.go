@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Slack backend: each session is a thread under a parent message in
+// SlackChannelID. Socket Mode delivers events over an outbound websocket, so
+// no public HTTP endpoint is needed (works behind corporate firewalls).
+//
+// Slack identifies threads and messages by a "ts" like "1700000000.123456".
+// It is stored in SessionInfo.TopicID (and block cache message IDs) as the
+// integer 1700000000123456; see slackTSToID / slackIDToTS.
+
+// slackAPIBase is a variable so tests can point it at a local server
+var slackAPIBase = "https://slack.com/api"
+
+// slackMaxLen keeps messages under Slack's recommended 4000 character limit
+const slackMaxLen = 3900
+
+var slackClient = &http.Client{Timeout: 30 * time.Second}
+
+// slackTSToID converts a Slack ts ("1700000000.123456") to an int64 ID
+func slackTSToID(ts string) (int64, error) {
+	sec, micro, ok := strings.Cut(ts, ".")
+	if !ok || len(micro) != 6 {
+		return 0, fmt.Errorf("invalid slack ts %q", ts)
+	}
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid slack ts %q", ts)
+	}
+	m, err := strconv.ParseInt(micro, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid slack ts %q", ts)
+	}
+	return s*1000000 + m, nil
+}
+
+// slackIDToTS converts an ID from slackTSToID back to a Slack ts
+func slackIDToTS(id int64) string {
+	return fmt.Sprintf("%d.%06d", id/1000000, id%1000000)
+}
+
+// slackAPI calls a Slack Web API method with a JSON body and decodes the reply into out
+func slackAPI(token, method string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", slackAPIBase+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := slackClient.Do(req)
+	if err != nil {
+		return redactTokenError(err, token)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("slack %s: bad response (%d)", method, resp.StatusCode)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack %s: %s", method, result.Error)
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// slackMessenger delivers messages through the Slack Web API
+type slackMessenger struct {
+	config *Config
+}
+
+// post sends one message (optionally with Block Kit blocks) and returns its ts as an ID.
+// threadID 0 posts to the channel itself; chatID is a Telegram concept and is ignored.
+func (s *slackMessenger) post(threadID int64, text string, blocks interface{}) (int64, error) {
+	payload := map[string]interface{}{
+		"channel": s.config.SlackChannelID,
+		"text":    text,
+	}
+	if threadID > 0 {
+		payload["thread_ts"] = slackIDToTS(threadID)
+	}
+	if blocks != nil {
+		payload["blocks"] = blocks
+	}
+	var result struct {
+		TS string `json:"ts"`
+	}
+	if err := slackAPI(s.config.SlackBotToken, "chat.postMessage", payload, &result); err != nil {
+		return 0, err
+	}
+	return slackTSToID(result.TS)
+}
+
+func (s *slackMessenger) Send(chatID, threadID int64, text string) error {
+	_, err := s.SendGetID(chatID, threadID, text)
+	return err
+}
+
+func (s *slackMessenger) SendGetID(chatID, threadID int64, text string) (int64, error) {
+	var lastMsgID int64
+	for _, part := range splitMessage(text, slackMaxLen) {
+		id, err := s.post(threadID, part, nil)
+		if err != nil {
+			return 0, err
+		}
+		lastMsgID = id
+	}
+	return lastMsgID, nil
+}
+
+// Edit replaces a message's text, sending overflow as new messages
+func (s *slackMessenger) Edit(chatID, messageID, threadID int64, text string) error {
+	parts := splitMessage(text, slackMaxLen)
+	err := slackAPI(s.config.SlackBotToken, "chat.update", map[string]interface{}{
+		"channel": s.config.SlackChannelID,
+		"ts":      slackIDToTS(messageID),
+		"text":    parts[0],
+	}, nil)
+	if err != nil {
+		return err
+	}
+	for _, part := range parts[1:] {
+		if _, err := s.post(threadID, part, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendFormatted sends text as is: Slack renders code fences and inline code itself
+func (s *slackMessenger) SendFormatted(chatID, threadID int64, text string) (int64, error) {
+	return s.SendGetID(chatID, threadID, text)
+}
+
+func (s *slackMessenger) EditFormatted(chatID, messageID, threadID int64, text string) error {
+	return s.Edit(chatID, messageID, threadID, text)
+}
+
+// slackButtonBlocks renders text plus one Block Kit actions block per keyboard row.
+// The button value carries the same callback data Telegram uses.
+func slackButtonBlocks(text string, buttons [][]InlineKeyboardButton) []map[string]interface{} {
+	blocks := []map[string]interface{}{{
+		"type": "section",
+		"text": map[string]string{"type": "mrkdwn", "text": text},
+	}}
+	for i, row := range buttons {
+		var elements []map[string]interface{}
+		for j, b := range row {
+			elements = append(elements, map[string]interface{}{
+				"type":      "button",
+				"text":      map[string]string{"type": "plain_text", "text": truncate(b.Text, 75)},
+				"value":     b.CallbackData,
+				"action_id": fmt.Sprintf("ccc_%d_%d", i, j),
+			})
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "actions", "elements": elements})
+	}
+	return blocks
+}
+
+func (s *slackMessenger) SendWithKeyboard(chatID, threadID int64, text string, buttons [][]InlineKeyboardButton) error {
+	_, err := s.post(threadID, truncate(text, 3000), slackButtonBlocks(truncate(text, 3000), buttons))
+	return err
+}
+
+// SendFile uploads a file with the external upload flow
+// (getUploadURLExternal, upload, completeUploadExternal)
+func (s *slackMessenger) SendFile(chatID, threadID int64, filePath string, caption string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	// getUploadURLExternal only accepts form parameters
+	params := url.Values{
+		"filename": {filepath.Base(filePath)},
+		"length":   {strconv.Itoa(len(data))},
+	}
+	req, err := http.NewRequest("POST", slackAPIBase+"/files.getUploadURLExternal", strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.config.SlackBotToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := slackClient.Do(req)
+	if err != nil {
+		return redactTokenError(err, s.config.SlackBotToken)
+	}
+	var upload struct {
+		OK        bool   `json:"ok"`
+		Error     string `json:"error"`
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&upload)
+	resp.Body.Close()
+	if !upload.OK {
+		return fmt.Errorf("slack files.getUploadURLExternal: %s", upload.Error)
+	}
+
+	resp, err = slackClient.Post(upload.UploadURL, "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("slack upload failed: HTTP %d", resp.StatusCode)
+	}
+
+	complete := map[string]interface{}{
+		"files":      []map[string]string{{"id": upload.FileID, "title": filepath.Base(filePath)}},
+		"channel_id": s.config.SlackChannelID,
+	}
+	if caption != "" {
+		complete["initial_comment"] = caption
+	}
+	if threadID > 0 {
+		complete["thread_ts"] = slackIDToTS(threadID)
+	}
+	return slackAPI(s.config.SlackBotToken, "files.completeUploadExternal", complete, nil)
+}
+
+// CreateTopic posts a parent message for the session; its thread is the session
+func (s *slackMessenger) CreateTopic(name string) (int64, error) {
+	if s.config.SlackChannelID == "" {
+		return 0, fmt.Errorf("no Slack channel configured. Run: ccc config slack-channel <id>")
+	}
+	return s.post(0, fmt.Sprintf("🧵 Session: %s", name), nil)
+}
+
+// DeleteTopic deletes the session's parent message
+func (s *slackMessenger) DeleteTopic(topicID int64) error {
+	return slackAPI(s.config.SlackBotToken, "chat.delete", map[string]interface{}{
+		"channel": s.config.SlackChannelID,
+		"ts":      slackIDToTS(topicID),
+	}, nil)
+}
+
+// slackEnvelope is a Socket Mode message
+type slackEnvelope struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Type       string          `json:"type"` // hello, events_api, interactive, disconnect
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// slackEventPayload is the events_api payload for message events
+type slackEventPayload struct {
+	Event struct {
+		Type     string `json:"type"`
+		Subtype  string `json:"subtype"`
+		BotID    string `json:"bot_id"`
+		User     string `json:"user"`
+		Channel  string `json:"channel"`
+		Text     string `json:"text"`
+		TS       string `json:"ts"`
+		ThreadTS string `json:"thread_ts"`
+	} `json:"event"`
+}
+
+// slackInteractivePayload is the block_actions payload for button presses
+type slackInteractivePayload struct {
+	Type string `json:"type"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Message struct {
+		TS   string `json:"ts"`
+		Text string `json:"text"`
+	} `json:"message"`
+	Actions []struct {
+		Value string `json:"value"`
+	} `json:"actions"`
+}
+
+// listenSlack connects over Socket Mode and handles events until killed,
+// reconnecting whenever Slack drops the connection.
+func listenSlack(config *Config) error {
+	if config.SlackAppToken == "" || config.SlackBotToken == "" || config.SlackChannelID == "" || config.SlackUserID == "" {
+		return fmt.Errorf("slack not configured. Run: ccc config slack-app-token <xapp-...>, slack-bot-token <xoxb-...>, slack-channel <id>, slack-user <id>")
+	}
+
+	fmt.Printf("Slack bot listening... (channel: %s)\n", config.SlackChannelID)
+	fmt.Printf("Active sessions: %d\n", len(config.Sessions))
+
+	go startSessionMonitor(config)
+	go startScheduler()
+	go startControlServer()
+
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := runSlackSocket(config)
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		fmt.Fprintf(os.Stderr, "Slack connection closed: %v (reconnecting in %v)\n", err, backoff)
+		time.Sleep(backoff)
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// runSlackSocket runs one Socket Mode connection until it fails or Slack asks us to reconnect
+func runSlackSocket(config *Config) error {
+	var open struct {
+		URL string `json:"url"`
+	}
+	if err := slackAPI(config.SlackAppToken, "apps.connections.open", map[string]string{}, &open); err != nil {
+		return err
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(open.URL, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for {
+		var env slackEnvelope
+		if err := conn.ReadJSON(&env); err != nil {
+			return err
+		}
+		// Acknowledge within 3 seconds or Slack retries the delivery
+		if env.EnvelopeID != "" {
+			if err := conn.WriteJSON(map[string]string{"envelope_id": env.EnvelopeID}); err != nil {
+				return err
+			}
+		}
+
+		switch env.Type {
+		case "disconnect":
+			return fmt.Errorf("disconnect requested")
+		case "events_api":
+			var p slackEventPayload
+			if json.Unmarshal(env.Payload, &p) == nil {
+				go handleSlackMessage(config, p)
+			}
+		case "interactive":
+			var p slackInteractivePayload
+			if json.Unmarshal(env.Payload, &p) == nil {
+				go handleSlackInteraction(config, p)
+			}
+		}
+	}
+}
+
+// handleSlackMessage forwards the configured user's replies in a session thread to Claude
+func handleSlackMessage(config *Config, p slackEventPayload) {
+	ev := p.Event
+	if ev.Type != "message" || ev.Subtype != "" || ev.BotID != "" || ev.User != config.SlackUserID {
+		return
+	}
+	text := strings.TrimSpace(ev.Text)
+	if ev.Channel != config.SlackChannelID || ev.ThreadTS == "" || text == "" {
+		return
+	}
+	threadID, err := slackTSToID(ev.ThreadTS)
+	if err != nil {
+		return
+	}
+
+	config, _ = loadConfig()
+	msgr := getMessenger(config)
+	sessName := getSessionByTopic(config, threadID)
+	if sessName == "" {
+		msgr.Send(0, threadID, "⚠️ No session linked to this thread.")
+		return
+	}
+	forwardToSession(config, msgr, 0, threadID, sessName, text)
+}
+
+// handleSlackInteraction answers permission and AskUserQuestion buttons
+func handleSlackInteraction(config *Config, p slackInteractivePayload) {
+	if p.Type != "block_actions" || p.User.ID != config.SlackUserID || len(p.Actions) == 0 {
+		return
+	}
+	value := p.Actions[0].Value
+
+	if strings.HasPrefix(value, permissionCallbackPrefix) {
+		if label, ok := handlePermissionCallback(value); ok {
+			slackAPI(config.SlackBotToken, "chat.update", map[string]interface{}{
+				"channel": config.SlackChannelID,
+				"ts":      p.Message.TS,
+				"text":    p.Message.Text + "\n\n" + label,
+				"blocks":  []interface{}{},
+			}, nil)
+		}
+		return
+	}
+
+	qc, ok := parseQuestionCallback(value)
+	if !ok {
+		return
+	}
+
+	// Replace the buttons with the selection
+	slackAPI(config.SlackBotToken, "chat.update", map[string]interface{}{
+		"channel": config.SlackChannelID,
+		"ts":      p.Message.TS,
+		"text":    fmt.Sprintf("%s\n\n✓ Selected option %d", p.Message.Text, qc.OptionIndex+1),
+		"blocks":  []interface{}{},
+	}, nil)
+	answerQuestion(qc)
+}
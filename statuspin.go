@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// statusPinInterval is the least time between edits of a status message
+	statusPinInterval = 10 * time.Second
+	// statusUsageInterval is how often the token usage on it is re-read from
+	// the transcripts
+	statusUsageInterval = time.Minute
+)
+
+// toolCallHeader matches a block that is a tool call: "Bash(go test ./...)"
+var toolCallHeader = regexp.MustCompile(`^([A-Z][\w-]*)\(`)
+
+// statusPin is the last text put on a session's status message
+type statusPin struct {
+	Text        string
+	Edited      time.Time
+	Usage       string
+	UsageLoaded time.Time
+}
+
+var (
+	statusPins   = make(map[string]*statusPin)
+	statusPinsMu sync.Mutex
+)
+
+// statusSnapshot is what the status message shows
+type statusSnapshot struct {
+	Session string
+	State   string // topicWorking, topicIdle or topicError
+	Tool    string
+	Elapsed time.Duration // on the current task, 0 when idle
+	Prompt  string
+	Usage   string
+	Alert   string // failure shown in the pane, for topicError
+}
+
+// currentToolFromBlocks returns the tool of the last block if it is a tool call
+func currentToolFromBlocks(blocks []string) string {
+	if len(blocks) == 0 {
+		return ""
+	}
+	header := strings.SplitN(blocks[len(blocks)-1], "\n", 2)[0]
+	if m := toolCallHeader.FindStringSubmatch(strings.TrimSpace(header)); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// formatStatusPin renders the status message. Elapsed time is shown in whole
+// minutes so a running task edits it at most once a minute.
+func formatStatusPin(s statusSnapshot) string {
+	var sb strings.Builder
+	switch s.State {
+	case topicWorking:
+		fmt.Fprintf(&sb, "📌 %s — ⏳ working", s.Session)
+	case topicError:
+		if s.Alert != "" {
+			fmt.Fprintf(&sb, "📌 %s — 🚨 %s", s.Session, s.Alert)
+		} else {
+			fmt.Fprintf(&sb, "📌 %s — 💥 Claude exited", s.Session)
+		}
+	default:
+		fmt.Fprintf(&sb, "📌 %s — 💤 idle", s.Session)
+	}
+	if s.State == topicWorking {
+		if s.Tool != "" {
+			sb.WriteString("\n🔧 " + s.Tool)
+		}
+		if s.Elapsed > 0 {
+			fmt.Fprintf(&sb, "\n⏱ %s on this task", formatDuration(s.Elapsed.Truncate(time.Minute)))
+		}
+	}
+	if s.Prompt != "" {
+		sb.WriteString("\n💬 " + truncate(strings.ReplaceAll(s.Prompt, "\n", " "), 100))
+	}
+	if s.Usage != "" {
+		sb.WriteString("\n📊 today: " + s.Usage)
+	}
+	return sb.String()
+}
+
+// updateStatusPin keeps a session's pinned status message current, sending
+// and pinning it first if the session has none
+func updateStatusPin(config *Config, sessName string, info *SessionInfo, mon *SessionMonitor, state string) {
+	if !config.StatusPin || configuredMessenger(config) != messengerTelegram || info.TopicID == 0 {
+		return
+	}
+	now := time.Now()
+
+	statusPinsMu.Lock()
+	pin := statusPins[sessName]
+	if pin == nil {
+		pin = &statusPin{}
+		statusPins[sessName] = pin
+	}
+	if now.Sub(pin.Edited) < statusPinInterval {
+		statusPinsMu.Unlock()
+		return
+	}
+	loadUsage := now.Sub(pin.UsageLoaded) >= statusUsageInterval
+	usage := pin.Usage
+	statusPinsMu.Unlock()
+
+	if loadUsage && info.Path != "" {
+		startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		usage = ""
+		if u := projectUsage(info.Path, startOfDay); u.totalTokens() > 0 {
+			usage = formatUsageLine(u)
+		}
+	}
+
+	snap := statusSnapshot{Session: sessName, State: state, Usage: usage}
+	monitorsMu.Lock()
+	snap.Tool, snap.Prompt, snap.Alert = mon.CurrentTool, mon.LastPrompt, mon.Alert
+	if !mon.TurnStarted.IsZero() {
+		snap.Elapsed = now.Sub(mon.TurnStarted)
+	}
+	monitorsMu.Unlock()
+	text := formatStatusPin(snap)
+
+	statusPinsMu.Lock()
+	if loadUsage {
+		pin.Usage, pin.UsageLoaded = usage, now
+	}
+	unchanged := text == pin.Text && info.StatusMsgID != 0
+	statusPinsMu.Unlock()
+	if unchanged {
+		return
+	}
+
+	if info.StatusMsgID != 0 {
+		gone, err := editStatusPin(config, sessionGroup(config, info), info.StatusMsgID, text)
+		if err != nil {
+			// Network trouble: try again on a later tick
+			return
+		}
+		if gone {
+			info.StatusMsgID = 0
+		}
+	}
+	if info.StatusMsgID == 0 {
+		msgID, err := sendMessageGetID(config, sessionGroup(config, info), info.TopicID, text)
+		if err != nil {
+			hookLog("statuspin: session=%s send failed: %v", sessName, err)
+			return
+		}
+		if err := pinMessage(config, sessionGroup(config, info), msgID); err != nil {
+			hookLog("statuspin: session=%s pin failed: %v", sessName, err)
+		}
+		info.StatusMsgID = msgID
+		if err := saveSession(sessName, info); err != nil {
+			hookLog("statuspin: session=%s saving message ID: %v", sessName, err)
+		}
+	}
+
+	statusPinsMu.Lock()
+	pin.Text, pin.Edited = text, now
+	statusPinsMu.Unlock()
+}
+
+// editStatusPin edits the status message, reporting whether it is gone
+// (deleted in Telegram) and needs sending again
+func editStatusPin(config *Config, chatID, msgID int64, text string) (bool, error) {
+	result, err := telegramAPI(config, "editMessageText", url.Values{
+		"chat_id":    {fmt.Sprintf("%d", chatID)},
+		"message_id": {fmt.Sprintf("%d", msgID)},
+		"text":       {text},
+	})
+	if err != nil {
+		return false, err
+	}
+	return !result.OK && !strings.Contains(result.Description, "not modified"), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCurrentToolFromBlocks(t *testing.T) {
+	if got := currentToolFromBlocks([]string{"Bash(ls)", "Read(main.go)\n⎿  Read 20 lines"}); got != "Read" {
+		t.Errorf("tool = %q, want Read", got)
+	}
+	if got := currentToolFromBlocks([]string{"Bash(ls)", "All done, the tests pass."}); got != "" {
+		t.Errorf("a text block is no tool call, got %q", got)
+	}
+}
+
+func TestFormatStatusPin(t *testing.T) {
+	got := formatStatusPin(statusSnapshot{
+		Session: "api",
+		State:   topicWorking,
+		Tool:    "Bash",
+		Elapsed: 3*time.Minute + 40*time.Second,
+		Prompt:  "fix the\nlogin bug",
+		Usage:   "$0.12 · 1.2k in / 300 out · 5.0k cached",
+	})
+	want := "📌 api — ⏳ working\n🔧 Bash\n⏱ 3m on this task\n💬 fix the login bug\n📊 today: $0.12 · 1.2k in / 300 out · 5.0k cached"
+	if got != want {
+		t.Errorf("working status =\n%s\nwant\n%s", got, want)
+	}
+
+	got = formatStatusPin(statusSnapshot{Session: "api", State: topicIdle, Tool: "Bash", Elapsed: time.Minute})
+	if got != "📌 api — 💤 idle" {
+		t.Errorf("idle status shows the tool or time: %q", got)
+	}
+
+	got = formatStatusPin(statusSnapshot{Session: "api", State: topicError, Alert: "Claude usage limit reached"})
+	if got != "📌 api — 🚨 Claude usage limit reached" {
+		t.Errorf("failure status = %q", got)
+	}
+}
+
+func TestUpdateStatusPin(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	calls, _, done := fakeTelegram(t)
+	defer done()
+
+	config := &Config{BotToken: "test", GroupID: -100, StatusPin: true}
+	info := &SessionInfo{TopicID: 5}
+	mon := &SessionMonitor{LastPrompt: "hello"}
+	updateStatusPin(config, "pin-test", info, mon, topicIdle)
+	if info.StatusMsgID != 7 || *calls != 2 {
+		t.Fatalf("status message %d after %d calls, want it sent and pinned", info.StatusMsgID, *calls)
+	}
+	stored, _ := loadSessions()
+	if stored["pin-test"] == nil || stored["pin-test"].StatusMsgID != 7 {
+		t.Error("the status message ID should be saved with the session")
+	}
+
+	// Within statusPinInterval nothing is edited, even on a state change
+	updateStatusPin(config, "pin-test", info, mon, topicWorking)
+	if *calls != 2 {
+		t.Errorf("edited again within %v", statusPinInterval)
+	}
+	statusPinsMu.Lock()
+	statusPins["pin-test"].Edited = time.Time{}
+	statusPinsMu.Unlock()
+	updateStatusPin(config, "pin-test", info, mon, topicWorking)
+	if *calls != 3 || info.StatusMsgID != 7 {
+		t.Errorf("state change made %d calls (message %d), want one edit", *calls-2, info.StatusMsgID)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// objectStore keeps files for `ccc send --store`, so they can be downloaded
+// after the sender has exited instead of streaming through the relay
+type objectStore interface {
+	Put(key string, body io.Reader, size int64) error
+	PresignGet(key string, expires time.Duration) (string, error)
+}
+
+// Presigned links: 24 hours by default, at most the 7 days SigV4 allows
+const (
+	defaultStorageExpiryHours = 24
+	maxStorageExpiryHours     = 7 * 24
+)
+
+// s3Store is an S3 bucket or an S3-compatible one (Cloudflare R2, MinIO,
+// Backblaze B2), addressed path-style: <endpoint>/<bucket>/<key>
+type s3Store struct {
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	now       func() time.Time
+}
+
+// newObjectStore returns the configured storage backend
+func newObjectStore(config *Config) (objectStore, error) {
+	if config.StorageEndpoint == "" || config.StorageBucket == "" || config.StorageAccessKey == "" || config.StorageSecretKey == "" {
+		return nil, fmt.Errorf("storage not configured (set storage-endpoint, storage-bucket, storage-access-key and storage-secret-key)")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(config.StorageEndpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid storage endpoint %q", config.StorageEndpoint)
+	}
+	region := config.StorageRegion
+	if region == "" {
+		region = "us-east-1" // R2 accepts any region, "auto" included
+	}
+	return &s3Store{endpoint, config.StorageBucket, region, config.StorageAccessKey, config.StorageSecretKey, time.Now}, nil
+}
+
+// storageExpiry returns how long presigned links stay valid
+func storageExpiry(config *Config) time.Duration {
+	hours := config.StorageExpiryHours
+	if hours <= 0 {
+		hours = defaultStorageExpiryHours
+	}
+	if hours > maxStorageExpiryHours {
+		hours = maxStorageExpiryHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func (s *s3Store) objectPath(key string) string {
+	return s.endpoint.Path + "/" + s3Escape(s.bucket, false) + "/" + s3Escape(key, false)
+}
+
+func (s *s3Store) presign(method, key string, expires time.Duration) string {
+	return presignV4(method, s.endpoint.Scheme, s.endpoint.Host, s.objectPath(key), s.accessKey, s.secretKey, s.region, s.now(), expires)
+}
+
+// Put uploads an object through a short-lived presigned PUT
+func (s *s3Store) Put(key string, body io.Reader, size int64) error {
+	req, err := http.NewRequest("PUT", s.presign("PUT", key, 15*time.Minute), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("upload rejected (%d): %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// PresignGet returns a download link that expires
+func (s *s3Store) PresignGet(key string, expires time.Duration) (string, error) {
+	return s.presign("GET", key, expires), nil
+}
+
+// s3Escape percent-encodes everything but unreserved characters, as SigV4
+// requires; slashes are kept in paths
+func s3Escape(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			sb.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// presignV4 builds an AWS Signature Version 4 query-signed URL for S3.
+// path must already be escaped.
+func presignV4(method, scheme, host, path, accessKey, secretKey, region string, t time.Time, expires time.Duration) string {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + region + "/s3/aws4_request"
+	params := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    accessKey + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       fmt.Sprintf("%d", int(expires.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	var names []string
+	for k := range params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var query []string
+	for _, k := range names {
+		query = append(query, s3Escape(k, true)+"="+s3Escape(params[k], true))
+	}
+	canonicalQuery := strings.Join(query, "&")
+
+	canonical := strings.Join([]string{method, path, canonicalQuery, "host:" + host, "", "host", "UNSIGNED-PAYLOAD"}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	return fmt.Sprintf("%s://%s%s?%s&X-Amz-Signature=%s", scheme, host, path, canonicalQuery, signature)
+}
+
+// storeItem uploads an item to the object store and returns its download
+// link. Directories are zipped to a temp file first, since the upload needs
+// its size.
+func storeItem(store objectStore, item relayItem, expires time.Duration) (string, error) {
+	body, err := item.open(0)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	size := item.Size
+	if item.Dir {
+		tmp, err := os.CreateTemp("", "ccc-store-*.zip")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if size, err = io.Copy(tmp, body); err != nil {
+			return "", err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		body = tmp
+	}
+	key := "ccc/" + newRelayToken() + "/" + item.Name
+	if err := store.Put(key, body, size); err != nil {
+		return "", err
+	}
+	return store.PresignGet(key, expires)
+}
+
+// sendStoredToTopic uploads items to the object store and posts their
+// expiring links to the topic, so nothing has to stay running
+func sendStoredToTopic(config *Config, sessionName string, topicID int64, items []relayItem) error {
+	store, err := newObjectStore(config)
+	if err != nil {
+		return err
+	}
+	expires := storageExpiry(config)
+	var sb strings.Builder
+	for _, item := range items {
+		fmt.Printf("📤 Uploading %s (%s) to storage...\n", item.Name, item.sizeLabel())
+		link, err := storeItem(store, item, expires)
+		if err != nil {
+			return fmt.Errorf("%s: %w", item.Name, err)
+		}
+		fmt.Fprintf(&sb, "📦 %s (%s)\n🔗 %s\n\n", item.Name, item.sizeLabel(), link)
+	}
+	fmt.Fprintf(&sb, "⏳ Links expire in %s", formatDuration(expires))
+	fmt.Printf("📤 Sending link to %s...\n", sessionName)
+	return sendMessage(config, sessionChat(config, sessionName), topicID, sb.String())
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// The store holds state that changes while sessions run: the session map,
+// per-session block caches (block hash -> Telegram message ID), the files
+// posted in each session's topic, the messages reactions act on and the
+// relay web client's recent message IDs. Settings and credentials stay in the config file.
+//
+// The listener, hooks and CLI all run as separate processes, so the database
+// is opened per operation and closed again; bbolt's file lock serialises
+// writes, while lookups (viewStore) share it.
+
+var (
+	sessionsBucket  = []byte("sessions")
+	blocksBucket    = []byte("blocks")
+	filesBucket     = []byte("files")
+	reactionsBucket = []byte("reactions")
+	replaysBucket   = []byte("control_replays")
+)
+
+// maxSessionFiles is how many recently posted files are remembered per session
+const maxSessionFiles = 20
+
+// maxReactionMessages is how many messages per session reactions can act on,
+// besides those in the block cache
+const maxReactionMessages = 50
+
+// storeLockTimeout is how long to wait for another ccc process to release the store
+const storeLockTimeout = 5 * time.Second
+
+func getStorePath() string {
+	return migrateLegacyFile(legacyFile(".db"), stateFile(".db"))
+}
+
+// withStore opens the store, runs fn in a read-write transaction and closes it
+func withStore(fn func(tx *bolt.Tx) error) error {
+	db, err := bolt.Open(getStorePath(), 0600, &bolt.Options{Timeout: storeLockTimeout})
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sessionsBucket, blocksBucket, filesBucket, reactionsBucket, replaysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// viewStore opens the store read-only and runs fn in a read transaction, so
+// lookups share the file lock instead of queueing for it. Only withStore
+// creates buckets, so fn must allow for a nil one; a store that doesn't
+// exist yet reads as empty.
+func viewStore(fn func(tx *bolt.Tx) error) error {
+	path := getStorePath()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: storeLockTimeout, ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to open store: %w", err)
+	}
+	defer db.Close()
+
+	return db.View(fn)
+}
+
+// loadSessions returns every session in the store
+func loadSessions() (map[string]*SessionInfo, error) {
+	sessions := make(map[string]*SessionInfo)
+	err := viewStore(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var info SessionInfo
+			if err := json.Unmarshal(v, &info); err != nil {
+				hookLog("store: skipping corrupt session %q: %v", k, err)
+				return nil
+			}
+			sessions[string(k)] = &info
+			return nil
+		})
+	})
+	return sessions, err
+}
+
+// saveSession creates or replaces a single session
+func saveSession(name string, info *SessionInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return withStore(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(name), data)
+	})
+}
+
+// deleteSession removes a single session
+func deleteSession(name string) error {
+	return withStore(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(name))
+	})
+}
+
+// deleteAllSessions removes every session
+func deleteAllSessions() error {
+	return withStore(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(sessionsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(sessionsBucket)
+		return err
+	})
+}
+
+// migrateSessions copies sessions from the legacy JSON config into the store.
+// Sessions already in the store win, so a re-run never clobbers newer state.
+func migrateSessions(sessions map[string]*SessionInfo) error {
+	return withStore(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		for name, info := range sessions {
+			if info == nil || b.Get([]byte(name)) != nil {
+				continue
+			}
+			data, err := json.Marshal(info)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(name), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// legacyBlockCachePath is where block caches lived before the store
+func legacyBlockCachePath(sessionName string) string {
+	return filepath.Join(os.TempDir(), "ccc-blocks-"+sessionName+".json")
+}
+
+// loadStoredBlockCache reads a session's block cache, migrating a legacy
+// /tmp cache file into the store the first time it is seen.
+func loadStoredBlockCache(sessionName string) (*BlockCache, error) {
+	var data []byte
+	err := viewStore(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(blocksBucket); b != nil {
+			if v := b.Get([]byte(sessionName)); v != nil {
+				data = append([]byte(nil), v...)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		if legacy, err := os.ReadFile(legacyBlockCachePath(sessionName)); err == nil {
+			data = legacy
+			err = withStore(func(tx *bolt.Tx) error {
+				b := tx.Bucket(blocksBucket)
+				if b.Get([]byte(sessionName)) != nil {
+					return nil
+				}
+				return b.Put([]byte(sessionName), legacy)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	os.Remove(legacyBlockCachePath(sessionName))
+
+	var cache BlockCache
+	if data != nil {
+		if err := json.Unmarshal(data, &cache); err != nil {
+			return &BlockCache{}, nil
+		}
+	}
+	return &cache, nil
+}
+
+func saveStoredBlockCache(sessionName string, cache *BlockCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return withStore(func(tx *bolt.Tx) error {
+		return tx.Bucket(blocksBucket).Put([]byte(sessionName), data)
+	})
+}
+
+func deleteStoredBlockCache(sessionName string) error {
+	os.Remove(legacyBlockCachePath(sessionName))
+	return withStore(func(tx *bolt.Tx) error {
+		return tx.Bucket(blocksBucket).Delete([]byte(sessionName))
+	})
+}
+
+// SessionFile is a document posted in a session's topic, fetchable with `ccc receive`
+type SessionFile struct {
+	MessageID int64     `json:"message_id"`
+	FileID    string    `json:"file_id"`
+	FileName  string    `json:"file_name"`
+	FileSize  int64     `json:"file_size"`
+	Posted    time.Time `json:"posted"`
+}
+
+// recordSessionFile remembers a posted file, keeping the newest maxSessionFiles
+func recordSessionFile(sessionName string, file SessionFile) error {
+	return withStore(func(tx *bolt.Tx) error {
+		b := tx.Bucket(filesBucket)
+		var files []SessionFile
+		if v := b.Get([]byte(sessionName)); v != nil {
+			json.Unmarshal(v, &files)
+		}
+		files = append(files, file)
+		if len(files) > maxSessionFiles {
+			files = files[len(files)-maxSessionFiles:]
+		}
+		data, err := json.Marshal(files)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(sessionName), data)
+	})
+}
+
+// loadSessionFiles returns the files posted in a session's topic, oldest first
+func loadSessionFiles(sessionName string) ([]SessionFile, error) {
+	var files []SessionFile
+	err := viewStore(func(tx *bolt.Tx) error {
+		b := tx.Bucket(filesBucket)
+		if b == nil {
+			return nil
+		}
+		if v := b.Get([]byte(sessionName)); v != nil {
+			return json.Unmarshal(v, &files)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// reactionMessage is a message in a session topic that reactions act on
+type reactionMessage struct {
+	MessageID int64  `json:"message_id"`
+	Question  string `json:"question,omitempty"` // callback data of a question's first option
+}
+
+// recordReactionMessage remembers a message, keeping the newest maxReactionMessages
+func recordReactionMessage(sessionName string, msg reactionMessage) error {
+	return withStore(func(tx *bolt.Tx) error {
+		b := tx.Bucket(reactionsBucket)
+		var msgs []reactionMessage
+		if v := b.Get([]byte(sessionName)); v != nil {
+			json.Unmarshal(v, &msgs)
+		}
+		msgs = append(msgs, msg)
+		if len(msgs) > maxReactionMessages {
+			msgs = msgs[len(msgs)-maxReactionMessages:]
+		}
+		data, err := json.Marshal(msgs)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(sessionName), data)
+	})
+}
+
+// findReactionMessage returns the session a recorded message belongs to
+func findReactionMessage(messageID int64) (string, reactionMessage, bool) {
+	var sessName string
+	var found reactionMessage
+	viewStore(func(tx *bolt.Tx) error {
+		b := tx.Bucket(reactionsBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var msgs []reactionMessage
+			json.Unmarshal(v, &msgs)
+			for _, m := range msgs {
+				if m.MessageID == messageID {
+					sessName, found = string(k), m
+				}
+			}
+			return nil
+		})
+	})
+	return sessName, found, sessName != ""
+}
+
+// loadControlReplays returns the IDs of the web client's messages the relay
+// listener accepted recently, so a restart doesn't make them fresh again
+func loadControlReplays() (controlReplays, error) {
+	seen := make(controlReplays)
+	err := viewStore(func(tx *bolt.Tx) error {
+		b := tx.Bucket(replaysBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if ms, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+				seen[string(k)] = time.UnixMilli(ms)
+			}
+			return nil
+		})
+	})
+	return seen, err
+}
+
+// recordControlReplay remembers an accepted message ID, dropping those old
+// enough that fresh rejects their messages anyway
+func recordControlReplay(id string, now time.Time) error {
+	return withStore(func(tx *bolt.Tx) error {
+		b := tx.Bucket(replaysBucket)
+		var old [][]byte
+		b.ForEach(func(k, v []byte) error {
+			if ms, err := strconv.ParseInt(string(v), 10, 64); err != nil || now.Sub(time.UnixMilli(ms)) > 2*controlMaxAge {
+				old = append(old, k)
+			}
+			return nil
+		})
+		for _, k := range old {
+			b.Delete(k)
+		}
+		return b.Put([]byte(id), []byte(strconv.FormatInt(now.UnixMilli(), 10)))
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxSubagentResultLen caps the subagent result quoted under its summary
+const maxSubagentResultLen = 3000
+
+var (
+	// subagentHeader matches the first line of a subagent block in the pane:
+	// "Task(Find the config loader)" or, in newer versions, "Agent(...)"
+	subagentHeader = regexp.MustCompile(`^(?:Task|Agent)\((.*)\)\s*$`)
+	// subagentDone matches the summary line Claude prints when a subagent
+	// finishes: "⎿  Done (5 tool uses · 12.3k tokens · 20.1s)"
+	subagentDone = regexp.MustCompile(`^(?:⎿\s*)?Done \(\d+ tool uses?.*\)$`)
+	// namedCall matches "<agent>(<description>)", how named agents are shown
+	namedCall = regexp.MustCompile(`^([\w-]+)\((.*)\)\s*$`)
+)
+
+// isSubagentTool reports whether a tool call runs a subagent
+func isSubagentTool(tool string) bool {
+	return tool == "Task" || tool == "Agent"
+}
+
+// parseSubagentBlock recognizes a pane block showing a subagent run and
+// returns its description, the nested lines and the Done summary (if it has
+// finished)
+func parseSubagentBlock(block string) (name string, nested []string, done string, ok bool) {
+	lines := strings.Split(block, "\n")
+	header := strings.TrimSpace(lines[0])
+	if m := subagentHeader.FindStringSubmatch(header); m != nil {
+		name, ok = m[1], true
+	}
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if subagentDone.MatchString(line) {
+			done = strings.TrimSpace(strings.TrimPrefix(line, "⎿"))
+			continue
+		}
+		nested = append(nested, removeBulletPrefix(strings.TrimSpace(strings.TrimPrefix(line, "⎿"))))
+	}
+	// Named agents look like any tool call; only their Done line gives them away
+	if !ok && done != "" {
+		if m := namedCall.FindStringSubmatch(header); m != nil {
+			name, ok = fmt.Sprintf("%s (%s)", m[2], m[1]), true
+		}
+	}
+	return name, nested, done, ok
+}
+
+// formatSubagentSummary renders a subagent as a header, its result or nested
+// output as a quote (collapsed in Telegram when long), and the Done line
+func formatSubagentSummary(name string, body []string, done string) string {
+	var sb strings.Builder
+	sb.WriteString("🧵 subagent: " + name)
+	for _, line := range body {
+		sb.WriteString("\n> " + line)
+	}
+	if done != "" {
+		sb.WriteString("\n✔ " + done)
+	}
+	return sb.String()
+}
+
+// renderBlock is how a pane block is shown: subagent blocks as a summary,
+// edits as a diff, everything else unchanged
+func renderBlock(block string) string {
+	if text, ok := renderDiffBlock(block); ok {
+		return text
+	}
+	if name, nested, done, ok := parseSubagentBlock(block); ok {
+		return formatSubagentSummary(name, nested, done)
+	}
+	return block
+}
+
+// subagentFromHook extracts the description and result of a finished Task
+// tool call from a PostToolUse payload. The result is a string or a list of
+// content parts, depending on the Claude version.
+func subagentFromHook(rawData []byte) (string, string) {
+	var data struct {
+		ToolInput struct {
+			Description  string `json:"description"`
+			SubagentType string `json:"subagent_type"`
+		} `json:"tool_input"`
+		ToolResponse json.RawMessage `json:"tool_response"`
+	}
+	if json.Unmarshal(rawData, &data) != nil {
+		return "", ""
+	}
+	name := data.ToolInput.Description
+	if t := data.ToolInput.SubagentType; t != "" && t != "general-purpose" {
+		name = fmt.Sprintf("%s (%s)", name, t)
+	}
+
+	var result string
+	if json.Unmarshal(data.ToolResponse, &result) != nil {
+		var parts struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		}
+		if json.Unmarshal(data.ToolResponse, &parts) == nil {
+			var texts []string
+			for _, p := range parts.Content {
+				if p.Type == "text" && p.Text != "" {
+					texts = append(texts, p.Text)
+				}
+			}
+			result = strings.Join(texts, "\n\n")
+		}
+	}
+	return name, truncate(strings.TrimSpace(result), maxSubagentResultLen)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSubagentBlock(t *testing.T) {
+	block := "Task(Find the config loader)\n⎿  ⏺ Read(config.go)\n⏺ Search(pattern: \"loadConfig\")\n⎿  Done (2 tool uses · 8.1k tokens · 12s)"
+	name, nested, done, ok := parseSubagentBlock(block)
+	if !ok || name != "Find the config loader" {
+		t.Fatalf("parseSubagentBlock = %q, %v", name, ok)
+	}
+	if len(nested) != 2 || nested[0] != "Read(config.go)" || nested[1] != `Search(pattern: "loadConfig")` {
+		t.Errorf("nested = %q", nested)
+	}
+	if done != "Done (2 tool uses · 8.1k tokens · 12s)" {
+		t.Errorf("done = %q", done)
+	}
+
+	name, _, _, ok = parseSubagentBlock("Explore(Map the repo)\n⎿  Done (9 tool uses · 20k tokens · 1m 3s)")
+	if !ok || name != "Map the repo (Explore)" {
+		t.Errorf("named agent = %q, %v", name, ok)
+	}
+
+	for _, block := range []string{"Read(config.go)\n⎿  Read 40 lines", "The fix is done.", "Bash(make)\n⎿  Done"} {
+		if _, _, _, ok := parseSubagentBlock(block); ok {
+			t.Errorf("%q should not be a subagent block", block)
+		}
+	}
+}
+
+func TestRenderBlock(t *testing.T) {
+	got := renderBlock("Task(Find it)\n⎿  Read(a.go)\n⎿  Done (1 tool use · 2k tokens · 3s)")
+	want := "🧵 subagent: Find it\n> Read(a.go)\n✔ Done (1 tool use · 2k tokens · 3s)"
+	if got != want {
+		t.Errorf("renderBlock = %q, want %q", got, want)
+	}
+	if got := renderBlock("Plain answer"); got != "Plain answer" {
+		t.Errorf("renderBlock changed a plain block: %q", got)
+	}
+}
+
+func TestSubagentFromHook(t *testing.T) {
+	raw := `{"tool_name":"Task","tool_input":{"description":"Find it","subagent_type":"Explore","prompt":"..."},` +
+		`"tool_response":{"content":[{"type":"text","text":"It's in config.go"}],"totalToolUseCount":3}}`
+	name, result := subagentFromHook([]byte(raw))
+	if name != "Find it (Explore)" || result != "It's in config.go" {
+		t.Errorf("subagentFromHook = %q, %q", name, result)
+	}
+
+	raw = `{"tool_input":{"description":"Review","subagent_type":"general-purpose"},"tool_response":"Looks good"}`
+	if name, result := subagentFromHook([]byte(raw)); name != "Review" || result != "Looks good" {
+		t.Errorf("string response = %q, %q", name, result)
+	}
+}
+
+func TestHookEventFromDataSubagents(t *testing.T) {
+	raw := `{"hook_event_name":"PostToolUse","tool_name":"Read","agent_id":"a1","tool_input":{"file_path":"/x"}}`
+	ev, ok := hookEventFromData(HookData{HookEventName: "PostToolUse", ToolName: "Read", AgentID: "a1"}, []byte(raw))
+	if !ok || !ev.Nested {
+		t.Errorf("tool call inside a subagent = %+v, %v; want nested", ev, ok)
+	}
+
+	raw = `{"tool_input":{"description":"Find it"},"tool_response":"found"}`
+	ev, ok = hookEventFromData(HookData{HookEventName: "PostToolUse", ToolName: "Task"}, []byte(raw))
+	if !ok || ev.Agent != "Find it" || ev.Text != "found" || ev.Nested {
+		t.Errorf("Task call = %+v, %v", ev, ok)
+	}
+
+	if _, ok := hookEventFromData(HookData{HookEventName: "SubagentStop"}, nil); !ok {
+		t.Error("SubagentStop should be forwarded")
+	}
+	if got := formatSubagentSummary("Find it", strings.Split("a\nb", "\n"), ""); got != "🧵 subagent: Find it\n> a\n> b" {
+		t.Errorf("formatSubagentSummary = %q", got)
+	}
+}
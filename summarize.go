@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A turn whose output passes summarize_blocks blocks or summarize_chars
+// characters stops forwarding blocks; when it completes, the held blocks go
+// through the router LLM and arrive as one summary with a button for the
+// full output.
+
+const (
+	// heldBlockMsgID marks a cached block held back for the turn's summary,
+	// summarizedBlockMsgID one that has been summarized
+	heldBlockMsgID       = -2
+	summarizedBlockMsgID = -3
+	// outputCallbackPrefix marks "Show full output" buttons: out:<id>
+	outputCallbackPrefix = "out:"
+	// maxSummaryInput is how much of the held output the router sees, from the end
+	maxSummaryInput = 30000
+)
+
+const outputSummaryPrompt = `You summarize the output of one turn of a coding assistant working in a terminal. Reply with at most 5 short lines of plain text: what it did, files and commands involved, errors, and the outcome. No preamble, no markdown headers.`
+
+var heldOutputs = make(map[string]*expandable) // guarded by expandablesMu
+
+// summarizeEnabled reports whether long turns are summarized. Only Telegram
+// answers the "Show full output" button.
+func summarizeEnabled(config *Config) bool {
+	return (config.SummarizeBlocks > 0 || config.SummarizeChars > 0) &&
+		configuredMessenger(config) == messengerTelegram && routerEnabled(config)
+}
+
+// overSummaryThreshold reports whether a block is held for the summary,
+// given its index in the turn and the characters of the turn up to and
+// including it
+func overSummaryThreshold(config *Config, index, chars int) bool {
+	return (config.SummarizeBlocks > 0 && index >= config.SummarizeBlocks) ||
+		(config.SummarizeChars > 0 && chars > config.SummarizeChars)
+}
+
+// rememberHeldOutput keeps a turn's held output for its button and returns the ID
+func rememberHeldOutput(text string) string {
+	idBytes := make([]byte, 4)
+	rand.Read(idBytes)
+	id := hex.EncodeToString(idBytes)
+	expandablesMu.Lock()
+	defer expandablesMu.Unlock()
+	now := time.Now()
+	for k, e := range heldOutputs {
+		if now.Sub(e.Created) > expandTimeout {
+			delete(heldOutputs, k)
+		}
+	}
+	heldOutputs[id] = &expandable{Text: text, Rest: text, Created: now}
+	return id
+}
+
+// takeHeldOutput returns the output behind a "Show full output" button, nil
+// if unknown or expired
+func takeHeldOutput(data string) *expandable {
+	id := strings.TrimPrefix(data, outputCallbackPrefix)
+	expandablesMu.Lock()
+	defer expandablesMu.Unlock()
+	e, ok := heldOutputs[id]
+	if !ok || time.Since(e.Created) > expandTimeout {
+		return nil
+	}
+	return e
+}
+
+// sendOutputSummary sends the summary of a turn's held blocks, led by the
+// completion header, with a button for the full output
+func sendOutputSummary(config *Config, sessName string, topicID int64, header string, held []string) {
+	full := strings.Join(held, "\n\n")
+	text := fmt.Sprintf("📝 %d more blocks (%d characters), summarized:\n\n", len(held), len(full))
+	summary, err := routerComplete(config, outputSummaryPrompt, outputTail(full, maxSummaryInput), 300)
+	if err != nil || strings.TrimSpace(summary) == "" {
+		hookLog("summarize: session=%s failed: %v", sessName, err)
+		text = fmt.Sprintf("📝 %d more blocks (%d characters); the summary failed.", len(held), len(full))
+	} else {
+		text += strings.TrimSpace(summary)
+	}
+	buttons := [][]InlineKeyboardButton{{
+		{Text: "📄 Show full output", CallbackData: outputCallbackPrefix + rememberHeldOutput(full)},
+	}}
+	if err := outputMessenger(config).SendWithKeyboard(sessionChat(config, sessName), topicID, header+text, buttons); err != nil {
+		hookLog("summarize: session=%s sending: %v", sessName, err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestOverSummaryThreshold(t *testing.T) {
+	config := &Config{SummarizeBlocks: 5}
+	if overSummaryThreshold(config, 4, 100000) {
+		t.Error("block 4 held with only a block threshold of 5")
+	}
+	if !overSummaryThreshold(config, 5, 10) {
+		t.Error("block 5 not held with a block threshold of 5")
+	}
+	config = &Config{SummarizeChars: 1000}
+	if overSummaryThreshold(config, 50, 1000) || !overSummaryThreshold(config, 0, 1001) {
+		t.Error("character threshold not applied")
+	}
+	if overSummaryThreshold(&Config{}, 100, 1000000) {
+		t.Error("block held with summarizing off")
+	}
+}
+
+func TestSummarizeEnabled(t *testing.T) {
+	if summarizeEnabled(&Config{SummarizeBlocks: 5}) {
+		t.Error("summarizing enabled without the router")
+	}
+	if !summarizeEnabled(&Config{SummarizeBlocks: 5, OpenRouterKey: "k"}) {
+		t.Error("summarizing disabled with the router configured")
+	}
+}
+
+func TestHeldOutput(t *testing.T) {
+	id := rememberHeldOutput("block one\n\nblock two")
+	e := takeHeldOutput(outputCallbackPrefix + id)
+	if e == nil || e.Rest != "block one\n\nblock two" {
+		t.Fatalf("takeHeldOutput() = %+v", e)
+	}
+	if takeHeldOutput(outputCallbackPrefix+"unknown") != nil {
+		t.Error("takeHeldOutput() found an unknown ID")
+	}
+}
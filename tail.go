@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTailLines  = 30
+	maxTailLines      = 200
+	tailInterval      = 3 * time.Second
+	tailMaxDuration   = 30 * time.Minute // live tails stop by themselves after this
+	maxTailBlockBytes = 3800             // leaves room for the code fence in a 4096-char message
+)
+
+var (
+	// tailers holds the stop channel of each session's live /tail
+	tailers   = make(map[string]chan struct{})
+	tailersMu sync.Mutex
+)
+
+// parseTailArg parses "/tail" arguments: "", a line count, "on" or "off"
+func parseTailArg(arg string) (lines int, mode string, err error) {
+	switch arg {
+	case "":
+		return defaultTailLines, "", nil
+	case "on", "off":
+		return defaultTailLines, arg, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n <= 0 {
+		return 0, "", fmt.Errorf("usage: /tail [lines|on|off]")
+	}
+	if n > maxTailLines {
+		n = maxTailLines
+	}
+	return n, "", nil
+}
+
+// clampTail keeps the end of pane output within one message, cutting at a line
+func clampTail(text string) string {
+	if len(text) <= maxTailBlockBytes {
+		return text
+	}
+	text = text[len(text)-maxTailBlockBytes:]
+	if idx := strings.Index(text, "\n"); idx != -1 {
+		text = text[idx+1:]
+	}
+	return text
+}
+
+// paneTail captures the last lines of a session's pane ready to send
+func paneTail(sessName string, lines int) (string, error) {
+	out, err := capturePaneTail(sessionName(sessName), lines)
+	if err != nil {
+		return "", err
+	}
+	out = clampTail(out)
+	if strings.TrimSpace(out) == "" {
+		out = "(empty pane)"
+	}
+	return out, nil
+}
+
+// startTail pins a message showing the session's pane and edits it every few
+// seconds until stopTail, the session ending, or tailMaxDuration.
+func startTail(config *Config, chatID, threadID int64, sessName string) error {
+	tailersMu.Lock()
+	if _, running := tailers[sessName]; running {
+		tailersMu.Unlock()
+		return fmt.Errorf("already tailing %s (/tail off to stop)", sessName)
+	}
+	stop := make(chan struct{})
+	tailers[sessName] = stop
+	tailersMu.Unlock()
+
+	text, err := paneTail(sessName, defaultTailLines)
+	if err == nil {
+		var msgID int64
+		if msgID, err = sendCodeBlockGetID(config, chatID, threadID, "", text); err == nil {
+			go runTail(config, chatID, msgID, sessName, text, stop)
+			return nil
+		}
+	}
+	tailersMu.Lock()
+	delete(tailers, sessName)
+	tailersMu.Unlock()
+	return err
+}
+
+func runTail(config *Config, chatID, msgID int64, sessName, last string, stop chan struct{}) {
+	pinMessage(config, chatID, msgID)
+	defer func() {
+		unpinMessage(config, chatID, msgID)
+		tailersMu.Lock()
+		if tailers[sessName] == stop {
+			delete(tailers, sessName)
+		}
+		tailersMu.Unlock()
+	}()
+
+	ticker := time.NewTicker(tailInterval)
+	defer ticker.Stop()
+	deadline := time.After(tailMaxDuration)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+			text, err := paneTail(sessName, defaultTailLines)
+			if err != nil {
+				// Session is gone
+				return
+			}
+			if text == last {
+				continue
+			}
+			if err := editCodeBlock(config, chatID, msgID, "", text); err != nil {
+				hookLog("tail: session=%s edit failed: %v", sessName, err)
+				continue
+			}
+			last = text
+		}
+	}
+}
+
+// stopTail stops a session's live tail, reporting whether one was running
+func stopTail(sessName string) bool {
+	tailersMu.Lock()
+	defer tailersMu.Unlock()
+	stop, running := tailers[sessName]
+	if running {
+		close(stop)
+		delete(tailers, sessName)
+	}
+	return running
+}
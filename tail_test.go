@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTailArg(t *testing.T) {
+	tests := []struct {
+		arg     string
+		lines   int
+		mode    string
+		wantErr bool
+	}{
+		{"", defaultTailLines, "", false},
+		{"50", 50, "", false},
+		{"5000", maxTailLines, "", false},
+		{"on", defaultTailLines, "on", false},
+		{"off", defaultTailLines, "off", false},
+		{"0", 0, "", true},
+		{"lots", 0, "", true},
+	}
+
+	for _, tt := range tests {
+		lines, mode, err := parseTailArg(tt.arg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTailArg(%q) error = %v, wantErr %v", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if lines != tt.lines || mode != tt.mode {
+			t.Errorf("parseTailArg(%q) = %d, %q, want %d, %q", tt.arg, lines, mode, tt.lines, tt.mode)
+		}
+	}
+}
+
+func TestClampTail(t *testing.T) {
+	short := "line1\nline2"
+	if got := clampTail(short); got != short {
+		t.Errorf("clampTail(short) = %q", got)
+	}
+
+	long := strings.Repeat("0123456789\n", 1000) + "last"
+	got := clampTail(long)
+	if len(got) > maxTailBlockBytes {
+		t.Errorf("clampTail kept %d bytes, want <= %d", len(got), maxTailBlockBytes)
+	}
+	if !strings.HasSuffix(got, "last") || !strings.HasPrefix(got, "0123456789") {
+		t.Errorf("clampTail should keep whole trailing lines, got prefix %q", got[:12])
+	}
+}
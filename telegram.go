@@ -12,6 +12,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -44,12 +45,25 @@ func telegramClientGet(client *http.Client, token string, url string) (*http.Res
 	return resp, nil
 }
 
-// updateCCC downloads the latest ccc binary from GitHub releases and restarts
-func updateCCC(config *Config, chatID, threadID int64, offset int) {
-	sendMessage(config, chatID, threadID, "🔄 Updating ccc...")
+// updateCCC downloads a ccc binary from the GitHub releases of a channel (see
+// releaseDownloadBase), checks it against the release's published SHA-256,
+// keeps the old binary for /rollback, and restarts
+func updateCCC(config *Config, chatID, threadID int64, offset int, channel string) {
+	base, label, err := releaseDownloadBase(channel)
+	if err != nil {
+		sendMessage(config, chatID, threadID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	sendMessage(config, chatID, threadID, fmt.Sprintf("🔄 Updating ccc to %s...", label))
 
 	binaryName := fmt.Sprintf("ccc-%s-%s", runtime.GOOS, runtime.GOARCH)
-	downloadURL := fmt.Sprintf("https://github.com/rsh3khar/ccc/releases/latest/download/%s", binaryName)
+	downloadURL := base + "/" + binaryName
+
+	wantSum, err := fetchPublishedChecksum(base+"/checksums.txt", binaryName)
+	if err != nil {
+		sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Update aborted: %v", err))
+		return
+	}
 
 	resp, err := http.Get(downloadURL)
 	if err != nil {
@@ -70,7 +84,8 @@ func updateCCC(config *Config, chatID, threadID int64, offset int) {
 		return
 	}
 
-	written, err := io.Copy(f, resp.Body)
+	body := newChecksumReader(resp.Body)
+	written, err := io.Copy(f, body)
 	f.Close()
 	if err != nil {
 		os.Remove(tmpPath)
@@ -85,6 +100,12 @@ func updateCCC(config *Config, chatID, threadID int64, offset int) {
 		return
 	}
 
+	if got := body.sum(); got != wantSum {
+		os.Remove(tmpPath)
+		sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Checksum mismatch, aborting\nexpected %s\ngot      %s", wantSum, got))
+		return
+	}
+
 	if err := os.Chmod(tmpPath, 0755); err != nil {
 		os.Remove(tmpPath)
 		sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to chmod: %v", err))
@@ -99,9 +120,9 @@ func updateCCC(config *Config, chatID, threadID int64, offset int) {
 		return
 	}
 
-	// Backup old binary
-	backupPath := cccPath + ".bak"
-	os.Remove(backupPath) // Remove old backup if exists
+	// Keep the old binary for /rollback
+	backupPath := oldBinaryPath()
+	os.Remove(backupPath) // Only one previous version is kept
 	if err := os.Rename(cccPath, backupPath); err != nil {
 		os.Remove(tmpPath)
 		sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Failed to backup old binary: %v", err))
@@ -127,27 +148,26 @@ func updateCCC(config *Config, chatID, threadID int64, offset int) {
 		}
 	}
 
-	// Success - remove backup
-	os.Remove(backupPath)
-
-	sendMessage(config, chatID, threadID, "✅ Updated. Restarting...")
-	// Confirm offset so the /update message is not reprocessed after restart
-	http.Get(fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates?offset=%d&timeout=1", config.BotToken, offset))
-	os.Exit(0)
+	sendMessage(config, chatID, threadID, fmt.Sprintf("✅ Updated to %s. Restarting... (/rollback to undo)", label))
+	restartAfterUpdate(config, offset)
 }
 
+// telegramAPI calls a Bot API method, throttling sends and retrying on rate
+// limits and server errors
 func telegramAPI(config *Config, method string, params url.Values) (*TelegramResponse, error) {
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/%s", config.BotToken, method)
-	resp, err := http.PostForm(apiURL, params)
-	if err != nil {
-		return nil, redactTokenError(err, config.BotToken)
+	apiURL := fmt.Sprintf("%s/bot%s/%s", telegramAPIBase, config.BotToken, method)
+	var chatID int64
+	if throttledMethods[method] {
+		chatID, _ = strconv.ParseInt(params.Get("chat_id"), 10, 64)
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
-	var result TelegramResponse
-	json.Unmarshal(body, &result)
-	return &result, nil
+	return telegramDo(config, chatID, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", apiURL, strings.NewReader(params.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
 }
 
 func sendMessage(config *Config, chatID int64, threadID int64, text string) error {
@@ -157,8 +177,38 @@ func sendMessage(config *Config, chatID int64, threadID int64, text string) erro
 
 // sendMessageGetID sends a message and returns the message ID for later editing
 func sendMessageGetID(config *Config, chatID int64, threadID int64, text string) (int64, error) {
+	return sendMessageParts(config, chatID, threadID, text, false)
+}
+
+// sendFormattedGetID is sendMessageGetID with markdown rendered as MarkdownV2
+func sendFormattedGetID(config *Config, chatID int64, threadID int64, text string) (int64, error) {
+	return sendMessageParts(config, chatID, threadID, text, true)
+}
+
+// telegramAPIMarkdown calls a text method with the text converted to MarkdownV2,
+// retrying as plain text if Telegram can't parse the entities.
+func telegramAPIMarkdown(config *Config, method string, params url.Values) (*TelegramResponse, error) {
+	plain := params.Get("text")
+	params.Set("text", toMarkdownV2(plain))
+	params.Set("parse_mode", "MarkdownV2")
+	result, err := telegramAPI(config, method, params)
+	if err != nil || result.OK || !strings.Contains(result.Description, "can't parse entities") {
+		return result, err
+	}
+	params.Set("text", plain)
+	params.Del("parse_mode")
+	return telegramAPI(config, method, params)
+}
+
+func sendMessageParts(config *Config, chatID int64, threadID int64, text string, formatted bool) (int64, error) {
 	const maxLen = 4000
 
+	// Claude's long blocks get a preview and a Show more button instead of
+	// several messages
+	if formatted && len(text) > maxLen {
+		return sendPreview(config, chatID, threadID, text)
+	}
+
 	// Split long messages
 	messages := splitMessage(text, maxLen)
 	var lastMsgID int64
@@ -172,7 +222,13 @@ func sendMessageGetID(config *Config, chatID int64, threadID int64, text string)
 			params.Set("message_thread_id", fmt.Sprintf("%d", threadID))
 		}
 
-		result, err := telegramAPI(config, "sendMessage", params)
+		var result *TelegramResponse
+		var err error
+		if formatted {
+			result, err = telegramAPIMarkdown(config, "sendMessage", params)
+		} else {
+			result, err = telegramAPI(config, "sendMessage", params)
+		}
 		if err != nil {
 			return 0, err
 		}
@@ -200,8 +256,21 @@ func sendMessageGetID(config *Config, chatID int64, threadID int64, text string)
 
 // editMessage edits an existing message, sending overflow as new messages
 func editMessage(config *Config, chatID int64, messageID int64, threadID int64, text string) error {
+	return editMessageParts(config, chatID, messageID, threadID, text, false)
+}
+
+// editFormattedMessage is editMessage with markdown rendered as MarkdownV2
+func editFormattedMessage(config *Config, chatID int64, messageID int64, threadID int64, text string) error {
+	return editMessageParts(config, chatID, messageID, threadID, text, true)
+}
+
+func editMessageParts(config *Config, chatID int64, messageID int64, threadID int64, text string, formatted bool) error {
 	const maxLen = 4000
 
+	if formatted && len(text) > maxLen {
+		return editPreview(config, chatID, messageID, text)
+	}
+
 	// Split message - first part goes to edit, rest as new messages
 	messages := splitMessage(text, maxLen)
 
@@ -212,7 +281,13 @@ func editMessage(config *Config, chatID int64, messageID int64, threadID int64,
 		"text":       {messages[0]},
 	}
 
-	result, err := telegramAPI(config, "editMessageText", params)
+	var result *TelegramResponse
+	var err error
+	if formatted {
+		result, err = telegramAPIMarkdown(config, "editMessageText", params)
+	} else {
+		result, err = telegramAPI(config, "editMessageText", params)
+	}
 	if err != nil {
 		return err
 	}
@@ -224,13 +299,165 @@ func editMessage(config *Config, chatID int64, messageID int64, threadID int64,
 	// Send remaining parts as new messages
 	for i := 1; i < len(messages); i++ {
 		time.Sleep(100 * time.Millisecond)
-		sendMessage(config, chatID, threadID, messages[i])
+		sendMessageParts(config, chatID, threadID, messages[i], formatted)
+	}
+
+	return nil
+}
+
+// sendPreview sends the start of a long block with a Show more button
+func sendPreview(config *Config, chatID int64, threadID int64, text string) (int64, error) {
+	preview, rest := previewText(text)
+	params := url.Values{
+		"chat_id":      {fmt.Sprintf("%d", chatID)},
+		"text":         {preview},
+		"reply_markup": {expandKeyboard()},
+	}
+	if threadID > 0 {
+		params.Set("message_thread_id", fmt.Sprintf("%d", threadID))
+	}
+	result, err := telegramAPIMarkdown(config, "sendMessage", params)
+	if err != nil {
+		return 0, err
+	}
+	if !result.OK {
+		return 0, fmt.Errorf("telegram error: %s", result.Description)
+	}
+	var msgResult struct {
+		MessageID int64 `json:"message_id"`
+	}
+	json.Unmarshal(result.Result, &msgResult)
+	rememberExpandable(chatID, msgResult.MessageID, text, rest)
+	return msgResult.MessageID, nil
+}
+
+// editPreview is sendPreview for a block that grew past one message
+func editPreview(config *Config, chatID int64, messageID int64, text string) error {
+	preview, rest := previewText(text)
+	params := url.Values{
+		"chat_id":      {fmt.Sprintf("%d", chatID)},
+		"message_id":   {fmt.Sprintf("%d", messageID)},
+		"text":         {preview},
+		"reply_markup": {expandKeyboard()},
+	}
+	result, err := telegramAPIMarkdown(config, "editMessageText", params)
+	if err != nil {
+		return err
+	}
+	// "message is not modified" still means the preview is current
+	if result.OK || strings.Contains(result.Description, "not modified") {
+		rememberExpandable(chatID, messageID, text, rest)
+	}
+	return nil
+}
+
+// removeKeyboard drops the inline keyboard from a message, keeping its text
+// and formatting
+func removeKeyboard(config *Config, chatID int64, messageID int) {
+	telegramAPI(config, "editMessageReplyMarkup", url.Values{
+		"chat_id":      {fmt.Sprintf("%d", chatID)},
+		"message_id":   {fmt.Sprintf("%d", messageID)},
+		"reply_markup": {`{"inline_keyboard":[]}`},
+	})
+}
+
+// sendJSON marshals v and sends it as a JSON code block
+func sendJSON(config *Config, chatID int64, threadID int64, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return sendCodeBlock(config, chatID, threadID, "json", string(data))
+}
+
+// sendCodeBlock sends text as a MarkdownV2 pre block. Falls back to plain
+// fenced text when the block is too long for a single message.
+func sendCodeBlock(config *Config, chatID int64, threadID int64, lang string, code string) error {
+	_, err := sendCodeBlockGetID(config, chatID, threadID, lang, code)
+	return err
+}
+
+// sendCodeBlockGetID is sendCodeBlock returning the message ID for later editing
+func sendCodeBlockGetID(config *Config, chatID int64, threadID int64, lang string, code string) (int64, error) {
+	const maxLen = 4000
+	if len(code) > maxLen {
+		return sendMessageGetID(config, chatID, threadID, "```"+lang+"\n"+code+"\n```")
+	}
+
+	// Inside pre blocks MarkdownV2 only requires escaping ` and \
+	params := url.Values{
+		"chat_id":    {fmt.Sprintf("%d", chatID)},
+		"text":       {"```" + lang + "\n" + escapeMarkdownV2Code(code) + "\n```"},
+		"parse_mode": {"MarkdownV2"},
+	}
+	if threadID > 0 {
+		params.Set("message_thread_id", fmt.Sprintf("%d", threadID))
+	}
+
+	result, err := telegramAPI(config, "sendMessage", params)
+	if err != nil {
+		return 0, err
+	}
+	if !result.OK {
+		return 0, fmt.Errorf("telegram error: %s", result.Description)
+	}
+	var msgResult struct {
+		MessageID int64 `json:"message_id"`
+	}
+	json.Unmarshal(result.Result, &msgResult)
+	return msgResult.MessageID, nil
+}
+
+// editCodeBlock replaces a message's text with a MarkdownV2 pre block.
+// Telegram's "message is not modified" is not an error.
+func editCodeBlock(config *Config, chatID int64, messageID int64, lang string, code string) error {
+	params := url.Values{
+		"chat_id":    {fmt.Sprintf("%d", chatID)},
+		"message_id": {fmt.Sprintf("%d", messageID)},
+		"text":       {"```" + lang + "\n" + escapeMarkdownV2Code(code) + "\n```"},
+		"parse_mode": {"MarkdownV2"},
+	}
+	result, err := telegramAPI(config, "editMessageText", params)
+	if err != nil {
+		return err
+	}
+	if !result.OK && !strings.Contains(result.Description, "not modified") {
+		return fmt.Errorf("telegram error: %s", result.Description)
 	}
+	return nil
+}
 
+// pinMessage pins a message silently; unpinMessage undoes it
+func pinMessage(config *Config, chatID int64, messageID int64) error {
+	result, err := telegramAPI(config, "pinChatMessage", url.Values{
+		"chat_id":              {fmt.Sprintf("%d", chatID)},
+		"message_id":           {fmt.Sprintf("%d", messageID)},
+		"disable_notification": {"true"},
+	})
+	if err != nil {
+		return err
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram error: %s", result.Description)
+	}
 	return nil
 }
 
+func unpinMessage(config *Config, chatID int64, messageID int64) {
+	telegramAPI(config, "unpinChatMessage", url.Values{
+		"chat_id":    {fmt.Sprintf("%d", chatID)},
+		"message_id": {fmt.Sprintf("%d", messageID)},
+	})
+}
+
 func sendMessageWithKeyboard(config *Config, chatID int64, threadID int64, text string, buttons [][]InlineKeyboardButton) error {
+	_, err := sendMessageWithKeyboardGetID(config, chatID, threadID, text, buttons)
+	return err
+}
+
+// sendMessageWithKeyboardGetID is sendMessageWithKeyboard returning the ID of
+// the message carrying the keyboard
+func sendMessageWithKeyboardGetID(config *Config, chatID int64, threadID int64, text string, buttons [][]InlineKeyboardButton) (int64, error) {
 	const maxLen = 4000
 
 	// Split long messages - send all but last as regular messages, last with keyboard
@@ -259,12 +486,16 @@ func sendMessageWithKeyboard(config *Config, chatID int64, threadID int64, text
 
 	result, err := telegramAPI(config, "sendMessage", params)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if !result.OK {
-		return fmt.Errorf("telegram error: %s", result.Description)
+		return 0, fmt.Errorf("telegram error: %s", result.Description)
 	}
-	return nil
+	var msgResult struct {
+		MessageID int64 `json:"message_id"`
+	}
+	json.Unmarshal(result.Result, &msgResult)
+	return msgResult.MessageID, nil
 }
 
 func answerCallbackQuery(config *Config, callbackID string) {
@@ -359,18 +590,18 @@ func sendFile(config *Config, chatID int64, threadID int64, filePath string, cap
 	io.Copy(part, file)
 	writer.Close()
 
-	resp, err := http.Post(
-		fmt.Sprintf("https://api.telegram.org/bot%s/sendDocument", config.BotToken),
-		writer.FormDataContentType(),
-		body,
-	)
+	apiURL := fmt.Sprintf("%s/bot%s/sendDocument", telegramAPIBase, config.BotToken)
+	result, err := telegramDo(config, chatID, func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", apiURL, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req, nil
+	})
 	if err != nil {
-		return redactTokenError(err, config.BotToken)
+		return err
 	}
-	defer resp.Body.Close()
-
-	var result TelegramResponse
-	json.NewDecoder(resp.Body).Decode(&result)
 	if !result.OK {
 		return fmt.Errorf("telegram error: %s", result.Description)
 	}
@@ -417,13 +648,13 @@ func downloadTelegramFile(config *Config, fileID string, destPath string) error
 	return err
 }
 
-func createForumTopic(config *Config, name string) (int64, error) {
-	if config.GroupID == 0 {
+func createForumTopic(config *Config, groupID int64, name string) (int64, error) {
+	if groupID == 0 {
 		return 0, fmt.Errorf("no group configured. Add bot to a group with topics enabled and run: ccc setgroup")
 	}
 
 	params := url.Values{
-		"chat_id": {fmt.Sprintf("%d", config.GroupID)},
+		"chat_id": {fmt.Sprintf("%d", groupID)},
 		"name":    {name},
 	}
 
@@ -443,13 +674,13 @@ func createForumTopic(config *Config, name string) (int64, error) {
 	return topic.MessageThreadID, nil
 }
 
-func deleteForumTopic(config *Config, topicID int64) error {
-	if config.GroupID == 0 {
+func deleteForumTopic(config *Config, groupID, topicID int64) error {
+	if groupID == 0 {
 		return fmt.Errorf("no group configured")
 	}
 
 	params := url.Values{
-		"chat_id":           {fmt.Sprintf("%d", config.GroupID)},
+		"chat_id":           {fmt.Sprintf("%d", groupID)},
 		"message_thread_id": {fmt.Sprintf("%d", topicID)},
 	}
 
@@ -464,45 +695,29 @@ func deleteForumTopic(config *Config, topicID int64) error {
 	return nil
 }
 
-// setBotCommands sets the bot commands in Telegram
+// setBotCommands registers the slash menu per scope. Telegram has no per-topic
+// scope, so groups get session-topic commands alongside group ones.
 func setBotCommands(botToken string) {
-	commands := []map[string]string{
-		{"command": "new", "description": "Create/restart session: /new <name>"},
-		{"command": "list", "description": "List all sessions with status"},
-		{"command": "delete", "description": "Delete current session and thread"},
-		{"command": "cleanup", "description": "Delete ALL sessions and threads"},
-		{"command": "c", "description": "Execute shell command: /c <cmd>"},
-		{"command": "continue", "description": "Restart session with history"},
-		{"command": "update", "description": "Update ccc binary from GitHub"},
-		{"command": "version", "description": "Show ccc version"},
-		{"command": "stats", "description": "Show system stats (RAM, disk, etc)"},
-		{"command": "auth", "description": "Re-authenticate Claude OAuth"},
-	}
-
-	// Set for default scope
-	defaultBody, _ := json.Marshal(map[string]interface{}{
-		"commands": commands,
-	})
-	resp, err := http.Post(
-		fmt.Sprintf("https://api.telegram.org/bot%s/setMyCommands", botToken),
-		"application/json",
-		bytes.NewReader(defaultBody),
-	)
-	if err == nil {
-		resp.Body.Close()
-	}
-
-	// Set for all group chats (makes the / button appear)
-	groupBody, _ := json.Marshal(map[string]interface{}{
-		"commands": commands,
-		"scope":    map[string]string{"type": "all_group_chats"},
-	})
-	resp, err = http.Post(
-		fmt.Sprintf("https://api.telegram.org/bot%s/setMyCommands", botToken),
-		"application/json",
-		bytes.NewReader(groupBody),
-	)
-	if err == nil {
-		resp.Body.Close()
+	scopes := []struct {
+		scope string
+		where int
+	}{
+		{"default", anywhere},
+		{"all_private_chats", inPrivate},
+		{"all_group_chats", inGroup | inTopic},
+	}
+	for _, s := range scopes {
+		body, _ := json.Marshal(map[string]interface{}{
+			"commands": menuCommands(s.where),
+			"scope":    map[string]string{"type": s.scope},
+		})
+		resp, err := http.Post(
+			fmt.Sprintf("https://api.telegram.org/bot%s/setMyCommands", botToken),
+			"application/json",
+			bytes.NewReader(body),
+		)
+		if err == nil {
+			resp.Body.Close()
+		}
 	}
 }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Telegram's send limits: about one message per second in a private chat,
+// 20 per minute in a group (shared by all its topics) and 30 per second overall
+const (
+	privateChatRate  = 1.0
+	privateChatBurst = 3
+	groupChatRate    = 20.0 / 60
+	groupChatBurst   = 20
+	globalSendRate   = 30
+)
+
+// outputShare is the part of a group's budget session output may use. The
+// rest is kept for replies to commands, so a burst of output doesn't hold
+// them up. A private chat gets little output and keeps one budget.
+const outputShare = 0.75
+
+// telegramMaxAttempts bounds how often one call is tried on 429 and 5xx
+const telegramMaxAttempts = 5
+
+// maxRetryAfter is the longest retry_after we wait out; beyond it the call fails
+const maxRetryAfter = 2 * time.Minute
+
+// throttledMethods are the calls that post or edit messages and count against the limits
+var throttledMethods = map[string]bool{
+	"sendMessage":     true,
+	"sendDocument":    true,
+	"sendPhoto":       true,
+	"editMessageText": true,
+}
+
+var (
+	telegramAPIBase = "https://api.telegram.org"
+	telegramSleep   = time.Sleep // replaced in tests
+)
+
+// rateLimiter is a token bucket handing out send slots in call order
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate, burst float64) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: burst, tokens: burst}
+}
+
+// reserve takes a slot and returns how long to wait before using it
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.After(l.last) {
+		if !l.last.IsZero() {
+			l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		}
+		l.last = now
+	}
+	l.tokens--
+	wait := l.last.Sub(now)
+	if l.tokens < 0 {
+		wait += time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	return wait
+}
+
+// holdUntil hands out no slots before t, after Telegram asked us to back off
+func (l *rateLimiter) holdUntil(t time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t.After(l.last) {
+		l.last = t
+		l.tokens = math.Min(l.tokens, 0)
+	}
+}
+
+// chatLane is a chat's send queue for session output or for everything else
+type chatLane struct {
+	chatID int64
+	output bool
+}
+
+var (
+	chatLimiters   = make(map[chatLane]*rateLimiter)
+	chatLimitersMu sync.Mutex
+	globalLimiter  = newRateLimiter(globalSendRate, globalSendRate)
+)
+
+// chatLimiter returns a chat's send queue for output or for replies. Group
+// IDs are negative.
+func chatLimiter(chatID int64, output bool) *rateLimiter {
+	lane := chatLane{chatID, output && chatID < 0}
+	chatLimitersMu.Lock()
+	defer chatLimitersMu.Unlock()
+	l := chatLimiters[lane]
+	if l == nil {
+		switch {
+		case chatID >= 0:
+			l = newRateLimiter(privateChatRate, privateChatBurst)
+		case lane.output:
+			l = newRateLimiter(groupChatRate*outputShare, groupChatBurst*outputShare)
+		default:
+			l = newRateLimiter(groupChatRate*(1-outputShare), groupChatBurst*(1-outputShare))
+		}
+		chatLimiters[lane] = l
+	}
+	return l
+}
+
+// throttleChat blocks until a message may be sent to chatID, in the output
+// lane for session output
+func throttleChat(chatID int64, output bool) {
+	now := time.Now()
+	wait := chatLimiter(chatID, output).reserve(now)
+	if g := globalLimiter.reserve(now); g > wait {
+		wait = g
+	}
+	if wait > 0 {
+		telegramSleep(wait)
+	}
+}
+
+// telegramDo sends the request built by newReq and decodes Telegram's reply.
+// Sends to chatID (0 for unthrottled calls) wait their turn in the chat's
+// queue, the output one when config comes from outputMessenger. HTTP 429 is retried after Telegram's retry_after, 5xx with
+// exponential backoff; the last reply is returned when retries run out.
+func telegramDo(config *Config, chatID int64, newReq func() (*http.Request, error)) (*TelegramResponse, error) {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		if chatID != 0 {
+			throttleChat(chatID, config.outputLane)
+		}
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, redactTokenError(err, config.BotToken)
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+		resp.Body.Close()
+		var result TelegramResponse
+		json.Unmarshal(body, &result)
+
+		status := resp.StatusCode
+		if (status != http.StatusTooManyRequests && status < 500) || attempt == telegramMaxAttempts {
+			return &result, nil
+		}
+
+		wait := backoff
+		backoff *= 2
+		if status == http.StatusTooManyRequests && result.Parameters.RetryAfter > 0 {
+			wait = time.Duration(result.Parameters.RetryAfter) * time.Second
+			if wait > maxRetryAfter {
+				return &result, nil
+			}
+		}
+		hookLog("telegram: HTTP %d, retrying in %v (attempt %d/%d)", status, wait, attempt, telegramMaxAttempts)
+		if status == http.StatusTooManyRequests && chatID != 0 {
+			// Hold both of the chat's queues, not just this call
+			chatLimiter(chatID, false).holdUntil(time.Now().Add(wait))
+			chatLimiter(chatID, true).holdUntil(time.Now().Add(wait))
+			continue
+		}
+		telegramSleep(wait)
+	}
+}
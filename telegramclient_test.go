@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter(1, 2)
+	if w := l.reserve(now); w != 0 {
+		t.Errorf("first reserve waits %v, want 0", w)
+	}
+	if w := l.reserve(now); w != 0 {
+		t.Errorf("second reserve within burst waits %v, want 0", w)
+	}
+	if w := l.reserve(now); w != time.Second {
+		t.Errorf("third reserve waits %v, want 1s", w)
+	}
+	if w := l.reserve(now); w != 2*time.Second {
+		t.Errorf("fourth reserve waits %v, want 2s (queued behind the third)", w)
+	}
+
+	// After a 429 nothing goes out before the hold ends
+	l = newRateLimiter(1, 5)
+	l.holdUntil(now.Add(10 * time.Second))
+	if w := l.reserve(now); w < 10*time.Second {
+		t.Errorf("reserve during hold waits %v, want at least 10s", w)
+	}
+}
+
+// fakeTelegram serves scripted status codes and bodies, then 200 OK, and
+// records sleeps instead of sleeping
+func fakeTelegram(t *testing.T, replies ...string) (*int, *time.Duration, func()) {
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if calls < len(replies) {
+			var status int
+			var body string
+			fmt.Sscanf(replies[calls], "%d", &status)
+			body = replies[calls][4:]
+			calls++
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+			return
+		}
+		calls++
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":7}}`)
+	}))
+	origBase, origSleep := telegramAPIBase, telegramSleep
+	telegramAPIBase = srv.URL
+	var slept time.Duration
+	telegramSleep = func(d time.Duration) { slept += d }
+	return &calls, &slept, func() {
+		srv.Close()
+		telegramAPIBase, telegramSleep = origBase, origSleep
+	}
+}
+
+func TestTelegramAPIRetries(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	config := &Config{BotToken: "test"}
+
+	calls, slept, done := fakeTelegram(t,
+		`429 {"ok":false,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`,
+		`502 bad gateway`,
+	)
+	result, err := telegramAPI(config, "sendMessage", url.Values{"chat_id": {"-100123"}, "text": {"hi"}})
+	done()
+	if err != nil || !result.OK {
+		t.Fatalf("telegramAPI = %+v, %v; want OK after retries", result, err)
+	}
+	if *calls != 3 {
+		t.Errorf("calls = %d, want 3", *calls)
+	}
+	if *slept < 900*time.Millisecond {
+		t.Errorf("slept %v, want retry_after (1s) to be honored", *slept)
+	}
+
+	calls, _, done = fakeTelegram(t, `400 {"ok":false,"description":"Bad Request: chat not found"}`)
+	result, err = telegramAPI(config, "getChat", url.Values{"chat_id": {"1"}})
+	done()
+	if err != nil || result.OK || result.Description != "Bad Request: chat not found" {
+		t.Errorf("telegramAPI on 400 = %+v, %v; want the error description", result, err)
+	}
+	if *calls != 1 {
+		t.Errorf("client errors should not be retried: calls = %d", *calls)
+	}
+
+	calls, _, done = fakeTelegram(t, "500 a", "500 b", "500 c", "500 d", "500 e", "500 f")
+	result, _ = telegramAPI(config, "getMe", nil)
+	done()
+	if result.OK || *calls != telegramMaxAttempts {
+		t.Errorf("persistent 5xx: ok=%v calls=%d, want failure after %d attempts", result.OK, *calls, telegramMaxAttempts)
+	}
+}
+
+func TestTelegramOutputLane(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	config := &Config{BotToken: "test"}
+	output := *config
+	output.outputLane = true
+	params := url.Values{"chat_id": {"-100777"}, "text": {"hi"}}
+
+	_, slept, done := fakeTelegram(t)
+	defer done()
+	for i := 0; i < groupChatBurst; i++ {
+		telegramAPI(&output, "sendMessage", params)
+	}
+	if *slept == 0 {
+		t.Fatal("a group's full burst of output should exceed the output budget")
+	}
+
+	// Replies have their own budget, and edits count against it too
+	*slept = 0
+	telegramAPI(config, "sendMessage", params)
+	telegramAPI(config, "editMessageText", params)
+	if *slept != 0 {
+		t.Errorf("replies waited %v behind session output", *slept)
+	}
+	for i := 0; i < groupChatBurst; i++ {
+		telegramAPI(config, "editMessageText", params)
+	}
+	if *slept == 0 {
+		t.Error("edits should be throttled")
+	}
+}
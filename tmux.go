@@ -66,10 +66,26 @@ func tmuxSessionExists(name string) bool {
 }
 
 func createTmuxSession(name string, workDir string, continueSession bool) error {
+	runArgs := ""
+	if continueSession {
+		runArgs = "-c"
+	}
+	return startTmuxSession(name, workDir, runArgs)
+}
+
+// startTmuxSession creates a tmux session running "ccc run <runArgs>"
+func startTmuxSession(name, workDir, runArgs string) error {
 	// Build the command to run inside tmux
 	cccCmd := cccPath + " run"
-	if continueSession {
-		cccCmd += " -c"
+	if runArgs != "" {
+		cccCmd += " " + runArgs
+	}
+	if config, err := loadConfig(); err == nil {
+		cccCmd = limitSessionCommand(config, cccCmd)
+	}
+	if p := currentProfile(); p != "" {
+		// Claude's hooks find the profile's config through the environment
+		cccCmd = profileEnv + "=" + p + " " + cccCmd
 	}
 
 	// Create tmux session with a login shell (don't run command directly - it kills session on exit)
@@ -89,14 +105,21 @@ func createTmuxSession(name string, workDir string, continueSession bool) error
 	return nil
 }
 
-// runClaudeRaw runs claude directly (used inside tmux sessions)
-func runClaudeRaw(continueSession bool) error {
+// runClaudeRaw runs claude directly (used inside tmux sessions), resuming
+// resumeID if set
+func runClaudeRaw(continueSession bool, resumeID string) error {
 	if claudePath == "" {
 		return fmt.Errorf("claude binary not found")
 	}
 
+	config, configErr := loadConfig()
 	args := []string{"--dangerously-skip-permissions"}
-	if continueSession {
+	if configErr == nil {
+		args = append(args, sessionClaudeFlags(config, currentTmuxSession())...)
+	}
+	if resumeID != "" {
+		args = append(args, "--resume", resumeID)
+	} else if continueSession {
 		args = append(args, "-c")
 	}
 
@@ -106,10 +129,8 @@ func runClaudeRaw(continueSession bool) error {
 	cmd.Stderr = os.Stderr
 
 	// Ensure OAuth token is available from config if not already in environment
-	if os.Getenv("CLAUDE_CODE_OAUTH_TOKEN") == "" {
-		if config, err := loadConfig(); err == nil && config.OAuthToken != "" {
-			cmd.Env = append(os.Environ(), "CLAUDE_CODE_OAUTH_TOKEN="+config.OAuthToken)
-		}
+	if os.Getenv("CLAUDE_CODE_OAUTH_TOKEN") == "" && configErr == nil && config.OAuthToken != "" {
+		cmd.Env = append(os.Environ(), "CLAUDE_CODE_OAUTH_TOKEN="+config.OAuthToken)
 	}
 
 	return cmd.Run()
@@ -186,11 +207,78 @@ func sendToTmuxWithDelay(session string, text string, delay time.Duration) error
 	return nil
 }
 
+// paneCurrentCommand returns the name of the foreground process in the session's pane
+func paneCurrentCommand(session string) string {
+	out, err := exec.Command(tmuxPath, "display-message", "-p", "-t", session, "#{pane_current_command}").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// isShellCommand reports whether a pane's foreground process is an interactive shell
+func isShellCommand(command string) bool {
+	switch strings.TrimPrefix(command, "-") {
+	case "bash", "zsh", "sh", "fish", "dash", "ksh":
+		return true
+	}
+	return false
+}
+
+// restartClaudeInPane exits Claude inside an existing tmux session and starts it
+// again with "ccc run -c", keeping the tmux window and its scrollback.
+func restartClaudeInPane(session string) error {
+	if err := stopClaudeInPane(session); err != nil {
+		return err
+	}
+	return launchClaudeInPane(session, "-c")
+}
+
+// stopClaudeInPane exits Claude inside a tmux session, leaving its shell
+func stopClaudeInPane(session string) error {
+	// Claude Code needs Ctrl-C twice to exit; retry in case the first
+	// press only interrupted a running tool
+	for attempt := 0; attempt < 3 && !isShellCommand(paneCurrentCommand(session)); attempt++ {
+		exec.Command(tmuxPath, "send-keys", "-t", session, "C-c").Run()
+		time.Sleep(200 * time.Millisecond)
+		exec.Command(tmuxPath, "send-keys", "-t", session, "C-c").Run()
+		time.Sleep(1 * time.Second)
+	}
+
+	if current := paneCurrentCommand(session); !isShellCommand(current) {
+		return fmt.Errorf("pane is still running %q, not a shell", current)
+	}
+	return nil
+}
+
+// launchClaudeInPane types "ccc run <runArgs>" into a pane's shell
+func launchClaudeInPane(session, runArgs string) error {
+	// Clear any partially typed input before running the command
+	exec.Command(tmuxPath, "send-keys", "-t", session, "C-u").Run()
+	return exec.Command(tmuxPath, "send-keys", "-t", session, strings.TrimSpace(cccPath+" run "+runArgs), "C-m").Run()
+}
+
+// capturePaneTail returns the last n non-blank-trailing lines of a pane,
+// including scrollback, exactly as tmux renders them
+func capturePaneTail(session string, n int) (string, error) {
+	out, err := exec.Command(tmuxPath, "capture-pane", "-t", session, "-p", "-S", fmt.Sprintf("-%d", n+50)).Output()
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimRight(string(out), " \n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
 func killTmuxSession(name string) error {
+	forgetPaneOffset(name)
 	cmd := exec.Command(tmuxPath, "kill-session", "-t", name)
 	return cmd.Run()
 }
 
+// listTmuxSessions returns the full names of the profile's tmux sessions
 func listTmuxSessions() ([]string, error) {
 	cmd := exec.Command(tmuxPath, "list-sessions", "-F", "#{session_name}")
 	out, err := cmd.Output()
@@ -202,8 +290,8 @@ func listTmuxSessions() ([]string, error) {
 	scanner := bufio.NewScanner(bytes.NewReader(out))
 	for scanner.Scan() {
 		name := scanner.Text()
-		if strings.HasPrefix(name, "claude-") {
-			sessions = append(sessions, strings.TrimPrefix(name, "claude-"))
+		if strings.HasPrefix(name, tmuxPrefix()) {
+			sessions = append(sessions, name)
 		}
 	}
 	return sessions, nil
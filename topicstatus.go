@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// topicBranchInterval is how often a session's git branch is looked up for
+// its topic name
+const topicBranchInterval = 30 * time.Second
+
+// maxTopicNameLen is Telegram's limit on forum topic names
+const maxTopicNameLen = 128
+
+// Session states shown as the topic icon
+const (
+	topicWorking = "working"
+	topicIdle    = "idle"
+	topicError   = "error"
+)
+
+// topicStateIcons are the icons tried for each state, in order. Topic icons
+// must come from Telegram's forum icon set (getForumTopicIconStickers); the
+// color of a topic can only be chosen when it is created.
+var topicStateIcons = map[string][]string{
+	topicWorking: {"⚡", "🔥"},
+	topicIdle:    {"✅", "✔", "💬"},
+	topicError:   {"❗", "‼", "⁉"},
+}
+
+// topicStatus is what was last applied to a session's topic
+type topicStatus struct {
+	Name          string
+	State         string
+	Branch        string
+	BranchChecked time.Time
+}
+
+var (
+	topicStatuses   = make(map[string]*topicStatus)
+	topicStatusesMu sync.Mutex
+
+	// topicIcons maps an emoji to its forum icon's custom emoji ID; loaded
+	// once, empty if the lookup failed
+	topicIcons     map[string]string
+	topicIconsOnce sync.Once
+)
+
+// topicTitle is the topic name for a session on a branch: "api ⎇ fix-auth"
+func topicTitle(sessName, branch string) string {
+	title := sessName
+	if branch != "" && branch != "HEAD" {
+		title += " ⎇ " + branch
+	}
+	if len(title) > maxTopicNameLen {
+		title = truncate(title, maxTopicNameLen-3)
+	}
+	return title
+}
+
+// gitBranch returns the branch checked out in dir, or "" outside a repo
+func gitBranch(dir string) string {
+	if dir == "" || !isGitRepo(dir) {
+		return ""
+	}
+	out, err := runGitTimeout(dir, 5*time.Second, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// normalizeIconEmoji drops variation selectors so "⚡️" and "⚡" match
+func normalizeIconEmoji(emoji string) string {
+	return strings.ReplaceAll(emoji, "\ufe0f", "")
+}
+
+// loadTopicIcons fetches the emoji usable as forum topic icons
+func loadTopicIcons(config *Config) map[string]string {
+	icons := make(map[string]string)
+	result, err := telegramAPI(config, "getForumTopicIconStickers", nil)
+	if err != nil || !result.OK {
+		hookLog("topics: loading icons failed: %v", err)
+		return icons
+	}
+	var stickers []struct {
+		Emoji         string `json:"emoji"`
+		CustomEmojiID string `json:"custom_emoji_id"`
+	}
+	json.Unmarshal(result.Result, &stickers)
+	for _, s := range stickers {
+		if s.CustomEmojiID != "" {
+			icons[normalizeIconEmoji(s.Emoji)] = s.CustomEmojiID
+		}
+	}
+	return icons
+}
+
+// topicIconFor picks the icon for a state from the available icons
+func topicIconFor(icons map[string]string, state string) string {
+	for _, emoji := range topicStateIcons[state] {
+		if id := icons[emoji]; id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// updateTopicStatus renames a session's topic after its git branch and sets
+// its icon for the state, calling Telegram only when something changed
+func updateTopicStatus(config *Config, sessName string, info *SessionInfo, state string) {
+	if !config.TopicStatus || configuredMessenger(config) != messengerTelegram || info.TopicID == 0 {
+		return
+	}
+
+	topicStatusesMu.Lock()
+	st := topicStatuses[sessName]
+	if st == nil {
+		st = &topicStatus{}
+		topicStatuses[sessName] = st
+	}
+	checkBranch := time.Since(st.BranchChecked) >= topicBranchInterval
+	branch := st.Branch
+	topicStatusesMu.Unlock()
+
+	if checkBranch {
+		branch = gitBranch(info.Path)
+	}
+	name := topicTitle(sessName, branch)
+
+	topicStatusesMu.Lock()
+	if checkBranch {
+		st.Branch, st.BranchChecked = branch, time.Now()
+	}
+	changed := st.Name != name || st.State != state
+	topicStatusesMu.Unlock()
+	if !changed {
+		return
+	}
+
+	topicIconsOnce.Do(func() { topicIcons = loadTopicIcons(config) })
+	params := url.Values{
+		"chat_id":           {fmt.Sprintf("%d", sessionGroup(config, info))},
+		"message_thread_id": {fmt.Sprintf("%d", info.TopicID)},
+		"name":              {name},
+	}
+	if icon := topicIconFor(topicIcons, state); icon != "" {
+		params.Set("icon_custom_emoji_id", icon)
+	}
+	result, err := telegramAPI(config, "editForumTopic", params)
+	if err == nil && !result.OK && !strings.Contains(result.Description, "TOPIC_NOT_MODIFIED") {
+		err = fmt.Errorf("%s", result.Description)
+	}
+	if err != nil {
+		// Not retried until the name or state changes again, so a bot
+		// without the right to manage topics doesn't call every tick
+		hookLog("topics: session=%s editing topic: %v", sessName, err)
+	}
+
+	topicStatusesMu.Lock()
+	st.Name, st.State = name, state
+	topicStatusesMu.Unlock()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTopicTitle(t *testing.T) {
+	tests := []struct{ sess, branch, want string }{
+		{"api", "fix-auth", "api ⎇ fix-auth"},
+		{"api", "", "api"},
+		{"api", "HEAD", "api"}, // detached
+	}
+	for _, tt := range tests {
+		if got := topicTitle(tt.sess, tt.branch); got != tt.want {
+			t.Errorf("topicTitle(%q, %q) = %q, want %q", tt.sess, tt.branch, got, tt.want)
+		}
+	}
+	if got := topicTitle("api", strings.Repeat("b", 200)); len(got) > maxTopicNameLen {
+		t.Errorf("title is %d bytes, Telegram allows %d", len(got), maxTopicNameLen)
+	}
+}
+
+func TestTopicIconFor(t *testing.T) {
+	icons := map[string]string{normalizeIconEmoji("⚡️"): "111", "🔥": "222", "💬": "333"}
+	if got := topicIconFor(icons, topicWorking); got != "111" {
+		t.Errorf("working icon = %q, want the ⚡ icon", got)
+	}
+	if got := topicIconFor(icons, topicIdle); got != "333" {
+		t.Errorf("idle icon = %q, want the first available fallback", got)
+	}
+	if got := topicIconFor(icons, topicError); got != "" {
+		t.Errorf("error icon = %q, want none when the set lacks one", got)
+	}
+}
+
+func TestUpdateTopicStatus(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	calls, _, done := fakeTelegram(t)
+	defer done()
+
+	config := &Config{BotToken: "test", GroupID: -100, TopicStatus: true}
+	info := &SessionInfo{TopicID: 5, Path: t.TempDir()}
+	updateTopicStatus(config, "topic-test", info, topicWorking)
+	first := *calls
+	if first == 0 {
+		t.Fatal("the topic should have been edited")
+	}
+	updateTopicStatus(config, "topic-test", info, topicWorking)
+	if *calls != first {
+		t.Error("an unchanged state should not call Telegram")
+	}
+	updateTopicStatus(config, "topic-test", info, topicIdle)
+	if *calls != first+1 {
+		t.Errorf("a state change made %d calls, want 1", *calls-first)
+	}
+
+	config.TopicStatus = false
+	updateTopicStatus(config, "topic-test", info, topicError)
+	if *calls != first+1 {
+		t.Error("topic_status off should leave topics alone")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Transcription backends for voice messages
+const (
+	transcribeLocal    = "local"    // run transcription_cmd on the audio file
+	transcribeOpenAI   = "openai"   // OpenAI audio transcriptions API
+	transcribeDeepgram = "deepgram" // Deepgram pre-recorded audio API
+)
+
+// Remote transcription endpoints (variables so tests can point them at a fake server)
+var (
+	openAITranscriptionURL = "https://api.openai.com/v1/audio/transcriptions"
+	deepgramListenURL      = "https://api.deepgram.com/v1/listen?smart_format=true&model=nova-2"
+)
+
+// transcriptionTimeout bounds one transcription, local or remote
+const transcriptionTimeout = 2 * time.Minute
+
+// configuredTranscriptionBackend returns the backend to use, or "" when voice
+// messages aren't set up. Without an explicit backend a transcription_cmd
+// implies local.
+func configuredTranscriptionBackend(config *Config) string {
+	if config.TranscriptionBackend != "" {
+		return config.TranscriptionBackend
+	}
+	if config.TranscriptionCmd != "" {
+		return transcribeLocal
+	}
+	return ""
+}
+
+// transcribeAudio turns a voice message file into text with the configured backend
+func transcribeAudio(config *Config, audioPath string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), transcriptionTimeout)
+	defer cancel()
+
+	var text string
+	var err error
+	switch backend := configuredTranscriptionBackend(config); backend {
+	case transcribeLocal:
+		text, err = transcribeWithCommand(ctx, config.TranscriptionCmd, audioPath)
+	case transcribeOpenAI:
+		text, err = transcribeWithOpenAI(ctx, config.TranscriptionAPIKey, audioPath)
+	case transcribeDeepgram:
+		text, err = transcribeWithDeepgram(ctx, config.TranscriptionAPIKey, audioPath)
+	case "":
+		return "", fmt.Errorf("voice messages are not set up. Run: ccc config transcription-backend <local|openai|deepgram>")
+	default:
+		return "", fmt.Errorf("unknown transcription backend %q", backend)
+	}
+	if err != nil {
+		return "", err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("no speech recognized")
+	}
+	return text, nil
+}
+
+// transcribeWithCommand runs cmd with the audio path and returns its stdout
+func transcribeWithCommand(ctx context.Context, cmd, audioPath string) (string, error) {
+	if cmd == "" {
+		return "", fmt.Errorf("the local backend needs transcription_cmd set (ccc config transcription-cmd <command>)")
+	}
+	var stderr bytes.Buffer
+	c := exec.CommandContext(ctx, expandPath(cmd), audioPath)
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v %s", filepath.Base(cmd), err, truncate(strings.TrimSpace(stderr.String()), 300))
+	}
+	return string(out), nil
+}
+
+// transcribeWithOpenAI uploads the file to OpenAI's transcription endpoint
+func transcribeWithOpenAI(ctx context.Context, apiKey, audioPath string) (string, error) {
+	if apiKey == "" {
+		return "", fmt.Errorf("the openai backend needs an API key. Run: ccc config transcription-key <key>")
+	}
+	f, err := os.Open(audioPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("model", "whisper-1")
+	part, err := writer.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return "", err
+	}
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", openAITranscriptionURL, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := doTranscriptionRequest(req, &result); err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// transcribeWithDeepgram posts the raw audio to Deepgram
+func transcribeWithDeepgram(ctx context.Context, apiKey, audioPath string) (string, error) {
+	if apiKey == "" {
+		return "", fmt.Errorf("the deepgram backend needs an API key. Run: ccc config transcription-key <key>")
+	}
+	f, err := os.Open(audioPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", deepgramListenURL, f)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Token "+apiKey)
+	req.Header.Set("Content-Type", "audio/ogg")
+
+	var result struct {
+		Results struct {
+			Channels []struct {
+				Alternatives []struct {
+					Transcript string `json:"transcript"`
+				} `json:"alternatives"`
+			} `json:"channels"`
+		} `json:"results"`
+	}
+	if err := doTranscriptionRequest(req, &result); err != nil {
+		return "", err
+	}
+	if len(result.Results.Channels) == 0 || len(result.Results.Channels[0].Alternatives) == 0 {
+		return "", nil
+	}
+	return result.Results.Channels[0].Alternatives[0].Transcript, nil
+}
+
+// doTranscriptionRequest sends a transcription API request and decodes the JSON reply
+func doTranscriptionRequest(req *http.Request, result interface{}) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("transcription API returned %s: %s", resp.Status, truncate(strings.TrimSpace(string(data)), 300))
+	}
+	return json.Unmarshal(data, result)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfiguredTranscriptionBackend(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   string
+	}{
+		{Config{}, ""},
+		{Config{TranscriptionCmd: "~/bin/transcribe"}, transcribeLocal},
+		{Config{TranscriptionBackend: transcribeDeepgram, TranscriptionCmd: "x"}, transcribeDeepgram},
+	}
+	for _, tt := range tests {
+		if got := configuredTranscriptionBackend(&tt.config); got != tt.want {
+			t.Errorf("configuredTranscriptionBackend(%+v) = %q, want %q", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestTranscribeRemote(t *testing.T) {
+	audio := filepath.Join(t.TempDir(), "voice.ogg")
+	os.WriteFile(audio, []byte("OggS fake audio"), 0644)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer sk-test":
+			if err := r.ParseMultipartForm(1 << 20); err != nil || r.FormValue("model") != "whisper-1" {
+				http.Error(w, "bad form", http.StatusBadRequest)
+				return
+			}
+			f, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, "no file", http.StatusBadRequest)
+				return
+			}
+			data, _ := io.ReadAll(f)
+			if string(data) != "OggS fake audio" {
+				http.Error(w, "wrong file", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"text":" hello from openai "}`))
+		case "Token dg-test":
+			if r.Header.Get("Content-Type") != "audio/ogg" {
+				http.Error(w, "bad type", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"results":{"channels":[{"alternatives":[{"transcript":"hello from deepgram"}]}]}}`))
+		default:
+			http.Error(w, `{"error":"invalid key"}`, http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	origOpenAI, origDeepgram := openAITranscriptionURL, deepgramListenURL
+	openAITranscriptionURL, deepgramListenURL = server.URL, server.URL
+	defer func() { openAITranscriptionURL, deepgramListenURL = origOpenAI, origDeepgram }()
+
+	text, err := transcribeAudio(&Config{TranscriptionBackend: transcribeOpenAI, TranscriptionAPIKey: "sk-test"}, audio)
+	if err != nil || text != "hello from openai" {
+		t.Errorf("openai = (%q, %v)", text, err)
+	}
+	text, err = transcribeAudio(&Config{TranscriptionBackend: transcribeDeepgram, TranscriptionAPIKey: "dg-test"}, audio)
+	if err != nil || text != "hello from deepgram" {
+		t.Errorf("deepgram = (%q, %v)", text, err)
+	}
+	if _, err := transcribeAudio(&Config{TranscriptionBackend: transcribeOpenAI, TranscriptionAPIKey: "wrong"}, audio); err == nil {
+		t.Error("expected an error for a rejected key")
+	}
+	if _, err := transcribeAudio(&Config{TranscriptionBackend: transcribeDeepgram}, audio); err == nil {
+		t.Error("expected an error without an API key")
+	}
+}
+
+func TestTranscribeWithCommand(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "transcribe")
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"heard $(basename $1)\"\n"), 0755)
+
+	text, err := transcribeWithCommand(context.Background(), script, "/tmp/voice.ogg")
+	if err != nil || text != "heard voice.ogg\n" {
+		t.Errorf("transcribeWithCommand = (%q, %v)", text, err)
+	}
+	if _, err := transcribeWithCommand(context.Background(), "", "/tmp/voice.ogg"); err == nil {
+		t.Error("expected an error without a command")
+	}
+}
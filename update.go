@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+const (
+	releasesBase = "https://github.com/rsh3khar/ccc/releases"
+	releasesAPI  = "https://api.github.com/repos/rsh3khar/ccc/releases"
+)
+
+// releaseTagPattern is what /update accepts as a release tag
+var releaseTagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// oldBinaryPath is the binary an update replaced, kept for /rollback
+func oldBinaryPath() string {
+	return cccPath + ".old"
+}
+
+// releaseDownloadBase returns the URL release assets are downloaded from, and
+// a label for messages. channel is "stable" (the latest release, default),
+// "beta" (the newest release, pre-releases included) or a release tag.
+func releaseDownloadBase(channel string) (string, string, error) {
+	switch channel {
+	case "", "stable":
+		return releasesBase + "/latest/download", "latest release", nil
+	case "beta":
+		tag, err := newestReleaseTag(releasesAPI + "?per_page=1")
+		if err != nil {
+			return "", "", err
+		}
+		return releasesBase + "/download/" + tag, tag, nil
+	}
+	if !releaseTagPattern.MatchString(channel) {
+		return "", "", fmt.Errorf("unknown channel or tag: %s (use stable, beta or a release tag)", channel)
+	}
+	return releasesBase + "/download/" + channel, channel, nil
+}
+
+// newestReleaseTag returns the tag of the first release the GitHub API lists,
+// which is the newest one, pre-release or not
+func newestReleaseTag(apiURL string) (string, error) {
+	resp, err := http.Get(apiURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to list releases: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to list releases: HTTP %d", resp.StatusCode)
+	}
+	var releases []struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&releases); err != nil {
+		return "", fmt.Errorf("failed to list releases: %w", err)
+	}
+	if len(releases) == 0 || !releaseTagPattern.MatchString(releases[0].TagName) {
+		return "", fmt.Errorf("no releases found")
+	}
+	return releases[0].TagName, nil
+}
+
+// swapBinaries exchanges the running binary with the one at old, so a
+// rollback can itself be rolled back
+func swapBinaries(current, old string) error {
+	if _, err := os.Stat(old); err != nil {
+		return fmt.Errorf("no previous binary to roll back to")
+	}
+	tmp := current + ".swap"
+	if err := os.Rename(current, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(old, current); err != nil {
+		os.Rename(tmp, current)
+		return err
+	}
+	return os.Rename(tmp, old)
+}
+
+// rollbackCCC puts back the binary the last /update replaced and restarts
+func rollbackCCC(config *Config, chatID, threadID int64, offset int) {
+	if err := swapBinaries(cccPath, oldBinaryPath()); err != nil {
+		sendMessage(config, chatID, threadID, fmt.Sprintf("❌ Rollback failed: %v", err))
+		return
+	}
+	sendMessage(config, chatID, threadID, "✅ Rolled back to the previous binary. Restarting...")
+	restartAfterUpdate(config, offset)
+}
+
+// restartAfterUpdate exits so the service manager starts the new binary
+func restartAfterUpdate(config *Config, offset int) {
+	// Confirm offset so the command is not reprocessed after restart
+	http.Get(fmt.Sprintf("https://api.telegram.org/bot%s/getUpdates?offset=%d&timeout=1", config.BotToken, offset))
+	os.Exit(0)
+}
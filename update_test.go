@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReleaseDownloadBase(t *testing.T) {
+	tests := []struct {
+		channel string
+		want    string
+		wantErr bool
+	}{
+		{"", releasesBase + "/latest/download", false},
+		{"stable", releasesBase + "/latest/download", false},
+		{"v2.1.0-rc1", releasesBase + "/download/v2.1.0-rc1", false},
+		{"../../evil", "", true},
+		{"v1 v2", "", true},
+	}
+	for _, tt := range tests {
+		got, _, err := releaseDownloadBase(tt.channel)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("releaseDownloadBase(%q) = %q, %v", tt.channel, got, err)
+		}
+	}
+}
+
+func TestNewestReleaseTag(t *testing.T) {
+	body := `[{"tag_name":"v2.1.0-beta.2","prerelease":true},{"tag_name":"v2.0.0"}]`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	if tag, err := newestReleaseTag(srv.URL); err != nil || tag != "v2.1.0-beta.2" {
+		t.Errorf("newestReleaseTag = %q, %v", tag, err)
+	}
+	body = `[]`
+	if _, err := newestReleaseTag(srv.URL); err == nil {
+		t.Error("expected an error without releases")
+	}
+}
+
+func TestSwapBinaries(t *testing.T) {
+	dir := t.TempDir()
+	current, old := filepath.Join(dir, "ccc"), filepath.Join(dir, "ccc.old")
+	if err := swapBinaries(current, old); err == nil {
+		t.Error("expected an error without a previous binary")
+	}
+
+	os.WriteFile(current, []byte("new"), 0755)
+	os.WriteFile(old, []byte("old"), 0755)
+	if err := swapBinaries(current, old); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(current); string(data) != "old" {
+		t.Errorf("current = %q after rollback, want old", data)
+	}
+	if data, _ := os.ReadFile(old); string(data) != "new" {
+		t.Errorf("ccc.old = %q after rollback, want new (so it can be undone)", data)
+	}
+}
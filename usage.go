@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UsageTotals is token usage and estimated cost summed over transcript entries
+type UsageTotals struct {
+	InputTokens         int64   `json:"input_tokens"`
+	OutputTokens        int64   `json:"output_tokens"`
+	CacheCreationTokens int64   `json:"cache_creation_tokens"`
+	CacheReadTokens     int64   `json:"cache_read_tokens"`
+	CostUSD             float64 `json:"cost_usd"`
+}
+
+func (u *UsageTotals) add(o UsageTotals) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CacheCreationTokens += o.CacheCreationTokens
+	u.CacheReadTokens += o.CacheReadTokens
+	u.CostUSD += o.CostUSD
+}
+
+// totalTokens counts every token billed, cached or not
+func (u UsageTotals) totalTokens() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationTokens + u.CacheReadTokens
+}
+
+// modelPrice is USD per million tokens
+type modelPrice struct {
+	input, output float64
+}
+
+// modelPrices is matched by substring in order, so specific versions come first.
+// Cache writes cost 1.25x input and cache reads 0.1x input.
+var modelPrices = []struct {
+	match string
+	price modelPrice
+}{
+	{"opus-4-5", modelPrice{5, 25}},
+	{"opus", modelPrice{15, 75}},
+	{"sonnet", modelPrice{3, 15}},
+	{"haiku-4-5", modelPrice{1, 5}},
+	{"haiku", modelPrice{0.8, 4}},
+}
+
+// estimateCost prices usage for a model; unknown models are priced as Sonnet
+func estimateCost(model string, u UsageTotals) float64 {
+	price := modelPrice{3, 15}
+	for _, p := range modelPrices {
+		if strings.Contains(model, p.match) {
+			price = p.price
+			break
+		}
+	}
+	perToken := func(perMTok float64) float64 { return perMTok / 1e6 }
+	return float64(u.InputTokens)*perToken(price.input) +
+		float64(u.OutputTokens)*perToken(price.output) +
+		float64(u.CacheCreationTokens)*perToken(price.input*1.25) +
+		float64(u.CacheReadTokens)*perToken(price.input*0.1)
+}
+
+// transcriptEntry is the subset of a transcript JSONL line that carries usage
+type transcriptEntry struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	CostUSD   *float64  `json:"costUSD"`
+	Message   struct {
+		ID    string `json:"id"`
+		Model string `json:"model"`
+		Usage *struct {
+			InputTokens         int64 `json:"input_tokens"`
+			OutputTokens        int64 `json:"output_tokens"`
+			CacheCreationTokens int64 `json:"cache_creation_input_tokens"`
+			CacheReadTokens     int64 `json:"cache_read_input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+// scanTranscriptUsage sums usage of assistant entries at or after since.
+// A response split over several lines repeats its usage, so entries are
+// counted once per message ID. A recorded costUSD wins over the estimate.
+func scanTranscriptUsage(path string, since time.Time) (UsageTotals, error) {
+	var totals UsageTotals
+	f, err := os.Open(path)
+	if err != nil {
+		return totals, err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e transcriptEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || e.Type != "assistant" || e.Message.Usage == nil {
+			continue
+		}
+		if !since.IsZero() && e.Timestamp.Before(since) {
+			continue
+		}
+		if e.Message.ID != "" {
+			if seen[e.Message.ID] {
+				continue
+			}
+			seen[e.Message.ID] = true
+		}
+
+		u := UsageTotals{
+			InputTokens:         e.Message.Usage.InputTokens,
+			OutputTokens:        e.Message.Usage.OutputTokens,
+			CacheCreationTokens: e.Message.Usage.CacheCreationTokens,
+			CacheReadTokens:     e.Message.Usage.CacheReadTokens,
+		}
+		if e.CostUSD != nil {
+			u.CostUSD = *e.CostUSD
+		} else {
+			u.CostUSD = estimateCost(e.Message.Model, u)
+		}
+		totals.add(u)
+	}
+	return totals, scanner.Err()
+}
+
+// nonAlnum matches the characters Claude replaces with "-" in project dir names
+var nonAlnum = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// claudeProjectDir returns where Claude keeps transcripts for a working directory
+// (~/.claude/projects/<path with non-alphanumerics replaced by ->)
+func claudeProjectDir(workDir string) string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".claude", "projects", nonAlnum.ReplaceAllString(workDir, "-"))
+}
+
+// projectUsage sums usage across every transcript of a project since a time
+func projectUsage(workDir string, since time.Time) UsageTotals {
+	var totals UsageTotals
+	files, _ := filepath.Glob(filepath.Join(claudeProjectDir(workDir), "*.jsonl"))
+	for _, file := range files {
+		// Transcripts untouched since the cutoff can't contain newer entries
+		if st, err := os.Stat(file); err != nil || (!since.IsZero() && st.ModTime().Before(since)) {
+			continue
+		}
+		u, err := scanTranscriptUsage(file, since)
+		if err != nil {
+			hookLog("cost: failed to read %s: %v", file, err)
+		}
+		totals.add(u)
+	}
+	return totals
+}
+
+// SessionUsage is a session's usage today and over all its transcripts
+type SessionUsage struct {
+	Name  string      `json:"name"`
+	Today UsageTotals `json:"today"`
+	Total UsageTotals `json:"total"`
+}
+
+// collectSessionUsage returns usage for every session, sorted by total cost
+func collectSessionUsage(config *Config, now time.Time) []SessionUsage {
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var usage []SessionUsage
+	for name, info := range config.Sessions {
+		if info == nil || info.Path == "" {
+			continue
+		}
+		usage = append(usage, SessionUsage{
+			Name:  name,
+			Today: projectUsage(info.Path, startOfDay),
+			Total: projectUsage(info.Path, time.Time{}),
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Total.CostUSD != usage[j].Total.CostUSD {
+			return usage[i].Total.CostUSD > usage[j].Total.CostUSD
+		}
+		return usage[i].Name < usage[j].Name
+	})
+	return usage
+}
+
+// formatTokens renders a token count as 950, 12.3k or 4.5M
+func formatTokens(n int64) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+func formatUsageLine(u UsageTotals) string {
+	return fmt.Sprintf("$%.2f · %s in / %s out · %s cached", u.CostUSD,
+		formatTokens(u.InputTokens+u.CacheCreationTokens), formatTokens(u.OutputTokens), formatTokens(u.CacheReadTokens))
+}
+
+// formatCostReport renders today's total followed by per-session totals
+func formatCostReport(usage []SessionUsage) string {
+	if len(usage) == 0 {
+		return "No sessions."
+	}
+	var today UsageTotals
+	for _, u := range usage {
+		today.add(u.Today)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💰 Today: %s\n\nPer session (today / all time):\n", formatUsageLine(today)))
+	for _, u := range usage {
+		if u.Total.totalTokens() == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n%s\n  today: %s\n  total: %s\n", u.Name, formatUsageLine(u.Today), formatUsageLine(u.Total)))
+	}
+	sb.WriteString("\nCosts are estimates from list prices.")
+	return sb.String()
+}
+
+// formatSessionCost renders one session's usage
+func formatSessionCost(u SessionUsage) string {
+	return fmt.Sprintf("💰 %s\n\ntoday: %s\ntotal: %s\n\nCosts are estimates from list prices.",
+		u.Name, formatUsageLine(u.Today), formatUsageLine(u.Total))
+}
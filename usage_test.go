@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanTranscriptUsage(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "session.jsonl")
+	lines := []string{
+		`{"type":"user","timestamp":"2026-01-02T10:00:00Z","message":{"role":"user","content":"hi"}}`,
+		// Same message split over two lines: counted once
+		`{"type":"assistant","timestamp":"2026-01-02T10:00:05Z","message":{"id":"msg_1","model":"claude-sonnet-4-5","usage":{"input_tokens":1000,"output_tokens":500,"cache_read_input_tokens":10000}}}`,
+		`{"type":"assistant","timestamp":"2026-01-02T10:00:06Z","message":{"id":"msg_1","model":"claude-sonnet-4-5","usage":{"input_tokens":1000,"output_tokens":500,"cache_read_input_tokens":10000}}}`,
+		`{"type":"assistant","timestamp":"2026-01-01T09:00:00Z","costUSD":0.5,"message":{"id":"msg_0","model":"claude-opus-4-1","usage":{"input_tokens":10,"output_tokens":20}}}`,
+		`not json`,
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatalf("Failed to write transcript: %v", err)
+	}
+
+	total, err := scanTranscriptUsage(path, time.Time{})
+	if err != nil {
+		t.Fatalf("scanTranscriptUsage failed: %v", err)
+	}
+	if total.InputTokens != 1010 || total.OutputTokens != 520 || total.CacheReadTokens != 10000 {
+		t.Errorf("totals = %+v, want duplicates counted once", total)
+	}
+	// Sonnet: 1000*3 + 500*15 + 10000*0.3 per MTok = 0.0135, plus the recorded 0.5
+	if math.Abs(total.CostUSD-0.5135) > 1e-9 {
+		t.Errorf("CostUSD = %v, want 0.5135", total.CostUSD)
+	}
+
+	since := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	today, err := scanTranscriptUsage(path, since)
+	if err != nil {
+		t.Fatalf("scanTranscriptUsage failed: %v", err)
+	}
+	if today.InputTokens != 1000 || today.OutputTokens != 500 {
+		t.Errorf("today = %+v, want only entries after the cutoff", today)
+	}
+}
+
+func TestClaudeProjectDir(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	got := claudeProjectDir("/home/user/my.project_x")
+	want := "/home/user/.claude/projects/-home-user-my-project-x"
+	if got != want {
+		t.Errorf("claudeProjectDir = %q, want %q", got, want)
+	}
+}
+
+func TestFormatTokens(t *testing.T) {
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{950, "950"},
+		{12345, "12.3k"},
+		{4500000, "4.5M"},
+	}
+
+	for _, tt := range tests {
+		if result := formatTokens(tt.n); result != tt.expected {
+			t.Errorf("formatTokens(%d) = %q, want %q", tt.n, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// usageResumeDelay is how long after the announced reset a session is
+// resumed, in case the clocks disagree a little
+const usageResumeDelay = time.Minute
+
+// usageResumePrompt is typed into a session when its usage limit resets.
+// Claude still has the interrupted turn in its context and picks it up.
+const usageResumePrompt = "continue"
+
+// limitResetTime matches the reset time in a usage limit message: "resets
+// 3pm", "reset at 3:30pm (Europe/Berlin)", "resets at 15:00"
+var limitResetTime = regexp.MustCompile(`(?i)\bresets?(?:\s+at)?\s+(\d{1,2})(?::(\d{2}))?\s*(am|pm)?(?:\s*\(([^)]+)\))?`)
+
+var (
+	// usageResumes holds when each session stopped by the usage limit can
+	// go on. Queued messages wait until then.
+	usageResumes   = make(map[string]time.Time)
+	usageResumesMu sync.Mutex
+)
+
+// parseLimitReset returns the next time matching the reset time in a usage
+// limit message, in the time zone it names or else the local one
+func parseLimitReset(line string, now time.Time) (time.Time, bool) {
+	m := limitResetTime.FindStringSubmatch(line)
+	if m == nil {
+		return time.Time{}, false
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	switch strings.ToLower(m[3]) {
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return time.Time{}, false
+	}
+
+	loc := now.Location()
+	if m[4] != "" {
+		if l, err := time.LoadLocation(m[4]); err == nil {
+			loc = l
+		}
+	}
+	local := now.In(loc)
+	reset := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	if !reset.After(now) {
+		reset = reset.AddDate(0, 0, 1)
+	}
+	return reset, true
+}
+
+// noteUsageLimit records when a session's usage limit resets and tells the
+// topic what will happen then
+func noteUsageLimit(config *Config, sessName string, info *SessionInfo, line string) {
+	reset, ok := parseLimitReset(line, time.Now())
+	if !ok {
+		return
+	}
+	usageResumesMu.Lock()
+	usageResumes[sessName] = reset
+	usageResumesMu.Unlock()
+
+	when := fmt.Sprintf("%s (in %s)", reset.Local().Format("15:04"), formatDuration(time.Until(reset)))
+	msg := "⏰ The limit resets at " + when + ". Queued messages wait until then, and ccc config auto-resume on resumes the session by itself."
+	if config.AutoResume {
+		msg = "⏰ Resuming at " + when + ", when the limit resets."
+	}
+	getMessenger(config).Send(sessionGroup(config, info), info.TopicID, msg)
+}
+
+// usageLimited reports whether a session is waiting for its usage limit to reset
+func usageLimited(sessName string) bool {
+	usageResumesMu.Lock()
+	defer usageResumesMu.Unlock()
+	_, ok := usageResumes[sessName]
+	return ok
+}
+
+// checkUsageResume resumes a session whose usage limit has reset, typing
+// usageResumePrompt when auto_resume is on and the pane still shows the
+// limit (nobody resumed it by hand). Either way queued messages are
+// delivered again.
+func checkUsageResume(config *Config, sessName string, info *SessionInfo, mon *SessionMonitor, tmuxName string, now time.Time) {
+	usageResumesMu.Lock()
+	reset, ok := usageResumes[sessName]
+	usageResumesMu.Unlock()
+	if !ok || now.Before(reset.Add(usageResumeDelay)) {
+		return
+	}
+	// Wait while Claude is busy again or something is typed in the pane
+	if config.AutoResume && !isClaudeIdle(tmuxName) {
+		return
+	}
+	usageResumesMu.Lock()
+	delete(usageResumes, sessName)
+	usageResumesMu.Unlock()
+	monitorsMu.Lock()
+	limited := mon.Alert == paneErrorOfKind(paneErrorUsageLimit).Title
+	monitorsMu.Unlock()
+	if !config.AutoResume || !limited {
+		return
+	}
+	hookLog("monitor: session=%s usage limit reset, resuming", sessName)
+	if err := typeIntoSession(sessName, usageResumePrompt); err != nil {
+		getMessenger(config).Send(sessionGroup(config, info), info.TopicID, fmt.Sprintf("❌ Failed to resume after the usage limit: %v", err))
+		return
+	}
+	getMessenger(config).Send(sessionGroup(config, info), info.TopicID, "▶️ Usage limit reset, resuming")
+}
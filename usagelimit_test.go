@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimitReset(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	now := time.Date(2026, 3, 10, 13, 20, 0, 0, time.UTC)
+	tests := []struct {
+		line string
+		want time.Time
+	}{
+		{"● Claude usage limit reached. Your limit will reset at 3pm.", time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)},
+		{"5-hour limit reached ∙ resets 3:30pm", time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)},
+		{"Your limit resets at 12am", time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{"limit resets 9am", time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC)},
+		{"limit will reset at 15:00", time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)},
+		{"resets 4pm (Europe/Berlin)", time.Date(2026, 3, 10, 16, 0, 0, 0, berlin)},
+		{"resets 2pm (Europe/Berlin)", time.Date(2026, 3, 11, 14, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		got, ok := parseLimitReset(tt.line, now)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("parseLimitReset(%q) = %v, %v, want %v", tt.line, got, ok, tt.want)
+		}
+	}
+	for _, line := range []string{"Claude usage limit reached.", "resets 25pm"} {
+		if _, ok := parseLimitReset(line, now); ok {
+			t.Errorf("parseLimitReset(%q) found a time", line)
+		}
+	}
+}
+
+func TestUsageLimitHoldsQueue(t *testing.T) {
+	usageResumesMu.Lock()
+	usageResumes["limited"] = time.Now().Add(-2 * usageResumeDelay)
+	usageResumesMu.Unlock()
+	if !usageLimited("limited") {
+		t.Fatal("a session waiting for its limit should hold its queue")
+	}
+
+	// With auto_resume off the wait ends without typing anything
+	mon := &SessionMonitor{Alert: paneErrorOfKind(paneErrorUsageLimit).Title}
+	checkUsageResume(&Config{}, "limited", &SessionInfo{TopicID: 1}, mon, "ccc-limited", time.Now())
+	if usageLimited("limited") {
+		t.Error("the queue should be released once the limit resets")
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// defaultWatchdogMinutes is how long polling or the session monitor may go
+// without progress before the listener restarts itself
+const defaultWatchdogMinutes = 10
+
+// watchdogInterval is how often the watchdog checks for a stall
+const watchdogInterval = time.Minute
+
+// Unix nanoseconds of the last successful getUpdates poll and the last
+// session monitor pass, updated by the loops the watchdog watches
+var (
+	lastPollAt        int64
+	lastMonitorTickAt int64
+)
+
+func markPoll()        { atomic.StoreInt64(&lastPollAt, time.Now().UnixNano()) }
+func markMonitorTick() { atomic.StoreInt64(&lastMonitorTickAt, time.Now().UnixNano()) }
+
+// watchdogLimit returns the configured stall limit, 0 when the watchdog is off
+func watchdogLimit(config *Config) time.Duration {
+	switch {
+	case config.WatchdogMinutes < 0:
+		return 0
+	case config.WatchdogMinutes == 0:
+		return defaultWatchdogMinutes * time.Minute
+	}
+	return time.Duration(config.WatchdogMinutes) * time.Minute
+}
+
+// watchdogStall returns why the listener looks stuck, or "" when polling and
+// the monitor have both made progress within limit
+func watchdogStall(now, lastPoll, lastTick time.Time, limit time.Duration) string {
+	if since := now.Sub(lastPoll); since > limit {
+		return fmt.Sprintf("no successful Telegram poll for %s", formatDuration(since))
+	}
+	if since := now.Sub(lastTick); since > limit {
+		return fmt.Sprintf("session monitor stalled for %s", formatDuration(since))
+	}
+	return ""
+}
+
+// startWatchdog restarts the listener, after telling the private chat, when
+// getUpdates or the session monitor stop making progress
+func startWatchdog(config *Config) {
+	limit := watchdogLimit(config)
+	if limit == 0 {
+		return
+	}
+	markPoll()
+	markMonitorTick()
+	for range time.Tick(watchdogInterval) {
+		reason := watchdogStall(time.Now(),
+			time.Unix(0, atomic.LoadInt64(&lastPollAt)),
+			time.Unix(0, atomic.LoadInt64(&lastMonitorTickAt)), limit)
+		if reason == "" {
+			continue
+		}
+		logf(levelError, "watchdog restarting listener", "reason", reason)
+		sendMessage(config, config.ChatID, 0, fmt.Sprintf("🐕 Watchdog: %s — restarting ccc", reason))
+		restartListener()
+	}
+}
+
+// readLockPID returns the PID the listener wrote to its lock file
+func readLockPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("no PID in %s", path)
+	}
+	return pid, nil
+}
+
+// processAlive reports whether a process with this PID exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// handleHealthCommand implements `ccc health`: it fails unless the listener
+// holding the lock file is alive
+func handleHealthCommand() error {
+	path := stateFile(".lock")
+	pid, err := readLockPID(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("listener not running (no %s)", path)
+		}
+		return fmt.Errorf("listener not running: %w", err)
+	}
+	if !processAlive(pid) {
+		return fmt.Errorf("listener not running (pid %d from %s is gone)", pid, path)
+	}
+	fmt.Printf("✅ listener running (pid %d)\n", pid)
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdogStall(t *testing.T) {
+	now := time.Now()
+	limit := 10 * time.Minute
+	if got := watchdogStall(now, now.Add(-time.Minute), now.Add(-3*time.Second), limit); got != "" {
+		t.Errorf("healthy listener reported stall: %q", got)
+	}
+	if got := watchdogStall(now, now.Add(-11*time.Minute), now, limit); !strings.Contains(got, "Telegram poll") {
+		t.Errorf("stale poll = %q", got)
+	}
+	if got := watchdogStall(now, now, now.Add(-15*time.Minute), limit); !strings.Contains(got, "monitor") {
+		t.Errorf("stalled monitor = %q", got)
+	}
+}
+
+func TestWatchdogLimit(t *testing.T) {
+	cases := map[int]time.Duration{0: defaultWatchdogMinutes * time.Minute, 3: 3 * time.Minute, -1: 0}
+	for minutes, want := range cases {
+		if got := watchdogLimit(&Config{WatchdogMinutes: minutes}); got != want {
+			t.Errorf("watchdogLimit(%d) = %v, want %v", minutes, got, want)
+		}
+	}
+}
+
+func TestReadLockPID(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ccc.lock")
+	if _, err := readLockPID(path); !os.IsNotExist(err) {
+		t.Errorf("missing lock file: %v", err)
+	}
+	os.WriteFile(path, []byte("\n"), 0600)
+	if _, err := readLockPID(path); err == nil {
+		t.Error("expected error for empty lock file")
+	}
+	os.WriteFile(path, []byte("4242\n"), 0600)
+	if pid, err := readLockPID(path); err != nil || pid != 4242 {
+		t.Errorf("readLockPID = %d, %v", pid, err)
+	}
+	if !processAlive(os.Getpid()) {
+		t.Error("own process reported dead")
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxTimelineEvents is how many recent events a session timeline shows
+const maxTimelineEvents = 100
+
+// TimelineEvent is one step of a session's activity: a prompt, a tool call or a reply
+type TimelineEvent struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"` // "prompt", "tool" or "reply"
+	Text string    `json:"text"`
+}
+
+// readTimeline extracts the last limit prompts, tool calls and replies from a
+// transcript
+func readTimeline(path string, limit int) ([]TimelineEvent, error) {
+	events, err := readTranscriptEvents(path, 500)
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events, err
+}
+
+// readTranscriptEvents extracts every prompt, tool call and reply from a
+// transcript, cutting texts to maxText (0 keeps them whole). Tool results come
+// back as user entries and are skipped.
+func readTranscriptEvents(path string, maxText int) ([]TimelineEvent, error) {
+	clip := func(s string) string {
+		if maxText > 0 {
+			return truncate(s, maxText)
+		}
+		return s
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []TimelineEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			Type      string    `json:"type"`
+			Timestamp time.Time `json:"timestamp"`
+			Message   struct {
+				Content json.RawMessage `json:"content"`
+			} `json:"message"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || (entry.Type != "user" && entry.Type != "assistant") {
+			continue
+		}
+
+		// User prompts are usually a plain string; everything else is a list of parts
+		var text string
+		if json.Unmarshal(entry.Message.Content, &text) == nil {
+			if entry.Type == "user" && strings.TrimSpace(text) != "" {
+				events = append(events, TimelineEvent{entry.Timestamp, "prompt", clip(text)})
+			}
+			continue
+		}
+		var parts []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		}
+		if json.Unmarshal(entry.Message.Content, &parts) != nil {
+			continue
+		}
+		for _, p := range parts {
+			switch {
+			case p.Type == "text" && strings.TrimSpace(p.Text) != "":
+				kind := "reply"
+				if entry.Type == "user" {
+					kind = "prompt"
+				}
+				events = append(events, TimelineEvent{entry.Timestamp, kind, clip(p.Text)})
+			case p.Type == "tool_use" && entry.Type == "assistant":
+				summary := summarizeToolInput(p.Name, []byte(fmt.Sprintf(`{"tool_input":%s}`, p.Input)))
+				events = append(events, TimelineEvent{entry.Timestamp, "tool", strings.TrimSpace(p.Name + " " + summary)})
+			}
+		}
+	}
+	return events, scanner.Err()
+}
+
+// latestTranscript returns the most recently written transcript of a project
+func latestTranscript(workDir string) string {
+	files, _ := filepath.Glob(filepath.Join(claudeProjectDir(workDir), "*.jsonl"))
+	var latest string
+	var latestMod time.Time
+	for _, file := range files {
+		if st, err := os.Stat(file); err == nil && st.ModTime().After(latestMod) {
+			latest, latestMod = file, st.ModTime()
+		}
+	}
+	return latest
+}
+
+// sendPromptFromWeb delivers a prompt through the listener so it's queued while
+// Claude is busy, typing it directly when no listener is running
+func sendPromptFromWeb(name, text string) error {
+	err := callControl("send", map[string]string{"session": name, "text": text}, nil, 30*time.Second)
+	if err != errControlUnavailable {
+		return err
+	}
+	if !tmuxSessionExists(sessionName(name)) {
+		return fmt.Errorf("session %s is not running", name)
+	}
+	return typeIntoSession(name, text)
+}
+
+// isLocalHost reports whether a Host header names this machine, so pages
+// served to other origins via DNS rebinding can't call the API
+func isLocalHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// newWebMux serves the dashboard page and its JSON API:
+//
+//	GET  /api/sessions               session list with state
+//	GET  /api/sessions/<name>/blocks parsed output blocks
+//	GET  /api/sessions/<name>/timeline
+//	POST /api/sessions/<name>/send   {"text": "..."}
+func newWebMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	writeJSON := func(w http.ResponseWriter, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, webDashboardHTML)
+	})
+
+	mux.HandleFunc("/api/sessions", func(w http.ResponseWriter, r *http.Request) {
+		config, err := loadConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, collectSessionStatuses(config))
+	})
+
+	mux.HandleFunc("/api/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/sessions/"), "/")
+		if len(parts) != 2 {
+			http.NotFound(w, r)
+			return
+		}
+		config, err := loadConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		name, action := parts[0], parts[1]
+		info := config.Sessions[name]
+		if info == nil {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+
+		switch action {
+		case "blocks":
+			blocks := []string{}
+			if tmuxName := sessionName(name); tmuxSessionExists(tmuxName) {
+				blocks = append(blocks, getLastBlocksFromTmux(tmuxName)...)
+			}
+			writeJSON(w, blocks)
+		case "timeline":
+			events := []TimelineEvent{}
+			if path := latestTranscript(info.Path); path != "" {
+				if e, err := readTimeline(path, maxTimelineEvents); err == nil && e != nil {
+					events = e
+				}
+			}
+			writeJSON(w, events)
+		case "send":
+			// A JSON content type can't be sent cross-origin without a preflight,
+			// which this server never approves
+			if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+				http.Error(w, "POST application/json required", http.StatusMethodNotAllowed)
+				return
+			}
+			var body struct {
+				Text string `json:"text"`
+			}
+			if err := json.NewDecoder(io.LimitReader(r.Body, maxResponseSize)).Decode(&body); err != nil || strings.TrimSpace(body.Text) == "" {
+				http.Error(w, "Missing text", http.StatusBadRequest)
+				return
+			}
+			if err := sendPromptFromWeb(name, body.Text); err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			writeJSON(w, map[string]bool{"ok": true})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	return mux
+}
+
+// webTokenCookie holds the dashboard's token once the printed link was opened
+const webTokenCookie = "ccc_web_token"
+
+// newWebHandler guards the dashboard: other local users and processes can
+// reach 127.0.0.1 too, so every request needs the per-run token, from the
+// printed link (then kept in a cookie). Requests for other hosts or from
+// other origins are refused, against DNS rebinding.
+func newWebHandler(token string) http.Handler {
+	mux := newWebMux()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLocalHost(r.Host) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || !isLocalHost(u.Host) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		if q := r.URL.Query().Get("token"); q != "" && subtle.ConstantTimeCompare([]byte(q), []byte(token)) == 1 {
+			http.SetCookie(w, &http.Cookie{Name: webTokenCookie, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+			// Drop the token from the address bar and history
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+		c, err := r.Cookie(webTokenCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(c.Value), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized: open the link ccc web printed", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// runWebServer serves the dashboard on localhost only
+func runWebServer(port string) error {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+	addr := "127.0.0.1:" + port
+	fmt.Printf("Dashboard at http://localhost:%s/?token=%s (Ctrl+C to stop)\n", port, token)
+	return http.ListenAndServe(addr, newWebHandler(token))
+}
+
+const webDashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>ccc</title>
+<style>
+body { margin: 0; font: 14px -apple-system, system-ui, sans-serif; display: flex; height: 100vh; color: #222; }
+nav { width: 220px; border-right: 1px solid #ddd; overflow-y: auto; background: #fafafa; }
+nav div { padding: 10px 14px; cursor: pointer; border-bottom: 1px solid #eee; }
+nav div.sel { background: #e8f0fe; }
+.state { font-size: 12px; color: #777; }
+main { flex: 1; display: flex; flex-direction: column; min-width: 0; }
+#panes { flex: 1; display: flex; min-height: 0; }
+#blocks, #timeline { flex: 1; overflow-y: auto; padding: 12px; }
+#timeline { border-left: 1px solid #ddd; max-width: 40%; }
+pre { white-space: pre-wrap; background: #f6f8fa; padding: 8px; border-radius: 4px; margin: 0 0 10px; }
+.ev { margin-bottom: 8px; } .ev time { color: #999; font-size: 12px; margin-right: 6px; }
+.prompt { color: #1a56db; } .tool { color: #777; font-family: monospace; }
+form { display: flex; border-top: 1px solid #ddd; }
+textarea { flex: 1; border: 0; padding: 10px; font: inherit; resize: none; height: 60px; }
+button { padding: 0 20px; }
+h3 { margin: 0 0 8px; font-size: 13px; color: #555; }
+</style>
+</head>
+<body>
+<nav id="sessions"></nav>
+<main>
+<div id="panes"><div id="blocks"></div><div id="timeline"></div></div>
+<form id="send"><textarea id="text" placeholder="Send a prompt (Ctrl+Enter)"></textarea><button>Send</button></form>
+</main>
+<script>
+let current = null;
+const el = (tag, cls, text) => { const e = document.createElement(tag); if (cls) e.className = cls; if (text != null) e.textContent = text; return e; };
+async function get(path) { const r = await fetch(path); if (!r.ok) throw new Error(await r.text()); return r.json(); }
+async function loadSessions() {
+  const list = await get('/api/sessions');
+  const nav = document.getElementById('sessions');
+  nav.replaceChildren(...list.map(s => {
+    const d = el('div', s.name === current ? 'sel' : '');
+    d.append(el('div', '', s.name), el('span', 'state', s.state));
+    d.onclick = () => { current = s.name; refresh(); };
+    return d;
+  }));
+  if (!current && list.length) { current = list[0].name; refresh(); }
+}
+async function loadSession() {
+  if (!current) return;
+  const name = encodeURIComponent(current);
+  const [blocks, events] = await Promise.all([get('/api/sessions/' + name + '/blocks'), get('/api/sessions/' + name + '/timeline')]);
+  const b = document.getElementById('blocks');
+  const atBottom = b.scrollTop + b.clientHeight >= b.scrollHeight - 20;
+  b.replaceChildren(el('h3', '', current), ...blocks.map(t => el('pre', '', t)));
+  if (atBottom) b.scrollTop = b.scrollHeight;
+  const t = document.getElementById('timeline');
+  t.replaceChildren(el('h3', '', 'Timeline'), ...events.slice().reverse().map(e => {
+    const d = el('div', 'ev ' + e.kind);
+    d.append(el('time', '', new Date(e.time).toLocaleTimeString()), document.createTextNode(e.text));
+    return d;
+  }));
+}
+function refresh() { loadSessions().catch(console.error); loadSession().catch(console.error); }
+document.getElementById('send').onsubmit = async ev => {
+  ev.preventDefault();
+  const text = document.getElementById('text');
+  if (!current || !text.value.trim()) return;
+  const r = await fetch('/api/sessions/' + encodeURIComponent(current) + '/send', {
+    method: 'POST', headers: {'Content-Type': 'application/json'}, body: JSON.stringify({text: text.value})});
+  if (r.ok) text.value = ''; else alert(await r.text());
+  refresh();
+};
+document.getElementById('text').onkeydown = ev => { if (ev.key === 'Enter' && ev.ctrlKey) document.getElementById('send').requestSubmit(); };
+refresh();
+setInterval(refresh, 3000);
+</script>
+</body>
+</html>
+`
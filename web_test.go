@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadTimeline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "t.jsonl")
+	content := `{"type":"user","timestamp":"2026-01-02T10:00:00Z","message":{"content":"fix the tests"}}
+{"type":"assistant","timestamp":"2026-01-02T10:00:05Z","message":{"content":[{"type":"text","text":"Looking."},{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]}}
+{"type":"user","timestamp":"2026-01-02T10:00:09Z","message":{"content":[{"type":"tool_result","content":"ok"}]}}
+{"type":"summary","summary":"ignored"}
+not json
+{"type":"assistant","timestamp":"2026-01-02T10:00:12Z","message":{"content":[{"type":"text","text":"All passing."}]}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := readTimeline(path, 10)
+	if err != nil {
+		t.Fatalf("readTimeline: %v", err)
+	}
+	want := []struct{ kind, text string }{
+		{"prompt", "fix the tests"},
+		{"reply", "Looking."},
+		{"tool", "Bash go test ./..."},
+		{"reply", "All passing."},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events %+v, want %d", len(events), events, len(want))
+	}
+	for i, w := range want {
+		if events[i].Kind != w.kind || events[i].Text != w.text {
+			t.Errorf("event %d = %s %q, want %s %q", i, events[i].Kind, events[i].Text, w.kind, w.text)
+		}
+	}
+	if events[0].Time.IsZero() {
+		t.Error("event time not parsed")
+	}
+
+	events, _ = readTimeline(path, 2)
+	if len(events) != 2 || events[1].Text != "All passing." {
+		t.Errorf("limited timeline = %+v, want the last 2 events", events)
+	}
+}
+
+func TestWebMux(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	data := []byte(`{"bot_token": "test", "chat_id": 123, "sessions": {"proj": {"topic_id": 100, "path": "/nonexistent/proj"}}}`)
+	if err := os.WriteFile(filepath.Join(tmpDir, ".ccc.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	mux := newWebMux()
+
+	tests := []struct {
+		method, path, contentType string
+		wantStatus                int
+		wantBody                  string
+	}{
+		{"GET", "/", "", http.StatusOK, "<title>ccc</title>"},
+		{"GET", "/api/sessions", "", http.StatusOK, `"name":"proj"`},
+		{"GET", "/api/sessions/proj/timeline", "", http.StatusOK, "[]"},
+		{"GET", "/api/sessions/missing/blocks", "", http.StatusNotFound, ""},
+		{"GET", "/api/sessions/proj/send", "", http.StatusMethodNotAllowed, ""},
+		{"POST", "/api/sessions/proj/send", "text/plain", http.StatusMethodNotAllowed, ""},
+		{"POST", "/api/sessions/proj/send", "application/json", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(""))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.wantStatus)
+		}
+		if tt.wantBody != "" && !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("%s %s body = %q, want it to contain %q", tt.method, tt.path, rec.Body.String(), tt.wantBody)
+		}
+	}
+}
+
+func TestWebHandlerToken(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	os.WriteFile(filepath.Join(tmpDir, ".ccc.json"), []byte(`{"bot_token": "test", "chat_id": 123}`), 0600)
+	handler := newWebHandler("s3cret")
+
+	serve := func(path, cookie, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://localhost:8377"+path, nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: webTokenCookie, Value: cookie})
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("/api/sessions", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d, want 401", rec.Code)
+	}
+	if rec := serve("/api/sessions", "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("with a wrong cookie: status %d, want 401", rec.Code)
+	}
+	rec := serve("/?token=s3cret", "", "")
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/" {
+		t.Errorf("printed link: status %d, location %q; want a redirect to /", rec.Code, rec.Header().Get("Location"))
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].Value != "s3cret" || !cookies[0].HttpOnly {
+		t.Errorf("printed link set cookies %v, want the token in an HttpOnly cookie", cookies)
+	}
+	if rec := serve("/api/sessions", "s3cret", ""); rec.Code != http.StatusOK {
+		t.Errorf("with the cookie: status %d, want 200", rec.Code)
+	}
+	if rec := serve("/api/sessions", "s3cret", "http://evil.example"); rec.Code != http.StatusForbidden {
+		t.Errorf("from another origin: status %d, want 403", rec.Code)
+	}
+}
+
+func TestIsLocalHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost:8377": true,
+		"127.0.0.1:8377": true,
+		"[::1]:8377":     true,
+		"localhost":      true,
+		"evil.com:8377":  false,
+		"192.168.1.5":    false,
+	} {
+		if got := isLocalHost(host); got != want {
+			t.Errorf("isLocalHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}